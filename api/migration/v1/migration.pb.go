@@ -0,0 +1,778 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v5.29.3
+// source: api/migration/v1/migration.proto
+
+// The control API of the serve command (--grpc-listen). Clients authenticate with a
+// certificate signed by the server's --grpc-client-ca
+
+package migrationv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ScriptProgress_State int32
+
+const (
+	ScriptProgress_STATE_UNSPECIFIED ScriptProgress_State = 0
+	ScriptProgress_STATE_EXECUTING   ScriptProgress_State = 1
+	ScriptProgress_STATE_SUCCESS     ScriptProgress_State = 2
+	ScriptProgress_STATE_FAILED      ScriptProgress_State = 3
+	// Failed, but onError=continue
+	ScriptProgress_STATE_TOLERATED ScriptProgress_State = 4
+	// skip-if guard matched
+	ScriptProgress_STATE_SKIPPED ScriptProgress_State = 5
+	// Held back by --tags/--skip-tags
+	ScriptProgress_STATE_DEFERRED ScriptProgress_State = 6
+	// The batch stopped at an earlier failure
+	ScriptProgress_STATE_NOT_RUN ScriptProgress_State = 7
+)
+
+// Enum value maps for ScriptProgress_State.
+var (
+	ScriptProgress_State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "STATE_EXECUTING",
+		2: "STATE_SUCCESS",
+		3: "STATE_FAILED",
+		4: "STATE_TOLERATED",
+		5: "STATE_SKIPPED",
+		6: "STATE_DEFERRED",
+		7: "STATE_NOT_RUN",
+	}
+	ScriptProgress_State_value = map[string]int32{
+		"STATE_UNSPECIFIED": 0,
+		"STATE_EXECUTING":   1,
+		"STATE_SUCCESS":     2,
+		"STATE_FAILED":      3,
+		"STATE_TOLERATED":   4,
+		"STATE_SKIPPED":     5,
+		"STATE_DEFERRED":    6,
+		"STATE_NOT_RUN":     7,
+	}
+)
+
+func (x ScriptProgress_State) Enum() *ScriptProgress_State {
+	p := new(ScriptProgress_State)
+	*p = x
+	return p
+}
+
+func (x ScriptProgress_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ScriptProgress_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_migration_v1_migration_proto_enumTypes[0].Descriptor()
+}
+
+func (ScriptProgress_State) Type() protoreflect.EnumType {
+	return &file_api_migration_v1_migration_proto_enumTypes[0]
+}
+
+func (x ScriptProgress_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ScriptProgress_State.Descriptor instead.
+func (ScriptProgress_State) EnumDescriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type PlanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanRequest) Reset() {
+	*x = PlanRequest{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanRequest) ProtoMessage() {}
+
+func (x *PlanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanRequest.ProtoReflect.Descriptor instead.
+func (*PlanRequest) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{0}
+}
+
+type PlanResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Database   string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	FromCommit string                 `protobuf:"bytes,2,opt,name=from_commit,json=fromCommit,proto3" json:"from_commit,omitempty"`
+	ToCommit   string                 `protobuf:"bytes,3,opt,name=to_commit,json=toCommit,proto3" json:"to_commit,omitempty"`
+	Changes    []*PlannedChange       `protobuf:"bytes,4,rep,name=changes,proto3" json:"changes,omitempty"`
+	// Problems that would stop the run
+	Errors []string `protobuf:"bytes,5,rep,name=errors,proto3" json:"errors,omitempty"`
+	// Highest risk of the changes: low, medium or high
+	Risk          string `protobuf:"bytes,6,opt,name=risk,proto3" json:"risk,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlanResponse) Reset() {
+	*x = PlanResponse{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlanResponse) ProtoMessage() {}
+
+func (x *PlanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlanResponse.ProtoReflect.Descriptor instead.
+func (*PlanResponse) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PlanResponse) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *PlanResponse) GetFromCommit() string {
+	if x != nil {
+		return x.FromCommit
+	}
+	return ""
+}
+
+func (x *PlanResponse) GetToCommit() string {
+	if x != nil {
+		return x.ToCommit
+	}
+	return ""
+}
+
+func (x *PlanResponse) GetChanges() []*PlannedChange {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *PlanResponse) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *PlanResponse) GetRisk() string {
+	if x != nil {
+		return x.Risk
+	}
+	return ""
+}
+
+// PlannedChange is one script the next apply would execute
+type PlannedChange struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Script string                 `protobuf:"bytes,1,opt,name=script,proto3" json:"script,omitempty"`
+	// create (first run) or update (changed repeatable)
+	Action        string   `protobuf:"bytes,2,opt,name=action,proto3" json:"action,omitempty"`
+	Risk          string   `protobuf:"bytes,3,opt,name=risk,proto3" json:"risk,omitempty"`
+	Reasons       []string `protobuf:"bytes,4,rep,name=reasons,proto3" json:"reasons,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlannedChange) Reset() {
+	*x = PlannedChange{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlannedChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlannedChange) ProtoMessage() {}
+
+func (x *PlannedChange) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlannedChange.ProtoReflect.Descriptor instead.
+func (*PlannedChange) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PlannedChange) GetScript() string {
+	if x != nil {
+		return x.Script
+	}
+	return ""
+}
+
+func (x *PlannedChange) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *PlannedChange) GetRisk() string {
+	if x != nil {
+		return x.Risk
+	}
+	return ""
+}
+
+func (x *PlannedChange) GetReasons() []string {
+	if x != nil {
+		return x.Reasons
+	}
+	return nil
+}
+
+type ApplyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyRequest) Reset() {
+	*x = ApplyRequest{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRequest) ProtoMessage() {}
+
+func (x *ApplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRequest.ProtoReflect.Descriptor instead.
+func (*ApplyRequest) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{3}
+}
+
+// ScriptProgress reports a pending script starting or finishing
+type ScriptProgress struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	BatchId string                 `protobuf:"bytes,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Script  string                 `protobuf:"bytes,2,opt,name=script,proto3" json:"script,omitempty"`
+	State   ScriptProgress_State   `protobuf:"varint,3,opt,name=state,proto3,enum=dbmigration.v1.ScriptProgress_State" json:"state,omitempty"`
+	// Position of the script among the pending ones, from 1, and their number
+	Index int32 `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"`
+	Total int32 `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	// Set once the script finished
+	Duration      *durationpb.Duration `protobuf:"bytes,6,opt,name=duration,proto3" json:"duration,omitempty"`
+	Error         string               `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScriptProgress) Reset() {
+	*x = ScriptProgress{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScriptProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScriptProgress) ProtoMessage() {}
+
+func (x *ScriptProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScriptProgress.ProtoReflect.Descriptor instead.
+func (*ScriptProgress) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ScriptProgress) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *ScriptProgress) GetScript() string {
+	if x != nil {
+		return x.Script
+	}
+	return ""
+}
+
+func (x *ScriptProgress) GetState() ScriptProgress_State {
+	if x != nil {
+		return x.State
+	}
+	return ScriptProgress_STATE_UNSPECIFIED
+}
+
+func (x *ScriptProgress) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ScriptProgress) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ScriptProgress) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *ScriptProgress) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type GetHistoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Return only the last limit records; 0 returns all
+	Limit         int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHistoryRequest) Reset() {
+	*x = GetHistoryRequest{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryRequest) ProtoMessage() {}
+
+func (x *GetHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetHistoryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*Record              `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHistoryResponse) Reset() {
+	*x = GetHistoryResponse{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryResponse) ProtoMessage() {}
+
+func (x *GetHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetHistoryResponse) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+// Record is a row of the tracking table
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sno           int64                  `protobuf:"varint,1,opt,name=sno,proto3" json:"sno,omitempty"`
+	Script        string                 `protobuf:"bytes,2,opt,name=script,proto3" json:"script,omitempty"`
+	Action        string                 `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Completed     bool                   `protobuf:"varint,4,opt,name=completed,proto3" json:"completed,omitempty"`
+	EndOfBatch    bool                   `protobuf:"varint,5,opt,name=end_of_batch,json=endOfBatch,proto3" json:"end_of_batch,omitempty"`
+	BatchId       string                 `protobuf:"bytes,6,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Commit        string                 `protobuf:"bytes,7,opt,name=commit,proto3" json:"commit,omitempty"`
+	Checksum      string                 `protobuf:"bytes,8,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	Tickets       string                 `protobuf:"bytes,9,opt,name=tickets,proto3" json:"tickets,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ModifiedAt    *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=modified_at,json=modifiedAt,proto3" json:"modified_at,omitempty"`
+	ApprovedBy    string                 `protobuf:"bytes,12,opt,name=approved_by,json=approvedBy,proto3" json:"approved_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_api_migration_v1_migration_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_api_migration_v1_migration_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_api_migration_v1_migration_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Record) GetSno() int64 {
+	if x != nil {
+		return x.Sno
+	}
+	return 0
+}
+
+func (x *Record) GetScript() string {
+	if x != nil {
+		return x.Script
+	}
+	return ""
+}
+
+func (x *Record) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *Record) GetCompleted() bool {
+	if x != nil {
+		return x.Completed
+	}
+	return false
+}
+
+func (x *Record) GetEndOfBatch() bool {
+	if x != nil {
+		return x.EndOfBatch
+	}
+	return false
+}
+
+func (x *Record) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *Record) GetCommit() string {
+	if x != nil {
+		return x.Commit
+	}
+	return ""
+}
+
+func (x *Record) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+func (x *Record) GetTickets() string {
+	if x != nil {
+		return x.Tickets
+	}
+	return ""
+}
+
+func (x *Record) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Record) GetModifiedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ModifiedAt
+	}
+	return nil
+}
+
+func (x *Record) GetApprovedBy() string {
+	if x != nil {
+		return x.ApprovedBy
+	}
+	return ""
+}
+
+var File_api_migration_v1_migration_proto protoreflect.FileDescriptor
+
+const file_api_migration_v1_migration_proto_rawDesc = "" +
+	"\n" +
+	" api/migration/v1/migration.proto\x12\x0edbmigration.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\r\n" +
+	"\vPlanRequest\"\xcd\x01\n" +
+	"\fPlanResponse\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1f\n" +
+	"\vfrom_commit\x18\x02 \x01(\tR\n" +
+	"fromCommit\x12\x1b\n" +
+	"\tto_commit\x18\x03 \x01(\tR\btoCommit\x127\n" +
+	"\achanges\x18\x04 \x03(\v2\x1d.dbmigration.v1.PlannedChangeR\achanges\x12\x16\n" +
+	"\x06errors\x18\x05 \x03(\tR\x06errors\x12\x12\n" +
+	"\x04risk\x18\x06 \x01(\tR\x04risk\"m\n" +
+	"\rPlannedChange\x12\x16\n" +
+	"\x06script\x18\x01 \x01(\tR\x06script\x12\x16\n" +
+	"\x06action\x18\x02 \x01(\tR\x06action\x12\x12\n" +
+	"\x04risk\x18\x03 \x01(\tR\x04risk\x12\x18\n" +
+	"\areasons\x18\x04 \x03(\tR\areasons\"\x0e\n" +
+	"\fApplyRequest\"\xa2\x03\n" +
+	"\x0eScriptProgress\x12\x19\n" +
+	"\bbatch_id\x18\x01 \x01(\tR\abatchId\x12\x16\n" +
+	"\x06script\x18\x02 \x01(\tR\x06script\x12:\n" +
+	"\x05state\x18\x03 \x01(\x0e2$.dbmigration.v1.ScriptProgress.StateR\x05state\x12\x14\n" +
+	"\x05index\x18\x04 \x01(\x05R\x05index\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x05R\x05total\x125\n" +
+	"\bduration\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\"\xa7\x01\n" +
+	"\x05State\x12\x15\n" +
+	"\x11STATE_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fSTATE_EXECUTING\x10\x01\x12\x11\n" +
+	"\rSTATE_SUCCESS\x10\x02\x12\x10\n" +
+	"\fSTATE_FAILED\x10\x03\x12\x13\n" +
+	"\x0fSTATE_TOLERATED\x10\x04\x12\x11\n" +
+	"\rSTATE_SKIPPED\x10\x05\x12\x12\n" +
+	"\x0eSTATE_DEFERRED\x10\x06\x12\x11\n" +
+	"\rSTATE_NOT_RUN\x10\a\")\n" +
+	"\x11GetHistoryRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\"F\n" +
+	"\x12GetHistoryResponse\x120\n" +
+	"\arecords\x18\x01 \x03(\v2\x16.dbmigration.v1.RecordR\arecords\"\x8c\x03\n" +
+	"\x06Record\x12\x10\n" +
+	"\x03sno\x18\x01 \x01(\x03R\x03sno\x12\x16\n" +
+	"\x06script\x18\x02 \x01(\tR\x06script\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x1c\n" +
+	"\tcompleted\x18\x04 \x01(\bR\tcompleted\x12 \n" +
+	"\fend_of_batch\x18\x05 \x01(\bR\n" +
+	"endOfBatch\x12\x19\n" +
+	"\bbatch_id\x18\x06 \x01(\tR\abatchId\x12\x16\n" +
+	"\x06commit\x18\a \x01(\tR\x06commit\x12\x1a\n" +
+	"\bchecksum\x18\b \x01(\tR\bchecksum\x12\x18\n" +
+	"\atickets\x18\t \x01(\tR\atickets\x129\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12;\n" +
+	"\vmodified_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"modifiedAt\x12\x1f\n" +
+	"\vapproved_by\x18\f \x01(\tR\n" +
+	"approvedBy2\xed\x01\n" +
+	"\n" +
+	"Migrations\x12A\n" +
+	"\x04Plan\x12\x1b.dbmigration.v1.PlanRequest\x1a\x1c.dbmigration.v1.PlanResponse\x12G\n" +
+	"\x05Apply\x12\x1c.dbmigration.v1.ApplyRequest\x1a\x1e.dbmigration.v1.ScriptProgress0\x01\x12S\n" +
+	"\n" +
+	"GetHistory\x12!.dbmigration.v1.GetHistoryRequest\x1a\".dbmigration.v1.GetHistoryResponseBDZBgithub.com/bontaramsonta/db-migration/api/migration/v1;migrationv1b\x06proto3"
+
+var (
+	file_api_migration_v1_migration_proto_rawDescOnce sync.Once
+	file_api_migration_v1_migration_proto_rawDescData []byte
+)
+
+func file_api_migration_v1_migration_proto_rawDescGZIP() []byte {
+	file_api_migration_v1_migration_proto_rawDescOnce.Do(func() {
+		file_api_migration_v1_migration_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_migration_v1_migration_proto_rawDesc), len(file_api_migration_v1_migration_proto_rawDesc)))
+	})
+	return file_api_migration_v1_migration_proto_rawDescData
+}
+
+var file_api_migration_v1_migration_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_migration_v1_migration_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_api_migration_v1_migration_proto_goTypes = []any{
+	(ScriptProgress_State)(0),     // 0: dbmigration.v1.ScriptProgress.State
+	(*PlanRequest)(nil),           // 1: dbmigration.v1.PlanRequest
+	(*PlanResponse)(nil),          // 2: dbmigration.v1.PlanResponse
+	(*PlannedChange)(nil),         // 3: dbmigration.v1.PlannedChange
+	(*ApplyRequest)(nil),          // 4: dbmigration.v1.ApplyRequest
+	(*ScriptProgress)(nil),        // 5: dbmigration.v1.ScriptProgress
+	(*GetHistoryRequest)(nil),     // 6: dbmigration.v1.GetHistoryRequest
+	(*GetHistoryResponse)(nil),    // 7: dbmigration.v1.GetHistoryResponse
+	(*Record)(nil),                // 8: dbmigration.v1.Record
+	(*durationpb.Duration)(nil),   // 9: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_api_migration_v1_migration_proto_depIdxs = []int32{
+	3,  // 0: dbmigration.v1.PlanResponse.changes:type_name -> dbmigration.v1.PlannedChange
+	0,  // 1: dbmigration.v1.ScriptProgress.state:type_name -> dbmigration.v1.ScriptProgress.State
+	9,  // 2: dbmigration.v1.ScriptProgress.duration:type_name -> google.protobuf.Duration
+	8,  // 3: dbmigration.v1.GetHistoryResponse.records:type_name -> dbmigration.v1.Record
+	10, // 4: dbmigration.v1.Record.created_at:type_name -> google.protobuf.Timestamp
+	10, // 5: dbmigration.v1.Record.modified_at:type_name -> google.protobuf.Timestamp
+	1,  // 6: dbmigration.v1.Migrations.Plan:input_type -> dbmigration.v1.PlanRequest
+	4,  // 7: dbmigration.v1.Migrations.Apply:input_type -> dbmigration.v1.ApplyRequest
+	6,  // 8: dbmigration.v1.Migrations.GetHistory:input_type -> dbmigration.v1.GetHistoryRequest
+	2,  // 9: dbmigration.v1.Migrations.Plan:output_type -> dbmigration.v1.PlanResponse
+	5,  // 10: dbmigration.v1.Migrations.Apply:output_type -> dbmigration.v1.ScriptProgress
+	7,  // 11: dbmigration.v1.Migrations.GetHistory:output_type -> dbmigration.v1.GetHistoryResponse
+	9,  // [9:12] is the sub-list for method output_type
+	6,  // [6:9] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_api_migration_v1_migration_proto_init() }
+func file_api_migration_v1_migration_proto_init() {
+	if File_api_migration_v1_migration_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_migration_v1_migration_proto_rawDesc), len(file_api_migration_v1_migration_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_migration_v1_migration_proto_goTypes,
+		DependencyIndexes: file_api_migration_v1_migration_proto_depIdxs,
+		EnumInfos:         file_api_migration_v1_migration_proto_enumTypes,
+		MessageInfos:      file_api_migration_v1_migration_proto_msgTypes,
+	}.Build()
+	File_api_migration_v1_migration_proto = out.File
+	file_api_migration_v1_migration_proto_goTypes = nil
+	file_api_migration_v1_migration_proto_depIdxs = nil
+}
@@ -0,0 +1,220 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: api/migration/v1/migration.proto
+
+// The control API of the serve command (--grpc-listen). Clients authenticate with a
+// certificate signed by the server's --grpc-client-ca
+
+package migrationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Migrations_Plan_FullMethodName       = "/dbmigration.v1.Migrations/Plan"
+	Migrations_Apply_FullMethodName      = "/dbmigration.v1.Migrations/Apply"
+	Migrations_GetHistory_FullMethodName = "/dbmigration.v1.Migrations/GetHistory"
+)
+
+// MigrationsClient is the client API for Migrations service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Migrations plans, applies and inspects the migrations of one database
+type MigrationsClient interface {
+	// Plan returns the scripts the next apply would execute, with the risk of each
+	Plan(ctx context.Context, in *PlanRequest, opts ...grpc.CallOption) (*PlanResponse, error)
+	// Apply runs the pending scripts, streaming the progress of each as it starts and
+	// finishes; the batch ID is also sent in the batch-id header. One apply runs at a
+	// time: ABORTED while another one is in progress. A failed run ends the stream with
+	// INTERNAL and the run's error. The run continues if the client goes away
+	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScriptProgress], error)
+	// GetHistory returns the tracking table, oldest first
+	GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*GetHistoryResponse, error)
+}
+
+type migrationsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMigrationsClient(cc grpc.ClientConnInterface) MigrationsClient {
+	return &migrationsClient{cc}
+}
+
+func (c *migrationsClient) Plan(ctx context.Context, in *PlanRequest, opts ...grpc.CallOption) (*PlanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PlanResponse)
+	err := c.cc.Invoke(ctx, Migrations_Plan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *migrationsClient) Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScriptProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Migrations_ServiceDesc.Streams[0], Migrations_Apply_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ApplyRequest, ScriptProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Migrations_ApplyClient = grpc.ServerStreamingClient[ScriptProgress]
+
+func (c *migrationsClient) GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*GetHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetHistoryResponse)
+	err := c.cc.Invoke(ctx, Migrations_GetHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MigrationsServer is the server API for Migrations service.
+// All implementations must embed UnimplementedMigrationsServer
+// for forward compatibility.
+//
+// Migrations plans, applies and inspects the migrations of one database
+type MigrationsServer interface {
+	// Plan returns the scripts the next apply would execute, with the risk of each
+	Plan(context.Context, *PlanRequest) (*PlanResponse, error)
+	// Apply runs the pending scripts, streaming the progress of each as it starts and
+	// finishes; the batch ID is also sent in the batch-id header. One apply runs at a
+	// time: ABORTED while another one is in progress. A failed run ends the stream with
+	// INTERNAL and the run's error. The run continues if the client goes away
+	Apply(*ApplyRequest, grpc.ServerStreamingServer[ScriptProgress]) error
+	// GetHistory returns the tracking table, oldest first
+	GetHistory(context.Context, *GetHistoryRequest) (*GetHistoryResponse, error)
+	mustEmbedUnimplementedMigrationsServer()
+}
+
+// UnimplementedMigrationsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMigrationsServer struct{}
+
+func (UnimplementedMigrationsServer) Plan(context.Context, *PlanRequest) (*PlanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Plan not implemented")
+}
+func (UnimplementedMigrationsServer) Apply(*ApplyRequest, grpc.ServerStreamingServer[ScriptProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method Apply not implemented")
+}
+func (UnimplementedMigrationsServer) GetHistory(context.Context, *GetHistoryRequest) (*GetHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (UnimplementedMigrationsServer) mustEmbedUnimplementedMigrationsServer() {}
+func (UnimplementedMigrationsServer) testEmbeddedByValue()                    {}
+
+// UnsafeMigrationsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MigrationsServer will
+// result in compilation errors.
+type UnsafeMigrationsServer interface {
+	mustEmbedUnimplementedMigrationsServer()
+}
+
+func RegisterMigrationsServer(s grpc.ServiceRegistrar, srv MigrationsServer) {
+	// If the following call pancis, it indicates UnimplementedMigrationsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Migrations_ServiceDesc, srv)
+}
+
+func _Migrations_Plan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MigrationsServer).Plan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Migrations_Plan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MigrationsServer).Plan(ctx, req.(*PlanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Migrations_Apply_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ApplyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MigrationsServer).Apply(m, &grpc.GenericServerStream[ApplyRequest, ScriptProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Migrations_ApplyServer = grpc.ServerStreamingServer[ScriptProgress]
+
+func _Migrations_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MigrationsServer).GetHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Migrations_GetHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MigrationsServer).GetHistory(ctx, req.(*GetHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Migrations_ServiceDesc is the grpc.ServiceDesc for Migrations service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Migrations_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dbmigration.v1.Migrations",
+	HandlerType: (*MigrationsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Plan",
+			Handler:    _Migrations_Plan_Handler,
+		},
+		{
+			MethodName: "GetHistory",
+			Handler:    _Migrations_GetHistory_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Apply",
+			Handler:       _Migrations_Apply_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/migration/v1/migration.proto",
+}
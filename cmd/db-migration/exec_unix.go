@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// execProcess replaces the process with path, so the command receives the container's
+// signals directly; it only returns on failure
+func execProcess(path string, argv, env []string) error {
+	return syscall.Exec(path, argv, env)
+}
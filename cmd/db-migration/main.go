@@ -1,63 +1,1373 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/bontaramsonta/db-migration/internal/approval"
+	"github.com/bontaramsonta/db-migration/internal/artifact"
+	"github.com/bontaramsonta/db-migration/internal/chaos"
 	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/connector"
 	"github.com/bontaramsonta/db-migration/internal/console"
 	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/git"
+	"github.com/bontaramsonta/db-migration/internal/logfile"
+	"github.com/bontaramsonta/db-migration/internal/metrics"
 	"github.com/bontaramsonta/db-migration/internal/migration"
+	"github.com/bontaramsonta/db-migration/internal/proxy"
+	"github.com/bontaramsonta/db-migration/internal/secret"
+	"github.com/bontaramsonta/db-migration/internal/server"
+	"github.com/bontaramsonta/db-migration/internal/tunnel"
+	"github.com/bontaramsonta/db-migration/internal/vault"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	// Initialize console for output
-	cons := console.New(true) // verbose mode
+	cons := console.New()
 
 	// Parse command line arguments
 	cfg, err := config.ParseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage()
+		exit(0)
+	}
 	if err != nil {
 		cons.Error("%v", err)
 		printUsage()
-		os.Exit(1)
+		exit(exitConfig)
+	}
+
+	// Kubernetes and container log collectors parse JSON lines
+	if cfg.LogFormat == config.LogJSON {
+		cons.SetJSON()
+	}
+	if cfg.LogOutput == config.LogStderr {
+		cons.SetOutput(os.Stderr)
+	}
+	cons.SetLevel(cfg.LogLevel)
+	if cfg.Quiet {
+		cons.SetQuiet()
+	}
+	// Runs on app servers leave a record beyond stdout
+	if cfg.LogFile != "" {
+		file, err := logfile.Open(cfg.LogFile, cfg.LogFileMaxSize, cfg.LogFileBackups)
+		if err != nil {
+			cons.Error("%v", err)
+			exit(exitConfig)
+		}
+		cleanups = append(cleanups, func() { file.Close() })
+		cons.SetLogFile(file)
+	}
+	if cfg.ConfigFile != "" {
+		cons.Debug("Read configuration from %s", cfg.ConfigFile)
+	}
+
+	// Locked-down networks reach the database, git remotes and cloud APIs through a proxy
+	var proxyDialer *proxy.Dialer
+	if cfg.Proxy != "" {
+		proxyDialer, err = proxy.New(cfg.Proxy, proxy.NoProxy())
+		if err != nil {
+			cons.Error("%v", err)
+			exit(exitConfig)
+		}
+		proxyDialer.Export()
+		proxyDialer.Register(config.ProxyNetwork)
+		cons.Info("Using proxy %s", proxyDialer)
+	}
+
+	// bundle push only packages the scripts
+	if cfg.Command == config.CommandBundle {
+		cons.Info("Pushing %s to %s...", cfg.ScriptsDir, cfg.BundleRef)
+		manifest, pinned, err := artifact.Push(cfg.ScriptsDir, cfg.BundleRef, cfg.SignKey, cons.Logger())
+		if err != nil {
+			cons.Error("Bundle push failed: %v", err)
+			exit(exitFailure)
+		}
+		cons.Success("Pushed commit %s as %s", manifest.Commit[:8], pinned)
+		if cfg.SignKey == "" {
+			cons.Warn("The bundle is unsigned; runners reject it until it is signed with cosign")
+		}
+		exit(0)
+	}
+
+	// login stores a password for keyring://<profile> references
+	if cfg.Command == config.CommandLogin {
+		if err := login(cfg.Profile); err != nil {
+			cons.Error("Login failed: %v", err)
+			exit(exitFailure)
+		}
+		cons.Success("Stored the password of %s in the OS keychain; pass keyring://%s as <password>", cfg.Profile, cfg.Profile)
+		exit(0)
+	}
+
+	// audit verify checks a run report log without connecting to a database
+	if cfg.Command == config.CommandAudit {
+		if err := verifyAuditLog(cfg.AuditLog, cfg.AuditKey, cons); err != nil {
+			cons.Error("Audit log verification failed: %v", err)
+			exit(exitFailure)
+		}
+		exit(0)
+	}
+
+	// SIGINT and SIGTERM kill a running bundle fetch or clone
+	fetchCtx, stopFetch := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	// Artifacts are verified and checked out before anything reads the scripts
+	if strings.HasPrefix(cfg.ScriptsDir, config.OCIScheme) {
+		dir, err := os.MkdirTemp("", "db-migration-")
+		if err != nil {
+			cons.Error("Failed to create temp directory: %v", err)
+			exit(exitFailure)
+		}
+		cleanups = append(cleanups, func() { os.RemoveAll(dir) })
+
+		cons.Info("Pulling and verifying %s...", cfg.ScriptsDir)
+		manifest, scriptsDir, err := artifact.Fetch(fetchCtx, cfg.ScriptsDir, cfg.VerifyKey, dir, cons.Logger())
+		if err != nil {
+			cons.Error("Bundle fetch failed: %v", err)
+			if errors.Is(err, artifact.ErrUnverified) {
+				exit(unlessInterrupted(fetchCtx, exitValidation))
+			}
+			exit(unlessInterrupted(fetchCtx, exitConnection))
+		}
+		cons.Success("Verified bundle at commit %s", manifest.Commit[:8])
+		cfg.ScriptsDir = scriptsDir
 	}
 
+	// Remote repositories are cloned before anything reads the scripts
+	if cfg.ScriptsRepo != "" {
+		dir, err := os.MkdirTemp("", "db-migration-")
+		if err != nil {
+			cons.Error("Failed to create temp directory: %v", err)
+			exit(exitFailure)
+		}
+		cleanups = append(cleanups, func() { os.RemoveAll(dir) })
+
+		cons.Info("Cloning %s...", git.RedactURL(cfg.ScriptsRepo))
+		repoDir := filepath.Join(dir, "repo")
+		if err := git.Clone(fetchCtx, cfg.ScriptsRepo, cfg.Ref, repoDir, cons.Logger()); err != nil {
+			cons.Error("Clone failed: %v", err)
+			exit(unlessInterrupted(fetchCtx, exitConnection))
+		}
+		cfg.ScriptsDir = filepath.Join(repoDir, cfg.ScriptsPath)
+		if info, err := os.Stat(cfg.ScriptsDir); err != nil || !info.IsDir() {
+			cons.Error("Scripts directory %s does not exist in %s", cfg.ScriptsPath, git.RedactURL(cfg.ScriptsRepo))
+			exit(exitConfig)
+		}
+		commit, err := git.New(cfg.ScriptsDir, cons.Logger()).GetCurrentCommit()
+		if err != nil {
+			cons.Error("Clone failed: %v", err)
+			exit(exitConnection)
+		}
+		cons.Success("Cloned %s at commit %s", git.RedactURL(cfg.ScriptsRepo), commit[:8])
+	}
+	stopFetch()
+
+	// Machine-readable plans own stdout; progress goes to stderr
+	if cfg.Command == config.CommandPlan && cfg.ExportFormat != config.PlanText {
+		cons.SetOutput(os.Stderr)
+	}
+
+	// Outside its maintenance window, up waits for it or leaves the work to a later run
+	if cfg.Window != nil && len(cfg.WindowTags) == 0 {
+		awaitWindow(cfg, cons)
+	}
+
+	// A - password not found elsewhere comes from stdin or a prompt, never from argv
+	if err := readPassword(cfg); err != nil {
+		cons.Error("%v", err)
+		exit(exitConfig)
+	}
+
+	// Secret references (aws-sm://, gcp-sm://, azure-kv://) are resolved before anything uses them
+	passwordRef, trackerPasswordRef := cfg.Password, cfg.TrackerPassword
+	if err := resolveSecrets(cfg); err != nil {
+		cons.Error("Secret resolution failed: %v", err)
+		exit(exitConfig)
+	}
+	// Reconnects after a lost connection resolve rotated passwords again
+	refreshPassword := func() error { return resolveSecret(&cfg.Password, passwordRef) }
+	refreshTrackerPassword := func() error { return resolveSecret(&cfg.TrackerPassword, trackerPasswordRef) }
+
+	// Short-lived Vault credentials replace <user> and <password>
+	if cfg.VaultPath != "" {
+		refresh, err := useVaultCredentials(cfg, cons)
+		if err != nil {
+			cons.Error("Vault credentials failed: %v", err)
+			exit(exitConfig)
+		}
+		refreshPassword = refresh
+	}
+
+	// Private databases are reached through an SSH bastion
+	if cfg.SSHHost != "" {
+		cons.Info("Opening SSH tunnel through %s...", cfg.SSHHost)
+		tc := tunnel.Config{Host: cfg.SSHHost, User: cfg.SSHUser, KeyFile: cfg.SSHKey, KnownHostsFile: cfg.SSHKnownHosts}
+		if proxyDialer != nil {
+			tc.Dial = proxyDialer.DialContext
+		}
+		t, err := tunnel.Open(tc)
+		if err != nil {
+			cons.Error("SSH tunnel failed: %v", err)
+			exit(exitConnection)
+		}
+		t.Register(config.TunnelNetwork)
+		cleanups = append(cleanups, func() { t.Close() })
+	}
+
+	// Managed databases are reached through their connector instead of a sidecar proxy
+	var opts []mysql.Option
+	if cfg.Target != nil {
+		switch cfg.Target.Scheme {
+		case connector.CloudSQLScheme:
+			var dialOpts []cloudsqlconn.Option
+			if proxyDialer != nil {
+				dialOpts = append(dialOpts, cloudsqlconn.WithDialFunc(proxyDialer.DialContext))
+			}
+			c, err := connector.NewCloudSQL(context.Background(), cfg.Target, dialOpts...)
+			if err != nil {
+				cons.Error("Cloud SQL connector failed: %v", err)
+				exit(exitConnection)
+			}
+			c.Register(connector.CloudSQLNetwork)
+			cleanups = append(cleanups, func() { c.Close() })
+		case connector.RDSScheme:
+			opts = append(opts, connector.RDSAuth(cfg.Target.Region))
+		}
+	}
+	if cfg.AzureAD {
+		cred, err := connector.NewAzureCredential()
+		if err != nil {
+			cons.Error("Azure authentication failed: %v", err)
+			exit(exitConfig)
+		}
+		opts = append(opts, connector.AzureADAuth(cred))
+	}
+
+	if cfg.SSLMode != "" {
+		opt, err := cfg.TLSPolicy().Option()
+		if err != nil {
+			cons.Error("%v", err)
+			exit(exitConfig)
+		}
+		opts = append(opts, opt)
+	}
+
+	if cfg.Command == config.CommandRollout {
+		if !rollout(cfg, cons, opts) {
+			exit(exitFailure)
+		}
+		exit(0)
+	}
+
+	// Shards and tenants each get their own connection, batch and tracking table
+	if len(cfg.Targets) > 0 {
+		if !fanOut(cfg, cons, opts) {
+			exit(exitFailure)
+		}
+		exit(0)
+	}
+
+	// Sessions carry the batch ID, so DBAs can attribute them in audit plugins and process lists
+	batchID := migration.NewBatchID()
+	opts = append(opts, db.ConnectionAttributes(cfg.ConnectionAttributes(batchID)))
+
 	// Connect to database
-	cons.Info("Connecting to database %s@%s:%d/%s...", cfg.User, cfg.Host, cfg.Port, cfg.DBName)
-	database, err := db.Connect(cfg.DSN())
+	if cfg.Driver == db.DriverSQLite {
+		cons.Info("Opening SQLite database %s...", cfg.DataSourceName)
+	} else {
+		cons.Info("Connecting to database %s@%s:%d/%s...", cfg.User, cfg.Host, cfg.Port, cfg.DBName)
+	}
+	database, err := waitForDB(cfg, cons, func() (*db.DB, error) {
+		return connect(cfg, cons, cfg.DSN(), cfg.SessionInit, opts)
+	})
 	if err != nil {
 		cons.Error("Database connection failed: %v", err)
-		os.Exit(1)
+		writeTerminationMessage(cfg, "database connection failed: "+err.Error())
+		exit(exitConnection)
 	}
-	defer database.Close()
+	// Closed before a Vault lease is revoked, since cleanups run in reverse
+	cleanups = append(cleanups, func() { database.Close() })
+	database.SetReconnect(func() (*db.DB, error) {
+		if err := refreshPassword(); err != nil {
+			return nil, err
+		}
+		return connect(cfg, cons, cfg.DSN(), cfg.SessionInit, opts)
+	})
 	cons.Success("Database connection established")
+	logTLS(cfg, database, cons)
+	cons.Info("Sessions are tagged with batch %s", batchID)
+
+	// Resilience tests inject failures through DB_MIGRATION_CHAOS, in chaos builds only
+	migratorOpts := []migration.Option{migration.WithLogger(cons.Logger())}
+	faults, err := chaos.FromEnv()
+	if err != nil {
+		cons.Error("Invalid %s: %v", chaos.Env, err)
+		exit(exitConfig)
+	}
+	if faults != nil {
+		cons.Warn("Injecting faults from %s=%s", chaos.Env, os.Getenv(chaos.Env))
+		migratorOpts = append(migratorOpts, migration.WithFaults(faults))
+	}
+
+	// A separate user may write the tracking tables, so the executing user can be scoped tightly
+	if cfg.TrackerUser != "" {
+		trackerDB, err := waitForDB(cfg, cons, func() (*db.DB, error) {
+			return connect(cfg, cons, cfg.TrackerDSN(), "", opts)
+		})
+		if err != nil {
+			cons.Error("Tracker connection failed: %v", err)
+			exit(exitConnection)
+		}
+		cleanups = append(cleanups, func() { trackerDB.Close() })
+		trackerDB.SetReconnect(func() (*db.DB, error) {
+			if err := refreshTrackerPassword(); err != nil {
+				return nil, err
+			}
+			return connect(cfg, cons, cfg.TrackerDSN(), "", opts)
+		})
+		migratorOpts = append(migratorOpts, migration.WithTrackerDB(trackerDB))
+		cons.Success("Tracking tables are written as %s", cfg.TrackerUser)
+	}
+
+	// Scripts and runs are counted for Prometheus
+	if cfg.Pushgateway != "" || cfg.MetricsListen != "" {
+		mx, err := startMetrics(cfg, cons)
+		if err != nil {
+			cons.Error("Metrics failed: %v", err)
+			exit(exitFailure)
+		}
+		migratorOpts = append(migratorOpts, migration.WithMetrics(mx))
+	}
+
+	// Every apply through the API is a batch of its own; the sessions keep the server's ID
+	if cfg.Command == config.CommandServe {
+		if err := serve(cfg, database, cons, migratorOpts); err != nil {
+			cons.Error("Server failed: %v", err)
+			exit(exitFailure)
+		}
+		exit(0)
+	}
+
+	// SIGINT and SIGTERM cancel the running statement, so its script is rolled back and
+	// recorded as cancelled, and no further script starts. A second signal exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, func() {
+		stop()
+		cons.Warn("Interrupted: stopping once the running script is rolled back; signal again to exit at once")
+	})
+	migratorOpts = append(migratorOpts, migration.WithContext(ctx))
+
+	// watch applies each new commit as a batch of its own until SIGINT or SIGTERM
+	if cfg.Command == config.CommandWatch {
+		cons.Success("Watching %s every %s", cfg.ScriptsDir, cfg.WatchInterval)
+		migration.Watch(ctx, cfg.WatchInterval, func() *migration.Migrator {
+			return migration.NewMigrator(cfg, database, cons, migratorOpts...)
+		})
+		exit(0)
+	}
 
 	// Create and run migrator
-	migrator := migration.NewMigrator(cfg, database, cons)
-	if err := migrator.Run(); err != nil {
-		cons.Error("Migration failed: %v", err)
-		os.Exit(1)
+	migrator := migration.NewMigrator(cfg, database, cons, append(migratorOpts, migration.WithBatchID(batchID))...)
+	switch cfg.Command {
+	case config.CommandSeed:
+		if err := migrator.Seed(cfg.Reseed); err != nil {
+			cons.Error("Seeding failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandRerun:
+		if err := migrator.Rerun(cfg.RerunScript, confirmer(cfg.Yes)); err != nil {
+			cons.Error("Rerun failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandImport:
+		if err := migrator.Import(cfg.ImportSource, confirmer(cfg.Yes)); err != nil {
+			cons.Error("Import failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandBaseline:
+		if err := migrator.Baseline(cfg.DownTo, confirmer(cfg.Yes)); err != nil {
+			cons.Error("Baseline failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandMarkApplied:
+		if err := migrator.MarkApplied(cfg.PlanBundle, confirmer(cfg.Yes)); err != nil {
+			cons.Error("Mark applied failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandRepair:
+		if err := migrator.Repair(cfg.DryRun, confirmer(cfg.Yes)); err != nil {
+			cons.Error("Repair failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandExport:
+		err := writeOutput(cfg, func(w io.Writer) error {
+			return migrator.Export(cfg.ExportFormat, w)
+		})
+		if err != nil {
+			cons.Error("Export failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+		cons.Success("Exported %s to %s", cfg.ExportFormat, cfg.ExportOutput)
+	case config.CommandDiff:
+		if _, err := migrator.Diff(cfg.DiffName); err != nil {
+			cons.Error("Diff failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandDrift:
+		changes, err := migrator.Drift()
+		if err != nil {
+			cons.Error("Drift failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+		if len(changes) > 0 {
+			exit(migration.CheckDrift)
+		}
+	case config.CommandDocs:
+		if err := writeOutput(cfg, migrator.Docs); err != nil {
+			cons.Error("Docs failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+		cons.Success("Schema documentation written to %s", cfg.ExportOutput)
+	case config.CommandPlan:
+		plan, err := migrator.Plan()
+		if err == nil {
+			err = migration.WritePlan(os.Stdout, plan, cfg.ExportFormat)
+		}
+		if err != nil {
+			cons.Error("Plan failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+		// Problems that would stop the run fail the plan too, so pipelines can gate on it
+		if len(plan.Errors) > 0 {
+			cons.Error("Plan has %d errors", len(plan.Errors))
+			exit(exitValidation)
+		}
+	case config.CommandValidate:
+		problems, err := migrator.Validate()
+		if err != nil {
+			cons.Error("Validation failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+		for _, problem := range problems {
+			cons.Failure("%s", problem)
+		}
+		if len(problems) > 0 {
+			cons.Error("Validation found %d problems", len(problems))
+			exit(exitValidation)
+		}
+		cons.Success("The pending scripts are valid")
+	case config.CommandStatus:
+		for _, ncfg := range namespaceConfigs(cfg) {
+			if ncfg != cfg {
+				cons.Info("Namespace %s: %s", ncfg.Namespace, ncfg.ScriptsDir)
+				migrator = migration.NewMigrator(ncfg, database, cons, migratorOpts...)
+			}
+			if err := migrator.Status(); err != nil {
+				cons.Error("Status failed: %v", err)
+				exit(failureCode(ctx, err))
+			}
+		}
+	case config.CommandHistory:
+		records, err := migration.NewInspector(cfg, database, migratorOpts...).History(ctx)
+		if err == nil {
+			if cfg.HistoryLimit > 0 && len(records) > cfg.HistoryLimit {
+				records = records[len(records)-cfg.HistoryLimit:]
+			}
+			err = migration.WriteHistory(os.Stdout, records)
+		}
+		if err != nil {
+			cons.Error("History failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	case config.CommandCheck:
+		result, err := migrator.Check()
+		if err != nil {
+			cons.Error("Check failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+		// Each finding has an exit code of its own, so pipelines can tell them apart
+		exit(result.Code())
+	case config.CommandDown:
+		if err := migrator.Down(cfg.DownTo, cfg.DownCount); err != nil {
+			cons.Error("Rollback failed: %v", err)
+			exit(failureCode(ctx, err))
+		}
+	default:
+		// The scripts directories of a monorepo are migrated in order, each in its namespace;
+		// the first failure stops the rest
+		pending := 0
+		for _, ncfg := range namespaceConfigs(cfg) {
+			if ncfg != cfg {
+				cons.Info("Namespace %s: %s", ncfg.Namespace, ncfg.ScriptsDir)
+				migrator = migration.NewMigrator(ncfg, database, cons, append(migratorOpts, migration.WithBatchID(batchID))...)
+			}
+			run := migrator.Run
+			if cfg.K8s {
+				run = func() error { return migrator.RunAsLeader(ctx) }
+			}
+			if err := run(); err != nil {
+				cons.Error("Migration failed: %v", err)
+				writeTerminationMessage(cfg, "migration failed: "+err.Error())
+				exit(failureCode(ctx, err))
+			}
+			pending += migrator.Pending()
+
+			// Scripts held back by --window-tags run once the window opens
+			if held := migrator.HeldForWindow(); held > 0 {
+				cons.Warn("%d scripts tagged %s wait for the maintenance window %s", held, strings.Join(cfg.WindowTags, ","), cfg.Window)
+				awaitWindow(cfg, cons)
+				if err := migration.NewMigrator(ncfg, database, cons, migratorOpts...).Run(); err != nil {
+					cons.Error("Migration failed: %v", err)
+					exit(failureCode(ctx, err))
+				}
+			}
+		}
+		if cfg.ThenExec != "" {
+			thenExec(cfg, cons)
+		}
+		if cfg.DetailedExitCode && pending == 0 {
+			exit(exitNothingToDo)
+		}
+	}
+
+	exit(0)
+}
+
+// namespaceConfigs returns the configuration of every scripts directory to migrate, in
+// order: one per namespace when several are given, or cfg itself
+func namespaceConfigs(cfg *config.Config) []*config.Config {
+	if len(cfg.Namespaces) < 2 {
+		return []*config.Config{cfg}
+	}
+	configs := make([]*config.Config, len(cfg.Namespaces))
+	for i, ns := range cfg.Namespaces {
+		configs[i] = cfg.ForNamespace(ns)
+	}
+	return configs
+}
+
+// cleanups run before the process exits, since os.Exit skips deferred calls
+var cleanups []func()
+
+// exit runs the cleanups and exits with code
+func exit(code int) {
+	runCleanups()
+	os.Exit(code)
+}
+
+// runCleanups runs the cleanups in reverse order
+func runCleanups() {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	cleanups = nil
+}
+
+// thenExec hands the process over to the --then-exec command once the schema is
+// current, e.g. the application server of the same container. The command is split
+// on spaces and run without a shell; the database passwords are not passed on
+func thenExec(cfg *config.Config, cons *console.Console) {
+	argv := strings.Fields(cfg.ThenExec)
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		cons.Error("Cannot run --then-exec command: %v", err)
+		exit(exitFailure)
+	}
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		name, _, _ := strings.Cut(kv, "=")
+		return name == config.PasswordEnv || name == config.TrackerPasswordEnv
+	})
+
+	cons.Info("Handing over to %s", cfg.ThenExec)
+	runCleanups()
+	err = execProcess(path, argv, env)
+	cons.Error("Failed to run --then-exec command: %v", err)
+	os.Exit(1)
+}
+
+// resolveSecrets replaces secret references in the configuration with their values
+func resolveSecrets(cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resolver := secret.NewResolver()
+	return cfg.ResolveSecrets(func(value string) (string, error) {
+		return resolver.Resolve(ctx, value)
+	})
+}
+
+// resolveSecret resolves ref into field again, e.g. after the secret was rotated;
+// values that are not references are left alone
+func resolveSecret(field *string, ref string) error {
+	if !secret.IsReference(ref) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	value, err := secret.NewResolver().Resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+// useVaultCredentials reads database credentials from --vault-path, renews their lease
+// in the background during long runs and revokes it when the process exits; the returned
+// function replaces them with a new lease when a reconnect is needed
+func useVaultCredentials(cfg *config.Config, cons *console.Console) (func() error, error) {
+	client, err := vault.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var creds *vault.Credentials
+	stopRenewal := func() {}
+	revoke := func() {
+		stopRenewal()
+		if creds == nil || creds.LeaseID == "" {
+			return
+		}
+		revokeCtx, done := context.WithTimeout(context.Background(), 10*time.Second)
+		defer done()
+		if err := client.Revoke(revokeCtx, creds.LeaseID); err != nil {
+			cons.Warn("Could not revoke Vault lease %s: %v", creds.LeaseID, err)
+		}
+	}
+	read := func() error {
+		cons.Info("Reading database credentials from Vault %s...", cfg.VaultPath)
+		fresh, err := client.ReadCredentials(context.Background(), cfg.VaultPath)
+		if err != nil {
+			return err
+		}
+		// A previous lease is only read again after its connections were lost
+		revoke()
+		creds = fresh
+		cfg.User, cfg.Password = creds.Username, creds.Password
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopRenewal = cancel
+		go client.KeepAlive(ctx, creds, func(err error) {
+			cons.Warn("Vault lease renewal failed: %v", err)
+		})
+		cons.Success("Using Vault credentials for %s (lease %s)", creds.Username, creds.LeaseDuration)
+		return nil
+	}
+
+	if err := read(); err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, revoke)
+	return read, nil
+}
+
+// connect opens dsn with the --driver, tracing statements to cons for -vv, and applies
+// --statement-timeout; the mysql options and sessionInit only apply to MySQL
+func connect(cfg *config.Config, cons *console.Console, dsn, sessionInit string, opts []mysql.Option) (*db.DB, error) {
+	var database *db.DB
+	var err error
+	if cfg.Driver != db.DriverMySQL {
+		database, err = db.Open(cfg.Driver, dsn, cons.Logger())
+	} else {
+		database, err = db.ConnectSession(dsn, sessionInit, cons.Logger(), opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	database.SetStatementTimeout(cfg.StatementTimeout)
+	database.SetTraceArgs(cfg.TraceArgs)
+	return database, nil
+}
+
+// fanOut runs up against every --targets database and prints the summary matrix;
+// it reports whether all of them succeeded
+func fanOut(cfg *config.Config, cons *console.Console, opts []mysql.Option) bool {
+	cons.Info("Migrating %d targets, %d at a time...", len(cfg.Targets), cfg.Parallel)
+	results := migration.FanOut(os.Stdout, cfg.Targets, cfg.Parallel, cfg.ContinueOnError, func(target config.Target, log io.Writer) migration.RunReport {
+		tc := cfg.ForTarget(target)
+		tcons := console.New()
+		tcons.SetOutput(log)
+		batchID := migration.NewBatchID()
+		m, closeTarget, err := openTarget(tc, tcons, opts, batchID)
+		if err != nil {
+			tcons.Failure("%v", err)
+			return migration.RunReport{BatchID: batchID, Database: tc.DBName, Host: tc.Host, Status: migration.TargetFailed, Error: err.Error()}
+		}
+		defer closeTarget()
+		err = m.Run()
+		return m.Report(err)
+	})
+
+	fmt.Println()
+	migration.WriteMatrix(os.Stdout, results)
+	for _, r := range results {
+		if r.Status != migration.TargetSuccess {
+			return false
+		}
+	}
+	return true
+}
+
+// openTarget connects to one of the --targets and returns its migrator running as
+// batchID, with a func closing its connections
+func openTarget(tc *config.Config, tcons *console.Console, opts []mysql.Option, batchID string, extra ...migration.Option) (*migration.Migrator, func(), error) {
+	topts := append(opts[:len(opts):len(opts)], db.ConnectionAttributes(tc.ConnectionAttributes(batchID)))
+	database, err := waitForDB(tc, tcons, func() (*db.DB, error) {
+		return connect(tc, tcons, tc.DSN(), tc.SessionInit, topts)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("database connection failed: %w", err)
+	}
+	closeTarget := func() { database.Close() }
+	migratorOpts := append([]migration.Option{migration.WithBatchID(batchID)}, extra...)
+	if tc.TrackerUser != "" {
+		trackerDB, err := connect(tc, tcons, tc.TrackerDSN(), "", topts)
+		if err != nil {
+			database.Close()
+			return nil, nil, fmt.Errorf("tracker connection failed: %w", err)
+		}
+		closeTarget = func() { trackerDB.Close(); database.Close() }
+		migratorOpts = append(migratorOpts, migration.WithTrackerDB(trackerDB))
+	}
+	return migration.NewMigrator(tc, database, tcons, migratorOpts...), closeTarget, nil
+}
+
+// rollout applies and verifies the targets one after another in file order, stopping
+// at the first that fails, and reports whether all succeeded
+func rollout(cfg *config.Config, cons *console.Console, opts []mysql.Option) bool {
+	var gate *approval.Gate
+	if cfg.ApprovalWebhook != "" {
+		gate = newApprovalGate(cfg)
+		mux := http.NewServeMux()
+		mux.Handle("POST /approvals", gate.Handler())
+		srv := &http.Server{Addr: cfg.ApprovalListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				cons.Error("Approval callback server failed: %v", err)
+			}
+		}()
+		defer srv.Close()
+		cons.Info("Receiving approvals on %s", cfg.ApprovalListen)
+	}
+
+	cons.Info("Rolling out to %d targets: %s", len(cfg.Targets), targetNames(cfg.Targets))
+	stage := 0
+	report := migration.Rollout(cfg.Targets, func(target config.Target) migration.RolloutStage {
+		stage++
+		cons.Header("Stage %d/%d: %s", stage, len(cfg.Targets), target.Name)
+		var extra []migration.Option
+		if cfg.NeedsApproval(target) {
+			extra = append(extra, migration.WithApproval(approve(context.Background(), gate, target.Name)))
+		}
+		m, closeTarget, err := openTarget(cfg.ForTarget(target), cons, opts, migration.NewBatchID(), extra...)
+		if err != nil {
+			cons.Failure("%v", err)
+			return migration.RolloutStage{Status: migration.StageFailed, Error: err.Error()}
+		}
+		defer closeTarget()
+		return m.RunStage(cfg.AutoRevert)
+	})
+
+	fmt.Println()
+	migration.WriteRollout(os.Stdout, report)
+	if cfg.ExportOutput != "" {
+		if err := writeOutput(cfg, report.WriteJSON); err != nil {
+			cons.Error("Failed to write rollout report: %v", err)
+			return false
+		}
+		cons.Info("Rollout report written to %s", cfg.ExportOutput)
 	}
+	return report.Status == migration.StageSuccess
+}
 
-	os.Exit(0)
+// newApprovalGate returns the gate of --approval-webhook
+func newApprovalGate(cfg *config.Config) *approval.Gate {
+	return approval.New(cfg.ApprovalWebhook, []byte(cfg.ApprovalSecret), cfg.ApprovalTimeout, cfg.ApprovalURL)
+}
+
+// approve asks the gate to approve each plan, naming where it is applied
+func approve(ctx context.Context, gate *approval.Gate, where string) migration.ApprovalFunc {
+	return func(plan *migration.Plan) (string, error) {
+		var text strings.Builder
+		migration.WritePlan(&text, plan, "")
+		return gate.Await(ctx, approval.Request{
+			Title: fmt.Sprintf("apply %d scripts to %s on %s (risk %s)", len(plan.Changes), plan.Database, where, plan.Risk()),
+			Text:  text.String(),
+		})
+	}
+}
+
+// targetNames joins the names of targets in order, e.g. "canary → staging → prod"
+func targetNames(targets []config.Target) string {
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.Name
+	}
+	return strings.Join(names, " → ")
+}
+
+// waitForDB connects, retrying with backoff for up to --wait-for-db while the database
+// is unreachable, e.g. while its pod or a sidecar proxy is still starting
+func waitForDB(cfg *config.Config, cons *console.Console, connect func() (*db.DB, error)) (*db.DB, error) {
+	deadline := time.Now().Add(cfg.WaitForDB)
+	delay := time.Second
+	for {
+		database, err := connect()
+		if err == nil || !db.IsConnectionError(err) || time.Now().Add(delay).After(deadline) {
+			return database, err
+		}
+		cons.Warn("Database is not reachable yet, retrying in %s: %v", delay, err)
+		time.Sleep(delay)
+		delay = min(2*delay, 30*time.Second)
+	}
+}
+
+// Exit codes, so pipelines can branch on why a command failed instead of matching its
+// output. check and drift report their findings as 2 to 4, see migration.CheckPending
+const (
+	exitFailure       = 1   // Any failure not classified below
+	exitOutsideWindow = 5   // up outside its --window without --wait
+	exitConfig        = 6   // Invalid flags, configuration file, password, secret reference or Vault or Azure credentials
+	exitConnection    = 7   // The database, tracker connection, tunnel, connector, git remote or bundle registry is unreachable
+	exitValidation    = 8   // Checks stopped the run before its first script; validate or plan found problems
+	exitScript        = 9   // A script failed
+	exitLocked        = 10  // Another run held the migration lock past --lock-timeout
+	exitNothingToDo   = 11  // up with --detailed-exitcode found no pending script
+	exitInterrupted   = 130 // Stopped by SIGINT or SIGTERM, as shells report a process killed by SIGINT
+)
+
+// failureCode returns the exit code of a command that failed with err: exitInterrupted
+// once a signal cancelled ctx, else the class of err
+func failureCode(ctx context.Context, err error) int {
+	var validation *migration.ValidationError
+	var script *migration.ScriptError
+	switch {
+	case ctx.Err() != nil:
+		return exitInterrupted
+	case errors.Is(err, migration.ErrLocked):
+		return exitLocked
+	case db.IsConnectionError(err):
+		return exitConnection
+	case errors.As(err, &validation):
+		return exitValidation
+	case errors.As(err, &script):
+		return exitScript
+	}
+	return exitFailure
+}
+
+// unlessInterrupted returns exitInterrupted once ctx is done, and code otherwise
+func unlessInterrupted(ctx context.Context, code int) int {
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	return code
+}
+
+// awaitWindow returns once the maintenance window is open. Without --wait, it exits
+// with exitOutsideWindow instead, so a scheduler can tell the run was not due
+func awaitWindow(cfg *config.Config, cons *console.Console) {
+	now := time.Now()
+	if cfg.Window.Contains(now) {
+		return
+	}
+	opens := cfg.Window.NextOpen(now)
+	if !cfg.WaitForWindow {
+		cons.Warn("Outside the maintenance window %s; it opens at %s", cfg.Window, opens.Format(time.RFC3339))
+		exit(exitOutsideWindow)
+	}
+
+	cons.Info("Waiting for the maintenance window %s to open at %s...", cfg.Window, opens.Format(time.RFC3339))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case <-time.After(time.Until(opens)):
+		cons.Info("Maintenance window %s is open", cfg.Window)
+	case <-ctx.Done():
+		cons.Error("Interrupted while waiting for the maintenance window")
+		exit(exitOutsideWindow)
+	}
+}
+
+// terminationMessagePath is where Kubernetes reads the reason a container failed from
+const terminationMessagePath = "/dev/termination-log"
+
+// writeTerminationMessage shows msg in kubectl describe and the Job's pod status (--k8s)
+func writeTerminationMessage(cfg *config.Config, msg string) {
+	if !cfg.K8s {
+		return
+	}
+	if _, err := os.Stat(terminationMessagePath); err == nil {
+		os.WriteFile(terminationMessagePath, []byte(msg), 0644)
+	}
+}
+
+// serve runs the migration APIs until SIGINT or SIGTERM, then lets a running apply finish
+func serve(cfg *config.Config, database *db.DB, cons *console.Console, opts []migration.Option) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var gate *approval.Gate
+	if cfg.ApprovalWebhook != "" {
+		gate = newApprovalGate(cfg)
+		opts = append(opts[:len(opts):len(opts)], migration.WithApproval(approve(ctx, gate, cfg.Host)))
+	}
+	api := server.New(&server.Migrations{Config: cfg, DB: database, Options: opts}, cfg.APIToken, cons)
+	errs := make(chan error, 2)
+
+	var srv *http.Server
+	if cfg.Listen != "" {
+		handler := api.Handler()
+		if gate != nil {
+			mux := http.NewServeMux()
+			mux.Handle("/", handler)
+			mux.Handle("POST /approvals", gate.Handler())
+			handler = mux
+		}
+		srv = &http.Server{Addr: cfg.Listen, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+		go func() { errs <- srv.ListenAndServe() }()
+		cons.Success("Serving the migration API on %s", cfg.Listen)
+	}
+
+	var grpcSrv *grpc.Server
+	if cfg.GRPCListen != "" {
+		tlsConfig, err := server.MutualTLS(cfg.GRPCCert, cfg.GRPCKey, cfg.GRPCClientCA)
+		if err != nil {
+			return err
+		}
+		lis, err := net.Listen("tcp", cfg.GRPCListen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", cfg.GRPCListen, err)
+		}
+		grpcSrv = api.GRPC(tlsConfig)
+		go func() { errs <- grpcSrv.Serve(lis) }()
+		cons.Success("Serving the gRPC migration API on %s (mTLS)", cfg.GRPCListen)
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	cons.Info("Shutting down; a running apply is allowed to finish...")
+	if srv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}
+	if grpcSrv != nil {
+		// Apply streams end when their client goes away, so they do not hold up the stop
+		grpcSrv.Stop()
+	}
+	api.Wait()
+	return nil
+}
+
+// login reads a password from the terminal without echoing it, or from piped stdin,
+// and stores it in the OS keychain
+func login(profile string) error {
+	var password []byte
+	var err error
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("Password for %s: ", profile)
+		password, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+	} else {
+		var line string
+		line, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err == io.EOF {
+			err = nil
+		}
+		password = []byte(strings.TrimRight(line, "\r\n"))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("empty password")
+	}
+	return secret.StorePassword(profile, string(password))
+}
+
+// readPassword reads a - <password> from the first line of stdin with --password-stdin,
+// or prompts for it when stdin is a terminal. --targets entries may bring their own
+// passwords, so a fan-out is left alone
+func readPassword(cfg *config.Config) error {
+	if cfg.Password != "-" || (cfg.TargetsFile != "" && !cfg.PasswordStdin) {
+		return nil
+	}
+	if cfg.PasswordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		cfg.Password = strings.TrimRight(line, "\r\n")
+		if cfg.Password == "" {
+			return fmt.Errorf("--password-stdin read an empty password")
+		}
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("<password> is - but $%s is not set; use --password-stdin or --password-file", config.PasswordEnv)
+	}
+	// The prompt goes to stderr, so stdout stays machine-readable
+	fmt.Fprintf(os.Stderr, "Password for %s@%s: ", cfg.User, cfg.Host)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	cfg.Password = string(password)
+	return nil
+}
+
+// writeOutput creates the configured output file and fills it with write
+func writeOutput(cfg *config.Config, write func(io.Writer) error) error {
+	file, err := os.Create(cfg.ExportOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", cfg.ExportOutput, err)
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// startMetrics registers the metrics of runs, serves them on --metrics-listen and pushes
+// them to --pushgateway as the process exits. Scrape-on-exit setups get until
+// --metrics-linger to fetch the final values; the first scrape after the last run ends the wait
+func startMetrics(cfg *config.Config, cons *console.Console) (*migration.Metrics, error) {
+	reg := metrics.NewRegistry()
+	mx := migration.NewMetrics(reg)
+
+	if cfg.Pushgateway != "" {
+		cleanups = append(cleanups, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := reg.Push(ctx, cfg.Pushgateway, "db-migration", map[string]string{"database": cfg.DBName}); err != nil {
+				cons.Warn("%v", err)
+				return
+			}
+			cons.Info("Metrics pushed to %s", cfg.Pushgateway)
+		})
+	}
+
+	if cfg.MetricsListen != "" {
+		lis, err := net.Listen("tcp", cfg.MetricsListen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", cfg.MetricsListen, err)
+		}
+		var done atomic.Bool
+		scraped := make(chan struct{}, 1)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			final := done.Load()
+			reg.Handler().ServeHTTP(w, r)
+			if final {
+				select {
+				case scraped <- struct{}{}:
+				default:
+				}
+			}
+		})
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(lis)
+		cons.Success("Serving metrics on %s/metrics", cfg.MetricsListen)
+
+		// Runs before the push, since cleanups run in reverse
+		cleanups = append(cleanups, func() {
+			done.Store(true)
+			if cfg.MetricsLinger > 0 {
+				cons.Info("Waiting up to %s for a final scrape of /metrics...", cfg.MetricsLinger)
+				select {
+				case <-scraped:
+				case <-time.After(cfg.MetricsLinger):
+					cons.Warn("/metrics was not scraped within %s", cfg.MetricsLinger)
+				}
+			}
+			srv.Close()
+		})
+	}
+	return mx, nil
+}
+
+// confirmer returns a prompt on stdin, or one that always agrees when yes is set
+func confirmer(yes bool) migration.ConfirmFunc {
+	return func(prompt string) bool {
+		if yes {
+			return true
+		}
+		fmt.Printf("%s [y/N]: ", prompt)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
+
+// logTLS reports the negotiated TLS version and cipher, as required for audits of FIPS deployments
+func logTLS(cfg *config.Config, database *db.DB, cons *console.Console) {
+	version, cipher, err := database.TLSStatus()
+	switch {
+	case err != nil:
+		cons.Warn("Could not read the TLS status: %v", err)
+	case version != "":
+		mode := ""
+		if cfg.FIPS {
+			mode = ", FIPS mode"
+		}
+		if cipher == "" {
+			// SQL Server does not report its cipher
+			cons.Info("Connection encrypted with %s", version)
+			break
+		}
+		cons.Info("Connection encrypted with %s (%s%s)", version, cipher, mode)
+	case cfg.SSLMode != "" && cfg.SSLMode != db.SSLDisabled:
+		cons.Warn("The server does not support TLS; the connection is not encrypted")
+	}
+}
+
+// verifyAuditLog checks the hash chain of an --audit-log and, given a public key, its signatures
+func verifyAuditLog(path, keyFile string, cons *console.Console) error {
+	var publicKey crypto.PublicKey
+	if keyFile != "" {
+		key, err := migration.LoadVerifyKey(keyFile)
+		if err != nil {
+			return err
+		}
+		publicKey = key
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	count, err := migration.VerifyAuditLog(file, publicKey)
+	if err != nil {
+		return err
+	}
+	if publicKey == nil {
+		cons.Success("Hash chain of %d run reports is intact (signatures not checked; pass --audit-key)", count)
+	} else {
+		cons.Success("Hash chain and signatures of %d run reports are intact", count)
+	}
+	return nil
 }
 
 func printUsage() {
 	fmt.Println()
-	fmt.Println("Usage: db-migration <host> <user> <password> <dbname> <port> <scripts_dir> [missed_scripts_file]")
+	fmt.Println("Usage: db-migration [command] [flags] <host> <user> <password> <dbname> <port> <scripts_dir> [missed_scripts_file]")
+	fmt.Println("       db-migration down [n] [flags] <host> <user> <password> <dbname> <port> <scripts_dir>")
+	fmt.Println("       db-migration rerun [flags] <script> <host> <user> <password> <dbname> <port> <scripts_dir>")
+	fmt.Println("       db-migration import [flags] <golang-migrate|goose> <host> <user> <password> <dbname> <port> <scripts_dir>")
+	fmt.Println("       db-migration [command] --driver sqlite --dsn <file> [flags] <scripts_dir> [missed_scripts_file]")
+	fmt.Println("       db-migration bundle push [--sign-key <cosign.key>] <ref> <scripts_dir>")
+	fmt.Println("       db-migration login <profile>")
+	fmt.Println("       db-migration audit verify [--audit-key <public.pem>] <audit_log>")
+	fmt.Println("       db-migration watch [--interval 1m] [--on-apply <command>] [flags] <host> <user> <password> <dbname> <port> <scripts_dir>")
+	fmt.Println("       db-migration serve [--listen :8080] [--grpc-listen :9090 --grpc-cert <file> --grpc-key <file> --grpc-client-ca <file>] [flags] <host> <user> <password> <dbname> <port> <scripts_dir>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  up                 Execute pending scripts (default)")
+	fmt.Println("  down [n]           Revert the last batch, or the last n scripts, using paired .down.sql scripts")
+	fmt.Println("  seed               Run new or changed seed data scripts only")
+	fmt.Println("  rerun <script>     Re-execute an applied script after verifying its checksum")
+	fmt.Println("  import <golang-migrate|goose> Record scripts applied by another tool")
+	fmt.Println("  baseline           Record the scripts up to HEAD or --to as applied without executing them")
+	fmt.Println("  mark-applied <plan.sql> Record the scripts of a plan --format sql bundle, run by hand, as applied")
+	fmt.Println("  repair             Delete failed attempts, recompute checksums and fix batches in the tracking table (--dry-run)")
+	fmt.Println("  export             Write applied history for Flyway or Liquibase (--format, --output)")
+	fmt.Println("  plan               Show what up would execute, with risk levels (--format text|tfplan-json|sql)")
+	fmt.Println("  status             Show the last batch and the applied, skipped, failed and pending scripts")
+	fmt.Println("  validate           Run the checks of up without executing anything; exit 8 on problems")
+	fmt.Println("  history            List the tracking table, oldest first (--limit)")
+	fmt.Println("  check              Exit 2 when scripts are pending, 3 when applied ones were modified, 4 on drift from --from/--from-db")
+	fmt.Println("  rollout            Apply and verify the --targets in order, e.g. canary then prod; stop at the first failure")
+	fmt.Println("  docs               Write Markdown/Mermaid documentation of the schema (--output)")
+	fmt.Println("  bundle push <ref>  Push the committed scripts as an OCI artifact, signed with --sign-key")
+	fmt.Println("  login <profile>    Store a password in the OS keychain for keyring://<profile>")
+	fmt.Println("  audit verify <log> Check the hash chain and signatures of an --audit-log")
+	fmt.Println("  watch              Apply new commits of the scripts repository every --interval, pulling its upstream")
+	fmt.Println("  serve              Serve /plan, /apply, /status, /history and /healthz over HTTP (--listen, $DB_MIGRATION_API_TOKEN) and gRPC (--grpc-listen)")
+	fmt.Println("  diff               Generate a script from a schema file or database to this one (--from, --from-db)")
+	fmt.Println("  drift              Exit 4 when tables, columns or indexes changed since the last batch's --schema-snapshot (or --from, --from-db)")
+	fmt.Println()
+	fmt.Println("Flags:")
+	fmt.Println("  --to <commit|script> (down) Revert everything applied after this commit or script; (baseline) baseline at this commit")
+	fmt.Println("  --var key=value    Value for ${key} placeholders in scripts (repeatable)")
+	fmt.Println("  --strict-vars      Fail on undefined placeholders")
+	fmt.Println("  --env <name>       Environment name (exposed to .sql.tmpl scripts)")
+	fmt.Println("  --tenant <name>    Tenant name (exposed to .sql.tmpl scripts)")
+	fmt.Println("  --render-archive <dir> Archive rendered templates per batch")
+	fmt.Println("  --schema-snapshot <dir> Write the schema after each batch; (drift) read the snapshot of the last batch")
+	fmt.Println("  --docs <file>      Regenerate Markdown schema documentation after each batch")
+	fmt.Println("  --seed-dir <dir>   Directory of seed data scripts (run after schema scripts)")
+	fmt.Println("  --reseed           (seed) Re-run all seed scripts")
+	fmt.Println("  --include-dir <dir> Include fragments directory, relative to scripts_dir (default: common)")
+	fmt.Println("  --max-script-size <size> Fail scripts larger than this, e.g. 512KB or 2MB")
+	fmt.Println("  --max-statements <n> Fail scripts with more statements than this")
+	fmt.Println("  --max-insert-rows <n> Fail scripts with an INSERT of more rows than this")
+	fmt.Println("  --yes              (rerun, import, baseline, repair, mark-applied) Skip the confirmation prompt")
+	fmt.Println("  --tags <a,b>       Only run scripts with one of these tags; defer the rest")
+	fmt.Println("  --skip-tags <a,b>  Defer scripts with any of these tags")
+	fmt.Println("  --sarif <file>     Write validation findings as SARIF for code scanning")
+	fmt.Println("  --report-junit <file> Write a JUnit XML report of validation rules and scripts")
+	fmt.Println("  --manifest <file>  Write a JSON manifest of the database objects each script touched")
+	fmt.Println("  --audit-log <file> Append a hash-chained JSON report of the run to this file")
+	fmt.Println("  --audit-key <file> PEM private key (Ed25519, ECDSA or RSA) to sign reports with; (audit verify) public key")
+	fmt.Println("  --ticket-pattern <regex> Link scripts to issue IDs in their names and commit messages")
+	fmt.Println("  --ticket-comment-url <url> POST a comment to each ticket applied in --ticket-comment-env ({ticket} placeholder)")
+	fmt.Println("  --ticket-comment-body <json> Comment body template (default: Jira REST v2 comment)")
+	fmt.Println("  --ticket-comment-env <name> Environment whose runs comment on tickets (default: prod)")
+	fmt.Println("  --tracker-user <user> User that writes the tracking tables (password: --tracker-password or $DB_MIGRATION_TRACKER_PASSWORD)")
+	fmt.Println("  --least-privilege  Check SHOW GRANTS for every privilege the pending scripts need before running them")
+	fmt.Println("  --allow-duplicates Run scripts whose statements match an applied script")
+	fmt.Println("  --no-verify-checksums Do not compare applied scripts with their recorded checksums")
+	fmt.Println("  --no-load-infile   Load data files with batched INSERTs instead of LOAD DATA LOCAL INFILE")
+	fmt.Println("  --format <format>  (export) flyway-history or liquibase-changelog; (plan) text, tfplan-json or sql")
+	fmt.Println("  --output <file>    (export, docs, rollout) File to write; rollout writes its JSON report")
+	fmt.Println("  --from <schema.sql> (diff, check, drift) Schema file to compare against, e.g. a schema snapshot")
+	fmt.Println("  --from-db <dbname> (diff, check, drift) Database on the same server to compare against")
+	fmt.Println("  --name <desc>      (diff) Description in the generated script name (default: schema_diff)")
+	fmt.Println("  --sign-key <file>  (bundle push) cosign private key to sign the artifact with")
+	fmt.Println("  --verify-key <file> cosign public key to verify an oci:// scripts_dir with")
+	fmt.Println("  --scripts-repo <url> Clone this git repository and read the scripts from it; leave out scripts_dir")
+	fmt.Println("  --scripts-path <dir> (--scripts-repo) Scripts directory within the repository (default: its root)")
+	fmt.Println("  --ref <ref>        Branch, tag or commit to migrate to, reading scripts from it (default: HEAD)")
+	fmt.Println("  --scripts-dir <[namespace=]dir> Scripts directory instead of scripts_dir, tracked in its namespace (repeatable)")
+	fmt.Println("  --scripts-dirs <file> File of scripts directories, one [namespace] dir per line, migrated in order")
+	fmt.Println("  --source <source>  Read scripts from git at the commit migrated to (git, default), the working tree (worktree) or the filesystem without git (filesystem)")
+	fmt.Println("  --password-stdin   Read the password from the first line of stdin; pass - as password")
+	fmt.Println("  --password-file <file> Read the password from the first line of this file; pass - as password")
+	fmt.Println("  --vault-path <path> Read short-lived credentials from Vault (VAULT_ADDR, VAULT_TOKEN); pass - as user and password")
+	fmt.Println("  --azure-ad         Authenticate to Azure Database for MySQL with an Entra ID token; pass - as password")
+	fmt.Println("  --conn-attr key=value Connection attribute for audit plugins and session_connect_attrs (repeatable)")
+	fmt.Println("  --session-init <sql> Statements to run on every connection of <user>, e.g. SET ROLE migrator_role")
+	fmt.Println("  --config <file>    YAML or TOML file of connection arguments and flags, below flags and environment")
+	fmt.Println("  --driver <mysql|postgres|mssql|sqlite> Database server (default: mysql)")
+	fmt.Println("  --dsn <file>       (sqlite) Database file; replaces all connection arguments but <scripts_dir>")
+	fmt.Println("  --ssl-mode <mode>  TLS: disabled, preferred, required, verify-ca or verify-identity")
+	fmt.Println("  --ssl-ca <file>    PEM CA bundle verifying the database certificate (default: system roots)")
+	fmt.Println("  --tls-min-version <1.2|1.3> Minimum TLS version (default: 1.2)")
+	fmt.Println("  --fips             Only FIPS 140 approved TLS parameters (default in FIPS 140 builds)")
+	fmt.Println("  --proxy <url>      socks5://, socks5h:// or http:// proxy for the database, git and cloud APIs (default: $ALL_PROXY)")
+	fmt.Println("  --ssh-host <host[:port]> Connect to the database through this SSH bastion")
+	fmt.Println("  --ssh-user <user>  SSH user (default: current user)")
+	fmt.Println("  --ssh-key <file>   SSH private key (default: ssh-agent and ~/.ssh/id_*)")
+	fmt.Println("  --ssh-known-hosts <file> known_hosts file verifying the bastion (default: ~/.ssh/known_hosts)")
+	fmt.Println("  --k8s              Run as a Kubernetes Job or init container: leader election, JSON logs, exit 0 only when current")
+	fmt.Println("  --lock-timeout <duration> Wait this long for another run holding the migration lock (default: 5m)")
+	fmt.Println("  --statement-timeout <duration> Cancel any statement of a script running longer than this")
+	fmt.Println("  --slow-threshold <duration>    List scripts running at least this long in the summary (default: 30s, 0 disables)")
+	fmt.Println("  --retries <n>      Run a script again after a deadlock, lock wait timeout or lost connection (default: 3)")
+	fmt.Println("  --retry-delay <duration> Wait before the first retry; doubles for each further one (default: 1s)")
+	fmt.Println("  --wait-for-db <duration> Retry connecting with backoff while the database starts, e.g. 5m")
+	fmt.Println("  --log-format <text|json> Log format (default: text; --k8s implies json); json ends up with a run report")
+	fmt.Println("  --log-level <level> Lowest level logged: debug, info, warn or error (default: info)")
+	fmt.Println("  --log-output <stdout|stderr> Where to log (default: stdout; text errors always go to stderr)")
+	fmt.Println("  --log-file <path>  Also write every message to this file, without colors, rotating it by size")
+	fmt.Println("  --log-file-max-size <size> (log-file) Rotate the file before it grows past this size (default: 10MB; 0 never rotates)")
+	fmt.Println("  --log-file-backups <n> (log-file) Rotated files to keep as <path>.1 to <path>.<n> (default: 5)")
+	fmt.Println("  -q, --quiet        Print only errors and the summary")
+	fmt.Println("  -v, --verbose      Print debug details, as --log-level debug")
+	fmt.Println("  -vv                Print debug details, every git command and every SQL statement")
+	fmt.Println("  --then-exec <command> (up) Replace the process with this command once the schema is current")
+	fmt.Println("  --detailed-exitcode (up) Exit 11 instead of 0 when no script was pending")
+	fmt.Println("  --targets <file>   Run up against every database in this file: [name] [user[:password]@]host[:port]/dbname per line")
+	fmt.Println("  --parallel <n>     (targets) Databases to migrate at a time (default: 1)")
+	fmt.Println("  --continue-on-error (targets) Keep starting targets after one failed")
+	fmt.Println("  --auto-revert      (rollout) Revert a target's batch with its down scripts when verification fails")
+	fmt.Println("  --interval <duration> (watch) How often to check for new commits (default: 1m)")
+	fmt.Println("  --on-apply <command> (watch) Shell command run after each run, with the JSON report on stdin")
+	fmt.Println("  --pushgateway <url> (up, serve, watch) Push Prometheus metrics of the runs to this Pushgateway on exit")
+	fmt.Println("  --metrics-listen <addr> (up, serve, watch) Serve Prometheus metrics of the runs on /metrics")
+	fmt.Println("  --metrics-linger <duration> (metrics-listen) After the last run, wait this long for a final scrape (default: 30s)")
+	fmt.Println("  --notify-webhook <url> (up, serve, watch) Post the start, success and failure of runs as JSON to this URL")
+	fmt.Println("  --notify-slack <url> (up, serve, watch) Post the start, success and failure of runs to this Slack incoming webhook")
+	fmt.Println("  --window <spec>    (up) Maintenance window, e.g. \"Sat 01:00-04:00 Europe/Berlin\"; outside it, exit 5")
+	fmt.Println("  --wait             (window) Wait for the window to open instead of exiting")
+	fmt.Println("  --window-tags <a,b> (window) Only hold back scripts with one of these tags; run the others now")
+	fmt.Println("  --listen <addr>    (serve) Address of the HTTP API (default: :8080 unless only --grpc-listen is given)")
+	fmt.Println("  --grpc-listen <addr> (serve) Address of the gRPC API, with mTLS")
+	fmt.Println("  --grpc-cert <file> (serve) Certificate of the gRPC API")
+	fmt.Println("  --grpc-key <file>  (serve) Private key of the gRPC API")
+	fmt.Println("  --grpc-client-ca <file> (serve) CA gRPC client certificates must be signed by")
+	fmt.Println("  --approval-webhook <url> (serve, rollout) Post each plan to this webhook and wait for a signed approval")
+	fmt.Println("  --approval-secret <secret> (approval) HMAC secret of approval tokens, or a secret reference")
+	fmt.Println("  --approval-timeout <duration> (approval) How long to wait for a decision (default: 1h)")
+	fmt.Println("  --approval-url <url> (approval) Public URL of the approval callback, shown in the message")
+	fmt.Println("  --approval-listen <addr> (rollout) Address to receive approval callbacks on")
+	fmt.Println("  --approval-targets <names> (rollout) Targets that need approval (default: all)")
+	fmt.Println("  --limit <n>        (history) Only list the last n records")
+	fmt.Println("  --dry-run          (up) Print the pending scripts in execution order without executing them")
+	fmt.Println("  --encoding <name>  Script encoding: utf-8 (default), utf-16, iso-8859-1, windows-1252")
 	fmt.Println()
 	fmt.Println("Arguments:")
-	fmt.Println("  host               MySQL host address")
+	fmt.Println("  host               MySQL host address, cloudsql://<project>:<region>:<instance>[/<db>] or rds://<endpoint>[/<db>]")
 	fmt.Println("  user               MySQL username")
-	fmt.Println("  password           MySQL password")
+	fmt.Println("  password           MySQL password, or a secret reference (aws-sm://, gcp-sm://, azure-kv://, keyring://)")
 	fmt.Println("  dbname             Database name")
 	fmt.Println("  port               MySQL port number")
-	fmt.Println("  scripts_dir        Directory containing SQL migration scripts")
+	fmt.Println("  scripts_dir        Directory containing SQL migration scripts, or oci://<ref> of a pushed bundle")
 	fmt.Println("  missed_scripts_file (optional) File containing list of missed scripts to execute")
 	fmt.Println()
+	fmt.Println("Environment:")
+	fmt.Println("  DB_MIGRATION_HOST, _USER, _PASSWORD, _DBNAME, _PORT, _SCRIPTS_DIR  Connection arguments, when none are given")
+	fmt.Println("  DB_MIGRATION_<FLAG>  Any flag not given, e.g. DB_MIGRATION_WAIT_FOR_DB=5m for --wait-for-db 5m")
+	fmt.Println("  DB_MIGRATION_PASSWORD is also read for a - password argument; without it, a terminal prompts")
+	fmt.Println("  Precedence: command line, then environment, then --config file")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0 success, 1 other failure, 2-4 check and drift findings, 5 outside --window, 6 configuration error,")
+	fmt.Println("  7 connection error, 8 validation failure, 9 script failure, 10 lock contention,")
+	fmt.Println("  11 nothing to do (--detailed-exitcode), 130 interrupted")
+	fmt.Println()
 	fmt.Println("Example:")
 	fmt.Println("  db-migration localhost root password mydb 3306 ./migrations")
 	fmt.Println("  db-migration localhost root password mydb 3306 ./migrations missed.txt")
+	fmt.Println("  db-migration --config db-migration.yaml")
+	fmt.Println("  db-migration --password-file /run/secrets/db_password localhost root - mydb 3306 ./migrations")
+	fmt.Println("  db-migration --driver postgres localhost postgres password mydb 5432 ./migrations")
+	fmt.Println("  db-migration --driver mssql sql.internal sa password mydb 1433 ./migrations")
+	fmt.Println("  db-migration --driver sqlite --dsn ./dev.db ./migrations")
+	fmt.Println("  db-migration down --to 001_create_users.sql localhost root password mydb 3306 ./migrations")
+	fmt.Println("  db-migration rerun R__refresh_views.sql localhost root password mydb 3306 ./migrations")
+	fmt.Println("  db-migration bundle push --sign-key cosign.key ghcr.io/org/schema:1.4.0 ./migrations")
+	fmt.Println("  db-migration --verify-key cosign.pub localhost root password mydb 3306 oci://ghcr.io/org/schema:1.4.0")
+	fmt.Println("  db-migration --scripts-repo https://github.com/org/app.git --scripts-path migrations --ref main localhost root password mydb 3306")
+	fmt.Println("  db-migration cloudsql://my-project:europe-west1:main/app migrator secret - 3306 ./migrations")
+	fmt.Println("  db-migration rds://app.proxy-abc123.eu-west-1.rds.amazonaws.com/app migrator - - 3306 ./migrations")
+	fmt.Println("  db-migration localhost root keyring://local mydb 3306 ./migrations")
+	fmt.Println("  db-migration --targets shards.txt --parallel 4 - migrator secret - 3306 ./migrations")
+	fmt.Println("  db-migration audit verify --audit-key audit.pub audit.jsonl")
+	fmt.Println("  DB_MIGRATION_API_TOKEN=s3cret db-migration serve --listen :8080 db.internal migrator - app 3306 ./migrations")
+	fmt.Println("  db-migration diff --from schema/latest.sql --name add_orders localhost root password devdb 3306 ./migrations")
+	fmt.Println("  db-migration history --limit 20 db.internal readonly secret app 3306 ./migrations")
+	fmt.Println("  db-migration check --from schema/latest.sql db.internal readonly secret app 3306 ./migrations")
+	fmt.Println("  DB_MIGRATION_HOST=mysql DB_MIGRATION_USER=migrator DB_MIGRATION_DBNAME=app DB_MIGRATION_SCRIPTS_DIR=/migrations db-migration --then-exec /app/server")
+	fmt.Println("  db-migration rollout --targets envs.txt --auto-revert --output rollout.json - migrator secret - 3306 ./migrations")
 	fmt.Println()
 }
-
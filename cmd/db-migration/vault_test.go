@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/vault"
+)
+
+// fakeVault hands out a new lease on every read and records renewals and revocations
+type fakeVault struct {
+	mu      sync.Mutex
+	leases  int
+	renewed map[string]int
+	revoked []string
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body struct {
+		LeaseID string `json:"lease_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	switch r.URL.Path {
+	case "/v1/database/creds/migrator":
+		f.leases++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       fmt.Sprintf("database/creds/migrator/%d", f.leases),
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]string{"username": fmt.Sprintf("v-migrator-%d", f.leases), "password": "p4ss"},
+		})
+	case "/v1/sys/leases/renew":
+		f.renewed[body.LeaseID]++
+		json.NewEncoder(w).Encode(map[string]interface{}{"lease_duration": 1})
+	case "/v1/sys/leases/revoke":
+		f.revoked = append(f.revoked, body.LeaseID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeVault) state() (map[string]int, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	renewed := make(map[string]int, len(f.renewed))
+	for id, n := range f.renewed {
+		renewed[id] = n
+	}
+	return renewed, append([]string(nil), f.revoked...)
+}
+
+func TestUseVaultCredentials(t *testing.T) {
+	fake := &fakeVault{renewed: map[string]int{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	t.Setenv(vault.AddrEnv, server.URL)
+	t.Setenv(vault.TokenEnv, "s.token")
+	t.Cleanup(func() { cleanups = nil })
+
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	cons.SetErrorOutput(io.Discard)
+	cfg := &config.Config{VaultPath: "database/creds/migrator"}
+	refresh, err := useVaultCredentials(cfg, cons)
+	if err != nil {
+		t.Fatalf("useVaultCredentials failed: %v", err)
+	}
+	if cfg.User != "v-migrator-1" || cfg.Password != "p4ss" {
+		t.Errorf("expected the Vault credentials to replace user and password, got %s/%s", cfg.User, cfg.Password)
+	}
+
+	// The lease of a long run is renewed in the background
+	deadline := time.Now().Add(3 * time.Second)
+	for renewed, _ := fake.state(); renewed["database/creds/migrator/1"] == 0; renewed, _ = fake.state() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the lease to be renewed during the run")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A reconnect reads a new lease and revokes the old one
+	if err := refresh(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if cfg.User != "v-migrator-2" {
+		t.Errorf("expected the refreshed user v-migrator-2, got %s", cfg.User)
+	}
+	if _, revoked := fake.state(); len(revoked) != 1 || revoked[0] != "database/creds/migrator/1" {
+		t.Errorf("expected the first lease to be revoked on refresh, got %v", revoked)
+	}
+
+	// Exiting revokes the current lease and stops renewing it
+	runCleanups()
+	renewed, revoked := fake.state()
+	if len(revoked) != 2 || revoked[1] != "database/creds/migrator/2" {
+		t.Errorf("expected the second lease to be revoked on exit, got %v", revoked)
+	}
+	time.Sleep(time.Second)
+	if after, _ := fake.state(); after["database/creds/migrator/2"] != renewed["database/creds/migrator/2"] {
+		t.Errorf("expected no renewals after exit, got %d more", after["database/creds/migrator/2"]-renewed["database/creds/migrator/2"])
+	}
+}
@@ -0,0 +1,241 @@
+// Package approval gates applies on a human decision made in chat: the plan is posted
+// to a webhook, e.g. a Slack incoming webhook, and the apply waits until a signed
+// approval arrives at the callback, or the timeout expires
+package approval
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decisions carried by a token
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// ErrTimeout is returned when no decision arrived in time
+var ErrTimeout = errors.New("no approval arrived before the timeout")
+
+// Claims are the contents of a callback token. Tokens are HS256 JSON Web Tokens signed
+// with the shared secret, so a ChatOps bot can issue them with any JWT library once it
+// identified the user who clicked
+type Claims struct {
+	Approval string `json:"approval"`           // ID of the approval request
+	Subject  string `json:"sub"`                // Approver identity, e.g. a Slack user
+	Decision string `json:"decision,omitempty"` // approve (default) or reject
+	Expires  int64  `json:"exp"`                // Unix time
+}
+
+// jwtHeader is the only header tokens may have
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Sign returns the token of claims
+func Sign(secret []byte, claims Claims) string {
+	payload, _ := json.Marshal(claims)
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac(secret, signed))
+}
+
+// Verify checks the signature and expiry of a token and returns its claims
+func Verify(secret []byte, token string, now time.Time) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("malformed token")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, fmt.Errorf("malformed token header")
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if json.Unmarshal(header, &h) != nil || h.Alg != "HS256" {
+		return claims, fmt.Errorf("token must be signed with HS256")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, mac(secret, parts[0]+"."+parts[1])) {
+		return claims, fmt.Errorf("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return claims, fmt.Errorf("malformed token claims")
+	}
+
+	switch {
+	case claims.Approval == "" || claims.Subject == "":
+		return claims, fmt.Errorf("token lacks the approval or sub claim")
+	case claims.Decision != "" && claims.Decision != DecisionApprove && claims.Decision != DecisionReject:
+		return claims, fmt.Errorf("unknown decision %q", claims.Decision)
+	case claims.Expires == 0 || now.After(time.Unix(claims.Expires, 0)):
+		return claims, fmt.Errorf("token expired")
+	}
+	return claims, nil
+}
+
+func mac(secret []byte, signed string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(signed))
+	return h.Sum(nil)
+}
+
+// Request is what an approval is asked for
+type Request struct {
+	Title string // e.g. "Apply 3 scripts to app on db-prod.internal"
+	Text  string // e.g. the plan
+}
+
+// Gate posts approval requests and waits for their decisions
+type Gate struct {
+	webhookURL  string
+	secret      []byte
+	timeout     time.Duration
+	callbackURL string
+	client      *http.Client
+	newID       func() string
+
+	mu      sync.Mutex
+	waiting map[string]chan Claims
+}
+
+// New returns a Gate posting to webhookURL and accepting tokens signed with secret
+// for timeout. callbackURL is where approvers reach Handler, shown in the message
+func New(webhookURL string, secret []byte, timeout time.Duration, callbackURL string) *Gate {
+	return &Gate{
+		webhookURL:  webhookURL,
+		secret:      secret,
+		timeout:     timeout,
+		callbackURL: callbackURL,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		newID:       newID,
+		waiting:     make(map[string]chan Claims),
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Await posts req and blocks until it is approved, returning the approver. A rejection,
+// the timeout or ctx ending fails it
+func (g *Gate) Await(ctx context.Context, req Request) (string, error) {
+	id := g.newID()
+	decision := make(chan Claims, 1)
+	g.mu.Lock()
+	g.waiting[id] = decision
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		delete(g.waiting, id)
+		g.mu.Unlock()
+	}()
+
+	deadline := time.Now().Add(g.timeout)
+	if err := g.post(ctx, id, req, deadline); err != nil {
+		return "", err
+	}
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case claims := <-decision:
+		if claims.Decision == DecisionReject {
+			return "", fmt.Errorf("rejected by %s", claims.Subject)
+		}
+		return claims.Subject, nil
+	case <-timer.C:
+		return "", ErrTimeout
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// post sends the approval request to the webhook as a Slack message
+func (g *Gate) post(ctx context.Context, id string, req Request, deadline time.Time) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "*Approval required:* %s\n", req.Title)
+	if req.Text != "" {
+		fmt.Fprintf(&text, "```\n%s\n```\n", strings.TrimRight(req.Text, "\n"))
+	}
+	fmt.Fprintf(&text, "Approval ID `%s`, open until %s", id, deadline.UTC().Format(time.RFC3339))
+	if g.callbackURL != "" {
+		fmt.Fprintf(&text, ". Decide by posting a signed token to %s", g.callbackURL)
+	}
+	body, _ := json.Marshal(map[string]string{"text": text.String(), "approval_id": id})
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create approval request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to post approval request: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("approval webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Handler receives decisions: POST with the token as the token form value or as a
+// bearer token. It needs no other authentication, since tokens are signed
+func (g *Gate) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+			return
+		}
+		token := r.FormValue("token")
+		if token == "" {
+			token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		claims, err := Verify(g.secret, token, time.Now())
+		if err != nil {
+			reply(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+
+		g.mu.Lock()
+		decision, ok := g.waiting[claims.Approval]
+		if ok {
+			// Only the first decision counts
+			delete(g.waiting, claims.Approval)
+		}
+		g.mu.Unlock()
+		if !ok {
+			reply(w, http.StatusNotFound, map[string]string{"error": "no pending approval " + claims.Approval})
+			return
+		}
+		decision <- claims
+
+		status := "approved"
+		if claims.Decision == DecisionReject {
+			status = "rejected"
+		}
+		reply(w, http.StatusOK, map[string]string{"approval": claims.Approval, "status": status, "by": claims.Subject})
+	})
+}
+
+func reply(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,116 @@
+package approval
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now()
+	claims := Claims{Approval: "a1", Subject: "alice", Expires: now.Add(time.Minute).Unix()}
+
+	got, err := Verify(secret, Sign(secret, claims), now)
+	if err != nil || got != claims {
+		t.Fatalf("expected the claims back, got %+v %v", got, err)
+	}
+
+	tampered := strings.Split(Sign(secret, claims), ".")
+	forged, _ := json.Marshal(Claims{Approval: "a1", Subject: "mallory", Expires: claims.Expires})
+	tampered[1] = base64.RawURLEncoding.EncodeToString(forged)
+	for name, token := range map[string]string{
+		"wrong secret": Sign([]byte("other"), claims),
+		"expired":      Sign(secret, Claims{Approval: "a1", Subject: "alice", Expires: now.Add(-time.Second).Unix()}),
+		"no subject":   Sign(secret, Claims{Approval: "a1", Expires: claims.Expires}),
+		"bad decision": Sign(secret, Claims{Approval: "a1", Subject: "alice", Decision: "maybe", Expires: claims.Expires}),
+		"tampered":     strings.Join(tampered, "."),
+		"malformed":    "not-a-token",
+	} {
+		if _, err := Verify(secret, token, now); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	secret := []byte("s3cret")
+	posted := make(chan map[string]string, 4)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		posted <- msg
+	}))
+	defer webhook.Close()
+
+	gate := New(webhook.URL, secret, 5*time.Second, "https://migrate.example.com/approvals")
+	callback := httptest.NewServer(gate.Handler())
+	defer callback.Close()
+	decide := func(id, subject, decision string) int {
+		token := Sign(secret, Claims{Approval: id, Subject: subject, Decision: decision, Expires: time.Now().Add(time.Minute).Unix()})
+		resp, err := http.PostForm(callback.URL, url.Values{"token": {token}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	await := func() (chan string, chan error) {
+		approver, errs := make(chan string, 1), make(chan error, 1)
+		go func() {
+			by, err := gate.Await(context.Background(), Request{Title: "apply 1 scripts to app", Text: "create low 001_users.sql"})
+			approver <- by
+			errs <- err
+		}()
+		return approver, errs
+	}
+
+	// An approval returns the approver; a second decision finds nothing pending
+	approver, errs := await()
+	msg := <-posted
+	if !strings.Contains(msg["text"], "001_users.sql") || !strings.Contains(msg["text"], "https://migrate.example.com/approvals") || msg["approval_id"] == "" {
+		t.Errorf("expected the plan and callback in the message, got %v", msg)
+	}
+	if code := decide("unknown", "alice", ""); code != http.StatusNotFound {
+		t.Errorf("expected an unknown approval to be 404, got %d", code)
+	}
+	if code := decide(msg["approval_id"], "alice", DecisionApprove); code != http.StatusOK {
+		t.Errorf("expected the approval to be accepted, got %d", code)
+	}
+	if by, err := <-approver, <-errs; by != "alice" || err != nil {
+		t.Errorf("expected approval by alice, got %q %v", by, err)
+	}
+	if code := decide(msg["approval_id"], "bob", DecisionReject); code != http.StatusNotFound {
+		t.Errorf("expected a decided approval to be 404, got %d", code)
+	}
+
+	// A rejection fails the wait
+	_, errs = await()
+	msg = <-posted
+	decide(msg["approval_id"], "bob", DecisionReject)
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "rejected by bob") {
+		t.Errorf("expected the rejection, got %v", err)
+	}
+
+	// Without a decision, the wait times out
+	gate.timeout = 50 * time.Millisecond
+	_, errs = await()
+	<-posted
+	if err := <-errs; !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	// Invalid tokens are refused
+	resp, _ := http.PostForm(callback.URL, url.Values{"token": {"forged"}})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an invalid token to be 401, got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,194 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Layer file names inside the artifact
+const (
+	RepoFile     = "repo.bundle"
+	ManifestFile = "manifest.json"
+)
+
+// ErrUnverified marks a bundle whose signature could not be verified with the key
+var ErrUnverified = errors.New("signature verification failed")
+
+// Manifest describes the git history packed into an artifact
+type Manifest struct {
+	Commit      string    `json:"commit"`
+	ScriptsPath string    `json:"scriptsPath"` // Scripts directory relative to the repository root
+	RepoSHA256  string    `json:"repoSha256"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Pack writes the committed history of the repository holding scriptsDir, and its
+// manifest, into outDir; uncommitted changes are refused so the artifact matches a commit.
+// logger receives the git commands run (optional)
+func Pack(scriptsDir, outDir string, logger *slog.Logger) (*Manifest, error) {
+	g := git.New(scriptsDir, logger)
+	if !g.IsGitRepository() {
+		return nil, fmt.Errorf("scripts directory is not within a git repository")
+	}
+	clean, err := g.IsClean()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check working tree: %w", err)
+	}
+	if !clean {
+		return nil, fmt.Errorf("scripts directory has uncommitted changes - commit them before packing")
+	}
+
+	commit, err := g.GetCurrentCommit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current commit: %w", err)
+	}
+	prefix, err := g.Prefix()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scripts path: %w", err)
+	}
+
+	repoFile := filepath.Join(outDir, RepoFile)
+	if err := g.CreateBundle(repoFile); err != nil {
+		return nil, fmt.Errorf("failed to create git bundle: %w", err)
+	}
+	sum, err := fileSHA256(repoFile)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Commit:      commit,
+		ScriptsPath: strings.TrimSuffix(prefix, "/"),
+		RepoSHA256:  sum,
+		CreatedAt:   time.Now().UTC(),
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, ManifestFile), content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// Unpack checks the files written by Pack in dir against the manifest, clones the
+// history into dest and returns the manifest and the scripts directory of the checkout.
+// The clone is killed once ctx is done; logger receives the git commands run (optional)
+func Unpack(ctx context.Context, dir, dest string, logger *slog.Logger) (*Manifest, string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, "", fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	repoFile := filepath.Join(dir, RepoFile)
+	sum, err := fileSHA256(repoFile)
+	if err != nil {
+		return nil, "", err
+	}
+	if sum != manifest.RepoSHA256 {
+		return nil, "", fmt.Errorf("%s does not match the manifest checksum", RepoFile)
+	}
+
+	if err := git.CloneBundle(ctx, repoFile, dest, logger); err != nil {
+		return nil, "", fmt.Errorf("failed to clone git bundle: %w", err)
+	}
+	head, err := git.New(dest, logger).GetCurrentCommit()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get bundle commit: %w", err)
+	}
+	if head != manifest.Commit {
+		return nil, "", fmt.Errorf("bundle is at commit %s, manifest expects %s", head, manifest.Commit)
+	}
+
+	return &manifest, filepath.Join(dest, filepath.FromSlash(manifest.ScriptsPath)), nil
+}
+
+// Push packs scriptsDir, pushes it to ref and signs it when signKey is set
+// It returns the manifest and the pinned reference (repository@digest)
+func Push(scriptsDir, ref, signKey string, logger *slog.Logger) (*Manifest, string, error) {
+	dir, err := os.MkdirTemp("", "db-migration-bundle-")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := Pack(scriptsDir, dir, logger)
+	if err != nil {
+		return nil, "", err
+	}
+
+	oci := NewOCI(context.Background(), dir)
+	digest, err := oci.Push(ref, RepoFile+":"+RepoMediaType, ManifestFile+":"+ManifestMediaType)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to push %s: %w", ref, err)
+	}
+	pinned := Repository(ref) + "@" + digest
+
+	if signKey != "" {
+		if err := oci.Sign(pinned, signKey); err != nil {
+			return nil, "", fmt.Errorf("failed to sign %s: %w", pinned, err)
+		}
+	}
+	return manifest, pinned, nil
+}
+
+// Fetch resolves ref to a digest, verifies its signature with verifyKey, pulls it
+// into dir and returns the manifest and the scripts directory of the checkout
+// The digest is pinned first so the verified artifact is the one pulled. The CLIs and
+// git are killed once ctx is done
+func Fetch(ctx context.Context, ref, verifyKey, dir string, logger *slog.Logger) (*Manifest, string, error) {
+	ref = strings.TrimPrefix(ref, config.OCIScheme)
+	layers := filepath.Join(dir, "layers")
+	if err := os.MkdirAll(layers, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create %s: %w", layers, err)
+	}
+
+	oci := NewOCI(ctx, layers)
+	digest, err := oci.Resolve(ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	pinned := Repository(ref) + "@" + digest
+
+	if err := oci.Verify(pinned, verifyKey); err != nil {
+		return nil, "", fmt.Errorf("%w for %s: %w", ErrUnverified, pinned, err)
+	}
+	if err := oci.Pull(pinned); err != nil {
+		return nil, "", fmt.Errorf("failed to pull %s: %w", pinned, err)
+	}
+
+	return Unpack(ctx, layers, filepath.Join(dir, "repo"), logger)
+}
+
+// fileSHA256 returns the hex SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
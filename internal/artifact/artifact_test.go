@@ -0,0 +1,88 @@
+package artifact
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+func TestRepository(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/org/schema:1.4.0":               "ghcr.io/org/schema",
+		"ghcr.io/org/schema":                     "ghcr.io/org/schema",
+		"localhost:5000/schema:latest":           "localhost:5000/schema",
+		"localhost:5000/schema":                  "localhost:5000/schema",
+		"ghcr.io/org/schema@sha256:0123456789ab": "ghcr.io/org/schema",
+	}
+	for ref, want := range tests {
+		if got := Repository(ref); got != want {
+			t.Errorf("Repository(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("db/migrations")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	commit := repo.CommitScripts("Add users")
+
+	out := t.TempDir()
+	manifest, err := Pack(scriptsDir, out, nil)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if manifest.Commit != commit || manifest.ScriptsPath != "db/migrations" {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	unpacked, dir, err := Unpack(context.Background(), out, filepath.Join(t.TempDir(), "repo"), nil)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if unpacked.Commit != commit {
+		t.Errorf("expected commit %s, got %s", commit, unpacked.Commit)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "001_create_users.sql"))
+	if err != nil || string(content) != testkit.SQLScripts.CreateUsers {
+		t.Errorf("expected the script in the checkout, got %q (%v)", content, err)
+	}
+}
+
+func TestPackRefusesUncommittedChanges(t *testing.T) {
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("migrations")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+	repo.AddSQLScript(scriptsDir, "002_create_posts.sql", testkit.SQLScripts.CreatePosts)
+
+	if _, err := Pack(scriptsDir, t.TempDir(), nil); err == nil || !strings.Contains(err.Error(), "uncommitted") {
+		t.Errorf("expected uncommitted changes error, got %v", err)
+	}
+}
+
+func TestUnpackRejectsTamperedRepo(t *testing.T) {
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("migrations")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	out := t.TempDir()
+	if _, err := Pack(scriptsDir, out, nil); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(out, RepoFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("tampered")
+	file.Close()
+
+	if _, _, err := Unpack(context.Background(), out, filepath.Join(t.TempDir(), "repo"), nil); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("expected checksum error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Media types of the artifact and its layers
+const (
+	ArtifactType      = "application/vnd.db-migration.bundle.v1"
+	RepoMediaType     = "application/vnd.db-migration.git-bundle"
+	ManifestMediaType = "application/vnd.db-migration.manifest.v1+json"
+)
+
+// digestPattern finds the manifest digest in oras output
+var digestPattern = regexp.MustCompile(`sha256:[0-9a-f]{64}`)
+
+// OCI provides registry operations through the oras and cosign CLIs
+type OCI struct {
+	ctx     context.Context
+	workDir string
+}
+
+// NewOCI creates a new OCI instance running commands in workDir; they are killed once
+// ctx is done
+func NewOCI(ctx context.Context, workDir string) *OCI {
+	return &OCI{ctx: ctx, workDir: workDir}
+}
+
+// run executes a CLI and returns its output
+func (o *OCI) run(name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(o.ctx, name, args...)
+	cmd.Dir = o.workDir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s %s failed: %s", name, strings.Join(args, " "), string(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Push uploads files of the working directory as layers of an artifact and returns its digest
+// Each file is given as name:mediaType
+func (o *OCI) Push(ref string, files ...string) (string, error) {
+	args := append([]string{"push", ref, "--artifact-type", ArtifactType}, files...)
+	output, err := o.run("oras", args...)
+	if err != nil {
+		return "", err
+	}
+	digest := digestPattern.FindString(output)
+	if digest == "" {
+		return "", fmt.Errorf("oras push did not report a digest")
+	}
+	return digest, nil
+}
+
+// Resolve returns the digest a tag currently points to
+func (o *OCI) Resolve(ref string) (string, error) {
+	output, err := o.run("oras", "resolve", ref)
+	if err != nil {
+		return "", err
+	}
+	digest := digestPattern.FindString(output)
+	if digest == "" {
+		return "", fmt.Errorf("oras resolve did not report a digest for %s", ref)
+	}
+	return digest, nil
+}
+
+// Pull downloads the layers of an artifact into the working directory
+func (o *OCI) Pull(ref string) error {
+	_, err := o.run("oras", "pull", ref, "--output", ".")
+	return err
+}
+
+// Sign signs an artifact with a cosign private key
+func (o *OCI) Sign(ref, key string) error {
+	_, err := o.run("cosign", "sign", "--yes", "--key", key, ref)
+	return err
+}
+
+// Verify checks the cosign signature of an artifact against a public key
+func (o *OCI) Verify(ref, key string) error {
+	_, err := o.run("cosign", "verify", "--key", key, ref)
+	return err
+}
+
+// Repository strips the tag or digest from a reference
+// (e.g. ghcr.io/org/schema:1.4.0 -> ghcr.io/org/schema)
+func Repository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
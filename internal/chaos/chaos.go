@@ -0,0 +1,95 @@
+// Package chaos injects failures into migration runs, to test how interrupted runs
+// recover: a connection lost after some script, slow tracking table writes, or the
+// process killed halfway through a script. Faults come from DB_MIGRATION_CHAOS only
+// in binaries built with the chaos tag, so release builds never inject any
+package chaos
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env is the variable faults are read from, e.g.
+// DB_MIGRATION_CHAOS=kill-conn-after=2,tracker-delay=500ms,crash-after=3
+const Env = "DB_MIGRATION_CHAOS"
+
+// Faults are the failures injected into a run. Scripts are counted from 1 across the
+// Migrator's runs; the zero value injects nothing
+type Faults struct {
+	KillConnAfter int           // Drop the database connection after the Nth script completes
+	TrackerDelay  time.Duration // Sleep before each tracking row is written
+	CrashAfter    int           // Kill the process once the Nth script's statements ran, before it is recorded
+	// Crash kills the process; the default is SIGKILL, tests replace it with a panic
+	Crash func()
+}
+
+// Parse reads faults from a comma-separated list of name=value pairs
+func Parse(spec string) (*Faults, error) {
+	f := &Faults{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault %q: expected name=value", pair)
+		}
+		var err error
+		switch name {
+		case "kill-conn-after":
+			f.KillConnAfter, err = strconv.Atoi(value)
+		case "crash-after":
+			f.CrashAfter, err = strconv.Atoi(value)
+		case "tracker-delay":
+			f.TrackerDelay, err = time.ParseDuration(value)
+		default:
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault %s: %w", name, err)
+		}
+	}
+	return f, nil
+}
+
+// FromEnv returns the faults of DB_MIGRATION_CHAOS, or nil when it is unset or the
+// binary was built without the chaos tag
+func FromEnv() (*Faults, error) {
+	spec := os.Getenv(Env)
+	if !Enabled || spec == "" {
+		return nil, nil
+	}
+	return Parse(spec)
+}
+
+// KillConn reports whether the connection is dropped after the nth script
+func (f *Faults) KillConn(n int) bool {
+	return f != nil && f.KillConnAfter == n
+}
+
+// DelayTrackerWrite sleeps before a tracking row is written
+func (f *Faults) DelayTrackerWrite() {
+	if f != nil && f.TrackerDelay > 0 {
+		time.Sleep(f.TrackerDelay)
+	}
+}
+
+// ScriptExecuted crashes when the nth script's statements ran but it is not recorded yet
+func (f *Faults) ScriptExecuted(n int) {
+	if f == nil || f.CrashAfter != n {
+		return
+	}
+	if f.Crash != nil {
+		f.Crash()
+		return
+	}
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Kill()
+	}
+	// Never continue past a crash point
+	os.Exit(137)
+}
@@ -0,0 +1,54 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse("kill-conn-after=2, tracker-delay=500ms,crash-after=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.KillConnAfter != 2 || f.TrackerDelay != 500*time.Millisecond || f.CrashAfter != 3 {
+		t.Errorf("unexpected faults: %+v", f)
+	}
+
+	for _, spec := range []string{"crash-after", "crash-after=x", "tracker-delay=5", "flood=1"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(Env, "crash-after=1")
+	f, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Enabled != (f != nil) {
+		t.Errorf("expected faults only in chaos builds, got %+v", f)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	// A nil Faults injects nothing
+	var none *Faults
+	none.DelayTrackerWrite()
+	none.ScriptExecuted(1)
+	if none.KillConn(1) {
+		t.Error("nil faults should not kill connections")
+	}
+
+	crashed := 0
+	f := &Faults{KillConnAfter: 2, CrashAfter: 3, Crash: func() { crashed++ }}
+	if f.KillConn(1) || !f.KillConn(2) {
+		t.Error("expected the connection to be killed after the second script only")
+	}
+	f.ScriptExecuted(2)
+	f.ScriptExecuted(3)
+	if crashed != 1 {
+		t.Errorf("expected one crash, got %d", crashed)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !chaos
+
+package chaos
+
+// Enabled reports whether faults are read from the environment
+const Enabled = false
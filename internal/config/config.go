@@ -1,13 +1,96 @@
 package config
 
 import (
+	"crypto/fips140"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/connector"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/proxy"
+	"github.com/bontaramsonta/db-migration/internal/textenc"
+	"github.com/bontaramsonta/db-migration/internal/window"
+)
+
+// Commands supported by the CLI
+const (
+	CommandUp          = "up"           // Execute pending scripts (default)
+	CommandDown        = "down"         // Revert the last batch using paired down scripts
+	CommandSeed        = "seed"         // Run seed data scripts only
+	CommandRerun       = "rerun"        // Re-execute one applied script whose checksum still matches
+	CommandImport      = "import"       // Adopt the applied state of another migration tool
+	CommandExport      = "export"       // Write applied history in another tool's format
+	CommandDiff        = "diff"         // Generate a script from the difference between two schemas
+	CommandDocs        = "docs"         // Write Markdown/Mermaid documentation of the schema
+	CommandBundle      = "bundle"       // Push the scripts as a signed OCI artifact
+	CommandPlan        = "plan"         // Show what up would execute, with risk levels
+	CommandLogin       = "login"        // Store a password in the OS keychain
+	CommandAudit       = "audit"        // Verify the hash chain and signatures of an --audit-log
+	CommandServe       = "serve"        // Serve the migration API over HTTP
+	CommandWatch       = "watch"        // Apply new commits of the scripts repository continuously
+	CommandCheck       = "check"        // Fail when the database is behind or diverged, for CI gates
+	CommandRollout     = "rollout"      // Apply and verify the --targets one after another, canary first
+	CommandStatus      = "status"       // Show the last batch and the status of every script
+	CommandValidate    = "validate"     // Run the checks of up without executing anything
+	CommandHistory     = "history"      // List the tracking table
+	CommandBaseline    = "baseline"     // Record the scripts up to a commit as applied without executing them
+	CommandRepair      = "repair"       // Reconcile the tracking table after a botched run
+	CommandMarkApplied = "mark-applied" // Record the scripts of a plan --format sql bundle, run by hand, as applied
+	CommandDrift       = "drift"        // Compare the schema with the snapshot of the last batch
+)
+
+// commandArgs names the argument taken by commands that have one
+var commandArgs = map[string]string{
+	CommandRerun:       "<script>",
+	CommandImport:      "<golang-migrate|goose>",
+	CommandMarkApplied: "<plan.sql>",
+}
+
+// OCIScheme marks a scripts_dir argument that names an OCI artifact pushed by
+// "bundle push" (e.g. oci://ghcr.io/org/schema:1.4.0) instead of a local directory
+const OCIScheme = "oci://"
+
+// Formats written by the export command
+const (
+	ExportFlyway    = "flyway-history"      // SQL for a flyway_schema_history table
+	ExportLiquibase = "liquibase-changelog" // Liquibase XML changelog
+)
+
+// Sources scripts are read from (--source)
+const (
+	SourceGit        = "git"        // The commit being migrated to, with git show (default)
+	SourceWorktree   = "worktree"   // The working tree, uncommitted edits included
+	SourceFilesystem = "filesystem" // The scripts directory without git, in lexicographic order
+)
+
+// Formats written by the plan command
+const (
+	PlanText   = "text"        // Human-readable list (default)
+	PlanTFJSON = "tfplan-json" // Flat JSON object for the Terraform/OpenTofu external data source
+	PlanSQL    = "sql"         // Annotated SQL bundle to review and run by hand, read back by mark-applied
+)
+
+// Migration tools whose state the import command reads
+const (
+	ImportGolangMigrate = "golang-migrate" // schema_migrations (version, dirty)
+	ImportGoose         = "goose"          // goose_db_version (version_id, is_applied)
 )
 
 // Config holds all configuration for the db-migration CLI
 type Config struct {
+	Command           string
 	Host              string
 	User              string
 	Password          string
@@ -15,38 +98,1028 @@ type Config struct {
 	Port              int
 	ScriptsDir        string
 	MissedScriptsFile string // Optional
+
+	// ConfigFile is a YAML or TOML file of connection arguments and flags, below the
+	// command line and the environment
+	ConfigFile string
+
+	// Driver is the database server: db.DriverMySQL (default), db.DriverPostgres,
+	// db.DriverMSSQL or db.DriverSQLite
+	Driver string
+	// DataSourceName is the SQLite database file of --dsn, which replaces the connection
+	// arguments but <scripts_dir>
+	DataSourceName string
+
+	// DownTo limits the down command: revert everything applied after this commit or script.
+	// For baseline, it is the commit whose scripts are recorded as applied
+	DownTo string
+	// DownCount limits the down command to the last n applied scripts
+	DownCount int
+
+	// Vars are substituted for ${NAME} placeholders in scripts
+	Vars map[string]string
+	// StrictVars fails scripts that reference undefined placeholders
+	StrictVars bool
+
+	// Environment and Tenant are exposed to .sql.tmpl scripts
+	Environment string
+	Tenant      string
+	// RenderArchiveDir receives the rendered SQL of templates, per batch (optional)
+	RenderArchiveDir string
+	// SnapshotDir receives the schema after each batch (optional)
+	SnapshotDir string
+	// DocsFile receives Markdown documentation of the schema after each batch (optional)
+	DocsFile string
+
+	// SeedDir holds reference data scripts tracked separately from schema migrations
+	SeedDir string
+	// Reseed re-runs every seed script, even unchanged ones (seed command)
+	Reseed bool
+
+	// IncludeDir holds shared SQL fragments for the include directive, relative to ScriptsDir
+	IncludeDir string
+
+	// Budget limits the size and complexity of pending scripts
+	Budget Budget
+
+	// Tags limits up to scripts carrying one of these tags; others are deferred
+	Tags []string
+	// SkipTags defers scripts carrying any of these tags
+	SkipTags []string
+
+	// SARIFFile receives validation findings in SARIF format (up command, optional)
+	SARIFFile string
+
+	// JUnitFile receives a JUnit XML report of validation rules and scripts (up command, optional)
+	JUnitFile string
+
+	// ManifestFile receives a JSON manifest of the database objects each script touched (up command, optional)
+	ManifestFile string
+
+	// AuditLog receives one hash-chained JSON run report per run (up command, optional);
+	// AuditKey is the PEM private key that signs each report, or the public key
+	// audit verify checks signatures with
+	AuditLog string
+	AuditKey string
+
+	// TicketPattern finds issue IDs (e.g. JIRA-123) in script names and commit messages;
+	// nil disables ticket linkage
+	TicketPattern *regexp.Regexp
+	// TicketCommentURL and TicketCommentBody are the REST request that comments on each
+	// ticket once its scripts are applied in TicketCommentEnv; {ticket}, {scripts}, {database},
+	// {env}, {batch} and {commit} are substituted. TicketAuth is sent as the Authorization header
+	TicketCommentURL  string
+	TicketCommentBody string
+	TicketCommentEnv  string
+	TicketAuth        string
+
+	// AllowDuplicates runs pending scripts even if their statements match an applied script
+	AllowDuplicates bool
+
+	// Encoding is the character encoding of scripts (default utf-8)
+	Encoding string
+	// Source is where script content is read from: SourceGit (default), SourceWorktree,
+	// or SourceFilesystem, which discovers scripts without git too
+	Source string
+
+	// RerunScript names the script re-executed by the rerun command
+	RerunScript string
+	// ImportSource names the tool whose state the import command reads
+	ImportSource string
+	// PlanBundle is the plan --format sql file read by the mark-applied command
+	PlanBundle string
+	// ExportFormat and ExportOutput select what the export command writes, and where
+	// ExportOutput is also the file written by the docs command; ExportFormat also
+	// selects the output of the plan command
+	ExportFormat string
+	ExportOutput string
+	// DiffFrom (a schema file) or DiffFromDB (a database on the same server) is the
+	// schema the diff command compares the connected database against, and the
+	// expected schema check detects drift from
+	DiffFrom   string
+	DiffFromDB string
+	// DiffName describes the script written by the diff command
+	DiffName string
+	// Yes answers confirmation prompts (rerun, import) with yes
+	Yes bool
+	// NoLoadInfile makes load directives use batched INSERTs instead of LOAD DATA LOCAL INFILE
+	NoLoadInfile bool
+
+	// NoVerifyChecksums turns off the comparison of applied scripts with their recorded checksums
+	NoVerifyChecksums bool
+
+	// VaultPath is the Vault secrets engine path, e.g. database/creds/migrator, that
+	// short-lived credentials are read from instead of <user> and <password>
+	VaultPath string
+
+	// PasswordStdin reads <password> from the first line of stdin, and PasswordFile from
+	// a file, e.g. a mounted secret; both require - as <password>
+	PasswordStdin bool
+	PasswordFile  string
+
+	// SSHHost is a bastion (host or host:port) the database is reached through, with
+	// SSHUser, the SSHKey private key and the SSHKnownHosts file verifying its host key
+	SSHHost       string
+	SSHUser       string
+	SSHKey        string
+	SSHKnownHosts string
+
+	// TrackerUser and TrackerPassword write the tracking tables, so the <user> executing
+	// scripts needs no access to them (optional; <user> writes them by default)
+	TrackerUser     string
+	TrackerPassword string
+
+	// ConnAttrs are sent as connection attributes next to program_name, the batch ID and
+	// the CI job URL; SessionInit runs on every connection of <user>, e.g. SET ROLE
+	ConnAttrs   map[string]string
+	SessionInit string
+
+	// Proxy is a socks5://, socks5h://, http:// or https:// proxy the database, the SSH
+	// bastion and Cloud SQL instances are dialed through; defaults to ALL_PROXY
+	Proxy string
+
+	// LeastPrivilege checks SHOW GRANTS for every privilege the run needs before executing
+	LeastPrivilege bool
+
+	// SSLMode, SSLCA and TLSMinVersion control the TLS of database connections; FIPS
+	// restricts it to FIPS 140 approved parameters and is on by default in FIPS 140 builds
+	SSLMode       string
+	SSLCA         string
+	TLSMinVersion string
+	FIPS          bool
+
+	// AzureAD authenticates with Entra ID access tokens instead of <password>
+	AzureAD bool
+
+	// Target is set when <host> selects a managed-database connector,
+	// e.g. cloudsql://project:region:instance/db or rds://<proxy endpoint>
+	Target *connector.Target
+
+	// K8s runs up as a Kubernetes Job or init container: replicas elect a leader through
+	// the database's migration lock, logs are JSON lines, and the exit code is 0 only
+	// when the schema is current
+	K8s bool
+	// LogFormat is text or json; --k8s implies json
+	LogFormat string
+	// LogLevel hides messages below it; debug shows the details of each step, and
+	// console.LevelTrace every git command and SQL statement too (-v and -vv)
+	LogLevel slog.Level
+	// Quiet shows only errors and the results of a command, e.g. the summary of a run (-q)
+	Quiet bool
+	// TraceArgs logs the values bound to the statements traced by -vv, which may include
+	// secrets; without it only their number is logged (--trace-args)
+	TraceArgs bool
+	// LogFile receives every message too, without colors; it is rotated before it grows
+	// past LogFileMaxSize bytes, keeping LogFileBackups rotated files
+	LogFile        string
+	LogFileMaxSize int64
+	LogFileBackups int
+	// LogOutput is where messages go, stdout or stderr; errors of the text format
+	// always go to stderr
+	LogOutput string
+	// ThenExec replaces the process with this command after a successful up, so one
+	// container can migrate, then serve
+	ThenExec string
+	// DetailedExitCode makes up exit with a code of its own when no script was pending
+	DetailedExitCode bool
+
+	// LockTimeout is how long a run waits for another run holding the migration lock
+	LockTimeout time.Duration
+	// StatementTimeout cancels a statement of a script running longer; zero means no limit
+	StatementTimeout time.Duration
+	// SlowThreshold lists the scripts running at least this long in the summary; zero disables it
+	SlowThreshold time.Duration
+	// Retries runs a script again this many times after a deadlock, a lock wait timeout or
+	// a lost connection, when nothing of it was committed
+	Retries int
+	// RetryDelay is the wait before the first retry; it doubles for each further one
+	RetryDelay time.Duration
+
+	// WaitForDB retries the first connection with backoff for this long, while the
+	// database is still starting (zero fails at once)
+	WaitForDB time.Duration
+
+	// TargetsFile lists databases (shards, tenants) that up runs against instead of
+	// <host> and <dbname>, Parallel at a time; after a failure, targets not started yet
+	// are skipped unless ContinueOnError
+	TargetsFile     string
+	Targets         []Target
+	Parallel        int
+	ContinueOnError bool
+	// AutoRevert reverts the batch a rollout applied to a target whose verify
+	// scripts failed
+	AutoRevert bool
+
+	// Window restricts up to a maintenance window: outside it, up waits for it with
+	// WaitForWindow or exits. With WindowTags, only scripts carrying one of these tags
+	// wait for the window and the others run immediately
+	WindowSpec    string
+	Window        *window.Window
+	WaitForWindow bool
+	WindowTags    []string
+
+	// WatchInterval is how often the watch command checks for new commits, and OnApply
+	// a shell command it runs after each run that applied scripts or failed
+	WatchInterval time.Duration
+	OnApply       string
+
+	// Pushgateway receives the metrics of the runs when the process exits, and
+	// MetricsListen serves them on /metrics; after the last run, it waits up to
+	// MetricsLinger for a final scrape
+	Pushgateway   string
+	MetricsListen string
+	MetricsLinger time.Duration
+
+	// NotifyWebhook receives the start, success and failure of runs as JSON, and
+	// NotifySlack the same as a Slack message
+	NotifyWebhook string
+	NotifySlack   string
+
+	// Listen is the address the serve command listens on, and APIToken the bearer token
+	// its endpoints require
+	Listen   string
+	APIToken string
+	// GRPCListen is the address of the serve command's gRPC API, which authenticates
+	// clients by a certificate signed by GRPCClientCA
+	GRPCListen   string
+	GRPCCert     string
+	GRPCKey      string
+	GRPCClientCA string
+
+	// ApprovalWebhook makes serve and rollout post the plan of each apply to this
+	// webhook, e.g. Slack's, and wait up to ApprovalTimeout for an approval token signed
+	// with ApprovalSecret. Tokens arrive at POST /approvals of the serve API, or of
+	// ApprovalListen during a rollout; ApprovalURL is that endpoint as approvers reach it.
+	// A rollout only asks for the ApprovalTargets, or every target when empty
+	ApprovalWebhook string
+	ApprovalSecret  string
+	ApprovalTimeout time.Duration
+	ApprovalURL     string
+	ApprovalListen  string
+	ApprovalTargets []string
+
+	// HistoryLimit makes the history command list only the last records
+	HistoryLimit int
+
+	// DryRun makes up discover, check and order the pending scripts, and print them
+	// instead of executing them; repair prints its changes instead of making them
+	DryRun bool
+
+	// Profile names the OS keychain entry the login command stores a password in
+	Profile string
+
+	// BundleRef is the OCI reference the bundle push command pushes to
+	BundleRef string
+	// SignKey is the cosign private key bundle push signs with (optional)
+	SignKey string
+	// VerifyKey is the cosign public key an oci:// scripts_dir is verified with
+	VerifyKey string
+
+	// ScriptsRepo is a remote git repository cloned to a temp dir and read instead of a
+	// scripts_dir argument; ScriptsPath is the scripts directory within it
+	ScriptsRepo string
+	ScriptsPath string
+	// Ref is the branch, tag or commit migrated to instead of HEAD; scripts are read
+	// from it rather than the working tree. A ScriptsRepo is checked out at it
+	Ref string
+
+	// Namespaces are the scripts directories of --scripts-dir or the NamespacesFile,
+	// migrated in this order instead of a scripts_dir argument. Namespace is the one
+	// of ScriptsDir, which its tracking records are kept under ("" for scripts_dir)
+	Namespaces     []Namespace
+	NamespacesFile string
+	Namespace      string
 }
 
+// Budget holds per-script limits enforced before execution (zero means unlimited)
+type Budget struct {
+	MaxScriptSize int64 // Bytes
+	MaxStatements int
+	MaxInsertRows int // Rows in a single INSERT ... VALUES statement
+}
+
+// TunnelNetwork is the mysql driver network that dials through the --ssh-host tunnel
+const TunnelNetwork = "ssh-tunnel"
+
+// ProxyNetwork is the mysql driver network that dials through the --proxy
+const ProxyNetwork = "proxy"
+
+// TrackerPasswordEnv holds the --tracker-user password when --tracker-password is not given
+const TrackerPasswordEnv = "DB_MIGRATION_TRACKER_PASSWORD"
+
+// APITokenEnv holds the bearer token of the serve command's API, or a secret reference to it
+const APITokenEnv = "DB_MIGRATION_API_TOKEN"
+
+// DefaultWatchInterval is how often the watch command checks without --interval
+const DefaultWatchInterval = time.Minute
+
+// DefaultMetricsLinger is how long --metrics-listen waits for a final scrape by default
+const DefaultMetricsLinger = 30 * time.Second
+
+// Rotation of --log-file by default: 10MB files, the current one and 5 rotated ones
+const (
+	DefaultLogFileMaxSize = 10 << 20
+	DefaultLogFileBackups = 5
+)
+
+// Log formats of --log-format
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// Log destinations of --log-output
+const (
+	LogStdout = "stdout"
+	LogStderr = "stderr"
+)
+
+// DefaultApprovalTimeout is how long an apply waits for approval without --approval-timeout
+const DefaultApprovalTimeout = time.Hour
+
+// DefaultLockTimeout is how long a run waits for the migration lock by default
+const DefaultLockTimeout = 5 * time.Minute
+
+// Default retries of scripts failing with transient errors, see Config.Retries
+const (
+	DefaultRetries    = 3
+	DefaultRetryDelay = time.Second
+)
+
+// DefaultListen is the address the serve command listens on without --listen
+const DefaultListen = ":8080"
+
+// TicketAuthEnv holds the Authorization header value for ticket comments,
+// e.g. "Bearer <token>" or "Basic <base64 user:token>"
+const TicketAuthEnv = "DB_MIGRATION_TICKET_AUTH"
+
+// DefaultTicketCommentBody is a Jira REST API v2 comment
+const DefaultTicketCommentBody = `{"body": "{scripts} applied to {database} ({env}) in batch {batch} at commit {commit}"}`
+
+// VarEnvPrefix marks environment variables that define script placeholders
+// (e.g. DB_MIGRATION_VAR_SCHEMA_PREFIX defines ${SCHEMA_PREFIX})
+const VarEnvPrefix = "DB_MIGRATION_VAR_"
+
 // ParseArgs parses command line arguments into Config
-// Usage: db-migration <host> <user> <password> <dbname> <port> <scripts_dir> [missed_scripts_file]
+// Usage: db-migration [command] [flags] [script] <host> <user> <password> <dbname> <port> <scripts_dir> [missed_scripts_file]
 func ParseArgs(args []string) (*Config, error) {
-	if len(args) < 6 {
-		return nil, fmt.Errorf("usage: db-migration <host> <user> <password> <dbname> <port> <scripts_dir> [missed_scripts_file]")
+	cfg := &Config{Command: CommandUp, Vars: varsFromEnv(os.Environ()), ConnAttrs: make(map[string]string), TicketAuth: os.Getenv(TicketAuthEnv)}
+
+	if len(args) > 0 && isCommand(args[0]) {
+		cfg.Command = args[0]
+		args = args[1:]
 	}
 
-	port, err := strconv.Atoi(args[4])
+	fs := flag.NewFlagSet("db-migration", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&cfg.ConfigFile, "config", "", "YAML or TOML file of connection arguments and flags")
+	fs.StringVar(&cfg.Driver, "driver", db.DriverMySQL, "database driver: mysql, postgres, mssql or sqlite")
+	fs.StringVar(&cfg.DataSourceName, "dsn", "", "sqlite: database file, e.g. ./dev.db; replaces all connection arguments but <scripts_dir>")
+	fs.StringVar(&cfg.DownTo, "to", "", "down: revert scripts applied after this commit or script; baseline: record the scripts up to this commit")
+	fs.Var(varFlag(cfg.Vars), "var", "placeholder value as key=value (repeatable)")
+	fs.BoolVar(&cfg.StrictVars, "strict-vars", false, "fail on undefined placeholders")
+	fs.StringVar(&cfg.Environment, "env", "", "environment name (e.g. staging, prod)")
+	fs.StringVar(&cfg.Tenant, "tenant", "", "tenant name exposed to templates")
+	fs.StringVar(&cfg.RenderArchiveDir, "render-archive", "", "directory to archive rendered templates")
+	fs.StringVar(&cfg.SnapshotDir, "schema-snapshot", "", "directory to write the schema after each batch")
+	fs.StringVar(&cfg.DocsFile, "docs", "", "Markdown file to document the schema in after each batch")
+	fs.StringVar(&cfg.SeedDir, "seed-dir", "", "directory of seed data scripts")
+	fs.BoolVar(&cfg.Reseed, "reseed", false, "seed: re-run all seed scripts")
+	fs.StringVar(&cfg.IncludeDir, "include-dir", "common", "directory of include fragments, relative to scripts_dir")
+	fs.Var((*sizeFlag)(&cfg.Budget.MaxScriptSize), "max-script-size", "maximum script size, e.g. 512KB or 2MB")
+	fs.IntVar(&cfg.Budget.MaxStatements, "max-statements", 0, "maximum statements per script")
+	fs.IntVar(&cfg.Budget.MaxInsertRows, "max-insert-rows", 0, "maximum rows in a single INSERT")
+	fs.BoolVar(&cfg.Yes, "yes", false, "answer confirmation prompts with yes")
+	fs.Var((*listFlag)(&cfg.Tags), "tags", "only run scripts with one of these tags (comma-separated)")
+	fs.Var((*listFlag)(&cfg.SkipTags), "skip-tags", "defer scripts with any of these tags (comma-separated)")
+	fs.StringVar(&cfg.SARIFFile, "sarif", "", "write validation findings to this SARIF file")
+	fs.StringVar(&cfg.JUnitFile, "report-junit", "", "write a JUnit XML report to this file")
+	fs.StringVar(&cfg.ManifestFile, "manifest", "", "write a manifest of the database objects touched by each script to this file")
+	fs.StringVar(&cfg.AuditLog, "audit-log", "", "append a hash-chained report of the run to this file")
+	fs.StringVar(&cfg.AuditKey, "audit-key", "", "PEM private key to sign --audit-log reports with; audit verify: public key")
+	fs.Var(patternFlag{&cfg.TicketPattern}, "ticket-pattern", "regular expression matching issue IDs in script names and commit messages")
+	fs.StringVar(&cfg.TicketCommentURL, "ticket-comment-url", "", "URL to POST a comment to for each ticket, e.g. https://jira.example.com/rest/api/2/issue/{ticket}/comment")
+	fs.StringVar(&cfg.TicketCommentBody, "ticket-comment-body", DefaultTicketCommentBody, "JSON body of the ticket comment")
+	fs.StringVar(&cfg.TicketCommentEnv, "ticket-comment-env", "prod", "only comment on tickets when --env is this environment")
+	fs.BoolVar(&cfg.NoLoadInfile, "no-load-infile", false, "load data files with batched INSERTs instead of LOAD DATA LOCAL INFILE")
+	fs.BoolVar(&cfg.NoVerifyChecksums, "no-verify-checksums", false, "do not compare applied scripts with their recorded checksums")
+	fs.BoolVar(&cfg.AllowDuplicates, "allow-duplicates", false, "run scripts whose statements match an applied script")
+	fs.StringVar(&cfg.ExportFormat, "format", "", "export: flyway-history or liquibase-changelog; plan: text or tfplan-json")
+	fs.StringVar(&cfg.ExportOutput, "output", "", "export, docs: file to write")
+	fs.StringVar(&cfg.DiffFrom, "from", "", "diff, check: schema file to compare against")
+	fs.StringVar(&cfg.DiffFromDB, "from-db", "", "diff, check: database on the same server to compare against")
+	fs.StringVar(&cfg.DiffName, "name", "schema_diff", "diff: description used in the generated script name")
+	fs.StringVar(&cfg.SignKey, "sign-key", "", "bundle push: cosign private key to sign with")
+	fs.StringVar(&cfg.VerifyKey, "verify-key", "", "cosign public key to verify an oci:// scripts_dir with")
+	fs.StringVar(&cfg.ScriptsRepo, "scripts-repo", "", "clone this git repository and read the scripts from it instead of <scripts_dir>")
+	fs.StringVar(&cfg.ScriptsPath, "scripts-path", "", "scripts-repo: scripts directory within the repository (default: its root)")
+	fs.Var(namespaceFlag{&cfg.Namespaces}, "scripts-dir", "scripts directory as [namespace=]dir instead of <scripts_dir>, tracked in its namespace (repeatable)")
+	fs.StringVar(&cfg.NamespacesFile, "scripts-dirs", "", "file of scripts directories, one [namespace] dir per line, migrated in order")
+	fs.StringVar(&cfg.Source, "source", SourceGit, "read scripts from git (the commit migrated to), the worktree, uncommitted edits included, or the filesystem without git")
+	fs.StringVar(&cfg.Ref, "ref", "", "branch, tag or commit to migrate to, read from git instead of the working tree (default: HEAD)")
+	fs.StringVar(&cfg.VaultPath, "vault-path", "", "read database credentials from this Vault path, e.g. database/creds/migrator")
+	fs.StringVar(&cfg.TrackerUser, "tracker-user", "", "user that writes the tracking tables (default: <user>)")
+	fs.BoolVar(&cfg.PasswordStdin, "password-stdin", false, "read <password> from the first line of stdin; pass - as <password>")
+	fs.StringVar(&cfg.PasswordFile, "password-file", "", "read <password> from this file; pass - as <password>")
+	fs.StringVar(&cfg.TrackerPassword, "tracker-password", "", "password of --tracker-user (default: $"+TrackerPasswordEnv+")")
+	fs.BoolVar(&cfg.LeastPrivilege, "least-privilege", false, "check that the user has every privilege the pending scripts need before running them")
+	fs.BoolVar(&cfg.AzureAD, "azure-ad", false, "authenticate to Azure Database for MySQL with an Entra ID token (managed identity, client credentials or az login)")
+	fs.Var(varFlag(cfg.ConnAttrs), "conn-attr", "connection attribute as key=value (repeatable)")
+	fs.StringVar(&cfg.SessionInit, "session-init", "", "statements to run on every connection, e.g. SET ROLE migrator_role")
+	fs.StringVar(&cfg.SSLMode, "ssl-mode", "", "TLS of database connections: disabled, preferred, required, verify-ca or verify-identity")
+	fs.StringVar(&cfg.SSLCA, "ssl-ca", "", "PEM CA bundle verifying the database certificate (default: system roots)")
+	fs.StringVar(&cfg.TLSMinVersion, "tls-min-version", "", "minimum TLS version: 1.2 (default) or 1.3")
+	fs.BoolVar(&cfg.FIPS, "fips", fips140.Enabled(), "only use FIPS 140 approved TLS versions, cipher suites and curves")
+	fs.StringVar(&cfg.Proxy, "proxy", proxy.AllProxy(), "socks5:// or http:// proxy to reach the database through (default: $ALL_PROXY)")
+	fs.StringVar(&cfg.SSHHost, "ssh-host", "", "connect to the database through this SSH bastion (host or host:port)")
+	fs.StringVar(&cfg.SSHUser, "ssh-user", "", "SSH user (default: current user)")
+	fs.StringVar(&cfg.SSHKey, "ssh-key", "", "SSH private key (default: ssh-agent and ~/.ssh/id_*)")
+	fs.StringVar(&cfg.SSHKnownHosts, "ssh-known-hosts", "", "known_hosts file verifying the bastion (default: ~/.ssh/known_hosts)")
+	fs.BoolVar(&cfg.K8s, "k8s", false, "run as a Kubernetes Job or init container: leader election, JSON logs, exit 0 only when current")
+	fs.StringVar(&cfg.LogFormat, "log-format", LogText, "log format: text or json")
+	fs.TextVar(&cfg.LogLevel, "log-level", slog.LevelInfo, "lowest level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.LogOutput, "log-output", LogStdout, "where to log: stdout or stderr")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "also write every message to this file, without colors")
+	cfg.LogFileMaxSize = DefaultLogFileMaxSize
+	fs.Var((*sizeFlag)(&cfg.LogFileMaxSize), "log-file-max-size", "log-file: rotate the file before it grows past this size, e.g. 50MB (0 never rotates)")
+	fs.IntVar(&cfg.LogFileBackups, "log-file-backups", DefaultLogFileBackups, "log-file: rotated files to keep")
+	var verbose, veryVerbose bool
+	fs.BoolVar(&cfg.Quiet, "q", false, "print only errors and the summary")
+	fs.BoolVar(&cfg.Quiet, "quiet", false, "print only errors and the summary")
+	fs.BoolVar(&verbose, "v", false, "print debug details, as --log-level debug")
+	fs.BoolVar(&verbose, "verbose", false, "print debug details, as --log-level debug")
+	fs.BoolVar(&veryVerbose, "vv", false, "print debug details, every git command and every SQL statement")
+	fs.BoolVar(&cfg.TraceArgs, "trace-args", false, "-vv: also print the values bound to SQL statements, which may include secrets")
+	fs.StringVar(&cfg.ThenExec, "then-exec", "", "up: replace the process with this command once the schema is current")
+	fs.BoolVar(&cfg.DetailedExitCode, "detailed-exitcode", false, "up: exit 11 instead of 0 when no script was pending")
+	fs.DurationVar(&cfg.LockTimeout, "lock-timeout", DefaultLockTimeout, "wait this long for another run holding the migration lock, e.g. 10m")
+	fs.DurationVar(&cfg.StatementTimeout, "statement-timeout", 0, "cancel any statement of a script running longer than this, e.g. 30m")
+	fs.DurationVar(&cfg.SlowThreshold, "slow-threshold", 30*time.Second, "list the scripts running at least this long in the summary (0 disables)")
+	fs.IntVar(&cfg.Retries, "retries", DefaultRetries, "run a script again up to this many times after a deadlock, lock wait timeout or lost connection")
+	fs.DurationVar(&cfg.RetryDelay, "retry-delay", DefaultRetryDelay, "wait before the first retry; it doubles for each further one")
+	fs.DurationVar(&cfg.WaitForDB, "wait-for-db", 0, "retry connecting with backoff for this long, e.g. 5m")
+	fs.StringVar(&cfg.TargetsFile, "targets", "", "file of databases to run against, one [name] [user[:password]@]host[:port]/dbname per line")
+	fs.IntVar(&cfg.Parallel, "parallel", 1, "targets: how many databases to migrate at a time")
+	fs.BoolVar(&cfg.ContinueOnError, "continue-on-error", false, "targets: keep starting targets after one failed")
+	fs.BoolVar(&cfg.AutoRevert, "auto-revert", false, "rollout: revert a target's batch with its down scripts when verification fails")
+	fs.StringVar(&cfg.WindowSpec, "window", "", "maintenance window up is restricted to, e.g. \"Sat 01:00-04:00 Europe/Berlin\"")
+	fs.BoolVar(&cfg.WaitForWindow, "wait", false, "window: wait for the window to open instead of exiting")
+	fs.Var((*listFlag)(&cfg.WindowTags), "window-tags", "window: only hold back scripts with one of these tags (comma-separated)")
+	fs.DurationVar(&cfg.WatchInterval, "interval", 0, "watch: how often to check for new commits (default "+DefaultWatchInterval.String()+")")
+	fs.StringVar(&cfg.OnApply, "on-apply", "", "watch: shell command to run after each run, with the report as JSON on stdin")
+	fs.StringVar(&cfg.Pushgateway, "pushgateway", "", "push Prometheus metrics of the runs to this Pushgateway URL on exit")
+	fs.StringVar(&cfg.MetricsListen, "metrics-listen", "", "serve Prometheus metrics of the runs on /metrics at this address, e.g. :9102")
+	fs.DurationVar(&cfg.MetricsLinger, "metrics-linger", DefaultMetricsLinger, "metrics-listen: after the last run, wait this long for a final scrape (0 disables)")
+	fs.StringVar(&cfg.NotifyWebhook, "notify-webhook", "", "post the start, success and failure of runs as JSON to this URL")
+	fs.StringVar(&cfg.NotifySlack, "notify-slack", "", "post the start, success and failure of runs to this Slack incoming webhook URL")
+	fs.StringVar(&cfg.Listen, "listen", "", "serve: address of the HTTP API (default "+DefaultListen+" unless only --grpc-listen is given)")
+	fs.StringVar(&cfg.GRPCListen, "grpc-listen", "", "serve: address of the gRPC API, e.g. :9090")
+	fs.StringVar(&cfg.GRPCCert, "grpc-cert", "", "serve: certificate file of the gRPC API")
+	fs.StringVar(&cfg.GRPCKey, "grpc-key", "", "serve: private key file of the gRPC API")
+	fs.StringVar(&cfg.GRPCClientCA, "grpc-client-ca", "", "serve: CA file gRPC client certificates must be signed by")
+	fs.StringVar(&cfg.ApprovalWebhook, "approval-webhook", "", "serve, rollout: post each plan to this webhook and wait for approval")
+	fs.StringVar(&cfg.ApprovalSecret, "approval-secret", "", "approval: HMAC secret approval tokens are signed with, or a secret reference")
+	fs.DurationVar(&cfg.ApprovalTimeout, "approval-timeout", DefaultApprovalTimeout, "approval: how long to wait for a decision")
+	fs.StringVar(&cfg.ApprovalURL, "approval-url", "", "approval: public URL of the approval callback, shown in the message")
+	fs.StringVar(&cfg.ApprovalListen, "approval-listen", "", "rollout: address to receive approval callbacks on")
+	fs.Var((*listFlag)(&cfg.ApprovalTargets), "approval-targets", "rollout: targets that need approval (comma-separated, default: all)")
+	fs.IntVar(&cfg.HistoryLimit, "limit", 0, "history: only list the last n records")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "print the pending scripts without executing them")
+	fs.StringVar(&cfg.Encoding, "encoding", textenc.UTF8, "script encoding: utf-8, utf-16, iso-8859-1 or windows-1252")
+
+	positional, err := parseInterspersed(fs, args)
 	if err != nil {
-		return nil, fmt.Errorf("invalid port number: %s", args[4])
+		return nil, err
+	}
+	if err := applyFlagEnv(fs, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	// Values are taken from the command line, then the environment, then --config
+	lookupConnection := os.LookupEnv
+	if cfg.ConfigFile != "" {
+		file, err := loadConfigFile(cfg.ConfigFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyConfigFile(fs, file, cfg.Vars, os.LookupEnv); err != nil {
+			return nil, err
+		}
+		lookupConnection = file.overEnv(os.LookupEnv)
+	}
+	if cfg.Quiet && (verbose || veryVerbose) {
+		return nil, fmt.Errorf("-q cannot be combined with -v or -vv")
+	}
+	if cfg.LogFileBackups < 0 {
+		return nil, fmt.Errorf("--log-file-backups must not be negative")
+	}
+	// -v and -vv replace --log-level
+	if verbose {
+		cfg.LogLevel = slog.LevelDebug
+	}
+	if veryVerbose {
+		cfg.LogLevel = console.LevelTrace
+	}
+	if cfg.TraceArgs && cfg.LogLevel > console.LevelTrace {
+		return nil, fmt.Errorf("--trace-args needs -vv")
+	}
+	if cfg.NamespacesFile != "" {
+		if len(cfg.Namespaces) > 0 {
+			return nil, fmt.Errorf("--scripts-dir and --scripts-dirs cannot be combined")
+		}
+		if cfg.Namespaces, err = LoadNamespaces(cfg.NamespacesFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// bundle push works on the scripts alone and never connects to a database
+	if cfg.Command == CommandBundle {
+		return parseBundleArgs(cfg, positional)
+	}
+	if cfg.SignKey != "" {
+		return nil, fmt.Errorf("--sign-key is only valid with bundle push")
 	}
 
-	cfg := &Config{
-		Host:       args[0],
-		User:       args[1],
-		Password:   args[2],
-		DBName:     args[3],
-		Port:       port,
-		ScriptsDir: args[5],
+	// audit verify reads the log alone
+	if cfg.Command == CommandAudit {
+		if len(positional) != 2 || positional[0] != "verify" {
+			return nil, fmt.Errorf("usage: db-migration audit verify [--audit-key <public.pem>] <audit_log>")
+		}
+		cfg.AuditLog = positional[1]
+		if _, err := os.Stat(cfg.AuditLog); os.IsNotExist(err) {
+			return nil, fmt.Errorf("audit log does not exist: %s", cfg.AuditLog)
+		}
+		return cfg, nil
 	}
 
-	if len(args) >= 7 {
-		cfg.MissedScriptsFile = args[6]
+	// login only talks to the OS keychain
+	if cfg.Command == CommandLogin {
+		if len(positional) != 1 || positional[0] == "" {
+			return nil, fmt.Errorf("usage: db-migration login <profile>")
+		}
+		cfg.Profile = positional[0]
+		return cfg, nil
 	}
 
-	// Validate scripts directory exists
-	if _, err := os.Stat(cfg.ScriptsDir); os.IsNotExist(err) {
+	// A --dsn names the database itself, so <scripts_dir> is the only connection argument
+	connArgs, connUsage := 6, "<host> <user> <password> <dbname> <port> <scripts_dir>"
+	if (cfg.Driver == "sqlite" || cfg.Driver == db.DriverSQLite) && cfg.DataSourceName == "" {
+		return nil, fmt.Errorf("--driver sqlite requires --dsn <file>")
+	}
+	if cfg.DataSourceName != "" {
+		connArgs, connUsage = 1, "<scripts_dir>"
+	}
+	// A --scripts-repo or --scripts-dir takes the place of <scripts_dir>
+	scriptsDirArg := cfg.ScriptsRepo == "" && len(cfg.Namespaces) == 0
+	if !scriptsDirArg {
+		connArgs--
+		connUsage = strings.TrimSpace(strings.TrimSuffix(connUsage, "<scripts_dir>"))
+	}
+
+	// down takes an optional number of scripts to revert before the connection arguments.
+	// One argument more than those is the number only if it is one; otherwise it is the
+	// missed scripts file after them
+	if cfg.Command == CommandDown && (len(positional) == connArgs+1 || (cfg.DataSourceName == "" && len(positional) == 1)) {
+		n, err := strconv.Atoi(positional[0])
+		switch {
+		case err != nil && len(positional) > 1:
+		case err != nil || n < 1:
+			return nil, fmt.Errorf("usage: db-migration down [n] [flags] %s, where n is a positive number of scripts", connUsage)
+		case cfg.DownTo != "":
+			return nil, fmt.Errorf("down takes either a number of scripts or --to, not both")
+		default:
+			cfg.DownCount = n
+			positional = positional[1:]
+		}
+	}
+
+	// Containers pass the connection arguments in the environment instead
+	_, hasArg := commandArgs[cfg.Command]
+	if len(positional) == 0 || (hasArg && len(positional) == 1) {
+		if cfg.DataSourceName != "" {
+			if scriptsDir, ok := lookupConnection(ScriptsDirEnv); ok && scriptsDirArg {
+				positional = append(positional, scriptsDir)
+			}
+		} else {
+			env, err := connectionFromEnv(lookupConnection, scriptsDirArg)
+			if err != nil {
+				return nil, err
+			}
+			positional = append(positional, env...)
+		}
+	}
+
+	// Some commands take an argument before the connection arguments
+	if argName, ok := commandArgs[cfg.Command]; ok {
+		if len(positional) < connArgs+1 {
+			return nil, fmt.Errorf("usage: db-migration %s [flags] %s %s", cfg.Command, argName, connUsage)
+		}
+		if len(positional) > connArgs+1 {
+			return nil, fmt.Errorf("%s does not take a missed scripts file", cfg.Command)
+		}
+		switch cfg.Command {
+		case CommandRerun:
+			cfg.RerunScript = positional[0]
+		case CommandImport:
+			cfg.ImportSource = positional[0]
+			if cfg.ImportSource != ImportGolangMigrate && cfg.ImportSource != ImportGoose {
+				return nil, fmt.Errorf("unknown import source %q (expected %s or %s)", cfg.ImportSource, ImportGolangMigrate, ImportGoose)
+			}
+		case CommandMarkApplied:
+			cfg.PlanBundle = positional[0]
+		}
+		positional = positional[1:]
+	}
+
+	if len(positional) < connArgs {
+		return nil, fmt.Errorf("usage: db-migration [command] [flags] %s [missed_scripts_file]", connUsage)
+	}
+
+	if cfg.DataSourceName != "" {
+		// main is SQLite's name for the database of the file, e.g. in main.users
+		cfg.DBName = "main"
+		if scriptsDirArg {
+			cfg.ScriptsDir = positional[0]
+		}
+	} else {
+		port, err := strconv.Atoi(positional[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port number: %s", positional[4])
+		}
+
+		cfg.Host = positional[0]
+		cfg.User = positional[1]
+		cfg.Password = positional[2]
+		cfg.DBName = positional[3]
+		cfg.Port = port
+		if scriptsDirArg {
+			cfg.ScriptsDir = positional[5]
+		}
+	}
+	if len(cfg.Namespaces) > 0 {
+		cfg.ScriptsDir, cfg.Namespace = cfg.Namespaces[0].Dir, cfg.Namespaces[0].Name
+	}
+
+	if len(positional) > connArgs {
+		cfg.MissedScriptsFile = positional[connArgs]
+	}
+
+	// serve applies whenever it is asked to, so it keeps no state of its own
+	if cfg.Command == CommandServe {
+		if cfg.MissedScriptsFile != "" {
+			return nil, fmt.Errorf("serve does not take a missed scripts file")
+		}
+		// The gRPC API authenticates clients by certificate only
+		grpcTLS := cfg.GRPCCert != "" || cfg.GRPCKey != "" || cfg.GRPCClientCA != ""
+		if cfg.GRPCListen != "" && (cfg.GRPCCert == "" || cfg.GRPCKey == "" || cfg.GRPCClientCA == "") {
+			return nil, fmt.Errorf("--grpc-listen requires --grpc-cert, --grpc-key and --grpc-client-ca")
+		}
+		if cfg.GRPCListen == "" && grpcTLS {
+			return nil, fmt.Errorf("--grpc-cert, --grpc-key and --grpc-client-ca require --grpc-listen")
+		}
+		if cfg.Listen == "" && cfg.GRPCListen == "" {
+			cfg.Listen = DefaultListen
+		}
+		if cfg.Listen != "" {
+			cfg.APIToken = os.Getenv(APITokenEnv)
+			if cfg.APIToken == "" {
+				return nil, fmt.Errorf("serve requires a bearer token in $%s", APITokenEnv)
+			}
+		}
+	} else if cfg.Listen != "" {
+		return nil, fmt.Errorf("--listen is only valid with the serve command")
+	} else if cfg.GRPCListen != "" || cfg.GRPCCert != "" || cfg.GRPCKey != "" || cfg.GRPCClientCA != "" {
+		return nil, fmt.Errorf("--grpc-listen, --grpc-cert, --grpc-key and --grpc-client-ca are only valid with the serve command")
+	}
+
+	if cfg.Command == CommandWatch {
+		if cfg.MissedScriptsFile != "" {
+			return nil, fmt.Errorf("watch does not take a missed scripts file")
+		}
+		if cfg.WatchInterval == 0 {
+			cfg.WatchInterval = DefaultWatchInterval
+		}
+		if cfg.WatchInterval < time.Second {
+			return nil, fmt.Errorf("--interval must be at least 1s")
+		}
+	} else if cfg.WatchInterval != 0 || cfg.OnApply != "" {
+		return nil, fmt.Errorf("--interval and --on-apply are only valid with the watch command")
+	}
+
+	if cfg.Target, err = connector.Parse(cfg.Host); err != nil {
+		return nil, err
+	}
+	if cfg.Target != nil {
+		if err := applyTarget(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.TrackerUser == "" && cfg.TrackerPassword != "" {
+		return nil, fmt.Errorf("--tracker-password requires --tracker-user")
+	}
+	if cfg.TrackerUser != "" && cfg.TrackerPassword == "" {
+		cfg.TrackerPassword = os.Getenv(TrackerPasswordEnv)
+	}
+
+	if cfg.AzureAD {
+		if cfg.Target != nil || cfg.VaultPath != "" {
+			return nil, fmt.Errorf("--azure-ad cannot be used with --vault-path or a cloudsql:// or rds:// host")
+		}
+		if cfg.Password != "" && cfg.Password != "-" {
+			return nil, fmt.Errorf("--azure-ad replaces <password>; pass -")
+		}
+		cfg.Password = ""
+	}
+
+	if err := applyTLSPolicy(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyDriver(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.SSHHost == "" && (cfg.SSHUser != "" || cfg.SSHKey != "" || cfg.SSHKnownHosts != "") {
+		return nil, fmt.Errorf("--ssh-user, --ssh-key and --ssh-known-hosts require --ssh-host")
+	}
+
+	// Static credentials next to --vault-path would be ignored, so they are refused
+	if cfg.VaultPath != "" {
+		if (cfg.User != "" && cfg.User != "-") || (cfg.Password != "" && cfg.Password != "-") {
+			return nil, fmt.Errorf("--vault-path replaces <user> and <password>; pass - for both")
+		}
+		cfg.User, cfg.Password = "", ""
+	}
+	if cfg.PasswordStdin || cfg.PasswordFile != "" {
+		if cfg.PasswordStdin && cfg.PasswordFile != "" {
+			return nil, fmt.Errorf("--password-stdin and --password-file cannot be used together")
+		}
+		if cfg.Password != "-" {
+			return nil, fmt.Errorf("--password-stdin and --password-file replace <password>; pass -")
+		}
+	}
+	if cfg.PasswordFile != "" {
+		if cfg.Password, err = readPasswordFile(cfg.PasswordFile); err != nil {
+			return nil, err
+		}
+	}
+	// A - password is read from the environment or --config, out of the process listing;
+	// --password-stdin reads it once the command runs
+	if cfg.Password == "-" && !cfg.PasswordStdin {
+		if password, ok := lookupConnection(PasswordEnv); ok {
+			cfg.Password = password
+		}
+	}
+
+	encoding, ok := textenc.Canonical(cfg.Encoding)
+	if !ok {
+		return nil, fmt.Errorf("unsupported script encoding: %s", cfg.Encoding)
+	}
+	cfg.Encoding = encoding
+
+	if cfg.Budget.MaxStatements < 0 || cfg.Budget.MaxInsertRows < 0 {
+		return nil, fmt.Errorf("--max-statements and --max-insert-rows must not be negative")
+	}
+
+	if cfg.DownTo != "" && cfg.Command != CommandDown && cfg.Command != CommandBaseline {
+		return nil, fmt.Errorf("--to is only valid with the down and baseline commands")
+	}
+
+	if cfg.HistoryLimit < 0 {
+		return nil, fmt.Errorf("--limit must not be negative")
+	}
+	if cfg.HistoryLimit > 0 && cfg.Command != CommandHistory {
+		return nil, fmt.Errorf("--limit is only valid with the history command")
+	}
+
+	if cfg.Source != SourceGit && cfg.Source != SourceWorktree && cfg.Source != SourceFilesystem {
+		return nil, fmt.Errorf("--source must be %s, %s or %s", SourceGit, SourceWorktree, SourceFilesystem)
+	}
+	if cfg.Source != SourceGit && cfg.Ref != "" {
+		return nil, fmt.Errorf("--ref reads scripts from git; it is not valid with --source %s", cfg.Source)
+	}
+	if cfg.ScriptsRepo == "" && cfg.ScriptsPath != "" {
+		return nil, fmt.Errorf("--scripts-path is only valid with --scripts-repo")
+	}
+	if strings.HasPrefix(cfg.Ref, "-") {
+		return nil, fmt.Errorf("invalid --ref %s", cfg.Ref)
+	}
+	if cfg.ScriptsPath != "" && !filepath.IsLocal(cfg.ScriptsPath) {
+		return nil, fmt.Errorf("--scripts-path must be a relative path within the repository")
+	}
+	if err := validateNamespaces(cfg); err != nil {
+		return nil, err
+	}
+
+	// Validate scripts directory exists; artifacts are pulled and verified, and remote
+	// repositories cloned, before use
+	if cfg.ScriptsRepo != "" {
+		if cfg.VerifyKey != "" {
+			return nil, fmt.Errorf("--verify-key is only valid with an %s scripts_dir", OCIScheme)
+		}
+	} else if strings.HasPrefix(cfg.ScriptsDir, OCIScheme) {
+		if cfg.VerifyKey == "" {
+			return nil, fmt.Errorf("an %s scripts_dir requires --verify-key <cosign.pub>", OCIScheme)
+		}
+	} else if cfg.VerifyKey != "" {
+		return nil, fmt.Errorf("--verify-key is only valid with an %s scripts_dir", OCIScheme)
+	} else if _, err := os.Stat(cfg.ScriptsDir); os.IsNotExist(err) {
 		return nil, fmt.Errorf("scripts directory does not exist: %s", cfg.ScriptsDir)
 	}
 
+	// serve and watch apply like up
+	appliesUp := cfg.Command == CommandUp || cfg.Command == CommandServe || cfg.Command == CommandWatch
+
+	if cfg.LeastPrivilege && !appliesUp {
+		return nil, fmt.Errorf("--least-privilege is only valid with the up, serve and watch commands")
+	}
+
+	if (len(cfg.Tags) > 0 || len(cfg.SkipTags) > 0) && !appliesUp {
+		return nil, fmt.Errorf("--tags and --skip-tags are only valid with the up, serve and watch commands")
+	}
+
+	if cfg.Command == CommandExport {
+		if cfg.ExportFormat != ExportFlyway && cfg.ExportFormat != ExportLiquibase {
+			return nil, fmt.Errorf("export requires --format %s or %s", ExportFlyway, ExportLiquibase)
+		}
+		if cfg.ExportOutput == "" {
+			return nil, fmt.Errorf("export requires --output <file>")
+		}
+	} else if cfg.Command == CommandPlan {
+		if cfg.ExportFormat == "" {
+			cfg.ExportFormat = PlanText
+		}
+		if cfg.ExportFormat != PlanText && cfg.ExportFormat != PlanTFJSON && cfg.ExportFormat != PlanSQL {
+			return nil, fmt.Errorf("plan supports --format %s, %s or %s", PlanText, PlanTFJSON, PlanSQL)
+		}
+	} else if cfg.ExportFormat != "" {
+		return nil, fmt.Errorf("--format is only valid with the export and plan commands")
+	}
+
+	if cfg.Command == CommandDocs {
+		if cfg.ExportOutput == "" {
+			return nil, fmt.Errorf("docs requires --output <file>")
+		}
+	} else if cfg.ExportOutput != "" && cfg.Command != CommandExport && cfg.Command != CommandRollout {
+		return nil, fmt.Errorf("--output is only valid with the export, docs and rollout commands")
+	}
+
+	if cfg.Command == CommandDiff || cfg.Command == CommandCheck || cfg.Command == CommandDrift {
+		if cfg.DiffFrom != "" && cfg.DiffFromDB != "" {
+			return nil, fmt.Errorf("--from and --from-db cannot be combined")
+		}
+		if cfg.Command == CommandDiff && cfg.DiffFrom == "" && cfg.DiffFromDB == "" {
+			return nil, fmt.Errorf("diff requires either --from <schema.sql> or --from-db <dbname>")
+		}
+		if cfg.Command == CommandDrift && cfg.DiffFrom == "" && cfg.DiffFromDB == "" && cfg.SnapshotDir == "" {
+			return nil, fmt.Errorf("drift requires --schema-snapshot <dir>, --from <schema.sql> or --from-db <dbname>")
+		}
+		if cfg.DiffFrom != "" {
+			if _, err := os.Stat(cfg.DiffFrom); os.IsNotExist(err) {
+				return nil, fmt.Errorf("schema file does not exist: %s", cfg.DiffFrom)
+			}
+		}
+	} else if cfg.DiffFrom != "" || cfg.DiffFromDB != "" {
+		return nil, fmt.Errorf("--from and --from-db are only valid with the diff, check and drift commands")
+	}
+
+	if (cfg.SARIFFile != "" || cfg.JUnitFile != "" || cfg.ManifestFile != "") && !appliesUp {
+		return nil, fmt.Errorf("--sarif, --report-junit and --manifest are only valid with the up, serve and watch commands")
+	}
+	if cfg.Pushgateway != "" || cfg.MetricsListen != "" {
+		if !appliesUp {
+			return nil, fmt.Errorf("--pushgateway and --metrics-listen are only valid with the up, serve and watch commands")
+		}
+		if cfg.TargetsFile != "" {
+			return nil, fmt.Errorf("--pushgateway and --metrics-listen cannot be used with --targets")
+		}
+		if u, err := url.Parse(cfg.Pushgateway); cfg.Pushgateway != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			return nil, fmt.Errorf("--pushgateway must be an http:// or https:// URL")
+		}
+	}
+	if cfg.MetricsLinger < 0 {
+		return nil, fmt.Errorf("--metrics-linger must not be negative")
+	}
+	if cfg.NotifyWebhook != "" || cfg.NotifySlack != "" {
+		if !appliesUp {
+			return nil, fmt.Errorf("--notify-webhook and --notify-slack are only valid with the up, serve and watch commands")
+		}
+		for _, target := range [][2]string{{"--notify-webhook", cfg.NotifyWebhook}, {"--notify-slack", cfg.NotifySlack}} {
+			if u, err := url.Parse(target[1]); target[1] != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+				return nil, fmt.Errorf("%s must be an http:// or https:// URL", target[0])
+			}
+		}
+	}
+	if cfg.AuditLog != "" && !appliesUp {
+		return nil, fmt.Errorf("--audit-log is only valid with the up, serve and watch commands")
+	}
+	if cfg.AuditKey != "" && cfg.AuditLog == "" {
+		return nil, fmt.Errorf("--audit-key requires --audit-log")
+	}
+
+	if cfg.TicketCommentURL != "" {
+		if !appliesUp {
+			return nil, fmt.Errorf("--ticket-comment-url is only valid with the up, serve and watch commands")
+		}
+		if cfg.TicketPattern == nil {
+			return nil, fmt.Errorf("--ticket-comment-url requires --ticket-pattern")
+		}
+	}
+
+	if cfg.K8s {
+		if cfg.Command != CommandUp {
+			return nil, fmt.Errorf("--k8s is only valid with the up command")
+		}
+		// Deferred scripts would keep the schema from ever being current
+		if len(cfg.Tags) > 0 || len(cfg.SkipTags) > 0 {
+			return nil, fmt.Errorf("--k8s cannot be used with --tags or --skip-tags")
+		}
+	}
+	if cfg.WaitForDB < 0 {
+		return nil, fmt.Errorf("--wait-for-db must not be negative")
+	}
+	if cfg.LockTimeout < 0 {
+		return nil, fmt.Errorf("--lock-timeout must not be negative")
+	}
+	if cfg.StatementTimeout < 0 {
+		return nil, fmt.Errorf("--statement-timeout must not be negative")
+	}
+	if cfg.Retries < 0 || cfg.RetryDelay < 0 {
+		return nil, fmt.Errorf("--retries and --retry-delay must not be negative")
+	}
+	if cfg.SlowThreshold < 0 {
+		return nil, fmt.Errorf("--slow-threshold must not be negative")
+	}
+
+	if cfg.WindowSpec != "" {
+		if cfg.Command != CommandUp {
+			return nil, fmt.Errorf("--window is only valid with the up command")
+		}
+		if cfg.Window, err = window.Parse(cfg.WindowSpec); err != nil {
+			return nil, err
+		}
+		// Held back scripts would keep the schema from being current, or stay behind in a fan-out
+		if len(cfg.WindowTags) > 0 && (cfg.K8s || cfg.TargetsFile != "") {
+			return nil, fmt.Errorf("--window-tags cannot be used with --k8s or --targets")
+		}
+	} else if cfg.WaitForWindow || len(cfg.WindowTags) > 0 {
+		return nil, fmt.Errorf("--wait and --window-tags require --window")
+	}
+
+	if cfg.TargetsFile != "" {
+		if err := applyTargets(cfg); err != nil {
+			return nil, err
+		}
+	} else if cfg.Command == CommandRollout {
+		return nil, fmt.Errorf("rollout requires --targets <file>, listing the targets in rollout order")
+	} else if cfg.Parallel != 1 || cfg.ContinueOnError {
+		return nil, fmt.Errorf("--parallel and --continue-on-error require --targets")
+	}
+	if cfg.LogFormat != LogText && cfg.LogFormat != LogJSON {
+		return nil, fmt.Errorf("unknown log format %q (expected %s or %s)", cfg.LogFormat, LogText, LogJSON)
+	}
+	if cfg.LogOutput != LogStdout && cfg.LogOutput != LogStderr {
+		return nil, fmt.Errorf("unknown log output %q (expected %s or %s)", cfg.LogOutput, LogStdout, LogStderr)
+	}
+	if cfg.K8s {
+		cfg.LogFormat = LogJSON
+	}
+	if cfg.ThenExec != "" && (cfg.Command != CommandUp || cfg.TargetsFile != "") {
+		return nil, fmt.Errorf("--then-exec is only valid with the up command, without --targets")
+	}
+	if cfg.DetailedExitCode && (cfg.Command != CommandUp || cfg.TargetsFile != "" || cfg.ThenExec != "") {
+		return nil, fmt.Errorf("--detailed-exitcode is only valid with the up command, without --targets and --then-exec")
+	}
+	if cfg.DryRun {
+		if cfg.Command != CommandUp && cfg.Command != CommandRepair {
+			return nil, fmt.Errorf("--dry-run is only valid with the up and repair commands; see plan for the other checks")
+		}
+		// Both would go on as if the schema were current
+		if cfg.K8s || cfg.ThenExec != "" {
+			return nil, fmt.Errorf("--dry-run cannot be used with --k8s or --then-exec")
+		}
+	}
+
+	if err := validateApproval(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.AutoRevert && cfg.Command != CommandRollout {
+		return nil, fmt.Errorf("--auto-revert is only valid with the rollout command")
+	}
+
+	if cfg.Reseed && cfg.Command != CommandSeed {
+		return nil, fmt.Errorf("--reseed is only valid with the seed command")
+	}
+
+	// Validate seed directory exists if provided
+	if cfg.SeedDir != "" {
+		if _, err := os.Stat(cfg.SeedDir); os.IsNotExist(err) {
+			return nil, fmt.Errorf("seed directory does not exist: %s", cfg.SeedDir)
+		}
+	} else if cfg.Command == CommandSeed {
+		return nil, fmt.Errorf("the seed command requires --seed-dir")
+	}
+
 	// Validate missed scripts file exists if provided
 	if cfg.MissedScriptsFile != "" {
 		if _, err := os.Stat(cfg.MissedScriptsFile); os.IsNotExist(err) {
@@ -57,9 +1130,509 @@ func ParseArgs(args []string) (*Config, error) {
 	return cfg, nil
 }
 
-// DSN returns the MySQL Data Source Name connection string
+// parseBundleArgs validates "bundle push <ref> <scripts_dir>"
+func parseBundleArgs(cfg *Config, positional []string) (*Config, error) {
+	if len(positional) != 3 || positional[0] != "push" {
+		return nil, fmt.Errorf("usage: db-migration bundle push [--sign-key <cosign.key>] <ref> <scripts_dir>")
+	}
+	if cfg.VerifyKey != "" {
+		return nil, fmt.Errorf("--verify-key is only valid with an %s scripts_dir", OCIScheme)
+	}
+	if cfg.ScriptsRepo != "" || cfg.Ref != "" || len(cfg.Namespaces) > 0 {
+		return nil, fmt.Errorf("bundle push packs the HEAD of a local checkout; --scripts-repo, --scripts-dir, --scripts-dirs and --ref are not valid")
+	}
+
+	cfg.BundleRef = strings.TrimPrefix(positional[1], OCIScheme)
+	cfg.ScriptsDir = positional[2]
+	if _, err := os.Stat(cfg.ScriptsDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("scripts directory does not exist: %s", cfg.ScriptsDir)
+	}
+	return cfg, nil
+}
+
+// varsFromEnv collects placeholder values from DB_MIGRATION_VAR_* environment variables
+func varsFromEnv(environ []string) map[string]string {
+	vars := make(map[string]string)
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(key, VarEnvPrefix) && len(key) > len(VarEnvPrefix) {
+			vars[strings.TrimPrefix(key, VarEnvPrefix)] = value
+		}
+	}
+	return vars
+}
+
+// varFlag is a repeatable key=value flag that writes into a map
+type varFlag map[string]string
+
+func (v varFlag) String() string {
+	return ""
+}
+
+func (v varFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	v[key] = value
+	return nil
+}
+
+// listFlag is a comma-separated, repeatable list flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// sizeFlag is a byte count with an optional KB, MB or GB suffix
+type sizeFlag int64
+
+func (f *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*f), 10)
+}
+
+func (f *sizeFlag) Set(s string) error {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a size such as 512KB or 2MB, got %q", s)
+	}
+	*f = sizeFlag(n * multiplier)
+	return nil
+}
+
+// readPasswordFile returns the first line of a password file
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	password, _, _ := strings.Cut(string(data), "\n")
+	password = strings.TrimSuffix(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("password file %s is empty", path)
+	}
+	return password, nil
+}
+
+// ResolveSecrets replaces secret references (e.g. aws-sm://prod/db#password) in the
+// connection arguments, placeholder values and ticket credentials with resolved values
+// Values that are not references are passed through resolve unchanged
+func (c *Config) ResolveSecrets(resolve func(string) (string, error)) error {
+	fields := map[string]*string{
+		"host":                 &c.Host,
+		"user":                 &c.User,
+		"password":             &c.Password,
+		"dbname":               &c.DBName,
+		"--tracker-password":   &c.TrackerPassword,
+		TicketAuthEnv:          &c.TicketAuth,
+		"--ticket-comment-url": &c.TicketCommentURL,
+		"--approval-secret":    &c.ApprovalSecret,
+		APITokenEnv:            &c.APIToken,
+	}
+	for name, field := range fields {
+		value, err := resolve(*field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*field = value
+	}
+	for name, value := range c.Vars {
+		resolved, err := resolve(value)
+		if err != nil {
+			return fmt.Errorf("placeholder %s: %w", name, err)
+		}
+		c.Vars[name] = resolved
+	}
+	return nil
+}
+
+// patternFlag is a regular expression, compiled when the flag is parsed
+type patternFlag struct {
+	re **regexp.Regexp
+}
+
+func (f patternFlag) String() string {
+	if f.re == nil || *f.re == nil {
+		return ""
+	}
+	return (*f.re).String()
+}
+
+func (f patternFlag) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+	*f.re = re
+	return nil
+}
+
+// isCommand reports whether arg names a CLI command
+func isCommand(arg string) bool {
+	switch arg {
+	case CommandUp, CommandDown, CommandSeed, CommandRerun, CommandImport, CommandExport, CommandDiff, CommandDocs, CommandBundle, CommandPlan, CommandLogin, CommandAudit, CommandServe, CommandWatch, CommandCheck, CommandRollout, CommandStatus, CommandValidate, CommandHistory, CommandBaseline, CommandRepair, CommandMarkApplied, CommandDrift:
+		return true
+	}
+	return false
+}
+
+// parseInterspersed parses flags that may appear before, between, or after positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// ConnectionAttributes returns the attributes every connection of the run sends:
+// program_name, the command, the batch ID, the CI job URL when there is one, and --conn-attr
+func (c *Config) ConnectionAttributes(batchID string) map[string]string {
+	attrs := map[string]string{
+		"program_name":       "db-migration",
+		"db_migration_cmd":   c.Command,
+		"db_migration_batch": batchID,
+	}
+	if c.Environment != "" {
+		attrs["db_migration_env"] = c.Environment
+	}
+	if url := ciJobURL(os.Getenv); url != "" {
+		attrs["ci_job_url"] = url
+	}
+	for key, value := range c.ConnAttrs {
+		attrs[key] = value
+	}
+	return attrs
+}
+
+// ciJobURL returns the URL of the CI job running the migration, for the common CI systems
+func ciJobURL(getenv func(string) string) string {
+	switch {
+	case getenv("GITHUB_RUN_ID") != "":
+		return getenv("GITHUB_SERVER_URL") + "/" + getenv("GITHUB_REPOSITORY") + "/actions/runs/" + getenv("GITHUB_RUN_ID")
+	case getenv("CI_JOB_URL") != "": // GitLab
+		return getenv("CI_JOB_URL")
+	case getenv("BUILD_URL") != "": // Jenkins
+		return getenv("BUILD_URL")
+	case getenv("CIRCLE_BUILD_URL") != "":
+		return getenv("CIRCLE_BUILD_URL")
+	case getenv("BUILDKITE_BUILD_URL") != "":
+		return getenv("BUILDKITE_BUILD_URL")
+	case getenv("BUILD_BUILDID") != "" && getenv("SYSTEM_COLLECTIONURI") != "": // Azure Pipelines
+		return getenv("SYSTEM_COLLECTIONURI") + getenv("SYSTEM_TEAMPROJECT") + "/_build/results?buildId=" + getenv("BUILD_BUILDID")
+	}
+	return ""
+}
+
+// applyTargets loads the --targets file and checks the flags that cannot be shared by targets
+func applyTargets(cfg *Config) error {
+	if cfg.Command != CommandUp && cfg.Command != CommandRollout {
+		return fmt.Errorf("--targets is only valid with the up and rollout commands")
+	}
+	// A rollout stops at the first target that fails
+	if cfg.Command == CommandRollout && (cfg.Parallel != 1 || cfg.ContinueOnError) {
+		return fmt.Errorf("rollout applies targets one at a time; --parallel and --continue-on-error do not apply")
+	}
+	if cfg.Target != nil || cfg.K8s {
+		return fmt.Errorf("--targets cannot be used with --k8s or a cloudsql:// or rds:// host")
+	}
+	// These files would be overwritten by every target
+	if cfg.SARIFFile != "" || cfg.JUnitFile != "" || cfg.ManifestFile != "" || cfg.SnapshotDir != "" || cfg.DocsFile != "" {
+		return fmt.Errorf("--targets cannot be used with --sarif, --report-junit, --manifest, --schema-snapshot or --docs")
+	}
+	if cfg.Parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	targets, err := LoadTargets(cfg.TargetsFile)
+	if err != nil {
+		return err
+	}
+	cfg.Targets = targets
+	return nil
+}
+
+// validateApproval checks the approval flags against the command
+func validateApproval(cfg *Config) error {
+	if cfg.ApprovalWebhook == "" {
+		if cfg.ApprovalSecret != "" || cfg.ApprovalURL != "" || cfg.ApprovalListen != "" || len(cfg.ApprovalTargets) > 0 || cfg.ApprovalTimeout != DefaultApprovalTimeout {
+			return fmt.Errorf("--approval-secret, --approval-timeout, --approval-url, --approval-listen and --approval-targets require --approval-webhook")
+		}
+		return nil
+	}
+	if cfg.Command != CommandServe && cfg.Command != CommandRollout {
+		return fmt.Errorf("--approval-webhook is only valid with the serve and rollout commands")
+	}
+	if cfg.ApprovalSecret == "" {
+		return fmt.Errorf("--approval-webhook requires --approval-secret to verify approval tokens")
+	}
+	if cfg.ApprovalTimeout <= 0 {
+		return fmt.Errorf("--approval-timeout must be positive")
+	}
+
+	if cfg.Command == CommandServe {
+		if cfg.Listen == "" {
+			return fmt.Errorf("--approval-webhook receives approvals on the HTTP API; serve it with --listen")
+		}
+		if cfg.ApprovalListen != "" || len(cfg.ApprovalTargets) > 0 {
+			return fmt.Errorf("--approval-listen and --approval-targets are only valid with the rollout command")
+		}
+		return nil
+	}
+	if cfg.ApprovalListen == "" {
+		return fmt.Errorf("rollout with --approval-webhook requires --approval-listen to receive approvals on")
+	}
+	for _, name := range cfg.ApprovalTargets {
+		if !slices.ContainsFunc(cfg.Targets, func(t Target) bool { return t.Name == name }) {
+			return fmt.Errorf("--approval-targets: no target named %s", name)
+		}
+	}
+	return nil
+}
+
+// NeedsApproval reports whether a rollout asks for approval before applying to target
+func (c *Config) NeedsApproval(target Target) bool {
+	return c.ApprovalWebhook != "" && (len(c.ApprovalTargets) == 0 || slices.Contains(c.ApprovalTargets, target.Name))
+}
+
+// applyTLSPolicy checks the TLS flags against the connection and fills in the SSL mode
+func applyTLSPolicy(cfg *Config) error {
+	if cfg.Target != nil && cfg.Target.Scheme == connector.CloudSQLScheme {
+		if cfg.SSLMode != "" || cfg.SSLCA != "" || cfg.TLSMinVersion != "" {
+			return fmt.Errorf("cloudsql:// connections use the connector's TLS; --ssl-mode, --ssl-ca and --tls-min-version do not apply")
+		}
+		if cfg.FIPS && !fips140.Enabled() {
+			return fmt.Errorf("--fips with a cloudsql:// host requires a FIPS 140 build (GOFIPS140)")
+		}
+		return nil
+	}
+	if cfg.SSLMode == "" && (cfg.FIPS || cfg.SSLCA != "" || cfg.TLSMinVersion != "") {
+		cfg.SSLMode = db.SSLVerifyIdentity
+	}
+	tokenAuth := cfg.AzureAD || (cfg.Target != nil && cfg.Target.IAM)
+	if tokenAuth && (cfg.SSLMode == db.SSLDisabled || cfg.SSLMode == db.SSLPreferred) {
+		return fmt.Errorf("access tokens are sent as cleartext passwords and require TLS; use --ssl-mode required or stricter")
+	}
+	if cfg.SSLMode != "" {
+		// Reports unsupported values before anything connects
+		if _, err := cfg.TLSPolicy().Option(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDriver checks the flags against --driver. The connectors, tunnels and schema
+// introspection are built on the MySQL driver and server, so PostgreSQL, SQL Server and
+// SQLite have none of them; a SQLite file has no users or TLS either
+func applyDriver(cfg *Config) error {
+	switch cfg.Driver {
+	case "mssql", db.DriverMSSQL:
+		cfg.Driver = db.DriverMSSQL
+	case "sqlite", db.DriverSQLite:
+		cfg.Driver = db.DriverSQLite
+	case db.DriverMySQL, db.DriverPostgres:
+	default:
+		return fmt.Errorf("unsupported --driver %q (expected %s, %s, mssql or sqlite)", cfg.Driver, db.DriverMySQL, db.DriverPostgres)
+	}
+	if cfg.DataSourceName != "" && cfg.Driver != db.DriverSQLite {
+		return fmt.Errorf("--dsn is only supported with --driver sqlite")
+	}
+	if cfg.Driver == db.DriverMySQL {
+		return nil
+	}
+
+	switch cfg.Command {
+	case CommandDiff, CommandDocs, CommandImport, CommandDrift:
+		return fmt.Errorf("%s is only supported with --driver %s", cfg.Command, db.DriverMySQL)
+	}
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"a cloudsql:// or rds:// host", cfg.Target != nil},
+		{"--azure-ad", cfg.AzureAD},
+		{"--ssh-host", cfg.SSHHost != ""},
+		{"--proxy (or $ALL_PROXY)", cfg.Proxy != ""},
+		{"--session-init", cfg.SessionInit != ""},
+		{"--conn-attr", len(cfg.ConnAttrs) > 0},
+		{"--least-privilege", cfg.LeastPrivilege},
+		{"--schema-snapshot", cfg.SnapshotDir != ""},
+		{"--docs", cfg.DocsFile != ""},
+		{"--from and --from-db", cfg.DiffFrom != "" || cfg.DiffFromDB != ""},
+	} {
+		if option.set {
+			return fmt.Errorf("%s is only supported with --driver %s", option.name, db.DriverMySQL)
+		}
+	}
+
+	if cfg.Driver == db.DriverSQLite {
+		for _, option := range []struct {
+			name string
+			set  bool
+		}{
+			{"--ssl-mode, --ssl-ca, --tls-min-version and --fips", cfg.SSLMode != ""},
+			{"--tracker-user", cfg.TrackerUser != ""},
+			{"--vault-path", cfg.VaultPath != ""},
+			{"--targets", cfg.TargetsFile != ""},
+		} {
+			if option.set {
+				return fmt.Errorf("%s cannot be used with --driver sqlite", option.name)
+			}
+		}
+		return nil
+	}
+	if cfg.Driver == db.DriverMSSQL {
+		_, err := cfg.TLSPolicy().MSSQLParams()
+		return err
+	}
+	_, err := cfg.TLSPolicy().PostgresParams()
+	return err
+}
+
+// TLSPolicy returns the TLS flags as a db.TLSPolicy
+func (c *Config) TLSPolicy() db.TLSPolicy {
+	return db.TLSPolicy{Mode: c.SSLMode, CAFile: c.SSLCA, MinVersion: c.TLSMinVersion, FIPS: c.FIPS}
+}
+
+// applyTarget checks the other arguments against a connector <host>
+func applyTarget(cfg *Config) error {
+	t := cfg.Target
+	if t.Database != "" {
+		if cfg.DBName != "-" && cfg.DBName != t.Database {
+			return fmt.Errorf("<host> names database %s but <dbname> is %s; pass - as <dbname>", t.Database, cfg.DBName)
+		}
+		cfg.DBName = t.Database
+	}
+	if t.Scheme == connector.CloudSQLScheme && cfg.SSHHost != "" {
+		return fmt.Errorf("--ssh-host cannot be used with a cloudsql:// host")
+	}
+	if t.IAM {
+		if cfg.VaultPath != "" {
+			return fmt.Errorf("--vault-path cannot be used with IAM database authentication")
+		}
+		// The password is a token generated per connection
+		if cfg.Password != "" && cfg.Password != "-" {
+			return fmt.Errorf("IAM database authentication replaces <password>; pass -")
+		}
+		cfg.Password = ""
+	}
+	return nil
+}
+
+// DSN returns the Data Source Name connection string of the --driver
 func (c *Config) DSN() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&multiStatements=true",
-		c.User, c.Password, c.Host, c.Port, c.DBName)
+	return c.dsn(c.User, c.Password)
 }
 
+// TrackerDSN returns the connection string of --tracker-user
+func (c *Config) TrackerDSN() string {
+	return c.dsn(c.TrackerUser, c.TrackerPassword)
+}
+
+func (c *Config) dsn(user, password string) string {
+	switch c.Driver {
+	case db.DriverPostgres:
+		return c.postgresDSN(user, password)
+	case db.DriverMSSQL:
+		return c.mssqlDSN(user, password)
+	case db.DriverSQLite:
+		return sqliteDSN(c.DataSourceName)
+	}
+	network := "tcp"
+	if c.SSHHost != "" {
+		network = TunnelNetwork
+	} else if c.Proxy != "" {
+		network = ProxyNetwork
+	}
+	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
+	params := "parseTime=true&multiStatements=true"
+	if c.Target != nil {
+		switch c.Target.Scheme {
+		case connector.CloudSQLScheme:
+			// The connector handles TLS and picks the instance IP
+			network, addr = connector.CloudSQLNetwork, c.Target.Addr
+		case connector.RDSScheme:
+			// IAM tokens are sent as cleartext passwords, so TLS is required
+			addr = fmt.Sprintf("%s:%d", c.Target.Addr, c.Port)
+			params += "&tls=true&allowCleartextPasswords=true"
+		}
+	}
+	if c.AzureAD {
+		params += "&tls=true&allowCleartextPasswords=true"
+	}
+	return fmt.Sprintf("%s:%s@%s(%s)/%s?%s", user, password, network, addr, c.DBName, params)
+}
+
+// mssqlDSN returns a sqlserver:// URL; its TLS parameters were checked by applyDriver
+func (c *Config) mssqlDSN(user, password string) string {
+	params, err := c.TLSPolicy().MSSQLParams()
+	if err != nil {
+		params = url.Values{}
+	}
+	params.Set("database", c.DBName)
+	params.Set("app name", "db-migration")
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// sqliteDSN waits up to 5s for a lock held by another connection, e.g. an editor's,
+// unless the --dsn sets a busy timeout of its own
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_busy_timeout") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_busy_timeout=5000"
+	}
+	return dsn + "?_busy_timeout=5000"
+}
+
+// postgresDSN returns a postgres:// URL; its TLS parameters were checked by applyDriver
+func (c *Config) postgresDSN(user, password string) string {
+	params, err := c.TLSPolicy().PostgresParams()
+	if err != nil {
+		params = url.Values{}
+	}
+	params.Set("application_name", "db-migration")
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.DBName,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables that configure db-migration, e.g. in a
+// container: every flag not given on the command line is read from its variable, as
+// DB_MIGRATION_WAIT_FOR_DB=5m for --wait-for-db 5m. The environment wins over --config
+const EnvPrefix = "DB_MIGRATION_"
+
+// Environment variables read in place of the connection arguments when none are given
+const (
+	HostEnv       = EnvPrefix + "HOST"
+	UserEnv       = EnvPrefix + "USER"
+	PasswordEnv   = EnvPrefix + "PASSWORD"
+	DBNameEnv     = EnvPrefix + "DBNAME"
+	PortEnv       = EnvPrefix + "PORT" // Defaults to 3306
+	ScriptsDirEnv = EnvPrefix + "SCRIPTS_DIR"
+)
+
+// FlagEnv returns the environment variable of a flag, e.g. DB_MIGRATION_SSL_MODE for ssl-mode
+func FlagEnv(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyFlagEnv sets the flags not given on the command line from their environment
+// variables. --var is left out, since placeholders have variables of their own, and so
+// is --scripts-dir, since DB_MIGRATION_SCRIPTS_DIR is the <scripts_dir> argument
+func applyFlagEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] || f.Name == "var" || f.Name == "scripts-dir" {
+			return
+		}
+		name := FlagEnv(f.Name)
+		if value, ok := lookup(name); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// connectionFromEnv returns the connection arguments from the environment, in command
+// line order, or nil when DB_MIGRATION_HOST is not set. DB_MIGRATION_SCRIPTS_DIR is only
+// read with scriptsDir, since a --scripts-repo replaces it
+func connectionFromEnv(lookup func(string) (string, bool), scriptsDir bool) ([]string, error) {
+	if _, ok := lookup(HostEnv); !ok {
+		return nil, nil
+	}
+	names := []string{HostEnv, UserEnv, PasswordEnv, DBNameEnv, PortEnv, ScriptsDirEnv}
+	if !scriptsDir {
+		names = names[:len(names)-1]
+	}
+	var args []string
+	for _, name := range names {
+		value, ok := lookup(name)
+		switch {
+		case ok:
+		case name == PortEnv:
+			value = "3306"
+		case name == PasswordEnv:
+			// Empty is taken as -, e.g. with --vault-path
+			value = ""
+		default:
+			return nil, fmt.Errorf("%s is set, but %s is not", HostEnv, name)
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
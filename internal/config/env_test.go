@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseArgs_Env(t *testing.T) {
+	scriptsDir := t.TempDir()
+	t.Setenv(HostEnv, "db.internal")
+	t.Setenv(UserEnv, "migrator")
+	t.Setenv(DBNameEnv, "app")
+	t.Setenv(ScriptsDirEnv, scriptsDir)
+	t.Setenv(FlagEnv("wait-for-db"), "5m")
+	t.Setenv(FlagEnv("log-format"), "json")
+	t.Setenv(FlagEnv("then-exec"), "/app/server --port 8080")
+	t.Setenv(FlagEnv("env"), "staging")
+
+	cfg, err := ParseArgs([]string{"--env", "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "db.internal" || cfg.User != "migrator" || cfg.Password != "" || cfg.DBName != "app" || cfg.Port != 3306 || cfg.ScriptsDir != scriptsDir {
+		t.Errorf("expected the connection from the environment, got %+v", cfg)
+	}
+	if cfg.WaitForDB != 5*time.Minute || cfg.LogFormat != LogJSON || cfg.ThenExec != "/app/server --port 8080" {
+		t.Errorf("expected flags from the environment, got %+v", cfg)
+	}
+	if cfg.Environment != "prod" {
+		t.Errorf("expected the command line to win over the environment, got %s", cfg.Environment)
+	}
+
+	// Connection arguments on the command line replace all of the environment's
+	cfg, err = ParseArgs([]string{"localhost", "root", "pw", "dev", "3307", scriptsDir})
+	if err != nil || cfg.Host != "localhost" || cfg.Port != 3307 {
+		t.Errorf("expected the command line connection, got %+v %v", cfg, err)
+	}
+
+	// A - password keeps the secret out of the arguments
+	t.Setenv(PasswordEnv, "s3cret")
+	cfg, err = ParseArgs([]string{"localhost", "root", "-", "dev", "3307", scriptsDir})
+	if err != nil || cfg.Password != "s3cret" {
+		t.Errorf("expected the password from the environment, got %q %v", cfg.Password, err)
+	}
+
+	t.Setenv(FlagEnv("wait-for-db"), "soon")
+	if _, err := ParseArgs(nil); err == nil {
+		t.Error("expected an invalid DB_MIGRATION_WAIT_FOR_DB to be rejected")
+	}
+	t.Setenv(FlagEnv("wait-for-db"), "0s")
+	if _, err := ParseArgs([]string{"down"}); err == nil {
+		t.Error("expected --then-exec to be rejected with down")
+	}
+}
+
+func TestConnectionFromEnv(t *testing.T) {
+	env := map[string]string{HostEnv: "db.internal", UserEnv: "migrator"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	if _, err := connectionFromEnv(lookup, true); err == nil {
+		t.Error("expected a missing DB_MIGRATION_DBNAME to be rejected")
+	}
+	delete(env, HostEnv)
+	if args, err := connectionFromEnv(lookup, true); args != nil || err != nil {
+		t.Errorf("expected nothing without DB_MIGRATION_HOST, got %v %v", args, err)
+	}
+}
+
+func TestParseArgs_PasswordFile(t *testing.T) {
+	scriptsDir := t.TempDir()
+	passwordFile := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(passwordFile, []byte("s3cret\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PasswordEnv, "from-env")
+
+	cfg, err := ParseArgs([]string{"--password-file", passwordFile, "db.internal", "migrator", "-", "app", "3306", scriptsDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "s3cret" {
+		t.Errorf("expected the password from the file, got %q", cfg.Password)
+	}
+
+	cfg, err = ParseArgs([]string{"--password-stdin", "db.internal", "migrator", "-", "app", "3306", scriptsDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "-" {
+		t.Errorf("expected the password to be left for stdin, got %q", cfg.Password)
+	}
+
+	if _, err := ParseArgs([]string{"--password-file", passwordFile, "db.internal", "migrator", "secret", "app", "3306", scriptsDir}); err == nil {
+		t.Error("expected a password next to --password-file to be rejected")
+	}
+	if _, err := ParseArgs([]string{"--password-file", passwordFile, "--password-stdin", "db.internal", "migrator", "-", "app", "3306", scriptsDir}); err == nil {
+		t.Error("expected --password-file with --password-stdin to be rejected")
+	}
+}
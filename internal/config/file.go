@@ -0,0 +1,164 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// connectionKeys are the keys of a --config file holding the connection arguments
+var connectionKeys = []string{"host", "user", "password", "dbname", "port", "scripts-dir"}
+
+// configFile holds the values of a --config file by the environment variable of their
+// key, so it can stand in for the environment: ssl-mode is DB_MIGRATION_SSL_MODE
+type configFile map[string][]string
+
+// loadConfigFile reads a YAML (.yaml, .yml) or TOML (.toml) file whose keys are the
+// connection arguments and flag names, e.g.
+//
+//	host: db.internal
+//	scripts-dir: ./migrations
+//	ssl-mode: verify-identity
+//	var:
+//	  SCHEMA_PREFIX: app_
+func loadConfigFile(path string, fs *flag.FlagSet) (configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s (expected .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	file := make(configFile)
+	for key, value := range raw {
+		if key == "config" || (fs.Lookup(key) == nil && !slices.Contains(connectionKeys, key)) {
+			return nil, fmt.Errorf("unknown key %q in config file %s", key, path)
+		}
+		values, err := configValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in config file %s: %w", key, path, err)
+		}
+		file[FlagEnv(key)] = values
+	}
+	return file, nil
+}
+
+// configValues turns a value of a config file into flag values: a list sets a
+// repeatable flag once per item, and a map once per key=value pair
+func configValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case []any:
+		var values []string
+		for _, item := range v {
+			s, err := configScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var values []string
+		for _, k := range keys {
+			s, err := configScalar(v[k])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, k+"="+s)
+		}
+		return values, nil
+	}
+	s, err := configScalar(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+// configScalar formats a string, number or boolean of a config file
+func configScalar(value any) (string, error) {
+	switch value.(type) {
+	case []any, map[string]any, nil:
+		return "", fmt.Errorf("expected a string, number or boolean")
+	}
+	return fmt.Sprint(value), nil
+}
+
+// lookup returns the first value of an environment variable's key
+func (f configFile) lookup(name string) (string, bool) {
+	values, ok := f[name]
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// overEnv returns a lookup of the environment that falls back to the file
+func (f configFile) overEnv(lookupEnv func(string) (string, bool)) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		if value, ok := lookupEnv(name); ok {
+			return value, true
+		}
+		return f.lookup(name)
+	}
+}
+
+// applyConfigFile sets the flags given neither on the command line nor in the
+// environment from the file. Placeholders are merged by key, so the file only adds
+// the ones --var and DB_MIGRATION_VAR_* do not define. The scripts-dir key is the
+// <scripts_dir> argument, not --scripts-dir
+func applyConfigFile(fs *flag.FlagSet, file configFile, vars map[string]string, lookupEnv func(string) (string, bool)) error {
+	for _, kv := range file[FlagEnv("var")] {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid var in config file: expected key=value, got %q", kv)
+		}
+		if _, set := vars[key]; !set {
+			vars[key] = value
+		}
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] || f.Name == "var" || f.Name == "scripts-dir" {
+			return
+		}
+		name := FlagEnv(f.Name)
+		if _, ok := lookupEnv(name); ok {
+			return
+		}
+		for _, value := range file[name] {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("invalid %s in config file: %w", f.Name, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseArgs_ConfigFile(t *testing.T) {
+	scriptsDir := t.TempDir()
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "db-migration.yaml")
+	if err := os.WriteFile(yamlFile, []byte(`host: db.internal
+user: migrator
+password: secret
+dbname: app
+port: 3307
+scripts-dir: `+scriptsDir+`
+env: staging
+strict-vars: true
+tags: [core, billing]
+var:
+  SCHEMA_PREFIX: app_
+  REGION: eu
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(FlagEnv("env"), "prod")
+	t.Setenv(VarEnvPrefix+"REGION", "us")
+	t.Setenv(PasswordEnv, "from-env")
+
+	cfg, err := ParseArgs([]string{"--config", yamlFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "db.internal" || cfg.User != "migrator" || cfg.Password != "from-env" || cfg.DBName != "app" || cfg.Port != 3307 || cfg.ScriptsDir != scriptsDir {
+		t.Errorf("expected the connection from the config file and the password from the environment, got %+v", cfg)
+	}
+	if !cfg.StrictVars || len(cfg.Tags) != 2 || cfg.Vars["SCHEMA_PREFIX"] != "app_" {
+		t.Errorf("expected flags from the config file, got %+v", cfg)
+	}
+	if cfg.Environment != "prod" || cfg.Vars["REGION"] != "us" {
+		t.Errorf("expected the environment to win over the config file, got %s and %s", cfg.Environment, cfg.Vars["REGION"])
+	}
+
+	cfg, err = ParseArgs([]string{"--config", yamlFile, "--env", "dev", "localhost", "root", "pw", "devdb", "3306", scriptsDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Environment != "dev" || cfg.Host != "localhost" {
+		t.Errorf("expected the command line to win over the config file, got %+v", cfg)
+	}
+
+	tomlFile := filepath.Join(dir, "db-migration.toml")
+	if err := os.WriteFile(tomlFile, []byte("host = \"db.internal\"\nuser = \"migrator\"\ndbname = \"app\"\nscripts-dir = \""+filepath.ToSlash(scriptsDir)+"\"\nwait-for-db = \"5m\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = ParseArgs([]string{"--config", tomlFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "db.internal" || cfg.Port != 3306 || cfg.WaitForDB.String() != "5m0s" {
+		t.Errorf("expected the connection from the TOML file, got %+v", cfg)
+	}
+
+	if err := os.WriteFile(yamlFile, []byte("ssl_mode: required\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseArgs([]string{"--config", yamlFile}); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+}
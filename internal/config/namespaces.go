@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Namespace is one scripts directory of a monorepo, e.g. a service's migrations. Its
+// records in the tracking table are kept apart from those of the other directories
+type Namespace struct {
+	Name string
+	Dir  string
+}
+
+// namespacePattern matches namespace names; they fit the namespace column
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+// parseNamespace parses a --scripts-dir value as [namespace=]dir. Without a namespace,
+// the directory's base name is taken
+func parseNamespace(s string) (Namespace, error) {
+	if name, dir, ok := strings.Cut(s, "="); ok && namespacePattern.MatchString(name) {
+		return Namespace{Name: name, Dir: dir}, nil
+	}
+	if s == "" {
+		return Namespace{}, fmt.Errorf("expected [namespace=]dir")
+	}
+	return Namespace{Name: filepath.Base(s), Dir: s}, nil
+}
+
+// LoadNamespaces reads a --scripts-dirs file: one scripts directory per line as
+// [namespace] dir, with # comments, in the order they are migrated. Relative
+// directories are taken from the file's directory
+func LoadNamespaces(path string) ([]Namespace, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scripts dirs file: %w", err)
+	}
+	defer file.Close()
+
+	var namespaces []Namespace
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var ns Namespace
+		switch fields := strings.Fields(line); len(fields) {
+		case 1:
+			ns = Namespace{Name: filepath.Base(fields[0]), Dir: fields[0]}
+		case 2:
+			ns = Namespace{Name: fields[0], Dir: fields[1]}
+		default:
+			return nil, fmt.Errorf("%s:%d: expected [namespace] dir", path, lineNo)
+		}
+		if !filepath.IsAbs(ns.Dir) {
+			ns.Dir = filepath.Join(filepath.Dir(path), ns.Dir)
+		}
+		namespaces = append(namespaces, ns)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read scripts dirs file: %w", err)
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("no scripts directories in %s", path)
+	}
+	return namespaces, nil
+}
+
+// validateNamespaces checks the scripts directories of --scripts-dir or --scripts-dirs.
+// Several of them are migrated one after another by up, and listed by status
+func validateNamespaces(cfg *Config) error {
+	if len(cfg.Namespaces) == 0 {
+		return nil
+	}
+	if cfg.ScriptsRepo != "" {
+		return fmt.Errorf("--scripts-dir and --scripts-dirs cannot be used with --scripts-repo")
+	}
+	seen := make(map[string]bool)
+	for _, ns := range cfg.Namespaces {
+		if !namespacePattern.MatchString(ns.Name) {
+			return fmt.Errorf("invalid namespace %q of %s: use up to 100 letters, digits, '_', '.' and '-'", ns.Name, ns.Dir)
+		}
+		if seen[ns.Name] {
+			return fmt.Errorf("namespace %s is given twice; name the directories with namespace=dir", ns.Name)
+		}
+		seen[ns.Name] = true
+		if strings.HasPrefix(ns.Dir, OCIScheme) {
+			return fmt.Errorf("--scripts-dir takes local directories, not %s", ns.Dir)
+		}
+		if info, err := os.Stat(ns.Dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("scripts directory does not exist: %s", ns.Dir)
+		}
+	}
+	if len(cfg.Namespaces) == 1 {
+		return nil
+	}
+
+	if cfg.Command != CommandUp && cfg.Command != CommandStatus {
+		return fmt.Errorf("several scripts directories are only supported by the up and status commands")
+	}
+	if cfg.MissedScriptsFile != "" || cfg.TargetsFile != "" || cfg.K8s {
+		return fmt.Errorf("several scripts directories cannot be used with a missed scripts file, --targets or --k8s")
+	}
+	// These files would be overwritten by every directory
+	if cfg.SARIFFile != "" || cfg.JUnitFile != "" || cfg.ManifestFile != "" || cfg.SnapshotDir != "" || cfg.DocsFile != "" {
+		return fmt.Errorf("several scripts directories cannot be used with --sarif, --report-junit, --manifest, --schema-snapshot or --docs")
+	}
+	return nil
+}
+
+// ForNamespace returns a copy of the configuration migrating the scripts directory of ns
+func (c *Config) ForNamespace(ns Namespace) *Config {
+	nc := *c
+	nc.ScriptsDir, nc.Namespace = ns.Dir, ns.Name
+	return &nc
+}
+
+// namespaceFlag is the repeatable --scripts-dir flag
+type namespaceFlag struct {
+	namespaces *[]Namespace
+}
+
+func (f namespaceFlag) String() string {
+	if f.namespaces == nil {
+		return ""
+	}
+	var values []string
+	for _, ns := range *f.namespaces {
+		values = append(values, ns.Name+"="+ns.Dir)
+	}
+	return strings.Join(values, ",")
+}
+
+func (f namespaceFlag) Set(s string) error {
+	ns, err := parseNamespace(s)
+	if err != nil {
+		return err
+	}
+	*f.namespaces = append(*f.namespaces, ns)
+	return nil
+}
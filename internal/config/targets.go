@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Target is one database of a --targets file, e.g. a shard or a tenant's database
+type Target struct {
+	Name     string
+	Host     string
+	Port     int // 0 uses <port>
+	DBName   string
+	User     string // "" uses <user>
+	Password string // "" uses <password>
+}
+
+// LoadTargets reads a targets file: one target per line as [name] [user[:password]@]host[:port]/dbname,
+// with # comments. Without a name, a target is named host/dbname
+func LoadTargets(path string) ([]Target, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open targets file: %w", err)
+	}
+	defer file.Close()
+
+	var targets []Target
+	names := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target, err := parseTarget(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if prev, ok := names[target.Name]; ok {
+			return nil, fmt.Errorf("%s:%d: target %s is already defined on line %d", path, lineNo, target.Name, prev)
+		}
+		names[target.Name] = lineNo
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read targets file: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets in %s", path)
+	}
+	return targets, nil
+}
+
+func parseTarget(fields []string) (Target, error) {
+	var t Target
+	switch len(fields) {
+	case 1:
+	case 2:
+		t.Name, fields = fields[0], fields[1:]
+	default:
+		return t, fmt.Errorf("expected [name] [user[:password]@]host[:port]/dbname")
+	}
+
+	u, err := url.Parse("mysql://" + strings.TrimPrefix(fields[0], "mysql://"))
+	if err != nil {
+		return t, fmt.Errorf("invalid target %q", fields[0])
+	}
+	t.Host, t.DBName = u.Hostname(), strings.TrimPrefix(u.Path, "/")
+	if t.Host == "" || t.DBName == "" || strings.Contains(t.DBName, "/") {
+		return t, fmt.Errorf("invalid target %q: expected host[:port]/dbname", fields[0])
+	}
+	if port := u.Port(); port != "" {
+		if t.Port, err = strconv.Atoi(port); err != nil {
+			return t, fmt.Errorf("invalid port in target %q", fields[0])
+		}
+	}
+	if u.User != nil {
+		t.User = u.User.Username()
+		t.Password, _ = u.User.Password()
+	}
+	if t.Name == "" {
+		t.Name = t.Host + "/" + t.DBName
+	}
+	return t, nil
+}
+
+// ForTarget returns a copy of the configuration connecting to target; the target's name
+// is the tenant of templates unless --tenant is given
+func (c *Config) ForTarget(target Target) *Config {
+	tc := *c
+	tc.Host, tc.DBName = target.Host, target.DBName
+	if target.Port != 0 {
+		tc.Port = target.Port
+	}
+	if target.User != "" {
+		tc.User, tc.Password = target.User, target.Password
+	}
+	if tc.Tenant == "" {
+		tc.Tenant = target.Name
+	}
+	tc.TargetsFile, tc.Targets = "", nil
+	return &tc
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	os.WriteFile(path, []byte(`# Shards
+shard1 db1.internal/app
+shard2 migrator:s3cret@db2.internal:3307/app
+
+db3.internal/tenant_c
+`), 0644)
+
+	targets, err := LoadTargets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("expected 3 targets, got %+v", targets)
+	}
+	if got := targets[1]; got != (Target{Name: "shard2", Host: "db2.internal", Port: 3307, DBName: "app", User: "migrator", Password: "s3cret"}) {
+		t.Errorf("unexpected target %+v", got)
+	}
+	if targets[2].Name != "db3.internal/tenant_c" {
+		t.Errorf("expected unnamed targets to be named host/dbname, got %s", targets[2].Name)
+	}
+
+	cfg := (&Config{Host: "-", DBName: "-", Port: 3306, User: "root", Password: "pw"}).ForTarget(targets[0])
+	if cfg.Host != "db1.internal" || cfg.Port != 3306 || cfg.User != "root" || cfg.Tenant != "shard1" {
+		t.Errorf("expected shard1 with the default port and credentials, got %+v", cfg)
+	}
+
+	for _, content := range []string{"a db1/app\na db2/app\n", "shard1 db1.internal\n", "a b c\n", "# empty\n"} {
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := LoadTargets(path); err == nil {
+			t.Errorf("expected %q to be rejected, got %v", content, err)
+		}
+	}
+}
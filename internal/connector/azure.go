@@ -0,0 +1,47 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/go-sql-driver/mysql"
+)
+
+// azureMySQLScope is the Entra ID scope of Azure Database for MySQL tokens
+const azureMySQLScope = "https://ossrdbms-aad.database.windows.net/.default"
+
+// NewAzureCredential returns the default Azure credential chain: client credentials
+// from AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_CLIENT_SECRET (or a certificate),
+// workload identity, managed identity, then the Azure CLI login
+func NewAzureCredential() (azcore.TokenCredential, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+	}
+	return cred, nil
+}
+
+// AzureADAuth returns a mysql driver option that authenticates each new connection
+// with an Entra ID access token; the credential caches tokens and refreshes them
+// before they expire
+func AzureADAuth(cred azcore.TokenCredential) mysql.Option {
+	return mysql.BeforeConnect(func(ctx context.Context, cfg *mysql.Config) error {
+		token, err := azureToken(ctx, cred)
+		if err != nil {
+			return err
+		}
+		cfg.Passwd = token
+		return nil
+	})
+}
+
+func azureToken(ctx context.Context, cred azcore.TokenCredential) (string, error) {
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureMySQLScope}})
+	if err != nil {
+		return "", fmt.Errorf("failed to get an Entra ID token: %w", err)
+	}
+	return token.Token, nil
+}
@@ -0,0 +1,59 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/go-sql-driver/mysql"
+)
+
+// CloudSQLNetwork is the mysql driver network that dials through the Cloud SQL connector
+const CloudSQLNetwork = "cloudsql"
+
+// CloudSQL dials a Cloud SQL instance through the Cloud SQL Go connector, which requests
+// the ephemeral client certificate, renews it before it expires and verifies the server
+type CloudSQL struct {
+	target *Target
+	dialer *cloudsqlconn.Dialer
+}
+
+// NewCloudSQL creates the connector of a cloudsql:// target with Application Default
+// Credentials; opts adjust the dialer, e.g. cloudsqlconn.WithDialFunc for a --proxy
+func NewCloudSQL(ctx context.Context, target *Target, opts ...cloudsqlconn.Option) (*CloudSQL, error) {
+	// A run opens few connections, so the certificate is refreshed when a dial needs it
+	// rather than by a background goroutine
+	opts = append([]cloudsqlconn.Option{cloudsqlconn.WithLazyRefresh()}, opts...)
+	if target.PrivateIP {
+		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithPrivateIP()))
+	}
+	if target.IAM {
+		// The token is embedded in the certificate and replaces the password
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
+	}
+	dialer, err := cloudsqlconn.NewDialer(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Cloud SQL dialer: %w", err)
+	}
+	return &CloudSQL{target: target, dialer: dialer}, nil
+}
+
+// Register makes the mysql driver dial DSNs with this network name through the connector
+func (c *CloudSQL) Register(network string) {
+	mysql.RegisterDialContext(network, c.DialContext)
+}
+
+// DialContext connects to the instance; addr is the connection name in the DSN and ignored
+func (c *CloudSQL) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := c.dialer.Dial(ctx, c.target.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.target.Addr, err)
+	}
+	return conn, nil
+}
+
+// Close stops the dialer; open connections are not affected
+func (c *CloudSQL) Close() error {
+	return c.dialer.Close()
+}
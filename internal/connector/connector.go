@@ -0,0 +1,104 @@
+// Package connector connects to managed MySQL without sidecar proxies. The <host>
+// argument selects a connector by scheme:
+//
+//	cloudsql://<project>:<region>:<instance>[/<db>][?ip=private][&iam=true]   Google Cloud SQL
+//	rds://<endpoint>[/<db>][?region=<region>]                                  AWS RDS (Proxy) with IAM
+//
+// Cloud SQL connections go through the Cloud SQL Go connector with Application Default
+// Credentials; RDS connections use IAM auth tokens from the AWS CLI login.
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// Connector schemes of the <host> argument
+const (
+	CloudSQLScheme = "cloudsql"
+	RDSScheme      = "rds"
+)
+
+// Target is a <host> that selects a connector
+type Target struct {
+	Scheme    string // CloudSQLScheme or RDSScheme
+	Addr      string // project:region:instance, or the RDS (Proxy) endpoint
+	Database  string // Optional database name from the path
+	IAM       bool   // IAM database authentication instead of a password (always on for rds)
+	PrivateIP bool   // Connect to the private IP of a Cloud SQL instance
+	Region    string // AWS region of an RDS endpoint
+}
+
+// Parse returns the Target a host names, or nil when it has no connector scheme
+func Parse(host string) (*Target, error) {
+	scheme, rest, ok := strings.Cut(host, "://")
+	if !ok || (scheme != CloudSQLScheme && scheme != RDSScheme) {
+		return nil, nil
+	}
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	addr, database, _ := strings.Cut(rest, "/")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", host, err)
+	}
+	t := &Target{Scheme: scheme, Addr: addr, Database: database}
+
+	switch scheme {
+	case CloudSQLScheme:
+		if parts := strings.Split(addr, ":"); len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("expected cloudsql://<project>:<region>:<instance>[/<db>], got %s", host)
+		}
+		for key, values := range query {
+			switch {
+			case key == "ip" && (values[0] == "public" || values[0] == "private"):
+				t.PrivateIP = values[0] == "private"
+			case key == "iam" && (values[0] == "true" || values[0] == "false"):
+				t.IAM = values[0] == "true"
+			default:
+				return nil, fmt.Errorf("unsupported cloudsql:// option %s=%s (expected ip=public|private or iam=true)", key, values[0])
+			}
+		}
+	case RDSScheme:
+		if addr == "" || strings.Contains(addr, ":") {
+			return nil, fmt.Errorf("expected rds://<endpoint>[/<db>] with the port in <port>, got %s", host)
+		}
+		for key := range query {
+			if key != "region" {
+				return nil, fmt.Errorf("unsupported rds:// option %s (expected region)", key)
+			}
+		}
+		t.IAM = true
+		t.Region = query.Get("region")
+		if t.Region == "" {
+			t.Region = endpointRegion(addr)
+		}
+	}
+	return t, nil
+}
+
+// endpointRegion returns the region of an endpoint such as
+// app.proxy-abc123.eu-west-1.rds.amazonaws.com, or "" for the CLI's default region
+func endpointRegion(endpoint string) string {
+	labels := strings.Split(endpoint, ".")
+	for i := 1; i < len(labels); i++ {
+		if labels[i] == "rds" {
+			return labels[i-1]
+		}
+	}
+	return ""
+}
+
+// runCommand runs a CLI and returns its trimmed output; replaced in tests
+var runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s failed: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,231 @@
+package connector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"golang.org/x/oauth2"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    *Target
+		wantErr bool
+	}{
+		{"localhost", nil, false},
+		{"aws-sm://prod/db#host", nil, false},
+		{"cloudsql://proj:europe-west1:main/app", &Target{Scheme: CloudSQLScheme, Addr: "proj:europe-west1:main", Database: "app"}, false},
+		{"cloudsql://proj:europe-west1:main?ip=private&iam=true", &Target{Scheme: CloudSQLScheme, Addr: "proj:europe-west1:main", IAM: true, PrivateIP: true}, false},
+		{"cloudsql://proj:main", nil, true},
+		{"cloudsql://proj:europe-west1:main?ip=psc", nil, true},
+		{"rds://app.proxy-abc.eu-west-1.rds.amazonaws.com/app", &Target{Scheme: RDSScheme, Addr: "app.proxy-abc.eu-west-1.rds.amazonaws.com", Database: "app", IAM: true, Region: "eu-west-1"}, false},
+		{"rds://db.internal?region=us-east-2", &Target{Scheme: RDSScheme, Addr: "db.internal", IAM: true, Region: "us-east-2"}, false},
+		{"rds://db.internal:3306", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.host)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestRDSTokens(t *testing.T) {
+	var calls []string
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		return "token-" + args[len(args)-3], nil
+	}
+	tokens := &rdsTokens{region: "eu-west-1", tokens: make(map[string]rdsToken)}
+	for i := 0; i < 2; i++ {
+		token, err := tokens.get(context.Background(), "migrator", "app.proxy-abc.eu-west-1.rds.amazonaws.com:3306")
+		if err != nil || token != "token-migrator" {
+			t.Fatalf("get = %q, %v", token, err)
+		}
+	}
+	want := "aws rds generate-db-auth-token --hostname app.proxy-abc.eu-west-1.rds.amazonaws.com --port 3306 --username migrator --region eu-west-1"
+	if len(calls) != 1 || calls[0] != want {
+		t.Errorf("expected one token to be generated with %q, got %q", want, calls)
+	}
+}
+
+// fakeCredential returns a token for the MySQL scope only
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(opts.Scopes) != 1 || opts.Scopes[0] != "https://ossrdbms-aad.database.windows.net/.default" {
+		return azcore.AccessToken{}, io.EOF
+	}
+	return azcore.AccessToken{Token: "eyJ0eXAi", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureToken(t *testing.T) {
+	token, err := azureToken(context.Background(), fakeCredential{})
+	if err != nil || token != "eyJ0eXAi" {
+		t.Errorf("expected the Azure Database for MySQL token, got %q, %v", token, err)
+	}
+}
+
+// newCA creates a self-signed CA and a function issuing certificates from it
+func newCA(t *testing.T) (*x509.Certificate, func(cn string, pub interface{}, usage x509.ExtKeyUsage) []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Google Cloud SQL Server CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+	serial := int64(1)
+	issue := func(cn string, pub interface{}, usage x509.ExtKeyUsage) []byte {
+		serial++
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}, ca, pub, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	return ca, issue
+}
+
+func TestCloudSQLDial(t *testing.T) {
+	ca, issue := newCA(t)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+
+	// The instance accepts TLS connections with client certificates from its CA and echoes
+	serverKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{issue("proj:main", &serverKey.PublicKey, x509.ExtKeyUsageServerAuth)}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(conn, conn); conn.Close() }()
+		}
+	}()
+
+	var ephemeralCalls int
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/sql/v1beta4/projects/proj/instances/main/connectSettings":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"region":          "europe-west1",
+				"backendType":     "SECOND_GEN",
+				"databaseVersion": "MYSQL_8_0",
+				"serverCaCert":    map[string]string{"cert": caPEM},
+				"ipAddresses":     []map[string]string{{"type": "PRIMARY", "ipAddress": "127.0.0.1"}},
+			})
+		case "/sql/v1beta4/projects/proj/instances/main:generateEphemeralCert":
+			ephemeralCalls++
+			var body struct {
+				PublicKey string `json:"public_key"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			block, _ := pem.Decode([]byte(body.PublicKey))
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			cert := issue("client", pub, x509.ExtKeyUsageClientAuth)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"ephemeralCert": map[string]string{"cert": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}))},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer admin.Close()
+
+	// Connections to port 3307 of the instance IP reach the listener instead
+	opts := []cloudsqlconn.Option{
+		cloudsqlconn.WithAdminAPIEndpoint(admin.URL + "/"),
+		cloudsqlconn.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "ya29.token"})),
+		cloudsqlconn.WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, listener.Addr().String())
+		}),
+	}
+	target, _ := Parse("cloudsql://proj:europe-west1:main/app")
+	c, err := NewCloudSQL(context.Background(), target, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		conn, err := c.DialContext(context.Background(), target.Addr)
+		if err != nil {
+			t.Fatalf("DialContext failed: %v", err)
+		}
+		conn.Write([]byte("ping"))
+		reply := make([]byte, 4)
+		if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+			t.Errorf("expected the echo over TLS, got %q (err: %v)", reply, err)
+		}
+		conn.Close()
+	}
+	if ephemeralCalls != 1 {
+		t.Errorf("expected the certificate to be reused until it expires, got %d requests", ephemeralCalls)
+	}
+
+	// A region that does not match the instance is refused
+	wrong, _ := Parse("cloudsql://proj:us-central1:main")
+	c, err = NewCloudSQL(context.Background(), wrong, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.DialContext(context.Background(), wrong.Addr); err == nil || !strings.Contains(err.Error(), "region was mismatched") {
+		t.Errorf("expected a region mismatch error, got %v", err)
+	}
+}
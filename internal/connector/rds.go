@@ -0,0 +1,64 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tokenLifetime is how long an RDS auth token is reused; tokens are valid for 15 minutes
+const tokenLifetime = 10 * time.Minute
+
+// RDSAuth returns a mysql driver option that authenticates each new connection with
+// an IAM auth token from the AWS CLI, generated for the connection's user and endpoint
+func RDSAuth(region string) mysql.Option {
+	tokens := &rdsTokens{region: region, tokens: make(map[string]rdsToken)}
+	return mysql.BeforeConnect(func(ctx context.Context, cfg *mysql.Config) error {
+		token, err := tokens.get(ctx, cfg.User, cfg.Addr)
+		if err != nil {
+			return err
+		}
+		cfg.Passwd = token
+		return nil
+	})
+}
+
+type rdsToken struct {
+	value     string
+	generated time.Time
+}
+
+// rdsTokens caches a token per user and endpoint
+type rdsTokens struct {
+	region string
+	mu     sync.Mutex
+	tokens map[string]rdsToken
+}
+
+func (r *rdsTokens) get(ctx context.Context, user, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid RDS address %s: %w", addr, err)
+	}
+	key := user + "@" + addr
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[key]; ok && time.Since(token.generated) < tokenLifetime {
+		return token.value, nil
+	}
+	args := []string{"rds", "generate-db-auth-token", "--hostname", host, "--port", port, "--username", user}
+	if r.region != "" {
+		args = append(args, "--region", r.region)
+	}
+	value, err := runCommand(ctx, "aws", args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate an RDS auth token: %w", err)
+	}
+	r.tokens[key] = rdsToken{value: value, generated: time.Now()}
+	return value, nil
+}
@@ -1,8 +1,13 @@
 package console
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,93 +24,232 @@ const (
 	Bold    = "\033[1m"
 )
 
-// Console provides colored output with logging
+// LevelTrace is below slog.LevelDebug: every git command and SQL statement, for -vv
+const LevelTrace = slog.LevelDebug - 4
+
+// Console logs the progress of a command through log/slog, rendered as colored text
+// for terminals or as JSON lines
 type Console struct {
-	verbose bool
+	logger *slog.Logger
+	level  slog.LevelVar
+	quiet  bool      // Only errors, summaries, tables and reports, see SetQuiet
+	out    io.Writer // Everything but errors
+	errOut io.Writer // Errors of the text format, stderr unless redirected
+	file   io.Writer // Every message too, without colors, see SetLogFile (optional)
+	json   bool      // One JSON object per message, errors included, instead of colored text
+	now    func() time.Time
+	mu     sync.Mutex // Serializes writes of the text format
+}
+
+// New creates a new Console writing colored text to stdout at the info level
+func New() *Console {
+	c := &Console{out: os.Stdout, errOut: os.Stderr, now: time.Now}
+	c.rebuild()
+	return c
+}
+
+// SetOutput redirects non-error output, e.g. to stderr when stdout carries machine-readable output
+func (c *Console) SetOutput(w io.Writer) {
+	c.out = w
+	c.rebuild()
+}
+
+// SetErrorOutput redirects errors, e.g. to capture a transcript in tests
+func (c *Console) SetErrorOutput(w io.Writer) {
+	c.errOut = w
+	c.rebuild()
+}
+
+// SetClock replaces the clock of message timestamps, e.g. with testkit.FakeClock
+func (c *Console) SetClock(clock interface{ Now() time.Time }) {
+	c.now = clock.Now
+	c.rebuild()
+}
+
+// SetJSON switches to JSON lines on the output, e.g. for Kubernetes log collectors
+func (c *Console) SetJSON() {
+	c.json = true
+	c.rebuild()
+}
+
+// SetLevel hides messages below level, e.g. slog.LevelWarn; debug messages are
+// hidden by default
+func (c *Console) SetLevel(level slog.Level) {
+	c.level.Set(level)
+}
+
+// SetQuiet hides everything but errors and a command's results: the summary of a run,
+// tables and reports. A log file still receives every message
+func (c *Console) SetQuiet() {
+	c.quiet = true
+	c.rebuild()
+}
+
+// SetLogFile writes every message to w as well, at the same level: plain text without
+// colors, errors included, or JSON lines with SetJSON
+func (c *Console) SetLogFile(w io.Writer) {
+	c.file = w
+	c.rebuild()
+}
+
+// Logger returns a slog logger writing through the console, for packages logging with
+// slog. It follows later changes of the console's output and format
+func (c *Console) Logger() *slog.Logger {
+	return slog.New(consoleHandler{c})
+}
+
+// consoleHandler hands records to the current handler of a console
+type consoleHandler struct {
+	c *Console
+}
+
+func (h consoleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.c.logger.Handler().Enabled(ctx, level)
+}
+
+func (h consoleHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.c.logger.Handler().Handle(ctx, r)
 }
 
-// New creates a new Console instance
-func New(verbose bool) *Console {
-	return &Console{verbose: verbose}
+func (h consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.c.logger.Handler().WithAttrs(attrs)
 }
 
-// timestamp returns current timestamp string
-func timestamp() string {
-	return time.Now().Format("2006-01-02 15:04:05")
+func (h consoleHandler) WithGroup(name string) slog.Handler {
+	return h.c.logger.Handler().WithGroup(name)
+}
+
+// rebuild replaces the handler after a change of its output, clock or format
+func (c *Console) rebuild() {
+	handler := c.handler(c.out, c.errOut)
+	if c.quiet {
+		handler = quietHandler{handler}
+	}
+	if c.file != nil {
+		plain := plainWriter{c.file}
+		handler = teeHandler{handler, c.handler(plain, plain)}
+	}
+	c.logger = slog.New(handler)
+}
+
+// handler returns the handler of the console's format writing to out and errOut
+func (c *Console) handler(out, errOut io.Writer) slog.Handler {
+	if c.json {
+		return slog.NewJSONHandler(out, &slog.HandlerOptions{Level: &c.level, ReplaceAttr: c.replaceJSONAttr})
+	}
+	return &textHandler{out: out, errOut: errOut, level: &c.level, now: c.now, mu: &c.mu}
+}
+
+// replaceJSONAttr stamps records with the console's clock and drops the rendering hint
+func (c *Console) replaceJSONAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		a.Value = slog.TimeValue(c.now())
+	case slog.LevelKey:
+		if a.Value.Any() == LevelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	case kindKey:
+		return slog.Attr{}
+	}
+	return a
+}
+
+// log writes one record of kind, which tells the text handler how to render it
+func (c *Console) log(level slog.Level, kind, msg string, attrs ...interface{}) {
+	c.logger.Log(context.Background(), level, msg, append([]interface{}{kindKey, kind}, attrs...)...)
+}
+
+// Debug prints a message only shown with --log-level debug
+func (c *Console) Debug(format string, args ...interface{}) {
+	c.log(slog.LevelDebug, kindPlain, fmt.Sprintf(format, args...))
 }
 
 // Success prints a success message in green
 func (c *Console) Success(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("%s[%s]%s %s✓%s %s\n", Cyan, timestamp(), Reset, Green, Reset, msg)
+	c.log(slog.LevelInfo, kindSuccess, fmt.Sprintf(format, args...), "status", "success")
 }
 
 // Failure prints a failure message in red
 func (c *Console) Failure(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("%s[%s]%s %s✗%s %s\n", Cyan, timestamp(), Reset, Red, Reset, msg)
+	c.log(slog.LevelError, kindFailure, fmt.Sprintf(format, args...), "status", "failed")
 }
 
 // Info prints an info message in blue
 func (c *Console) Info(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("%s[%s]%s %sℹ%s %s\n", Cyan, timestamp(), Reset, Blue, Reset, msg)
+	c.log(slog.LevelInfo, kindPlain, fmt.Sprintf(format, args...))
 }
 
 // Warn prints a warning message in yellow
 func (c *Console) Warn(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("%s[%s]%s %s⚠%s %s\n", Cyan, timestamp(), Reset, Yellow, Reset, msg)
+	c.log(slog.LevelWarn, kindPlain, fmt.Sprintf(format, args...))
 }
 
 // Error prints an error message in red and bold
 func (c *Console) Error(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	fmt.Fprintf(os.Stderr, "%s[%s]%s %s%s✗ ERROR:%s %s\n", Cyan, timestamp(), Reset, Bold, Red, Reset, msg)
+	c.log(slog.LevelError, kindPlain, fmt.Sprintf(format, args...))
 }
 
 // Header prints a section header
 func (c *Console) Header(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("\n%s%s═══ %s ═══%s\n\n", Bold, Cyan, msg, Reset)
+	c.log(slog.LevelInfo, kindHeader, fmt.Sprintf(format, args...), "section", true)
 }
 
 // Script prints script execution info
 func (c *Console) Script(name string, status string) {
-	var statusColor string
-	var symbol string
-
-	switch status {
-	case "executing":
-		statusColor = Yellow
-		symbol = "▶"
-	case "success":
-		statusColor = Green
-		symbol = "✓"
-	case "failed":
-		statusColor = Red
-		symbol = "✗"
-	case "skipped":
-		statusColor = Blue
-		symbol = "○"
-	default:
-		statusColor = White
-		symbol = "•"
+	c.log(slog.LevelInfo, kindScript, "script "+status, "script", name, "status", status)
+}
+
+// Table prints rows under column headers, aligned; in JSON mode each row is one
+// message with the lower-cased columns as attributes
+func (c *Console) Table(columns []string, rows [][]string) {
+	if !c.json {
+		c.log(slog.LevelInfo, kindTable, "table", "table", table{columns: columns, rows: rows})
+		return
+	}
+	for _, row := range rows {
+		attrs := make([]interface{}, 0, 2*len(columns))
+		for i, column := range columns {
+			attrs = append(attrs, strings.ToLower(column), row[i])
+		}
+		c.log(slog.LevelInfo, kindRow, "row", attrs...)
+	}
+}
+
+// ScriptDone prints the outcome of a script like Script; in JSON mode it adds the
+// duration and the error, if any
+func (c *Console) ScriptDone(name, status string, duration time.Duration, err error) {
+	if !c.json {
+		c.Script(name, status)
+		return
+	}
+	attrs := []interface{}{"script", name, "status", status, "duration_ms", duration.Milliseconds()}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
 	}
+	c.log(slog.LevelInfo, kindScript, "script "+status, attrs...)
+}
+
+// Report writes a whole report, e.g. of a run, as the report attribute of one JSON
+// line. Text output has summaries for that, so it prints nothing
+func (c *Console) Report(msg string, report interface{}) {
+	c.log(slog.LevelInfo, kindReport, msg, "report", report)
+}
 
-	fmt.Printf("%s[%s]%s %s%s%s %s\n", Cyan, timestamp(), Reset, statusColor, symbol, Reset, name)
+// SlowScript is a script of the summary that ran longer than it should on production
+type SlowScript struct {
+	Script     string `json:"script"`
+	DurationMS int64  `json:"duration_ms"`
 }
 
-// Summary prints final execution summary
-func (c *Console) Summary(total, success, failed, skipped int) {
-	c.Header("Migration Summary")
-	fmt.Printf("  Total scripts:   %s%d%s\n", Bold, total, Reset)
-	fmt.Printf("  Successful:      %s%s%d%s\n", Green, Bold, success, Reset)
-	if failed > 0 {
-		fmt.Printf("  Failed:          %s%s%d%s\n", Red, Bold, failed, Reset)
-	} else {
-		fmt.Printf("  Failed:          %d\n", failed)
+// Summary prints final execution summary, with the slow scripts, if any
+func (c *Console) Summary(total, success, failed, skipped int, slow []SlowScript) {
+	attrs := []interface{}{"total", total, "success", success, "failed", failed, "skipped", skipped}
+	if len(slow) > 0 {
+		attrs = append(attrs, "slow", slow)
 	}
-	fmt.Printf("  Skipped:         %s%d%s\n", Blue, skipped, Reset)
-	fmt.Println()
+	c.log(slog.LevelInfo, kindSummary, "migration summary", attrs...)
 }
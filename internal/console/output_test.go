@@ -0,0 +1,132 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+func TestConsole_Levels(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c := New()
+	c.SetOutput(&out)
+	c.SetErrorOutput(&errOut)
+	c.SetClock(fixedClock{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+	c.Debug("hidden")
+	c.Info("shown")
+	c.SetLevel(slog.LevelDebug)
+	c.Debug("details")
+	c.SetLevel(slog.LevelWarn)
+	c.Info("quiet")
+	c.Script("001_users.sql", "success")
+	c.Warn("careful")
+	c.Error("broken")
+
+	want := "\x1b[36m[2024-01-01 00:00:00]\x1b[0m \x1b[34mℹ\x1b[0m shown\n" +
+		"\x1b[36m[2024-01-01 00:00:00]\x1b[0m \x1b[37m·\x1b[0m details\n" +
+		"\x1b[36m[2024-01-01 00:00:00]\x1b[0m \x1b[33m⚠\x1b[0m careful\n"
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%q\nwant\n%q", out.String(), want)
+	}
+	if !strings.Contains(errOut.String(), "ERROR:") || !strings.HasSuffix(errOut.String(), " broken\n") {
+		t.Errorf("expected the error on the error output, got %q", errOut.String())
+	}
+}
+
+func TestConsole_JSON(t *testing.T) {
+	var out bytes.Buffer
+	c := New()
+	c.SetOutput(&out)
+	c.SetClock(fixedClock{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	c.SetJSON()
+
+	c.Debug("hidden")
+	c.Script("001_users.sql", "executing")
+	c.Table([]string{"SCRIPT", "STATUS"}, [][]string{{"001_users.sql", "applied"}})
+	c.Report("run report", map[string]string{"status": "success"})
+	c.Error("broken")
+
+	want := `{"time":"2024-01-01T00:00:00Z","level":"INFO","msg":"script executing","script":"001_users.sql","status":"executing"}
+{"time":"2024-01-01T00:00:00Z","level":"INFO","msg":"row","script":"001_users.sql","status":"applied"}
+{"time":"2024-01-01T00:00:00Z","level":"INFO","msg":"run report","report":{"status":"success"}}
+{"time":"2024-01-01T00:00:00Z","level":"ERROR","msg":"broken"}
+`
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestConsole_Quiet(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c := New()
+	c.SetOutput(&out)
+	c.SetErrorOutput(&errOut)
+	c.SetClock(fixedClock{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	c.SetQuiet()
+
+	c.Header("DB Migration Started")
+	c.Info("Found 2 new scripts to execute")
+	c.Script("001_users.sql", "success")
+	c.Warn("careful")
+	c.Summary(2, 1, 1, 0, nil)
+	c.Error("broken")
+
+	if !strings.Contains(out.String(), "Migration Summary") || strings.Contains(out.String(), "Started") ||
+		strings.Contains(out.String(), "001_users.sql") || strings.Contains(out.String(), "careful") {
+		t.Errorf("expected only the summary, got %q", out.String())
+	}
+	if !strings.HasSuffix(errOut.String(), " broken\n") {
+		t.Errorf("expected the error on the error output, got %q", errOut.String())
+	}
+}
+
+func TestConsole_Trace(t *testing.T) {
+	var out bytes.Buffer
+	c := New()
+	c.SetClock(fixedClock{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	logger := c.Logger()
+	c.SetOutput(&out)
+	c.SetJSON()
+
+	logger.Log(context.Background(), LevelTrace, "git rev-parse HEAD")
+	c.SetLevel(LevelTrace)
+	logger.Log(context.Background(), LevelTrace, "SQL: SELECT 1")
+
+	want := `{"time":"2024-01-01T00:00:00Z","level":"TRACE","msg":"SQL: SELECT 1"}` + "\n"
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestConsole_LogFile(t *testing.T) {
+	var out, errOut, file bytes.Buffer
+	c := New()
+	c.SetOutput(&out)
+	c.SetErrorOutput(&errOut)
+	c.SetClock(fixedClock{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	c.SetLogFile(&file)
+	c.SetQuiet()
+
+	c.Debug("hidden")
+	c.Info("Found 1 new scripts to execute")
+	c.Script("001_users.sql", "failed")
+	c.Error("Script execution failed: Error 1064 (42000): You have an error in your SQL syntax\nnear 'TABLEE users' at line 1")
+
+	want := "[2024-01-01 00:00:00] ℹ Found 1 new scripts to execute\n" +
+		"[2024-01-01 00:00:00] ✗ 001_users.sql\n" +
+		"[2024-01-01 00:00:00] ✗ ERROR: Script execution failed: Error 1064 (42000): You have an error in your SQL syntax\nnear 'TABLEE users' at line 1\n"
+	if file.String() != want {
+		t.Errorf("unexpected log file:\n%q\nwant\n%q", file.String(), want)
+	}
+	if out.Len() != 0 || !strings.Contains(errOut.String(), "\x1b[31m") {
+		t.Errorf("expected the terminal to show the colored error only, got %q and %q", out.String(), errOut.String())
+	}
+}
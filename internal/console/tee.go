@@ -0,0 +1,87 @@
+package console
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+)
+
+// quietHandler drops everything below errors but the results of a command, for SetQuiet
+type quietHandler struct {
+	slog.Handler
+}
+
+func (h quietHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		return h.Handler.Handle(ctx, r)
+	}
+	result := false
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != kindKey {
+			return true
+		}
+		switch a.Value.String() {
+		case kindSummary, kindTable, kindRow, kindReport:
+			result = true
+		}
+		return false
+	})
+	if !result {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h quietHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return quietHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h quietHandler) WithGroup(name string) slog.Handler {
+	return quietHandler{h.Handler.WithGroup(name)}
+}
+
+// teeHandler hands each record to the terminal and the log file
+type teeHandler struct {
+	terminal slog.Handler
+	file     slog.Handler
+}
+
+func (h teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.terminal.Enabled(ctx, level) || h.file.Enabled(ctx, level)
+}
+
+func (h teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	if h.terminal.Enabled(ctx, r.Level) {
+		errs = append(errs, h.terminal.Handle(ctx, r.Clone()))
+	}
+	if h.file.Enabled(ctx, r.Level) {
+		errs = append(errs, h.file.Handle(ctx, r))
+	}
+	return errors.Join(errs...)
+}
+
+func (h teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return teeHandler{h.terminal.WithAttrs(attrs), h.file.WithAttrs(attrs)}
+}
+
+func (h teeHandler) WithGroup(name string) slog.Handler {
+	return teeHandler{h.terminal.WithGroup(name), h.file.WithGroup(name)}
+}
+
+// ansiCodes matches the color codes of the text format
+var ansiCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// plainWriter removes the color codes of the text format, e.g. for a log file
+type plainWriter struct {
+	w io.Writer
+}
+
+func (p plainWriter) Write(b []byte) (int, error) {
+	if _, err := p.w.Write(ansiCodes.ReplaceAll(b, nil)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
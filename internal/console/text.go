@@ -0,0 +1,158 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// kindKey is the attribute naming how the text handler renders a record; the JSON
+// handler drops it
+const kindKey = "console.kind"
+
+// Record kinds, one per Console method with a rendering of its own
+const (
+	kindPlain   = "plain" // Rendered by level
+	kindSuccess = "success"
+	kindFailure = "failure"
+	kindHeader  = "header"
+	kindScript  = "script"
+	kindTable   = "table"
+	kindRow     = "row" // A table row of the JSON format
+	kindSummary = "summary"
+	kindReport  = "report" // JSON only
+)
+
+// table is the value of a table record of the text format
+type table struct {
+	columns []string
+	rows    [][]string
+}
+
+// textHandler renders records as colored lines for terminals. Errors go to errOut,
+// everything else to out
+type textHandler struct {
+	out    io.Writer
+	errOut io.Writer
+	level  slog.Leveler
+	now    func() time.Time
+	mu     *sync.Mutex
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// WithAttrs and WithGroup return the handler unchanged: the text format shows the
+// message, and the attributes only choose how
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *textHandler) WithGroup(string) slog.Handler      { return h }
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	kind := kindPlain
+	attrs := make(map[string]slog.Value)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == kindKey {
+			kind = a.Value.String()
+		} else {
+			attrs[a.Key] = a.Value
+		}
+		return true
+	})
+
+	var b strings.Builder
+	w := h.out
+	switch kind {
+	case kindReport:
+		return nil
+	case kindSuccess:
+		fmt.Fprintf(&b, "%s[%s]%s %s✓%s %s\n", Cyan, h.timestamp(), Reset, Green, Reset, r.Message)
+	case kindFailure:
+		fmt.Fprintf(&b, "%s[%s]%s %s✗%s %s\n", Cyan, h.timestamp(), Reset, Red, Reset, r.Message)
+	case kindHeader:
+		writeHeader(&b, r.Message)
+	case kindScript:
+		color, symbol := scriptStyle(attrs["status"].String())
+		fmt.Fprintf(&b, "%s[%s]%s %s%s%s %s\n", Cyan, h.timestamp(), Reset, color, symbol, Reset, attrs["script"].String())
+	case kindTable:
+		t, _ := attrs["table"].Any().(table)
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  "+strings.Join(t.columns, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, "  "+strings.Join(row, "\t"))
+		}
+		tw.Flush()
+	case kindSummary:
+		writeHeader(&b, "Migration Summary")
+		fmt.Fprintf(&b, "  Total scripts:   %s%d%s\n", Bold, attrs["total"].Int64(), Reset)
+		fmt.Fprintf(&b, "  Successful:      %s%s%d%s\n", Green, Bold, attrs["success"].Int64(), Reset)
+		if failed := attrs["failed"].Int64(); failed > 0 {
+			fmt.Fprintf(&b, "  Failed:          %s%s%d%s\n", Red, Bold, failed, Reset)
+		} else {
+			fmt.Fprintf(&b, "  Failed:          %d\n", failed)
+		}
+		fmt.Fprintf(&b, "  Skipped:         %s%d%s\n", Blue, attrs["skipped"].Int64(), Reset)
+		if slow, ok := attrs["slow"].Any().([]SlowScript); ok {
+			width := 0
+			for _, s := range slow {
+				width = max(width, len(s.Script))
+			}
+			fmt.Fprintf(&b, "  Slow scripts:\n")
+			for _, s := range slow {
+				fmt.Fprintf(&b, "    %s%-*s%s  %s\n", Yellow, width, s.Script, Reset, time.Duration(s.DurationMS)*time.Millisecond)
+			}
+		}
+		fmt.Fprintln(&b)
+	default:
+		switch {
+		case r.Level >= slog.LevelError:
+			w = h.errOut
+			fmt.Fprintf(&b, "%s[%s]%s %s%s✗ ERROR:%s %s\n", Cyan, h.timestamp(), Reset, Bold, Red, Reset, r.Message)
+		case r.Level >= slog.LevelWarn:
+			fmt.Fprintf(&b, "%s[%s]%s %s⚠%s %s\n", Cyan, h.timestamp(), Reset, Yellow, Reset, r.Message)
+		case r.Level >= slog.LevelInfo:
+			fmt.Fprintf(&b, "%s[%s]%s %sℹ%s %s\n", Cyan, h.timestamp(), Reset, Blue, Reset, r.Message)
+		case r.Level >= slog.LevelDebug:
+			fmt.Fprintf(&b, "%s[%s]%s %s·%s %s\n", Cyan, h.timestamp(), Reset, White, Reset, r.Message)
+		default:
+			fmt.Fprintf(&b, "%s[%s]%s %s›%s %s\n", Cyan, h.timestamp(), Reset, Magenta, Reset, r.Message)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// timestamp returns current timestamp string
+func (h *textHandler) timestamp() string {
+	return h.now().Format("2006-01-02 15:04:05")
+}
+
+// writeHeader writes a section header
+func writeHeader(b *strings.Builder, title string) {
+	fmt.Fprintf(b, "\n%s%s═══ %s ═══%s\n\n", Bold, Cyan, title, Reset)
+}
+
+// scriptStyle returns the color and symbol of a script status
+func scriptStyle(status string) (string, string) {
+	switch status {
+	case "executing":
+		return Yellow, "▶"
+	case "success":
+		return Green, "✓"
+	case "failed":
+		return Red, "✗"
+	case "skipped":
+		return Blue, "○"
+	case "deferred":
+		return Magenta, "»"
+	}
+	return White, "•"
+}
@@ -0,0 +1,31 @@
+package db
+
+import "strings"
+
+// IsBatchSeparator reports whether a line is a GO, the batch separator of sqlcmd and
+// SSMS. It is not T-SQL: the client splits the script and sends each batch on its own
+func IsBatchSeparator(line string) bool {
+	return strings.EqualFold(strings.TrimSpace(line), "GO")
+}
+
+// SplitBatches splits a SQL Server script at its GO lines, dropping empty batches.
+// Statements such as CREATE PROCEDURE must be the first of their batch
+func SplitBatches(sqlContent string) []string {
+	var batches []string
+	var b strings.Builder
+	flush := func() {
+		if strings.TrimSpace(b.String()) != "" {
+			batches = append(batches, b.String())
+		}
+		b.Reset()
+	}
+	for _, line := range strings.SplitAfter(sqlContent, "\n") {
+		if IsBatchSeparator(line) {
+			flush()
+			continue
+		}
+		b.WriteString(line)
+	}
+	flush()
+	return batches
+}
@@ -1,23 +1,98 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	// SQLite driver of --driver sqlite; it needs cgo, and fails to open without it
+	_ "github.com/mattn/go-sqlite3"
+	// SQL Server driver of --driver mssql, registered as sqlserver
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// Drivers a DB may be opened with
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite3" // Local development and unit tests, see Open
+	DriverMSSQL    = "sqlserver"
 )
 
 // DB wraps *sql.DB with transaction support
 type DB struct {
-	conn *sql.DB
+	conn   *sql.DB
+	driver string
+	// killed is set by Kill until the next Reconnect
+	killed bool
+	// reopen opens a replacement pool, e.g. with refreshed credentials (optional)
+	reopen func() (*DB, error)
+	// statementTimeout bounds each statement of ExecuteSQL and ExecuteSQLTx (optional)
+	statementTimeout time.Duration
+	// logger receives every statement sent at console.LevelTrace, e.g. for -vv
+	logger *slog.Logger
+	// traceArgs logs the values bound to statements too, see SetTraceArgs
+	traceArgs bool
+}
+
+// ErrTimeout marks a statement cancelled by the deadline of its context or the statement timeout
+var ErrTimeout = errors.New("statement timed out")
+
+// ErrInterrupted marks a statement cancelled because its context was cancelled, e.g. on SIGINT
+var ErrInterrupted = errors.New("statement interrupted")
+
+// StatementError is the failure of one of the statements of a script sent one at a time
+type StatementError struct {
+	Index int // Position of the failed statement, from 1
+	Total int
+	Err   error
 }
 
-// Connect establishes a database connection with pooling configuration
-func Connect(dsn string) (*DB, error) {
-	conn, err := sql.Open("mysql", dsn)
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d of %d: %v", e.Index, e.Total, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// Connect establishes a database connection with pooling configuration; opts adjust
+// the driver configuration, e.g. to authenticate each connection with a fresh token.
+// logger receives every statement sent (optional)
+func Connect(dsn string, logger *slog.Logger, opts ...mysql.Option) (*DB, error) {
+	return ConnectSession(dsn, "", logger, opts...)
+}
+
+// ConnectSession is Connect, running sessionInit (e.g. SET ROLE) on every new connection
+// of the pool before it is used
+func ConnectSession(dsn, sessionInit string, logger *slog.Logger, opts ...mysql.Option) (*DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err == nil {
+		err = cfg.Apply(opts...)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	database := New(nil, DriverMySQL, logger)
+	if sessionInit != "" {
+		connector = &sessionConnector{Connector: connector, init: sessionInit, db: database}
+	}
+	conn := sql.OpenDB(connector)
 
 	// Configure connection pool
 	conn.SetMaxOpenConns(10)
@@ -25,10 +100,223 @@ func Connect(dsn string) (*DB, error) {
 
 	// Verify connection
 	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	database.conn = conn
+	return database, nil
+}
+
+// Open opens a database with a registered database/sql driver other than MySQL, e.g.
+// PostgreSQL, or a SQLite file for local development and unit tests; logger receives
+// every statement sent (optional)
+func Open(driverName, dsn string, logger *slog.Logger) (*DB, error) {
+	conn, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn.SetMaxOpenConns(10)
+	conn.SetMaxIdleConns(5)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
+	return New(conn, driverName, logger), nil
+}
+
+// New wraps a pool opened by the caller, e.g. a service's own *sql.DB, with the
+// driver it was opened with; closing the DB closes conn. logger receives every
+// statement sent (optional)
+func New(conn *sql.DB, driverName string, logger *slog.Logger) *DB {
+	if logger == nil {
+		logger = discard
+	}
+	return &DB{conn: conn, driver: driverName, logger: logger}
+}
+
+// Driver returns the name of the driver the database was opened with
+func (db *DB) Driver() string {
+	return db.driver
+}
+
+// sessionConnector runs session setup statements on every new connection
+type sessionConnector struct {
+	driver.Connector
+	init string
+	db   *DB // Traces init
+}
+
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("driver does not support session setup statements")
+	}
+	c.db.trace(ctx, c.init, nil)
+	if _, err := execer.ExecContext(ctx, c.init, nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to run session setup: %w", err)
+	}
+	return conn, nil
+}
+
+// ConnectionAttributes returns a mysql driver option that sends attrs with every
+// connection, for performance_schema.session_connect_attrs and audit plugins
+func ConnectionAttributes(attrs map[string]string) mysql.Option {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// The driver splits the list at commas and each pair at its first colon
+		name := strings.NewReplacer(":", "_", ",", "_").Replace(key)
+		pairs = append(pairs, name+":"+strings.ReplaceAll(attrs[key], ",", "%2C"))
+	}
+	return func(cfg *mysql.Config) error {
+		cfg.ConnectionAttributes = strings.Join(pairs, ",")
+		return nil
+	}
+}
+
+// SetReconnect sets how Reconnect opens a replacement pool, e.g. after reading new
+// credentials from Vault or a secret manager
+func (db *DB) SetReconnect(open func() (*DB, error)) {
+	db.reopen = open
+}
+
+// SetStatementTimeout bounds each statement that ExecuteSQL and ExecuteSQLTx send; zero
+// means no limit
+func (db *DB) SetStatementTimeout(timeout time.Duration) {
+	db.statementTimeout = timeout
+}
+
+// CanReconnect reports whether a reconnect function is set
+func (db *DB) CanReconnect() bool {
+	return db.reopen != nil
+}
+
+// Reconnect replaces the connection pool with a freshly opened one and closes the old
+// pool; it must not be called while queries or transactions are running
+func (db *DB) Reconnect() error {
+	if db.reopen == nil {
+		return fmt.Errorf("no way to reconnect is configured")
+	}
+	fresh, err := db.reopen()
+	if err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+	old := db.conn
+	db.conn = fresh.conn
+	db.killed = false
+	old.Close()
+	return nil
+}
+
+// Kill closes the pool as if the server had dropped its connections, e.g. to test how
+// runs recover; Ping reports driver.ErrBadConn until Reconnect
+func (db *DB) Kill() error {
+	db.killed = true
+	return db.conn.Close()
+}
+
+// Ping verifies that a connection can be used, opening one if needed
+func (db *DB) Ping(ctx context.Context) error {
+	if db.killed {
+		return driver.ErrBadConn
+	}
+	return db.conn.PingContext(ctx)
+}
+
+// connectionErrors are server errors after which a new connection, possibly with new
+// credentials, may succeed
+var connectionErrors = map[uint16]bool{
+	1045: true, // ER_ACCESS_DENIED_ERROR: expired token or revoked lease
+	1053: true, // ER_SERVER_SHUTDOWN
+	1820: true, // ER_MUST_CHANGE_PASSWORD
+	1862: true, // ER_MUST_CHANGE_PASSWORD_LOGIN
+	1927: true, // ER_CONNECTION_KILLED (MariaDB)
+	3118: true, // ER_ACCOUNT_HAS_BEEN_LOCKED
+	4031: true, // ER_CLIENT_INTERACTION_TIMEOUT
+}
+
+// postgresConnectionErrors are the PostgreSQL counterparts of connectionErrors, besides
+// class 08 (connection exception)
+var postgresConnectionErrors = map[pq.ErrorCode]bool{
+	"28000": true, // invalid_authorization_specification
+	"28P01": true, // invalid_password: expired token or revoked lease
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// transientErrors are MySQL errors that roll back a statement or transaction which may
+// succeed when run again
+var transientErrors = map[uint16]bool{
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1213: true, // ER_LOCK_DEADLOCK
+}
+
+// postgresTransientErrors are the PostgreSQL counterparts of transientErrors
+var postgresTransientErrors = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+}
+
+// mssqlTransientErrors are the SQL Server counterparts of transientErrors
+var mssqlTransientErrors = map[int32]bool{
+	1205: true, // Chosen as deadlock victim
+	1222: true, // Lock request time out period exceeded
+}
+
+// IsTransientError reports whether err is a deadlock, a lock wait timeout or a lost
+// connection, after which running the rolled back transaction again may succeed
+func IsTransientError(err error) bool {
+	if IsConnectionError(err) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return transientErrors[mysqlErr.Number]
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return postgresTransientErrors[pqErr.Code]
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return mssqlTransientErrors[mssqlErr.Number]
+	}
+	// The SQLite driver's error type needs cgo, so SQLITE_BUSY and SQLITE_LOCKED are
+	// told by their messages
+	return strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "database table is locked")
+}
 
-	return &DB{conn: conn}, nil
+// IsConnectionError reports whether err means the connection or its credentials were
+// lost, rather than a statement failing
+func IsConnectionError(err error) bool {
+	// A cancelled statement may also drop its connection
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrInterrupted) {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return connectionErrors[mysqlErr.Number]
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || postgresConnectionErrors[pqErr.Code]
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
 }
 
 // Close closes the database connection
@@ -43,27 +331,133 @@ func (db *DB) Begin() (*sql.Tx, error) {
 
 // Exec executes a query without returning rows
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	db.trace(context.Background(), query, args)
 	return db.conn.Exec(query, args...)
 }
 
 // Query executes a query that returns rows
 func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	db.trace(context.Background(), query, args)
 	return db.conn.Query(query, args...)
 }
 
 // QueryRow executes a query that returns at most one row
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	db.trace(context.Background(), query, args)
 	return db.conn.QueryRow(query, args...)
 }
 
-// ExecuteSQL executes SQL content within a transaction
-func ExecuteSQL(tx *sql.Tx, sqlContent string) error {
-	_, err := tx.Exec(sqlContent)
-	return err
+// QueryContext executes a query that returns rows, honoring ctx cancellation
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	db.trace(ctx, query, args)
+	return db.conn.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query that returns at most one row, honoring ctx cancellation
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	db.trace(ctx, query, args)
+	return db.conn.QueryRowContext(ctx, query, args...)
+}
+
+// ServerVersion returns the database server version string
+func (db *DB) ServerVersion() (string, error) {
+	query := "SELECT VERSION()"
+	switch db.driver {
+	case DriverSQLite:
+		query = "SELECT sqlite_version()"
+	case DriverMSSQL:
+		query = "SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))"
+	}
+	var version string
+	db.trace(context.Background(), query, nil)
+	if err := db.conn.QueryRow(query).Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to get server version: %w", err)
+	}
+	return version, nil
+}
+
+// session is a transaction or a connection that statements of one script share
+type session interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
-// ExecuteSQLWithDB executes SQL content directly on the database connection
-func (db *DB) ExecuteSQL(sqlContent string) error {
-	_, err := db.conn.Exec(sqlContent)
-	return err
+// ExecuteSQLTx executes SQL content within a transaction; ctx bounds all of it
+func (db *DB) ExecuteSQLTx(ctx context.Context, tx *sql.Tx, sqlContent string) error {
+	return db.execBatches(ctx, tx, db.batches(sqlContent, true))
+}
+
+// ExecuteSQL executes SQL content directly on a connection of its own, so its
+// statements share a session; ctx bounds all of it
+func (db *DB) ExecuteSQL(ctx context.Context, sqlContent string) error {
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return db.execBatches(ctx, conn, db.batches(sqlContent, false))
+}
+
+// batches splits SQL content into what is sent in one round trip: statements on MySQL,
+// which honors DELIMITER lines, and GO batches on SQL Server. PostgreSQL runs several
+// statements of one query in an implicit transaction, which CREATE INDEX CONCURRENTLY
+// refuses, so outside a transaction they are sent one by one too
+func (db *DB) batches(sqlContent string, inTx bool) []string {
+	switch {
+	case db.driver == DriverMySQL, db.driver == DriverPostgres && !inTx:
+		return SplitStatementsFor(db.driver, sqlContent)
+	case db.driver == DriverMSSQL:
+		return SplitBatches(sqlContent)
+	}
+	return []string{sqlContent}
+}
+
+// execBatches executes batches in order, stopping at the first failure. Each is
+// cancelled when ctx is done or the statement timeout passes
+func (db *DB) execBatches(ctx context.Context, s session, batches []string) error {
+	kill, err := db.queryKiller(ctx, s)
+	if err != nil {
+		return err
+	}
+	for i, batch := range batches {
+		stmtCtx, cancel := ctx, context.CancelFunc(func() {})
+		if db.statementTimeout > 0 {
+			stmtCtx, cancel = context.WithTimeout(ctx, db.statementTimeout)
+		}
+		stop := context.AfterFunc(stmtCtx, kill)
+		db.trace(ctx, batch, nil)
+		_, err := s.ExecContext(stmtCtx, batch)
+		stop()
+		if err != nil && errors.Is(stmtCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %w", ErrTimeout, err)
+		} else if err != nil && errors.Is(stmtCtx.Err(), context.Canceled) {
+			err = fmt.Errorf("%w: %w", ErrInterrupted, err)
+		}
+		cancel()
+		if err != nil {
+			if len(batches) > 1 {
+				return &StatementError{Index: i + 1, Total: len(batches), Err: err}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// queryKiller returns what stops the running statement of a session once its context is
+// done. The MySQL driver only drops the connection, which leaves the statement running
+// on the server, so it is killed from another connection; the other drivers cancel
+// statements themselves
+func (db *DB) queryKiller(ctx context.Context, s session) (func(), error) {
+	if db.driver != DriverMySQL || (ctx.Done() == nil && db.statementTimeout == 0) {
+		return func() {}, nil
+	}
+	var id int64
+	db.trace(ctx, "SELECT CONNECTION_ID()", nil)
+	if err := s.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return nil, fmt.Errorf("failed to get connection ID: %w", err)
+	}
+	return func() {
+		db.Exec(fmt.Sprintf("KILL QUERY %d", id))
+	}, nil
 }
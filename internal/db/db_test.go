@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func TestConnectionAttributes(t *testing.T) {
+	cfg := mysql.NewConfig()
+	err := cfg.Apply(ConnectionAttributes(map[string]string{
+		"program_name": "db-migration",
+		"ci_job_url":   "https://ci.example.com/job?ids=1,2",
+		"team:name":    "payments",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ci_job_url:https://ci.example.com/job?ids=1%2C2,program_name:db-migration,team_name:payments"
+	if cfg.ConnectionAttributes != want {
+		t.Errorf("ConnectionAttributes = %q, want %q", cfg.ConnectionAttributes, want)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied for user 'v-token-migrator'"}, true},
+		{fmt.Errorf("failed to begin transaction: %w", driver.ErrBadConn), true},
+		{mysql.ErrInvalidConn, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&mysql.MySQLError{Number: 1146, Message: "Table 'app.missing' doesn't exist"}, false},
+		{&pq.Error{Code: "28P01", Message: "password authentication failed"}, true},
+		{&pq.Error{Code: "08006", Message: "connection failure"}, true},
+		{&pq.Error{Code: "42P01", Message: `relation "missing" does not exist`}, false},
+		{errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		if got := IsConnectionError(tt.err); got != tt.want {
+			t.Errorf("IsConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{&StatementError{Index: 2, Total: 3, Err: &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}}, true},
+		{fmt.Errorf("failed to begin transaction: %w", driver.ErrBadConn), true},
+		{&mysql.MySQLError{Number: 1146, Message: "Table 'app.missing' doesn't exist"}, false},
+		{&pq.Error{Code: "40P01", Message: "deadlock detected"}, true},
+		{&pq.Error{Code: "42P01", Message: `relation "missing" does not exist`}, false},
+		{mssql.Error{Number: 1205, Message: "Transaction was deadlocked"}, true},
+		{mssql.Error{Number: 208, Message: "Invalid object name"}, false},
+		{errors.New("database is locked"), true},
+		{fmt.Errorf("%w: %w", ErrTimeout, context.DeadlineExceeded), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	database, err := Open(DriverSQLite, ":memory:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	database.SetStatementTimeout(50 * time.Millisecond)
+
+	slow := "SELECT 1; WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT COUNT(*) FROM c"
+	if err := database.ExecuteSQL(context.Background(), slow); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected the statement to time out, got %v", err)
+	}
+	if err := database.ExecuteSQL(context.Background(), "SELECT 1"); err != nil {
+		t.Errorf("expected a fast statement to run, got %v", err)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var out strings.Builder
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: console.LevelTrace}))
+
+	database, err := Open(DriverSQLite, ":memory:", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.ExecuteSQL(context.Background(), "CREATE TABLE t (id INTEGER);\n  INSERT INTO t VALUES (1);"); err != nil {
+		t.Fatal(err)
+	}
+	// Bound values may be secrets, so they are only logged when asked for
+	if _, err := database.Exec("DELETE FROM t WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	database.SetTraceArgs(true)
+	if _, err := database.Exec("DELETE FROM t WHERE id = ?", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`msg="SQL: CREATE TABLE t (id INTEGER); INSERT INTO t VALUES (1);"`, `msg="SQL: DELETE FROM t WHERE id = ? [1 redacted]"`, `msg="SQL: DELETE FROM t WHERE id = ? [2]"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %s in:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "[1]") {
+		t.Errorf("expected the first value to be redacted:\n%s", out.String())
+	}
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect is the SQL of the tool's own queries that differs between servers. Migration
+// scripts are written for their server and never rewritten
+type Dialect interface {
+	// Rebind rewrites the ? placeholders of a query into the driver's style
+	Rebind(query string) string
+	// SerialKey is the column definition of an auto-numbered integer primary key
+	SerialKey() string
+	// Timestamp is the column type of a date and time without time zone
+	Timestamp() string
+	// TableExistsQuery counts the tables named by its one argument in the current
+	// database or schema
+	TableExistsQuery() string
+	// ColumnExistsQuery counts the columns named by its second argument of the table
+	// named by its first
+	ColumnExistsQuery() string
+	// AddColumn is the statement adding a column to a table
+	AddColumn(table, column, definition string) string
+	// QuoteIdentifier quotes a plain or schema-qualified identifier
+	QuoteIdentifier(ident string) string
+	// CreateTableIfNotExists starts the statement creating a table unless it exists
+	CreateTableIfNotExists(table string) string
+	// Boolean is the column type of a flag
+	Boolean() string
+	// Text is the column type of a string of unbounded length
+	Text() string
+	// Bool is the literal of a flag value
+	Bool(v bool) string
+	// Limit ends a query ordered by ORDER BY after its first n rows
+	Limit(n int) string
+}
+
+// DialectOf returns the dialect of a driver; unknown drivers get MySQL's
+func DialectOf(driverName string) Dialect {
+	switch driverName {
+	case DriverPostgres:
+		return postgresDialect{}
+	case DriverSQLite:
+		return sqliteDialect{}
+	case DriverMSSQL:
+		return mssqlDialect{}
+	}
+	return mysqlDialect{}
+}
+
+// Dialect returns the dialect of the database's driver
+func (db *DB) Dialect() Dialect {
+	return DialectOf(db.driver)
+}
+
+// standardDialect is the SQL that MySQL, PostgreSQL and SQLite share
+type standardDialect struct{}
+
+func (standardDialect) CreateTableIfNotExists(table string) string {
+	return "CREATE TABLE IF NOT EXISTS " + table
+}
+func (standardDialect) Boolean() string { return "BOOLEAN" }
+func (standardDialect) Text() string    { return "TEXT" }
+func (standardDialect) Bool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+func (standardDialect) Limit(n int) string { return "LIMIT " + strconv.Itoa(n) }
+func (standardDialect) AddColumn(table, column, definition string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition
+}
+
+type mysqlDialect struct{ standardDialect }
+
+func (mysqlDialect) Rebind(query string) string { return query }
+func (mysqlDialect) SerialKey() string          { return "INT(11) PRIMARY KEY AUTO_INCREMENT" }
+func (mysqlDialect) Timestamp() string          { return "DATETIME" }
+func (mysqlDialect) TableExistsQuery() string {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+}
+func (mysqlDialect) ColumnExistsQuery() string {
+	return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+}
+func (mysqlDialect) QuoteIdentifier(ident string) string { return quoteParts(ident, "`") }
+
+type postgresDialect struct{ standardDialect }
+
+// Rebind numbers the placeholders $1, $2, ...
+func (postgresDialect) Rebind(query string) string { return numberPlaceholders(query, "$") }
+
+func (postgresDialect) SerialKey() string { return "SERIAL PRIMARY KEY" }
+func (postgresDialect) Timestamp() string { return "TIMESTAMP" }
+
+// TableExistsQuery folds the name to lower case, as PostgreSQL does with unquoted names
+func (postgresDialect) TableExistsQuery() string {
+	return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = lower($1)`
+}
+
+func (postgresDialect) ColumnExistsQuery() string {
+	return `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = lower($1) AND column_name = lower($2)`
+}
+
+func (postgresDialect) QuoteIdentifier(ident string) string { return quoteParts(ident, `"`) }
+
+type sqliteDialect struct{ standardDialect }
+
+func (sqliteDialect) Rebind(query string) string { return query }
+func (sqliteDialect) SerialKey() string          { return "INTEGER PRIMARY KEY AUTOINCREMENT" }
+func (sqliteDialect) Timestamp() string          { return "DATETIME" }
+func (sqliteDialect) TableExistsQuery() string {
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+}
+func (sqliteDialect) ColumnExistsQuery() string {
+	return `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+}
+func (sqliteDialect) QuoteIdentifier(ident string) string { return quoteParts(ident, "`") }
+
+type mssqlDialect struct{}
+
+// Rebind numbers the placeholders @p1, @p2, ...
+func (mssqlDialect) Rebind(query string) string { return numberPlaceholders(query, "@p") }
+
+func (mssqlDialect) SerialKey() string { return "INT IDENTITY(1,1) PRIMARY KEY" }
+func (mssqlDialect) Timestamp() string { return "DATETIME2" }
+func (mssqlDialect) TableExistsQuery() string {
+	return `SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = SCHEMA_NAME() AND TABLE_NAME = @p1`
+}
+
+func (mssqlDialect) ColumnExistsQuery() string {
+	return `SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = SCHEMA_NAME() AND TABLE_NAME = @p1 AND COLUMN_NAME = @p2`
+}
+
+// AddColumn omits COLUMN, which SQL Server does not accept
+func (mssqlDialect) AddColumn(table, column, definition string) string {
+	return "ALTER TABLE " + table + " ADD " + column + " " + definition
+}
+
+// QuoteIdentifier brackets each part, doubling the ] within it
+func (mssqlDialect) QuoteIdentifier(ident string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = "[" + strings.ReplaceAll(part, "]", "]]") + "]"
+	}
+	return strings.Join(parts, ".")
+}
+
+// CreateTableIfNotExists checks OBJECT_ID, as SQL Server has no IF NOT EXISTS
+func (mssqlDialect) CreateTableIfNotExists(table string) string {
+	return "IF OBJECT_ID(N'" + strings.ReplaceAll(table, "'", "''") + "', N'U') IS NULL CREATE TABLE " + table
+}
+
+func (mssqlDialect) Boolean() string { return "BIT" }
+func (mssqlDialect) Text() string    { return "NVARCHAR(MAX)" }
+func (mssqlDialect) Bool(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+func (mssqlDialect) Limit(n int) string {
+	return "OFFSET 0 ROWS FETCH NEXT " + strconv.Itoa(n) + " ROWS ONLY"
+}
+
+// numberPlaceholders rewrites the ? placeholders into prefix1, prefix2, ...; the tool's
+// queries have no ? in literals
+func numberPlaceholders(query, prefix string) string {
+	var b strings.Builder
+	n := 0
+	for _, part := range strings.SplitAfter(query, "?") {
+		if strings.HasSuffix(part, "?") {
+			n++
+			part = part[:len(part)-1] + prefix + strconv.Itoa(n)
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// quoteParts quotes each dot-separated part of an identifier
+func quoteParts(ident, quote string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = quote + part + quote
+	}
+	return strings.Join(parts, ".")
+}
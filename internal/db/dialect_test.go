@@ -0,0 +1,40 @@
+package db
+
+import "testing"
+
+func TestPostgresRebind(t *testing.T) {
+	got := DialectOf(DriverPostgres).Rebind("SELECT * FROM t WHERE sno > ? AND sno <= ?")
+	if want := "SELECT * FROM t WHERE sno > $1 AND sno <= $2"; got != want {
+		t.Errorf("Rebind = %q, want %q", got, want)
+	}
+	if got := DialectOf(DriverMSSQL).Rebind("SELECT * FROM t WHERE sno > ?"); got != "SELECT * FROM t WHERE sno > @p1" {
+		t.Errorf("SQL Server Rebind = %q", got)
+	}
+	if got := DialectOf(DriverMySQL).Rebind("SELECT ?"); got != "SELECT ?" {
+		t.Errorf("MySQL Rebind changed the query: %q", got)
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	if got := DialectOf(DriverPostgres).QuoteIdentifier("public.users"); got != `"public"."users"` {
+		t.Errorf("PostgreSQL QuoteIdentifier = %s", got)
+	}
+	if got := DialectOf(DriverMySQL).QuoteIdentifier("app.users"); got != "`app`.`users`" {
+		t.Errorf("MySQL QuoteIdentifier = %s", got)
+	}
+	if got := DialectOf(DriverMSSQL).QuoteIdentifier("dbo.odd]name"); got != "[dbo].[odd]]name]" {
+		t.Errorf("SQL Server QuoteIdentifier = %s", got)
+	}
+}
+
+func TestSplitBatches(t *testing.T) {
+	script := "CREATE TABLE users (id INT IDENTITY(1,1));\ngo\n\nCREATE PROCEDURE list_users AS SELECT * FROM users;\n  GO  \r\nGO\n"
+	got := SplitBatches(script)
+	want := []string{"CREATE TABLE users (id INT IDENTITY(1,1));\n", "\nCREATE PROCEDURE list_users AS SELECT * FROM users;\n"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("SplitBatches = %q, want %q", got, want)
+	}
+	if got := SplitBatches("SELECT 'GO'\nGOTO done"); len(got) != 1 {
+		t.Errorf("expected GO within a line to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLockTimeout is returned when another session held a lock for the whole wait
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// Lock is a named user lock (GET_LOCK, an advisory lock on PostgreSQL or an application
+// lock on SQL Server) held by a connection of its own. The server releases it when that
+// session ends, so a crashed holder never leaves it behind
+type Lock struct {
+	conn *sql.Conn
+	name string
+	db   *DB
+}
+
+// advisoryPoll is how often a PostgreSQL advisory lock is tried again while waiting
+const advisoryPoll = 250 * time.Millisecond
+
+// AcquireLock waits up to wait (whole seconds) for the named lock
+func (db *DB) AcquireLock(ctx context.Context, name string, wait time.Duration) (*Lock, error) {
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for lock %s: %w", name, err)
+	}
+
+	acquired, err := db.tryLock(ctx, conn, name, wait)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, ErrLockTimeout
+	}
+	return &Lock{conn: conn, name: name, db: db}, nil
+}
+
+// tryLock waits up to wait for the named lock on conn. PostgreSQL advisory locks are
+// keyed by number, so the name is hashed; they cannot wait, so they are polled. SQLite
+// has no named locks and serializes writers itself, so its lock is always granted
+func (db *DB) tryLock(ctx context.Context, conn *sql.Conn, name string, wait time.Duration) (bool, error) {
+	if db.driver == DriverSQLite {
+		return true, nil
+	}
+	if db.driver == DriverMSSQL {
+		// sp_getapplock returns 0 or 1 when granted, and a negative code otherwise
+		var result int
+		db.trace(ctx, "EXEC sp_getapplock", []interface{}{name, wait.Milliseconds()})
+		err := conn.QueryRowContext(ctx, `DECLARE @r INT;
+EXEC @r = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2;
+SELECT @r`, name, wait.Milliseconds()).Scan(&result)
+		return result >= 0, err
+	}
+	if db.driver != DriverPostgres {
+		var acquired sql.NullInt64
+		db.trace(ctx, "SELECT GET_LOCK(?, ?)", []interface{}{name, int(wait.Seconds())})
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(wait.Seconds())).Scan(&acquired)
+		return acquired.Int64 == 1, err
+	}
+
+	// Polls are not traced, only the first try
+	db.trace(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", []interface{}{name})
+	deadline := time.Now().Add(wait)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired); err != nil || acquired {
+			return acquired, err
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(advisoryPoll):
+		}
+	}
+}
+
+// Release releases the lock and returns its connection to the pool
+func (l *Lock) Release() error {
+	var query string
+	switch l.db.driver {
+	case DriverSQLite:
+	case DriverPostgres:
+		query = "SELECT pg_advisory_unlock(hashtext($1))"
+	case DriverMSSQL:
+		query = "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'"
+	default:
+		query = "DO RELEASE_LOCK(?)"
+	}
+	var err error
+	if query != "" {
+		l.db.trace(context.Background(), query, []interface{}{l.name})
+		_, err = l.conn.ExecContext(context.Background(), query, l.name)
+	}
+	if closeErr := l.conn.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.name, err)
+	}
+	return nil
+}
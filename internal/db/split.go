@@ -0,0 +1,188 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultDelimiter ends statements until a DELIMITER line changes it
+const DefaultDelimiter = ";"
+
+// SplitStatements splits a script into the statements the server is sent one at a time.
+// Like the mysql client, it ends them at the delimiter, ";" until a DELIMITER line sets
+// another, so the bodies of procedures and triggers keep their semicolons:
+//
+//	DELIMITER //
+//	CREATE TRIGGER users_bi BEFORE INSERT ON users FOR EACH ROW
+//	BEGIN
+//	  SET NEW.email = LOWER(NEW.email);
+//	END //
+//	DELIMITER ;
+//
+// Delimiters in quotes, PostgreSQL's dollar quotes and comments are ignored, and GO
+// lines end statements too. Statements keep their comments, since /*! */ and /*+ */
+// are executed; those with nothing else are dropped. Comments are MySQL's: # starts
+// one, and -- only when whitespace or a control character follows, so 1--1 is code
+func SplitStatements(content string) []string {
+	return split(content, true, true)
+}
+
+// SplitStatementsFor is SplitStatements with the comments of driverName's SQL: on the
+// other servers # is an operator, e.g. PostgreSQL's XOR, and -- always starts a comment
+func SplitStatementsFor(driverName, content string) []string {
+	return split(content, true, driverName == DriverMySQL)
+}
+
+// SplitCode is SplitStatements without the comments, for analyzing statements
+func SplitCode(content string) []string {
+	return split(content, false, true)
+}
+
+func split(content string, keepComments, mysql bool) []string {
+	var statements []string
+	var current strings.Builder
+	hasCode := false
+	delimiter := DefaultDelimiter
+
+	flush := func() {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		hasCode = false
+	}
+
+	for i := 0; i < len(content); i++ {
+		if i == 0 || content[i-1] == '\n' {
+			line, _, _ := strings.Cut(content[i:], "\n")
+			if d, ok := delimiterDirective(line); ok || IsBatchSeparator(line) {
+				flush()
+				if ok {
+					delimiter = d
+				}
+				i += len(line) - 1
+				continue
+			}
+		}
+
+		c := content[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := SkipQuoted(content, i)
+			current.WriteString(content[i:end])
+			hasCode = true
+			i = end - 1
+		case c == '#' && mysql, c == '-' && lineComment(content, i, mysql):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			if keepComments {
+				current.WriteString(content[i : i+end])
+			}
+			if i += end; i < len(content) {
+				current.WriteByte('\n')
+			}
+		case c == '/' && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				end = len(content) - i
+			} else {
+				end += 4
+			}
+			if keepComments {
+				current.WriteString(content[i : i+end])
+				// Executable comments are code
+				if strings.HasPrefix(content[i:], "/*!") || strings.HasPrefix(content[i:], "/*+") {
+					hasCode = true
+				}
+			} else {
+				current.WriteByte(' ')
+			}
+			i += end - 1
+		case strings.HasPrefix(content[i:], delimiter):
+			flush()
+			i += len(delimiter) - 1
+		case c == '$' && dollarTag(content, i) != "":
+			tag := dollarTag(content, i)
+			end := strings.Index(content[i+len(tag):], tag)
+			if end < 0 {
+				end = len(content) - i
+			} else {
+				end += 2 * len(tag)
+			}
+			current.WriteString(content[i : i+end])
+			hasCode = true
+			i += end - 1
+		default:
+			if !unicode.IsSpace(rune(c)) {
+				hasCode = true
+			}
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// lineComment reports whether a -- comment starts at i; MySQL needs whitespace or a
+// control character after the dashes
+func lineComment(content string, i int, mysql bool) bool {
+	if !strings.HasPrefix(content[i:], "--") {
+		return false
+	}
+	return !mysql || i+2 == len(content) || content[i+2] <= ' ' || content[i+2] == 0x7f
+}
+
+// dollarTag returns the opening $tag$ of a PostgreSQL dollar-quoted string at i, e.g.
+// the $$ around a function body, or "" if there is none
+func dollarTag(content string, i int) string {
+	if i > 0 && isIdentByte(content[i-1]) {
+		return ""
+	}
+	for j := i + 1; j < len(content); j++ {
+		switch c := content[j]; {
+		case c == '$':
+			return content[i : j+1]
+		case !isIdentByte(c) || (j == i+1 && c >= '0' && c <= '9'):
+			return ""
+		}
+	}
+	return ""
+}
+
+// isIdentByte reports whether c may be part of an unquoted identifier
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// delimiterDirective returns the delimiter a DELIMITER line of the mysql client sets
+func delimiterDirective(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "DELIMITER") {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// SkipQuoted returns the index just past the quoted string starting at start
+// Backslash escapes and doubled quotes are honored
+func SkipQuoted(content string, start int) int {
+	quote := content[start]
+	for i := start + 1; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(content) && content[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(content)
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `/*!40101 SET NAMES utf8mb4 */;
+CREATE TABLE users (id INT, email VARCHAR(255)); -- users; and their emails
+INSERT INTO users VALUES (1, 'a;b''c'), (2, "x\";y");
+
+DELIMITER //
+CREATE TRIGGER users_bi BEFORE INSERT ON users FOR EACH ROW
+BEGIN
+  SET NEW.email = LOWER(NEW.email); # lower case;
+END //
+delimiter ;
+/* only a comment; */
+SELECT 1`
+	want := []string{
+		"/*!40101 SET NAMES utf8mb4 */",
+		"CREATE TABLE users (id INT, email VARCHAR(255))",
+		"-- users; and their emails\nINSERT INTO users VALUES (1, 'a;b''c'), (2, \"x\\\";y\")",
+		"CREATE TRIGGER users_bi BEFORE INSERT ON users FOR EACH ROW\nBEGIN\n  SET NEW.email = LOWER(NEW.email); # lower case;\nEND",
+		"/* only a comment; */\nSELECT 1",
+	}
+	got := SplitStatements(script)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d statements, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i+1, got[i], want[i])
+		}
+	}
+
+	code := SplitCode(script)
+	if len(code) != 4 || code[3] != "SELECT 1" {
+		t.Errorf("expected the comments to be removed, got %q", code)
+	}
+}
+
+func TestSplitStatements_DollarQuotes(t *testing.T) {
+	script := `CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+  NEW.updated_at := now(); RETURN NEW;
+END
+$body$ LANGUAGE plpgsql;
+CREATE INDEX CONCURRENTLY idx_users_email ON users (email);
+SELECT $1, price$ FROM t`
+	got := SplitStatements(script)
+	if len(got) != 3 || !strings.HasSuffix(got[0], "LANGUAGE plpgsql") || got[2] != "SELECT $1, price$ FROM t" {
+		t.Errorf("expected the function body to stay whole, got %q", got)
+	}
+}
+
+func TestSplitStatements_Comments(t *testing.T) {
+	// MySQL starts a -- comment only when whitespace or a control character follows
+	got := SplitStatements("SELECT 1--1;\nSELECT 2 --\tcomment;\nSELECT 3;--")
+	want := []string{"SELECT 1--1", "SELECT 2 --\tcomment;\nSELECT 3"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("SplitStatements = %q, want %q", got, want)
+	}
+
+	// On PostgreSQL # is XOR, and -- is always a comment
+	got = SplitStatementsFor(DriverPostgres, "SELECT 5 # 3;\nSELECT 2--1;\nSELECT 3;")
+	want = []string{"SELECT 5 # 3", "SELECT 2--1;\nSELECT 3"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("SplitStatementsFor(postgres) = %q, want %q", got, want)
+	}
+	if got := SplitStatementsFor(DriverMySQL, "SELECT 5 # 3;\nSELECT 2;"); len(got) != 1 {
+		t.Errorf("expected # to start a comment on MySQL, got %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SSL modes of --ssl-mode, named after the mysql client's
+const (
+	SSLDisabled       = "disabled"        // Plaintext only
+	SSLPreferred      = "preferred"       // TLS when the server supports it, unverified
+	SSLRequired       = "required"        // TLS, unverified
+	SSLVerifyCA       = "verify-ca"       // TLS with a certificate from a trusted CA
+	SSLVerifyIdentity = "verify-identity" // verify-ca, and the certificate names the host
+)
+
+// fipsCipherSuites are the FIPS 140 approved TLS 1.2 suites; TLS 1.3 suites can only be
+// restricted by a FIPS 140 build (GOFIPS140), which also restricts everything else
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// TLSPolicy controls the TLS of database connections
+type TLSPolicy struct {
+	Mode       string // One of the SSL modes
+	CAFile     string // PEM CA bundle; the system roots by default
+	MinVersion string // "1.2" (default) or "1.3"
+	FIPS       bool   // FIPS 140 approved versions, cipher suites and curves only
+}
+
+// Option returns a mysql driver option applying the policy; it replaces any TLS the DSN sets
+func (p TLSPolicy) Option() (mysql.Option, error) {
+	if p.Mode == SSLDisabled {
+		if p.FIPS {
+			return nil, fmt.Errorf("FIPS mode requires TLS")
+		}
+		return func(cfg *mysql.Config) error {
+			cfg.TLS, cfg.TLSConfig, cfg.AllowFallbackToPlaintext = nil, "false", false
+			return nil
+		}, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch p.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS version %q: use 1.2 or 1.3", p.MinVersion)
+	}
+	if p.FIPS {
+		tlsConfig.CipherSuites = fipsCipherSuites
+		tlsConfig.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+	}
+	if p.CAFile != "" {
+		pem, err := os.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", p.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	switch p.Mode {
+	case SSLPreferred, SSLRequired:
+		if p.FIPS && p.Mode == SSLPreferred {
+			return nil, fmt.Errorf("FIPS mode requires TLS; use --ssl-mode required or stricter")
+		}
+		tlsConfig.InsecureSkipVerify = true
+	case SSLVerifyCA:
+		// The chain is verified without the host name, which is often an IP or proxy endpoint
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("the server sent no certificate")
+			}
+			opts := x509.VerifyOptions{Roots: tlsConfig.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	case SSLVerifyIdentity:
+	default:
+		return nil, fmt.Errorf("unsupported SSL mode %q: use disabled, preferred, required, verify-ca or verify-identity", p.Mode)
+	}
+
+	return func(cfg *mysql.Config) error {
+		c := tlsConfig.Clone()
+		if p.Mode == SSLVerifyIdentity {
+			host, _, err := net.SplitHostPort(cfg.Addr)
+			if err != nil {
+				host = cfg.Addr
+			}
+			c.ServerName = host
+		}
+		cfg.TLS, cfg.AllowFallbackToPlaintext = c, p.Mode == SSLPreferred
+		return nil
+	}, nil
+}
+
+// postgresSSLModes maps SSL modes to the sslmode of the postgres driver, which cannot
+// fall back to plaintext; without --ssl-mode, connections are plaintext as with MySQL
+var postgresSSLModes = map[string]string{
+	"":                "disable",
+	SSLDisabled:       "disable",
+	SSLRequired:       "require",
+	SSLVerifyCA:       "verify-ca",
+	SSLVerifyIdentity: "verify-full",
+}
+
+// PostgresParams returns the DSN parameters applying the policy with the postgres
+// driver, which reads its TLS settings from the DSN only
+func (p TLSPolicy) PostgresParams() (url.Values, error) {
+	mode, ok := postgresSSLModes[p.Mode]
+	if !ok {
+		return nil, fmt.Errorf("SSL mode %q is not supported with PostgreSQL: use disabled, required, verify-ca or verify-identity", p.Mode)
+	}
+	if p.MinVersion != "" || p.FIPS {
+		return nil, fmt.Errorf("--tls-min-version and --fips are not supported with PostgreSQL")
+	}
+	params := url.Values{"sslmode": {mode}}
+	if p.CAFile != "" {
+		params.Set("sslrootcert", p.CAFile)
+	}
+	return params, nil
+}
+
+// mssqlEncrypt maps SSL modes to the encrypt and TrustServerCertificate parameters of
+// the sqlserver driver, which always checks the host name of a verified certificate
+var mssqlEncrypt = map[string][2]string{
+	SSLDisabled:       {"disable", "false"},
+	SSLRequired:       {"true", "true"},
+	SSLVerifyIdentity: {"true", "false"},
+}
+
+// MSSQLParams returns the DSN parameters applying the policy with the sqlserver driver;
+// without --ssl-mode, the driver encrypts the login only
+func (p TLSPolicy) MSSQLParams() (url.Values, error) {
+	params := url.Values{}
+	if p.Mode == "" {
+		return params, nil
+	}
+	encrypt, ok := mssqlEncrypt[p.Mode]
+	if !ok {
+		return nil, fmt.Errorf("SSL mode %q is not supported with SQL Server: use disabled, required or verify-identity", p.Mode)
+	}
+	if p.FIPS {
+		return nil, fmt.Errorf("--fips is not supported with SQL Server")
+	}
+	params.Set("encrypt", encrypt[0])
+	params.Set("TrustServerCertificate", encrypt[1])
+	if p.MinVersion != "" {
+		params.Set("tlsmin", p.MinVersion)
+	}
+	if p.CAFile != "" {
+		params.Set("certificate", p.CAFile)
+	}
+	return params, nil
+}
+
+// TLSStatus returns the TLS version and cipher of a connection as the server reports
+// them, both empty when it is not encrypted
+func (db *DB) TLSStatus() (version, cipher string, err error) {
+	// A SQLite database is a local file
+	if db.driver == DriverSQLite {
+		return "", "", nil
+	}
+	if db.driver == DriverMSSQL {
+		// SQL Server reports whether a connection is encrypted, but not how
+		var encrypted string
+		err := db.QueryRow("SELECT encrypt_option FROM sys.dm_exec_connections WHERE session_id = @@SPID").Scan(&encrypted)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read TLS status: %w", err)
+		}
+		if encrypted == "TRUE" {
+			version = "TLS"
+		}
+		return version, "", nil
+	}
+	if db.driver == DriverPostgres {
+		err := db.QueryRow("SELECT COALESCE(version, ''), COALESCE(cipher, '') FROM pg_stat_ssl WHERE pid = pg_backend_pid()").Scan(&version, &cipher)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read TLS status: %w", err)
+		}
+		return version, cipher, nil
+	}
+	rows, err := db.Query("SHOW SESSION STATUS WHERE Variable_name IN ('Ssl_version', 'Ssl_cipher')")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read TLS status: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return "", "", fmt.Errorf("failed to read TLS status: %w", err)
+		}
+		switch name {
+		case "Ssl_version":
+			version = value
+		case "Ssl_cipher":
+			cipher = value
+		}
+	}
+	return version, cipher, rows.Err()
+}
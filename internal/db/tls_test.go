@@ -0,0 +1,53 @@
+package db
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestTLSPolicy(t *testing.T) {
+	apply := func(p TLSPolicy) (*mysql.Config, error) {
+		opt, err := p.Option()
+		if err != nil {
+			return nil, err
+		}
+		cfg, _ := mysql.ParseDSN("root:secret@tcp(db.example.com:3306)/app?tls=skip-verify")
+		return cfg, cfg.Apply(opt)
+	}
+
+	cfg, err := apply(TLSPolicy{Mode: SSLVerifyIdentity, FIPS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLS.InsecureSkipVerify || cfg.TLS.ServerName != "db.example.com" || cfg.TLS.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected verification of db.example.com with TLS 1.2 or later, got %+v", cfg.TLS)
+	}
+	for _, suite := range cfg.TLS.CipherSuites {
+		if tls.CipherSuiteName(suite) == "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256" {
+			t.Errorf("expected FIPS cipher suites only, got %v", cfg.TLS.CipherSuites)
+		}
+	}
+
+	cfg, _ = apply(TLSPolicy{Mode: SSLPreferred, MinVersion: "1.3"})
+	if !cfg.AllowFallbackToPlaintext || cfg.TLS.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected opportunistic TLS 1.3, got %+v", cfg.TLS)
+	}
+	cfg, _ = apply(TLSPolicy{Mode: SSLDisabled})
+	if cfg.TLS != nil {
+		t.Errorf("expected TLS to be disabled, got %+v", cfg.TLS)
+	}
+
+	for _, p := range []TLSPolicy{
+		{Mode: SSLPreferred, FIPS: true},
+		{Mode: SSLDisabled, FIPS: true},
+		{Mode: "strict"},
+		{Mode: SSLRequired, MinVersion: "1.0"},
+		{Mode: SSLVerifyCA, CAFile: "missing.pem"},
+	} {
+		if _, err := p.Option(); err == nil {
+			t.Errorf("expected %+v to be refused", p)
+		}
+	}
+}
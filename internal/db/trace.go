@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+)
+
+// discard is the logger of a DB opened without one
+var discard = slog.New(slog.DiscardHandler)
+
+// SetTraceArgs logs the values bound to statements along with them; they may include
+// secrets, e.g. the columns of a data file loaded by a script, so only their number is
+// logged by default
+func (db *DB) SetTraceArgs(on bool) {
+	db.traceArgs = on
+}
+
+// Trace logs a statement sent outside the methods of DB, e.g. on a *sql.Tx
+func (db *DB) Trace(query string, args ...interface{}) {
+	db.trace(context.Background(), query, args)
+}
+
+// trace logs query on one line at console.LevelTrace, followed by its arguments
+func (db *DB) trace(ctx context.Context, query string, args []interface{}) {
+	if !db.logger.Enabled(ctx, console.LevelTrace) {
+		return
+	}
+	msg := "SQL: " + strings.Join(strings.Fields(query), " ")
+	switch {
+	case len(args) > 0 && db.traceArgs:
+		msg += fmt.Sprintf(" %v", args)
+	case len(args) > 0:
+		msg += fmt.Sprintf(" [%d redacted]", len(args))
+	}
+	db.logger.Log(ctx, console.LevelTrace, msg)
+}
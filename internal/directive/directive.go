@@ -0,0 +1,433 @@
+// Package directive parses structured comment headers in SQL scripts.
+//
+// Directives are SQL line comments at the top of a script:
+//
+//	-- dbmig: noTransaction
+//	-- dbmig: timeout=10m
+//	-- dbmig: environments=staging,prod
+//	-- dbmig: load table=countries file=data/countries.csv
+//
+// Parsing stops at the first line that is neither blank nor a comment. The dbmate
+// comments "-- migrate:no-transaction" and "-- migrate:timeout 10m" are read as
+// noTransaction and timeout.
+package directive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prefix introduces a directive comment
+const Prefix = "-- dbmig:"
+
+// MigratePrefix introduces the dbmate comments accepted for scripts ported from dbmate
+const MigratePrefix = "-- migrate:"
+
+// migrateKeys maps the dbmate comments to directive keys; others, such as the
+// migrate:up and migrate:down section markers, are ignored
+var migrateKeys = map[string]string{
+	"no-transaction": KeyNoTransaction,
+	"timeout":        KeyTimeout,
+}
+
+// Known directive keys (compared case-insensitively)
+const (
+	KeyNoTransaction = "noTransaction"
+	KeyTimeout       = "timeout"
+	KeyEnvironments  = "environments"
+	KeyOnError       = "onError"
+	KeyParallelGroup = "parallelGroup"
+	KeyRequires      = "requires"
+	KeySkipIf        = "skip-if"
+	KeyInclude       = "include" // Expanded when the script is read, see migration.expandIncludes
+	KeyAfter         = "after"
+	KeyBackfill      = "backfill"
+	KeyTags          = "tags"
+	KeyLoad          = "load"
+)
+
+// DefaultBackfillBatch is the number of key values updated per backfill chunk
+const DefaultBackfillBatch = 1000
+
+// DefaultLoadBatch is the number of rows per INSERT when a load falls back to inserts
+const DefaultLoadBatch = 500
+
+// Error policies accepted by onError
+const (
+	OnErrorFail     = "fail"
+	OnErrorContinue = "continue"
+	OnErrorRetry    = "retry"
+)
+
+// Directive is a single parsed directive line
+type Directive struct {
+	Key   string // Canonical key name
+	Value string // Everything after "=" (or after the key and whitespace), trimmed
+	Line  int    // 1-based line number in the script
+}
+
+// Set holds the directives declared by one script
+type Set struct {
+	NoTransaction bool
+	Timeout       time.Duration
+	Environments  []string
+	OnError       string // fail, continue or retry (see Retries)
+	Retries       int    // Attempts after the first when OnError is retry
+	ParallelGroup string
+	Requires      []string
+	SkipIf        string   // Query whose non-zero result skips the script
+	After         []string // Scripts that must be applied before this one
+	Backfill      *Backfill
+	Tags          []string // Labels for selective runs (--tags, --skip-tags)
+	Loads         []*Load  // Data files loaded after the script body, in order
+
+	// Entries lists every directive in declaration order
+	Entries []Directive
+}
+
+// Backfill describes a chunked UPDATE of one column; the script body is the new value expression
+type Backfill struct {
+	Table  string
+	Column string
+	Key    string        // Integer key the chunks are ranged over (default id)
+	Batch  int           // Key values per chunk
+	Sleep  time.Duration // Pause between chunks
+}
+
+// Load describes a CSV file loaded into a table
+type Load struct {
+	Table     string
+	File      string   // Relative to the script's directory
+	Columns   []string // Target columns; taken from the header row when empty
+	Header    bool     // The first row holds column names and is not loaded
+	Delimiter rune
+	Null      string // Field value loaded as NULL
+	Batch     int    // Rows per INSERT when LOAD DATA LOCAL INFILE is unavailable
+}
+
+// keys maps lower-cased keys to their canonical spelling
+var keys = map[string]string{}
+
+func init() {
+	for _, k := range []string{KeyNoTransaction, KeyTimeout, KeyEnvironments, KeyOnError, KeyParallelGroup, KeyRequires, KeySkipIf, KeyInclude, KeyAfter, KeyBackfill, KeyTags, KeyLoad} {
+		keys[strings.ToLower(k)] = k
+	}
+}
+
+// Parse extracts the directive header from script content
+func Parse(content []byte) (Set, error) {
+	var set Set
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			// Header ends at the first SQL statement
+			break
+		}
+		var d Directive
+		switch {
+		case strings.HasPrefix(line, MigratePrefix):
+			body := strings.TrimSpace(strings.TrimPrefix(line, MigratePrefix))
+			key, value, _ := strings.Cut(body, " ")
+			canonical, ok := migrateKeys[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			d = Directive{Key: canonical, Value: strings.TrimSpace(value), Line: lineNo}
+		case strings.HasPrefix(line, Prefix):
+			var err error
+			if d, err = parseLine(strings.TrimSpace(strings.TrimPrefix(line, Prefix)), lineNo); err != nil {
+				return Set{}, err
+			}
+		default:
+			continue
+		}
+		if err := set.apply(d); err != nil {
+			return Set{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		set.Entries = append(set.Entries, d)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Set{}, fmt.Errorf("failed to read directives: %w", err)
+	}
+
+	return set, nil
+}
+
+// parseLine splits "key=value", "key value" or "key" into a Directive
+func parseLine(body string, lineNo int) (Directive, error) {
+	if body == "" {
+		return Directive{}, fmt.Errorf("line %d: empty directive", lineNo)
+	}
+
+	end := strings.IndexAny(body, "= \t")
+	key, value := body, ""
+	if end >= 0 {
+		key = body[:end]
+		value = strings.TrimSpace(body[end+1:])
+	}
+
+	canonical, ok := keys[strings.ToLower(key)]
+	if !ok {
+		return Directive{}, fmt.Errorf("line %d: unknown directive %q", lineNo, key)
+	}
+
+	return Directive{Key: canonical, Value: value, Line: lineNo}, nil
+}
+
+// apply sets the typed field for a directive, validating its value
+func (s *Set) apply(d Directive) error {
+	switch d.Key {
+	case KeyNoTransaction:
+		if d.Value == "" {
+			s.NoTransaction = true
+			return nil
+		}
+		v, err := strconv.ParseBool(d.Value)
+		if err != nil {
+			return fmt.Errorf("%s expects true or false, got %q", d.Key, d.Value)
+		}
+		s.NoTransaction = v
+	case KeyTimeout:
+		v, err := time.ParseDuration(d.Value)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("%s expects a positive duration such as 30s or 10m, got %q", d.Key, d.Value)
+		}
+		s.Timeout = v
+	case KeyEnvironments:
+		s.Environments = splitList(d.Value)
+		if len(s.Environments) == 0 {
+			return fmt.Errorf("%s expects a comma-separated list", d.Key)
+		}
+	case KeyOnError:
+		policy, retries, err := parseOnError(d.Value)
+		if err != nil {
+			return err
+		}
+		s.OnError = policy
+		s.Retries = retries
+	case KeyParallelGroup:
+		if d.Value == "" {
+			return fmt.Errorf("%s expects a group name", d.Key)
+		}
+		s.ParallelGroup = d.Value
+	case KeyRequires:
+		s.Requires = append(s.Requires, splitList(d.Value)...)
+		if len(s.Requires) == 0 {
+			return fmt.Errorf("%s expects a comma-separated list", d.Key)
+		}
+	case KeySkipIf:
+		if d.Value == "" {
+			return fmt.Errorf("%s expects a query", d.Key)
+		}
+		s.SkipIf = d.Value
+	case KeyAfter:
+		s.After = append(s.After, splitList(d.Value)...)
+		if len(s.After) == 0 {
+			return fmt.Errorf("%s expects a comma-separated list of script names", d.Key)
+		}
+	case KeyTags:
+		s.Tags = append(s.Tags, splitList(d.Value)...)
+		if len(s.Tags) == 0 {
+			return fmt.Errorf("%s expects a comma-separated list", d.Key)
+		}
+	case KeyBackfill:
+		b, err := parseBackfill(d.Value)
+		if err != nil {
+			return err
+		}
+		s.Backfill = b
+	case KeyLoad:
+		l, err := parseLoad(d.Value)
+		if err != nil {
+			return err
+		}
+		s.Loads = append(s.Loads, l)
+	}
+	return nil
+}
+
+// parseOnError parses fail, continue or retry:N
+func parseOnError(value string) (string, int, error) {
+	switch {
+	case value == OnErrorFail || value == OnErrorContinue:
+		return value, 0, nil
+	case strings.HasPrefix(value, OnErrorRetry+":"):
+		n, err := strconv.Atoi(strings.TrimPrefix(value, OnErrorRetry+":"))
+		if err != nil || n < 1 {
+			return "", 0, fmt.Errorf("%s retry count must be a positive integer, got %q", KeyOnError, value)
+		}
+		return OnErrorRetry, n, nil
+	}
+	return "", 0, fmt.Errorf("%s expects fail, continue or retry:N, got %q", KeyOnError, value)
+}
+
+// identifierPattern matches plain and schema-qualified SQL identifiers
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_$]+(\.[A-Za-z0-9_$]+)?$`)
+
+// parseBackfill parses "table=orders column=status batch=10000 sleep=100ms key=id"
+func parseBackfill(value string) (*Backfill, error) {
+	b := &Backfill{Key: "id", Batch: DefaultBackfillBatch}
+	for _, field := range strings.Fields(value) {
+		name, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return nil, fmt.Errorf("%s expects name=value settings, got %q", KeyBackfill, field)
+		}
+		switch strings.ToLower(name) {
+		case "table":
+			b.Table = v
+		case "column":
+			b.Column = v
+		case "key":
+			b.Key = v
+		case "batch":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s batch must be a positive integer, got %q", KeyBackfill, v)
+			}
+			b.Batch = n
+		case "sleep":
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("%s sleep expects a duration such as 100ms, got %q", KeyBackfill, v)
+			}
+			b.Sleep = d
+		default:
+			return nil, fmt.Errorf("%s has unknown setting %q", KeyBackfill, name)
+		}
+	}
+
+	if b.Table == "" || b.Column == "" {
+		return nil, fmt.Errorf("%s requires table and column", KeyBackfill)
+	}
+	for _, ident := range []string{b.Table, b.Column, b.Key} {
+		if !identifierPattern.MatchString(ident) {
+			return nil, fmt.Errorf("%s: invalid identifier %q", KeyBackfill, ident)
+		}
+	}
+	return b, nil
+}
+
+// parseLoad parses "table=countries file=data/countries.csv header=true delimiter=; null=NULL batch=500 columns=a,b"
+func parseLoad(value string) (*Load, error) {
+	l := &Load{Header: true, Delimiter: ',', Null: `\N`, Batch: DefaultLoadBatch}
+	delimiterSet := false
+	for _, field := range strings.Fields(value) {
+		name, v, ok := strings.Cut(field, "=")
+		if !ok || v == "" {
+			return nil, fmt.Errorf("%s expects name=value settings, got %q", KeyLoad, field)
+		}
+		switch strings.ToLower(name) {
+		case "table":
+			l.Table = v
+		case "file":
+			l.File = v
+		case "columns":
+			l.Columns = splitList(v)
+		case "header":
+			h, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s header expects true or false, got %q", KeyLoad, v)
+			}
+			l.Header = h
+		case "delimiter":
+			switch {
+			case v == "tab":
+				l.Delimiter = '\t'
+			case len([]rune(v)) == 1:
+				l.Delimiter = []rune(v)[0]
+			default:
+				return nil, fmt.Errorf("%s delimiter expects a single character or tab, got %q", KeyLoad, v)
+			}
+			delimiterSet = true
+		case "null":
+			l.Null = v
+		case "batch":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s batch must be a positive integer, got %q", KeyLoad, v)
+			}
+			l.Batch = n
+		default:
+			return nil, fmt.Errorf("%s has unknown setting %q", KeyLoad, name)
+		}
+	}
+
+	if l.Table == "" || l.File == "" {
+		return nil, fmt.Errorf("%s requires table and file", KeyLoad)
+	}
+	switch strings.ToLower(filepath.Ext(l.File)) {
+	case ".csv":
+	case ".tsv":
+		if !delimiterSet {
+			l.Delimiter = '\t'
+		}
+	default:
+		return nil, fmt.Errorf("%s supports .csv and .tsv files, got %q", KeyLoad, l.File)
+	}
+	for _, ident := range append([]string{l.Table}, l.Columns...) {
+		if !identifierPattern.MatchString(ident) {
+			return nil, fmt.Errorf("%s: invalid identifier %q", KeyLoad, ident)
+		}
+	}
+	return l, nil
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Has reports whether the script declared the given directive
+func (s Set) Has(key string) bool {
+	for _, d := range s.Entries {
+		if strings.EqualFold(d.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTag reports whether the script carries the given tag (case-insensitive)
+func (s Set) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsEnvironment reports whether the script may run in env
+// Scripts without an environments directive run everywhere
+func (s Set) AllowsEnvironment(env string) bool {
+	if len(s.Environments) == 0 {
+		return true
+	}
+	for _, e := range s.Environments {
+		if strings.EqualFold(e, env) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package directive
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParse_Header tests parsing a typical directive header
+func TestParse_Header(t *testing.T) {
+	content := `-- Adds the orders index
+-- dbmig: noTransaction
+-- dbmig: timeout=10m
+-- dbmig: environments=staging, prod
+-- dbmig: onError=retry:3
+-- dbmig: parallelGroup=indexes
+-- dbmig: requires=001_create_users.sql,002_create_posts.sql
+
+CREATE INDEX idx_orders_status ON orders(status);
+-- dbmig: timeout=1s
+`
+	set, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if !set.NoTransaction {
+		t.Error("expected NoTransaction")
+	}
+	if set.Timeout != 10*time.Minute {
+		t.Errorf("expected timeout 10m (directives after SQL are ignored), got %v", set.Timeout)
+	}
+	if strings.Join(set.Environments, "|") != "staging|prod" {
+		t.Errorf("unexpected environments: %v", set.Environments)
+	}
+	if set.OnError != OnErrorRetry || set.Retries != 3 {
+		t.Errorf("expected retry:3, got %s:%d", set.OnError, set.Retries)
+	}
+	if set.ParallelGroup != "indexes" {
+		t.Errorf("unexpected parallel group: %s", set.ParallelGroup)
+	}
+	if len(set.Requires) != 2 || set.Requires[0] != "001_create_users.sql" {
+		t.Errorf("unexpected requires: %v", set.Requires)
+	}
+	if len(set.Entries) != 6 || set.Entries[1].Line != 3 {
+		t.Errorf("unexpected entries: %+v", set.Entries)
+	}
+	if !set.AllowsEnvironment("PROD") || set.AllowsEnvironment("dev") {
+		t.Error("environment matching is wrong")
+	}
+}
+
+// TestParse_SkipIf tests that skip-if keeps the whole query, including spaces and "="
+func TestParse_SkipIf(t *testing.T) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'users'"
+	set, err := Parse([]byte("-- dbmig: skip-if=" + query + "\nCREATE TABLE users (id INT);"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if set.SkipIf != query {
+		t.Errorf("unexpected skip-if query: %q", set.SkipIf)
+	}
+}
+
+// TestParse_Backfill tests backfill settings and their defaults
+func TestParse_Backfill(t *testing.T) {
+	set, err := Parse([]byte("-- dbmig: backfill table=shop.orders column=status batch=10000 sleep=50ms\n'open'"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := Backfill{Table: "shop.orders", Column: "status", Key: "id", Batch: 10000, Sleep: 50 * time.Millisecond}
+	if set.Backfill == nil || *set.Backfill != want {
+		t.Errorf("expected %+v, got %+v", want, set.Backfill)
+	}
+}
+
+// TestParse_Load tests load settings, their defaults and repeated loads
+func TestParse_Load(t *testing.T) {
+	set, err := Parse([]byte("-- dbmig: load table=countries file=data/countries.csv\n-- dbmig: load table=regions file=data/regions.tsv header=false columns=id,name null=NULL batch=100\nDELETE FROM countries;"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(set.Loads) != 2 {
+		t.Fatalf("expected 2 loads, got %d", len(set.Loads))
+	}
+	want := Load{Table: "countries", File: "data/countries.csv", Header: true, Delimiter: ',', Null: `\N`, Batch: DefaultLoadBatch}
+	if got := *set.Loads[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	want = Load{Table: "regions", File: "data/regions.tsv", Columns: []string{"id", "name"}, Delimiter: '\t', Null: "NULL", Batch: 100}
+	if got := *set.Loads[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+// TestParse_Tags tests tag lists and case-insensitive matching
+func TestParse_Tags(t *testing.T) {
+	set, err := Parse([]byte("-- dbmig: tags=reporting, slow\nCREATE INDEX i ON t(c);"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !set.HasTag("SLOW") || !set.HasTag("reporting") || set.HasTag("fast") {
+		t.Errorf("unexpected tags: %v", set.Tags)
+	}
+}
+
+// TestParse_Errors tests that malformed directives are rejected
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", "-- dbmig: noTransactoin\nSELECT 1;", "unknown directive"},
+		{"bad timeout", "-- dbmig: timeout=soon\nSELECT 1;", "positive duration"},
+		{"bad policy", "-- dbmig: onError=ignore\nSELECT 1;", "fail, continue or retry:N"},
+		{"bad retry count", "-- dbmig: onError=retry:0\nSELECT 1;", "positive integer"},
+		{"empty", "-- dbmig:\nSELECT 1;", "empty directive"},
+		{"backfill without column", "-- dbmig: backfill table=orders\nSELECT 1;", "requires table and column"},
+		{"backfill bad batch", "-- dbmig: backfill table=orders column=status batch=0\nSELECT 1;", "positive integer"},
+		{"backfill bad identifier", "-- dbmig: backfill table=orders;DROP column=status\nSELECT 1;", "invalid identifier"},
+		{"load without file", "-- dbmig: load table=countries\nSELECT 1;", "requires table and file"},
+		{"load parquet", "-- dbmig: load table=countries file=countries.parquet\nSELECT 1;", "supports .csv and .tsv"},
+		{"load bad column", "-- dbmig: load table=countries file=c.csv columns=id,na-me\nSELECT 1;", "invalid identifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestParse_Migrate tests the dbmate spellings of noTransaction and timeout
+func TestParse_Migrate(t *testing.T) {
+	set, err := Parse([]byte("-- migrate:up\n-- migrate:no-transaction\n-- migrate:timeout 10m\nCREATE INDEX CONCURRENTLY idx ON t (c);"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !set.NoTransaction || set.Timeout != 10*time.Minute || len(set.Entries) != 2 || set.Entries[0].Key != KeyNoTransaction {
+		t.Errorf("expected noTransaction and a timeout, got %+v", set)
+	}
+	if _, err := Parse([]byte("-- migrate:timeout soon\nSELECT 1;")); err == nil {
+		t.Error("expected an invalid timeout to be rejected")
+	}
+}
+
+// TestParse_NoHeader tests scripts without directives
+func TestParse_NoHeader(t *testing.T) {
+	set, err := Parse([]byte("CREATE TABLE t (id INT);"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(set.Entries) != 0 || set.NoTransaction {
+		t.Errorf("expected empty set, got %+v", set)
+	}
+}
@@ -1,37 +1,93 @@
 package git
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/directive"
 )
 
 // Git provides Git CLI operations
 type Git struct {
 	workDir string
+	ctx     context.Context
+	// logger receives every git command run at console.LevelTrace, e.g. for -vv
+	logger *slog.Logger
+
+	// prefix caches Prefix, which is asked for every discovered script
+	mu     sync.Mutex
+	prefix *string
+
+	// catFile serves ReadFile, see catFileIdle
+	catFileMu sync.Mutex
+	catFile   *catFile
+}
+
+// catFileIdle is how long the git cat-file --batch process of ReadFile outlives its
+// last read; a run reads all its scripts through one process instead of one per file
+const catFileIdle = time.Second
+
+// New creates a new Git instance for the given working directory; logger receives every
+// git command it runs (optional)
+func New(workDir string, logger *slog.Logger) *Git {
+	return NewContext(context.Background(), workDir, logger)
 }
 
-// New creates a new Git instance for the given working directory
-func New(workDir string) *Git {
-	return &Git{workDir: workDir}
+// NewContext creates a Git instance whose commands are killed once ctx is done
+func NewContext(ctx context.Context, workDir string, logger *slog.Logger) *Git {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Git{workDir: workDir, ctx: ctx, logger: logger}
 }
 
 // run executes a git command and returns the output
 func (g *Git) run(args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-	cmd.Dir = g.workDir
-	output, err := cmd.Output()
+	output, err := g.output(args...)
+	return strings.TrimSpace(string(output)), err
+}
+
+// output executes a git command and returns its output as is
+func (g *Git) output(args ...string) ([]byte, error) {
+	output, err := g.command(args...).Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), string(exitErr.Stderr))
+			return nil, fmt.Errorf("git %s failed: %s", strings.Join(args, " "), string(exitErr.Stderr))
 		}
-		return "", fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+		return nil, fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return output, nil
+}
+
+// command prepares a git command in the working directory, tracing it
+func (g *Git) command(args ...string) *exec.Cmd {
+	if g.logger.Enabled(g.ctx, console.LevelTrace) {
+		redacted := make([]string, len(args))
+		for i, arg := range args {
+			redacted[i] = RedactURL(arg)
+		}
+		g.logger.Log(g.ctx, console.LevelTrace, "git "+strings.Join(redacted, " "), "dir", g.workDir)
+	}
+	cmd := exec.CommandContext(g.ctx, "git", args...)
+	cmd.Dir = g.workDir
+	// Missing credentials fail instead of waiting for a prompt nobody answers
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
 }
 
 // GetCurrentCommit returns the current HEAD commit hash
@@ -39,6 +95,155 @@ func (g *Git) GetCurrentCommit() (string, error) {
 	return g.run("rev-parse", "HEAD")
 }
 
+// ResolveCommit returns the full hash of the commit a ref (hash, hash prefix, branch or
+// tag) names
+func (g *Git) ResolveCommit(ref string) (string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("unknown commit %s", ref)
+	}
+	commit, err := g.run("rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %s: %w", ref, err)
+	}
+	return commit, nil
+}
+
+// ReadFile returns the content of a file at a commit, with path relative to the
+// repository root, as committed; a missing file is an fs.ErrNotExist
+func (g *Git) ReadFile(commit, path string) ([]byte, error) {
+	object := commit + ":" + path
+	if strings.HasPrefix(object, "-") {
+		return nil, fmt.Errorf("invalid commit %s", commit)
+	}
+	if strings.ContainsAny(object, "\r\n") {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	g.catFileMu.Lock()
+	defer g.catFileMu.Unlock()
+	if g.catFile == nil {
+		c, err := g.startCatFile()
+		if err != nil {
+			return nil, err
+		}
+		g.catFile = c
+	}
+	c := g.catFile
+	c.idle.Stop()
+	if g.logger.Enabled(g.ctx, console.LevelTrace) {
+		g.logger.Log(g.ctx, console.LevelTrace, "git cat-file --batch: "+object, "dir", g.workDir)
+	}
+	content, err := c.read(object)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.idle.Reset(catFileIdle)
+		return nil, fmt.Errorf("%s does not exist at commit %s: %w", path, commit, err)
+	}
+	if err != nil {
+		// The process is out of step with its requests; the next read starts a new one
+		g.catFile = nil
+		c.close()
+		return nil, fmt.Errorf("failed to read %s at commit %s: %w", path, commit, err)
+	}
+	c.idle.Reset(catFileIdle)
+	return content, nil
+}
+
+// catFile is a running git cat-file --batch, which answers each object name written to
+// its input with the object's type, size and content
+type catFile struct {
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	out  *bufio.Reader
+	idle *time.Timer
+}
+
+// startCatFile starts the cat-file process of ReadFile, which exits once idle for
+// catFileIdle; the caller holds catFileMu
+func (g *Git) startCatFile() (*catFile, error) {
+	cmd := g.command("cat-file", "--batch")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+	c := &catFile{cmd: cmd, in: in, out: bufio.NewReader(out)}
+	c.idle = time.AfterFunc(catFileIdle, func() {
+		g.catFileMu.Lock()
+		defer g.catFileMu.Unlock()
+		if g.catFile == c {
+			g.catFile = nil
+			c.close()
+		}
+	})
+	return c, nil
+}
+
+// read returns the content of a blob; a missing object is an fs.ErrNotExist
+func (c *catFile) read(object string) ([]byte, error) {
+	if _, err := io.WriteString(c.in, object+"\n"); err != nil {
+		return nil, err
+	}
+	header, err := c.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(header, " missing\n") || strings.HasSuffix(header, " ambiguous\n") {
+		return nil, fs.ErrNotExist
+	}
+	// <oid> <type> <size>
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected git cat-file output %q", header)
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected git cat-file output %q", header)
+	}
+	// The content is followed by a newline
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(c.out, content); err != nil {
+		return nil, err
+	}
+	if fields[1] != "blob" {
+		return nil, fmt.Errorf("%s is a %s, not a file", object, fields[1])
+	}
+	return content[:size], nil
+}
+
+// close ends the process by closing its input
+func (c *catFile) close() {
+	c.idle.Stop()
+	c.in.Close()
+	c.cmd.Wait()
+}
+
+// Prefix returns the working directory's path relative to the repository root
+// ("" at the root, otherwise ending in "/")
+func (g *Git) Prefix() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.prefix != nil {
+		return *g.prefix, nil
+	}
+	prefix, err := g.run("rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	g.prefix = &prefix
+	return prefix, nil
+}
+
+// TopLevel returns the absolute path of the repository root
+func (g *Git) TopLevel() (string, error) {
+	return g.run("rev-parse", "--show-toplevel")
+}
+
 // GetEmptyTreeHash returns the hash of an empty tree (for initial comparison)
 func (g *Git) GetEmptyTreeHash() (string, error) {
 	return g.run("hash-object", "-t", "tree", "/dev/null")
@@ -105,11 +310,60 @@ func (g *Git) DiffFileStatus(fromCommit, toCommit string) (map[string]string, er
 	return result, nil
 }
 
+// DownScriptSuffix marks the undo script paired with an up script
+// (e.g. 001_create_users.down.sql reverts 001_create_users.sql)
+const DownScriptSuffix = ".down.sql"
+
+// TemplateSuffix marks scripts rendered with text/template before execution
+// (e.g. 006_create_reports.sql.tmpl)
+const TemplateSuffix = ".sql.tmpl"
+
+// IsScript reports whether a file is a SQL script or SQL template
+func IsScript(file string) bool {
+	return strings.HasSuffix(file, ".sql") || strings.HasSuffix(file, TemplateSuffix)
+}
+
+// IsTemplate reports whether a script file is a SQL template
+func IsTemplate(file string) bool {
+	return strings.HasSuffix(file, TemplateSuffix)
+}
+
+// IsDownScript reports whether a script file is a down (undo) script
+func IsDownScript(file string) bool {
+	return strings.HasSuffix(file, DownScriptSuffix) || strings.HasSuffix(file, ".down"+TemplateSuffix)
+}
+
+// RepeatablePrefix marks scripts that re-run whenever their content changes
+// (e.g. R__refresh_views.sql)
+const RepeatablePrefix = "R__"
+
+// IsRepeatable reports whether a script file is a repeatable script
+func IsRepeatable(file string) bool {
+	return strings.HasPrefix(filepath.Base(file), RepeatablePrefix)
+}
+
+// ListFiles returns all files tracked at the given commit under the working directory
+// Paths are relative to the working directory
+func (g *Git) ListFiles(commit string) ([]string, error) {
+	output, err := g.run("ls-tree", "-r", "--name-only", commit, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	if output == "" {
+		return []string{}, nil
+	}
+
+	return strings.Split(output, "\n"), nil
+}
+
 // ScriptInfo holds information about a script file
 type ScriptInfo struct {
-	Name      string
-	Path      string
-	Timestamp time.Time
+	Name       string
+	Path       string
+	Timestamp  time.Time
+	Directives directive.Set // Parsed "-- dbmig:" header, filled in before execution
+	Tickets    []string      // Issue IDs from the name and commit messages, filled in before execution
 }
 
 // GetFileCommitTimestamp returns the commit timestamp for a file
@@ -133,34 +387,100 @@ func (g *Git) GetFileCommitTimestamp(filepath string) (time.Time, error) {
 	return time.Unix(timestamp, 0), nil
 }
 
+// CommitMessages returns the messages of the commits that changed a path, newest first
+// Relative paths are taken from the repository root, like ScriptInfo.Path
+func (g *Git) CommitMessages(path string) ([]string, error) {
+	if !filepath.IsAbs(path) {
+		path = ":(top)" + path
+	}
+	output, err := g.run("log", "--format=%B%x00", "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	for _, message := range strings.Split(output, "\x00") {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// AddedTimestamps returns when each file under the working directory was added between
+// two commits, following renames, in one pass over the history; fromCommit "" scans all
+// of it. Paths are relative to the repository root; files added before fromCommit are
+// missing
+func (g *Git) AddedTimestamps(fromCommit, toCommit string) (map[string]time.Time, error) {
+	revs := toCommit
+	if fromCommit != "" {
+		revs = fromCommit + ".." + toCommit
+	}
+	output, err := g.run("log", "--reverse", "--format=%x00%ct", "--name-status", "-M", "--diff-filter=AR", revs, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	added := make(map[string]time.Time)
+	var timestamp time.Time
+	for _, line := range strings.Split(output, "\n") {
+		if seconds, ok := strings.CutPrefix(line, "\x00"); ok {
+			unix, err := strconv.ParseInt(seconds, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git log output %q", line)
+			}
+			timestamp = time.Unix(unix, 0)
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		switch {
+		case len(fields) == 2 && fields[0] == "A":
+			added[fields[1]] = timestamp
+		case len(fields) == 3 && strings.HasPrefix(fields[0], "R"):
+			// A renamed file keeps the time it was first added
+			if from, ok := added[fields[1]]; ok {
+				added[fields[2]] = from
+			}
+		}
+	}
+	return added, nil
+}
+
 // GetChangedScripts returns SQL scripts changed between commits, sorted by commit timestamp
+// Timestamps come from one scan of the commits in between; only scripts added earlier
+// are looked up one by one
 func (g *Git) GetChangedScripts(fromCommit, toCommit, scriptsDir string) ([]ScriptInfo, error) {
 	files, err := g.DiffFileNames(fromCommit, toCommit)
 	if err != nil {
 		return nil, err
 	}
+	added, err := g.AddedTimestamps(fromCommit, toCommit)
+	if err != nil {
+		return nil, err
+	}
 
 	var scripts []ScriptInfo
 
 	for _, file := range files {
-		// Only include SQL files from the scripts directory
-		if !strings.HasSuffix(file, ".sql") {
+		// Only include SQL files and SQL templates
+		if !IsScript(file) {
 			continue
 		}
 
-		// Check if file is in the scripts directory
-		relDir := filepath.Dir(file)
-		scriptsBase := filepath.Base(scriptsDir)
-		if !strings.Contains(relDir, scriptsBase) && relDir != scriptsBase && file[:len(scriptsBase)] != scriptsBase {
-			// More permissive check - just verify it ends with .sql
-			if !strings.HasSuffix(file, ".sql") {
-				continue
-			}
+		// Down scripts are only run explicitly by the down command
+		if IsDownScript(file) {
+			continue
 		}
 
-		timestamp, err := g.GetFileCommitTimestamp(file)
-		if err != nil {
-			timestamp = time.Now()
+		// Repeatable scripts are selected by checksum, not by git history
+		if IsRepeatable(file) {
+			continue
+		}
+
+		// Diff paths are relative to the repository root, not the working directory
+		timestamp, ok := added[file]
+		if !ok {
+			timestamp, _ = g.GetFileCommitTimestamp(":(top)" + file)
 		}
 
 		scripts = append(scripts, ScriptInfo{
@@ -170,8 +490,8 @@ func (g *Git) GetChangedScripts(fromCommit, toCommit, scriptsDir string) ([]Scri
 		})
 	}
 
-	// Sort by commit timestamp (oldest first)
-	sort.Slice(scripts, func(i, j int) bool {
+	// Sort by commit timestamp (oldest first); scripts of one commit keep path order
+	sort.SliceStable(scripts, func(i, j int) bool {
 		return scripts[i].Timestamp.Before(scripts[j].Timestamp)
 	})
 
@@ -211,9 +531,98 @@ func (g *Git) CheckModifications(fromCommit, toCommit string, files []string) (m
 	return modified, deleted, nil
 }
 
+// Upstream returns the remote-tracking branch of the checkout, or "" when it has none
+// (e.g. a detached HEAD)
+func (g *Git) Upstream() string {
+	upstream, err := g.run("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	if err != nil {
+		return ""
+	}
+	return upstream
+}
+
+// Pull fast-forwards the checkout to its upstream
+func (g *Git) Pull() error {
+	_, err := g.run("pull", "--ff-only", "--quiet")
+	return err
+}
+
 // IsGitRepository checks if the working directory is a git repository
+// IsClean reports whether the working directory has no uncommitted or untracked changes
+func (g *Git) IsClean() (bool, error) {
+	output, err := g.run("status", "--porcelain", "--", ".")
+	if err != nil {
+		return false, err
+	}
+	return output == "", nil
+}
+
+// CreateBundle writes the history of HEAD to a single bundle file
+func (g *Git) CreateBundle(file string) error {
+	_, err := g.run("bundle", "create", file, "HEAD")
+	return err
+}
+
+// CloneBundle checks out a bundle file written by CreateBundle into dest; git is killed
+// once ctx is done
+func CloneBundle(ctx context.Context, file, dest string, logger *slog.Logger) error {
+	_, err := NewContext(ctx, filepath.Dir(dest), logger).run("clone", "--quiet", file, dest)
+	return err
+}
+
+// Clone checks out ref (a branch, tag or commit) of the remote repository at repoURL
+// into dest, or its default branch when ref is empty. Only commits and trees are fetched
+// up front, since scripts are ordered by their history; file contents are fetched as
+// they are read. A branch is checked out tracking its remote, so Pull updates it. Git is
+// killed once ctx is done
+func Clone(ctx context.Context, repoURL, ref, dest string, logger *slog.Logger) error {
+	if strings.HasPrefix(repoURL, "-") || strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid repository %q or ref %q", RedactURL(repoURL), ref)
+	}
+	// Errors quote the command, which must not show a token in the URL
+	redact := func(err error) error {
+		if err == nil {
+			return nil
+		}
+		return errors.New(strings.ReplaceAll(err.Error(), repoURL, RedactURL(repoURL)))
+	}
+
+	if _, err := NewContext(ctx, filepath.Dir(dest), logger).run("clone", "--quiet", "--filter=blob:none", "--no-checkout", "--", repoURL, dest); err != nil {
+		return redact(err)
+	}
+	g := NewContext(ctx, dest, logger)
+	var err error
+	switch {
+	case ref == "":
+		_, err = g.run("reset", "--quiet", "--hard")
+	case g.hasRemoteBranch(ref):
+		_, err = g.run("checkout", "--quiet", "-B", ref, "--track", "origin/"+ref)
+	default:
+		_, err = g.run("checkout", "--quiet", "--detach", ref+"^{commit}")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check out %s: %w", ref, redact(err))
+	}
+	return nil
+}
+
+// hasRemoteBranch reports whether origin has a branch named name
+func (g *Git) hasRemoteBranch(name string) bool {
+	_, err := g.run("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+name)
+	return err == nil
+}
+
+// RedactURL drops the user and password or token from a repository URL, for messages
+func RedactURL(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.User == nil {
+		return repoURL
+	}
+	u.User = nil
+	return u.String()
+}
+
 func (g *Git) IsGitRepository() bool {
 	_, err := g.run("rev-parse", "--git-dir")
 	return err == nil
 }
-
@@ -0,0 +1,301 @@
+// Package liquibase reads Liquibase XML and YAML changelogs as a script source.
+//
+// Every changeset becomes one executable unit named like Liquibase identifies it:
+//
+//	db/changelog.xml::1::alice
+//
+// Raw SQL (sql, sqlFile) and the common refactorings (createTable, addColumn,
+// createIndex, ...) are converted to MySQL statements; anything else is an error
+// rather than a silently skipped change.
+package liquibase
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Changelog file suffixes; only files with these suffixes are loaded as root changelogs
+var rootSuffixes = []string{".changelog.xml", ".changelog.yaml", ".changelog.yml"}
+
+// IsChangelog reports whether a file is a root Liquibase changelog, e.g. db.changelog.xml
+func IsChangelog(file string) bool {
+	for _, suffix := range rootSuffixes {
+		if strings.HasSuffix(file, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeSet is one changeset converted to SQL
+type ChangeSet struct {
+	ID          string
+	Author      string
+	File        string // Changelog path relative to the scripts directory, slash-separated
+	SQL         string
+	RunOnChange bool // Re-run when the SQL changes instead of failing
+	RunAlways   bool // Run on every up
+	AnyChecksum bool // validCheckSum ANY: changes to an applied changeset are accepted
+}
+
+// Name returns the Liquibase identity of the changeset, used as the tracked script name
+func (c ChangeSet) Name() string {
+	return c.File + "::" + c.ID + "::" + c.Author
+}
+
+// Changelog is the result of loading a root changelog and everything it includes
+type Changelog struct {
+	ChangeSets []ChangeSet
+	Files      []string // Every changelog and sqlFile read, relative to the scripts directory
+}
+
+// node is a changelog element, read from either XML or YAML
+type node struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*node
+}
+
+func (n *node) attr(name string) string {
+	return n.attrs[name]
+}
+
+// Load reads the root changelog file (relative to dir) and the changelogs it includes
+func Load(dir, file string) (*Changelog, error) {
+	l := &loader{dir: dir, seen: make(map[string]bool), properties: make(map[string]string)}
+	if err := l.load(path.Clean(filepath.ToSlash(file))); err != nil {
+		return nil, err
+	}
+	return &Changelog{ChangeSets: l.changeSets, Files: l.files}, nil
+}
+
+type loader struct {
+	dir        string
+	seen       map[string]bool
+	properties map[string]string
+	changeSets []ChangeSet
+	files      []string
+}
+
+func (l *loader) load(file string) error {
+	if l.seen[file] {
+		return fmt.Errorf("changelog %s is included twice", file)
+	}
+	l.seen[file] = true
+	l.files = append(l.files, file)
+
+	data, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(file)))
+	if err != nil {
+		return fmt.Errorf("failed to read changelog: %w", err)
+	}
+
+	var root *node
+	switch strings.ToLower(path.Ext(file)) {
+	case ".xml":
+		root, err = parseXML(data)
+	case ".yaml", ".yml":
+		root, err = parseYAMLChangelog(data)
+	default:
+		return fmt.Errorf("changelog %s: only XML and YAML changelogs are supported", file)
+	}
+	if err != nil {
+		return fmt.Errorf("changelog %s: %w", file, err)
+	}
+	if root.name != "databaseChangeLog" {
+		return fmt.Errorf("changelog %s: expected databaseChangeLog, got %s", file, root.name)
+	}
+
+	for _, child := range root.children {
+		var err error
+		switch child.name {
+		case "changeSet":
+			err = l.changeSet(file, child)
+		case "include":
+			err = l.load(l.resolve(file, child.attr("file"), child.attr("relativeToChangelogFile")))
+		case "includeAll":
+			err = l.includeAll(l.resolve(file, child.attr("path"), child.attr("relativeToChangelogFile")))
+		case "property":
+			l.properties[child.attr("name")] = child.attr("value")
+		case "comment":
+		default:
+			err = fmt.Errorf("unsupported element %s", child.name)
+		}
+		if err != nil {
+			return fmt.Errorf("changelog %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// resolve returns an included path relative to the scripts directory
+func (l *loader) resolve(changelog, file, relative string) string {
+	if relative == "true" {
+		return path.Join(path.Dir(changelog), file)
+	}
+	return path.Clean(file)
+}
+
+// includeAll loads every XML and YAML changelog in a directory, by file name
+func (l *loader) includeAll(dir string) error {
+	entries, err := os.ReadDir(filepath.Join(l.dir, filepath.FromSlash(dir)))
+	if err != nil {
+		return fmt.Errorf("failed to read includeAll directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		switch strings.ToLower(path.Ext(entry.Name())) {
+		case ".xml", ".yaml", ".yml":
+			if !entry.IsDir() {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		if err := l.load(path.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeSet converts a changeSet element into a ChangeSet
+func (l *loader) changeSet(file string, n *node) error {
+	cs := ChangeSet{
+		ID:          n.attr("id"),
+		Author:      n.attr("author"),
+		File:        file,
+		RunOnChange: n.attr("runOnChange") == "true",
+		RunAlways:   n.attr("runAlways") == "true",
+		AnyChecksum: strings.EqualFold(n.attr("validCheckSum"), "ANY"), // YAML form
+	}
+	if cs.ID == "" {
+		return fmt.Errorf("changeSet without id")
+	}
+	// Changesets restricted to other databases do not apply
+	if dbms := n.attr("dbms"); dbms != "" && !appliesToMySQL(dbms) {
+		return nil
+	}
+
+	var header, stmts []string
+	if n.attr("runInTransaction") == "false" {
+		header = append(header, "-- dbmig: noTransaction")
+	}
+	if n.attr("failOnError") == "false" {
+		header = append(header, "-- dbmig: onError=continue")
+	}
+
+	for _, change := range n.children {
+		if dbms := change.attr("dbms"); dbms != "" && !appliesToMySQL(dbms) {
+			continue
+		}
+		switch change.name {
+		case "comment", "rollback":
+			continue
+		case "validCheckSum":
+			if strings.EqualFold(strings.TrimSpace(change.text), "ANY") {
+				cs.AnyChecksum = true
+			}
+			continue
+		case "sqlFile":
+			sqlPath := l.resolve(file, change.attr("path"), change.attr("relativeToChangelogFile"))
+			data, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(sqlPath)))
+			if err != nil {
+				return fmt.Errorf("changeSet %s: failed to read sqlFile: %w", cs.ID, err)
+			}
+			l.files = append(l.files, sqlPath)
+			stmts = append(stmts, strings.TrimSpace(string(data)))
+			continue
+		}
+
+		sql, err := changeSQL(change)
+		if err != nil {
+			return fmt.Errorf("changeSet %s: %w", cs.ID, err)
+		}
+		if sql != "" {
+			stmts = append(stmts, sql)
+		}
+	}
+	if len(stmts) == 0 {
+		return fmt.Errorf("changeSet %s has no changes", cs.ID)
+	}
+
+	for i, stmt := range stmts {
+		if !strings.HasSuffix(stmt, ";") {
+			stmts[i] = stmt + ";"
+		}
+	}
+	sql := strings.Join(append(header, stmts...), "\n") + "\n"
+	for name, value := range l.properties {
+		sql = strings.ReplaceAll(sql, "${"+name+"}", value)
+	}
+	cs.SQL = sql
+
+	l.changeSets = append(l.changeSets, cs)
+	return nil
+}
+
+// appliesToMySQL reports whether a dbms attribute includes MySQL
+func appliesToMySQL(dbms string) bool {
+	applies := false
+	for _, name := range strings.Split(dbms, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		switch {
+		case name == "!mysql" || name == "none":
+			return false
+		case name == "mysql" || name == "mariadb" || name == "all" || strings.HasPrefix(name, "!"):
+			applies = true
+		}
+	}
+	return applies
+}
+
+// parseXML reads an XML changelog into a node tree
+func parseXML(data []byte) (*node, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	var stack []*node
+	var root *node
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: make(map[string]string)}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			n.text = strings.TrimSpace(n.text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("empty changelog")
+	}
+	return root, nil
+}
@@ -0,0 +1,184 @@
+package liquibase
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const xmlChangelog = `<?xml version="1.0" encoding="UTF-8"?>
+<databaseChangeLog xmlns="http://www.liquibase.org/xml/ns/dbchangelog">
+    <property name="engine" value="InnoDB"/>
+    <changeSet id="1" author="alice">
+        <comment>Users</comment>
+        <createTable tableName="users">
+            <column name="id" type="BIGINT" autoIncrement="true">
+                <constraints primaryKey="true"/>
+            </column>
+            <column name="email" type="VARCHAR(255)">
+                <constraints nullable="false" unique="true"/>
+            </column>
+            <column name="active" type="BOOLEAN" defaultValueBoolean="true"/>
+        </createTable>
+        <rollback><dropTable tableName="users"/></rollback>
+    </changeSet>
+    <changeSet id="2" author="bob" runInTransaction="false">
+        <sql>ALTER TABLE users ENGINE=${engine}</sql>
+    </changeSet>
+    <changeSet id="3" author="bob" dbms="postgresql">
+        <sql>CREATE EXTENSION pgcrypto</sql>
+    </changeSet>
+    <include file="orders.changelog.yaml" relativeToChangelogFile="true"/>
+</databaseChangeLog>
+`
+
+const yamlChangelog = `databaseChangeLog:
+  # Orders belong to users
+  - changeSet:
+      id: orders-1
+      author: carol
+      changes:
+        - createTable:
+            tableName: orders
+            columns:
+              - column:
+                  name: id
+                  type: BIGINT
+                  constraints:
+                    primaryKey: true
+              - column:
+                  name: user_id
+                  type: BIGINT
+                  constraints:
+                    nullable: false
+                    references: users(id)
+                    foreignKeyName: fk_orders_user
+        - insert:
+            tableName: orders
+            columns:
+            - column:
+                name: id
+                valueNumeric: 1
+            - column:
+                name: user_id
+                valueNumeric: 1
+  - changeSet:
+      id: orders-2
+      author: carol
+      runOnChange: true
+      changes:
+        - sql:
+            sql: |
+              CREATE OR REPLACE VIEW order_counts AS
+              SELECT user_id, COUNT(*) AS n FROM orders GROUP BY user_id
+        - sqlFile:
+            path: sql/grants.sql
+            relativeToChangelogFile: true
+`
+
+func writeChangelogs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"db/main.changelog.xml":    xmlChangelog,
+		"db/orders.changelog.yaml": yamlChangelog,
+		"db/sql/grants.sql":        "GRANT SELECT ON order_counts TO 'reporting'@'%';\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeChangelogs(t)
+
+	changelog, err := Load(dir, "db/main.changelog.xml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var names []string
+	for _, cs := range changelog.ChangeSets {
+		names = append(names, cs.Name())
+	}
+	want := []string{
+		"db/main.changelog.xml::1::alice",
+		"db/main.changelog.xml::2::bob",
+		"db/orders.changelog.yaml::orders-1::carol",
+		"db/orders.changelog.yaml::orders-2::carol",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected changesets %v, want %v", names, want)
+	}
+	if !reflect.DeepEqual(changelog.Files, []string{"db/main.changelog.xml", "db/orders.changelog.yaml", "db/sql/grants.sql"}) {
+		t.Errorf("unexpected files %v", changelog.Files)
+	}
+
+	users := changelog.ChangeSets[0].SQL
+	for _, part := range []string{"CREATE TABLE `users` (", "`id` BIGINT NOT NULL AUTO_INCREMENT", "`email` VARCHAR(255) NOT NULL UNIQUE", "`active` BOOLEAN DEFAULT TRUE", "PRIMARY KEY (`id`)"} {
+		if !strings.Contains(users, part) {
+			t.Errorf("expected %q in:\n%s", part, users)
+		}
+	}
+	if strings.Contains(users, "DROP TABLE") {
+		t.Errorf("rollback must not be executed:\n%s", users)
+	}
+
+	if got := changelog.ChangeSets[1].SQL; got != "-- dbmig: noTransaction\nALTER TABLE users ENGINE=InnoDB;\n" {
+		t.Errorf("unexpected SQL %q", got)
+	}
+
+	orders := changelog.ChangeSets[2].SQL
+	for _, part := range []string{"CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)", "INSERT INTO `orders` (`id`, `user_id`) VALUES (1, 1);"} {
+		if !strings.Contains(orders, part) {
+			t.Errorf("expected %q in:\n%s", part, orders)
+		}
+	}
+
+	views := changelog.ChangeSets[3]
+	if !views.RunOnChange {
+		t.Errorf("expected runOnChange")
+	}
+	wantSQL := "CREATE OR REPLACE VIEW order_counts AS\nSELECT user_id, COUNT(*) AS n FROM orders GROUP BY user_id;\nGRANT SELECT ON order_counts TO 'reporting'@'%';\n"
+	if views.SQL != wantSQL {
+		t.Errorf("unexpected SQL %q, want %q", views.SQL, wantSQL)
+	}
+}
+
+func TestLoadUnsupportedChange(t *testing.T) {
+	dir := t.TempDir()
+	content := `<databaseChangeLog><changeSet id="1" author="a"><mergeColumns tableName="t"/></changeSet></databaseChangeLog>`
+	if err := os.WriteFile(filepath.Join(dir, "db.changelog.xml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(dir, "db.changelog.xml")
+	if err == nil || !strings.Contains(err.Error(), "unsupported change type mergeColumns") {
+		t.Errorf("expected unsupported change error, got %v", err)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	value, err := parseYAML("a: 1\nb:\n- x\n- 'it''s'\nc: >\n  folded\n  text\n\n  para\nd: \"q # not a comment\" # comment\n")
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+	want := []yamlEntry{
+		{key: "a", value: "1"},
+		{key: "b", value: []interface{}{"x", "it's"}},
+		{key: "c", value: "folded text\npara\n"},
+		{key: "d", value: "q # not a comment"},
+	}
+	if !reflect.DeepEqual(value, want) {
+		t.Errorf("unexpected value %#v", value)
+	}
+}
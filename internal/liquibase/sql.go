@@ -0,0 +1,284 @@
+package liquibase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// changeSQL converts one change element of a changeset to MySQL
+func changeSQL(c *node) (string, error) {
+	switch c.name {
+	case "sql":
+		if text := strings.TrimSpace(c.text); text != "" {
+			return text, nil
+		}
+		return strings.TrimSpace(c.attr("sql")), nil
+	case "createTable":
+		return createTable(c)
+	case "dropTable":
+		return fmt.Sprintf("DROP TABLE %s", tableName(c, "tableName")), nil
+	case "renameTable":
+		return fmt.Sprintf("RENAME TABLE %s TO %s", tableName(c, "oldTableName"), tableName(c, "newTableName")), nil
+	case "addColumn":
+		var adds []string
+		for _, col := range columns(c) {
+			def, err := columnDefinition(col)
+			if err != nil {
+				return "", err
+			}
+			switch {
+			case col.attr("afterColumn") != "":
+				def += " AFTER " + quote(col.attr("afterColumn"))
+			case col.attr("position") == "1":
+				def += " FIRST"
+			}
+			adds = append(adds, "ADD COLUMN "+def)
+		}
+		if len(adds) == 0 {
+			return "", fmt.Errorf("addColumn without columns")
+		}
+		return fmt.Sprintf("ALTER TABLE %s %s", tableName(c, "tableName"), strings.Join(adds, ", ")), nil
+	case "dropColumn":
+		names := []string{c.attr("columnName")}
+		if names[0] == "" {
+			names = names[:0]
+			for _, col := range columns(c) {
+				names = append(names, col.attr("name"))
+			}
+		}
+		var drops []string
+		for _, name := range names {
+			drops = append(drops, "DROP COLUMN "+quote(name))
+		}
+		return fmt.Sprintf("ALTER TABLE %s %s", tableName(c, "tableName"), strings.Join(drops, ", ")), nil
+	case "renameColumn":
+		return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName(c, "tableName"), quote(c.attr("oldColumnName")), quote(c.attr("newColumnName"))), nil
+	case "modifyDataType":
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", tableName(c, "tableName"), quote(c.attr("columnName")), dataType(c.attr("newDataType"))), nil
+	case "addNotNullConstraint":
+		if c.attr("columnDataType") == "" {
+			return "", fmt.Errorf("addNotNullConstraint on MySQL requires columnDataType")
+		}
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s NOT NULL", tableName(c, "tableName"), quote(c.attr("columnName")), dataType(c.attr("columnDataType"))), nil
+	case "addDefaultValue":
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s", tableName(c, "tableName"), quote(c.attr("columnName")), defaultValue(c, "defaultValue")), nil
+	case "createIndex":
+		var cols []string
+		for _, col := range columns(c) {
+			cols = append(cols, quote(col.attr("name")))
+		}
+		unique := ""
+		if c.attr("unique") == "true" {
+			unique = "UNIQUE "
+		}
+		return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, quote(c.attr("indexName")), tableName(c, "tableName"), strings.Join(cols, ", ")), nil
+	case "dropIndex":
+		return fmt.Sprintf("DROP INDEX %s ON %s", quote(c.attr("indexName")), tableName(c, "tableName")), nil
+	case "addPrimaryKey":
+		return fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", tableName(c, "tableName"), quoteList(c.attr("columnNames"))), nil
+	case "addUniqueConstraint":
+		return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s)", tableName(c, "tableName"), quote(c.attr("constraintName")), quoteList(c.attr("columnNames"))), nil
+	case "dropUniqueConstraint":
+		return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", tableName(c, "tableName"), quote(c.attr("uniqueConstraintName"))), nil
+	case "addForeignKeyConstraint":
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			tableName(c, "baseTableName"), quote(c.attr("constraintName")), quoteList(c.attr("baseColumnNames")),
+			tableName(c, "referencedTableName"), quoteList(c.attr("referencedColumnNames")))
+		if action := c.attr("onDelete"); action != "" {
+			stmt += " ON DELETE " + action
+		}
+		if action := c.attr("onUpdate"); action != "" {
+			stmt += " ON UPDATE " + action
+		}
+		return stmt, nil
+	case "dropForeignKeyConstraint":
+		return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", tableName(c, "baseTableName"), quote(c.attr("constraintName"))), nil
+	case "createView":
+		query := c.text
+		if query == "" {
+			query = strings.TrimSpace(c.attr("selectQuery"))
+		}
+		create := "CREATE"
+		if c.attr("replaceIfExists") == "true" {
+			create = "CREATE OR REPLACE"
+		}
+		return fmt.Sprintf("%s VIEW %s AS %s", create, tableName(c, "viewName"), query), nil
+	case "dropView":
+		return fmt.Sprintf("DROP VIEW %s", tableName(c, "viewName")), nil
+	case "insert":
+		var names, values []string
+		for _, col := range columns(c) {
+			names = append(names, quote(col.attr("name")))
+			values = append(values, columnValue(col))
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName(c, "tableName"), strings.Join(names, ", "), strings.Join(values, ", ")), nil
+	case "tagDatabase", "empty", "output":
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported change type %s", c.name)
+}
+
+// createTable converts a createTable change, with inline column constraints
+func createTable(c *node) (string, error) {
+	var defs, primary, foreignKeys []string
+	for _, col := range columns(c) {
+		def, err := columnDefinition(col)
+		if err != nil {
+			return "", err
+		}
+		defs = append(defs, def)
+
+		constraints := child(col, "constraints")
+		if constraints == nil {
+			continue
+		}
+		if constraints.attr("primaryKey") == "true" {
+			primary = append(primary, quote(col.attr("name")))
+		}
+		refTable, refColumns := constraints.attr("referencedTableName"), constraints.attr("referencedColumnNames")
+		if refs := constraints.attr("references"); refs != "" {
+			// references="users(id)"
+			table, cols, _ := strings.Cut(strings.TrimSuffix(refs, ")"), "(")
+			refTable, refColumns = strings.TrimSpace(table), cols
+		}
+		if refTable != "" {
+			fk := "FOREIGN KEY (" + quote(col.attr("name")) + ") REFERENCES " + quote(refTable) + " (" + quoteList(refColumns) + ")"
+			if name := constraints.attr("foreignKeyName"); name != "" {
+				fk = "CONSTRAINT " + quote(name) + " " + fk
+			}
+			if constraints.attr("deleteCascade") == "true" {
+				fk += " ON DELETE CASCADE"
+			}
+			foreignKeys = append(foreignKeys, fk)
+		}
+	}
+	if len(defs) == 0 {
+		return "", fmt.Errorf("createTable without columns")
+	}
+	if len(primary) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(primary, ", ")+")")
+	}
+	defs = append(defs, foreignKeys...)
+
+	stmt := fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", tableName(c, "tableName"), strings.Join(defs, ",\n  "))
+	if remarks := c.attr("remarks"); remarks != "" {
+		stmt += " COMMENT=" + literal(remarks)
+	}
+	return stmt, nil
+}
+
+// columnDefinition converts a column element to a column definition
+func columnDefinition(col *node) (string, error) {
+	if col.attr("name") == "" || col.attr("type") == "" {
+		return "", fmt.Errorf("column requires name and type")
+	}
+	def := quote(col.attr("name")) + " " + dataType(col.attr("type"))
+
+	if constraints := child(col, "constraints"); constraints != nil {
+		if constraints.attr("nullable") == "false" || constraints.attr("primaryKey") == "true" {
+			def += " NOT NULL"
+		}
+		if constraints.attr("unique") == "true" {
+			def += " UNIQUE"
+		}
+	}
+	if col.attr("autoIncrement") == "true" {
+		def += " AUTO_INCREMENT"
+	}
+	if value := defaultValue(col, "defaultValue"); value != "" {
+		def += " DEFAULT " + value
+	}
+	if remarks := col.attr("remarks"); remarks != "" {
+		def += " COMMENT " + literal(remarks)
+	}
+	return def, nil
+}
+
+// defaultValue returns the SQL of the defaultValue, defaultValueNumeric, ... attributes
+func defaultValue(n *node, prefix string) string {
+	switch {
+	case n.attr(prefix) != "":
+		return literal(n.attr(prefix))
+	case n.attr(prefix+"Numeric") != "":
+		return n.attr(prefix + "Numeric")
+	case n.attr(prefix+"Boolean") != "":
+		return strings.ToUpper(n.attr(prefix + "Boolean"))
+	case n.attr(prefix+"Date") != "":
+		return literal(n.attr(prefix + "Date"))
+	case n.attr(prefix+"Computed") != "":
+		return n.attr(prefix + "Computed")
+	}
+	return ""
+}
+
+// columnValue returns the value of a column of an insert change
+func columnValue(col *node) string {
+	if value := defaultValue(col, "value"); value != "" {
+		return value
+	}
+	if _, ok := col.attrs["value"]; ok {
+		return "''"
+	}
+	return "NULL"
+}
+
+// dataType maps Liquibase's portable type names to MySQL
+func dataType(t string) string {
+	switch strings.ToUpper(t) {
+	case "CLOB", "NCLOB":
+		return "LONGTEXT"
+	case "UUID":
+		return "CHAR(36)"
+	case "CURRENCY":
+		return "DECIMAL(19,4)"
+	}
+	return t
+}
+
+// columns returns the column children of a change
+func columns(c *node) []*node {
+	var cols []*node
+	for _, n := range c.children {
+		if n.name == "column" {
+			cols = append(cols, n)
+		}
+	}
+	return cols
+}
+
+// child returns the first child element with the given name
+func child(n *node, name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// tableName quotes the table named by attr, qualified by schemaName if set
+func tableName(c *node, attr string) string {
+	if schema := c.attr("schemaName"); schema != "" {
+		return quote(schema) + "." + quote(c.attr(attr))
+	}
+	return quote(c.attr(attr))
+}
+
+// quote quotes an identifier with backticks
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteList quotes a comma-separated list of identifiers
+func quoteList(names string) string {
+	var quoted []string
+	for _, name := range strings.Split(names, ",") {
+		quoted = append(quoted, quote(strings.TrimSpace(name)))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// literal quotes a string literal
+func literal(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(s) + "'"
+}
@@ -0,0 +1,309 @@
+package liquibase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The YAML reader covers the block style Liquibase changelogs are written in:
+// nested maps and sequences, plain and quoted scalars, and | and > block scalars
+// Flow collections ([a, b], {a: b}), anchors and tags are not supported
+
+// yamlEntry is one key of a YAML map; order is kept because changes run in order
+type yamlEntry struct {
+	key   string
+	value interface{} // string, []yamlEntry or []interface{}
+}
+
+type yamlLine struct {
+	indent int
+	text   string // Without indentation
+	num    int    // 1-based line number
+}
+
+type yamlParser struct {
+	raw   []string
+	lines []yamlLine // Non-blank, non-comment lines
+	pos   int
+}
+
+// parseYAMLChangelog reads a YAML changelog into the same node tree as an XML one
+func parseYAMLChangelog(data []byte) (*node, error) {
+	value, err := parseYAML(string(data))
+	if err != nil {
+		return nil, err
+	}
+	entries, ok := value.([]yamlEntry)
+	if !ok || len(entries) != 1 {
+		return nil, fmt.Errorf("expected a single databaseChangeLog key")
+	}
+	return toNode(entries[0].key, entries[0].value), nil
+}
+
+// toNode converts YAML to the XML element model: scalar values become attributes,
+// maps become child elements, and sequence items become children of the enclosing element
+func toNode(name string, value interface{}) *node {
+	n := &node{name: name, attrs: make(map[string]string)}
+	var add func(value interface{})
+	add = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			n.text = v
+		case []yamlEntry:
+			for _, e := range v {
+				switch ev := e.value.(type) {
+				case string:
+					n.attrs[e.key] = ev
+				case []yamlEntry:
+					n.children = append(n.children, toNode(e.key, ev))
+				case []interface{}:
+					add(ev)
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if entries, ok := item.([]yamlEntry); ok && len(entries) == 1 {
+					n.children = append(n.children, toNode(entries[0].key, entries[0].value))
+				}
+			}
+		}
+	}
+	add(value)
+	return n
+}
+
+// parseYAML parses a YAML document into strings, []yamlEntry maps and []interface{} sequences
+func parseYAML(doc string) (interface{}, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")}
+	for i, line := range p.raw {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(trimmed) == "" || strings.HasPrefix(trimmed, "#") || (i == 0 && strings.TrimSpace(trimmed) == "---") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{indent: len(line) - len(trimmed), text: strings.TrimRight(trimmed, " "), num: i + 1})
+	}
+	if len(p.lines) == 0 {
+		return nil, fmt.Errorf("empty changelog")
+	}
+
+	value, err := p.parseValue(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return value, nil
+}
+
+// parseValue parses the map or sequence starting at the current line
+func (p *yamlParser) parseValue(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if isSequenceItem(line.text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	var items []interface{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isSequenceItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		switch {
+		case rest == "":
+			// The item is on the following, deeper lines
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, "")
+				continue
+			}
+			item, err := p.parseValue(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case isSequenceItem(rest) || isMapEntry(rest):
+			// "- key: value" starts a map whose keys align with "key"
+			p.lines[p.pos] = yamlLine{indent: indent + len(line.text) - len(rest), text: rest, num: line.num}
+			item, err := p.parseValue(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		default:
+			value, err := scalar(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			p.pos++
+		}
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	var entries []yamlEntry
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent || isSequenceItem(line.text) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, rest, ok := splitMapEntry(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", line.num)
+		}
+		p.pos++
+
+		var value interface{}
+		var err error
+		switch {
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			value = p.blockScalar(rest, line)
+		case rest != "":
+			value, err = scalar(rest, line.num)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			value, err = p.parseValue(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text):
+			// Sequences may sit at the same indentation as their key
+			value, err = p.parseSequence(indent)
+		default:
+			value = ""
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, yamlEntry{key: key, value: value})
+	}
+	return entries, nil
+}
+
+// blockScalar reads a | (literal) or > (folded) scalar following the line of its key
+func (p *yamlParser) blockScalar(header string, key yamlLine) string {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+
+	// The raw lines are used because blank and #-lines belong to the scalar
+	var body []string
+	indent := -1
+	last := key.num
+	for i := key.num; i < len(p.raw); i++ {
+		line := p.raw[i]
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			body = append(body, "")
+			continue
+		}
+		lineIndent := len(line) - len(trimmed)
+		if indent < 0 {
+			indent = lineIndent
+		}
+		if lineIndent < indent || lineIndent <= key.indent {
+			break
+		}
+		body = append(body, line[indent:])
+		last = i + 1
+	}
+	body = body[:len(body)-countTrailingBlank(body)]
+
+	// Skip the parsed lines that belong to the scalar
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= last {
+		p.pos++
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, line := range body {
+			// Blank lines become newlines; other line breaks become spaces
+			switch {
+			case line == "":
+				b.WriteString("\n")
+			case i > 0 && body[i-1] != "":
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(body, "\n")
+	}
+	if !strings.HasPrefix(chomp, "-") {
+		text += "\n"
+	}
+	return text
+}
+
+func countTrailingBlank(lines []string) int {
+	n := 0
+	for i := len(lines) - 1; i >= 0 && lines[i] == ""; i-- {
+		n++
+	}
+	return n
+}
+
+// scalar returns the value of a plain or quoted scalar
+func scalar(s string, num int) (string, error) {
+	switch s[0] {
+	case '"':
+		end := strings.LastIndex(s, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("line %d: unterminated string", num)
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(s[1:end]), nil
+	case '\'':
+		end := strings.LastIndex(s, "'")
+		if end == 0 {
+			return "", fmt.Errorf("line %d: unterminated string", num)
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	case '[', '{', '&', '*', '!':
+		return "", fmt.Errorf("line %d: flow collections, anchors and tags are not supported", num)
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// isSequenceItem reports whether a line starts a sequence item
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// isMapEntry reports whether text starts with "key:"
+func isMapEntry(text string) bool {
+	_, _, ok := splitMapEntry(text)
+	return ok
+}
+
+// splitMapEntry splits "key: value" into key and value; the key may be quoted
+func splitMapEntry(text string) (key, value string, ok bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, rest := text[1:end+1], text[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest[1:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return text[:i], strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
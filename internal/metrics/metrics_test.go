@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	scripts := reg.Counter("scripts_total", "Scripts by outcome", "database", "status")
+	duration := reg.Histogram("script_duration_seconds", "Script durations", []float64{1, 10}, "database")
+	scripts.Inc("app", "success")
+	scripts.Inc("app", "success")
+	scripts.Inc("app", `fail"ed`)
+	duration.Observe(0.5, "app")
+	duration.Observe(5, "app")
+	duration.Observe(50, "app")
+
+	var out strings.Builder
+	if err := reg.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP scripts_total Scripts by outcome
+# TYPE scripts_total counter
+scripts_total{database="app",status="fail\"ed"} 1
+scripts_total{database="app",status="success"} 2
+# HELP script_duration_seconds Script durations
+# TYPE script_duration_seconds histogram
+script_duration_seconds_bucket{database="app",le="1"} 1
+script_duration_seconds_bucket{database="app",le="10"} 2
+script_duration_seconds_bucket{database="app",le="+Inf"} 3
+script_duration_seconds_sum{database="app"} 55.5
+script_duration_seconds_count{database="app"} 3
+`
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.EscapedPath(), string(content)
+	}))
+	defer gateway.Close()
+
+	reg := NewRegistry()
+	reg.Counter("runs_total", "Runs").Inc()
+	if err := reg.Push(context.Background(), gateway.URL+"/", "db-migration", map[string]string{"database": "app", "path": "a/b"}); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	// Values with slashes are base64-encoded
+	if method != http.MethodPut || path != "/metrics/job/db-migration/database/app/path@base64/YS9i" {
+		t.Errorf("unexpected request %s %s", method, path)
+	}
+	if !strings.Contains(body, "runs_total 1\n") {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metric", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	if err := reg.Push(context.Background(), failing.URL, "db-migration", nil); err == nil || !strings.Contains(err.Error(), "bad metric") {
+		t.Errorf("expected the gateway's error, got %v", err)
+	}
+}
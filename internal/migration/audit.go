@@ -0,0 +1,312 @@
+package migration
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// RunReport is the evidence of one run appended to the --audit-log
+type RunReport struct {
+	BatchID     string            `json:"batch_id"`
+	Database    string            `json:"database"`
+	Host        string            `json:"host"`
+	User        string            `json:"user"`
+	Environment string            `json:"environment,omitempty"`
+	Commit      string            `json:"commit,omitempty"`
+	StartedAt   string            `json:"started_at"`
+	FinishedAt  string            `json:"finished_at"`
+	Status      string            `json:"status"` // success or failed
+	Error       string            `json:"error,omitempty"`
+	ApprovedBy  string            `json:"approved_by,omitempty"`
+	Scripts     []ReportScript    `json:"scripts"`
+	Reconnects  []ReportReconnect `json:"reconnects,omitempty"`
+}
+
+// ReportReconnect is a connection reopened with refreshed credentials during the run
+type ReportReconnect struct {
+	At           string `json:"at"`
+	BeforeScript string `json:"before_script"`
+	Connection   string `json:"connection"`
+	Reason       string `json:"reason"`
+}
+
+// ReportScript is the outcome of one script of a run
+type ReportScript struct {
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	Checksum   string   `json:"checksum,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+	Tickets    []string `json:"tickets,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// AuditEntry is one line of an audit log; Hash chains it to the previous entry and
+// Signature, when a key is configured, signs Hash
+type AuditEntry struct {
+	Report    json.RawMessage `json:"report"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// auditHash covers the previous hash and the exact report bytes
+func auditHash(prevHash string, report []byte) string {
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), report...))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditMu serializes appends, e.g. of the targets of a fan-out sharing one log
+var auditMu sync.Mutex
+
+// AppendAuditLog appends report to the audit log at path, chained to its last entry
+// and signed with signer when it is not nil
+func AppendAuditLog(path string, report RunReport, signer crypto.Signer) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	prevHash, err := lastAuditHash(path)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	entry := AuditEntry{Report: raw, PrevHash: prevHash, Hash: auditHash(prevHash, raw)}
+	if signer != nil {
+		digest, _ := hex.DecodeString(entry.Hash)
+		sig, err := signDigest(signer, digest)
+		if err != nil {
+			return fmt.Errorf("failed to sign the run report: %w", err)
+		}
+		entry.Signature = base64.StdEncoding.EncodeToString(sig)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append to audit log: %w", err)
+	}
+	return file.Close()
+}
+
+// lastAuditHash returns the hash of the last entry of the audit log, or "" for a new log
+func lastAuditHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(last, &entry); err != nil || entry.Hash == "" {
+		return "", fmt.Errorf("audit log %s does not end with a valid entry; verify it before appending", path)
+	}
+	return entry.Hash, nil
+}
+
+// VerifyAuditLog checks every entry's hash chain and, when publicKey is not nil, its
+// signature; it returns the number of entries, or an error naming the first bad line
+func VerifyAuditLog(r io.Reader, publicKey crypto.PublicKey) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	prevHash := ""
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, fmt.Errorf("line %d: invalid entry: %w", line, err)
+		}
+		if entry.PrevHash != prevHash {
+			return count, fmt.Errorf("line %d: chain broken; an earlier entry was removed, reordered or changed", line)
+		}
+		if auditHash(entry.PrevHash, entry.Report) != entry.Hash {
+			return count, fmt.Errorf("line %d: report does not match its hash; it was modified", line)
+		}
+		if publicKey != nil {
+			digest, _ := hex.DecodeString(entry.Hash)
+			sig, err := base64.StdEncoding.DecodeString(entry.Signature)
+			if err != nil || entry.Signature == "" || !verifyDigest(publicKey, digest, sig) {
+				return count, fmt.Errorf("line %d: missing or invalid signature", line)
+			}
+		}
+		prevHash = entry.Hash
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return count, nil
+}
+
+// LoadSigningKey reads a PEM private key (PKCS#8, PKCS#1 or SEC 1): Ed25519, ECDSA or RSA
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type in %s", path)
+	}
+	return signer, nil
+}
+
+// LoadVerifyKey reads a PEM public key (PKIX) matching a LoadSigningKey key
+func LoadVerifyKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM key", path)
+	}
+	return block, nil
+}
+
+// signDigest signs a SHA-256 digest; Ed25519 signs the digest bytes as its message
+func signDigest(signer crypto.Signer, digest []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, digest, crypto.Hash(0))
+	}
+	return signer.Sign(rand.Reader, digest, crypto.SHA256)
+}
+
+func verifyDigest(publicKey crypto.PublicKey, digest, sig []byte) bool {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, digest, sig)
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig) == nil
+	}
+	return false
+}
+
+// Report describes the most recent Run; runErr is the error it returned
+func (m *Migrator) Report(runErr error) RunReport {
+	report := RunReport{
+		BatchID:     m.batchID,
+		Database:    m.config.DBName,
+		Host:        m.config.Host,
+		User:        m.config.User,
+		Environment: m.config.Environment,
+		ApprovedBy:  m.approvedBy,
+		StartedAt:   m.started.UTC().Format(time.RFC3339),
+		FinishedAt:  m.clock.Now().UTC().Format(time.RFC3339),
+		Status:      "success",
+		Scripts:     []ReportScript{},
+	}
+	if runErr != nil {
+		report.Status, report.Error = "failed", runErr.Error()
+	}
+	if commit, err := m.git.GetCurrentCommit(); err == nil {
+		report.Commit = commit
+	}
+	for _, r := range m.results {
+		script := ReportScript{Name: r.Name, Status: r.Status, DurationMS: r.Duration.Milliseconds(), Tickets: r.Tickets, Error: r.Error}
+		if content, err := m.readScript(git.ScriptInfo{Name: r.Name, Path: r.Path}); err == nil {
+			script.Checksum, _ = m.scriptChecksum(git.ScriptInfo{Name: r.Name, Path: r.Path}, content)
+		}
+		report.Scripts = append(report.Scripts, script)
+	}
+	for _, r := range m.reconnects {
+		report.Reconnects = append(report.Reconnects, ReportReconnect{At: r.At.UTC().Format(time.RFC3339), BeforeScript: r.BeforeScript, Connection: r.Connection, Reason: r.Reason})
+	}
+	return report
+}
+
+// logReport writes the report of this run as the last JSON line of --log-format json,
+// so CI jobs can read the outcome of every script from one document
+func (m *Migrator) logReport(runErr error) {
+	if m.config.DryRun {
+		return
+	}
+	m.console.Report("run report", m.Report(runErr))
+}
+
+// writeAuditLog appends the report of this run to the --audit-log, even when it failed.
+// Dry runs change nothing and are not logged
+func (m *Migrator) writeAuditLog(runErr error) {
+	if m.config.AuditLog == "" || m.config.DryRun {
+		return
+	}
+
+	report := m.Report(runErr)
+	var signer crypto.Signer
+	var err error
+	if m.config.AuditKey != "" {
+		signer, err = LoadSigningKey(m.config.AuditKey)
+	}
+	if err == nil {
+		err = AppendAuditLog(m.config.AuditLog, report, signer)
+	}
+	if err != nil {
+		m.console.Warn("Could not append to audit log %s: %v", m.config.AuditLog, err)
+		return
+	}
+	signed := ""
+	if signer != nil {
+		signed = "signed "
+	}
+	m.console.Info("Appended %srun report to %s", signed, m.config.AuditLog)
+}
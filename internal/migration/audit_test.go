@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	for name, signer := range map[string]interface{}{"ed25519": edKey, "ecdsa": ecKey} {
+		t.Run(name, func(t *testing.T) {
+			// Keys round-trip through PEM files like --audit-key
+			der, _ := x509.MarshalPKCS8PrivateKey(signer)
+			keyFile := filepath.Join(dir, name+".pem")
+			os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+			key, err := LoadSigningKey(keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, _ = x509.MarshalPKIXPublicKey(key.Public())
+			pubFile := filepath.Join(dir, name+".pub")
+			os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+			pub, err := LoadVerifyKey(pubFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			log := filepath.Join(dir, name+".jsonl")
+			for _, batch := range []string{"b1", "b2", "b3"} {
+				report := RunReport{BatchID: batch, Database: "app", Status: "success", Scripts: []ReportScript{{Name: "001_init.sql", Status: ResultSuccess}}}
+				if err := AppendAuditLog(log, report, key); err != nil {
+					t.Fatal(err)
+				}
+			}
+			verify := func(content string) (int, error) {
+				return VerifyAuditLog(strings.NewReader(content), pub)
+			}
+			data, _ := os.ReadFile(log)
+			if n, err := verify(string(data)); err != nil || n != 3 {
+				t.Fatalf("expected 3 verified entries, got %d, %v", n, err)
+			}
+
+			lines := strings.SplitAfter(string(data), "\n")
+			tampered := strings.Replace(string(data), `"status":"success"`, `"status":"failed"`, 2)
+			if _, err := verify(tampered); err == nil || !strings.Contains(err.Error(), "line 1: report does not match") {
+				t.Errorf("expected a modified report to be detected, got %v", err)
+			}
+			if _, err := verify(lines[0] + lines[2]); err == nil || !strings.Contains(err.Error(), "line 2: chain broken") {
+				t.Errorf("expected a removed entry to be detected, got %v", err)
+			}
+			if _, err := VerifyAuditLog(strings.NewReader(string(data)), nil); err != nil {
+				t.Errorf("expected the chain to verify without a key, got %v", err)
+			}
+			other, _, _ := ed25519.GenerateKey(rand.Reader)
+			if _, err := VerifyAuditLog(strings.NewReader(string(data)), other); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+				t.Errorf("expected signatures from another key to be refused, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// executeBackfill updates a column in key-range chunks, each committed on its own,
+// so large tables are never locked by a single UPDATE
+// The script body is the SQL expression assigned to the column
+func (m *Migrator) executeBackfill(t *Tracker, script git.ScriptInfo, content []byte, directives directive.Set, rec ScriptRecord) error {
+	b := directives.Backfill
+	started := m.clock.Now()
+
+	fail := func(err error) error {
+		rec.DurationMS = m.clock.Now().Sub(started).Milliseconds()
+		t.RecordExecutionDirect(failureRecord(rec, directives, err))
+		return fmt.Errorf("backfill error: %w", err)
+	}
+
+	statements := splitStatements(string(content))
+	if len(statements) != 1 {
+		return fail(fmt.Errorf("%s must contain exactly one value expression, found %d statements", script.Name, len(statements)))
+	}
+	expression := statements[0]
+
+	dialect := m.db.Dialect()
+	quote := dialect.QuoteIdentifier
+	var minKey, maxKey sql.NullInt64
+	bounds := fmt.Sprintf("SELECT MIN(%[1]s), MAX(%[1]s) FROM %[2]s", quote(b.Key), quote(b.Table))
+	if err := m.db.QueryRow(bounds).Scan(&minKey, &maxKey); err != nil {
+		return fail(fmt.Errorf("failed to read key range of %s: %w", b.Table, err))
+	}
+
+	if minKey.Valid {
+		update := dialect.Rebind(fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s >= ? AND %s < ?",
+			quote(b.Table), quote(b.Column), expression, quote(b.Key), quote(b.Key)))
+
+		var updated int64
+		for lo := minKey.Int64; lo <= maxKey.Int64; lo += int64(b.Batch) {
+			if err := m.ctx.Err(); err != nil {
+				m.console.Warn("%s interrupted at %s >= %d: earlier chunks stay committed", script.Name, b.Key, lo)
+				return fail(err)
+			}
+			if lo > minKey.Int64 && b.Sleep > 0 {
+				time.Sleep(b.Sleep)
+			}
+
+			result, err := m.db.Exec(update, lo, lo+int64(b.Batch))
+			if err != nil {
+				m.console.Warn("%s stopped at %s >= %d: earlier chunks stay committed", script.Name, b.Key, lo)
+				return fail(err)
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				updated += n
+			}
+			m.console.Info("Backfill %s.%s: %s %d-%d done, %d rows updated", b.Table, b.Column, b.Key, lo, min(lo+int64(b.Batch)-1, maxKey.Int64), updated)
+		}
+	}
+
+	rec.Completed = true
+	rec.DurationMS = m.clock.Now().Sub(started).Milliseconds()
+	if err := t.RecordExecutionDirect(rec); err != nil {
+		return fmt.Errorf("failed to record execution: %w", err)
+	}
+	return nil
+}
+
+// quoteIdentifier backtick-quotes a plain or schema-qualified identifier
+func quoteIdentifier(ident string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = "`" + part + "`"
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,86 @@
+package migration
+
+import (
+	"fmt"
+)
+
+// Baseline adopts a database provisioned without the tool, e.g. by hand, by recording
+// the scripts an up at target (a commit, or HEAD when empty) would execute as applied,
+// without executing them. The records form one batch ending at target, so the next up
+// only executes scripts committed after it
+// Nothing is written when the tracking table already has records
+func (m *Migrator) Baseline(target string, confirm ConfirmFunc) error {
+	m.console.Header("DB Baseline")
+	m.forgetCommit()
+	m.batchID = m.ids.NewID()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return err
+	}
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+	hasRecords, err := m.tracker.HasRecords()
+	if err != nil {
+		return err
+	}
+	if hasRecords {
+		return fmt.Errorf("tracking table %s already has records; baseline only adopts a fresh database", m.tracker.tableName)
+	}
+
+	var commit string
+	if target == "" {
+		commit, err = m.currentCommit()
+	} else {
+		commit, err = m.git.ResolveCommit(target)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve baseline commit: %w", err)
+	}
+	m.console.Info("Baseline commit: %s", shortCommit(commit))
+
+	scripts, _, _, err := m.discoverPending("", commit, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	if len(scripts) == 0 {
+		m.console.Success("No scripts to baseline")
+		return nil
+	}
+
+	if !confirm(fmt.Sprintf("Record %d scripts as applied without executing them?", len(scripts))) {
+		return fmt.Errorf("baseline cancelled")
+	}
+
+	for i, script := range scripts {
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		checksum, err := m.scriptChecksum(script, content)
+		if err != nil {
+			return err
+		}
+		rec := ScriptRecord{
+			ScriptName:  script.Name,
+			Completed:   true,
+			EndOfBatch:  i == len(scripts)-1,
+			LastGitID:   commit,
+			BatchID:     m.batchID,
+			Checksum:    checksum,
+			Fingerprint: Fingerprint(content),
+		}
+		if err := m.tracker.RecordExecutionDirect(rec); err != nil {
+			return fmt.Errorf("failed to record %s: %w", script.Name, err)
+		}
+		m.console.Script(script.Name, "success")
+	}
+
+	m.console.Success("Baselined %d scripts at commit %s", len(scripts), shortCommit(commit))
+	return nil
+}
@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+	yes := func(string) bool { return true }
+
+	// The database was provisioned by hand up to posts
+	if err := testDB.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE posts (id INTEGER PRIMARY KEY);"); err != nil {
+		t.Fatal(err)
+	}
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	baseline := repo.CommitScripts("add posts")
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add tags")
+
+	if err := m.Baseline(baseline[:8], yes); err != nil {
+		t.Fatalf("baseline failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql")
+	testDB.AssertBatches(1)
+	records, err := m.tracker.GetAllScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[1].LastGitID != baseline || !records[1].EndOfBatch || records[0].Checksum == "" {
+		t.Errorf("expected a batch ending at the baseline commit, got %+v", records)
+	}
+
+	if err := m.Baseline("", yes); err == nil || !strings.Contains(err.Error(), "already has records") {
+		t.Errorf("expected a second baseline to be refused, got %v", err)
+	}
+
+	// Only scripts committed after the baseline execute
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	testDB.AssertTableExists("tags")
+}
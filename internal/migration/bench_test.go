@@ -0,0 +1,131 @@
+package migration
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+// Size of the large history and the time a plan over it may take, see README
+const (
+	historyCommits = 50000
+	historyScripts = 5000
+	planBudget     = 5 * time.Second
+)
+
+// largeHistory is a repository of historyCommits commits adding historyScripts scripts
+type largeHistory struct {
+	scriptsDir string
+	added      []string // Commit adding each script
+}
+
+func newLargeHistory(tb testing.TB) *largeHistory {
+	tb.Helper()
+
+	repo := testkit.SetupGitRepo(tb)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	return &largeHistory{scriptsDir: scriptsDir, added: repo.GenerateHistory(scriptsDir, historyCommits, historyScripts)}
+}
+
+// migrator returns a Migrator over the history and a SQLite tracker in which the first
+// applied scripts are recorded, in batches of 50
+func (h *largeHistory) migrator(tb testing.TB, applied int) *Migrator {
+	tb.Helper()
+
+	testDB := testkit.OpenSQLite(tb)
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	m := NewMigrator(&config.Config{ScriptsDir: h.scriptsDir}, wrapDB(testDB), cons)
+	if err := m.tracker.EnsureTable(); err != nil {
+		tb.Fatal(err)
+	}
+
+	tx, err := testDB.DB.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+	for n := 1; n <= applied; n++ {
+		rec := ScriptRecord{ScriptName: testkit.HistoryScript(n), Completed: true, EndOfBatch: n%50 == 0 || n == applied, LastGitID: h.added[n-1], BatchID: "seed"}
+		if err := m.tracker.RecordExecution(tx, rec); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// plan plans m and checks that pending scripts are found
+func plan(tb testing.TB, m *Migrator, pending int) {
+	tb.Helper()
+
+	p, err := m.Plan()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(p.Changes) != pending {
+		tb.Fatalf("expected %d pending scripts, got %d", pending, len(p.Changes))
+	}
+}
+
+func BenchmarkLargeHistory(b *testing.B) {
+	h := newLargeHistory(b)
+
+	b.Run("PlanIncremental", func(b *testing.B) {
+		m := h.migrator(b, historyScripts-10)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			plan(b, m, 10)
+		}
+	})
+	b.Run("PlanFresh", func(b *testing.B) {
+		m := h.migrator(b, 0)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			plan(b, m, historyScripts)
+		}
+	})
+	b.Run("AppliedScripts", func(b *testing.B) {
+		m := h.migrator(b, historyScripts)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := m.tracker.GetAppliedScripts(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestPlanBudget fails when planning over the large history exceeds planBudget
+func TestPlanBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping performance test in short mode")
+	}
+
+	h := newLargeHistory(t)
+	for _, tc := range []struct {
+		name             string
+		applied, pending int
+	}{
+		{"incremental", historyScripts - 10, 10},
+		{"fresh", 0, historyScripts},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := h.migrator(t, tc.applied)
+			started := time.Now()
+			plan(t, m, tc.pending)
+			if elapsed := time.Since(started); elapsed > planBudget {
+				t.Errorf("planning %d scripts over %d applied took %v, over the budget of %v", tc.pending, tc.applied, elapsed, planBudget)
+			}
+		})
+	}
+}
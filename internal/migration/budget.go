@@ -0,0 +1,132 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// ScriptStats summarizes the size and complexity of a script
+type ScriptStats struct {
+	Size          int // Bytes after includes, placeholders and templates
+	Statements    int
+	MaxInsertRows int // Largest number of rows in a single INSERT/REPLACE ... VALUES
+}
+
+// AnalyzeScript computes the size and complexity of script content
+func AnalyzeScript(content string) ScriptStats {
+	stats := ScriptStats{Size: len(content)}
+	for _, stmt := range splitStatements(content) {
+		stats.Statements++
+		if rows := insertRows(stmt); rows > stats.MaxInsertRows {
+			stats.MaxInsertRows = rows
+		}
+	}
+	return stats
+}
+
+// checkBudgets reads the final SQL of each script and fails if any exceeds the configured budget
+func (m *Migrator) checkBudgets(scripts []git.ScriptInfo) error {
+	budget := m.config.Budget
+	if budget == (config.Budget{}) {
+		return nil
+	}
+
+	contents := make([]string, len(scripts))
+	for i, script := range scripts {
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		contents[i] = string(content)
+	}
+	return m.validator.CheckScriptBudgets(scripts, contents, budget)
+}
+
+// budgetViolations lists the limits a script exceeds (zero limits are unlimited)
+func budgetViolations(stats ScriptStats, budget config.Budget) []string {
+	var violations []string
+	if budget.MaxScriptSize > 0 && int64(stats.Size) > budget.MaxScriptSize {
+		violations = append(violations, fmt.Sprintf("size %d bytes exceeds %d", stats.Size, budget.MaxScriptSize))
+	}
+	if budget.MaxStatements > 0 && stats.Statements > budget.MaxStatements {
+		violations = append(violations, fmt.Sprintf("%d statements exceed %d", stats.Statements, budget.MaxStatements))
+	}
+	if budget.MaxInsertRows > 0 && stats.MaxInsertRows > budget.MaxInsertRows {
+		violations = append(violations, fmt.Sprintf("INSERT of %d rows exceeds %d", stats.MaxInsertRows, budget.MaxInsertRows))
+	}
+	return violations
+}
+
+// splitStatements splits SQL into statements without comments, see db.SplitCode
+func splitStatements(content string) []string {
+	return db.SplitCode(content)
+}
+
+// insertRows counts the row tuples of an INSERT or REPLACE ... VALUES statement
+func insertRows(stmt string) int {
+	upper := strings.ToUpper(stmt)
+	if !strings.HasPrefix(upper, "INSERT") && !strings.HasPrefix(upper, "REPLACE") {
+		return 0
+	}
+
+	values := indexKeyword(upper, "VALUES")
+	if values < 0 {
+		values = indexKeyword(upper, "VALUE")
+	}
+	if values < 0 {
+		return 0
+	}
+
+	rows, depth := 0, 0
+	for i := values; i < len(stmt); i++ {
+		c := stmt[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i = db.SkipQuoted(stmt, i) - 1
+		case c == '(':
+			if depth == 0 {
+				rows++
+			}
+			depth++
+		case c == ')':
+			depth--
+		case depth > 0 || c == ',' || unicode.IsSpace(rune(c)):
+		case strings.HasPrefix(upper[i:], "ROW("):
+			// MySQL 8 row constructor: VALUES ROW(1), ROW(2)
+			i += 2
+		default:
+			// The row list ends at the next clause, e.g. ON DUPLICATE KEY UPDATE
+			return rows
+		}
+	}
+	return rows
+}
+
+// indexKeyword finds a keyword as a whole word outside quotes
+func indexKeyword(upper, keyword string) int {
+	for i := 0; i < len(upper); i++ {
+		switch upper[i] {
+		case '\'', '"', '`':
+			i = db.SkipQuoted(upper, i) - 1
+			continue
+		}
+		if !strings.HasPrefix(upper[i:], keyword) {
+			continue
+		}
+		before := i == 0 || !isWordByte(upper[i-1])
+		after := i+len(keyword) == len(upper) || !isWordByte(upper[i+len(keyword)])
+		if before && after {
+			return i + len(keyword)
+		}
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,51 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+// TestAnalyzeScript tests statement splitting and INSERT row counting
+func TestAnalyzeScript(t *testing.T) {
+	content := `-- dbmig: timeout=1m
+/* header; with a semicolon */
+CREATE TABLE t (id INT, note VARCHAR(20));
+INSERT INTO t VALUES (1, 'a;b'), (2, 'it''s (x)'), (3, "q");
+INSERT INTO t (id, note) VALUES (4, 'd') ON DUPLICATE KEY UPDATE note = VALUES(note);
+# trailing comment;
+`
+	stats := AnalyzeScript(content)
+	if stats.Statements != 3 {
+		t.Errorf("expected 3 statements, got %d", stats.Statements)
+	}
+	if stats.MaxInsertRows != 3 {
+		t.Errorf("expected largest INSERT of 3 rows, got %d", stats.MaxInsertRows)
+	}
+	if stats.Size != len(content) {
+		t.Errorf("expected size %d, got %d", len(content), stats.Size)
+	}
+}
+
+// TestAnalyzeScript_BatchSeparator tests that SQL Server's GO lines end statements
+func TestAnalyzeScript_BatchSeparator(t *testing.T) {
+	content := "CREATE TABLE t (id INT)\nGO\nCREATE VIEW v AS SELECT id FROM t\ngo\n"
+	if stats := AnalyzeScript(content); stats.Statements != 2 {
+		t.Errorf("expected 2 statements, got %d", stats.Statements)
+	}
+}
+
+// TestBudgetViolations tests that only exceeded, non-zero limits are reported
+func TestBudgetViolations(t *testing.T) {
+	stats := ScriptStats{Size: 2048, Statements: 10, MaxInsertRows: 500}
+
+	if v := budgetViolations(stats, config.Budget{}); len(v) != 0 {
+		t.Errorf("expected no violations without limits, got %v", v)
+	}
+	if v := budgetViolations(stats, config.Budget{MaxScriptSize: 2048, MaxStatements: 10, MaxInsertRows: 500}); len(v) != 0 {
+		t.Errorf("expected no violations at the limits, got %v", v)
+	}
+	if v := budgetViolations(stats, config.Budget{MaxScriptSize: 1024, MaxStatements: 5, MaxInsertRows: 100}); len(v) != 3 {
+		t.Errorf("expected 3 violations, got %v", v)
+	}
+}
@@ -0,0 +1,149 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// BundleManifest optionally lists a bundle's files in execution order
+const BundleManifest = "manifest.txt"
+
+// bundleDirPattern matches migration bundle directories, e.g. 015_split_orders
+var bundleDirPattern = regexp.MustCompile(`^[0-9]+_[^/]+$`)
+
+// groupBundles replaces changed files inside bundle directories with one script per bundle
+// A bundle takes the position of its earliest changed file
+func (m *Migrator) groupBundles(scripts []git.ScriptInfo) []git.ScriptInfo {
+	var grouped []git.ScriptInfo
+	seen := make(map[string]bool)
+	for _, script := range scripts {
+		bundle, ok := m.bundleOf(script.Path)
+		if !ok {
+			grouped = append(grouped, script)
+			continue
+		}
+		if seen[bundle] {
+			continue
+		}
+		seen[bundle] = true
+
+		path, err := filepath.Abs(filepath.Join(m.config.ScriptsDir, bundle))
+		if err != nil {
+			path = filepath.Join(m.config.ScriptsDir, bundle)
+		}
+		grouped = append(grouped, git.ScriptInfo{
+			Name:      bundle,
+			Path:      path,
+			Timestamp: script.Timestamp,
+		})
+	}
+	return grouped
+}
+
+// bundleOf returns the bundle directory a repository path belongs to, if any
+func (m *Migrator) bundleOf(repoPath string) (string, bool) {
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return "", false
+	}
+	rel := filepath.ToSlash(repoPath)
+	if !strings.HasPrefix(rel, prefix) {
+		return "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(rel, prefix), "/")
+	if len(parts) != 2 || !bundleDirPattern.MatchString(parts[0]) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// readBundle reads every file of a bundle and joins them into a single script,
+// so the bundle runs in one transaction and is tracked as one unit
+func (m *Migrator) readBundle(bundle git.ScriptInfo) ([]byte, error) {
+	files, err := bundleFiles(bundle.Path)
+	if err != nil {
+		return nil, fmt.Errorf("bundle %s: %w", bundle.Name, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("bundle %s contains no scripts", bundle.Name)
+	}
+
+	var out strings.Builder
+	for _, file := range files {
+		content, err := m.readScript(git.ScriptInfo{Name: file, Path: filepath.Join(bundle.Path, file)})
+		if err != nil {
+			return nil, fmt.Errorf("bundle %s: %w", bundle.Name, err)
+		}
+		out.Write(content)
+		if !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return []byte(out.String()), nil
+}
+
+// bundleFiles returns the scripts of a bundle directory in execution order:
+// the manifest order if present, otherwise by file name
+func bundleFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle directory: %w", err)
+	}
+
+	present := make(map[string]bool)
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !git.IsScript(name) || git.IsDownScript(name) {
+			continue
+		}
+		present[name] = true
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	manifest, err := os.Open(filepath.Join(dir, BundleManifest))
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer manifest.Close()
+
+	var ordered []string
+	listed := make(map[string]bool)
+	scanner := bufio.NewScanner(manifest)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !present[line] {
+			return nil, fmt.Errorf("manifest lists %s, which is not a script in the bundle", line)
+		}
+		if listed[line] {
+			return nil, fmt.Errorf("manifest lists %s twice", line)
+		}
+		listed[line] = true
+		ordered = append(ordered, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	// Every script must be listed, so a forgotten manifest entry cannot be silently skipped
+	for _, name := range files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%s is not listed in %s", name, BundleManifest)
+		}
+	}
+	return ordered, nil
+}
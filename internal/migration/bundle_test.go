@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBundleFiles tests bundle file ordering with and without a manifest
+func TestBundleFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"01_b.sql", "02_a.sql", "02_a.down.sql", "notes.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := bundleFiles(dir)
+	if err != nil {
+		t.Fatalf("bundleFiles failed: %v", err)
+	}
+	if got := strings.Join(files, ","); got != "01_b.sql,02_a.sql" {
+		t.Errorf("expected name order without manifest, got %s", got)
+	}
+
+	writeManifest := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, BundleManifest), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeManifest("# run a first\n02_a.sql\n\n01_b.sql\n")
+	files, err = bundleFiles(dir)
+	if err != nil {
+		t.Fatalf("bundleFiles failed: %v", err)
+	}
+	if got := strings.Join(files, ","); got != "02_a.sql,01_b.sql" {
+		t.Errorf("expected manifest order, got %s", got)
+	}
+
+	writeManifest("02_a.sql\n")
+	if _, err := bundleFiles(dir); err == nil {
+		t.Error("expected error for script missing from manifest")
+	}
+
+	writeManifest("02_a.sql\n01_b.sql\n03_missing.sql\n")
+	if _, err := bundleFiles(dir); err == nil {
+		t.Error("expected error for manifest entry without a script")
+	}
+}
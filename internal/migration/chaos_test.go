@@ -0,0 +1,177 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/chaos"
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+var errCrash = errors.New("simulated SIGKILL")
+
+// newChaosMigrator returns a Migrator with faults over its own connection to testDB,
+// so testDB stays usable when the fault drops the connection
+func newChaosMigrator(t *testing.T, testDB *testkit.TestDatabase, repo *testkit.FakeRepo, scriptsDir string, faults *chaos.Faults) (*Migrator, *db.DB) {
+	t.Helper()
+
+	database, err := db.Open(db.DriverSQLite, testDB.DSN, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	m := NewMigrator(&config.Config{ScriptsDir: scriptsDir}, database, cons, WithRepository(repo.At(scriptsDir)), WithFaults(faults))
+	return m, database
+}
+
+// runUntilCrash runs m and returns errCrash if a fault crashed it
+func runUntilCrash(m *Migrator) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errCrash {
+				panic(r)
+			}
+			err = errCrash
+		}
+	}()
+	return m.Run()
+}
+
+// addChaosScripts commits 001_users.sql, 002_posts.sql and 003_tags.sql, one per commit
+func addChaosScripts(repo *testkit.FakeRepo, scriptsDir string) {
+	for i, table := range []string{"users", "posts", "tags"} {
+		repo.AddSQLScript(scriptsDir, fmt.Sprintf("%03d_%s.sql", i+1, table), fmt.Sprintf("CREATE TABLE %s (id INTEGER PRIMARY KEY);", table))
+		repo.CommitScripts("add " + table)
+	}
+}
+
+func TestChaos_KilledConnectionResumesOnNextRun(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	addChaosScripts(repo, scriptsDir)
+
+	m, _ := newChaosMigrator(t, testDB, repo, scriptsDir, &chaos.Faults{KillConnAfter: 1})
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the run to fail once the connection was dropped")
+	}
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertNoTable("posts")
+
+	// The next run finds the incomplete batch, keeps its completed script and finishes it
+	m, _ = newChaosMigrator(t, testDB, repo, scriptsDir, nil)
+	if err := m.Run(); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].EndOfBatch || !records[2].EndOfBatch {
+		t.Errorf("expected three records ending in one batch marker, got %+v", records)
+	}
+}
+
+func TestChaos_KilledConnectionReconnects(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	addChaosScripts(repo, scriptsDir)
+
+	m, database := newChaosMigrator(t, testDB, repo, scriptsDir, &chaos.Faults{KillConnAfter: 2})
+	database.SetReconnect(func() (*db.DB, error) {
+		return db.Open(db.DriverSQLite, testDB.DSN, nil)
+	})
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	testDB.AssertBatches(1)
+	if len(m.reconnects) != 1 || m.reconnects[0].BeforeScript != "003_tags.sql" {
+		t.Errorf("expected one reconnect before 003_tags.sql, got %+v", m.reconnects)
+	}
+}
+
+func TestChaos_CrashMidScript(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	addChaosScripts(repo, scriptsDir)
+
+	faults := &chaos.Faults{CrashAfter: 2, Crash: func() { panic(errCrash) }}
+	m, _ := newChaosMigrator(t, testDB, repo, scriptsDir, faults)
+	if err := runUntilCrash(m); err != errCrash {
+		t.Fatalf("expected the run to crash, got %v", err)
+	}
+
+	// The crashed script's transaction never committed, so neither it nor its record exist
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertNoTable("posts")
+
+	m, _ = newChaosMigrator(t, testDB, repo, scriptsDir, nil)
+	if err := m.Run(); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+
+	// 001 is not executed again
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Errorf("expected one record per script, got %+v", records)
+	}
+}
+
+func TestChaos_CrashAfterFailedScript(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	addChaosScripts(repo, scriptsDir)
+
+	// A failed attempt left behind by an interrupted batch needs a human
+	m, _ := newChaosMigrator(t, testDB, repo, scriptsDir, &chaos.Faults{CrashAfter: 1, Crash: func() { panic(errCrash) }})
+	if err := runUntilCrash(m); err != errCrash {
+		t.Fatalf("expected the run to crash, got %v", err)
+	}
+	if err := testDB.InsertTrackingRecord("001_users.sql", false, false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ = newChaosMigrator(t, testDB, repo, scriptsDir, nil)
+	err := m.Run()
+	if err == nil || !strings.Contains(err.Error(), "manual intervention required") {
+		t.Fatalf("expected the failed script to stop the run, got %v", err)
+	}
+	testDB.AssertApplied()
+}
+
+func TestChaos_SlowTrackerWrites(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	addChaosScripts(repo, scriptsDir)
+
+	m, _ := newChaosMigrator(t, testDB, repo, scriptsDir, &chaos.Faults{TrackerDelay: 20 * time.Millisecond})
+	started := time.Now()
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 60*time.Millisecond {
+		t.Errorf("expected each of the three tracking writes to be delayed, the run took %v", elapsed)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	testDB.AssertBatches(1)
+}
@@ -0,0 +1,139 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Exit codes of the check command; when several apply, the highest wins
+const (
+	CheckPending  = 2 // Scripts are waiting to be applied
+	CheckModified = 3 // Applied scripts were modified or deleted
+	CheckDrift    = 4 // The schema differs from the expected one
+)
+
+// CheckResult is what the check command found
+type CheckResult struct {
+	Pending  []string // Scripts the next up would execute
+	Modified []string // Applied scripts modified or deleted since they ran
+	Drift    []string // Statements turning the expected schema into the database's
+}
+
+// Code returns the exit code of the result, 0 when the database is current
+func (r *CheckResult) Code() int {
+	switch {
+	case len(r.Drift) > 0:
+		return CheckDrift
+	case len(r.Modified) > 0:
+		return CheckModified
+	case len(r.Pending) > 0:
+		return CheckPending
+	}
+	return 0
+}
+
+// Check reports whether the database is behind the scripts directory or diverged
+// from it, without applying anything or writing the tracking table. Drift is only
+// checked against an expected schema given with --from or --from-db
+func (m *Migrator) Check() (*CheckResult, error) {
+	m.console.Header("DB Migration Check")
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return nil, err
+	}
+	currentCommit, err := m.currentCommit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current commit: %w", err)
+	}
+
+	result := &CheckResult{}
+	exists, err := m.tracker.TableExists(m.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := m.checkApplied(result, currentCommit); err != nil {
+			return nil, err
+		}
+	} else {
+		// Nothing was ever applied, so every migration is pending
+		m.console.Info("Tracking table does not exist - this database was never migrated")
+		scripts, err := m.git.GetChangedScripts("", currentCommit, m.config.ScriptsDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changed scripts: %w", err)
+		}
+		var migrations []git.ScriptInfo
+		for _, script := range scripts {
+			if !m.isIncludeFragment(script.Path) && !m.isHook(script.Path) && !m.isVerifyScript(script.Path) {
+				migrations = append(migrations, script)
+			}
+		}
+		for _, script := range m.groupBundles(migrations) {
+			result.Pending = append(result.Pending, script.Name)
+		}
+	}
+
+	if m.config.DiffFrom != "" || m.config.DiffFromDB != "" {
+		source, statements, err := m.diffFrom()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for drift: %w", err)
+		}
+		result.Drift = statements
+		if len(statements) > 0 {
+			m.console.Failure("Database %s drifted from %s:", m.config.DBName, source)
+			for _, stmt := range statements {
+				m.console.Info("  %s", stmt)
+			}
+		} else {
+			m.console.Success("Schema matches %s", source)
+		}
+	}
+
+	if len(result.Pending) > 0 {
+		m.console.Warn("%d scripts are pending:", len(result.Pending))
+		for _, name := range result.Pending {
+			m.console.Info("  - %s", name)
+		}
+	}
+	if result.Code() == 0 {
+		m.console.Success("Database %s is current at commit %s", m.config.DBName, currentCommit[:8])
+	}
+	return result, nil
+}
+
+// checkApplied finds the applied scripts that were modified and the pending ones
+func (m *Migrator) checkApplied(result *CheckResult, currentCommit string) error {
+	lastGitID, err := m.tracker.GetLastSuccessfulCommit()
+	if err != nil {
+		return fmt.Errorf("failed to get last successful commit: %w", err)
+	}
+	executedScripts, err := m.tracker.GetExecutedScriptNames()
+	if err != nil {
+		return fmt.Errorf("failed to get executed scripts: %w", err)
+	}
+
+	// The validator lists what it found before failing
+	err = m.validator.CheckFileModifications(lastGitID, currentCommit, executedScripts)
+	if err == nil {
+		err = m.verifyChecksums()
+	}
+	if err != nil {
+		for _, f := range m.validator.Findings() {
+			if f.RuleID == RuleModifiedScript || f.RuleID == RuleDeletedScript {
+				result.Modified = append(result.Modified, f.Path)
+			}
+		}
+		if len(result.Modified) == 0 {
+			return err
+		}
+	}
+
+	pending, _, _, err := m.discoverPending(lastGitID, currentCommit, executedScripts)
+	if err != nil {
+		return err
+	}
+	for _, script := range pending {
+		result.Pending = append(result.Pending, script.Name)
+	}
+	return nil
+}
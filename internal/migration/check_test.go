@@ -0,0 +1,21 @@
+package migration
+
+import "testing"
+
+func TestCheckResult_Code(t *testing.T) {
+	tests := []struct {
+		name   string
+		result CheckResult
+		want   int
+	}{
+		{"current", CheckResult{}, 0},
+		{"pending", CheckResult{Pending: []string{"002_orders.sql"}}, CheckPending},
+		{"modified", CheckResult{Pending: []string{"002_orders.sql"}, Modified: []string{"001_init.sql"}}, CheckModified},
+		{"drift", CheckResult{Modified: []string{"001_init.sql"}, Drift: []string{"ALTER TABLE `users` DROP COLUMN `email`;"}}, CheckDrift},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Code(); got != tt.want {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Checksum returns the hex-encoded SHA-256 of script content
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyChecksums compares the recorded checksum of every applied script with its
+// current content, independent of git history. Repeatable scripts are expected to
+// change, templates render per server version, and scripts without a file (deleted
+// ones, which the git check reports, and Liquibase changesets) are left out
+func (m *Migrator) verifyChecksums() error {
+	if m.config.NoVerifyChecksums {
+		return nil
+	}
+	applied, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for _, rec := range applied {
+		if rec.Checksum == "" {
+			continue
+		}
+		checksum, ok, err := m.currentChecksum(rec)
+		if err != nil {
+			return err
+		}
+		if ok && checksum != rec.Checksum {
+			changed = append(changed, rec.ScriptName)
+		}
+	}
+	return m.validator.CheckChecksums(changed)
+}
+
+// currentChecksum returns the checksum of the current content of an applied script,
+// or false for the scripts verifyChecksums leaves out and those that cannot be read
+func (m *Migrator) currentChecksum(rec ScriptRecord) (string, bool, error) {
+	if git.IsRepeatable(rec.ScriptName) || git.IsTemplate(rec.ScriptName) {
+		return "", false, nil
+	}
+	path, err := filepath.Abs(filepath.Join(m.config.ScriptsDir, rec.ScriptName))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve %s: %w", rec.ScriptName, err)
+	}
+	// Scripts read from git may be missing from the working tree
+	if _, err := os.Stat(path); err != nil && m.config.Source == config.SourceWorktree {
+		return "", false, nil
+	}
+	script := git.ScriptInfo{Name: rec.ScriptName, Path: path}
+	content, err := m.readScript(script)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		m.console.Warn("Cannot verify the checksum of %s: %v", rec.ScriptName, err)
+		return "", false, nil
+	}
+	checksum, err := m.scriptChecksum(script, content)
+	if err != nil {
+		m.console.Warn("Cannot verify the checksum of %s: %v", rec.ScriptName, err)
+		return "", false, nil
+	}
+	return checksum, true, nil
+}
@@ -0,0 +1,168 @@
+package migration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/chaos"
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// Clock provides the current time to the migrator and tracker
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator produces unique identifiers, e.g. for migration batches
+type IDGenerator interface {
+	NewID() string
+}
+
+// systemClock is the default Clock backed by time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// randomIDGenerator is the default IDGenerator producing random hex IDs
+type randomIDGenerator struct{}
+
+func (randomIDGenerator) NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms; fall back to the clock
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// Option configures optional dependencies of a Migrator or Tracker
+type Option func(*options)
+
+// options holds the optional dependencies shared by Migrator and Tracker
+type options struct {
+	clock     Clock
+	ids       IDGenerator
+	trackerDB *db.DB
+	progress  func(ScriptProgress)
+	metrics   *Metrics
+	approve   ApprovalFunc
+	repo      Repository
+	fsys      fs.FS
+	faults    *chaos.Faults
+	executor  Executor
+	ctx       context.Context
+	logger    *slog.Logger
+}
+
+// WithClock overrides the clock used for timestamps
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithExecutor overrides the user, host and version recorded with each script
+func WithExecutor(e Executor) Option {
+	return func(o *options) {
+		o.executor = e
+	}
+}
+
+// WithContext stops a Migrator once ctx is done, e.g. on SIGINT: the running statement
+// and git command are cancelled, the script's transaction is rolled back and recorded
+// with failure cancelled, and no further script starts
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithLogger traces the git commands of the scripts repository to l, e.g. for -vv; a
+// Migrator traces to its console by default
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithIDGenerator overrides the generator used for batch IDs
+func WithIDGenerator(g IDGenerator) Option {
+	return func(o *options) {
+		o.ids = g
+	}
+}
+
+// WithBatchID makes the first batch use id, e.g. one already sent to the server as a
+// connection attribute; later batches get generated IDs
+func WithBatchID(id string) Option {
+	return func(o *options) {
+		o.ids = &presetIDGenerator{first: id, next: o.ids}
+	}
+}
+
+// WithProgress calls fn as each pending script of a run starts and finishes, e.g. to
+// stream progress to an API client
+func WithProgress(fn func(ScriptProgress)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// ApprovalFunc decides whether the plan of a run may be applied and returns who
+// approved it, e.g. by asking in chat; an error stops the run before any script executes
+type ApprovalFunc func(plan *Plan) (approver string, err error)
+
+// WithApproval makes runs with scripts to execute wait for approve, and record the
+// approver with each script
+func WithApproval(approve ApprovalFunc) Option {
+	return func(o *options) {
+		o.approve = approve
+	}
+}
+
+// WithFaults injects failures into runs, to test how interrupted runs recover
+func WithFaults(f *chaos.Faults) Option {
+	return func(o *options) {
+		o.faults = f
+	}
+}
+
+// NewBatchID returns a random batch ID, as the default IDGenerator does
+func NewBatchID() string {
+	return randomIDGenerator{}.NewID()
+}
+
+// presetIDGenerator returns a preset ID once, then defers to another generator
+type presetIDGenerator struct {
+	first string
+	next  IDGenerator
+}
+
+func (g *presetIDGenerator) NewID() string {
+	if g.first != "" {
+		id := g.first
+		g.first = ""
+		return id
+	}
+	return g.next.NewID()
+}
+
+// buildOptions applies opts on top of the defaults
+func buildOptions(opts []Option) options {
+	o := options{
+		clock:    systemClock{},
+		ids:      randomIDGenerator{},
+		executor: CurrentExecutor(),
+		ctx:      context.Background(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
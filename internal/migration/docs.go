@@ -0,0 +1,274 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// TableDoc describes a table for generated documentation
+type TableDoc struct {
+	Name        string
+	Comment     string
+	Columns     []ColumnDoc
+	ForeignKeys []ForeignKeyDoc
+}
+
+// ColumnDoc describes a column for generated documentation
+type ColumnDoc struct {
+	Name     string
+	DataType string // Base type, e.g. varchar
+	Type     string // Full type, e.g. varchar(100)
+	Nullable bool
+	Key      string // PRI, UNI or MUL
+	Default  sql.NullString
+	Comment  string
+}
+
+// ForeignKeyDoc describes a foreign key for generated documentation
+type ForeignKeyDoc struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// Docs writes Markdown documentation with a Mermaid ER diagram of the migrated schema
+func (m *Migrator) Docs(w io.Writer) error {
+	tables, err := DescribeSchema(m.db, ScriptTableName, SeedTableName)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, RenderDocs(m.config.DBName, tables))
+	return err
+}
+
+// writeDocs regenerates the --docs file after a batch
+func (m *Migrator) writeDocs() error {
+	if m.config.DocsFile == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	if err := m.Docs(&b); err != nil {
+		return err
+	}
+	if dir := filepath.Dir(m.config.DocsFile); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create docs directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(m.config.DocsFile, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write docs: %w", err)
+	}
+
+	m.console.Info("Schema documentation written to %s", m.config.DocsFile)
+	return nil
+}
+
+// DescribeSchema reads the tables, columns and foreign keys of the current database
+// from information_schema, in name and column order, skipping the excluded tables
+func DescribeSchema(database *db.DB, exclude ...string) ([]TableDoc, error) {
+	skip := make(map[string]bool)
+	for _, name := range exclude {
+		skip[strings.ToLower(name)] = true
+	}
+
+	rows, err := database.Query(`SELECT TABLE_NAME, TABLE_COMMENT FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var tables []TableDoc
+	index := make(map[string]int)
+	for rows.Next() {
+		var t TableDoc
+		if err := rows.Scan(&t.Name, &t.Comment); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		if !skip[strings.ToLower(t.Name)] {
+			index[t.Name] = len(tables)
+			tables = append(tables, t)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = database.Query(`SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, COLUMN_DEFAULT, COLUMN_COMMENT
+		FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list columns: %w", err)
+	}
+	for rows.Next() {
+		var table, nullable string
+		var c ColumnDoc
+		if err := rows.Scan(&table, &c.Name, &c.DataType, &c.Type, &nullable, &c.Key, &c.Default, &c.Comment); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		if i, ok := index[table]; ok {
+			c.Nullable = nullable == "YES"
+			tables[i].Columns = append(tables[i].Columns, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = database.Query(`SELECT TABLE_NAME, CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, name, column, refTable, refColumn string
+		if err := rows.Scan(&table, &name, &column, &refTable, &refColumn); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key: %w", err)
+		}
+		i, ok := index[table]
+		if !ok {
+			continue
+		}
+		fks := tables[i].ForeignKeys
+		if len(fks) == 0 || fks[len(fks)-1].Name != name {
+			fks = append(fks, ForeignKeyDoc{Name: name, RefTable: refTable})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+		tables[i].ForeignKeys = fks
+	}
+
+	return tables, rows.Err()
+}
+
+// RenderDocs returns Markdown with a Mermaid ER diagram followed by a section per table
+// The output only depends on the schema, so it changes exactly when the schema does
+func RenderDocs(dbName string, tables []TableDoc) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Schema: %s\n\n", dbName)
+	b.WriteString("<!-- Generated by db-migration. Do not edit; changes are overwritten by the next batch. -->\n\n")
+
+	if len(tables) == 0 {
+		b.WriteString("No tables.\n")
+		return b.String()
+	}
+
+	b.WriteString("```mermaid\nerDiagram\n")
+	for _, t := range tables {
+		fmt.Fprintf(&b, "    %s {\n", mermaidName(t.Name))
+		for _, c := range t.Columns {
+			fmt.Fprintf(&b, "        %s %s", mermaidName(c.DataType), mermaidName(c.Name))
+			if keys := columnKeys(t, c); len(keys) > 0 {
+				fmt.Fprintf(&b, " %s", strings.Join(keys, ", "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, t := range tables {
+		for _, fk := range t.ForeignKeys {
+			// A nullable foreign key means the child may exist without a parent
+			parent := "||"
+			if fkNullable(t, fk) {
+				parent = "|o"
+			}
+			fmt.Fprintf(&b, "    %s %s--o{ %s : \"%s\"\n", mermaidName(fk.RefTable), parent, mermaidName(t.Name), fk.Name)
+		}
+	}
+	b.WriteString("```\n")
+
+	for _, t := range tables {
+		fmt.Fprintf(&b, "\n## %s\n\n", t.Name)
+		if t.Comment != "" {
+			fmt.Fprintf(&b, "%s\n\n", t.Comment)
+		}
+		b.WriteString("| Column | Type | Null | Default | Key | Comment |\n")
+		b.WriteString("|--------|------|------|---------|-----|---------|\n")
+		for _, c := range t.Columns {
+			null := "NO"
+			if c.Nullable {
+				null = "YES"
+			}
+			def := ""
+			if c.Default.Valid {
+				def = "`" + c.Default.String + "`"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s |\n",
+				c.Name, markdownCell(c.Type), null, markdownCell(def), strings.Join(columnKeys(t, c), ", "), markdownCell(c.Comment))
+		}
+		if len(t.ForeignKeys) > 0 {
+			b.WriteString("\nForeign keys:\n\n")
+			for _, fk := range t.ForeignKeys {
+				fmt.Fprintf(&b, "- `%s`: (%s) → `%s` (%s)\n", fk.Name, strings.Join(fk.Columns, ", "), fk.RefTable, strings.Join(fk.RefColumns, ", "))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// columnKeys returns the Mermaid key markers (PK, FK, UK) of a column
+func columnKeys(t TableDoc, c ColumnDoc) []string {
+	var keys []string
+	switch c.Key {
+	case "PRI":
+		keys = append(keys, "PK")
+	case "UNI":
+		keys = append(keys, "UK")
+	}
+	for _, fk := range t.ForeignKeys {
+		if containsName(fk.Columns, c.Name) {
+			keys = append(keys, "FK")
+			break
+		}
+	}
+	return keys
+}
+
+// fkNullable reports whether any column of a foreign key allows NULL
+func fkNullable(t TableDoc, fk ForeignKeyDoc) bool {
+	for _, c := range t.Columns {
+		if c.Nullable && containsName(fk.Columns, c.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsName reports whether names holds name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mermaidName replaces characters Mermaid does not accept in entity and attribute names
+func mermaidName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// markdownCell escapes pipes and newlines in a table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
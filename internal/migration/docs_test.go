@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestRenderDocs(t *testing.T) {
+	tables := []TableDoc{
+		{
+			Name: "orders",
+			Columns: []ColumnDoc{
+				{Name: "id", DataType: "int", Type: "int", Key: "PRI"},
+				{Name: "user_id", DataType: "int", Type: "int unsigned", Nullable: true, Key: "MUL"},
+				{Name: "total", DataType: "decimal", Type: "decimal(10,2)", Default: sql.NullString{String: "0.00", Valid: true}, Comment: "gross | net"},
+			},
+			ForeignKeys: []ForeignKeyDoc{{Name: "fk_orders_user", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}},
+		},
+		{
+			Name:    "users",
+			Comment: "Registered users",
+			Columns: []ColumnDoc{{Name: "id", DataType: "int", Type: "int", Key: "PRI"}},
+		},
+	}
+
+	out := RenderDocs("shop", tables)
+	for _, want := range []string{
+		"# Schema: shop\n",
+		"```mermaid\nerDiagram\n    orders {\n        int id PK\n        int user_id FK\n        decimal total\n    }\n",
+		"    users |o--o{ orders : \"fk_orders_user\"\n",
+		"| `total` | decimal(10,2) | NO | `0.00` |  | gross \\| net |\n",
+		"## users\n\nRegistered users\n",
+		"- `fk_orders_user`: (user_id) → `users` (id)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected docs to contain %q, got:\n%s", want, out)
+		}
+	}
+}
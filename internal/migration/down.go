@@ -0,0 +1,198 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Down reverts applied scripts in reverse order using their paired down scripts
+// With a count it reverts the last count scripts; with a target it reverts everything
+// applied after the given commit (hash or hash prefix) or script name; with neither it
+// reverts the last batch
+func (m *Migrator) Down(target string, count int) error {
+	m.console.Header("DB Rollback Started")
+	m.forgetCommit()
+	m.batchID = m.ids.NewID()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// 1. Validate git repository
+	m.console.Info("Validating scripts directory...")
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return err
+	}
+
+	// 2. Ensure tracking table exists
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+
+	// 3. Work out which applied scripts to revert
+	all, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return err
+	}
+
+	// Repeatable scripts are maintained in place and are never reverted;
+	// scripts skipped by their guard never ran, so there is nothing to revert
+	var applied []ScriptRecord
+	for _, rec := range all {
+		if !git.IsRepeatable(rec.ScriptName) && rec.Action != ActionSkip {
+			applied = append(applied, rec)
+		}
+	}
+	if len(applied) == 0 {
+		m.console.Success("No applied scripts to revert")
+		return nil
+	}
+
+	keep, revert, err := splitForDown(applied, target, count)
+	if err != nil {
+		return err
+	}
+	if len(revert) == 0 {
+		m.console.Success("Nothing applied after %s", target)
+		return nil
+	}
+
+	// 4. Every script must have a down script before anything is reverted, read the way
+	// the revert will read it: from the commit being migrated to, unless --source worktree
+	var missing []string
+	for _, rec := range revert {
+		script := m.downScript(rec)
+		if _, err := m.readScript(script); errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, script.Name)
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		m.console.Error("The following down scripts are missing:")
+		for _, name := range missing {
+			m.console.Failure("  - %s", name)
+		}
+		return fmt.Errorf("cannot revert: %d down scripts are missing", len(missing))
+	}
+
+	// The tracker points back at the commit of the newest script that stays applied,
+	// so a later run rediscovers the reverted scripts as pending. Scripts of a partly
+	// reverted batch share its commit, so the commit before that batch is used
+	revertedBatches := make(map[string]bool)
+	for _, rec := range revert {
+		revertedBatches[batchKey(rec)] = true
+	}
+	restoreGitID := ""
+	for i := len(keep) - 1; i >= 0; i-- {
+		if !revertedBatches[batchKey(keep[i])] {
+			restoreGitID = keep[i].LastGitID
+			break
+		}
+	}
+
+	m.console.Info("Reverting %d scripts", len(revert))
+
+	// 5. Revert newest first, each in its own transaction
+	successCount := 0
+	for i := len(revert) - 1; i >= 0; i-- {
+		rec := revert[i]
+		script := m.downScript(rec)
+		downName := script.Name
+
+		m.console.Script(downName, "executing")
+
+		downRec := ScriptRecord{
+			ScriptName: rec.ScriptName,
+			EndOfBatch: i == 0,
+			LastGitID:  restoreGitID,
+			Action:     ActionDown,
+		}
+		if err := m.executeScript(m.tracker, script, downRec); err != nil {
+			m.console.Script(downName, "failed")
+			m.console.Error("Down script failed: %v", err)
+			m.console.Summary(len(revert), successCount, 1, 0, nil)
+			return fmt.Errorf("rollback failed at script: %s", downName)
+		}
+
+		m.console.Script(downName, "success")
+		successCount++
+	}
+
+	m.console.Summary(len(revert), successCount, 0, 0, nil)
+	if err := m.writeSnapshot(restoreGitID); err != nil {
+		m.console.Warn("Could not write schema snapshot: %v", err)
+	}
+	if err := m.writeDocs(); err != nil {
+		m.console.Warn("Could not write schema documentation: %v", err)
+	}
+	m.console.Success("Rollback completed successfully!")
+
+	return nil
+}
+
+// downScript returns the down script paired with an applied script
+func (m *Migrator) downScript(rec ScriptRecord) git.ScriptInfo {
+	name := DownScriptName(rec.ScriptName)
+	return git.ScriptInfo{Name: name, Path: filepath.Join(m.config.ScriptsDir, name)}
+}
+
+// DownScriptName returns the name of the down script paired with an up script
+// Templates pair with templates: 006_x.sql.tmpl is reverted by 006_x.down.sql.tmpl
+func DownScriptName(scriptName string) string {
+	if git.IsTemplate(scriptName) {
+		return strings.TrimSuffix(scriptName, git.TemplateSuffix) + ".down" + git.TemplateSuffix
+	}
+	return strings.TrimSuffix(scriptName, ".sql") + git.DownScriptSuffix
+}
+
+// splitForDown divides applied records into those that stay and those to revert
+func splitForDown(applied []ScriptRecord, target string, count int) (keep, revert []ScriptRecord, err error) {
+	if count > 0 {
+		if count > len(applied) {
+			return nil, nil, fmt.Errorf("cannot revert %d scripts: only %d are applied", count, len(applied))
+		}
+		return applied[:len(applied)-count], applied[len(applied)-count:], nil
+	}
+
+	if target == "" {
+		// Revert the trailing records that share the last record's batch
+		last := batchKey(applied[len(applied)-1])
+		i := len(applied)
+		for i > 0 && batchKey(applied[i-1]) == last {
+			i--
+		}
+		return applied[:i], applied[i:], nil
+	}
+
+	// Target may name an applied script...
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].ScriptName == target {
+			return applied[:i+1], applied[i+1:], nil
+		}
+	}
+
+	// ...or the commit of a batch
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].LastGitID != "" && strings.HasPrefix(applied[i].LastGitID, target) {
+			return applied[:i+1], applied[i+1:], nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("target %s does not match any applied script or batch commit", target)
+}
+
+// batchKey identifies the batch a record belongs to
+// Records written before batch IDs existed fall back to their git commit
+func batchKey(rec ScriptRecord) string {
+	if rec.BatchID != "" {
+		return rec.BatchID
+	}
+	return rec.LastGitID
+}
@@ -0,0 +1,156 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Changes of schema objects reported by drift
+const (
+	DriftAdded   = "added"   // In the database, not in the expected schema: created outside the tool
+	DriftRemoved = "removed" // In the expected schema, not in the database
+	DriftChanged = "changed" // In both, with different definitions
+)
+
+// DriftChange is one difference between the expected schema and the database
+type DriftChange struct {
+	Kind   string // table, column, index, constraint or view
+	Object string // The table or view, and the column, index or constraint of a table
+	Change string // added, removed or changed
+}
+
+// Drift compares the schema of the database with the expected one: the --from file or
+// --from-db database, or else the --schema-snapshot of the last batch, and returns the
+// tables, columns, indexes, constraints and views changed outside the tool
+func (m *Migrator) Drift() ([]DriftChange, error) {
+	m.console.Header("DB Schema Drift")
+
+	var source string
+	var expected *Schema
+	var err error
+	if m.config.DiffFrom != "" || m.config.DiffFromDB != "" {
+		source, expected, err = m.fromSchema()
+	} else {
+		source, expected, err = m.snapshotSchema()
+	}
+	if err != nil {
+		return nil, err
+	}
+	actual, err := m.liveSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := compareSchemas(expected, actual)
+	if len(changes) == 0 {
+		m.console.Success("Schema of %s matches %s", m.config.DBName, source)
+		return changes, nil
+	}
+	m.console.Failure("Database %s drifted from %s:", m.config.DBName, source)
+	rows := make([][]string, len(changes))
+	for i, c := range changes {
+		rows[i] = []string{c.Kind, c.Object, c.Change}
+	}
+	m.console.Table([]string{"KIND", "OBJECT", "CHANGE"}, rows)
+	return changes, nil
+}
+
+// snapshotSchema parses the snapshot the last batch wrote to --schema-snapshot
+func (m *Migrator) snapshotSchema() (string, *Schema, error) {
+	batch, err := m.inspector().LastBatch(m.ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get last batch: %w", err)
+	}
+	if batch == nil {
+		return "", nil, fmt.Errorf("no batch has completed yet, so there is no expected schema")
+	}
+	path := filepath.Join(m.config.SnapshotDir, batch.ID+".sql")
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("no snapshot of the last batch %s in %s; run up with --schema-snapshot, or pass --from", batch.ID, m.config.SnapshotDir)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	schema, err := ParseSchema(string(content))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return path, schema, nil
+}
+
+// compareSchemas lists the objects of actual that differ from expected, tables first,
+// each in name order
+func compareSchemas(expected, actual *Schema) []DriftChange {
+	changes := []DriftChange{}
+	add := func(kind, object, change string) {
+		changes = append(changes, DriftChange{Kind: kind, Object: object, Change: change})
+	}
+
+	for _, name := range sortedKeys(actual.Tables) {
+		if _, ok := expected.Tables[name]; !ok {
+			add("table", name, DriftAdded)
+		}
+	}
+	for _, name := range sortedKeys(expected.Tables) {
+		table, ok := actual.Tables[name]
+		if !ok {
+			add("table", name, DriftRemoved)
+			continue
+		}
+		old := expected.Tables[name]
+		for _, d := range compareDefinitions(columnDefinitions(old), columnDefinitions(table)) {
+			add("column", name+"."+d.name, d.change)
+		}
+		for _, d := range compareDefinitions(old.Indexes, table.Indexes) {
+			add("index", name+"."+d.name, d.change)
+		}
+		for _, d := range compareDefinitions(old.Constraints, table.Constraints) {
+			add("constraint", name+"."+d.name, d.change)
+		}
+	}
+
+	for _, d := range compareDefinitions(expected.Views, actual.Views) {
+		add("view", d.name, d.change)
+	}
+	return changes
+}
+
+// definitionChange is a named definition that was added, removed or changed
+type definitionChange struct {
+	name   string
+	change string
+}
+
+// compareDefinitions compares two sets of named definitions, ignoring formatting
+func compareDefinitions(expected, actual map[string]string) []definitionChange {
+	var changes []definitionChange
+	for _, name := range sortedKeys(actual) {
+		old, ok := expected[name]
+		switch {
+		case !ok:
+			changes = append(changes, definitionChange{name, DriftAdded})
+		case normalizeDefinition(old) != normalizeDefinition(actual[name]):
+			changes = append(changes, definitionChange{name, DriftChanged})
+		}
+	}
+	for _, name := range sortedKeys(expected) {
+		if _, ok := actual[name]; !ok {
+			changes = append(changes, definitionChange{name, DriftRemoved})
+		}
+	}
+	return changes
+}
+
+// columnDefinitions returns the definitions of a table's columns by lowercase name
+func columnDefinitions(table *Table) map[string]string {
+	defs := make(map[string]string, len(table.Columns))
+	for _, col := range table.Columns {
+		defs[strings.ToLower(col.Name)] = col.Def
+	}
+	return defs
+}
@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	expected, err := ParseSchema(usersTable +
+		"CREATE TABLE `legacy` (`id` int NOT NULL, PRIMARY KEY (`id`));\n" +
+		"CREATE VIEW `active_users` AS select `users`.`id` AS `id` from `users`;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changed by hand: a column widened, one added and one dropped, an index added,
+	// a table created and one dropped; formatting alone is no change
+	actual, err := ParseSchema("CREATE TABLE `users` (\n" +
+		"  `id` int   NOT NULL AUTO_INCREMENT,\n" +
+		"  `name` varchar(200) NOT NULL,\n" +
+		"  `email` varchar(255) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `idx_name` (`name`),\n" +
+		"  KEY `idx_email` (`email`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+		"CREATE TABLE `tmp_fix` (`id` int NOT NULL);\n" +
+		"CREATE VIEW `active_users` AS select `users`.`id` AS `id` from `users`;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DriftChange{
+		{"table", "tmp_fix", DriftAdded},
+		{"table", "legacy", DriftRemoved},
+		{"column", "users.email", DriftAdded},
+		{"column", "users.name", DriftChanged},
+		{"column", "users.legacy", DriftRemoved},
+		{"index", "users.idx_email", DriftAdded},
+	}
+	if got := compareSchemas(expected, actual); !reflect.DeepEqual(got, want) {
+		t.Errorf("compareSchemas =\n%v\nwant\n%v", got, want)
+	}
+	if got := compareSchemas(expected, expected); len(got) != 0 {
+		t.Errorf("expected no drift of a schema from itself, got %v", got)
+	}
+}
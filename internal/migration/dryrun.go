@@ -0,0 +1,21 @@
+package migration
+
+import (
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// printDryRun lists the scripts a run would execute, in execution order, with the time
+// each was committed
+func (m *Migrator) printDryRun(pending []git.ScriptInfo) {
+	m.console.Header("Dry Run")
+	for i, script := range pending {
+		committed := "not committed"
+		if !script.Timestamp.IsZero() {
+			committed = "committed " + script.Timestamp.UTC().Format(time.RFC3339)
+		}
+		m.console.Info("%d. %s (%s)", i+1, script.Name, committed)
+	}
+	m.console.Success("Dry run complete: %d scripts would be executed", len(pending))
+}
@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun_DryRun(t *testing.T) {
+	m, repo, scriptsDir, cons := newGoldenMigrator(t)
+	m.config.DryRun = true
+
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+
+	if err := m.Run(); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	golden(t, "dry_run.txt", []byte(scrubPaths(cons.Output(), repo)))
+
+	names, err := m.tracker.GetExecutedScriptNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected a dry run to execute nothing, got %v", names)
+	}
+	var tables int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('posts', 'users')`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("expected a dry run to create no tables")
+	}
+	// Not even the tracking table, so a dry run needs no write access
+	if exists, err := m.tracker.TableExists(context.Background()); err != nil || exists {
+		t.Errorf("expected a dry run to leave the tracking table uncreated, got %v, %v", exists, err)
+	}
+}
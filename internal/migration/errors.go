@@ -0,0 +1,38 @@
+package migration
+
+import "errors"
+
+// ErrLocked marks a command that gave up waiting for another run's migration lock
+var ErrLocked = errors.New("another run holds the migration lock")
+
+// ValidationError is a check that stopped a run before its first script: modified or
+// duplicated scripts, a failed previous batch, or problems of the pending scripts
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalid marks err, if any, as a ValidationError
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ValidationError{Err: err}
+}
+
+// ScriptError is a run that stopped at a failed script; the script's error is in its
+// result and the console output
+type ScriptError struct {
+	Script string
+}
+
+func (e *ScriptError) Error() string {
+	return "migration failed at script: " + e.Script
+}
@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"os"
+	"os/user"
+	"runtime/debug"
+)
+
+// Executor identifies who ran a script from where, recorded with each script for audits
+type Executor struct {
+	User    string // OS user running the tool
+	Host    string // Hostname of the machine running the tool
+	Version string // Version of the tool
+}
+
+// CurrentExecutor returns the executor of this process. Values that cannot be
+// determined, such as the user of a container without a passwd entry, are left empty
+func CurrentExecutor() Executor {
+	var e Executor
+	if current, err := user.Current(); err == nil {
+		e.User = current.Username
+	} else if name := os.Getenv("USER"); name != "" {
+		e.User = name
+	} else {
+		e.User = os.Getenv("USERNAME")
+	}
+	e.Host, _ = os.Hostname()
+	e.Version = ToolVersion()
+	return e
+}
+
+// ToolVersion returns the module version the binary was built from, or "devel" for
+// builds outside a tagged module, e.g. go build in a checkout without tags
+func ToolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
@@ -0,0 +1,166 @@
+package migration
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/git"
+	"github.com/bontaramsonta/db-migration/internal/textenc"
+)
+
+// Export writes the applied scripts in a format other migration tools consume
+func (m *Migrator) Export(format string, w io.Writer) error {
+	m.forgetCommit()
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+
+	applied, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case config.ExportFlyway:
+		return m.exportFlyway(applied, w)
+	case config.ExportLiquibase:
+		return m.exportLiquibase(applied, w)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// exportFlyway writes SQL that creates and fills a flyway_schema_history table
+func (m *Migrator) exportFlyway(applied []ScriptRecord, w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(`-- flyway_schema_history exported by db-migration
+CREATE TABLE IF NOT EXISTS flyway_schema_history (
+    installed_rank INT NOT NULL PRIMARY KEY,
+    version VARCHAR(50),
+    description VARCHAR(200) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    script VARCHAR(1000) NOT NULL,
+    checksum INT,
+    installed_by VARCHAR(100) NOT NULL,
+    installed_on TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    execution_time INT NOT NULL,
+    success BOOL NOT NULL
+);
+`)
+
+	for i, rec := range applied {
+		version := "NULL"
+		if !git.IsRepeatable(rec.ScriptName) {
+			if v, ok := scriptVersion(rec.ScriptName); ok {
+				version = sqlString(fmt.Sprint(v))
+			}
+		}
+
+		checksum := "NULL"
+		if sum, ok := m.flywayChecksum(rec.ScriptName); ok {
+			checksum = fmt.Sprint(sum)
+		}
+
+		fmt.Fprintf(&b, "INSERT INTO flyway_schema_history (installed_rank, version, description, type, script, checksum, installed_by, installed_on, execution_time, success) VALUES (%d, %s, %s, 'SQL', %s, %s, %s, %s, 0, 1);\n",
+			i+1, version, sqlString(scriptDescription(rec.ScriptName)), sqlString(rec.ScriptName), checksum,
+			sqlString(m.config.User), sqlString(rec.CreatedDateTime.Format("2006-01-02 15:04:05")))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Liquibase changelog XML elements
+type liquibaseChangeLog struct {
+	XMLName        xml.Name             `xml:"databaseChangeLog"`
+	Xmlns          string               `xml:"xmlns,attr"`
+	XmlnsXsi       string               `xml:"xmlns:xsi,attr"`
+	SchemaLocation string               `xml:"xsi:schemaLocation,attr"`
+	ChangeSets     []liquibaseChangeSet `xml:"changeSet"`
+}
+
+type liquibaseChangeSet struct {
+	ID       string           `xml:"id,attr"`
+	Author   string           `xml:"author,attr"`
+	RunOnChg bool             `xml:"runOnChange,attr,omitempty"`
+	SQLFile  liquibaseSQLFile `xml:"sqlFile"`
+}
+
+type liquibaseSQLFile struct {
+	Path     string `xml:"path,attr"`
+	Relative bool   `xml:"relativeToChangelogFile,attr"`
+}
+
+// exportLiquibase writes a changelog with one changeSet per applied script, referencing
+// the script files relative to the scripts directory
+func (m *Migrator) exportLiquibase(applied []ScriptRecord, w io.Writer) error {
+	changelog := liquibaseChangeLog{
+		Xmlns:          "http://www.liquibase.org/xml/ns/dbchangelog",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.liquibase.org/xml/ns/dbchangelog http://www.liquibase.org/xml/ns/dbchangelog/dbchangelog-latest.xsd",
+	}
+	for _, rec := range applied {
+		changelog.ChangeSets = append(changelog.ChangeSets, liquibaseChangeSet{
+			ID:       rec.ScriptName,
+			Author:   "db-migration",
+			RunOnChg: git.IsRepeatable(rec.ScriptName),
+			SQLFile:  liquibaseSQLFile{Path: rec.ScriptName, Relative: true},
+		})
+	}
+
+	out, err := xml.MarshalIndent(changelog, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode changelog: %w", err)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// flywayChecksum computes Flyway's checksum of a script file: the CRC32 of its lines
+// without line terminators, as a signed 32-bit integer
+func (m *Migrator) flywayChecksum(name string) (int32, bool) {
+	raw, err := os.ReadFile(filepath.Join(m.config.ScriptsDir, name))
+	if err != nil {
+		return 0, false
+	}
+	content, err := textenc.Normalize(raw, m.config.Encoding)
+	if err != nil {
+		return 0, false
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	crc := crc32.NewIEEE()
+	for _, line := range lines {
+		crc.Write([]byte(line))
+	}
+	return int32(crc.Sum32()), true
+}
+
+// scriptDescription derives a Flyway-style description: 001_create_users.sql -> "create users"
+func scriptDescription(name string) string {
+	desc := strings.TrimSuffix(strings.TrimSuffix(name, git.TemplateSuffix), ".sql")
+	desc = strings.TrimPrefix(desc, git.RepeatablePrefix)
+	if _, ok := scriptVersion(desc); ok {
+		if i := strings.IndexByte(desc, '_'); i >= 0 {
+			desc = desc[i+1:]
+		}
+	}
+	return strings.TrimSpace(strings.ReplaceAll(desc, "_", " "))
+}
+
+// sqlString quotes a value as a MySQL string literal
+func sqlString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
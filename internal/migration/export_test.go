@@ -0,0 +1,63 @@
+package migration
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+// TestExportFlyway tests flyway_schema_history rows and Flyway-compatible checksums
+func TestExportFlyway(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "001_create_users.sql"), []byte("CREATE TABLE users (id INT);\r\nSELECT 'it''s';\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &Migrator{config: &config.Config{ScriptsDir: dir, User: "deploy"}}
+
+	sum, ok := m.flywayChecksum("001_create_users.sql")
+	if want := int32(crc32.ChecksumIEEE([]byte("CREATE TABLE users (id INT);SELECT 'it''s';"))); !ok || sum != want {
+		t.Errorf("expected checksum %d, got %d (ok=%v)", want, sum, ok)
+	}
+
+	applied := []ScriptRecord{
+		{ScriptName: "001_create_users.sql", CreatedDateTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ScriptName: "R__refresh_views.sql", CreatedDateTime: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	var out strings.Builder
+	if err := m.exportFlyway(applied, &out); err != nil {
+		t.Fatalf("exportFlyway failed: %v", err)
+	}
+	for _, want := range []string{
+		"VALUES (1, '1', 'create users', 'SQL', '001_create_users.sql', " + fmt.Sprint(sum) + ", 'deploy', '2024-01-02 03:04:05', 0, 1);",
+		"VALUES (2, NULL, 'refresh views', 'SQL', 'R__refresh_views.sql', NULL, 'deploy', '2024-01-03 00:00:00', 0, 1);",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestExportLiquibase tests the changelog has one changeSet per applied script
+func TestExportLiquibase(t *testing.T) {
+	m := &Migrator{config: &config.Config{}}
+	var out strings.Builder
+	err := m.exportLiquibase([]ScriptRecord{{ScriptName: "001_create_users.sql"}, {ScriptName: "R__views.sql"}}, &out)
+	if err != nil {
+		t.Fatalf("exportLiquibase failed: %v", err)
+	}
+	for _, want := range []string{
+		`<changeSet id="001_create_users.sql" author="db-migration">`,
+		`<changeSet id="R__views.sql" author="db-migration" runOnChange="true">`,
+		`<sqlFile path="001_create_users.sql" relativeToChangelogFile="true"></sqlFile>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected changelog to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,638 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+var _ Repository = (*testkit.FakeGit)(nil)
+
+// newFakeMigrator returns a Migrator over a fake repository and a SQLite database, for
+// unit tests of the run logic that need neither MySQL nor git
+func newFakeMigrator(t *testing.T) (*Migrator, *testkit.FakeRepo, string, *testkit.TestDatabase) {
+	t.Helper()
+
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	cfg := &config.Config{ScriptsDir: scriptsDir}
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	m := NewMigrator(cfg, wrapDB(testDB), cons, WithRepository(repo.At(scriptsDir)),
+		WithClock(testkit.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))), WithIDGenerator(testkit.NewSequentialIDs("batch")))
+	return m, repo, scriptsDir, testDB
+}
+
+func TestRun_CommitOrder(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	// Committed later, so it runs after 002 despite its name
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	head := repo.CommitScripts("add users")
+
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("002_posts.sql", "001_users.sql")
+	testDB.AssertTableExists("users")
+	testDB.AssertBatches(1)
+
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := records[len(records)-1]
+	if !last.EndOfBatch || last.LastGitID != head || last.BatchID != "batch-1" {
+		t.Errorf("expected the last record to end batch-1 at %s, got %+v", head, last)
+	}
+	if records[0].EndOfBatch {
+		t.Error("only the last script should end the batch")
+	}
+}
+
+func TestRun_Incremental(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	// Nothing new: no records are added
+	if err := m.Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	testDB.AssertBatches(1)
+
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts")
+	if err := m.Run(); err != nil {
+		t.Fatalf("third run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql")
+	testDB.AssertBatches(2)
+}
+
+func TestRun_Filtering(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "001_users.down.sql", "DROP TABLE users;")
+	repo.AddSQLScript(scriptsDir, "README.md", "not a script")
+	repo.CommitScripts("add users")
+
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertNotApplied("001_users.down.sql", "README.md")
+}
+
+func TestRun_FailureKeepsEarlierScripts(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.CommitScripts("add broken")
+	repo.AddSQLScript(scriptsDir, "003_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts")
+
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertNoTable("posts")
+
+	// The failed batch did not end, so the next run starts from the same commit
+	lastGitID, err := m.tracker.GetLastSuccessfulCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastGitID != "" {
+		t.Errorf("expected no successful batch, got one at %s", lastGitID)
+	}
+}
+
+func TestRun_ModifiedScript(t *testing.T) {
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+
+	repo.ModifyFile("scripts/001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")
+	repo.CommitScripts("change users")
+	if err := m.Run(); err == nil {
+		t.Fatal("expected a modified script to fail the run")
+	}
+}
+
+func TestRun_Down(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "001_users.down.sql", "DROP TABLE users;")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if err := m.Down("", 0); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	testDB.AssertApplied()
+	testDB.AssertNoTable("users")
+}
+
+func TestRun_DownReadsCommittedScripts(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// An uncommitted down script would not be read by the revert, so it is missing
+	repo.AddSQLScript(scriptsDir, "001_users.down.sql", "DROP TABLE users;")
+	if err := m.Down("", 0); err == nil || !strings.Contains(err.Error(), "1 down scripts are missing") {
+		t.Fatalf("expected the uncommitted down script to be missing, got %v", err)
+	}
+	testDB.AssertApplied("001_users.sql")
+
+	// A committed one is reverted with, even once deleted from the checkout
+	repo.CommitScripts("add users down script")
+	repo.DeleteFile("scripts/001_users.down.sql")
+	if err := m.Down("", 0); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	testDB.AssertApplied()
+	testDB.AssertNoTable("users")
+}
+
+func TestRun_RepeatableScriptsInSubdirectories(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "a/R__views.sql", "CREATE VIEW a_views AS SELECT 1 AS id;")
+	repo.AddSQLScript(scriptsDir, "b/R__views.sql", "CREATE VIEW b_views AS SELECT 1 AS id;")
+	repo.CommitScripts("add views")
+	if err := m.Run(); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	testDB.AssertApplied("a/R__views.sql", "b/R__views.sql")
+
+	// Only the changed one re-runs; the other keeps its own checksum
+	repo.ModifyFile("scripts/b/R__views.sql", "DROP VIEW b_views; CREATE VIEW b_views AS SELECT 2 AS id;")
+	repo.CommitScripts("change b views")
+	if err := m.Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, rec := range records {
+		names = append(names, rec.ScriptName)
+	}
+	if want := []string{"a/R__views.sql", "b/R__views.sql", "b/R__views.sql"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
+
+func TestRun_DownCount(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "001_users.down.sql", "DROP TABLE users;")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "002_posts.down.sql", "DROP TABLE posts;")
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "003_tags.down.sql", "DROP TABLE tags;")
+	repo.CommitScripts("add posts and tags")
+	if err := m.Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+
+	// Half of the last batch is reverted, and comes back with the next run
+	if err := m.Down("", 1); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql")
+	testDB.AssertNoTable("tags")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run after down failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+
+	if err := m.Down("", 4); err == nil {
+		t.Error("expected reverting more scripts than are applied to fail")
+	}
+}
+
+func TestRun_ChecksumMismatch(t *testing.T) {
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// An edit git diff does not see, as after a history rewrite. Scripts are read from
+	// git, so the working tree only counts with --source worktree
+	if err := os.WriteFile(filepath.Join(scriptsDir, "001_users.sql"), []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatalf("expected the uncommitted edit to be ignored, got %v", err)
+	}
+	m.config.Source = config.SourceWorktree
+	err := m.Run()
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	m.config.NoVerifyChecksums = true
+	if err := m.Run(); err != nil {
+		t.Errorf("expected --no-verify-checksums to skip the check, got %v", err)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_slow.sql", "-- migrate:timeout 100ms\n"+
+		"WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT COUNT(*) FROM c;")
+	repo.CommitScripts("add slow script")
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the script to time out")
+	}
+
+	records, err := m.tracker.GetAllScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Completed || records[0].Failure != FailureTimeout {
+		t.Errorf("expected a timed out record, got %+v", records)
+	}
+}
+
+func TestRun_Interrupted(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewMigrator(&config.Config{ScriptsDir: scriptsDir}, wrapDB(testDB), cons, WithRepository(repo.At(scriptsDir)), WithContext(ctx))
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "002_slow.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);\n"+
+		"WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT COUNT(*) FROM c;")
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add scripts")
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the run to be interrupted")
+	}
+
+	// The slow script is rolled back and recorded as cancelled; the next is not started
+	records, err := m.tracker.GetAllScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[0].Completed || records[1].Completed || records[1].Failure != FailureCancelled {
+		t.Errorf("expected 001 applied and 002 cancelled, got %+v", records)
+	}
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertNoTable("posts")
+}
+
+func TestRun_Ref(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	newMigrator := func(ref string) *Migrator {
+		return NewMigrator(&config.Config{ScriptsDir: scriptsDir, Ref: ref}, wrapDB(testDB), cons, WithRepository(repo.At(scriptsDir)))
+	}
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	v1 := repo.CommitScripts("release 1")
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	v2 := repo.CommitScripts("release 2")
+	// Uncommitted edits of the working tree are not what the ref deploys
+	repo.ModifyFile("scripts/001_users.sql", "CREATE TABLE edited (id INTEGER PRIMARY KEY);")
+
+	if err := newMigrator(v1).Run(); err != nil {
+		t.Fatalf("run at %s failed: %v", v1, err)
+	}
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertTableExists("users")
+	testDB.AssertNoTable("edited")
+
+	if err := newMigrator(v2).Run(); err != nil {
+		t.Fatalf("run at %s failed: %v", v2, err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql")
+	testDB.AssertBatches(2)
+}
+
+func TestRun_SourceFilesystem(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	scriptsDir := t.TempDir()
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	m := NewMigrator(&config.Config{ScriptsDir: scriptsDir, Source: config.SourceFilesystem}, wrapDB(testDB), cons)
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(scriptsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without commits, scripts run in name order
+	write("002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	write("001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql")
+
+	write("003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	if err := m.Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	testDB.AssertBatches(2)
+
+	// Edits of applied scripts are caught by their checksums
+	write("001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")
+	if err := m.Run(); err == nil || !strings.Contains(err.Error(), "checksum changed") {
+		t.Fatalf("expected the edited script to fail the checksum check, got %v", err)
+	}
+}
+
+func TestRun_FS(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	fsys := fstest.MapFS{
+		"002_posts.sql":        {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY);")},
+		"001_users.sql":        {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);\n-- dbmig: include common/admin.sql\n")},
+		"common/admin.sql":     {Data: []byte("INSERT INTO users (id) VALUES (1);")},
+		"hooks/post-batch.sql": {Data: []byte("CREATE TABLE IF NOT EXISTS hooked (id INTEGER);")},
+	}
+	m := NewMigrator(&config.Config{IncludeDir: "common"}, wrapDB(testDB), cons, WithFS(fsys))
+
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql")
+	testDB.AssertTableExists("hooked")
+	if count, err := testDB.GetTableRowCount("users"); err != nil || count != 1 {
+		t.Errorf("expected the included insert to run, got %d rows (%v)", count, err)
+	}
+
+	fsys["001_users.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")}
+	if err := m.Run(); err == nil || !strings.Contains(err.Error(), "checksum changed") {
+		t.Fatalf("expected the changed script to fail the checksum check, got %v", err)
+	}
+}
+
+func TestRun_Namespaces(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	usersDir := repo.CreateScriptsDir("services/users/migrations")
+	billingDir := repo.CreateScriptsDir("services/billing/migrations")
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	newMigrator := func(namespace, dir string) *Migrator {
+		cfg := &config.Config{ScriptsDir: dir, Namespace: namespace}
+		return NewMigrator(cfg, wrapDB(testDB), cons, WithRepository(repo.At(dir)))
+	}
+
+	// Both services start with a script of the same name
+	repo.AddSQLScript(usersDir, "001_init.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(billingDir, "001_init.sql", "CREATE TABLE invoices (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add services")
+
+	users, billing := newMigrator("users", usersDir), newMigrator("billing", billingDir)
+	if err := users.Run(); err != nil {
+		t.Fatalf("users run failed: %v", err)
+	}
+	if err := billing.Run(); err != nil {
+		t.Fatalf("billing run failed: %v", err)
+	}
+	testDB.AssertTableExists("users")
+	testDB.AssertTableExists("invoices")
+
+	repo.AddSQLScript(billingDir, "002_payments.sql", "CREATE TABLE payments (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add payments")
+	pending, err := users.inspector().PendingCount(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("expected no pending users scripts, got %d", pending)
+	}
+	if err := billing.Run(); err != nil {
+		t.Fatalf("second billing run failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		m    *Migrator
+		want string
+	}{
+		{users, "001_init.sql"},
+		{billing, "001_init.sql,002_payments.sql"},
+	} {
+		applied, err := tc.m.tracker.GetAppliedScripts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, rec := range applied {
+			names = append(names, rec.ScriptName)
+		}
+		if strings.Join(names, ",") != tc.want {
+			t.Errorf("expected %s applied in namespace %s, got %v", tc.want, tc.m.config.Namespace, names)
+		}
+	}
+}
+
+func TestRun_RecordsExecutor(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	executor := Executor{User: "deploy", Host: "ci-runner-7", Version: "v1.4.0"}
+	m := NewMigrator(&config.Config{ScriptsDir: scriptsDir}, wrapDB(testDB), cons, WithRepository(repo.At(scriptsDir)), WithExecutor(executor))
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	records, err := m.tracker.GetAllScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ExecutedBy != "deploy" || records[0].ExecutedHost != "ci-runner-7" || records[0].ToolVersion != "v1.4.0" {
+		t.Errorf("expected the executor in the record, got %+v", records)
+	}
+}
+
+func TestRun_RetriesTransientErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.db")
+	database, err := db.Open(db.DriverSQLite, path+"?_busy_timeout=0&_journal_mode=WAL", nil)
+	if err != nil && strings.Contains(err.Error(), "CGO_ENABLED=0") {
+		t.Skip("SQLite needs cgo")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	other, err := db.Open(db.DriverSQLite, path+"?_busy_timeout=0&_journal_mode=WAL", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	var out strings.Builder
+	cons := console.New()
+	cons.SetOutput(&out)
+	cfg := &config.Config{ScriptsDir: scriptsDir, Retries: 3, RetryDelay: 100 * time.Millisecond}
+
+	// Another session holds the write lock while the script starts, like a deadlock would
+	m := NewMigrator(cfg, database, cons, WithRepository(repo.At(scriptsDir)), WithProgress(func(p ScriptProgress) {
+		if p.Status != ProgressExecuting {
+			return
+		}
+		tx, err := other.Begin()
+		if err == nil {
+			_, err = tx.Exec("CREATE TABLE held (id INTEGER)")
+		}
+		if err != nil {
+			t.Errorf("failed to take the write lock: %v", err)
+			return
+		}
+		time.AfterFunc(150*time.Millisecond, func() { tx.Rollback() })
+	}))
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	records, err := m.tracker.GetAllScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Completed {
+		t.Errorf("expected one completed record and no failed attempt, got %+v", records)
+	}
+	if !strings.Contains(out.String(), "retrying") {
+		t.Errorf("expected the retry to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestCommitsImplicitly(t *testing.T) {
+	tests := map[string]bool{
+		"ALTER TABLE users ADD COLUMN email VARCHAR(255)":     true,
+		"/* audit */ create index idx_email ON users (email)": true,
+		"INSERT INTO users (id) VALUES (1)":                   false,
+		"UPDATE users SET email = 'CREATE'":                   false,
+	}
+	for stmt, want := range tests {
+		if got := commitsImplicitly(stmt); got != want {
+			t.Errorf("commitsImplicitly(%q) = %v, want %v", stmt, got, want)
+		}
+	}
+}
+
+func TestRetrySafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  string
+		content string
+		ran     int
+		want    bool
+	}{
+		{"DML only", db.DriverMySQL, "INSERT INTO users (id) VALUES (1);\nINSERT INTO users (id) VALUES (2);", 1, true},
+		{"DDL before the failure", db.DriverMySQL, "ALTER TABLE users ADD COLUMN email VARCHAR(255);\nINSERT INTO users (id) VALUES (1);", 1, false},
+		// The INSERT is committed before the ALTER starts, even if the ALTER then fails
+		{"DML then failing DDL", db.DriverMySQL, "INSERT INTO users (id) VALUES (1);\nALTER TABLE users ADD COLUMN email VARCHAR(255);", 1, false},
+		{"failing DDL first", db.DriverMySQL, "ALTER TABLE users ADD COLUMN email VARCHAR(255);", 0, false},
+		{"transactional DDL", db.DriverPostgres, "INSERT INTO users (id) VALUES (1);\nALTER TABLE users ADD COLUMN email TEXT;", 1, true},
+	}
+	for _, tc := range tests {
+		if got := retrySafe(tc.driver, tc.content, tc.ran); got != tc.want {
+			t.Errorf("%s: retrySafe = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRun_ErrorClasses(t *testing.T) {
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	for range 2 {
+		if err := m.Run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+	}
+	if m.Pending() != 0 {
+		t.Errorf("expected nothing to do, got %d pending", m.Pending())
+	}
+
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.AddSQLScript(scriptsDir, "003_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add scripts")
+	err := m.Run()
+	var script *ScriptError
+	if !errors.As(err, &script) || script.Script != "002_broken.sql" {
+		t.Fatalf("expected a script error, got %v", err)
+	}
+	if m.Pending() != 2 {
+		t.Errorf("expected 2 pending scripts, got %d", m.Pending())
+	}
+
+	// The failed batch stops the next run before its first script
+	var validation *ValidationError
+	if err := m.Run(); !errors.As(err, &validation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
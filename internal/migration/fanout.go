@@ -0,0 +1,118 @@
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+// Target outcomes of a fan-out
+const (
+	TargetSuccess = "success"
+	TargetFailed  = "failed"
+	TargetSkipped = "skipped" // Not started after another target failed
+)
+
+// TargetResult is the outcome of one target of a fan-out
+type TargetResult struct {
+	Target   string
+	Status   string
+	Duration time.Duration
+	Report   *RunReport // nil when skipped
+}
+
+// FanOut runs run against every target, parallel at a time, with each target's output
+// prefixed by its name on out. After a failure, targets not started yet are skipped
+// unless continueOnError. Results are in the order of targets
+func FanOut(out io.Writer, targets []config.Target, parallel int, continueOnError bool, run func(target config.Target, log io.Writer) RunReport) []TargetResult {
+	results := make([]TargetResult, len(targets))
+	var outMu, failedMu sync.Mutex
+	failed := false
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for i, target := range targets {
+		slots <- struct{}{}
+		failedMu.Lock()
+		skip := failed && !continueOnError
+		failedMu.Unlock()
+		if skip {
+			<-slots
+			results[i] = TargetResult{Target: target.Name, Status: TargetSkipped}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			started := time.Now()
+			report := run(target, &prefixWriter{mu: &outMu, w: out, prefix: "[" + target.Name + "] "})
+			status := TargetSuccess
+			if report.Status != TargetSuccess {
+				status = TargetFailed
+				failedMu.Lock()
+				failed = true
+				failedMu.Unlock()
+			}
+			results[i] = TargetResult{Target: target.Name, Status: status, Duration: time.Since(started), Report: &report}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// WriteMatrix writes a summary table of a fan-out: one row per target with its status,
+// applied and failed script counts, duration and error
+func WriteMatrix(w io.Writer, results []TargetResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tAPPLIED\tFAILED\tDURATION\tERROR")
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		applied, failed, errMsg := 0, 0, ""
+		if r.Report != nil {
+			for _, s := range r.Report.Scripts {
+				switch s.Status {
+				case ResultSuccess:
+					applied++
+				case ResultFailed, ResultTolerated:
+					failed++
+				}
+			}
+			errMsg = r.Report.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", r.Target, r.Status, applied, failed, r.Duration.Round(time.Millisecond), errMsg)
+	}
+	fmt.Fprintf(tw, "\n%d targets: %d succeeded, %d failed, %d skipped\n", len(results), counts[TargetSuccess], counts[TargetFailed], counts[TargetSkipped])
+	return tw.Flush()
+}
+
+// prefixWriter prefixes every line with a target's name; writes of parallel targets
+// share mu, so their lines do not interleave
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if line != "" {
+			buf.WriteString(p.prefix)
+			buf.WriteString(line)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
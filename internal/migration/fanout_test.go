@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+func TestFanOut(t *testing.T) {
+	targets := []config.Target{{Name: "shard1"}, {Name: "shard2"}, {Name: "shard3"}}
+	run := func(target config.Target, log io.Writer) RunReport {
+		io.WriteString(log, "\nmigrating\n")
+		if target.Name == "shard1" {
+			return RunReport{Status: TargetFailed, Error: "boom", Scripts: []ReportScript{{Status: ResultFailed}}}
+		}
+		return RunReport{Status: TargetSuccess, Scripts: []ReportScript{{Status: ResultSuccess}, {Status: ResultSuccess}}}
+	}
+
+	// Serially, the first failure skips the rest
+	var out bytes.Buffer
+	results := FanOut(&out, targets, 1, false, run)
+	if results[0].Status != TargetFailed || results[1].Status != TargetSkipped || results[2].Status != TargetSkipped {
+		t.Errorf("expected the failure to skip the remaining targets, got %+v", results)
+	}
+	if !strings.Contains(out.String(), "[shard1] migrating\n") || strings.Contains(out.String(), "shard2") {
+		t.Errorf("expected prefixed output of shard1 only, got %q", out.String())
+	}
+
+	// With continue-on-error every target runs, in parallel
+	var running, peak int32
+	results = FanOut(io.Discard, targets, 3, true, func(target config.Target, log io.Writer) RunReport {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		defer atomic.AddInt32(&running, -1)
+		return run(target, log)
+	})
+	if results[1].Status != TargetSuccess || results[2].Status != TargetSuccess {
+		t.Errorf("expected every target to run, got %+v", results)
+	}
+	if peak > 3 {
+		t.Errorf("expected at most 3 targets at a time, got %d", peak)
+	}
+
+	var matrix bytes.Buffer
+	WriteMatrix(&matrix, results)
+	for _, want := range []string{"TARGET", "shard1  failed   0        1", "shard2  success  2        0", "3 targets: 2 succeeded, 1 failed, 0 skipped"} {
+		if !strings.Contains(matrix.String(), want) {
+			t.Errorf("expected %q in the matrix:\n%s", want, matrix.String())
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// WithFS makes a Migrator or Inspector read scripts from fsys instead of git, e.g. an
+// embed.FS of migrations shipped inside the binary. Its root is the scripts directory;
+// pass fs.Sub for a subdirectory. Without history, scripts run in lexicographic order
+// of their paths, and modified ones are found by their checksums
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) {
+		o.fsys = fsys
+	}
+}
+
+// fsRepository is a Repository of the plain files of a file system, for WithFS and
+// --source filesystem. In place of a commit, the current state is a hash of the
+// content, so it changes with every edit. dir is the scripts directory the files are
+// reported under, which paths within ScriptsDir are mapped to
+type fsRepository struct {
+	fsys fs.FS
+	dir  string
+}
+
+// newFSRepository returns the Repository of fsys as the scripts directory dir
+func newFSRepository(fsys fs.FS, dir string) fsRepository {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return fsRepository{fsys: fsys, dir: dir}
+}
+
+// newFilesystemRepository returns the Repository of the files under dir
+func newFilesystemRepository(dir string) fsRepository {
+	repo := newFSRepository(nil, dir)
+	repo.fsys = os.DirFS(repo.dir)
+	return repo
+}
+
+// GetCurrentCommit returns the SHA-256 of the paths and content of all files
+func (r fsRepository) GetCurrentCommit() (string, error) {
+	files, err := r.ListFiles("")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, file := range files {
+		content, err := fs.ReadFile(r.fsys, file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", file, len(content))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ResolveCommit resolves HEAD and the current hash only, since there is no history
+func (r fsRepository) ResolveCommit(ref string) (string, error) {
+	current, err := r.GetCurrentCommit()
+	if err != nil {
+		return "", err
+	}
+	if ref == "HEAD" || ref == current {
+		return current, nil
+	}
+	return "", fmt.Errorf("cannot resolve %s: scripts without git have no commits", ref)
+}
+
+// ReadFile reads a file, given relative to the scripts directory
+func (r fsRepository) ReadFile(_, path string) ([]byte, error) {
+	return fs.ReadFile(r.fsys, path)
+}
+
+// Prefix returns "", since the scripts directory is the root
+func (r fsRepository) Prefix() (string, error) {
+	return "", nil
+}
+
+// TopLevel returns the scripts directory
+func (r fsRepository) TopLevel() (string, error) {
+	return r.dir, nil
+}
+
+// IsGitRepository reports whether the scripts directory exists
+func (r fsRepository) IsGitRepository() bool {
+	info, err := fs.Stat(r.fsys, ".")
+	return err == nil && info.IsDir()
+}
+
+// ListFiles returns the files of the scripts directory as sorted slash paths relative
+// to it. Hidden files and directories, such as .git, are left out
+func (r fsRepository) ListFiles(string) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(r.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.dir, err)
+	}
+	return files, nil
+}
+
+// DiffFileStatus reports no changes: without history, modified scripts are found by
+// their checksums
+func (r fsRepository) DiffFileStatus(_, _ string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// GetChangedScripts returns all scripts in lexicographic order of their paths, with
+// the filters of git.Git.GetChangedScripts; applied ones are filtered out by name
+func (r fsRepository) GetChangedScripts(_, _, _ string) ([]git.ScriptInfo, error) {
+	files, err := r.ListFiles("")
+	if err != nil {
+		return nil, err
+	}
+	var scripts []git.ScriptInfo
+	for _, file := range files {
+		if !git.IsScript(file) || git.IsDownScript(file) || git.IsRepeatable(file) {
+			continue
+		}
+		timestamp, _ := r.GetFileCommitTimestamp(file)
+		scripts = append(scripts, git.ScriptInfo{
+			Name:      path.Base(file),
+			Path:      file,
+			Timestamp: timestamp,
+		})
+	}
+	return scripts, nil
+}
+
+// GetFileCommitTimestamp returns the modification time of a file, given relative to
+// the scripts directory or absolute, or the current time if it cannot be read.
+// Embedded files have none, so it is zero for them
+func (r fsRepository) GetFileCommitTimestamp(file string) (time.Time, error) {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(r.dir, file)
+		if err != nil {
+			return time.Now(), nil
+		}
+		file = rel
+	}
+	info, err := fs.Stat(r.fsys, filepath.ToSlash(file))
+	if err != nil {
+		return time.Now(), nil
+	}
+	return info.ModTime(), nil
+}
+
+// CommitMessages returns nothing, since files have no commits
+func (r fsRepository) CommitMessages(string) ([]string, error) {
+	return nil, nil
+}
+
+// Upstream returns "", since there is no remote
+func (r fsRepository) Upstream() string {
+	return ""
+}
+
+// Pull does nothing, since there is no remote
+func (r fsRepository) Pull() error {
+	return nil
+}
@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Fingerprint returns the SHA-256 of a script's normalized statements
+// Comments, whitespace, keyword/identifier case, identifier backticks and the
+// final semicolon do not affect it; string literals are kept verbatim, so data
+// scripts with different values get different fingerprints
+func Fingerprint(content []byte) string {
+	statements := splitStatements(string(content))
+	if len(statements) == 0 {
+		return ""
+	}
+
+	normalized := make([]string, len(statements))
+	for i, stmt := range statements {
+		normalized[i] = normalizeStatement(stmt)
+	}
+	return Checksum([]byte(strings.Join(normalized, ";\n")))
+}
+
+// normalizeStatement lower-cases ASCII letters outside string literals, drops
+// identifier backticks and collapses whitespace; whitespace only survives as a
+// single space between two word characters
+func normalizeStatement(stmt string) string {
+	var out strings.Builder
+	space := false
+	last := byte(0)
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		switch {
+		case c == '`':
+			continue
+		case unicode.IsSpace(rune(c)):
+			space = true
+			continue
+		}
+
+		if space && isWordByte(last) && isWordByte(c) {
+			out.WriteByte(' ')
+		}
+		space = false
+
+		if c == '\'' || c == '"' {
+			end := db.SkipQuoted(stmt, i)
+			out.WriteString(stmt[i:end])
+			last = stmt[end-1]
+			i = end - 1
+			continue
+		}
+
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out.WriteByte(c)
+		last = c
+	}
+	return out.String()
+}
+
+// checkDuplicates fails when a pending script is byte-different from, but
+// statement-for-statement identical to, a script that is already applied
+// (typically a rename plus a history rewrite), so it is not applied twice
+func (m *Migrator) checkDuplicates(scripts []git.ScriptInfo) error {
+	if m.config.AllowDuplicates {
+		return nil
+	}
+
+	applied, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return err
+	}
+	byFingerprint := make(map[string]string)
+	for _, rec := range applied {
+		if rec.Fingerprint != "" && !git.IsRepeatable(rec.ScriptName) {
+			byFingerprint[rec.Fingerprint] = rec.ScriptName
+		}
+	}
+	if len(byFingerprint) == 0 {
+		return nil
+	}
+
+	var duplicates int
+	for _, script := range scripts {
+		if git.IsRepeatable(script.Name) {
+			continue
+		}
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		original, ok := byFingerprint[Fingerprint(content)]
+		if !ok || original == script.Name {
+			continue
+		}
+		if duplicates == 0 {
+			m.console.Error("The following pending scripts duplicate applied scripts:")
+		}
+		duplicates++
+		m.console.Failure("  - %s has the same statements as %s", script.Name, original)
+		m.validator.report(Finding{RuleID: RuleDuplicateScript, Level: "error", Path: script.Path,
+			Message: fmt.Sprintf("Script has the same statements as applied script %s", original)})
+	}
+
+	if duplicates > 0 {
+		return fmt.Errorf("%d pending scripts duplicate applied scripts - if they were renamed, restore the original names; to apply them anyway use --allow-duplicates", duplicates)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package migration
+
+import "testing"
+
+// TestFingerprint tests that formatting differences do not change the fingerprint
+func TestFingerprint(t *testing.T) {
+	original := []byte("CREATE TABLE users (\n    id INT PRIMARY KEY,\n    name VARCHAR(100)\n);\nINSERT INTO users VALUES (1, 'Alice');\n")
+	reformatted := []byte("-- renamed from 001_create_users.sql\r\ncreate table `users` (id int primary key, name varchar(100));\n/* seed */ insert into users values (1, 'Alice')")
+
+	if Fingerprint(original) != Fingerprint(reformatted) {
+		t.Error("expected reformatted script to have the same fingerprint")
+	}
+	if Fingerprint(original) == Fingerprint([]byte("CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(100)); INSERT INTO users VALUES (1, 'alice');")) {
+		t.Error("expected different string literals to change the fingerprint")
+	}
+	if Fingerprint([]byte("-- only a comment\n")) != "" {
+		t.Error("expected empty fingerprint for a script without statements")
+	}
+}
@@ -0,0 +1,184 @@
+package migration
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+// goldenTime is the frozen clock of every snapshot
+var goldenTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// golden compares got with testdata/golden/<name>; UPDATE_GOLDEN=1 rewrites it
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	testkit.Golden(t, filepath.Join("testdata", "golden", name), got)
+}
+
+// newGoldenMigrator returns a Migrator like newFakeMigrator, printing to a captured
+// console that shares the migrator's fake clock
+func newGoldenMigrator(t *testing.T) (*Migrator, *testkit.FakeRepo, string, *testkit.CapturedConsole) {
+	t.Helper()
+
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.NewFakeRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	cons := testkit.NewCapturedConsole(goldenTime)
+	cfg := &config.Config{ScriptsDir: scriptsDir, DBName: "app", Host: "db.internal", User: "deploy"}
+	m := NewMigrator(cfg, wrapDB(testDB), cons.Console, WithRepository(repo.At(scriptsDir)),
+		WithClock(cons.Clock), WithIDGenerator(testkit.NewSequentialIDs("batch")))
+	return m, repo, scriptsDir, cons
+}
+
+// scrubPaths replaces the temporary directory of a fake repository, which differs on
+// every run
+func scrubPaths(s string, repo *testkit.FakeRepo) string {
+	return strings.ReplaceAll(s, repo.Dir, "<repo>")
+}
+
+func TestGolden_RunTranscript(t *testing.T) {
+	m, repo, scriptsDir, cons := newGoldenMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts")
+
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	golden(t, "run.txt", []byte(scrubPaths(cons.Output(), repo)))
+}
+
+func TestGolden_FailedRun(t *testing.T) {
+	m, repo, scriptsDir, cons := newGoldenMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.CommitScripts("add broken")
+
+	runErr := m.Run()
+	if runErr == nil {
+		t.Fatal("expected the run to fail")
+	}
+	cons.Error("Migration failed: %v", runErr)
+	golden(t, "run_failed.txt", []byte(scrubPaths(cons.Output(), repo)))
+}
+
+func TestGolden_RunJSON(t *testing.T) {
+	m, repo, scriptsDir, cons := newGoldenMigrator(t)
+	cons.SetJSON()
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.CommitScripts("add broken")
+
+	runErr := m.Run()
+	if runErr == nil {
+		t.Fatal("expected the run to fail")
+	}
+	cons.Error("Migration failed: %v", runErr)
+	golden(t, "run.jsonl", []byte(scrubPaths(cons.Output(), repo)))
+}
+
+func TestGolden_RunReport(t *testing.T) {
+	m, repo, scriptsDir, _ := newGoldenMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.CommitScripts("add broken")
+
+	report := m.Report(m.Run())
+	var buf bytes.Buffer
+	if err := (RolloutReport{Status: StageFailed, StartedAt: goldenTime, Stages: []RolloutStage{
+		{Target: "canary", Status: StageFailed, DurationMS: 1500, Report: &report, Error: report.Error},
+		{Target: "prod", Status: StageNotStarted},
+	}}).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "rollout.json", []byte(scrubPaths(buf.String(), repo)))
+}
+
+func TestGolden_Summary(t *testing.T) {
+	cons := testkit.NewCapturedConsole(goldenTime)
+	cons.Summary(4, 2, 1, 1, nil)
+	golden(t, "summary.txt", []byte(cons.Output()))
+}
+
+func TestGolden_SummarySlow(t *testing.T) {
+	cons := testkit.NewCapturedConsole(goldenTime)
+	cons.Summary(3, 3, 0, 0, []console.SlowScript{{Script: "002_backfill.sql", DurationMS: 95250}, {Script: "003_index.sql", DurationMS: 31000}})
+	golden(t, "summary_slow.txt", []byte(cons.Output()))
+}
+
+func TestGolden_Matrix(t *testing.T) {
+	results := []TargetResult{
+		{Target: "eu", Status: TargetSuccess, Duration: 1234 * time.Millisecond, Report: &RunReport{Status: "success", Scripts: []ReportScript{
+			{Name: "001_users.sql", Status: ResultSuccess}, {Name: "002_posts.sql", Status: ResultSuccess},
+		}}},
+		{Target: "us", Status: TargetFailed, Duration: 2 * time.Second, Report: &RunReport{Status: "failed", Error: "script 002_posts.sql failed: table exists", Scripts: []ReportScript{
+			{Name: "001_users.sql", Status: ResultSuccess}, {Name: "002_posts.sql", Status: ResultFailed},
+		}}},
+		{Target: "ap", Status: TargetSkipped},
+	}
+	var buf bytes.Buffer
+	if err := WriteMatrix(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "matrix.txt", buf.Bytes())
+}
+
+func TestGolden_Rollout(t *testing.T) {
+	report := RolloutReport{Status: StageFailed, StartedAt: goldenTime, Stages: []RolloutStage{
+		{Target: "canary", Status: StageSuccess, DurationMS: 800, Report: &RunReport{Scripts: []ReportScript{{Name: "001_users.sql", Status: ResultSuccess}}},
+			Verify: []VerifyResult{{Script: "001_users.verify.sql", Passed: true}}},
+		{Target: "staging", Status: StageReverted, DurationMS: 2100, Report: &RunReport{Scripts: []ReportScript{{Name: "001_users.sql", Status: ResultSuccess}}},
+			Verify: []VerifyResult{{Script: "001_users.verify.sql", Passed: false}}, Error: "verify script 001_users.verify.sql failed"},
+		{Target: "prod", Status: StageNotStarted},
+	}}
+	var buf bytes.Buffer
+	if err := WriteRollout(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "rollout.txt", buf.Bytes())
+}
+
+func TestGolden_Plan(t *testing.T) {
+	plan := &Plan{
+		Database:   "app",
+		FromCommit: "1111111",
+		ToCommit:   "2222222",
+		Changes: []PlannedChange{
+			{Script: "001_users.sql", Action: "create", Risk: RiskLow,
+				content: []byte("CREATE TABLE users (id INT);\n"), checksum: "aaaa"},
+			{Script: "002_drop_legacy.sql", Action: "create", Risk: RiskHigh, Reasons: []string{"drops a table"},
+				content: []byte("DROP TABLE legacy;"), checksum: "bbbb"},
+			{Script: "R__views.sql", Action: "update", Risk: RiskMedium, Reasons: []string{"changed repeatable script"},
+				content: []byte("CREATE OR REPLACE VIEW v AS SELECT 1;\n"), checksum: "cccc"},
+		},
+		Errors: []string{"script 003_old.sql was modified after it ran"},
+	}
+
+	for _, tc := range []struct{ format, file string }{
+		{config.PlanText, "plan.txt"},
+		{config.PlanTFJSON, "plan.tfplan.json"},
+		{config.PlanSQL, "plan.sql"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WritePlan(&buf, plan, tc.format); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, tc.file, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// evaluateSkipIf runs the script's skip-if query and reports whether the script
+// should be skipped (a non-zero, non-NULL first column)
+func (m *Migrator) evaluateSkipIf(script git.ScriptInfo) (bool, error) {
+	query := script.Directives.SkipIf
+	if query == "" {
+		return false, nil
+	}
+
+	var result sql.NullString
+	if err := m.db.QueryRow(query).Scan(&result); err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("skip-if query for %s failed: %w", script.Name, err)
+	}
+	if !result.Valid {
+		return false, nil
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(result.String), 64)
+	if err != nil {
+		return false, fmt.Errorf("skip-if query for %s must return a number, got %q", script.Name, result.String)
+	}
+
+	return value != 0, nil
+}
+
+// recordSkipped tracks a script as skipped by its guard so it is not pending again
+func (m *Migrator) recordSkipped(t *Tracker, script git.ScriptInfo, rec ScriptRecord) error {
+	content, err := m.readScript(script)
+	if err != nil {
+		return err
+	}
+
+	rec.Completed = true
+	rec.Action = ActionSkip
+	rec.BatchID = m.batchID
+	rec.Checksum, err = m.scriptChecksum(script, content)
+	if err != nil {
+		return err
+	}
+	rec.Fingerprint = Fingerprint(content)
+
+	return t.RecordExecutionDirect(rec)
+}
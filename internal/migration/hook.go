@@ -0,0 +1,77 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// HooksDir holds batch hook scripts, relative to the scripts directory
+const HooksDir = "hooks"
+
+// Batch hook scripts run around every batch that executes scripts
+const (
+	PreBatchHook  = "pre-batch.sql"
+	PostBatchHook = "post-batch.sql"
+)
+
+// runBatchHook executes a hook script in its own transaction if it exists
+// Hooks are not recorded in the tracking table; they run on every batch
+func (m *Migrator) runBatchHook(name string) error {
+	path, err := filepath.Abs(filepath.Join(m.config.ScriptsDir, HooksDir, name))
+	if err != nil {
+		return fmt.Errorf("failed to resolve hook %s: %w", name, err)
+	}
+	// The hook exists where scripts are read from: the commit, working tree or WithFS
+	if _, err := m.readSource(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	label := HooksDir + "/" + name
+	m.console.Script(label, "executing")
+
+	content, err := m.readScript(git.ScriptInfo{Name: name, Path: path})
+	if err == nil {
+		err = m.executeHook(string(content))
+	}
+	if err != nil {
+		m.console.Script(label, "failed")
+		m.console.Error("Batch hook failed: %v", err)
+		return fmt.Errorf("batch hook %s failed: %w", label, err)
+	}
+
+	m.console.Script(label, "success")
+	return nil
+}
+
+// executeHook runs hook SQL in a transaction
+func (m *Migrator) executeHook(content string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.db.ExecuteSQLTx(m.ctx, tx, content); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isHook reports whether a repository path lies in the hooks directory
+func (m *Migrator) isHook(repoPath string) bool {
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return false
+	}
+	dir := filepath.ToSlash(filepath.Join(prefix, HooksDir)) + "/"
+	return strings.HasPrefix(filepath.ToSlash(repoPath), dir)
+}
@@ -0,0 +1,278 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// ImportReport reconciles another tool's applied versions with the scripts directory
+type ImportReport struct {
+	Matched    []git.ScriptInfo   // Scripts whose version is applied, in version order
+	Unmatched  []int64            // Applied versions without a script
+	Ambiguous  map[int64][]string // Applied versions claimed by several scripts
+	NotApplied []string           // Scripts whose version is not applied; they stay pending
+}
+
+// OK reports whether every applied version maps to exactly one script
+func (r *ImportReport) OK() bool {
+	return len(r.Unmatched) == 0 && len(r.Ambiguous) == 0
+}
+
+// Import adopts the applied state of golang-migrate or goose by writing tracking rows
+// for the scripts whose versions the source tool applied
+// Nothing is written unless every applied version maps to exactly one script
+func (m *Migrator) Import(source string, confirm ConfirmFunc) error {
+	m.console.Header("Import from %s", source)
+	m.forgetCommit()
+	m.batchID = m.ids.NewID()
+
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+	hasRecords, err := m.tracker.HasRecords()
+	if err != nil {
+		return err
+	}
+	if hasRecords {
+		return fmt.Errorf("tracking table %s already has records; import only adopts a fresh database", m.tracker.tableName)
+	}
+
+	versions, err := m.sourceVersions(source)
+	if err != nil {
+		return err
+	}
+	m.console.Info("%s has %d applied versions", source, len(versions))
+
+	report, err := m.reconcile(versions)
+	if err != nil {
+		return err
+	}
+	m.printImportReport(report)
+	if !report.OK() {
+		return fmt.Errorf("cannot import: %d applied versions have no script and %d match several scripts", len(report.Unmatched), len(report.Ambiguous))
+	}
+	if len(report.Matched) == 0 {
+		m.console.Success("Nothing to import")
+		return nil
+	}
+
+	if !confirm(fmt.Sprintf("Record %d scripts as applied?", len(report.Matched))) {
+		return fmt.Errorf("import cancelled")
+	}
+
+	// No commit is recorded, so the next run compares the whole scripts directory
+	// against these rows and picks up any script that is not applied
+	for i, script := range report.Matched {
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		rec := ScriptRecord{
+			ScriptName:  script.Name,
+			Completed:   true,
+			EndOfBatch:  i == len(report.Matched)-1,
+			BatchID:     m.batchID,
+			Checksum:    Checksum(content),
+			Fingerprint: Fingerprint(content),
+		}
+		if err := m.tracker.RecordExecutionDirect(rec); err != nil {
+			return fmt.Errorf("failed to record %s: %w", script.Name, err)
+		}
+		m.console.Script(script.Name, "success")
+	}
+
+	m.console.Success("Imported %d scripts from %s", len(report.Matched), source)
+	return nil
+}
+
+// sourceVersions reads the versions the source tool considers applied
+func (m *Migrator) sourceVersions(source string) ([]int64, error) {
+	switch source {
+	case config.ImportGolangMigrate:
+		// A single row holds the current version; every version up to it is applied
+		var version int64
+		var dirty bool
+		err := m.db.QueryRow("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		if dirty {
+			return nil, fmt.Errorf("schema_migrations is dirty at version %d; fix it with golang-migrate before importing", version)
+		}
+		if version <= 0 {
+			return nil, nil
+		}
+		return m.versionsUpTo(version)
+
+	case config.ImportGoose:
+		// The latest row per version decides whether it is applied; version 0 is goose's own marker
+		rows, err := m.db.Query("SELECT version_id, is_applied FROM goose_db_version ORDER BY id ASC")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read goose_db_version: %w", err)
+		}
+		defer rows.Close()
+
+		applied := make(map[int64]bool)
+		for rows.Next() {
+			var version int64
+			var isApplied bool
+			if err := rows.Scan(&version, &isApplied); err != nil {
+				return nil, fmt.Errorf("failed to scan goose_db_version: %w", err)
+			}
+			if version != 0 {
+				applied[version] = isApplied
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		var versions []int64
+		for version, ok := range applied {
+			if ok {
+				versions = append(versions, version)
+			}
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+		return versions, nil
+	}
+
+	return nil, fmt.Errorf("unknown import source %q", source)
+}
+
+// versionsUpTo lists script versions up to and including current, plus current
+// itself so a missing script for the current version is reported
+func (m *Migrator) versionsUpTo(current int64) ([]int64, error) {
+	scripts, err := m.versionedScripts()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[int64]bool{current: true}
+	versions := []int64{current}
+	for version := range scripts {
+		if version <= current && !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions, nil
+}
+
+// reconcile maps applied versions to scripts in the scripts directory
+func (m *Migrator) reconcile(versions []int64) (*ImportReport, error) {
+	scripts, err := m.versionedScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Ambiguous: make(map[int64][]string)}
+	applied := make(map[int64]bool)
+	for _, version := range versions {
+		applied[version] = true
+		names := scripts[version]
+		switch len(names) {
+		case 0:
+			report.Unmatched = append(report.Unmatched, version)
+		case 1:
+			path, err := filepath.Abs(filepath.Join(m.config.ScriptsDir, names[0]))
+			if err != nil {
+				return nil, err
+			}
+			report.Matched = append(report.Matched, git.ScriptInfo{Name: names[0], Path: path})
+		default:
+			report.Ambiguous[version] = names
+		}
+	}
+
+	for version, names := range scripts {
+		if !applied[version] {
+			report.NotApplied = append(report.NotApplied, names...)
+		}
+	}
+	sort.Strings(report.NotApplied)
+
+	return report, nil
+}
+
+// versionedScripts groups the scripts directory's scripts and bundles by their numeric prefix
+// Down scripts, repeatable scripts and unnumbered files are ignored
+func (m *Migrator) versionedScripts() (map[int64][]string, error) {
+	entries, err := os.ReadDir(m.config.ScriptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scripts directory: %w", err)
+	}
+
+	scripts := make(map[int64][]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if !bundleDirPattern.MatchString(name) {
+				continue
+			}
+		} else if !git.IsScript(name) || git.IsDownScript(name) || git.IsRepeatable(name) {
+			continue
+		}
+
+		version, ok := scriptVersion(name)
+		if ok {
+			scripts[version] = append(scripts[version], name)
+		}
+	}
+	for _, names := range scripts {
+		sort.Strings(names)
+	}
+	return scripts, nil
+}
+
+// scriptVersion parses the leading digits of a script name (001_x.sql is version 1)
+func scriptVersion(name string) (int64, bool) {
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end <= 0 {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(name[:end], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// printImportReport lists how applied versions map to scripts
+func (m *Migrator) printImportReport(r *ImportReport) {
+	m.console.Info("%d applied versions match a script", len(r.Matched))
+	if len(r.Unmatched) > 0 {
+		m.console.Error("Applied versions without a script:")
+		for _, version := range r.Unmatched {
+			m.console.Failure("  - %d", version)
+		}
+	}
+	if len(r.Ambiguous) > 0 {
+		m.console.Error("Applied versions matching several scripts:")
+		var versions []int64
+		for version := range r.Ambiguous {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+		for _, version := range versions {
+			m.console.Failure("  - %d: %s", version, strings.Join(r.Ambiguous[version], ", "))
+		}
+	}
+	if len(r.NotApplied) > 0 {
+		m.console.Warn("Scripts not applied by the source tool (they stay pending):")
+		for _, name := range r.NotApplied {
+			m.console.Info("  - %s", name)
+		}
+	}
+}
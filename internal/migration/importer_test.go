@@ -0,0 +1,92 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+// TestScriptVersion tests version parsing from script names
+func TestScriptVersion(t *testing.T) {
+	tests := map[string]int64{"001_create_users.sql": 1, "20240101120000_add_orders.up.sql": 20240101120000, "015_bundle": 15}
+	for name, want := range tests {
+		if got, ok := scriptVersion(name); !ok || got != want {
+			t.Errorf("scriptVersion(%q) = %d, %v; want %d", name, got, ok, want)
+		}
+	}
+	if _, ok := scriptVersion("R__views.sql"); ok {
+		t.Error("expected unnumbered script to have no version")
+	}
+}
+
+// TestImport_Goose tests adopting goose state, including the unmatchable-version report
+func TestImport_Goose(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL with goose state: 1 and 2 applied, 3 rolled back
+	testDB := testkit.SetupTestDB(t)
+	if err := testDB.Exec(`CREATE TABLE goose_db_version (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL, is_applied BOOLEAN NOT NULL, tstamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("failed to create goose table: %v", err)
+	}
+	if err := testDB.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1), (1, 1), (2, 1), (3, 1), (3, 0)"); err != nil {
+		t.Fatalf("failed to insert goose rows: %v", err)
+	}
+	if err := testDB.Exec(testkit.SQLScripts.CreateUsers); err != nil {
+		t.Fatalf("failed to create users: %v", err)
+	}
+	if err := testDB.Exec(testkit.SQLScripts.CreatePosts); err != nil {
+		t.Fatalf("failed to create posts: %v", err)
+	}
+
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	yes := func(string) bool { return true }
+
+	// 2. Version 2 has no script: nothing is written
+	err := NewMigrator(cfg, wrapDB(testDB), console.New()).Import(config.ImportGoose, yes)
+	if err == nil || !strings.Contains(err.Error(), "no script") {
+		t.Fatalf("expected unmatched version error, got %v", err)
+	}
+	if records, _ := testDB.GetTrackingRecords(); len(records) != 0 {
+		t.Fatalf("expected no tracking rows after a failed import, got %d", len(records))
+	}
+
+	// 3. With every applied version present, the import succeeds
+	repo.AddSQLScript(scriptsDir, "002_create_posts.sql", testkit.SQLScripts.CreatePosts)
+	repo.CommitScripts("Add posts")
+	repo.AddSQLScript(scriptsDir, "003_create_tags.sql", testkit.SQLScripts.CreateTags)
+	repo.CommitScripts("Add tags")
+
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Import(config.ImportGoose, yes); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	// 4. The next run only executes the script goose had rolled back
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration after import failed: %v", err)
+	}
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 3 || records[2].ScriptName != "003_create_tags.sql" {
+		t.Errorf("expected imported 001/002 then executed 003, got %+v", records)
+	}
+}
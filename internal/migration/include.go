@@ -0,0 +1,103 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/textenc"
+)
+
+// includeDirective introduces an include line: "-- dbmig: include common/grants.sql"
+const includeDirective = directive.Prefix + " " + directive.KeyInclude
+
+// expandIncludes replaces include lines with the content of the referenced fragment,
+// resolved relative to the scripts directory; nested includes are expanded and cycles rejected
+func (m *Migrator) expandIncludes(name string, content []byte) ([]byte, error) {
+	expanded, err := m.expand(name, string(content), []string{name})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(expanded), nil
+}
+
+// expand performs include expansion; stack holds the chain of files being expanded
+func (m *Migrator) expand(name, content string, stack []string) (string, error) {
+	if !strings.Contains(content, includeDirective) {
+		return content, nil
+	}
+
+	var out strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		target, ok := parseInclude(line)
+		if !ok {
+			out.WriteString(line)
+			out.WriteString("\n")
+			continue
+		}
+
+		for _, seen := range stack {
+			if seen == target {
+				return "", fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), target)
+			}
+		}
+
+		fragment, err := m.readSource(filepath.Join(m.config.ScriptsDir, filepath.FromSlash(target)))
+		if err != nil {
+			return "", fmt.Errorf("%s: failed to include %s: %w", name, target, err)
+		}
+		fragment, err = textenc.Normalize(fragment, m.config.Encoding)
+		if err != nil {
+			return "", fmt.Errorf("%s: failed to decode %s: %w", name, target, err)
+		}
+
+		nested, err := m.expand(target, string(fragment), append(stack, target))
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(nested)
+		if !strings.HasSuffix(nested, "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("%s: failed to expand includes: %w", name, err)
+	}
+
+	return out.String(), nil
+}
+
+// parseInclude returns the fragment path of an include line
+func parseInclude(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, directive.Prefix) {
+		return "", false
+	}
+	body := strings.TrimSpace(strings.TrimPrefix(trimmed, directive.Prefix))
+	key, value, _ := strings.Cut(body, " ")
+	if !strings.EqualFold(key, directive.KeyInclude) {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	return value, value != ""
+}
+
+// isIncludeFragment reports whether a repo-relative path lies in the include directory,
+// whose files are only used through include and never run as migrations
+func (m *Migrator) isIncludeFragment(repoPath string) bool {
+	if m.config.IncludeDir == "" {
+		return false
+	}
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return false
+	}
+	dir := filepath.ToSlash(filepath.Join(prefix, m.config.IncludeDir)) + "/"
+	return strings.HasPrefix(filepath.ToSlash(repoPath), dir)
+}
@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+// TestExpandIncludes tests nested include expansion and cycle detection
+func TestExpandIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("common/grants.sql", "GRANT SELECT ON orders TO 'app';\n-- dbmig: include common/audit.sql\n")
+	write("common/audit.sql", "CREATE TRIGGER orders_audit AFTER UPDATE ON orders FOR EACH ROW SET @x = 1;")
+	write("common/loop_a.sql", "-- dbmig: include common/loop_b.sql")
+	write("common/loop_b.sql", "-- dbmig: include common/loop_a.sql")
+
+	m := &Migrator{config: &config.Config{ScriptsDir: dir, Source: config.SourceWorktree}}
+
+	got, err := m.expandIncludes("001_orders.sql", []byte("CREATE TABLE orders (id INT);\n-- dbmig: include common/grants.sql\n"))
+	if err != nil {
+		t.Fatalf("expandIncludes failed: %v", err)
+	}
+	want := "CREATE TABLE orders (id INT);\nGRANT SELECT ON orders TO 'app';\nCREATE TRIGGER orders_audit AFTER UPDATE ON orders FOR EACH ROW SET @x = 1;\n"
+	if string(got) != want {
+		t.Errorf("unexpected expansion:\n%s\nwant:\n%s", got, want)
+	}
+
+	_, err = m.expandIncludes("002_loop.sql", []byte("-- dbmig: include common/loop_a.sql\n"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+}
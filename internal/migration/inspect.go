@@ -0,0 +1,212 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Inspector answers read-only questions about migration state
+// It never creates or writes the tracking table, so it only needs SELECT access
+// and is safe to call from health endpoints and dashboards
+type Inspector struct {
+	config  *config.Config
+	options options
+	tracker *Tracker
+	// git is the repository of a Migrator's inspector; others open one per call
+	git Repository
+}
+
+// Batch describes a completed migration batch
+type Batch struct {
+	ID      string
+	GitID   string
+	Scripts []ScriptRecord
+}
+
+// Script statuses reported by Inspector.Scripts
+const (
+	StatusApplied = "applied" // Executed and not reverted since
+	StatusSkipped = "skipped" // Recorded without executing, e.g. by a skip-if guard
+	StatusFailed  = "failed"  // The latest attempt failed
+	StatusPending = "pending" // The next up would execute it
+)
+
+// ScriptStatus is one row of the status table
+type ScriptStatus struct {
+	Script    string
+	Status    string    // StatusApplied, StatusSkipped, StatusFailed or StatusPending
+	Commit    string    // Commit the script ran at, or the commit the next up would record
+	Timestamp time.Time // When it ran, or when a pending script was committed
+	Error     string    // Error of the last attempt of a failed script
+}
+
+// NewInspector creates a new Inspector instance
+func NewInspector(cfg *config.Config, database *db.DB, opts ...Option) *Inspector {
+	o := buildOptions(opts)
+	if o.trackerDB != nil {
+		database = o.trackerDB
+	}
+	tracker := NewTracker(database, opts...)
+	tracker.namespace = cfg.Namespace
+	return &Inspector{
+		config:  cfg,
+		options: o,
+		tracker: tracker,
+	}
+}
+
+// repository returns the scripts of the inspected checkout, with git commands killed
+// once ctx is done
+func (i *Inspector) repository(ctx context.Context) Repository {
+	if i.git != nil {
+		return i.git
+	}
+	o := i.options
+	o.ctx = ctx
+	return o.repository(i.config)
+}
+
+// PendingCount returns the number of scripts that a Run would execute
+func (i *Inspector) PendingCount(ctx context.Context) (int, error) {
+	pending, err := i.PendingScripts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// PendingScripts returns the scripts that a Run would execute, in execution order
+func (i *Inspector) PendingScripts(ctx context.Context) ([]git.ScriptInfo, error) {
+	exists, err := i.tracker.TableExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lastGitID := ""
+	executed := map[string]bool{}
+	if exists {
+		lastGitID, err = i.tracker.GetLastSuccessfulCommit()
+		if err != nil {
+			return nil, err
+		}
+		executed, err = i.tracker.GetExecutedScriptNames()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo := i.repository(ctx)
+	currentCommit, err := repo.GetCurrentCommit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current commit: %w", err)
+	}
+
+	scripts, err := repo.GetChangedScripts(lastGitID, currentCommit, i.config.ScriptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed scripts: %w", err)
+	}
+
+	return filterPending(scripts, executed), nil
+}
+
+// LastBatch returns the most recent completed batch, or nil if none has completed
+func (i *Inspector) LastBatch(ctx context.Context) (*Batch, error) {
+	exists, err := i.tracker.TableExists(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	records, err := i.tracker.LastBatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	last := records[len(records)-1]
+	return &Batch{
+		ID:      last.BatchID,
+		GitID:   last.LastGitID,
+		Scripts: records,
+	}, nil
+}
+
+// FailedScripts returns scripts whose latest attempt failed
+func (i *Inspector) FailedScripts(ctx context.Context) ([]ScriptRecord, error) {
+	exists, err := i.tracker.TableExists(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return i.tracker.FailedScripts(ctx)
+}
+
+// History returns every record of the tracking table, oldest first
+func (i *Inspector) History(ctx context.Context) ([]ScriptRecord, error) {
+	exists, err := i.tracker.TableExists(ctx)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return i.tracker.GetAllScripts()
+}
+
+// Scripts joins the tracking table with the scripts changed since the last batch:
+// applied and skipped scripts in execution order, then failed and pending ones
+func (i *Inspector) Scripts(ctx context.Context) ([]ScriptStatus, error) {
+	exists, err := i.tracker.TableExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []ScriptStatus
+	failed := map[string]bool{}
+	if exists {
+		applied, err := i.tracker.GetAppliedScripts()
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range applied {
+			status := StatusApplied
+			if rec.Action == ActionSkip {
+				status = StatusSkipped
+			}
+			statuses = append(statuses, ScriptStatus{Script: rec.ScriptName, Status: status, Commit: rec.LastGitID, Timestamp: rec.CreatedDateTime})
+		}
+
+		records, err := i.tracker.FailedScripts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			failed[rec.ScriptName] = true
+			statuses = append(statuses, ScriptStatus{Script: rec.ScriptName, Status: StatusFailed, Commit: rec.LastGitID, Timestamp: rec.CreatedDateTime, Error: rec.ErrorMessage})
+		}
+	}
+
+	pending, err := i.PendingScripts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return statuses, nil
+	}
+	currentCommit, err := i.repository(ctx).GetCurrentCommit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current commit: %w", err)
+	}
+	for _, script := range pending {
+		// A failed script stays in the diff until it succeeds; it is listed once, as failed
+		if failed[script.Name] {
+			continue
+		}
+		statuses = append(statuses, ScriptStatus{Script: script.Name, Status: StatusPending, Commit: currentCommit, Timestamp: script.Timestamp})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+// TestInspector_ReadOnlyState tests the inspection API before and after a migration
+func TestInspector_ReadOnlyState(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	// 1. Setup MySQL and git repository with scripts
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	for filename, content := range testkit.StandardScripts() {
+		repo.AddSQLScript(scriptsDir, filename, content)
+	}
+	commitHash := repo.CommitScripts("Add migration scripts")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	inspector := NewInspector(cfg, wrapDB(testDB))
+
+	// 2. Before any run, everything is pending and no tracking table is created
+	pending, err := inspector.PendingCount(ctx)
+	if err != nil {
+		t.Fatalf("PendingCount failed: %v", err)
+	}
+	if pending != 3 {
+		t.Errorf("expected 3 pending scripts, got %d", pending)
+	}
+	batch, err := inspector.LastBatch(ctx)
+	if err != nil {
+		t.Fatalf("LastBatch failed: %v", err)
+	}
+	if batch != nil {
+		t.Errorf("expected no last batch, got %+v", batch)
+	}
+	exists, err := testDB.TableExists("sqlScriptExec")
+	if err != nil {
+		t.Fatalf("failed to check tracking table: %v", err)
+	}
+	if exists {
+		t.Error("inspector should not create the tracking table")
+	}
+
+	// 3. Run migration
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 4. Nothing pending, last batch contains all scripts
+	pending, err = inspector.PendingCount(ctx)
+	if err != nil {
+		t.Fatalf("PendingCount failed: %v", err)
+	}
+	if pending != 0 {
+		t.Errorf("expected 0 pending scripts, got %d", pending)
+	}
+	batch, err = inspector.LastBatch(ctx)
+	if err != nil {
+		t.Fatalf("LastBatch failed: %v", err)
+	}
+	if batch == nil || len(batch.Scripts) != 3 {
+		t.Fatalf("expected last batch with 3 scripts, got %+v", batch)
+	}
+	if batch.GitID != commitHash {
+		t.Errorf("expected last batch git id %s, got %s", commitHash, batch.GitID)
+	}
+	failed, err := inspector.FailedScripts(ctx)
+	if err != nil {
+		t.Fatalf("FailedScripts failed: %v", err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("expected no failed scripts, got %d", len(failed))
+	}
+}
+
+// TestInspector_RepositoryUsesCallContext tests that git commands of an Inspector are
+// killed with the context of the call, not the one it was created with
+func TestInspector_RepositoryUsesCallContext(t *testing.T) {
+	testDB := testkit.OpenSQLite(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+
+	inspector := NewInspector(&config.Config{ScriptsDir: scriptsDir}, wrapDB(testDB))
+	if _, err := inspector.repository(context.Background()).GetCurrentCommit(); err != nil {
+		t.Fatalf("GetCurrentCommit failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := inspector.repository(ctx).GetCurrentCommit(); err == nil {
+		t.Error("expected git to fail with a cancelled context")
+	}
+	if _, err := inspector.PendingScripts(ctx); err == nil {
+		t.Error("expected PendingScripts to fail with a cancelled context")
+	}
+}
@@ -0,0 +1,259 @@
+package migration
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Script outcomes collected for reports
+const (
+	ResultSuccess   = "success"
+	ResultFailed    = "failed"
+	ResultTolerated = "tolerated" // Failed, but onError=continue
+	ResultSkipped   = "skipped"   // skip-if guard matched
+	ResultDeferred  = "deferred"  // Held back by --tags/--skip-tags
+	ResultNotRun    = "not-run"   // The batch stopped at an earlier failure
+)
+
+// ScriptResult is the outcome of one pending script of a run
+type ScriptResult struct {
+	Name     string
+	Path     string
+	Tickets  []string
+	Status   string
+	Duration time.Duration
+	Error    string
+}
+
+// Results returns the outcome of each pending script of the last run, in execution order
+func (m *Migrator) Results() []ScriptResult {
+	return m.results
+}
+
+// addResult records and prints the outcome of a script that started at started
+func (m *Migrator) addResult(script git.ScriptInfo, status string, started time.Time, err error) {
+	result := ScriptResult{Name: script.Name, Path: script.Path, Tickets: script.Tickets, Status: status, Duration: m.clock.Now().Sub(started)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	shown := status
+	if status == ResultTolerated {
+		shown = ResultFailed
+	}
+	m.console.ScriptDone(script.Name, shown, result.Duration, err)
+	m.results = append(m.results, result)
+	m.observeScript(result)
+	m.reportProgress(ScriptProgress{Script: result.Name, Status: status, Duration: result.Duration, Error: result.Error})
+}
+
+// slowScripts returns the scripts of the run taking at least --slow-threshold, for the summary
+func (m *Migrator) slowScripts() []console.SlowScript {
+	if m.config.SlowThreshold <= 0 {
+		return nil
+	}
+	var slow []console.SlowScript
+	for _, r := range m.results {
+		if r.Duration >= m.config.SlowThreshold {
+			slow = append(slow, console.SlowScript{Script: r.Name, DurationMS: r.Duration.Milliseconds()})
+		}
+	}
+	return slow
+}
+
+// addNotRun records scripts left pending because the batch stopped
+func (m *Migrator) addNotRun(scripts []git.ScriptInfo) {
+	for _, script := range scripts {
+		m.results = append(m.results, ScriptResult{Name: script.Name, Path: script.Path, Tickets: script.Tickets, Status: ResultNotRun})
+		m.observeScript(m.results[len(m.results)-1])
+		m.reportProgress(ScriptProgress{Script: script.Name, Status: ResultNotRun})
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Failure    *junitMessage    `xml:"failure,omitempty"`
+	Skipped    *junitMessage    `xml:"skipped,omitempty"`
+	SystemOut  string           `xml:"system-out,omitempty"`
+}
+
+// junitProperties carry the tickets of a script test case
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes a JUnit XML report with a test case per validation rule and per script
+// runErr fails the preflight test case when the run stopped before any script failed
+func WriteJUnit(w io.Writer, findings []Finding, results []ScriptResult, runErr error) error {
+	validation := junitTestSuite{Name: "validation"}
+
+	preflight := junitTestCase{Name: "preflight", ClassName: "validation", Time: "0.000"}
+	if runErr != nil && !anyFailed(results) {
+		preflight.Failure = &junitMessage{Message: runErr.Error()}
+	}
+	validation.add(preflight)
+
+	byRule := make(map[string][]Finding)
+	for _, f := range findings {
+		byRule[f.RuleID] = append(byRule[f.RuleID], f)
+	}
+	for _, rule := range sortedKeys(ruleDescriptions) {
+		tc := junitTestCase{Name: rule, ClassName: "validation", Time: "0.000"}
+		var errors, warnings []string
+		for _, f := range byRule[rule] {
+			line := f.Path
+			if f.Line > 0 {
+				line = fmt.Sprintf("%s:%d", f.Path, f.Line)
+			}
+			line += ": " + f.Message
+			if f.Level == "error" {
+				errors = append(errors, line)
+			} else {
+				warnings = append(warnings, line)
+			}
+		}
+		// Warnings do not fail the run, so they do not fail the test case either
+		if len(errors) > 0 {
+			tc.Failure = &junitMessage{Message: ruleDescriptions[rule], Text: strings.Join(errors, "\n")}
+		}
+		if len(warnings) > 0 {
+			tc.SystemOut = strings.Join(warnings, "\n")
+		}
+		validation.add(tc)
+	}
+
+	scripts := junitTestSuite{Name: "scripts"}
+	var total time.Duration
+	for _, r := range results {
+		tc := junitTestCase{Name: r.Name, ClassName: "scripts", Time: junitSeconds(r.Duration)}
+		if len(r.Tickets) > 0 {
+			tc.Properties = &junitProperties{}
+			for _, ticket := range r.Tickets {
+				tc.Properties.Properties = append(tc.Properties.Properties, junitProperty{Name: "ticket", Value: ticket})
+			}
+		}
+		switch r.Status {
+		case ResultFailed:
+			tc.Failure = &junitMessage{Message: "script failed", Text: r.Error}
+		case ResultTolerated:
+			tc.Failure = &junitMessage{Message: "script failed, tolerated by onError=continue", Text: r.Error}
+		case ResultSkipped:
+			tc.Skipped = &junitMessage{Message: "skip-if guard matched"}
+		case ResultDeferred:
+			tc.Skipped = &junitMessage{Message: "deferred by tag filter"}
+		case ResultNotRun:
+			tc.Skipped = &junitMessage{Message: "not run: the batch stopped at an earlier failure"}
+		}
+		total += r.Duration
+		scripts.add(tc)
+	}
+	scripts.Time = junitSeconds(total)
+
+	report := junitTestSuites{
+		Name:     "db-migration",
+		Tests:    validation.Tests + scripts.Tests,
+		Failures: validation.Failures + scripts.Failures,
+		Skipped:  validation.Skipped + scripts.Skipped,
+		Time:     scripts.Time,
+		Suites:   []junitTestSuite{validation, scripts},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// add appends a test case and updates the suite counters
+func (s *junitTestSuite) add(tc junitTestCase) {
+	s.Tests++
+	if tc.Failure != nil {
+		s.Failures++
+	}
+	if tc.Skipped != nil {
+		s.Skipped++
+	}
+	if s.Time == "" {
+		s.Time = "0.000"
+	}
+	s.Cases = append(s.Cases, tc)
+}
+
+// writeJUnit saves the run as a JUnit report to the --report-junit file, even when the run failed
+func (m *Migrator) writeJUnit(runErr error) {
+	if m.config.JUnitFile == "" {
+		return
+	}
+
+	file, err := os.Create(m.config.JUnitFile)
+	if err == nil {
+		err = WriteJUnit(file, m.validator.Findings(), m.results, runErr)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		m.console.Warn("Could not write JUnit report to %s: %v", m.config.JUnitFile, err)
+		return
+	}
+	m.console.Info("Wrote JUnit report to %s", m.config.JUnitFile)
+}
+
+// anyFailed reports whether a script failed outright
+func anyFailed(results []ScriptResult) bool {
+	for _, r := range results {
+		if r.Status == ResultFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// junitSeconds formats a duration as JUnit expects
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	findings := []Finding{
+		{RuleID: RuleDestructiveStatement, Level: "warning", Path: "db/002_reset.sql", Line: 3, Message: "TRUNCATE removes all rows"},
+	}
+	results := []ScriptResult{
+		{Name: "001_create_users.sql", Tickets: []string{"SHOP-1"}, Status: ResultSuccess, Duration: 1500 * time.Millisecond},
+		{Name: "002_reset.sql", Status: ResultFailed, Error: "Error 1146: Table 'shop.sessions' doesn't exist"},
+		{Name: "003_create_posts.sql", Status: ResultNotRun},
+	}
+
+	var out strings.Builder
+	if err := WriteJUnit(&out, findings, results, errors.New("migration failed at script: 002_reset.sql")); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out.String())
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("expected validation and scripts suites, got %d", len(report.Suites))
+	}
+
+	validation := report.Suites[0]
+	if validation.Tests != len(ruleDescriptions)+1 || validation.Failures != 0 {
+		t.Errorf("expected %d passing validation cases, got %d tests and %d failures", len(ruleDescriptions)+1, validation.Tests, validation.Failures)
+	}
+
+	scripts := report.Suites[1]
+	if scripts.Tests != 3 || scripts.Failures != 1 || scripts.Skipped != 1 || scripts.Time != "1.500" {
+		t.Errorf("unexpected scripts suite counters: %+v", scripts)
+	}
+	if failure := scripts.Cases[1].Failure; failure == nil || !strings.Contains(failure.Text, "doesn't exist") {
+		t.Errorf("expected the script error in the failure, got %+v", failure)
+	}
+	if props := scripts.Cases[0].Properties; props == nil || props.Properties[0] != (junitProperty{Name: "ticket", Value: "SHOP-1"}) {
+		t.Errorf("expected the ticket as a test case property, got %+v", props)
+	}
+	if !strings.Contains(out.String(), "db/002_reset.sql:3: TRUNCATE removes all rows") {
+		t.Errorf("expected the warning in system-out, got:\n%s", out.String())
+	}
+}
+
+func TestWriteJUnitPreflightFailure(t *testing.T) {
+	var out strings.Builder
+	if err := WriteJUnit(&out, nil, nil, errors.New("previous migration batch has failed script")); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if report.Failures != 1 || report.Suites[0].Cases[0].Failure == nil {
+		t.Errorf("expected a failed preflight case, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,110 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// leaderPoll is how long a replica waits for the lock between progress messages
+const leaderPoll = 30 * time.Second
+
+// LockName returns the GET_LOCK name that serializes runs against a database;
+// MySQL limits lock names to 64 characters
+func LockName(dbName string) string {
+	name := "db-migration:" + dbName
+	if len(name) > 64 {
+		sum := sha256.Sum256([]byte(dbName))
+		name = "db-migration:" + hex.EncodeToString(sum[:])[:51]
+	}
+	return name
+}
+
+// RunAsLeader runs up while holding the migration lock of the database, so replicas
+// started together (init containers, parallel Jobs) take turns: the leader applies the
+// pending scripts and the others find nothing left to do. It fails unless the schema is
+// current afterwards, so a zero exit means the scripts directory is fully applied
+func (m *Migrator) RunAsLeader(ctx context.Context) error {
+	lock, err := m.waitForLeadership(ctx)
+	if err != nil {
+		return err
+	}
+	m.lockHeld = true
+	defer func() {
+		m.lockHeld = false
+		if err := lock.Release(); err != nil {
+			m.console.Warn("%v", err)
+		}
+	}()
+
+	if err := m.Run(); err != nil {
+		return err
+	}
+
+	pending, err := m.pendingCount(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check that the schema is current: %w", err)
+	}
+	if pending > 0 {
+		return fmt.Errorf("schema is not current: %d scripts are still pending", pending)
+	}
+	m.console.Success("Schema is current")
+	return nil
+}
+
+// waitForLeadership blocks until this replica holds the migration lock
+func (m *Migrator) waitForLeadership(ctx context.Context) (*db.Lock, error) {
+	name := LockName(m.config.DBName)
+	for {
+		lock, err := m.db.AcquireLock(ctx, name, leaderPoll)
+		if err == nil {
+			m.console.Info("Acquired migration lock %s", name)
+			return lock, nil
+		}
+		if !errors.Is(err, db.ErrLockTimeout) {
+			return nil, err
+		}
+		m.console.Info("Waiting for another replica holding %s to finish...", name)
+	}
+}
+
+// lockRun takes the migration lock of the database for a run that writes the tracking
+// table, waiting up to --lock-timeout for another run to finish, and returns the func
+// releasing it. Under RunAsLeader and watch the lock is already held
+func (m *Migrator) lockRun() (func(), error) {
+	if m.lockHeld {
+		return func() {}, nil
+	}
+	ctx := m.ctx
+	name := LockName(m.config.DBName)
+	lock, err := m.db.AcquireLock(ctx, name, 0)
+	if errors.Is(err, db.ErrLockTimeout) && m.config.LockTimeout > 0 {
+		m.console.Info("Waiting up to %v for another run holding the migration lock %s...", m.config.LockTimeout, name)
+		lock, err = m.db.AcquireLock(ctx, name, m.config.LockTimeout)
+	}
+	if errors.Is(err, db.ErrLockTimeout) {
+		return nil, fmt.Errorf("%w %s; gave up after %v (see --lock-timeout)", ErrLocked, name, m.config.LockTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.lockHeld = true
+	return func() {
+		m.lockHeld = false
+		if err := lock.Release(); err != nil {
+			m.console.Warn("%v", err)
+		}
+	}, nil
+}
+
+// pendingCount returns the number of scripts a Run would execute now
+func (m *Migrator) pendingCount(ctx context.Context) (int, error) {
+	inspector := &Inspector{config: m.config, git: m.git, tracker: m.tracker}
+	return inspector.PendingCount(ctx)
+}
@@ -0,0 +1,17 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLockName(t *testing.T) {
+	if got := LockName("app"); got != "db-migration:app" {
+		t.Errorf("expected db-migration:app, got %s", got)
+	}
+	long := strings.Repeat("x", 64)
+	got := LockName(long)
+	if len(got) != 64 || got == LockName(long+"y") {
+		t.Errorf("expected long names to hash to 64 distinct characters, got %s", got)
+	}
+}
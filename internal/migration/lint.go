@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// lintScripts warns about destructive statements and unconventional names in pending scripts
+// Neither stops the run; both are reported as findings for review
+func (m *Migrator) lintScripts(scripts []git.ScriptInfo) error {
+	m.validator.CheckScriptNames(scripts)
+
+	contents := make([]string, len(scripts))
+	for i, script := range scripts {
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		contents[i] = string(content)
+	}
+	m.validator.CheckDestructiveStatements(scripts, contents)
+	return nil
+}
+
+// CheckScriptNames warns about versioned scripts without a numeric <version>_ prefix
+func (v *Validator) CheckScriptNames(scripts []git.ScriptInfo) {
+	for _, script := range scripts {
+		if git.IsRepeatable(script.Name) {
+			continue
+		}
+		end := strings.IndexFunc(script.Name, func(r rune) bool { return r < '0' || r > '9' })
+		if end > 0 && script.Name[end] == '_' {
+			continue
+		}
+		v.console.Warn("Script %s does not follow the <version>_<description>.sql naming convention", script.Name)
+		v.report(Finding{RuleID: RuleScriptNaming, Level: "warning", Path: script.Path,
+			Message: "Script name should start with a numeric version and an underscore, e.g. 042_add_orders.sql"})
+	}
+}
+
+// CheckDestructiveStatements warns about statements that remove data
+// contents holds the final SQL of each script, in the same order as scripts
+func (v *Validator) CheckDestructiveStatements(scripts []git.ScriptInfo, contents []string) {
+	for i, script := range scripts {
+		offset := 0
+		for _, stmt := range splitStatements(contents[i]) {
+			line := 0
+			firstLine, _, _ := strings.Cut(stmt, "\n")
+			if at := strings.Index(contents[i][offset:], firstLine); at >= 0 {
+				offset += at
+				line = strings.Count(contents[i][:offset], "\n") + 1
+			}
+
+			reason := destructiveReason(stmt)
+			if reason == "" {
+				continue
+			}
+			// Lines of a bundle refer to its concatenated files, not to one file
+			if filepath.IsAbs(script.Path) {
+				line = 0
+			}
+			if line > 0 {
+				v.console.Warn("%s (line %d): %s", script.Name, line, reason)
+			} else {
+				v.console.Warn("%s: %s", script.Name, reason)
+			}
+			v.report(Finding{RuleID: RuleDestructiveStatement, Level: "warning", Path: script.Path, Line: line, Message: reason})
+		}
+	}
+}
+
+// destructiveReason describes why a statement removes data, or returns ""
+func destructiveReason(stmt string) string {
+	words := strings.Fields(strings.ToUpper(stmt))
+	if len(words) < 2 {
+		return ""
+	}
+
+	switch words[0] {
+	case "DROP":
+		switch words[1] {
+		case "TABLE", "DATABASE", "SCHEMA":
+			return fmt.Sprintf("DROP %s removes all of its data", words[1])
+		}
+	case "TRUNCATE":
+		return "TRUNCATE removes all rows"
+	case "DELETE", "UPDATE":
+		if !containsName(words, "WHERE") {
+			return fmt.Sprintf("%s without WHERE affects every row", words[0])
+		}
+	case "ALTER":
+		if words[1] != "TABLE" {
+			return ""
+		}
+		for j := 2; j < len(words)-1; j++ {
+			if words[j] != "DROP" {
+				continue
+			}
+			switch words[j+1] {
+			case "INDEX", "KEY", "FOREIGN", "PRIMARY", "CHECK", "CONSTRAINT", "DEFAULT":
+			case "PARTITION":
+				return "ALTER TABLE ... DROP PARTITION removes the rows of that partition"
+			default:
+				return "ALTER TABLE ... DROP COLUMN removes the column's data"
+			}
+		}
+	}
+	return ""
+}
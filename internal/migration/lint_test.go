@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+func TestDestructiveReason(t *testing.T) {
+	tests := []struct {
+		stmt        string
+		destructive bool
+	}{
+		{"DROP TABLE users", true},
+		{"drop database shop", true},
+		{"DROP VIEW active_users", false},
+		{"DROP INDEX idx_name ON users", false},
+		{"TRUNCATE TABLE sessions", true},
+		{"DELETE FROM sessions", true},
+		{"DELETE FROM sessions WHERE expires_at < NOW()", false},
+		{"UPDATE users SET active = 0", true},
+		{"UPDATE users SET active = 0 WHERE id = 1", false},
+		{"ALTER TABLE users DROP COLUMN legacy", true},
+		{"ALTER TABLE users DROP `legacy`", true},
+		{"ALTER TABLE users DROP INDEX idx_name, DROP FOREIGN KEY fk_user", false},
+		{"ALTER TABLE users ALTER COLUMN status DROP DEFAULT", false},
+		{"ALTER TABLE events DROP PARTITION p2020", true},
+		{"CREATE TABLE users (id INT)", false},
+	}
+
+	for _, tt := range tests {
+		if got := destructiveReason(tt.stmt) != ""; got != tt.destructive {
+			t.Errorf("destructiveReason(%q) destructive = %v, want %v", tt.stmt, got, tt.destructive)
+		}
+	}
+}
+
+func TestCheckDestructiveStatements(t *testing.T) {
+	v := NewValidator(nil, console.New())
+	scripts := []git.ScriptInfo{{Name: "005_cleanup.sql", Path: "db/005_cleanup.sql"}, {Name: "cleanup.sql", Path: "db/cleanup.sql"}}
+	contents := []string{
+		"-- remove old data\nCREATE TABLE archive (id INT);\n\nDROP TABLE legacy;\n",
+		"SELECT 1;",
+	}
+
+	v.CheckScriptNames(scripts)
+	v.CheckDestructiveStatements(scripts, contents)
+
+	findings := v.Findings()
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+	if f := findings[0]; f.RuleID != RuleScriptNaming || f.Path != "db/cleanup.sql" {
+		t.Errorf("expected naming finding for cleanup.sql, got %+v", f)
+	}
+	if f := findings[1]; f.RuleID != RuleDestructiveStatement || f.Path != "db/005_cleanup.sql" || f.Line != 4 {
+		t.Errorf("expected destructive finding at db/005_cleanup.sql:4, got %+v", f)
+	}
+}
@@ -0,0 +1,108 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+	"github.com/bontaramsonta/db-migration/internal/liquibase"
+)
+
+// loadChangelogs reads the Liquibase changelogs (*.changelog.xml/yaml) of the scripts
+// directory at commit and returns the changesets to run, in changelog order:
+// changesets that never ran, runAlways ones, and runOnChange ones whose SQL changed
+// Applied changesets whose SQL changed fail the run, like modified scripts
+func (m *Migrator) loadChangelogs(commit string) ([]git.ScriptInfo, error) {
+	files, err := m.git.ListFiles(commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %w", err)
+	}
+
+	m.changesets = make(map[string]string)
+	m.changelogFiles = make(map[string]bool)
+	var changeSets []liquibase.ChangeSet
+	for _, file := range files {
+		if !liquibase.IsChangelog(file) || m.changelogFiles[file] {
+			continue
+		}
+		changelog, err := liquibase.Load(m.config.ScriptsDir, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range changelog.Files {
+			m.changelogFiles[f] = true
+		}
+		changeSets = append(changeSets, changelog.ChangeSets...)
+	}
+	if len(changeSets) == 0 {
+		return nil, nil
+	}
+
+	applied, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string)
+	for _, rec := range applied {
+		checksums[rec.ScriptName] = rec.Checksum
+	}
+
+	dir, err := filepath.Abs(m.config.ScriptsDir)
+	if err != nil {
+		dir = m.config.ScriptsDir
+	}
+	var pending []git.ScriptInfo
+	var modified []string
+	for _, cs := range changeSets {
+		if _, ok := m.changesets[cs.Name()]; ok {
+			return nil, fmt.Errorf("changeset %s is defined twice", cs.Name())
+		}
+		m.changesets[cs.Name()] = cs.SQL
+
+		script := git.ScriptInfo{Name: cs.Name(), Path: filepath.Join(dir, filepath.FromSlash(cs.File))}
+		recorded, ran := checksums[script.Name]
+		if ran && !cs.RunAlways {
+			content, err := m.readScript(script)
+			if err != nil {
+				return nil, err
+			}
+			checksum, err := m.scriptChecksum(script, content)
+			if err != nil {
+				return nil, err
+			}
+			if recorded == checksum || cs.AnyChecksum {
+				continue
+			}
+			if !cs.RunOnChange {
+				modified = append(modified, script.Name)
+				m.validator.report(Finding{RuleID: RuleModifiedScript, Level: "error", Path: cs.File,
+					Message: fmt.Sprintf("Applied changeset %s was modified; add a new changeset or mark it runOnChange", cs.Name())})
+				continue
+			}
+		}
+		pending = append(pending, script)
+	}
+
+	if len(modified) > 0 {
+		m.console.Error("The following previously executed changesets have been MODIFIED:")
+		for _, name := range modified {
+			m.console.Failure("  - %s", name)
+		}
+		return nil, fmt.Errorf("detected %d modified changesets that were previously executed - migration aborted", len(modified))
+	}
+	return pending, nil
+}
+
+// isChangelogFile reports whether a repository path is a changelog, or a file
+// read by one, so it is not also run as a script of its own
+func (m *Migrator) isChangelogFile(repoPath string) bool {
+	if len(m.changelogFiles) == 0 {
+		return false
+	}
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return false
+	}
+	return m.changelogFiles[strings.TrimPrefix(filepath.ToSlash(repoPath), prefix)]
+}
@@ -0,0 +1,273 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+	"github.com/go-sql-driver/mysql"
+)
+
+// maxPlaceholders is MySQL's limit of ? parameters in one prepared statement
+const maxPlaceholders = 65535
+
+// MySQL errors returned when the server or client refuses LOAD DATA LOCAL INFILE
+var localInfileDisabled = map[uint16]bool{1148: true, 3948: true, 3950: true}
+
+// loadSeq makes reader handler names unique across loads
+var loadSeq atomic.Int64
+
+// execer runs statements on a transaction or directly on the database
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// executeLoads loads the data files of a script's load directives, in order
+func (m *Migrator) executeLoads(exec execer, script git.ScriptInfo, loads []*directive.Load) error {
+	for _, l := range loads {
+		rows, err := m.loadFile(exec, m.loadPath(script, l), l)
+		if err != nil {
+			return fmt.Errorf("failed to load %s into %s: %w", l.File, l.Table, err)
+		}
+		m.console.Info("Loaded %d rows from %s into %s", rows, l.File, l.Table)
+	}
+	return nil
+}
+
+// loadPath resolves a load file relative to the directory of its script
+func (m *Migrator) loadPath(script git.ScriptInfo, l *directive.Load) string {
+	if filepath.IsAbs(l.File) {
+		return l.File
+	}
+	dir := m.config.ScriptsDir
+	if filepath.IsAbs(script.Path) {
+		dir = filepath.Dir(script.Path)
+	}
+	return filepath.Join(dir, filepath.FromSlash(l.File))
+}
+
+// loadFile streams a CSV file into a table with LOAD DATA LOCAL INFILE, falling back
+// to batched INSERTs when that is disabled by --no-load-infile or by the server, and
+// on servers other than MySQL
+func (m *Migrator) loadFile(exec execer, path string, l *directive.Load) (int64, error) {
+	dialect := m.db.Dialect()
+	if !m.config.NoLoadInfile && m.db.Driver() == db.DriverMySQL {
+		rows, err := loadInfile(exec, path, l)
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) || !localInfileDisabled[mysqlErr.Number] {
+			return rows, err
+		}
+		m.console.Warn("LOAD DATA LOCAL INFILE is disabled (%v); loading %s with INSERTs", err, l.File)
+	}
+	return loadInserts(exec, dialect, path, l)
+}
+
+// openCSV opens a data file and returns its reader and target columns
+func openCSV(path string, l *directive.Load) (*csv.Reader, []string, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	reader := csv.NewReader(file)
+	reader.Comma = l.Delimiter
+	reader.ReuseRecord = true
+
+	columns := l.Columns
+	if l.Header {
+		header, err := reader.Read()
+		if err != nil {
+			file.Close()
+			return nil, nil, nil, fmt.Errorf("failed to read header row: %w", err)
+		}
+		if len(columns) == 0 {
+			for _, name := range header {
+				// Spreadsheet exports often start with a UTF-8 BOM
+				columns = append(columns, strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+			}
+		}
+	}
+	for _, name := range columns {
+		if name == "" || strings.ContainsAny(name, "`\n") {
+			file.Close()
+			return nil, nil, nil, fmt.Errorf("invalid column name %q", name)
+		}
+	}
+	return reader, columns, file, nil
+}
+
+// loadInfile sends the rows to LOAD DATA LOCAL INFILE in its default tab-separated format,
+// so CSV quoting is handled by encoding/csv the same way for both load methods
+func loadInfile(exec execer, path string, l *directive.Load) (int64, error) {
+	reader, columns, file, err := openCSV(path, l)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(writeInfileRows(pw, reader, l.Null))
+	}()
+
+	name := fmt.Sprintf("dbmig-load-%d", loadSeq.Add(1))
+	mysql.RegisterReaderHandler(name, func() io.Reader { return pr })
+	defer mysql.DeregisterReaderHandler(name)
+
+	query := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4", name, quoteIdentifier(l.Table))
+	if len(columns) > 0 {
+		query += " (" + quoteColumns(db.DialectOf(db.DriverMySQL), columns) + ")"
+	}
+	result, err := exec.Exec(query)
+
+	// Unblock the writer if the server stopped reading early
+	pr.Close()
+	<-done
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// writeInfileRows writes CSV records as LOAD DATA's default format: tab-separated,
+// backslash-escaped, with \N for NULL
+func writeInfileRows(w io.Writer, reader *csv.Reader, null string) error {
+	escaper := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+	var line strings.Builder
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line.Reset()
+		for i, field := range record {
+			if i > 0 {
+				line.WriteByte('\t')
+			}
+			if field == null {
+				line.WriteString(`\N`)
+			} else {
+				line.WriteString(escaper.Replace(field))
+			}
+		}
+		line.WriteByte('\n')
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+}
+
+// loadInserts loads the rows with multi-row INSERT statements of up to l.Batch rows
+func loadInserts(exec execer, dialect db.Dialect, path string, l *directive.Load) (int64, error) {
+	reader, columns, file, err := openCSV(path, l)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var total int64
+	var args []interface{}
+	rows, width := 0, 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		row := "(" + strings.TrimSuffix(strings.Repeat("?, ", width), ", ") + ")"
+		query := "INSERT INTO " + dialect.QuoteIdentifier(l.Table)
+		if len(columns) > 0 {
+			query += " (" + quoteColumns(dialect, columns) + ")"
+		}
+		query += " VALUES " + strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+		if _, err := exec.Exec(dialect.Rebind(query), args...); err != nil {
+			return err
+		}
+		total += int64(rows)
+		args, rows = args[:0], 0
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+		if width == 0 {
+			width = len(record)
+		}
+		if len(columns) > 0 && len(record) != len(columns) {
+			return total, fmt.Errorf("row has %d fields, expected %d columns", len(record), len(columns))
+		}
+
+		for _, field := range record {
+			if field == l.Null {
+				args = append(args, nil)
+			} else {
+				args = append(args, field)
+			}
+		}
+		rows++
+		if rows >= l.Batch || (rows+1)*width > maxPlaceholders {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// quoteColumns backtick-quotes a list of column names
+func quoteColumns(dialect db.Dialect, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, name := range columns {
+		quoted[i] = dialect.QuoteIdentifier(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// scriptChecksum returns the checksum recorded for a script: its content, plus the
+// data files it loads, so changing a CSV re-runs a seed like changing its SQL does
+func (m *Migrator) scriptChecksum(script git.ScriptInfo, content []byte) (string, error) {
+	directives, err := directive.Parse(content)
+	if err != nil || len(directives.Loads) == 0 {
+		// Malformed headers are reported when the script runs
+		return Checksum(content), nil
+	}
+
+	hash := sha256.New()
+	hash.Write(content)
+	for _, l := range directives.Loads {
+		file, err := os.Open(m.loadPath(script, l))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s of %s: %w", l.File, script.Name, err)
+		}
+		fmt.Fprintf(hash, "\n%s\n", l.File)
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s of %s: %w", l.File, script.Name, err)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
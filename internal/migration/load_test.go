@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/directive"
+)
+
+// recordingExecer records statements instead of running them
+type recordingExecer struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (r *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, append([]interface{}(nil), args...))
+	return nil, nil
+}
+
+func TestWriteInfileRows(t *testing.T) {
+	reader := csv.NewReader(strings.NewReader("1,\"Côte d'Ivoire\",\\N\n2,\"tab\there\",\"a\\b\"\n"))
+
+	var out strings.Builder
+	if err := writeInfileRows(&out, reader, `\N`); err != nil {
+		t.Fatalf("writeInfileRows failed: %v", err)
+	}
+	want := "1\tCôte d'Ivoire\t\\N\n2\ttab\\there\ta\\\\b\n"
+	if out.String() != want {
+		t.Errorf("unexpected rows %q, want %q", out.String(), want)
+	}
+}
+
+func TestLoadInserts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	if err := os.WriteFile(path, []byte("\ufeffcode,name\nDE,Germany\nFR,France\nXX,NULL\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &recordingExecer{}
+	rows, err := loadInserts(exec, db.DialectOf(db.DriverMySQL), path, &directive.Load{Table: "countries", Header: true, Delimiter: ',', Null: "NULL", Batch: 2})
+	if err != nil {
+		t.Fatalf("loadInserts failed: %v", err)
+	}
+	if rows != 3 {
+		t.Errorf("expected 3 rows, got %d", rows)
+	}
+
+	wantQueries := []string{
+		"INSERT INTO `countries` (`code`, `name`) VALUES (?, ?), (?, ?)",
+		"INSERT INTO `countries` (`code`, `name`) VALUES (?, ?)",
+	}
+	if !reflect.DeepEqual(exec.queries, wantQueries) {
+		t.Errorf("unexpected queries %q", exec.queries)
+	}
+	wantArgs := [][]interface{}{{"DE", "Germany", "FR", "France"}, {"XX", nil}}
+	if !reflect.DeepEqual(exec.args, wantArgs) {
+		t.Errorf("unexpected args %v", exec.args)
+	}
+}
@@ -0,0 +1,395 @@
+package migration
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Operations a statement performs on an object
+const (
+	OpCreate   = "create"
+	OpAlter    = "alter"
+	OpDrop     = "drop"
+	OpRename   = "rename"
+	OpTruncate = "truncate"
+	OpInsert   = "insert"
+	OpUpdate   = "update"
+	OpDelete   = "delete"
+	OpRead     = "read"
+)
+
+// objectName matches a table name, optionally qualified and backtick-quoted
+const objectName = "((?:`[^`]+`|\\w+)(?:\\.(?:`[^`]+`|\\w+))?)"
+
+var (
+	createRoutinePattern = regexp.MustCompile("(?is)^CREATE\\s.*?\\b(PROCEDURE|FUNCTION|TRIGGER|EVENT)\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?" + objectName)
+	createIndexPattern   = regexp.MustCompile("(?is)^CREATE\\s+(?:UNIQUE\\s+|FULLTEXT\\s+|SPATIAL\\s+)?INDEX\\s+\\S+\\s+ON\\s+" + objectName)
+	alterTablePattern    = regexp.MustCompile("(?is)^ALTER\\s+(?:ONLINE\\s+|IGNORE\\s+)*TABLE\\s+" + objectName)
+	alterViewPattern     = regexp.MustCompile("(?is)^ALTER\\s.*?\\bVIEW\\s+" + objectName)
+	dropPattern          = regexp.MustCompile("(?is)^DROP\\s+(?:TEMPORARY\\s+)?(TABLE|VIEW|PROCEDURE|FUNCTION|TRIGGER|EVENT)\\s+(?:IF\\s+EXISTS\\s+)?(.+)$")
+	dropIndexPattern     = regexp.MustCompile("(?is)^DROP\\s+INDEX\\s+\\S+\\s+ON\\s+" + objectName)
+	renamePattern        = regexp.MustCompile("(?is)^RENAME\\s+TABLES?\\s+(.+)$")
+	renamePairPattern    = regexp.MustCompile("(?is)^\\s*" + objectName + "\\s+TO\\s+" + objectName + "\\s*$")
+	truncatePattern      = regexp.MustCompile("(?is)^TRUNCATE\\s+(?:TABLE\\s+)?" + objectName)
+	insertPattern        = regexp.MustCompile("(?is)^(?:INSERT|REPLACE)\\s+(?:(?:LOW_PRIORITY|DELAYED|HIGH_PRIORITY|IGNORE)\\s+)*(?:INTO\\s+)?" + objectName)
+	loadDataPattern      = regexp.MustCompile("(?is)^LOAD\\s+DATA\\s.*?\\bINTO\\s+TABLE\\s+" + objectName)
+	updatePattern        = regexp.MustCompile("(?is)^UPDATE\\s+(?:(?:LOW_PRIORITY|IGNORE)\\s+)*" + objectName)
+	deletePattern        = regexp.MustCompile("(?is)^DELETE\\s+(?:(?:LOW_PRIORITY|QUICK|IGNORE)\\s+)*FROM\\s+" + objectName)
+	readPattern          = regexp.MustCompile("(?i)\\b(?:FROM|JOIN)\\s+" + objectName)
+	triggerTablePattern  = regexp.MustCompile("(?is)\\bON\\s+" + objectName + "\\s+FOR\\s+EACH\\s+ROW")
+)
+
+// ObjectRef is a database object a script touches
+type ObjectRef struct {
+	Type      string `json:"type"` // table, view, procedure, function, trigger or event
+	Database  string `json:"database"`
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+}
+
+// uniqueID identifies the object in a manifest; tables and views share a namespace in MySQL
+func (o ObjectRef) uniqueID() string {
+	kind := o.Type
+	switch kind {
+	case "table", "view":
+		kind = "relation"
+	case "procedure", "function":
+		kind = "routine"
+	}
+	return kind + "." + o.Database + "." + o.Name
+}
+
+// statementObjects is what one statement writes and reads
+type statementObjects struct {
+	writes  []ObjectRef
+	reads   []ObjectRef
+	renames [][2]ObjectRef // old, new
+}
+
+// parseStatementObjects finds the objects a statement touches
+// This is a pattern match, not a SQL parser: FROM lists contribute only their first table,
+// and dynamic SQL in procedures is not followed
+func parseStatementObjects(stmt, database string) statementObjects {
+	var objs statementObjects
+	stmt = blankLiterals(stmt)
+	ref := func(kind, name, op string) ObjectRef {
+		return newObjectRef(kind, name, op, database)
+	}
+	write := func(kind, name, op string) {
+		objs.writes = append(objs.writes, ref(kind, name, op))
+	}
+
+	readFrom := 0
+	if m := createTablePattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpCreate)
+	} else if m := createViewPattern.FindStringSubmatch(stmt); m != nil {
+		write("view", m[1], OpCreate)
+	} else if m := createRoutinePattern.FindStringSubmatch(stmt); m != nil {
+		write(strings.ToLower(m[1]), m[2], OpCreate)
+		if t := triggerTablePattern.FindStringSubmatch(stmt); t != nil && strings.EqualFold(m[1], "TRIGGER") {
+			objs.reads = append(objs.reads, ref("table", t[1], OpRead))
+		}
+	} else if m := createIndexPattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpAlter)
+	} else if m := alterTablePattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpAlter)
+	} else if m := alterViewPattern.FindStringSubmatch(stmt); m != nil {
+		write("view", m[1], OpAlter)
+	} else if m := dropIndexPattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpAlter)
+	} else if m := dropPattern.FindStringSubmatch(stmt); m != nil {
+		for _, name := range strings.Split(m[2], ",") {
+			if fields := strings.Fields(name); len(fields) > 0 {
+				write(strings.ToLower(m[1]), fields[0], OpDrop)
+			}
+		}
+		return objs
+	} else if m := renamePattern.FindStringSubmatch(stmt); m != nil {
+		for _, pair := range strings.Split(m[1], ",") {
+			if p := renamePairPattern.FindStringSubmatch(pair); p != nil {
+				from, to := ref("table", p[1], OpRename), ref("table", p[2], OpRename)
+				objs.writes = append(objs.writes, from, to)
+				objs.renames = append(objs.renames, [2]ObjectRef{from, to})
+			}
+		}
+		return objs
+	} else if m := truncatePattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpTruncate)
+	} else if m := insertPattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpInsert)
+	} else if m := loadDataPattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpInsert)
+		return objs
+	} else if m := updatePattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpUpdate)
+	} else if m := deletePattern.FindStringSubmatch(stmt); m != nil {
+		write("table", m[1], OpDelete)
+		// The FROM of DELETE names the target, not a source
+		readFrom = len(m[0])
+	}
+
+	for _, m := range readPattern.FindAllStringSubmatch(stmt[readFrom:], -1) {
+		if strings.EqualFold(m[1], "DUAL") || isNumeric(m[1]) {
+			continue
+		}
+		objs.reads = append(objs.reads, ref("table", m[1], OpRead))
+	}
+	for _, m := range referencesPattern.FindAllStringSubmatch(stmt, -1) {
+		objs.reads = append(objs.reads, ref("table", m[1], OpRead))
+	}
+	return objs
+}
+
+// newObjectRef splits a possibly qualified name, defaulting to the migrated database
+func newObjectRef(kind, name, op, database string) ObjectRef {
+	schema := database
+	if strings.HasSuffix(name, "`") && strings.Count(name, "`") == 2 {
+		name = unquote(name)
+	} else if i := strings.Index(name, "."); i >= 0 {
+		schema, name = unquote(name[:i]), unquote(name[i+1:])
+	}
+	return ObjectRef{Type: kind, Database: schema, Name: name, Operation: op}
+}
+
+// blankLiterals empties string literals so their text is not matched as SQL
+func blankLiterals(stmt string) string {
+	var b strings.Builder
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		if c == '\'' || c == '"' {
+			end := db.SkipQuoted(stmt, i)
+			b.WriteByte(c)
+			b.WriteByte(c)
+			i = end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// isNumeric reports whether s is a number, as in SUBSTRING(s FROM 2)
+func isNumeric(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// ScriptObjects returns the objects a script touches, once per object and operation,
+// in statement order; tables filled by load directives count as inserts
+func ScriptObjects(content, database string) []ObjectRef {
+	var objects []ObjectRef
+	seen := make(map[ObjectRef]bool)
+	add := func(o ObjectRef) {
+		if !seen[o] {
+			seen[o] = true
+			objects = append(objects, o)
+		}
+	}
+	for _, stmt := range splitStatements(content) {
+		objs := parseStatementObjects(stmt, database)
+		for _, o := range objs.writes {
+			add(o)
+		}
+		for _, o := range objs.reads {
+			add(o)
+		}
+	}
+	if directives, err := directive.Parse([]byte(content)); err == nil {
+		for _, l := range directives.Loads {
+			add(newObjectRef("table", l.Table, OpInsert, database))
+		}
+	}
+	return objects
+}
+
+// Manifest lists the objects touched by each script of a run, shaped like a dbt manifest
+// so lineage tools can connect schema changes to the models that read those objects
+type Manifest struct {
+	Metadata  ManifestMetadata        `json:"metadata"`
+	Nodes     map[string]ManifestNode `json:"nodes"`
+	ParentMap map[string][]string     `json:"parent_map"`
+	ChildMap  map[string][]string     `json:"child_map"`
+}
+
+// ManifestMetadata describes the run a manifest was written for
+type ManifestMetadata struct {
+	Generator   string `json:"generator"`
+	GeneratedAt string `json:"generated_at"`
+	Database    string `json:"database"`
+	BatchID     string `json:"batch_id"`
+	Commit      string `json:"commit,omitempty"`
+}
+
+// ManifestNode is a migration script or a database object
+type ManifestNode struct {
+	UniqueID     string      `json:"unique_id"`
+	ResourceType string      `json:"resource_type"` // migration, table, view, procedure, ...
+	Database     string      `json:"database"`
+	Name         string      `json:"name"`
+	Path         string      `json:"original_file_path,omitempty"`
+	Status       string      `json:"status,omitempty"`
+	Tickets      []string    `json:"tickets,omitempty"`
+	Objects      []ObjectRef `json:"objects,omitempty"`
+}
+
+// ManifestScript is a script that ran, with its executable content
+type ManifestScript struct {
+	Name    string
+	Path    string
+	Status  string
+	Tickets []string
+	Content string
+}
+
+// BuildManifest builds the manifest of the scripts of a run
+// A script is a parent of the objects it writes and a child of the objects it reads;
+// within a statement, written objects are children of the objects read, and renamed
+// tables are parents of their new names
+func BuildManifest(meta ManifestMetadata, scripts []ManifestScript) *Manifest {
+	manifest := &Manifest{
+		Metadata:  meta,
+		Nodes:     make(map[string]ManifestNode),
+		ParentMap: make(map[string][]string),
+		ChildMap:  make(map[string][]string),
+	}
+	link := func(parent, child string) {
+		if parent == child {
+			return
+		}
+		if !containsName(manifest.ParentMap[child], parent) {
+			manifest.ParentMap[child] = append(manifest.ParentMap[child], parent)
+		}
+		if !containsName(manifest.ChildMap[parent], child) {
+			manifest.ChildMap[parent] = append(manifest.ChildMap[parent], child)
+		}
+	}
+	addObject := func(o ObjectRef, written bool) string {
+		id := o.uniqueID()
+		node, ok := manifest.Nodes[id]
+		if !ok || (written && o.Operation != OpRename) {
+			// Reads cannot tell tables from views; a write names the type
+			kind := o.Type
+			if ok && !written {
+				kind = node.ResourceType
+			}
+			node = ManifestNode{UniqueID: id, ResourceType: kind, Database: o.Database, Name: o.Name}
+		}
+		manifest.Nodes[id] = node
+		return id
+	}
+
+	for _, script := range scripts {
+		id := "migration." + meta.Database + "." + script.Name
+		manifest.Nodes[id] = ManifestNode{
+			UniqueID:     id,
+			ResourceType: "migration",
+			Database:     meta.Database,
+			Name:         script.Name,
+			Path:         script.Path,
+			Status:       script.Status,
+			Tickets:      script.Tickets,
+			Objects:      ScriptObjects(script.Content, meta.Database),
+		}
+
+		for _, stmt := range splitStatements(script.Content) {
+			objs := parseStatementObjects(stmt, meta.Database)
+			for _, r := range objs.reads {
+				link(addObject(r, false), id)
+			}
+			for _, w := range objs.writes {
+				target := addObject(w, true)
+				link(id, target)
+				for _, r := range objs.reads {
+					link(r.uniqueID(), target)
+				}
+			}
+			for _, pair := range objs.renames {
+				link(pair[0].uniqueID(), pair[1].uniqueID())
+			}
+		}
+		for _, o := range manifest.Nodes[id].Objects {
+			if o.Operation == OpInsert {
+				link(id, addObject(o, true))
+			}
+		}
+	}
+
+	// Like dbt, every node has an entry in both maps
+	for id := range manifest.Nodes {
+		for _, edges := range []map[string][]string{manifest.ParentMap, manifest.ChildMap} {
+			if edges[id] == nil {
+				edges[id] = []string{}
+			}
+			sort.Strings(edges[id])
+		}
+	}
+	return manifest
+}
+
+// WriteManifest writes a manifest as indented JSON
+func WriteManifest(w io.Writer, manifest *Manifest) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// writeManifest saves the objects touched by the scripts that ran to the --manifest file,
+// even when the run failed, since a failed script may have applied some statements
+func (m *Migrator) writeManifest() {
+	if m.config.ManifestFile == "" {
+		return
+	}
+
+	root, rootErr := m.git.TopLevel()
+	var scripts []ManifestScript
+	for _, r := range m.results {
+		switch r.Status {
+		case ResultSuccess, ResultTolerated, ResultFailed:
+		default:
+			continue
+		}
+		content, err := m.readScript(git.ScriptInfo{Name: r.Name, Path: r.Path})
+		if err != nil {
+			m.console.Warn("Could not read %s for the manifest: %v", r.Name, err)
+			continue
+		}
+		path := r.Path
+		if rel, err := filepath.Rel(root, path); rootErr == nil && err == nil && filepath.IsAbs(path) {
+			path = rel
+		}
+		scripts = append(scripts, ManifestScript{Name: r.Name, Path: filepath.ToSlash(path), Status: r.Status, Tickets: r.Tickets, Content: string(content)})
+	}
+
+	meta := ManifestMetadata{
+		Generator:   "db-migration",
+		GeneratedAt: m.clock.Now().UTC().Format(time.RFC3339),
+		Database:    m.config.DBName,
+		BatchID:     m.batchID,
+	}
+	if commit, err := m.git.GetCurrentCommit(); err == nil {
+		meta.Commit = commit
+	}
+	manifest := BuildManifest(meta, scripts)
+
+	file, err := os.Create(m.config.ManifestFile)
+	if err == nil {
+		err = WriteManifest(file, manifest)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		m.console.Warn("Could not write manifest to %s: %v", m.config.ManifestFile, err)
+		return
+	}
+	m.console.Info("Wrote manifest of %d scripts to %s", len(scripts), m.config.ManifestFile)
+}
@@ -0,0 +1,103 @@
+package migration
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptObjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []ObjectRef
+	}{
+		{"create table", "CREATE TABLE IF NOT EXISTS `users` (id INT, team_id INT REFERENCES teams (id));", []ObjectRef{
+			{"table", "shop", "users", OpCreate},
+			{"table", "shop", "teams", OpRead},
+		}},
+		{"view", "CREATE OR REPLACE VIEW active_users AS SELECT u.id FROM users u JOIN analytics.sessions s ON s.user_id = u.id;", []ObjectRef{
+			{"view", "shop", "active_users", OpCreate},
+			{"table", "shop", "users", OpRead},
+			{"table", "analytics", "sessions", OpRead},
+		}},
+		{"index", "CREATE UNIQUE INDEX idx_email ON users (email);", []ObjectRef{
+			{"table", "shop", "users", OpAlter},
+		}},
+		{"drop list", "DROP TABLE IF EXISTS old_a, `old_b`;", []ObjectRef{
+			{"table", "shop", "old_a", OpDrop},
+			{"table", "shop", "old_b", OpDrop},
+		}},
+		{"rename", "RENAME TABLE users TO customers;", []ObjectRef{
+			{"table", "shop", "users", OpRename},
+			{"table", "shop", "customers", OpRename},
+		}},
+		{"insert select", "INSERT IGNORE INTO archive SELECT * FROM orders WHERE note = 'from users';", []ObjectRef{
+			{"table", "shop", "archive", OpInsert},
+			{"table", "shop", "orders", OpRead},
+		}},
+		{"delete", "DELETE FROM sessions WHERE user_id IN (SELECT id FROM banned);", []ObjectRef{
+			{"table", "shop", "sessions", OpDelete},
+			{"table", "shop", "banned", OpRead},
+		}},
+		{"update twice", "UPDATE users SET name = SUBSTRING(name FROM 2);\nUPDATE users SET active = 1;", []ObjectRef{
+			{"table", "shop", "users", OpUpdate},
+		}},
+		{"load directive", "-- dbmig: load table=countries file=countries.csv\n", []ObjectRef{
+			{"table", "shop", "countries", OpInsert},
+		}},
+		{"procedure", "CREATE DEFINER=`admin`@`%` PROCEDURE cleanup() BEGIN END;", []ObjectRef{
+			{"procedure", "shop", "cleanup", OpCreate},
+		}},
+		{"select only", "SELECT 1;", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScriptObjects(tt.content, "shop")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScriptObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildManifest(t *testing.T) {
+	manifest := BuildManifest(ManifestMetadata{Generator: "db-migration", Database: "shop", BatchID: "b1"}, []ManifestScript{
+		{Name: "001_users.sql", Path: "db/001_users.sql", Status: ResultSuccess, Content: "CREATE TABLE users (id INT);"},
+		{Name: "002_view.sql", Path: "db/002_view.sql", Status: ResultSuccess, Content: "CREATE VIEW active_users AS SELECT id FROM users;"},
+	})
+
+	parents := map[string][]string{
+		"relation.shop.users":          {"migration.shop.001_users.sql"},
+		"relation.shop.active_users":   {"migration.shop.002_view.sql", "relation.shop.users"},
+		"migration.shop.002_view.sql":  {"relation.shop.users"},
+		"migration.shop.001_users.sql": {},
+	}
+	for id, want := range parents {
+		if got := manifest.ParentMap[id]; !reflect.DeepEqual(got, want) {
+			t.Errorf("parent_map[%s] = %v, want %v", id, got, want)
+		}
+	}
+	if got := manifest.ChildMap["relation.shop.users"]; !reflect.DeepEqual(got, []string{"migration.shop.002_view.sql", "relation.shop.active_users"}) {
+		t.Errorf("unexpected child_map of users: %v", got)
+	}
+	if node := manifest.Nodes["relation.shop.active_users"]; node.ResourceType != "view" {
+		t.Errorf("expected active_users to be a view, got %+v", node)
+	}
+
+	var out strings.Builder
+	if err := WriteManifest(&out, manifest); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"metadata", "nodes", "parent_map", "child_map"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("expected %s in the manifest", key)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Markers of a plan --format sql bundle
+const (
+	markerCommit = "-- dbmig:commit"
+	markerBegin  = "-- dbmig:begin"
+	markerEnd    = "-- dbmig:end"
+)
+
+// planBundle is what mark-applied reads from a plan --format sql file
+type planBundle struct {
+	Commit  string
+	Scripts []bundledScript // In execution order
+}
+
+// bundledScript is one script of a plan bundle
+type bundledScript struct {
+	Name     string
+	Checksum string
+}
+
+// readPlanBundle parses the markers of a plan --format sql file. Every script must be
+// closed by its end marker, so a truncated file is refused
+func readPlanBundle(path string) (*planBundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plan bundle: %w", err)
+	}
+	defer file.Close()
+
+	bundle := &planBundle{}
+	open := ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, markerCommit+" "):
+			if bundle.Commit != "" || len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: malformed commit marker", path, lineNo)
+			}
+			bundle.Commit = fields[2]
+		case strings.HasPrefix(line, markerBegin+" "):
+			if open != "" {
+				return nil, fmt.Errorf("%s:%d: %s begins before %s ends", path, lineNo, fields[2], open)
+			}
+			script := bundledScript{Name: fields[2]}
+			for _, attr := range fields[3:] {
+				if v, ok := strings.CutPrefix(attr, "checksum="); ok {
+					script.Checksum = v
+				}
+			}
+			if script.Checksum == "" {
+				return nil, fmt.Errorf("%s:%d: %s has no checksum", path, lineNo, script.Name)
+			}
+			bundle.Scripts = append(bundle.Scripts, script)
+			open = script.Name
+		case strings.HasPrefix(line, markerEnd+" "):
+			if len(fields) != 3 || fields[2] != open {
+				return nil, fmt.Errorf("%s:%d: unexpected end marker", path, lineNo)
+			}
+			open = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read plan bundle: %w", err)
+	}
+	if open != "" {
+		return nil, fmt.Errorf("%s is truncated: %s has no end marker", path, open)
+	}
+	if bundle.Commit == "" {
+		return nil, fmt.Errorf("%s is not a plan bundle: no %s line", path, markerCommit)
+	}
+	return bundle, nil
+}
+
+// MarkApplied records the scripts of a plan --format sql bundle as applied without
+// executing them, after a DBA ran the bundle by hand. The scripts must be at the commit
+// of the bundle (HEAD, or the one of --ref), still pending with the checksums they were
+// planned with, and be all that is pending, so the records form the batch up would have
+func (m *Migrator) MarkApplied(path string, confirm ConfirmFunc) error {
+	m.console.Header("DB Mark Applied")
+	m.forgetCommit()
+	m.batchID = m.ids.NewID()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	bundle, err := readPlanBundle(path)
+	if err != nil {
+		return err
+	}
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return err
+	}
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+
+	currentCommit, err := m.currentCommit()
+	if err != nil {
+		return fmt.Errorf("failed to get current commit: %w", err)
+	}
+	if bundle.Commit != currentCommit {
+		return fmt.Errorf("%s was planned at commit %s, but the scripts are at %s; pass --ref %s", path, shortCommit(bundle.Commit), shortCommit(currentCommit), bundle.Commit)
+	}
+	lastGitID, err := m.tracker.GetLastSuccessfulCommit()
+	if err != nil {
+		return fmt.Errorf("failed to get last successful commit: %w", err)
+	}
+	executedScripts, err := m.tracker.GetExecutedScriptNames()
+	if err != nil {
+		return fmt.Errorf("failed to get executed scripts: %w", err)
+	}
+	pending, _, _, err := m.discoverPending(lastGitID, currentCommit, executedScripts)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]git.ScriptInfo, len(pending))
+	for _, script := range pending {
+		byName[script.Name] = script
+	}
+	recs := make([]ScriptRecord, 0, len(bundle.Scripts))
+	for _, bs := range bundle.Scripts {
+		script, ok := byName[bs.Name]
+		if !ok {
+			return fmt.Errorf("%s of %s is not pending; plan again", bs.Name, path)
+		}
+		delete(byName, bs.Name)
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		checksum, err := m.scriptChecksum(script, content)
+		if err != nil {
+			return err
+		}
+		if checksum != bs.Checksum {
+			return fmt.Errorf("%s has changed since it was planned (checksum %s, planned %s); plan again", bs.Name, shortChecksum(checksum), shortChecksum(bs.Checksum))
+		}
+		recs = append(recs, ScriptRecord{
+			ScriptName:  script.Name,
+			Completed:   true,
+			LastGitID:   currentCommit,
+			BatchID:     m.batchID,
+			Checksum:    checksum,
+			Fingerprint: Fingerprint(content),
+		})
+	}
+	if len(byName) > 0 {
+		var missing []string
+		for _, script := range pending {
+			if _, ok := byName[script.Name]; ok {
+				missing = append(missing, script.Name)
+			}
+		}
+		return fmt.Errorf("%s lacks pending scripts %s; plan again", path, strings.Join(missing, ", "))
+	}
+	if len(recs) == 0 {
+		m.console.Success("No scripts to mark as applied")
+		return nil
+	}
+
+	if !confirm(fmt.Sprintf("Record %d scripts of %s as applied? Only confirm after running them", len(recs), path)) {
+		return fmt.Errorf("mark-applied cancelled")
+	}
+	recs[len(recs)-1].EndOfBatch = true
+	if err := m.tracker.RecordBatch(recs); err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		m.console.Script(rec.ScriptName, "success")
+	}
+
+	m.console.Success("Marked %d scripts as applied at commit %s", len(recs), shortCommit(currentCommit))
+	return nil
+}
@@ -0,0 +1,134 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+func TestMarkApplied(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+	yes := func(string) bool { return true }
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY)")
+	planned := repo.CommitScripts("add posts and tags")
+
+	plan, err := m.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WritePlan(&out, plan, config.PlanSQL); err != nil {
+		t.Fatal(err)
+	}
+	bundle := out.String()
+	if !strings.Contains(bundle, "-- dbmig:commit "+planned) || !strings.Contains(bundle, "-- dbmig:end 003_tags.sql") {
+		t.Fatalf("unexpected bundle:\n%s", bundle)
+	}
+	writeBundle := func(content string) string {
+		path := filepath.Join(t.TempDir(), "plan.sql")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	path := writeBundle(bundle)
+
+	// The DBA runs the bundle by hand
+	if err := testDB.Exec(bundle); err != nil {
+		t.Fatalf("bundle does not run: %v", err)
+	}
+
+	if err := m.MarkApplied(path, func(string) bool { return false }); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("expected mark-applied to be cancelled, got %v", err)
+	}
+	tags := plan.Changes[1].checksum
+	tampered := writeBundle(strings.Replace(bundle, "checksum="+tags, "checksum=0000", 1))
+	if err := m.MarkApplied(tampered, yes); err == nil || !strings.Contains(err.Error(), "003_tags.sql has changed since it was planned") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	truncated := writeBundle(bundle[:strings.Index(bundle, "-- dbmig:end 003_tags.sql")])
+	if err := m.MarkApplied(truncated, yes); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("expected a truncated bundle to be refused, got %v", err)
+	}
+	testDB.AssertApplied("001_users.sql")
+
+	if err := m.MarkApplied(path, yes); err != nil {
+		t.Fatalf("mark-applied failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	testDB.AssertBatches(2)
+
+	// Nothing is left for up, and the bundle cannot be recorded twice
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	if err := m.MarkApplied(path, yes); err == nil || !strings.Contains(err.Error(), "002_posts.sql of "+path+" is not pending") {
+		t.Errorf("expected applied scripts to be refused, got %v", err)
+	}
+
+	repo.AddSQLScript(scriptsDir, "004_likes.sql", "CREATE TABLE likes (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add likes")
+	if err := m.MarkApplied(path, yes); err == nil || !strings.Contains(err.Error(), "pass --ref "+planned) {
+		t.Errorf("expected a bundle of another commit to be refused, got %v", err)
+	}
+}
+
+// TestMarkApplied_FailedInsert tests that a failed insert records none of the bundle
+func TestMarkApplied_FailedInsert(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+	yes := func(string) bool { return true }
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts and tags")
+
+	plan, err := m.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WritePlan(&out, plan, config.PlanSQL); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "plan.sql")
+	if err := os.WriteFile(path, []byte(out.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second insert fails, after the first one went through
+	if err := testDB.Exec(`CREATE TRIGGER fail_tags BEFORE INSERT ON sqlScriptExec
+		WHEN NEW.scriptName = '003_tags.sql' BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MarkApplied(path, yes); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected the insert to fail, got %v", err)
+	}
+	testDB.AssertApplied("001_users.sql")
+	testDB.AssertBatches(1)
+
+	// The bundle is still pending as a whole, so it can be recorded once the fault is gone
+	if err := testDB.Exec("DROP TRIGGER fail_tags"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MarkApplied(path, yes); err != nil {
+		t.Fatalf("mark-applied failed: %v", err)
+	}
+	testDB.AssertApplied("001_users.sql", "002_posts.sql", "003_tags.sql")
+	testDB.AssertBatches(2)
+}
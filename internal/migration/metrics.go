@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/metrics"
+)
+
+// Metrics are the Prometheus metrics of runs, shared by the migrators of one process
+type Metrics struct {
+	scripts        *metrics.Counter
+	scriptDuration *metrics.Histogram
+	batches        *metrics.Counter
+	batchDuration  *metrics.Histogram
+}
+
+// NewMetrics registers the metrics of runs with reg
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	return &Metrics{
+		scripts: reg.Counter("db_migration_scripts_total",
+			"Pending scripts of runs by outcome: success, failed, tolerated, skipped, deferred or not-run", "database", "namespace", "status"),
+		scriptDuration: reg.Histogram("db_migration_script_duration_seconds",
+			"Execution time of scripts that ran", metrics.DefaultBuckets, "database", "namespace"),
+		batches: reg.Counter("db_migration_batches_total",
+			"Runs by outcome: success or failed", "database", "namespace", "status"),
+		batchDuration: reg.Histogram("db_migration_batch_duration_seconds",
+			"Duration of runs, from start to the last tracking record", metrics.DefaultBuckets, "database", "namespace"),
+	}
+}
+
+// WithMetrics counts the scripts and runs of a Migrator in mx
+func WithMetrics(mx *Metrics) Option {
+	return func(o *options) {
+		o.metrics = mx
+	}
+}
+
+// observeScript counts the outcome of a pending script; the duration of those that
+// executed is observed too
+func (m *Migrator) observeScript(result ScriptResult) {
+	if m.metrics == nil {
+		return
+	}
+	m.metrics.scripts.Inc(m.config.DBName, m.config.Namespace, result.Status)
+	switch result.Status {
+	case ResultSuccess, ResultFailed, ResultTolerated:
+		m.metrics.scriptDuration.Observe(result.Duration.Seconds(), m.config.DBName, m.config.Namespace)
+	}
+}
+
+// observeBatch counts a run that started at started and ended with err
+func (m *Migrator) observeBatch(started time.Time, err error) {
+	if m.metrics == nil || m.config.DryRun {
+		return
+	}
+	status := ResultSuccess
+	if err != nil {
+		status = ResultFailed
+	}
+	m.metrics.batches.Inc(m.config.DBName, m.config.Namespace, status)
+	m.metrics.batchDuration.Observe(m.clock.Now().Sub(started).Seconds(), m.config.DBName, m.config.Namespace)
+}
@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/metrics"
+)
+
+func TestRun_Metrics(t *testing.T) {
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+	reg := metrics.NewRegistry()
+	m.metrics = NewMetrics(reg)
+	m.config.DBName = "app"
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.AddSQLScript(scriptsDir, "003_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add scripts")
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	var out strings.Builder
+	if err := reg.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`db_migration_scripts_total{database="app",namespace="",status="success"} 1`,
+		`db_migration_scripts_total{database="app",namespace="",status="failed"} 1`,
+		`db_migration_scripts_total{database="app",namespace="",status="not-run"} 1`,
+		`db_migration_script_duration_seconds_count{database="app",namespace=""} 2`,
+		`db_migration_batches_total{database="app",namespace="",status="failed"} 1`,
+		`db_migration_batch_duration_seconds_count{database="app",namespace=""} 1`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("expected %s in:\n%s", want, out.String())
+		}
+	}
+}
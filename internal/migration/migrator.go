@@ -2,47 +2,150 @@ package migration
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/bontaramsonta/db-migration/internal/chaos"
 	"github.com/bontaramsonta/db-migration/internal/config"
 	"github.com/bontaramsonta/db-migration/internal/console"
 	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/directive"
 	"github.com/bontaramsonta/db-migration/internal/git"
+	"github.com/bontaramsonta/db-migration/internal/textenc"
 )
 
 // Migrator orchestrates the migration process
 type Migrator struct {
 	config    *config.Config
 	db        *db.DB
-	git       *git.Git
+	git       Repository
 	tracker   *Tracker
 	validator *Validator
-	console   *console.Console
+
+	// seedTracker records seed data scripts separately from schema history
+	seedTracker *Tracker
+	console     *console.Console
+	clock       Clock
+	ids         IDGenerator
+	batchID     string
+	started     time.Time
+
+	// serverVersion caches SELECT VERSION() for template rendering
+	serverVersion string
+
+	// results holds the outcome of each pending script of the current run
+	results []ScriptResult
+	// progress receives the progress of the current run, see WithProgress; pendingTotal
+	// is the number of its pending scripts
+	progress     func(ScriptProgress)
+	pendingTotal int
+	// metrics counts the scripts and runs, see WithMetrics
+	metrics *Metrics
+	// heldBack counts the scripts of the current run waiting for the maintenance window
+	heldBack int
+	// approve decides on the plan of each run, see WithApproval; approvedBy is who
+	// approved the current run
+	approve    ApprovalFunc
+	approvedBy string
+	// reconnects holds the connections reopened during the current run
+	reconnects []Reconnect
+	// faults are injected by resilience tests, see WithFaults; executed counts the
+	// scripts whose statements ran, which is what faults are triggered by
+	faults   *chaos.Faults
+	executed int
+
+	// changesets holds the SQL of Liquibase changesets by name, and changelogFiles
+	// the changelogs and sqlFiles they were read from (see loadChangelogs)
+	changesets     map[string]string
+	changelogFiles map[string]bool
+
+	// lockHeld is set while the migration lock of the database is held, see lockRun
+	lockHeld bool
+
+	// commit is the commit the current run reads its scripts from, see currentCommit;
+	// sources caches the files read from it, since each script is read by several checks
+	commit  string
+	sources map[string][]byte
+
+	// ctx stops the migrator once done, see WithContext
+	ctx context.Context
 }
 
 // NewMigrator creates a new Migrator instance
-func NewMigrator(cfg *config.Config, database *db.DB, console *console.Console) *Migrator {
-	gitInstance := git.New(cfg.ScriptsDir)
-	tracker := NewTracker(database)
+// Options may replace the clock and ID generator, e.g. with fakes from the testkit package
+func NewMigrator(cfg *config.Config, database *db.DB, console *console.Console, opts ...Option) *Migrator {
+	o := buildOptions(opts)
+	if o.logger == nil {
+		o.logger = console.Logger()
+	}
+	gitInstance := o.repository(cfg)
+	trackerDB := database
+	if o.trackerDB != nil {
+		trackerDB = o.trackerDB
+	}
+	tracker := NewTracker(trackerDB, opts...)
+	tracker.namespace = cfg.Namespace
 	validator := NewValidator(gitInstance, console)
 
 	return &Migrator{
-		config:    cfg,
-		db:        database,
-		git:       gitInstance,
-		tracker:   tracker,
-		validator: validator,
-		console:   console,
+		config:      cfg,
+		db:          database,
+		git:         gitInstance,
+		tracker:     tracker,
+		validator:   validator,
+		console:     console,
+		clock:       o.clock,
+		ids:         o.ids,
+		progress:    o.progress,
+		metrics:     o.metrics,
+		approve:     o.approve,
+		faults:      o.faults,
+		ctx:         o.ctx,
+		seedTracker: NewSeedTracker(trackerDB, opts...),
 	}
 }
 
+// BatchID returns the ID of the batch started by the most recent Run
+func (m *Migrator) BatchID() string {
+	return m.batchID
+}
+
+// Pending returns the number of scripts that were pending in the most recent Run
+func (m *Migrator) Pending() int {
+	return m.pendingTotal
+}
+
 // Run executes the migration process
-func (m *Migrator) Run() error {
+func (m *Migrator) Run() (err error) {
 	m.console.Header("DB Migration Started")
+	m.batchID = m.ids.NewID()
+	// A dry run writes nothing, so it neither waits for nor blocks other runs
+	if !m.config.DryRun {
+		unlock, err := m.lockRun()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+	m.results = nil
+	m.pendingTotal = 0
+	m.forgetCommit()
+	m.reconnects = nil
+	m.heldBack = 0
+	m.approvedBy = ""
+	defer m.writeSARIF()
+	defer func() { m.writeJUnit(err) }()
+	defer m.writeManifest()
+	m.started = m.clock.Now()
+	defer func() { m.observeBatch(m.started, err) }()
+	defer func() { m.writeAuditLog(err) }()
+	defer func() { m.logReport(err) }()
+	defer func() { m.notifyEnd(err) }()
 
 	// 1. Validate git repository
 	m.console.Info("Validating scripts directory...")
@@ -51,8 +154,12 @@ func (m *Migrator) Run() error {
 	}
 
 	// 2. Ensure tracking table exists
-	m.console.Info("Ensuring tracking table exists...")
-	if err := m.tracker.EnsureTable(); err != nil {
+	if m.config.DryRun {
+		m.console.Info("Checking tracking table...")
+	} else {
+		m.console.Info("Ensuring tracking table exists...")
+	}
+	if err := m.prepareTracker(m.config.DryRun); err != nil {
 		return err
 	}
 
@@ -82,7 +189,7 @@ func (m *Migrator) Run() error {
 	}
 
 	// 6. Get current commit
-	currentCommit, err := m.git.GetCurrentCommit()
+	currentCommit, err := m.currentCommit()
 	if err != nil {
 		return fmt.Errorf("failed to get current commit: %w", err)
 	}
@@ -91,7 +198,10 @@ func (m *Migrator) Run() error {
 	// 7. Check file modifications (fail if executed scripts were modified/deleted)
 	m.console.Info("Checking for modifications to executed scripts...")
 	if err := m.validator.CheckFileModifications(lastGitID, currentCommit, executedScripts); err != nil {
-		return err
+		return invalid(err)
+	}
+	if err := m.verifyChecksums(); err != nil {
+		return invalid(err)
 	}
 
 	// 8. Check half-committed files
@@ -100,91 +210,541 @@ func (m *Migrator) Run() error {
 		return fmt.Errorf("failed to get half-committed scripts: %w", err)
 	}
 	if err := m.validator.CheckHalfCommittedFiles(halfCommitted); err != nil {
+		return invalid(err)
+	}
+
+	// 9-10. Discover scripts changed since the last batch that have not run yet
+	pendingScripts, totalCount, skippedCount, err := m.discoverPending(lastGitID, currentCommit, executedScripts)
+	if err != nil {
+		return err
+	}
+
+	m.pendingTotal = len(pendingScripts)
+	if len(pendingScripts) == 0 {
+		m.console.Success("No new scripts to execute")
+		if m.config.DryRun {
+			return nil
+		}
+		return m.runSeedsAfterSchema()
+	}
+
+	m.console.Info("Found %d new scripts to execute", len(pendingScripts))
+	for _, script := range pendingScripts {
+		m.console.Debug("Pending %s, committed %s", script.Path, script.Timestamp.UTC().Format(time.RFC3339))
+	}
+
+	if err := m.loadDirectives(pendingScripts); err != nil {
+		return invalid(err)
+	}
+	m.findTickets(pendingScripts)
+
+	// Destructive statements and unconventional names are flagged for review
+	if err := m.lintScripts(pendingScripts); err != nil {
+		return invalid(err)
+	}
+
+	// Oversized scripts fail before anything runs
+	if err := m.checkBudgets(pendingScripts); err != nil {
+		return invalid(err)
+	}
+
+	// Renamed copies of applied scripts must not run twice
+	if err := m.checkDuplicates(pendingScripts); err != nil {
+		return invalid(err)
+	}
+
+	// Scripts declaring after= dependencies run once those are applied
+	ordered, err := orderByDependencies(pendingScripts, executedScripts)
+	if err != nil {
+		return invalid(fmt.Errorf("invalid script dependencies: %w", err))
+	}
+	for i := range ordered {
+		if ordered[i].Name != pendingScripts[i].Name {
+			m.console.Warn("Script dependencies override commit order: running %s before %s", ordered[i].Name, pendingScripts[i].Name)
+			break
+		}
+	}
+	pendingScripts = ordered
+
+	// Missing grants fail here rather than mid-batch with error 1142
+	if err := m.checkPrivileges(pendingScripts); err != nil {
+		return invalid(err)
+	}
+
+	// A dry run stops once everything before the first statement has passed
+	if m.config.DryRun {
+		m.printDryRun(pendingScripts)
+		return nil
+	}
+
+	// Production applies may wait for a human decision, e.g. in chat
+	if err := m.awaitApproval(pendingScripts, executedScripts, lastGitID, currentCommit); err != nil {
+		return err
+	}
+
+	m.notifyStart(pendingScripts)
+
+	// 11. Execute the batch between the pre- and post-batch hooks
+	if err := m.runBatchHook(PreBatchHook); err != nil {
+		m.runBatchHook(PostBatchHook)
+		return err
+	}
+	batchErr := m.executeBatch(pendingScripts, currentCommit, totalCount, skippedCount)
+	if err := m.runBatchHook(PostBatchHook); err != nil && batchErr == nil {
+		return err
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	if err := m.writeSnapshot(currentCommit); err != nil {
+		m.console.Warn("Could not write schema snapshot: %v", err)
+	}
+	if err := m.writeDocs(); err != nil {
+		m.console.Warn("Could not write schema documentation: %v", err)
+	}
+	m.commentOnTickets(currentCommit)
+
+	// 12. Seed data follows the schema it depends on
+	if err := m.runSeedsAfterSchema(); err != nil {
 		return err
 	}
 
-	// 9. Get changed files from git, sorted by commit time
+	m.console.Success("Migration completed successfully!")
+
+	return nil
+}
+
+// discoverPending returns the scripts a run at currentCommit executes, in order, with
+// the number of discovered scripts and how many of them already ran
+func (m *Migrator) discoverPending(lastGitID, currentCommit string, executedScripts map[string]bool) (pending []git.ScriptInfo, totalCount, skippedCount int, err error) {
+	// Get changed files from git, sorted by commit time
 	m.console.Info("Discovering new scripts...")
 	scripts, err := m.git.GetChangedScripts(lastGitID, currentCommit, m.config.ScriptsDir)
 	if err != nil {
-		return fmt.Errorf("failed to get changed scripts: %w", err)
+		return nil, 0, 0, fmt.Errorf("failed to get changed scripts: %w", err)
+	}
+
+	// Liquibase changelogs are loaded first so the files they read are not taken for scripts
+	changesets, err := m.loadChangelogs(currentCommit)
+	if err != nil {
+		return nil, 0, 0, err
 	}
 
-	// 10. Filter out already-executed scripts
-	var pendingScripts []git.ScriptInfo
+	// Include fragments, batch hooks, verify scripts and changelog files are never migrations on their own;
+	// files in a bundle directory run together as one migration
+	var migrations []git.ScriptInfo
 	for _, script := range scripts {
-		if !executedScripts[script.Name] {
-			pendingScripts = append(pendingScripts, script)
+		if !m.isIncludeFragment(script.Path) && !m.isHook(script.Path) && !m.isVerifyScript(script.Path) && !m.isChangelogFile(script.Path) {
+			migrations = append(migrations, script)
 		}
 	}
+	scripts = m.groupBundles(migrations)
 
-	if len(pendingScripts) == 0 {
-		m.console.Success("No new scripts to execute")
-		return nil
+	// Filter out already-executed scripts
+	pending = filterPending(scripts, executedScripts)
+	skippedCount = len(scripts) - len(pending)
+
+	// Scripts deferred by an earlier tag filter run before newly discovered ones
+	deferred, err := m.deferredScripts(scripts, executedScripts)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(deferred) > 0 {
+		m.console.Info("Found %d previously deferred scripts", len(deferred))
+		pending = append(deferred, pending...)
 	}
 
-	m.console.Info("Found %d new scripts to execute", len(pendingScripts))
+	// Changesets follow versioned scripts, in changelog order
+	if len(changesets) > 0 {
+		m.console.Info("Found %d pending Liquibase changesets", len(changesets))
+	}
+	pending = append(pending, changesets...)
+
+	// Repeatable scripts whose content changed always run after versioned scripts
+	repeatables, err := m.pendingRepeatables(currentCommit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(repeatables) > 0 {
+		m.console.Info("Found %d changed repeatable scripts", len(repeatables))
+	}
+	pending = append(pending, repeatables...)
+
+	return pending, len(scripts) + len(deferred) + len(changesets) + len(repeatables), skippedCount, nil
+}
 
-	// 11. Execute each script in its own transaction
+// executeBatch runs pending scripts in order, recording each outcome, and reports the summary
+func (m *Migrator) executeBatch(pendingScripts []git.ScriptInfo, currentCommit string, totalCount, skippedCount int) error {
+	// Each script runs in its own transaction
 	successCount := 0
 	failedCount := 0
-	skippedCount := len(scripts) - len(pendingScripts)
+	var deferredNames []string
 
 	for i, script := range pendingScripts {
 		isLast := i == len(pendingScripts)-1
 
+		rec := ScriptRecord{ScriptName: script.Name, EndOfBatch: isLast, LastGitID: currentCommit, Tickets: joinTickets(script.Tickets), ApprovedBy: m.approvedBy}
+		started := m.clock.Now()
+
+		// An interrupted run starts no further script; the batch stays open, so the next
+		// run resumes here
+		if m.ctx.Err() != nil {
+			m.console.Error("Interrupted before %s", script.Name)
+			m.addNotRun(pendingScripts[i:])
+			m.console.Summary(totalCount, successCount, failedCount, skippedCount, m.slowScripts())
+			return fmt.Errorf("migration interrupted before script: %s", script.Name)
+		}
+
+		// Credentials may expire during a long batch; scripts start on a live connection
+		if err := m.ensureConnection(script.Name); err != nil {
+			m.addResult(script, ResultFailed, started, err)
+			m.console.Error("%v", err)
+			m.addNotRun(pendingScripts[i+1:])
+			m.console.Summary(totalCount, successCount, failedCount+1, skippedCount, m.slowScripts())
+			return &ScriptError{Script: script.Name}
+		}
+
+		// Scripts held back by --tags/--skip-tags or --window-tags stay pending for a later run
+		if m.deferredByTags(script) || m.heldForWindow(script) {
+			if err := m.recordDeferred(m.tracker, script, rec); err != nil {
+				m.addResult(script, ResultFailed, started, err)
+				m.console.Error("Failed to record deferred script: %v", err)
+				m.addNotRun(pendingScripts[i+1:])
+				m.console.Summary(totalCount, successCount, failedCount+1, skippedCount, m.slowScripts())
+				return &ScriptError{Script: script.Name}
+			}
+			m.addResult(script, ResultDeferred, started, nil)
+			deferredNames = append(deferredNames, script.Name)
+			continue
+		}
+
+		// Guarded scripts whose skip-if query matches are recorded as skipped
+		skip, err := m.evaluateSkipIf(script)
+		if err == nil && skip {
+			err = m.recordSkipped(m.tracker, script, rec)
+		}
+		if err != nil {
+			m.addResult(script, ResultFailed, started, err)
+			m.console.Error("Script guard failed: %v", err)
+			m.addNotRun(pendingScripts[i+1:])
+			failedCount++
+			m.console.Summary(totalCount, successCount, failedCount, skippedCount, m.slowScripts())
+			return &ScriptError{Script: script.Name}
+		}
+		if skip {
+			m.addResult(script, ResultSkipped, started, nil)
+			skippedCount++
+			continue
+		}
+
 		m.console.Script(script.Name, "executing")
+		m.reportProgress(ScriptProgress{Script: script.Name, Status: ProgressExecuting})
 
-		if err := m.executeScript(script, currentCommit, isLast); err != nil {
-			m.console.Script(script.Name, "failed")
+		tolerated, err := m.executeWithPolicy(m.tracker, script, rec)
+		if err != nil {
+			m.addResult(script, ResultFailed, started, err)
 			m.console.Error("Script execution failed: %v", err)
+			m.addNotRun(pendingScripts[i+1:])
 			failedCount++
 
 			// Report summary and exit
-			m.console.Summary(len(scripts), successCount, failedCount, skippedCount)
-			return fmt.Errorf("migration failed at script: %s", script.Name)
+			m.console.Summary(totalCount, successCount, failedCount, skippedCount, m.slowScripts())
+			return &ScriptError{Script: script.Name}
+		}
+		if tolerated {
+			m.addResult(script, ResultTolerated, started, nil)
+			failedCount++
+			continue
 		}
 
-		m.console.Script(script.Name, "success")
+		m.addResult(script, ResultSuccess, started, nil)
 		successCount++
+
+		if m.faults.KillConn(m.executed) {
+			m.console.Warn("Fault injection: dropping the database connection after %s", script.Name)
+			m.db.Kill()
+		}
 	}
 
-	// 12. Report final status
-	m.console.Summary(len(scripts), successCount, failedCount, skippedCount)
-	m.console.Success("Migration completed successfully!")
+	// Report final status
+	m.console.Summary(totalCount, successCount, failedCount, skippedCount, m.slowScripts())
+	if failedCount > 0 {
+		m.console.Warn("%d scripts failed with onError=continue", failedCount)
+	}
+	if len(deferredNames) > 0 {
+		m.console.Warn("%d scripts deferred by tag filter, still pending: %s", len(deferredNames), strings.Join(deferredNames, ", "))
+	}
 
 	return nil
 }
 
-// executeScript runs a single script within a transaction
-func (m *Migrator) executeScript(script git.ScriptInfo, gitID string, isLast bool) error {
-	// Read script content
-	scriptPath := filepath.Join(m.config.ScriptsDir, script.Name)
-	content, err := os.ReadFile(scriptPath)
+// runSeedsAfterSchema runs new or changed seed scripts when a seed directory is configured
+func (m *Migrator) runSeedsAfterSchema() error {
+	if m.config.SeedDir == "" {
+		return nil
+	}
+	m.console.Header("Seed Data")
+	return m.runSeeds(false)
+}
+
+// executeScriptDirect runs a script on a plain connection for statements that
+// cannot run inside a transaction (noTransaction directive)
+// Statements that succeeded before a failure are NOT rolled back
+func (m *Migrator) executeScriptDirect(t *Tracker, script git.ScriptInfo, content []byte, directives directive.Set, rec ScriptRecord) error {
+	m.console.Warn("%s runs without a transaction (noTransaction)", script.Name)
+
+	ctx, cancel := m.scriptContext(directives)
+	defer cancel()
+	started := m.clock.Now()
+
+	var err error
+	if len(directives.Loads) == 0 || len(splitStatements(string(content))) > 0 {
+		err = m.db.ExecuteSQL(ctx, string(content))
+	}
+	if err == nil {
+		err = m.executeLoads(m.db, script, directives.Loads)
+	}
+	rec.DurationMS = m.clock.Now().Sub(started).Milliseconds()
 	if err != nil {
-		// Try the full path from git
-		content, err = os.ReadFile(script.Path)
+		t.RecordExecutionDirect(failureRecord(rec, directives, err))
+		m.console.Warn("%s was not transactional: statements before the failure may have been applied", script.Name)
+		return fmt.Errorf("script execution error: %w", err)
+	}
+	m.scriptRan()
+
+	rec.Completed = true
+	if err := t.RecordExecutionDirect(rec); err != nil {
+		return fmt.Errorf("failed to record execution: %w", err)
+	}
+
+	return nil
+}
+
+// prepareTracker creates the tracking table for a run that writes it. A dry run, plan
+// or validate only reads it, so they need no write access; without a table, they find
+// nothing applied
+func (m *Migrator) prepareTracker(readOnly bool) error {
+	if !readOnly {
+		return m.tracker.EnsureTable()
+	}
+	exists, err := m.tracker.TableExists(m.ctx)
+	if err != nil {
+		return err
+	}
+	m.tracker.absent = !exists
+	return nil
+}
+
+// readScript returns the executable content of a script from the scripts directory,
+// with includes expanded, ${NAME} placeholders substituted and .sql.tmpl templates rendered
+func (m *Migrator) readScript(script git.ScriptInfo) ([]byte, error) {
+	var content []byte
+	var err error
+	if sql, ok := m.changesets[script.Name]; ok {
+		content = []byte(sql)
+	} else if filepath.IsAbs(script.Path) {
+		if info, statErr := os.Stat(script.Path); statErr == nil && info.IsDir() {
+			return m.readBundle(script)
+		}
+		content, err = m.readSource(script.Path)
+	} else {
+		content, err = m.readSource(filepath.Join(m.config.ScriptsDir, script.Name))
 		if err != nil {
-			return fmt.Errorf("failed to read script %s: %w", script.Name, err)
+			// Try the full path from git
+			if m.config.Source != config.SourceWorktree {
+				content, err = m.readAtRef(script.Path)
+			} else {
+				content, err = os.ReadFile(script.Path)
+			}
 		}
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script %s: %w", script.Name, err)
+	}
 
-	// Start transaction
-	tx, err := m.db.Begin()
+	// Decode, strip the BOM and normalize line endings before anything else sees the text
+	content, err = textenc.Normalize(content, m.config.Encoding)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to decode script %s: %w", script.Name, err)
+	}
+
+	content, err = m.expandIncludes(script.Name, content)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, missing, err := SubstitutePlaceholders(string(content), m.config.Vars, m.config.StrictVars)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", script.Name, err)
+	}
+	if len(missing) > 0 {
+		m.console.Warn("%s: undefined placeholders left as-is: %s", script.Name, strings.Join(missing, ", "))
+	}
+
+	if git.IsTemplate(script.Name) {
+		data, err := m.templateContext()
+		if err != nil {
+			return nil, err
+		}
+		rendered, err = RenderTemplate(script.Name, rendered, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(rendered), nil
+}
+
+// readSource reads a file, given like the paths within the scripts directory. Files of
+// the scripts directory are read from the commit being migrated to, so uncommitted
+// edits never run; --source worktree reads the working tree instead
+func (m *Migrator) readSource(path string) ([]byte, error) {
+	if m.config.Source == config.SourceWorktree {
+		return os.ReadFile(path)
+	}
+	dir, err := filepath.Abs(m.config.ScriptsDir)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		// Outside the scripts directory, e.g. in --seed-dir
+		return os.ReadFile(path)
+	}
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	return m.readAtRef(prefix + filepath.ToSlash(rel))
+}
+
+// readAtRef reads a file, given relative to the repository root, from the commit being
+// migrated to: HEAD, or the one of --ref
+func (m *Migrator) readAtRef(repoPath string) ([]byte, error) {
+	if m.commit == "" {
+		if _, err := m.currentCommit(); err != nil {
+			return nil, err
+		}
+	}
+	if content, ok := m.sources[repoPath]; ok {
+		return content, nil
+	}
+	content, err := m.git.ReadFile(m.commit, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if m.sources == nil {
+		m.sources = make(map[string][]byte)
+	}
+	m.sources[repoPath] = content
+	return content, nil
+}
+
+// forgetCommit drops the commit and files of the previous command, so each command reads
+// the scripts of the commit that is current when it starts
+func (m *Migrator) forgetCommit() {
+	m.commit, m.sources = "", nil
+}
+
+// currentCommit resolves the commit being migrated to and keeps it for readAtRef, so the
+// scripts of a run are read from the commit it records, resolved once
+func (m *Migrator) currentCommit() (string, error) {
+	commit, err := m.git.GetCurrentCommit()
+	if err != nil {
+		return "", err
+	}
+	if commit != m.commit {
+		m.commit, m.sources = commit, nil
+	}
+	return commit, nil
+}
+
+// loadDirectives parses the directive header of every script before anything runs,
+// so a malformed header fails the batch up front
+func (m *Migrator) loadDirectives(scripts []git.ScriptInfo) error {
+	for i := range scripts {
+		content, err := m.readScript(scripts[i])
+		if err != nil {
+			return err
+		}
+		set, err := directive.Parse(content)
+		if err != nil {
+			return fmt.Errorf("invalid directives in %s: %w", scripts[i].Name, err)
+		}
+		scripts[i].Directives = set
+	}
+	return nil
+}
+
+// filterPending drops scripts that have already been executed
+func filterPending(scripts []git.ScriptInfo, executed map[string]bool) []git.ScriptInfo {
+	var pending []git.ScriptInfo
+	for _, script := range scripts {
+		if !executed[script.Name] {
+			pending = append(pending, script)
+		}
+	}
+	return pending
+}
+
+// executeScript runs a single script within a transaction
+// rec describes the tracking row to write into t; Completed and BatchID are filled in here
+func (m *Migrator) executeScript(t *Tracker, script git.ScriptInfo, rec ScriptRecord) error {
+	// Read script content
+	content, err := m.readScript(script)
+	if err != nil {
+		return err
+	}
+
+	directives, err := directive.Parse(content)
+	if err != nil {
+		return fmt.Errorf("invalid directives in %s: %w", script.Name, err)
+	}
+
+	rec.BatchID = m.batchID
+	rec.Checksum, err = m.scriptChecksum(script, content)
+	if err != nil {
+		return err
+	}
+	rec.Fingerprint = Fingerprint(content)
+
+	if err := m.archiveRendered(script, content); err != nil {
+		return err
+	}
+
+	if directives.Backfill != nil {
+		return m.executeBackfill(t, script, content, directives, rec)
 	}
-	defer tx.Rollback()
 
-	// Execute script
-	if err := db.ExecuteSQL(tx, string(content)); err != nil {
+	if directives.NoTransaction {
+		return m.executeScriptDirect(t, script, content, directives, rec)
+	}
+
+	// Execute script, then load its data files in the same transaction, retrying
+	// transient errors
+	ctx, cancel := m.scriptContext(directives)
+	defer cancel()
+	started := m.clock.Now()
+	tx, err := m.executeInTx(ctx, script, content, directives)
+	rec.DurationMS = m.clock.Now().Sub(started).Milliseconds()
+	if err != nil {
 		// Record failure (in a new transaction since this one is tainted)
-		m.tracker.RecordExecutionDirect(script.Name, false, false, gitID)
+		t.RecordExecutionDirect(failureRecord(rec, directives, err))
 		return fmt.Errorf("script execution error: %w", err)
 	}
+	defer tx.Rollback()
+	m.scriptRan()
 
 	// Record success
-	if err := m.tracker.RecordExecution(tx, script.Name, true, isLast, gitID); err != nil {
+	rec.Completed = true
+	if t.db != m.db {
+		return m.commitRecorded(t, tx, rec)
+	}
+	if err := t.RecordExecution(tx, rec); err != nil {
 		return fmt.Errorf("failed to record execution: %w", err)
 	}
 
@@ -196,6 +756,34 @@ func (m *Migrator) executeScript(script git.ScriptInfo, gitID string, isLast boo
 	return nil
 }
 
+// scriptRan counts a script whose statements ran and crashes there if a fault says so
+func (m *Migrator) scriptRan() {
+	m.executed++
+	m.faults.ScriptExecuted(m.executed)
+}
+
+// commitRecorded commits a script whose tracker runs on a separate connection: the
+// record is written first and committed right after the script, so a script that fails
+// to commit is never recorded
+func (m *Migrator) commitRecorded(t *Tracker, tx *sql.Tx, rec ScriptRecord) error {
+	trackerTx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tracker transaction: %w", err)
+	}
+	defer trackerTx.Rollback()
+	if err := t.RecordExecution(trackerTx, rec); err != nil {
+		return fmt.Errorf("failed to record execution: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if err := trackerTx.Commit(); err != nil {
+		return fmt.Errorf("%s was committed but could not be recorded, so the next run would execute it again; insert its tracking row by hand: %w", rec.ScriptName, err)
+	}
+	return nil
+}
+
 // executeMissedScripts processes scripts from the missed scripts file
 func (m *Migrator) executeMissedScripts() error {
 	m.console.Header("Processing Missed Scripts")
@@ -227,7 +815,7 @@ func (m *Migrator) executeMissedScripts() error {
 	m.console.Info("Found %d missed scripts to process", len(missedScripts))
 
 	// Get current commit for tracking
-	currentCommit, err := m.git.GetCurrentCommit()
+	currentCommit, err := m.currentCommit()
 	if err != nil {
 		return fmt.Errorf("failed to get current commit: %w", err)
 	}
@@ -245,6 +833,11 @@ func (m *Migrator) executeMissedScripts() error {
 			continue
 		}
 
+		if m.config.DryRun {
+			m.console.Script(scriptName, "pending")
+			continue
+		}
+
 		isLast := i == len(missedScripts)-1
 		script := git.ScriptInfo{
 			Name: scriptName,
@@ -253,7 +846,8 @@ func (m *Migrator) executeMissedScripts() error {
 
 		m.console.Script(scriptName, "executing")
 
-		if err := m.executeScript(script, currentCommit, isLast); err != nil {
+		rec := ScriptRecord{ScriptName: scriptName, EndOfBatch: isLast, LastGitID: currentCommit}
+		if err := m.executeScript(m.tracker, script, rec); err != nil {
 			m.console.Script(scriptName, "failed")
 			return fmt.Errorf("failed to execute missed script %s: %w", scriptName, err)
 		}
@@ -261,27 +855,33 @@ func (m *Migrator) executeMissedScripts() error {
 		m.console.Script(scriptName, "success")
 	}
 
+	if m.config.DryRun {
+		return nil
+	}
 	m.console.Success("All missed scripts processed successfully")
 	return nil
 }
 
 // ExecuteSingleScript executes a single script by name (for testing purposes)
 func (m *Migrator) ExecuteSingleScript(scriptName string) error {
-	currentCommit, err := m.git.GetCurrentCommit()
+	currentCommit, err := m.currentCommit()
 	if err != nil {
 		currentCommit = "manual"
 	}
+	if m.batchID == "" {
+		m.batchID = m.ids.NewID()
+	}
 
 	script := git.ScriptInfo{
 		Name: scriptName,
 		Path: filepath.Join(m.config.ScriptsDir, scriptName),
 	}
 
-	return m.executeScript(script, currentCommit, true)
+	return m.executeScript(m.tracker, script, ScriptRecord{ScriptName: scriptName, EndOfBatch: true, LastGitID: currentCommit})
 }
 
 // getTransaction is a helper to get a transaction from the tracker's db
 // This is needed because RecordExecution expects a *sql.Tx
 func (m *Migrator) beginTrackerTransaction() (*sql.Tx, error) {
-	return m.db.Begin()
+	return m.tracker.db.Begin()
 }
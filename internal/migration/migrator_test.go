@@ -1,13 +1,24 @@
 package migration
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bontaramsonta/db-migration/internal/config"
 	"github.com/bontaramsonta/db-migration/internal/console"
-	"github.com/bontaramsonta/db-migration/internal/testhelpers"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/window"
+	"github.com/bontaramsonta/db-migration/testkit"
 )
 
 // TestMigrator_FreshMigration tests migration on a fresh database with no prior executions
@@ -18,16 +29,16 @@ func TestMigrator_FreshMigration(t *testing.T) {
 	}
 
 	// 1. Setup MySQL container
-	testDB := testhelpers.SetupTestDB(t)
+	testDB := testkit.SetupTestDB(t)
 
 	// 2. Setup git repository
-	repo := testhelpers.SetupGitRepo(t)
+	repo := testkit.SetupGitRepo(t)
 
 	// 3. Create scripts directory
 	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
 
 	// 4. Create SQL scripts and commit them
-	scripts := testhelpers.StandardScripts()
+	scripts := testkit.StandardScripts()
 	for filename, content := range scripts {
 		repo.AddSQLScript(scriptsDir, filename, content)
 	}
@@ -43,8 +54,8 @@ func TestMigrator_FreshMigration(t *testing.T) {
 		ScriptsDir: scriptsDir,
 	}
 
-	cons := console.New(false)
-	migrator := NewMigrator(cfg, testDB.DB, cons)
+	cons := console.New()
+	migrator := NewMigrator(cfg, wrapDB(testDB), cons)
 
 	// 6. Run migration
 	err := migrator.Run()
@@ -79,6 +90,7 @@ func TestMigrator_FreshMigration(t *testing.T) {
 			t.Errorf("last script should have lastgitid = %s, got %s", commitHash, lastRecord.LastGitID)
 		}
 	}
+	testDB.AssertBatches(1)
 
 	// 8. Verify database tables were created
 	usersExists, err := testDB.TableExists("users")
@@ -114,14 +126,14 @@ func TestMigrator_IncrementalMigration(t *testing.T) {
 	}
 
 	// 1. Setup MySQL container
-	testDB := testhelpers.SetupTestDB(t)
+	testDB := testkit.SetupTestDB(t)
 
 	// 2. Setup git repository
-	repo := testhelpers.SetupGitRepo(t)
+	repo := testkit.SetupGitRepo(t)
 	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
 
 	// 3. Create initial scripts and commit (commit A)
-	initialScripts := testhelpers.StandardScripts()
+	initialScripts := testkit.StandardScripts()
 	for filename, content := range initialScripts {
 		repo.AddSQLScript(scriptsDir, filename, content)
 	}
@@ -136,8 +148,8 @@ func TestMigrator_IncrementalMigration(t *testing.T) {
 		Port:       mustParsePort(testDB.Port),
 		ScriptsDir: scriptsDir,
 	}
-	cons := console.New(false)
-	migrator := NewMigrator(cfg, testDB.DB, cons)
+	cons := console.New()
+	migrator := NewMigrator(cfg, wrapDB(testDB), cons)
 
 	if err := migrator.Run(); err != nil {
 		t.Fatalf("initial migration failed: %v", err)
@@ -150,14 +162,14 @@ func TestMigrator_IncrementalMigration(t *testing.T) {
 	}
 
 	// 5. Add new scripts and commit (commit B)
-	newScripts := testhelpers.IncrementalScripts()
+	newScripts := testkit.IncrementalScripts()
 	for filename, content := range newScripts {
 		repo.AddSQLScript(scriptsDir, filename, content)
 	}
 	commitB := repo.CommitScripts("Add new migration scripts")
 
 	// 6. Run incremental migration
-	migrator2 := NewMigrator(cfg, testDB.DB, cons)
+	migrator2 := NewMigrator(cfg, wrapDB(testDB), cons)
 	if err := migrator2.Run(); err != nil {
 		t.Fatalf("incremental migration failed: %v", err)
 	}
@@ -223,14 +235,14 @@ func TestMigrator_ScriptFailure(t *testing.T) {
 	}
 
 	// 1. Setup MySQL container
-	testDB := testhelpers.SetupTestDB(t)
+	testDB := testkit.SetupTestDB(t)
 
 	// 2. Setup git repository
-	repo := testhelpers.SetupGitRepo(t)
+	repo := testkit.SetupGitRepo(t)
 	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
 
 	// 3. Create scripts with one invalid script
-	failingScripts := testhelpers.FailingScripts()
+	failingScripts := testkit.FailingScripts()
 	for filename, content := range failingScripts {
 		repo.AddSQLScript(scriptsDir, filename, content)
 	}
@@ -245,8 +257,8 @@ func TestMigrator_ScriptFailure(t *testing.T) {
 		Port:       mustParsePort(testDB.Port),
 		ScriptsDir: scriptsDir,
 	}
-	cons := console.New(false)
-	migrator := NewMigrator(cfg, testDB.DB, cons)
+	cons := console.New()
+	migrator := NewMigrator(cfg, wrapDB(testDB), cons)
 
 	err := migrator.Run()
 	if err == nil {
@@ -310,14 +322,14 @@ func TestMigrator_ModifiedScriptDetection(t *testing.T) {
 	}
 
 	// 1. Setup MySQL container
-	testDB := testhelpers.SetupTestDB(t)
+	testDB := testkit.SetupTestDB(t)
 
 	// 2. Setup git repository
-	repo := testhelpers.SetupGitRepo(t)
+	repo := testkit.SetupGitRepo(t)
 	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
 
 	// 3. Create initial script and commit
-	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testhelpers.SQLScripts.CreateUsers)
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
 	repo.CommitScripts("Add initial script")
 
 	// 4. Run initial migration
@@ -329,8 +341,8 @@ func TestMigrator_ModifiedScriptDetection(t *testing.T) {
 		Port:       mustParsePort(testDB.Port),
 		ScriptsDir: scriptsDir,
 	}
-	cons := console.New(false)
-	migrator := NewMigrator(cfg, testDB.DB, cons)
+	cons := console.New()
+	migrator := NewMigrator(cfg, wrapDB(testDB), cons)
 
 	if err := migrator.Run(); err != nil {
 		t.Fatalf("initial migration failed: %v", err)
@@ -343,7 +355,7 @@ func TestMigrator_ModifiedScriptDetection(t *testing.T) {
 	}
 
 	// 5. Modify the executed script
-	modifiedContent := testhelpers.ModifiedCreateUsers()
+	modifiedContent := testkit.ModifiedCreateUsers()
 	scriptPath := filepath.Join(scriptsDir, "001_create_users.sql")
 	repo.ModifyFile(filepath.Join("Automated_Change_Scripts", "001_create_users.sql"), modifiedContent)
 	repo.CommitChanges("Modify executed script")
@@ -355,7 +367,7 @@ func TestMigrator_ModifiedScriptDetection(t *testing.T) {
 	}
 
 	// 6. Try to run migration again - should fail
-	migrator2 := NewMigrator(cfg, testDB.DB, cons)
+	migrator2 := NewMigrator(cfg, wrapDB(testDB), cons)
 	err := migrator2.Run()
 
 	if err == nil {
@@ -394,14 +406,14 @@ func TestMigrator_NoNewScripts(t *testing.T) {
 	}
 
 	// 1. Setup MySQL container
-	testDB := testhelpers.SetupTestDB(t)
+	testDB := testkit.SetupTestDB(t)
 
 	// 2. Setup git repository
-	repo := testhelpers.SetupGitRepo(t)
+	repo := testkit.SetupGitRepo(t)
 	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
 
 	// 3. Create scripts and commit
-	scripts := testhelpers.StandardScripts()
+	scripts := testkit.StandardScripts()
 	for filename, content := range scripts {
 		repo.AddSQLScript(scriptsDir, filename, content)
 	}
@@ -416,15 +428,15 @@ func TestMigrator_NoNewScripts(t *testing.T) {
 		Port:       mustParsePort(testDB.Port),
 		ScriptsDir: scriptsDir,
 	}
-	cons := console.New(false)
-	migrator := NewMigrator(cfg, testDB.DB, cons)
+	cons := console.New()
+	migrator := NewMigrator(cfg, wrapDB(testDB), cons)
 
 	if err := migrator.Run(); err != nil {
 		t.Fatalf("initial migration failed: %v", err)
 	}
 
 	// 5. Run migration again - should succeed with no new scripts
-	migrator2 := NewMigrator(cfg, testDB.DB, cons)
+	migrator2 := NewMigrator(cfg, wrapDB(testDB), cons)
 	if err := migrator2.Run(); err != nil {
 		t.Fatalf("second migration should succeed even with no new scripts: %v", err)
 	}
@@ -447,10 +459,10 @@ func TestMigrator_EmptyRepository(t *testing.T) {
 	}
 
 	// 1. Setup MySQL container
-	testDB := testhelpers.SetupTestDB(t)
+	testDB := testkit.SetupTestDB(t)
 
 	// 2. Setup git repository with just an initial commit
-	repo := testhelpers.SetupGitRepo(t)
+	repo := testkit.SetupGitRepo(t)
 	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
 
 	// Create an empty file to make the directory tracked
@@ -467,8 +479,8 @@ func TestMigrator_EmptyRepository(t *testing.T) {
 		Port:       mustParsePort(testDB.Port),
 		ScriptsDir: scriptsDir,
 	}
-	cons := console.New(false)
-	migrator := NewMigrator(cfg, testDB.DB, cons)
+	cons := console.New()
+	migrator := NewMigrator(cfg, wrapDB(testDB), cons)
 
 	if err := migrator.Run(); err != nil {
 		t.Fatalf("migration should succeed on empty repo: %v", err)
@@ -485,6 +497,1686 @@ func TestMigrator_EmptyRepository(t *testing.T) {
 	}
 }
 
+// TestMigrator_DeterministicBatch tests that injected clock and ID generator are used for tracking records
+func TestMigrator_DeterministicBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL container
+	testDB := testkit.SetupTestDB(t)
+
+	// 2. Setup git repository with scripts
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	for filename, content := range testkit.StandardScripts() {
+		repo.AddSQLScript(scriptsDir, filename, content)
+	}
+	repo.CommitScripts("Add migration scripts")
+
+	// 3. Run migration with fake clock and IDs
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := testkit.NewFakeClock(fixed)
+	ids := testkit.NewSequentialIDs("batch")
+	migrator := NewMigrator(cfg, wrapDB(testDB), console.New(), WithClock(clock), WithIDGenerator(ids))
+
+	if err := migrator.Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if migrator.BatchID() != "batch-1" {
+		t.Errorf("expected batch id batch-1, got %s", migrator.BatchID())
+	}
+
+	// 4. Verify every record carries the fixed batch id and timestamp
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	for _, rec := range records {
+		if rec.BatchID != "batch-1" {
+			t.Errorf("script %s should have batchid batch-1, got %s", rec.ScriptName, rec.BatchID)
+		}
+		if !rec.CreatedDateTime.Equal(fixed) {
+			t.Errorf("script %s should have createddatetime %v, got %v", rec.ScriptName, fixed, rec.CreatedDateTime)
+		}
+	}
+}
+
+// TestMigrator_DownLastBatch tests reverting the last batch with down scripts and re-applying it
+func TestMigrator_DownLastBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL container
+	testDB := testkit.SetupTestDB(t)
+
+	// 2. Setup git repository and run the initial batch
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	for filename, content := range testkit.StandardScripts() {
+		repo.AddSQLScript(scriptsDir, filename, content)
+	}
+	commitA := repo.CommitScripts("Initial migration scripts")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	cons := console.New()
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("initial migration failed: %v", err)
+	}
+
+	// 3. Add a second batch with paired down scripts
+	for filename, content := range testkit.IncrementalScripts() {
+		repo.AddSQLScript(scriptsDir, filename, content)
+	}
+	for filename, content := range testkit.IncrementalDownScripts() {
+		repo.AddSQLScript(scriptsDir, filename, content)
+	}
+	repo.CommitScripts("Add second batch")
+
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+
+	// 4. Revert the last batch
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Down("", 0); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+
+	for _, table := range []string{"comments", "tags"} {
+		exists, err := testDB.TableExists(table)
+		if err != nil {
+			t.Fatalf("failed to check %s table: %v", table, err)
+		}
+		if exists {
+			t.Errorf("%s table should have been dropped by down script", table)
+		}
+	}
+	usersExists, _ := testDB.TableExists("users")
+	if !usersExists {
+		t.Error("users table from the first batch should remain")
+	}
+
+	// Rollback rows point the tracker back at the first batch's commit
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("expected 7 tracking records (5 up + 2 down), got %d", len(records))
+	}
+	last := records[len(records)-1]
+	if !last.EndOfBatch || last.LastGitID != commitA {
+		t.Errorf("unexpected final rollback record: %+v", last)
+	}
+
+	// 5. A new run re-applies the reverted scripts
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("re-apply failed: %v", err)
+	}
+	commentsExists, _ := testDB.TableExists("comments")
+	if !commentsExists {
+		t.Error("comments table should exist after re-applying")
+	}
+}
+
+// TestMigrator_RepeatableScripts tests that R__ scripts re-run only when their content changes
+func TestMigrator_RepeatableScripts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL container
+	testDB := testkit.SetupTestDB(t)
+
+	// 2. Setup git repository with a versioned and a repeatable script
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.AddSQLScript(scriptsDir, "R__users_view.sql", "CREATE OR REPLACE VIEW user_names AS SELECT name FROM users;")
+	repo.CommitScripts("Add scripts")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	cons := console.New()
+
+	// 3. First run executes both, repeatable last
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	records, _ := testDB.GetTrackingRecords()
+	if len(records) != 2 || records[1].ScriptName != "R__users_view.sql" || !records[1].EndOfBatch {
+		t.Fatalf("expected repeatable script to run last and end the batch, got %+v", records)
+	}
+
+	// 4. Unchanged repeatable is not re-run
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+	records, _ = testDB.GetTrackingRecords()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 tracking records after unchanged run, got %d", len(records))
+	}
+
+	// 5. Changing the repeatable re-runs it instead of failing modification checks
+	repo.ModifyFile(filepath.Join("Automated_Change_Scripts", "R__users_view.sql"),
+		"CREATE OR REPLACE VIEW user_names AS SELECT name, email FROM users;")
+	repo.CommitChanges("Update view")
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("migration after repeatable change failed: %v", err)
+	}
+	records, _ = testDB.GetTrackingRecords()
+	if len(records) != 3 || records[2].ScriptName != "R__users_view.sql" {
+		t.Fatalf("expected repeatable script to re-run, got %+v", records)
+	}
+	colExists, err := testDB.ColumnExists("user_names", "email")
+	if err != nil {
+		t.Fatalf("failed to check column: %v", err)
+	}
+	if !colExists {
+		t.Error("view should have been replaced with the new definition")
+	}
+}
+
+// TestMigrator_NoTransactionDirective tests that noTransaction scripts run outside a transaction
+func TestMigrator_NoTransactionDirective(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL container
+	testDB := testkit.SetupTestDB(t)
+
+	// 2. Second script inserts a row and then fails, without a transaction
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_items.sql": testkit.SimpleCreateTable("items"),
+	}, "Add table")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/002_load_items.sql": "-- dbmig: noTransaction\n" +
+			testkit.SimpleInsert("items", "first") + "\n" + testkit.SQLScripts.InvalidSyntax,
+	}, "Add non-transactional script")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 3. Run migration - should fail on the second script
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err == nil {
+		t.Fatal("migration should have failed due to invalid SQL")
+	}
+
+	// 4. The insert before the failure is not rolled back
+	count, err := testDB.GetTableRowCount("items")
+	if err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 row to survive the non-transactional failure, got %d", count)
+	}
+
+	// 5. The failure is still tracked
+	records, _ := testDB.GetTrackingRecords()
+	if len(records) != 2 || records[1].Completed {
+		t.Errorf("expected second script to be recorded as failed, got %+v", records)
+	}
+}
+
+// TestMigrator_SeedData tests that seed scripts are tracked apart from schema migrations
+func TestMigrator_SeedData(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL container
+	testDB := testkit.SetupTestDB(t)
+
+	// 2. Setup git repository with a schema script and a seed script
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	seedDir := repo.CreateScriptsDir("Seed_Data")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_roles.sql": testkit.SimpleCreateTable("roles"),
+		"Seed_Data/001_roles.sql":                       testkit.SimpleInsert("roles", "admin"),
+	}, "Add schema and seed")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+		SeedDir:    seedDir,
+	}
+	cons := console.New()
+
+	// 3. Run applies schema, then seeds
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	count, err := testDB.GetTableRowCount("roles")
+	if err != nil {
+		t.Fatalf("failed to count roles: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 seeded role, got %d", count)
+	}
+
+	// 4. Unchanged seeds are not re-run; reseed runs them again
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Seed(false); err != nil {
+		t.Fatalf("seed failed: %v", err)
+	}
+	count, _ = testDB.GetTableRowCount("roles")
+	if count != 1 {
+		t.Errorf("unchanged seed should not re-run, got %d roles", count)
+	}
+	if err := NewMigrator(cfg, wrapDB(testDB), cons).Seed(true); err != nil {
+		t.Fatalf("reseed failed: %v", err)
+	}
+	count, _ = testDB.GetTableRowCount("roles")
+	if count != 2 {
+		t.Errorf("reseed should re-run the seed script, got %d roles", count)
+	}
+
+	// 5. Schema history only contains schema scripts
+	records, _ := testDB.GetTrackingRecords()
+	if len(records) != 1 {
+		t.Errorf("expected 1 schema tracking record, got %d", len(records))
+	}
+	seedRecords, err := testDB.GetTableRowCount("sqlSeedExec")
+	if err != nil {
+		t.Fatalf("failed to count seed records: %v", err)
+	}
+	if seedRecords != 2 {
+		t.Errorf("expected 2 seed tracking records, got %d", seedRecords)
+	}
+}
+
+// TestMigrator_SkipIfGuard tests that a matching skip-if guard records the script as skipped
+func TestMigrator_SkipIfGuard(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL with a pre-existing users table
+	testDB := testkit.SetupTestDB(t)
+	if err := testDB.Exec(testkit.SQLScripts.CreateUsers); err != nil {
+		t.Fatalf("failed to create users table: %v", err)
+	}
+
+	// 2. Guarded script would fail if executed because the table exists
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	guard := "-- dbmig: skip-if=SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'users'\n"
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", guard+testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add guarded script")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 3. Run migration - guard skips the script
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 4. Skip is recorded as a completed row so the script is not pending again
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 1 || !records[0].Completed || !records[0].EndOfBatch {
+		t.Fatalf("expected one completed end-of-batch record, got %+v", records)
+	}
+	var action string
+	if err := testDB.QueryRow("SELECT action FROM sqlScriptExec WHERE sno = ?", records[0].SNO).Scan(&action); err != nil {
+		t.Fatalf("failed to read action: %v", err)
+	}
+	if action != ActionSkip {
+		t.Errorf("expected action %q, got %q", ActionSkip, action)
+	}
+}
+
+func TestMigrator_OnErrorContinue(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a best-effort script that fails, followed by a good one
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_best_effort.sql", "-- dbmig: onError=continue\nDROP TABLE does_not_exist;")
+	repo.CommitScripts("Add best-effort script")
+	repo.AddSQLScript(scriptsDir, "002_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 2. Run migration - the failure is tolerated
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	exists, err := testDB.TableExists("users")
+	if err != nil || !exists {
+		t.Fatalf("expected users table to be created, err=%v", err)
+	}
+
+	// 3. Both outcomes are recorded and the batch is closed
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 tracking records, got %d", len(records))
+	}
+	for _, rec := range records {
+		if rec.Completed == (rec.ScriptName == "001_best_effort.sql") {
+			t.Errorf("unexpected completed=%v for %s", rec.Completed, rec.ScriptName)
+		}
+	}
+	if !records[1].EndOfBatch {
+		t.Errorf("expected the last record to close the batch, got %+v", records[1])
+	}
+
+	// 4. Next run finds nothing pending and does not abort
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+}
+
+func TestMigrator_BatchHooks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL with a table the hooks write to
+	testDB := testkit.SetupTestDB(t)
+	if err := testDB.Exec("CREATE TABLE hook_log (event VARCHAR(20))"); err != nil {
+		t.Fatalf("failed to create hook_log table: %v", err)
+	}
+
+	// 2. Hooks live under hooks/ next to the scripts
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "hooks/pre-batch.sql", "INSERT INTO hook_log VALUES ('pre');")
+	repo.AddSQLScript(scriptsDir, "hooks/post-batch.sql", "INSERT INTO hook_log VALUES ('post');")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add script and hooks")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 3. Run migration twice - hooks run only for the batch with scripts
+	for i := 0; i < 2; i++ {
+		if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+			t.Fatalf("migration %d failed: %v", i+1, err)
+		}
+	}
+
+	count, err := testDB.GetTableRowCount("hook_log")
+	if err != nil {
+		t.Fatalf("failed to count hook_log rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected hooks to run once each, got %d rows", count)
+	}
+
+	// 4. Hooks are not tracked as migrations
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 1 || records[0].ScriptName != "001_create_users.sql" {
+		t.Errorf("expected only 001_create_users.sql to be tracked, got %+v", records)
+	}
+}
+
+func TestMigrator_Bundle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a bundle whose manifest reverses file name order
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+	repo.AddSQLScript(scriptsDir, "002_users_bundle/a_insert.sql", "INSERT INTO users (id, name, email) SELECT id + 1, 'bob', 'bob@example.com' FROM users;")
+	repo.AddSQLScript(scriptsDir, "002_users_bundle/b_insert.sql", "INSERT INTO users (id, name, email) VALUES (1, 'alice', 'alice@example.com');")
+	repo.AddSQLScript(scriptsDir, "002_users_bundle/"+BundleManifest, "b_insert.sql\na_insert.sql\n")
+	repo.CommitScripts("Add bundle")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 2. Run migration
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 3. Files ran in manifest order: bob is derived from alice
+	count, err := testDB.GetTableRowCount("users")
+	if err != nil {
+		t.Fatalf("failed to count users: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 users, got %d", count)
+	}
+
+	// 4. The bundle is tracked as one unit
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, rec := range records {
+		names[rec.ScriptName] = true
+	}
+	if len(records) != 2 || !names["001_create_users.sql"] || !names["002_users_bundle"] {
+		t.Errorf("expected records for 001_create_users.sql and 002_users_bundle, got %+v", records)
+	}
+}
+
+func TestMigrator_Backfill(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL with rows spread over several chunks
+	testDB := testkit.SetupTestDB(t)
+	if err := testDB.Exec("CREATE TABLE orders (id INT PRIMARY KEY, shipped BOOLEAN, status VARCHAR(10))"); err != nil {
+		t.Fatalf("failed to create orders table: %v", err)
+	}
+	if err := testDB.Exec("INSERT INTO orders (id, shipped) VALUES (1, 1), (2, 0), (3, 1), (4, 0), (5, 1), (7, 0)"); err != nil {
+		t.Fatalf("failed to insert orders: %v", err)
+	}
+
+	// 2. Backfill script: the body is the value expression
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_backfill_status.sql", "-- dbmig: backfill table=orders column=status batch=2\nCASE WHEN shipped THEN 'shipped' ELSE 'open' END;")
+	repo.CommitScripts("Add backfill")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 3. Run migration
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 4. Every row is backfilled and the script is recorded once
+	var missing, shipped int
+	if err := testDB.QueryRow("SELECT SUM(status IS NULL), SUM(status = 'shipped') FROM orders").Scan(&missing, &shipped); err != nil {
+		t.Fatalf("failed to read orders: %v", err)
+	}
+	if missing != 0 || shipped != 3 {
+		t.Errorf("expected all rows backfilled with 3 shipped, got %d missing and %d shipped", missing, shipped)
+	}
+
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 1 || !records[0].Completed {
+		t.Errorf("expected one completed record, got %+v", records)
+	}
+}
+
+func TestMigrator_Rerun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and apply a script that appends a row
+	testDB := testkit.SetupTestDB(t)
+	if err := testDB.Exec("CREATE TABLE refreshes (id INT AUTO_INCREMENT PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create refreshes table: %v", err)
+	}
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	scriptPath := repo.AddSQLScript(scriptsDir, "001_refresh.sql", "INSERT INTO refreshes () VALUES ();")
+	repo.CommitScripts("Add refresh script")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	yes := func(string) bool { return true }
+	no := func(string) bool { return false }
+
+	// 2. Declined confirmation runs nothing
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Rerun("001_refresh.sql", no); err == nil {
+		t.Fatal("expected declined rerun to fail")
+	}
+
+	// 3. Confirmed rerun executes again and records a rerun row
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Rerun("001_refresh.sql", yes); err != nil {
+		t.Fatalf("rerun failed: %v", err)
+	}
+	count, err := testDB.GetTableRowCount("refreshes")
+	if err != nil {
+		t.Fatalf("failed to count refreshes: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows after rerun, got %d", count)
+	}
+	var reruns int
+	if err := testDB.QueryRow("SELECT COUNT(*) FROM sqlScriptExec WHERE action = ? AND completed = 1", ActionRerun).Scan(&reruns); err != nil {
+		t.Fatalf("failed to count rerun rows: %v", err)
+	}
+	if reruns != 1 {
+		t.Errorf("expected 1 rerun row, got %d", reruns)
+	}
+
+	// 4. A following run finds nothing pending
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration after rerun failed: %v", err)
+	}
+
+	// 5. Changed content and unapplied scripts are refused
+	repo.ModifyFile(scriptPath, "INSERT INTO refreshes () VALUES (), ();")
+	repo.CommitChanges("change refresh")
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Rerun("001_refresh.sql", yes); err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Errorf("expected checksum mismatch error, got %v", err)
+	}
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Rerun("999_unknown.sql", yes); err == nil {
+		t.Error("expected error for unapplied script")
+	}
+}
+
+func TestMigrator_DeferredByTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a slow-tagged script after a regular one
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+	repo.AddSQLScript(scriptsDir, "002_create_posts.sql", "-- dbmig: tags=slow\n"+testkit.SQLScripts.CreatePosts)
+	repo.CommitScripts("Add posts")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+		SkipTags:   []string{"slow"},
+	}
+
+	// 2. Run with the slow script deferred
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if exists, _ := testDB.TableExists("posts"); exists {
+		t.Fatal("expected posts table to be deferred")
+	}
+	if exists, _ := testDB.TableExists("users"); !exists {
+		t.Fatal("expected users table to be created")
+	}
+
+	// 3. A later run without the filter picks the deferred script up
+	cfg.SkipTags = nil
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+	if exists, _ := testDB.TableExists("posts"); !exists {
+		t.Fatal("expected deferred posts table to be created")
+	}
+
+	var actions []string
+	rows, err := testDB.DB.Query("SELECT action FROM sqlScriptExec WHERE scriptName = '002_create_posts.sql' ORDER BY sno")
+	if err != nil {
+		t.Fatalf("failed to read actions: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			t.Fatalf("failed to scan action: %v", err)
+		}
+		actions = append(actions, action)
+	}
+	if strings.Join(actions, ",") != ActionDefer+","+ActionUp {
+		t.Errorf("expected defer then up rows, got %v", actions)
+	}
+}
+
+func TestMigrator_WindowTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a slow-tagged script after a regular one
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.AddSQLScript(scriptsDir, "002_create_posts.sql", "-- dbmig: tags=slow\n"+testkit.SQLScripts.CreatePosts)
+	repo.CommitScripts("Add users and posts")
+
+	w, err := window.Parse("Sat 01:00-04:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir, Window: w, WindowTags: []string{"slow"}}
+	// 2026-10-14 is a Wednesday
+	clock := testkit.NewFakeClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+
+	// 2. Outside the window, only the light script runs
+	m := NewMigrator(cfg, wrapDB(testDB), console.New(), WithClock(clock))
+	if err := m.Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if m.HeldForWindow() != 1 {
+		t.Errorf("expected 1 script held for the window, got %d", m.HeldForWindow())
+	}
+	if exists, _ := testDB.TableExists("posts"); exists {
+		t.Fatal("expected posts table to wait for the window")
+	}
+	if exists, _ := testDB.TableExists("users"); !exists {
+		t.Fatal("expected users table to be created")
+	}
+
+	// 3. Inside the window, the slow script runs
+	clock.Set(time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC))
+	m = NewMigrator(cfg, wrapDB(testDB), console.New(), WithClock(clock))
+	if err := m.Run(); err != nil {
+		t.Fatalf("second migration failed: %v", err)
+	}
+	if exists, _ := testDB.TableExists("posts"); !exists || m.HeldForWindow() != 0 {
+		t.Fatal("expected posts table to be created in the window")
+	}
+}
+
+func TestMigrator_Approval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a script
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir}
+
+	// 2. A rejected plan runs nothing
+	var planned []string
+	reject := WithApproval(func(plan *Plan) (string, error) {
+		for _, c := range plan.Changes {
+			planned = append(planned, c.Script)
+		}
+		return "", errors.New("rejected by bob")
+	})
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New(), reject).Run(); err == nil || !strings.Contains(err.Error(), "rejected by bob") {
+		t.Fatalf("expected the rejection to stop the run, got %v", err)
+	}
+	if len(planned) != 1 || planned[0] != "001_create_users.sql" {
+		t.Errorf("expected the plan of 001_create_users.sql, got %v", planned)
+	}
+	if exists, _ := testDB.TableExists("users"); exists {
+		t.Fatal("expected users table not to be created")
+	}
+
+	// 3. An approved run records the approver
+	m := NewMigrator(cfg, wrapDB(testDB), console.New(), WithApproval(func(*Plan) (string, error) { return "alice", nil }))
+	err := m.Run()
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if report := m.Report(err); report.ApprovedBy != "alice" {
+		t.Errorf("expected the report to name alice, got %q", report.ApprovedBy)
+	}
+	var approvedBy string
+	if err := testDB.QueryRow("SELECT COALESCE(approvedby, '') FROM sqlScriptExec WHERE scriptName = '001_create_users.sql'").Scan(&approvedBy); err != nil || approvedBy != "alice" {
+		t.Errorf("expected approvedby alice, got %q (err: %v)", approvedBy, err)
+	}
+}
+
+func TestMigrator_RunStage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL, a script with its down script and a verify script it violates
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers+"\nINSERT INTO users (name, email) VALUES ('alice', '');")
+	repo.AddSQLScript(scriptsDir, "001_create_users.down.sql", "DROP TABLE users;")
+	repo.AddSQLScript(scriptsDir, "verify/001_emails.sql", "SELECT id FROM users WHERE email = '';")
+	repo.CommitScripts("Add users and verification")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir}
+
+	// 2. Without auto-revert, the failed verification leaves the batch applied
+	m := NewMigrator(cfg, wrapDB(testDB), console.New())
+	stage := m.RunStage(false)
+	if stage.Status != StageVerifyFailed || len(stage.Verify) != 1 || stage.Verify[0].Rows != 1 {
+		t.Fatalf("expected the verification to fail, got %+v", stage)
+	}
+	if stage.Report == nil || len(stage.Report.Scripts) != 1 {
+		t.Errorf("expected only 001_create_users.sql to run, got %+v", stage.Report)
+	}
+	if exists, _ := testDB.TableExists("users"); !exists {
+		t.Fatal("expected users table to stay")
+	}
+
+	// 3. Nothing is pending now, so there is no batch of this stage to revert
+	m = NewMigrator(cfg, wrapDB(testDB), console.New())
+	if stage := m.RunStage(true); stage.Status != StageVerifyFailed {
+		t.Errorf("expected an empty stage not to revert, got %+v", stage)
+	}
+
+	// 4. From scratch, auto-revert runs the down script
+	if err := testDB.ResetDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	m = NewMigrator(cfg, wrapDB(testDB), console.New())
+	if stage := m.RunStage(true); stage.Status != StageReverted {
+		t.Fatalf("expected the stage to be reverted, got %+v", stage)
+	}
+	if exists, _ := testDB.TableExists("users"); exists {
+		t.Error("expected users table to be dropped by the revert")
+	}
+}
+
+func TestMigrator_DuplicateScript(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and apply a script
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 2. A reformatted copy under a new name is refused
+	repo.AddSQLScript(scriptsDir, "005_users.sql", "-- copied\n"+strings.ToLower(testkit.SQLScripts.CreateUsers))
+	repo.CommitScripts("Add renamed copy")
+
+	err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run()
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("expected duplicate script error, got %v", err)
+	}
+
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("expected the copy not to run, got %d records", len(records))
+	}
+}
+
+func TestMigrator_SchemaSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a script that creates a table with rows
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+	repo.AddSQLScript(scriptsDir, "002_insert_users.sql", "INSERT INTO users (name, email) VALUES ('a', 'a@example.com');")
+	repo.CommitScripts("Add user rows")
+
+	snapshotDir := filepath.Join(t.TempDir(), "schema")
+	cfg := &config.Config{
+		Host:        testDB.Host,
+		User:        testDB.User,
+		Password:    testDB.Password,
+		DBName:      testDB.DBName,
+		Port:        mustParsePort(testDB.Port),
+		ScriptsDir:  scriptsDir,
+		SnapshotDir: snapshotDir,
+	}
+
+	// 2. Run migration
+	m := NewMigrator(cfg, wrapDB(testDB), console.New())
+	if err := m.Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 3. The batch snapshot has the new table, without counters or tracking tables
+	content, err := os.ReadFile(filepath.Join(snapshotDir, m.BatchID()+".sql"))
+	if err != nil {
+		t.Fatalf("expected batch snapshot: %v", err)
+	}
+	snapshot := string(content)
+	if !strings.Contains(snapshot, "CREATE TABLE `users`") {
+		t.Errorf("expected snapshot to contain users table, got:\n%s", snapshot)
+	}
+	if strings.Contains(snapshot, "AUTO_INCREMENT=") || strings.Contains(snapshot, ScriptTableName) {
+		t.Errorf("expected snapshot without counters or tracking table, got:\n%s", snapshot)
+	}
+
+	latest, err := os.ReadFile(filepath.Join(snapshotDir, "latest.sql"))
+	if err != nil {
+		t.Fatalf("expected latest snapshot: %v", err)
+	}
+	if !strings.HasSuffix(snapshot, string(latest)) {
+		t.Errorf("expected latest.sql to match the batch snapshot")
+	}
+}
+
+func TestMigrator_Diff(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL, snapshot its schema, then add a column by hand
+	testDB := testkit.SetupTestDB(t)
+	if err := testDB.Exec(testkit.SQLScripts.CreateUsers); err != nil {
+		t.Fatalf("failed to create users table: %v", err)
+	}
+	schema, err := DumpSchema(wrapDB(testDB))
+	if err != nil {
+		t.Fatalf("failed to dump schema: %v", err)
+	}
+	schemaFile := filepath.Join(t.TempDir(), "schema.sql")
+	if err := os.WriteFile(schemaFile, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema file: %v", err)
+	}
+	if err := testDB.Exec("ALTER TABLE users ADD COLUMN nickname VARCHAR(50)"); err != nil {
+		t.Fatalf("failed to add column: %v", err)
+	}
+
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+		DiffFrom:   schemaFile,
+	}
+
+	// 2. Generate the script
+	path, err := NewMigrator(cfg, wrapDB(testDB), console.New()).Diff("add nickname")
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+
+	// 3. The next numbered script only adds the column
+	if filepath.Base(path) != "002_add_nickname.sql" {
+		t.Errorf("unexpected script name %s", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read generated script: %v", err)
+	}
+	if !strings.Contains(string(content), "ALTER TABLE `users`\n  ADD COLUMN `nickname` varchar(50) DEFAULT NULL AFTER `created_at`;") {
+		t.Errorf("expected script to add nickname, got:\n%s", content)
+	}
+}
+
+func TestMigrator_Drift(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and migrate with schema snapshots
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	cfg := &config.Config{
+		Host:        testDB.Host,
+		User:        testDB.User,
+		Password:    testDB.Password,
+		DBName:      testDB.DBName,
+		Port:        mustParsePort(testDB.Port),
+		ScriptsDir:  scriptsDir,
+		SnapshotDir: filepath.Join(t.TempDir(), "schema"),
+	}
+	m := NewMigrator(cfg, wrapDB(testDB), console.New())
+	if err := m.Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 2. Right after the batch, the schema matches its snapshot
+	changes, err := m.Drift()
+	if err != nil {
+		t.Fatalf("drift failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no drift, got %v", changes)
+	}
+
+	// 3. Changes made by hand are reported
+	if err := testDB.Exec("ALTER TABLE users ADD COLUMN nickname VARCHAR(50), ADD INDEX idx_nickname (nickname)"); err != nil {
+		t.Fatalf("failed to alter users: %v", err)
+	}
+	if err := testDB.Exec("CREATE TABLE hotfix (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create hotfix: %v", err)
+	}
+	changes, err = NewMigrator(cfg, wrapDB(testDB), console.New()).Drift()
+	if err != nil {
+		t.Fatalf("drift failed: %v", err)
+	}
+	want := []DriftChange{
+		{"table", "hotfix", DriftAdded},
+		{"column", "users.nickname", DriftAdded},
+		{"index", "users.idx_nickname", DriftAdded},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("unexpected drift %v, want %v", changes, want)
+	}
+}
+
+func TestMigrator_Docs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and scripts with a foreign key
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+	repo.AddSQLScript(scriptsDir, "002_create_posts.sql", testkit.SQLScripts.CreatePosts)
+	repo.CommitScripts("Add posts")
+
+	docsFile := filepath.Join(t.TempDir(), "docs", "schema.md")
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+		DocsFile:   docsFile,
+	}
+
+	// 2. Run migration
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	// 3. The docs describe both tables and their relationship, not the tracking table
+	content, err := os.ReadFile(docsFile)
+	if err != nil {
+		t.Fatalf("expected docs file: %v", err)
+	}
+	docs := string(content)
+	for _, want := range []string{"## users", "## posts", "users ||--o{ posts"} {
+		if !strings.Contains(docs, want) {
+			t.Errorf("expected docs to contain %q, got:\n%s", want, docs)
+		}
+	}
+	if strings.Contains(docs, ScriptTableName) {
+		t.Errorf("expected docs without the tracking table")
+	}
+}
+
+func TestMigrator_Plan(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL with one applied script and two pending ones
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	repo.CommitScripts("Add users")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	repo.AddSQLScript(scriptsDir, "002_create_posts.sql", testkit.SQLScripts.CreatePosts)
+	repo.CommitScripts("Add posts")
+	repo.AddSQLScript(scriptsDir, "003_drop_posts.sql", "DROP TABLE posts;")
+	repo.CommitScripts("Drop posts")
+
+	// 2. Plan lists the pending scripts with their risk
+	plan, err := NewMigrator(cfg, wrapDB(testDB), console.New()).Plan()
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if len(plan.Errors) != 0 {
+		t.Errorf("expected no errors, got %v", plan.Errors)
+	}
+	if len(plan.Changes) != 2 || plan.Changes[0].Risk != RiskLow || plan.Changes[1].Risk != RiskHigh {
+		t.Errorf("unexpected changes %+v", plan.Changes)
+	}
+	if plan.Risk() != RiskHigh {
+		t.Errorf("expected high plan risk, got %s", plan.Risk())
+	}
+
+	// 3. Nothing was executed
+	if exists, err := testDB.TableExists("posts"); err != nil || exists {
+		t.Errorf("expected plan not to create posts (err: %v)", err)
+	}
+}
+
+func TestMigrator_LiquibaseChangelog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL, a plain script and a changelog with two changesets
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.AddSQLScript(scriptsDir, "001_create_users.sql", testkit.SQLScripts.CreateUsers)
+	changelog := `<databaseChangeLog>
+  <changeSet id="1" author="alice">
+    <createTable tableName="audit_log">
+      <column name="id" type="INT" autoIncrement="true"><constraints primaryKey="true"/></column>
+      <column name="message" type="VARCHAR(255)"/>
+    </createTable>
+  </changeSet>
+  <changeSet id="2" author="alice">
+    <sqlFile path="audit/seed.sql" relativeToChangelogFile="true"/>
+  </changeSet>
+</databaseChangeLog>
+`
+	repo.AddSQLScript(scriptsDir, "db.changelog.xml", changelog)
+	repo.AddSQLScript(scriptsDir, "audit/seed.sql", "INSERT INTO audit_log (message) VALUES ('created');")
+	repo.CommitScripts("Add users and changelog")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 2. Each changeset runs and is tracked under its Liquibase identity;
+	// the sqlFile is not run as a script of its own
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	records, err := testDB.GetTrackingRecords()
+	if err != nil {
+		t.Fatalf("failed to get tracking records: %v", err)
+	}
+	var names []string
+	for _, rec := range records {
+		names = append(names, rec.ScriptName)
+	}
+	want := []string{"001_create_users.sql", "db.changelog.xml::1::alice", "db.changelog.xml::2::alice"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("expected tracked scripts %v, got %v", want, names)
+	}
+	if count, _ := testDB.GetTableRowCount("audit_log"); count != 1 {
+		t.Errorf("expected 1 audit_log row, got %d", count)
+	}
+
+	// 3. Editing an applied changeset fails the next run
+	repo.AddSQLScript(scriptsDir, "audit/seed.sql", "INSERT INTO audit_log (message) VALUES ('changed');")
+	repo.CommitScripts("Edit applied changeset")
+	err = NewMigrator(cfg, wrapDB(testDB), console.New()).Run()
+	if err == nil || !strings.Contains(err.Error(), "modified changesets") {
+		t.Errorf("expected modified changeset error, got %v", err)
+	}
+}
+
+func TestMigrator_SeedLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL, a countries table and a seed script loading a CSV file
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	seedDir := repo.CreateScriptsDir("Seed_Data")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_countries.sql": "CREATE TABLE countries (code CHAR(2) PRIMARY KEY, name VARCHAR(100) NOT NULL, region VARCHAR(50) NULL);",
+		"Seed_Data/001_countries.sql":                       "-- dbmig: load table=countries file=data/countries.csv null=NULL\nDELETE FROM countries;\n",
+		"Seed_Data/data/countries.csv":                      "code,name,region\nDE,Germany,Europe\nCI,\"Côte d'Ivoire, Republic of\",NULL\n",
+	}, "Add countries")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+		SeedDir:    seedDir,
+	}
+
+	// 2. Both load methods produce the same rows
+	for _, noInfile := range []bool{false, true} {
+		cfg.NoLoadInfile = noInfile
+		if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+			t.Fatalf("migration failed (no-load-infile=%v): %v", noInfile, err)
+		}
+		var name string
+		var nullRegions int
+		if err := testDB.QueryRow("SELECT name FROM countries WHERE code = 'CI'").Scan(&name); err != nil || name != "Côte d'Ivoire, Republic of" {
+			t.Errorf("unexpected name %q (err: %v)", name, err)
+		}
+		if err := testDB.QueryRow("SELECT COUNT(*) FROM countries WHERE region IS NULL").Scan(&nullRegions); err != nil || nullRegions != 1 {
+			t.Errorf("expected 1 NULL region, got %d (err: %v)", nullRegions, err)
+		}
+
+		if noInfile {
+			break
+		}
+
+		// Changing only the CSV re-runs the seed
+		repo.ModifyFile("Seed_Data/data/countries.csv", "code,name,region\nDE,Germany,Europe\nCI,\"Côte d'Ivoire, Republic of\",NULL\nFR,France,Europe\n")
+		repo.CommitChanges("Add France")
+	}
+	if count, _ := testDB.GetTableRowCount("countries"); count != 3 {
+		t.Errorf("expected the changed CSV to be reloaded, got %d rows", count)
+	}
+}
+
+func TestMigrator_TicketLinkage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and scripts referencing tickets in their name and commit message
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_SHOP-1_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+	}, "Create users\n\nRefs SHOP-2")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/002_create_orders.sql": "CREATE TABLE orders (id INT PRIMARY KEY);",
+	}, "Create orders")
+
+	cfg := &config.Config{
+		Host:          testDB.Host,
+		User:          testDB.User,
+		Password:      testDB.Password,
+		DBName:        testDB.DBName,
+		Port:          mustParsePort(testDB.Port),
+		ScriptsDir:    scriptsDir,
+		TicketPattern: regexp.MustCompile(`SHOP-\d+`),
+	}
+
+	// 2. Tickets are recorded in the tracking table and the results
+	migrator := NewMigrator(cfg, wrapDB(testDB), console.New())
+	if err := migrator.Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	var tickets string
+	if err := testDB.QueryRow("SELECT COALESCE(tickets, '') FROM sqlScriptExec WHERE scriptName = '001_SHOP-1_create_users.sql'").Scan(&tickets); err != nil || tickets != "SHOP-1,SHOP-2" {
+		t.Errorf("expected tickets SHOP-1,SHOP-2, got %q (err: %v)", tickets, err)
+	}
+	results := migrator.Results()
+	if len(results) != 2 || len(results[1].Tickets) != 0 {
+		t.Errorf("expected no tickets for 002_create_orders.sql, got %+v", results)
+	}
+}
+
+func TestMigrator_LeastPrivilege(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a batch whose second script writes to a database the test user cannot
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+		"Automated_Change_Scripts/002_audit.sql":        "INSERT INTO mysql.migration_audit (id) VALUES (1);",
+	}, "Add scripts")
+
+	cfg := &config.Config{
+		Host:           testDB.Host,
+		User:           testDB.User,
+		Password:       testDB.Password,
+		DBName:         testDB.DBName,
+		Port:           mustParsePort(testDB.Port),
+		ScriptsDir:     scriptsDir,
+		LeastPrivilege: true,
+	}
+
+	// 2. The missing grant fails the run before any script executes
+	err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run()
+	if err == nil || !strings.Contains(err.Error(), "privileges are missing") {
+		t.Fatalf("expected a missing privilege error, got %v", err)
+	}
+	if count, err := testDB.GetTableRowCount("sqlScriptExec"); err != nil || count != 0 {
+		t.Errorf("expected no scripts to run, got %d tracking rows (err: %v)", count, err)
+	}
+
+	// 3. Without the script the user has every privilege it needs
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/002_audit.sql": "INSERT INTO users (id) VALUES (1);",
+	}, "Audit in the application database")
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+}
+
+func TestMigrator_SeparateTrackerConnection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a second pool standing in for the --tracker-user connection
+	testDB := testkit.SetupTestDB(t)
+	trackerDB, err := db.Connect(testDB.DSN, nil)
+	if err != nil {
+		t.Fatalf("failed to open tracker connection: %v", err)
+	}
+	defer trackerDB.Close()
+
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+		"Automated_Change_Scripts/002_insert_users.sql": "INSERT INTO users VALUES (1), (2);",
+	}, "Add scripts")
+
+	cfg := &config.Config{
+		Host:       testDB.Host,
+		User:       testDB.User,
+		Password:   testDB.Password,
+		DBName:     testDB.DBName,
+		Port:       mustParsePort(testDB.Port),
+		ScriptsDir: scriptsDir,
+	}
+
+	// 2. Scripts run and are recorded through their own connections
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New(), WithTrackerDB(trackerDB)).Run(); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if count, err := testDB.GetTableRowCount("users"); err != nil || count != 2 {
+		t.Errorf("expected 2 users, got %d (err: %v)", count, err)
+	}
+	var completed int
+	if err := trackerDB.QueryRow("SELECT COUNT(*) FROM sqlScriptExec WHERE completed = 1").Scan(&completed); err != nil || completed != 2 {
+		t.Errorf("expected 2 completed records, got %d (err: %v)", completed, err)
+	}
+
+	// 3. A failing script is recorded as incomplete and its statements are rolled back
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/003_broken.sql": "INSERT INTO users VALUES (3); INSERT INTO missing_table VALUES (1);",
+	}, "Add broken script")
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New(), WithTrackerDB(trackerDB)).Run(); err == nil {
+		t.Fatal("expected the broken script to fail")
+	}
+	if count, err := testDB.GetTableRowCount("users"); err != nil || count != 2 {
+		t.Errorf("expected the failed script to be rolled back, got %d users (err: %v)", count, err)
+	}
+}
+
+func TestMigrator_ReconnectsAtScriptBoundary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL behind a forwarder standing in for a connection that can be lost
+	testDB := testkit.SetupTestDB(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var forwarded []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", net.JoinHostPort(testDB.Host, testDB.Port))
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			mu.Lock()
+			forwarded = append(forwarded, conn, upstream)
+			mu.Unlock()
+			go io.Copy(upstream, conn)
+			go io.Copy(conn, upstream)
+		}
+	}()
+	forwardedDSN := strings.Replace(testDB.DSN, net.JoinHostPort(testDB.Host, testDB.Port), listener.Addr().String(), 1)
+	database, err := db.Connect(forwardedDSN, nil)
+	if err != nil {
+		t.Fatalf("failed to connect through the forwarder: %v", err)
+	}
+	defer database.Close()
+	reopened := 0
+	database.SetReconnect(func() (*db.DB, error) {
+		reopened++
+		return db.Connect(testDB.DSN, nil)
+	})
+
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir}
+	migrator := NewMigrator(cfg, database, console.New())
+
+	// 2. A live connection is left alone
+	if err := migrator.ensureConnection("001_first.sql"); err != nil || reopened != 0 {
+		t.Fatalf("expected no reconnect, got %d (err: %v)", reopened, err)
+	}
+
+	// 3. Once every connection is lost and new ones fail, the pool is reopened
+	listener.Close()
+	mu.Lock()
+	for _, conn := range forwarded {
+		conn.Close()
+	}
+	mu.Unlock()
+	if err := migrator.ensureConnection("002_second.sql"); err != nil {
+		t.Fatalf("expected a reconnect, got %v", err)
+	}
+	if reconnects := migrator.Reconnects(); reopened != 1 || len(reconnects) != 1 || reconnects[0].BeforeScript != "002_second.sql" {
+		t.Errorf("expected one reconnect before 002_second.sql, got %d: %+v", reopened, reconnects)
+	}
+	var one int
+	if err := database.QueryRow("SELECT 1").Scan(&one); err != nil {
+		t.Errorf("expected the reopened pool to work, got %v", err)
+	}
+}
+
+func TestMigrator_RunAsLeader(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a script slow enough for the replicas to overlap
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+		"Automated_Change_Scripts/002_insert_users.sql": "DO SLEEP(1); INSERT INTO users VALUES (1), (2);",
+	}, "Add scripts")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir}
+
+	// 2. Three replicas start together; each applies or waits, and all end current
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			errs <- NewMigrator(cfg, wrapDB(testDB), console.New()).RunAsLeader(context.Background())
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("replica failed: %v", err)
+		}
+	}
+	if count, err := testDB.GetTableRowCount("users"); err != nil || count != 2 {
+		t.Errorf("expected the scripts to run once, got %d users (err: %v)", count, err)
+	}
+	var executions int
+	if err := testDB.DB.QueryRow("SELECT COUNT(*) FROM sqlScriptExec").Scan(&executions); err != nil || executions != 2 {
+		t.Errorf("expected 2 tracking records, got %d (err: %v)", executions, err)
+	}
+
+	// 3. The lock is free again
+	lock, err := wrapDB(testDB).AcquireLock(context.Background(), LockName(testDB.DBName), 0)
+	if err != nil {
+		t.Fatalf("expected the lock to be released, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestMigrator_LockTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a pending script
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+	}, "Add users")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir, LockTimeout: time.Second}
+
+	// 2. Another run holds the lock for longer than the timeout
+	lock, err := wrapDB(testDB).AcquireLock(context.Background(), LockName(testDB.DBName), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewMigrator(cfg, wrapDB(testDB), console.New()).Run()
+	if err == nil || !strings.Contains(err.Error(), "migration lock") {
+		t.Fatalf("expected the run to give up waiting for the lock, got %v", err)
+	}
+	testDB.AssertNoTable("users")
+
+	// 3. Once it is released, the run proceeds
+	lock.Release()
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	testDB.AssertTableExists("users")
+}
+
+func TestMigrator_WatchAppliesNewCommits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a first commit
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+	}, "Add users")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir}
+	check := func(applied string) string {
+		commit, err := NewMigrator(cfg, wrapDB(testDB), console.New()).applyNewCommit(context.Background(), applied)
+		if err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+		return commit
+	}
+
+	// 2. The first check applies HEAD; an unchanged HEAD is left alone
+	first := check("")
+	if first != repo.GetCurrentCommit() {
+		t.Fatalf("expected HEAD to be applied, got %s", first)
+	}
+	if check(first) != first {
+		t.Error("expected an unchanged HEAD to be skipped")
+	}
+
+	// 3. A new commit is applied at the next check
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/002_insert_users.sql": "INSERT INTO users VALUES (1);",
+	}, "Add a user")
+	if check(first) == first {
+		t.Error("expected the new commit to be applied")
+	}
+	if count, err := testDB.GetTableRowCount("users"); err != nil || count != 1 {
+		t.Errorf("expected 1 user, got %d (err: %v)", count, err)
+	}
+
+	// 4. Checks are skipped while another run holds the lock
+	lock, err := wrapDB(testDB).AcquireLock(context.Background(), LockName(testDB.DBName), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/003_insert_more.sql": "INSERT INTO users VALUES (2);",
+	}, "Add another user")
+	if check(first) != first {
+		t.Error("expected the check to wait for the lock holder")
+	}
+}
+
+func TestMigrator_Check(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	// 1. Setup MySQL and a never migrated database
+	testDB := testkit.SetupTestDB(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("Automated_Change_Scripts")
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql": "CREATE TABLE users (id INT PRIMARY KEY);",
+	}, "Add users")
+	cfg := &config.Config{DBName: testDB.DBName, ScriptsDir: scriptsDir}
+	check := func() *CheckResult {
+		t.Helper()
+		result, err := NewMigrator(cfg, wrapDB(testDB), console.New()).Check()
+		if err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+		return result
+	}
+
+	if result := check(); result.Code() != CheckPending || len(result.Pending) != 1 {
+		t.Errorf("expected 001_create_users.sql pending, got %+v", result)
+	}
+	if exists, _ := NewTracker(wrapDB(testDB)).TableExists(context.Background()); exists {
+		t.Error("expected check not to create the tracking table")
+	}
+
+	// 2. Once applied, the database is current and matches its own snapshot
+	if err := NewMigrator(cfg, wrapDB(testDB), console.New()).Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	schema, err := DumpSchema(wrapDB(testDB), ScriptTableName, SeedTableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DiffFrom = filepath.Join(t.TempDir(), "latest.sql")
+	os.WriteFile(cfg.DiffFrom, []byte(schema), 0644)
+	if result := check(); result.Code() != 0 {
+		t.Errorf("expected the database to be current, got %+v", result)
+	}
+
+	// 3. A new script is pending, an applied one modified, and a manual change is drift
+	repo.CreateCommit(map[string]string{
+		"Automated_Change_Scripts/001_create_users.sql":  "CREATE TABLE users (id BIGINT PRIMARY KEY);",
+		"Automated_Change_Scripts/002_create_orders.sql": "CREATE TABLE orders (id INT PRIMARY KEY);",
+	}, "Modify users and add orders")
+	result := check()
+	if result.Code() != CheckModified || len(result.Modified) != 1 || len(result.Pending) != 1 {
+		t.Errorf("expected a modified and a pending script, got %+v", result)
+	}
+	if _, err := testDB.DB.Exec("ALTER TABLE users ADD COLUMN email VARCHAR(255)"); err != nil {
+		t.Fatal(err)
+	}
+	if result := check(); result.Code() != CheckDrift || len(result.Drift) == 0 {
+		t.Errorf("expected drift, got %+v", result)
+	}
+}
+
 // mustParsePort converts port string to int
 func mustParsePort(port string) int {
 	var result int
@@ -496,3 +2188,7 @@ func mustParsePort(port string) int {
 	return result
 }
 
+// wrapDB returns the connection of a test database for the migrator
+func wrapDB(td *testkit.TestDatabase) *db.DB {
+	return db.New(td.DB, td.Driver, nil)
+}
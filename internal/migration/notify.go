@@ -0,0 +1,157 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Events of a run posted to --notify-webhook and --notify-slack
+const (
+	NotifyStart   = "start"   // Pending scripts are about to execute
+	NotifySuccess = "success" // The run applied scripts
+	NotifyFailure = "failure" // The run failed, before or during its scripts
+)
+
+// maxNotifiedScripts is how many scripts a notification lists
+const maxNotifiedScripts = 20
+
+// notifyClient posts notifications; an unreachable chat must not hold up the run for long
+var notifyClient = &http.Client{Timeout: 30 * time.Second}
+
+// Notification is the JSON posted to --notify-webhook
+type Notification struct {
+	Event   string    `json:"event"`             // start, success or failure
+	Text    string    `json:"text"`              // Summary, as posted to Slack
+	Pending []string  `json:"pending,omitempty"` // start: the scripts about to execute
+	Report  RunReport `json:"report"`            // The run so far, in the shape of an audit log report
+}
+
+// notifyStart announces the pending scripts of a batch about to execute
+func (m *Migrator) notifyStart(pending []git.ScriptInfo) {
+	if !m.notifies() {
+		return
+	}
+	n := Notification{Event: NotifyStart, Report: m.Report(nil)}
+	for _, script := range pending {
+		n.Pending = append(n.Pending, script.Name)
+	}
+	n.Report.Status = "running"
+	n.Text = m.notificationText(n)
+	m.postNotification(n)
+}
+
+// notifyEnd reports the outcome of a run that executed scripts or failed. Runs with
+// nothing to do and dry runs stay quiet
+func (m *Migrator) notifyEnd(runErr error) {
+	if !m.notifies() || m.config.DryRun || (runErr == nil && len(m.results) == 0) {
+		return
+	}
+	n := Notification{Event: NotifySuccess, Report: m.Report(runErr)}
+	if runErr != nil {
+		n.Event = NotifyFailure
+	}
+	n.Text = m.notificationText(n)
+	m.postNotification(n)
+}
+
+func (m *Migrator) notifies() bool {
+	return m.config.NotifyWebhook != "" || m.config.NotifySlack != ""
+}
+
+// postNotification posts n to the webhook and Slack; failures are only warnings, so a chat
+// outage never fails a migration
+func (m *Migrator) postNotification(n Notification) {
+	if m.config.NotifyWebhook != "" {
+		if err := postJSON(m.config.NotifyWebhook, n); err != nil {
+			m.console.Warn("Could not post %s notification to webhook: %v", n.Event, err)
+		}
+	}
+	if m.config.NotifySlack != "" {
+		if err := postJSON(m.config.NotifySlack, map[string]string{"text": n.Text}); err != nil {
+			m.console.Warn("Could not post %s notification to Slack: %v", n.Event, err)
+		}
+	}
+}
+
+// notificationText summarizes a notification in Slack's mrkdwn: what ran where, then
+// the failing script and error, then the scripts with their outcome and duration
+func (m *Migrator) notificationText(n Notification) string {
+	r := n.Report
+	where := "`" + r.Database + "`"
+	if r.Host != "" {
+		where += " on " + r.Host
+	}
+	if r.Environment != "" {
+		where += " (" + r.Environment + ")"
+	}
+	batch := fmt.Sprintf("batch %s at commit %s", r.BatchID, shortCommit(r.Commit))
+
+	var b strings.Builder
+	switch n.Event {
+	case NotifyStart:
+		fmt.Fprintf(&b, "*Migration started:* %s\n%d scripts, %s\n", where, len(n.Pending), batch)
+		writeNotifiedScripts(&b, n.Pending, func(name string) string { return "`" + name + "`" })
+		return b.String()
+	case NotifySuccess:
+		fmt.Fprintf(&b, "*Migration succeeded:* %s\n%d scripts in %s, %s\n", where, len(r.Scripts), m.clock.Now().Sub(m.started).Round(time.Millisecond), batch)
+	default:
+		fmt.Fprintf(&b, "*Migration failed:* %s\n", where)
+		for _, s := range r.Scripts {
+			if s.Status == ResultFailed {
+				fmt.Fprintf(&b, "`%s` failed after %s, %s\n", s.Name, time.Duration(s.DurationMS)*time.Millisecond, batch)
+				break
+			}
+		}
+		fmt.Fprintf(&b, "```\n%s\n```\n", r.Error)
+	}
+
+	names := make([]string, len(r.Scripts))
+	byName := make(map[string]ReportScript, len(r.Scripts))
+	for i, s := range r.Scripts {
+		names[i], byName[s.Name] = s.Name, s
+	}
+	writeNotifiedScripts(&b, names, func(name string) string {
+		s := byName[name]
+		if s.Status == ResultNotRun || s.Status == ResultDeferred {
+			return fmt.Sprintf("`%s` %s", s.Name, s.Status)
+		}
+		return fmt.Sprintf("`%s` %s %s", s.Name, s.Status, time.Duration(s.DurationMS)*time.Millisecond)
+	})
+	return b.String()
+}
+
+// writeNotifiedScripts lists up to maxNotifiedScripts scripts as bullets
+func writeNotifiedScripts(b *strings.Builder, names []string, format func(string) string) {
+	for i, name := range names {
+		if i == maxNotifiedScripts {
+			fmt.Fprintf(b, "… and %d more\n", len(names)-i)
+			break
+		}
+		fmt.Fprintf(b, "• %s\n", format(name))
+	}
+}
+
+// postJSON posts v as JSON to target
+func postJSON(target string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := notifyClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRun_Notify(t *testing.T) {
+	var webhook []Notification
+	var slack []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webhook":
+			var n Notification
+			if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+				t.Errorf("invalid webhook body: %v", err)
+			}
+			webhook = append(webhook, n)
+		case "/slack":
+			var msg struct{ Text string }
+			if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+				t.Errorf("invalid Slack body: %v", err)
+			}
+			slack = append(slack, msg.Text)
+		default:
+			http.Error(w, "no such hook", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+	m.config.DBName = "app"
+	m.config.NotifyWebhook = server.URL + "/webhook"
+	m.config.NotifySlack = server.URL + "/slack"
+
+	// A run that applies scripts succeeds, and a run with nothing to do stays quiet
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	for range 2 {
+		if err := m.Run(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+	}
+	if len(webhook) != 2 || webhook[0].Event != NotifyStart || webhook[1].Event != NotifySuccess || webhook[1].Report.Status != "success" {
+		t.Fatalf("expected start and success, got %+v", webhook)
+	}
+	if len(slack) != 2 || !strings.Contains(slack[1], "*Migration succeeded:* `app`") || !strings.Contains(slack[1], "• `001_users.sql` success") {
+		t.Errorf("unexpected Slack messages: %q", slack)
+	}
+
+	webhook, slack = nil, nil
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLE;")
+	repo.AddSQLScript(scriptsDir, "003_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add scripts")
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if len(webhook) != 2 || webhook[0].Event != NotifyStart || webhook[1].Event != NotifyFailure {
+		t.Fatalf("expected start and failure, got %+v", webhook)
+	}
+	if want := []string{"002_broken.sql", "003_posts.sql"}; !reflect.DeepEqual(webhook[0].Pending, want) {
+		t.Errorf("expected pending %v, got %v", want, webhook[0].Pending)
+	}
+	failure := webhook[1].Report
+	if failure.Status != "failed" || failure.Error == "" || len(failure.Scripts) != 2 || failure.Scripts[0].Status != ResultFailed {
+		t.Errorf("unexpected failure report: %+v", failure)
+	}
+	if len(slack) != 2 {
+		t.Fatalf("expected 2 Slack messages, got %q", slack)
+	}
+	for _, want := range []string{"*Migration failed:* `app`", "`002_broken.sql` failed after", failure.Error, "• `003_posts.sql` not-run"} {
+		if !strings.Contains(slack[1], want) {
+			t.Errorf("expected %q in:\n%s", want, slack[1])
+		}
+	}
+
+	// An unreachable hook does not change the outcome of a run
+	m.config.NotifySlack = server.URL + "/gone"
+	err := m.Run()
+	if err == nil || !strings.Contains(err.Error(), "manual intervention required") {
+		t.Errorf("expected the failed batch to block the run, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// orderByDependencies sorts pending scripts so that every script runs after
+// the scripts named in its after directive, keeping commit order otherwise
+// A dependency must either be applied already or be pending in this batch
+func orderByDependencies(scripts []git.ScriptInfo, applied map[string]bool) ([]git.ScriptInfo, error) {
+	index := make(map[string]int, len(scripts))
+	for i, script := range scripts {
+		index[script.Name] = i
+	}
+
+	// Edges point from a dependency to the scripts waiting on it
+	dependents := make([][]int, len(scripts))
+	waiting := make([]int, len(scripts))
+	for i, script := range scripts {
+		for _, dep := range script.Directives.After {
+			j, pending := index[dep]
+			switch {
+			case pending && j == i:
+				return nil, fmt.Errorf("%s declares a dependency on itself", script.Name)
+			case pending:
+				dependents[j] = append(dependents[j], i)
+				waiting[i]++
+			case !applied[dep]:
+				return nil, fmt.Errorf("%s must run after %s, which is neither applied nor pending", script.Name, dep)
+			}
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest ready script in commit order
+	var ready []int
+	for i := range scripts {
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	ordered := make([]git.ScriptInfo, 0, len(scripts))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		next := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, scripts[next])
+		for _, d := range dependents[next] {
+			if waiting[d]--; waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(ordered) < len(scripts) {
+		var cycle []string
+		for i, script := range scripts {
+			if waiting[i] > 0 {
+				cycle = append(cycle, script.Name)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle between scripts: %s", strings.Join(cycle, ", "))
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// TestOrderByDependencies tests after= ordering, cycles and unknown dependencies
+func TestOrderByDependencies(t *testing.T) {
+	script := func(name string, after ...string) git.ScriptInfo {
+		return git.ScriptInfo{Name: name, Directives: directive.Set{After: after}}
+	}
+	names := func(scripts []git.ScriptInfo) string {
+		var out []string
+		for _, s := range scripts {
+			out = append(out, s.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	// Cherry-picked script committed before its dependency is moved after it
+	got, err := orderByDependencies([]git.ScriptInfo{
+		script("003_add_fk.sql", "002_create_orders.sql"),
+		script("001_create_users.sql"),
+		script("002_create_orders.sql", "001_create_users.sql"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("orderByDependencies failed: %v", err)
+	}
+	if want := "001_create_users.sql,002_create_orders.sql,003_add_fk.sql"; names(got) != want {
+		t.Errorf("got order %s, want %s", names(got), want)
+	}
+
+	// Applied dependencies are satisfied; commit order is kept otherwise
+	got, err = orderByDependencies([]git.ScriptInfo{
+		script("005_b.sql"),
+		script("004_a.sql", "001_create_users.sql"),
+	}, map[string]bool{"001_create_users.sql": true})
+	if err != nil {
+		t.Fatalf("orderByDependencies failed: %v", err)
+	}
+	if want := "005_b.sql,004_a.sql"; names(got) != want {
+		t.Errorf("got order %s, want %s", names(got), want)
+	}
+
+	if _, err := orderByDependencies([]git.ScriptInfo{script("004_a.sql", "999_missing.sql")}, nil); err == nil {
+		t.Error("expected error for unknown dependency")
+	}
+
+	_, err = orderByDependencies([]git.ScriptInfo{
+		script("004_a.sql", "005_b.sql"),
+		script("005_b.sql", "004_a.sql"),
+		script("006_c.sql"),
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "004_a.sql, 005_b.sql") {
+		t.Errorf("expected cycle error naming both scripts, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern matches ${NAME} and the $${NAME} escape for a literal ${NAME}
+var placeholderPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// SubstitutePlaceholders replaces ${NAME} placeholders with values from vars
+// Undefined placeholders are left untouched and returned; in strict mode they are an error
+func SubstitutePlaceholders(content string, vars map[string]string, strict bool) (string, []string, error) {
+	undefined := make(map[string]bool)
+
+	result := placeholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := match[2 : len(match)-1]
+		value, ok := vars[name]
+		if !ok {
+			undefined[name] = true
+			return match
+		}
+		return value
+	})
+
+	var missing []string
+	for name := range undefined {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+
+	if strict && len(missing) > 0 {
+		return "", missing, fmt.Errorf("undefined placeholders: %s", strings.Join(missing, ", "))
+	}
+
+	return result, missing, nil
+}
@@ -0,0 +1,29 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSubstitutePlaceholders tests ${NAME} substitution, escaping and strict mode
+func TestSubstitutePlaceholders(t *testing.T) {
+	vars := map[string]string{"SCHEMA_PREFIX": "acme_", "APP_USER": "app"}
+	content := "CREATE TABLE ${SCHEMA_PREFIX}users (id INT);\nGRANT SELECT ON ${SCHEMA_PREFIX}users TO '${APP_USER}';\n-- literal $${APP_USER} ${UNSET}"
+
+	got, missing, err := SubstitutePlaceholders(content, vars, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "CREATE TABLE acme_users (id INT);\nGRANT SELECT ON acme_users TO 'app';\n-- literal ${APP_USER} ${UNSET}"
+	if got != want {
+		t.Errorf("unexpected result:\n%s\nwant:\n%s", got, want)
+	}
+	if len(missing) != 1 || missing[0] != "UNSET" {
+		t.Errorf("expected UNSET to be reported missing, got %v", missing)
+	}
+
+	_, _, err = SubstitutePlaceholders(content, vars, true)
+	if err == nil || !strings.Contains(err.Error(), "UNSET") {
+		t.Errorf("strict mode should fail on UNSET, got %v", err)
+	}
+}
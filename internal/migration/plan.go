@@ -0,0 +1,309 @@
+package migration
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Risk levels of planned changes, from least to most severe
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// riskRank orders risk levels
+var riskRank = map[string]int{RiskLow: 0, RiskMedium: 1, RiskHigh: 2}
+
+// Plan describes what the next up would do, without doing it
+type Plan struct {
+	Database   string          `json:"database"`
+	FromCommit string          `json:"from_commit"`
+	ToCommit   string          `json:"to_commit"`
+	Changes    []PlannedChange `json:"changes"`
+	Errors     []string        `json:"errors"` // Problems that would stop the run
+}
+
+// PlannedChange is one script the next up would execute
+type PlannedChange struct {
+	Script  string   `json:"script"`
+	Action  string   `json:"action"` // create (first run) or update (changed repeatable)
+	Risk    string   `json:"risk"`
+	Reasons []string `json:"reasons"`
+
+	content  []byte // Rendered SQL, for --format sql
+	checksum string // Checksum recorded when it runs
+}
+
+// Risk returns the highest risk of the planned changes
+func (p *Plan) Risk() string {
+	risk := RiskLow
+	for _, c := range p.Changes {
+		if riskRank[c.Risk] > riskRank[risk] {
+			risk = c.Risk
+		}
+	}
+	return risk
+}
+
+// Count returns the number of planned changes with the given action
+func (p *Plan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Plan discovers and checks the scripts the next up would execute, and rates the risk of each
+// Checks that would stop the run are collected in Plan.Errors instead of failing the plan
+func (m *Migrator) Plan() (*Plan, error) {
+	m.console.Header("DB Migration Plan")
+	return m.plan()
+}
+
+// Validate runs the checks of Plan and returns the problems that would stop the next up,
+// without executing anything
+func (m *Migrator) Validate() ([]string, error) {
+	m.console.Header("DB Migration Validation")
+	plan, err := m.plan()
+	if err != nil {
+		return nil, err
+	}
+	return plan.Errors, nil
+}
+
+// plan builds the Plan of the pending scripts
+func (m *Migrator) plan() (*Plan, error) {
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return nil, err
+	}
+	if err := m.prepareTracker(true); err != nil {
+		return nil, err
+	}
+
+	lastGitID, err := m.tracker.GetLastSuccessfulCommit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last successful commit: %w", err)
+	}
+	executedScripts, err := m.tracker.GetExecutedScriptNames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executed scripts: %w", err)
+	}
+	currentCommit, err := m.currentCommit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current commit: %w", err)
+	}
+
+	plan := &Plan{Database: m.config.DBName, FromCommit: lastGitID, ToCommit: currentCommit, Changes: []PlannedChange{}, Errors: []string{}}
+	fail := func(err error) {
+		if err != nil {
+			plan.Errors = append(plan.Errors, err.Error())
+		}
+	}
+
+	if err := m.validator.CheckFileModifications(lastGitID, currentCommit, executedScripts); err != nil {
+		fail(err)
+	} else {
+		fail(m.verifyChecksums())
+	}
+	halfCommitted, err := m.tracker.GetHalfCommittedScripts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get half-committed scripts: %w", err)
+	}
+	fail(m.validator.CheckHalfCommittedFiles(halfCommitted))
+
+	pending, _, _, err := m.discoverPending(lastGitID, currentCommit, executedScripts)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.loadDirectives(pending); err != nil {
+		return nil, err
+	}
+	if err := m.lintScripts(pending); err != nil {
+		return nil, err
+	}
+	fail(m.checkBudgets(pending))
+	fail(m.checkDuplicates(pending))
+
+	ordered, err := orderByDependencies(pending, executedScripts)
+	if err != nil {
+		fail(fmt.Errorf("invalid script dependencies: %w", err))
+	} else {
+		pending = ordered
+	}
+
+	plan.Changes, err = m.plannedChanges(pending, executedScripts)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// plannedChanges rates the risk of each pending script
+func (m *Migrator) plannedChanges(pending []git.ScriptInfo, executedScripts map[string]bool) ([]PlannedChange, error) {
+	changes := []PlannedChange{}
+	for _, script := range pending {
+		content, err := m.readScript(script)
+		if err != nil {
+			return nil, err
+		}
+		checksum, err := m.scriptChecksum(script, content)
+		if err != nil {
+			return nil, err
+		}
+		change := PlannedChange{Script: script.Name, Action: "create", content: content, checksum: checksum}
+		if git.IsRepeatable(script.Name) && executedScripts[script.Name] {
+			change.Action = "update"
+		}
+		change.Risk, change.Reasons = assessRisk(script, string(content))
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// awaitApproval asks the approval func of WithApproval whether the pending scripts
+// may run, and keeps the approver for their tracking records
+func (m *Migrator) awaitApproval(pending []git.ScriptInfo, executedScripts map[string]bool, lastGitID, currentCommit string) error {
+	if m.approve == nil {
+		return nil
+	}
+	changes, err := m.plannedChanges(pending, executedScripts)
+	if err != nil {
+		return err
+	}
+	plan := &Plan{Database: m.config.DBName, FromCommit: lastGitID, ToCommit: currentCommit, Changes: changes, Errors: []string{}}
+	m.console.Info("Waiting for approval of %d scripts (risk: %s)...", len(changes), plan.Risk())
+	approver, err := m.approve(plan)
+	if err != nil {
+		return fmt.Errorf("apply was not approved: %w", err)
+	}
+	m.approvedBy = approver
+	m.console.Success("Approved by %s", approver)
+	return nil
+}
+
+// assessRisk rates a script by its most dangerous statement
+// high: removes data; medium: changes existing tables or rows; low: only adds
+func assessRisk(script git.ScriptInfo, content string) (string, []string) {
+	risk := RiskLow
+	reasons := []string{}
+	raise := func(level, reason string) {
+		if riskRank[level] > riskRank[risk] {
+			risk = level
+		}
+		if !containsName(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if script.Directives.Backfill != nil {
+		raise(RiskMedium, "backfill updates existing rows")
+	}
+
+	for _, stmt := range splitStatements(content) {
+		if reason := destructiveReason(stmt); reason != "" {
+			raise(RiskHigh, reason)
+			continue
+		}
+		words := strings.Fields(strings.ToUpper(stmt))
+		if len(words) < 2 {
+			continue
+		}
+		switch {
+		case words[0] == "ALTER" && words[1] == "TABLE":
+			raise(RiskMedium, "ALTER TABLE may lock or rebuild the table")
+		case words[0] == "RENAME":
+			raise(RiskMedium, "RENAME breaks clients using the old name")
+		case words[0] == "DROP":
+			raise(RiskMedium, fmt.Sprintf("DROP %s removes an existing object", words[1]))
+		case words[0] == "CREATE" && containsName(words[:min(len(words), 4)], "INDEX"):
+			raise(RiskMedium, "CREATE INDEX may lock the table")
+		case words[0] == "UPDATE" || words[0] == "DELETE":
+			raise(RiskMedium, words[0]+" changes existing rows")
+		}
+	}
+	return risk, reasons
+}
+
+// WritePlan writes a plan in the given format (text, tfplan-json or sql)
+func WritePlan(w io.Writer, plan *Plan, format string) error {
+	switch format {
+	case config.PlanTFJSON:
+		return writeTFPlan(w, plan)
+	case config.PlanSQL:
+		return writeSQLPlan(w, plan)
+	}
+
+	fmt.Fprintf(w, "Plan for %s: %d to create, %d to update, risk %s\n", plan.Database, plan.Count("create"), plan.Count("update"), plan.Risk())
+	for _, c := range plan.Changes {
+		fmt.Fprintf(w, "  %-7s %-6s %s\n", c.Action, c.Risk, c.Script)
+		for _, reason := range c.Reasons {
+			fmt.Fprintf(w, "                   - %s\n", reason)
+		}
+	}
+	for _, e := range plan.Errors {
+		fmt.Fprintf(w, "  error: %s\n", e)
+	}
+	return nil
+}
+
+// writeTFPlan writes the plan as a flat JSON object of strings, the result shape the
+// Terraform/OpenTofu external data source requires; the full plan is JSON-encoded under "plan"
+func writeTFPlan(w io.Writer, plan *Plan) error {
+	full, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	result := map[string]string{
+		"database":    plan.Database,
+		"from_commit": plan.FromCommit,
+		"to_commit":   plan.ToCommit,
+		"has_changes": strconv.FormatBool(len(plan.Changes) > 0),
+		"create":      strconv.Itoa(plan.Count("create")),
+		"update":      strconv.Itoa(plan.Count("update")),
+		"risk":        plan.Risk(),
+		"errors":      strconv.Itoa(len(plan.Errors)),
+		"plan":        string(full),
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// writeSQLPlan writes the plan as one SQL file for DBAs to review and run by hand: the
+// rendered scripts in execution order, each between begin and end markers naming its
+// checksum, under a header naming the commit. mark-applied reads the markers back
+func writeSQLPlan(w io.Writer, plan *Plan) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "-- db-migration plan for %s: %d to create, %d to update, risk %s\n", plan.Database, plan.Count("create"), plan.Count("update"), plan.Risk())
+	fmt.Fprintf(bw, "%s %s\n", markerCommit, plan.ToCommit)
+	if plan.FromCommit != "" {
+		fmt.Fprintf(bw, "-- Applied up to commit %s\n", plan.FromCommit)
+	}
+	for _, e := range plan.Errors {
+		fmt.Fprintf(bw, "-- error: %s\n", e)
+	}
+	fmt.Fprintln(bw, "-- Run the scripts in order, then record them with: db-migration mark-applied <this file> ...")
+	for _, c := range plan.Changes {
+		fmt.Fprintf(bw, "\n%s %s action=%s risk=%s checksum=%s\n", markerBegin, c.Script, c.Action, c.Risk, c.checksum)
+		for _, reason := range c.Reasons {
+			fmt.Fprintf(bw, "-- %s\n", reason)
+		}
+		bw.Write(c.content)
+		if len(c.content) > 0 && c.content[len(c.content)-1] != '\n' {
+			bw.WriteByte('\n')
+		}
+		fmt.Fprintf(bw, "%s %s\n", markerEnd, c.Script)
+	}
+	return bw.Flush()
+}
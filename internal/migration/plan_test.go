@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+func TestAssessRisk(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  git.ScriptInfo
+		content string
+		want    string
+	}{
+		{"create only", git.ScriptInfo{}, "CREATE TABLE users (id INT); INSERT INTO users VALUES (1);", RiskLow},
+		{"alter", git.ScriptInfo{}, "CREATE TABLE a (id INT); ALTER TABLE users ADD COLUMN email TEXT;", RiskMedium},
+		{"index", git.ScriptInfo{}, "CREATE UNIQUE INDEX uq_email ON users (email);", RiskMedium},
+		{"drop view", git.ScriptInfo{}, "DROP VIEW active_users;", RiskMedium},
+		{"bounded delete", git.ScriptInfo{}, "DELETE FROM sessions WHERE id < 10;", RiskMedium},
+		{"backfill", git.ScriptInfo{Directives: directive.Set{Backfill: &directive.Backfill{Table: "orders", Column: "status"}}}, "'open'", RiskMedium},
+		{"drop table", git.ScriptInfo{}, "ALTER TABLE users ADD COLUMN x INT; DROP TABLE legacy;", RiskHigh},
+	}
+
+	for _, tt := range tests {
+		if got, reasons := assessRisk(tt.script, tt.content); got != tt.want {
+			t.Errorf("%s: risk = %s (%v), want %s", tt.name, got, reasons, tt.want)
+		}
+	}
+}
+
+func TestWritePlanTFJSON(t *testing.T) {
+	plan := &Plan{
+		Database: "shop",
+		ToCommit: "abc123",
+		Changes: []PlannedChange{
+			{Script: "001_create_users.sql", Action: "create", Risk: RiskLow, Reasons: []string{}},
+			{Script: "R__views.sql", Action: "update", Risk: RiskMedium, Reasons: []string{"DROP VIEW removes an existing object"}},
+		},
+		Errors: []string{},
+	}
+
+	var out strings.Builder
+	if err := WritePlan(&out, plan, config.PlanTFJSON); err != nil {
+		t.Fatalf("WritePlan failed: %v", err)
+	}
+
+	// The external data source only accepts string values
+	var result map[string]string
+	if err := json.Unmarshal([]byte(out.String()), &result); err != nil {
+		t.Fatalf("expected a flat object of strings: %v\n%s", err, out.String())
+	}
+	if result["create"] != "1" || result["update"] != "1" || result["risk"] != RiskMedium || result["has_changes"] != "true" {
+		t.Errorf("unexpected summary %v", result)
+	}
+
+	var full Plan
+	if err := json.Unmarshal([]byte(result["plan"]), &full); err != nil {
+		t.Fatalf("expected the full plan as JSON: %v", err)
+	}
+	if len(full.Changes) != 2 || full.Changes[1].Reasons[0] != "DROP VIEW removes an existing object" {
+		t.Errorf("unexpected full plan %+v", full)
+	}
+}
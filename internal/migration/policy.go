@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// retryDelay is the pause before each retry of a script with onError=retry:N
+var retryDelay = time.Second
+
+// executeWithPolicy executes a script honoring its onError directive:
+// retry:N re-executes up to N more times, continue tolerates the failure.
+// Every failed attempt is recorded. tolerated is true when the script failed
+// but the batch should go on.
+func (m *Migrator) executeWithPolicy(t *Tracker, script git.ScriptInfo, rec ScriptRecord) (tolerated bool, err error) {
+	attempts := 1
+	if script.Directives.OnError == directive.OnErrorRetry {
+		attempts += script.Directives.Retries
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			m.console.Warn("Retrying %s (attempt %d/%d)", script.Name, attempt, attempts)
+			time.Sleep(retryDelay)
+		}
+
+		err = m.executeScript(t, script, rec)
+		if err == nil {
+			return false, nil
+		}
+		// An interrupted run neither retries nor tolerates the script
+		if m.ctx.Err() != nil {
+			return false, err
+		}
+	}
+
+	if script.Directives.OnError == directive.OnErrorContinue {
+		m.console.Warn("%s failed but onError=continue, going on: %v", script.Name, err)
+		return true, nil
+	}
+
+	return false, err
+}
+
+// failureRecord returns the tracking row for an attempt that failed with err
+// A tolerated failure (onError=continue) or a failed rerun may still close the batch,
+// since neither leaves a batch half-applied
+func failureRecord(rec ScriptRecord, directives directive.Set, err error) ScriptRecord {
+	failed := rec
+	failed.Completed = false
+	failed.EndOfBatch = rec.EndOfBatch && (directives.OnError == directive.OnErrorContinue || rec.Action == ActionRerun)
+	failed.Failure = FailureError
+	failed.ErrorMessage = err.Error()
+	switch {
+	case errors.Is(err, db.ErrTimeout):
+		failed.Failure = FailureTimeout
+	case errors.Is(err, db.ErrInterrupted), errors.Is(err, context.Canceled):
+		failed.Failure = FailureCancelled
+	}
+	return failed
+}
+
+// scriptContext bounds the statements of a script by its timeout directive and the
+// context of the migrator
+func (m *Migrator) scriptContext(directives directive.Set) (context.Context, context.CancelFunc) {
+	if directives.Timeout > 0 {
+		return context.WithTimeout(m.ctx, directives.Timeout)
+	}
+	return context.WithCancel(m.ctx)
+}
@@ -0,0 +1,329 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// grantPattern matches a SHOW GRANTS row granting or (partially) revoking privileges
+// on a global, database or table level; role grants and routine-level grants do not match
+var grantPattern = regexp.MustCompile("(?is)^(GRANT|REVOKE)\\s+(.+?)\\s+ON\\s+(?:TABLE\\s+)?(\\*|`[^`]+`|\\w+)\\.(\\*|`[^`]+`|\\w+)\\s+(?:TO|FROM)\\s")
+
+// Privilege is a MySQL privilege needed on a database (Table empty) or table
+type Privilege struct {
+	Name     string
+	Database string
+	Table    string
+}
+
+func (p Privilege) String() string {
+	table := "*"
+	if p.Table != "" {
+		table = "`" + p.Table + "`"
+	}
+	return fmt.Sprintf("%s ON `%s`.%s", p.Name, p.Database, table)
+}
+
+// ScriptPrivileges returns the privileges the statements of a script need, once each,
+// following the MySQL reference manual for each statement kind
+// Like ScriptObjects this is a pattern match: bodies of routines and triggers run with
+// their definer's privileges and are not inspected
+func ScriptPrivileges(content, database string) []Privilege {
+	var privileges []Privilege
+	seen := make(map[Privilege]bool)
+	need := func(name string, o ObjectRef, tableLevel bool) {
+		p := Privilege{Name: name, Database: o.Database}
+		if tableLevel {
+			p.Table = o.Name
+		}
+		if !seen[p] {
+			seen[p] = true
+			privileges = append(privileges, p)
+		}
+	}
+
+	for _, stmt := range splitStatements(content) {
+		blank := blankLiterals(stmt)
+		objs := parseStatementObjects(stmt, database)
+
+		if len(objs.writes) == 1 && objs.writes[0].Operation == OpCreate {
+			switch o := objs.writes[0]; o.Type {
+			case "trigger":
+				if t := triggerTablePattern.FindStringSubmatch(blank); t != nil {
+					need("TRIGGER", newObjectRef("table", t[1], OpCreate, database), true)
+				}
+				continue
+			case "event":
+				need("EVENT", o, false)
+				continue
+			case "procedure", "function":
+				need("CREATE ROUTINE", o, false)
+				continue
+			}
+		}
+		if createIndexPattern.MatchString(blank) || dropIndexPattern.MatchString(blank) {
+			need("INDEX", objs.writes[0], true)
+			continue
+		}
+
+		for _, o := range objs.writes {
+			switch {
+			case o.Operation == OpRename:
+				// Handled per pair below
+			case o.Operation == OpCreate && o.Type == "view":
+				need("CREATE VIEW", o, true)
+			case o.Operation == OpCreate:
+				need("CREATE", o, true)
+			case o.Operation == OpAlter && o.Type == "view":
+				need("CREATE VIEW", o, true)
+				need("DROP", o, true)
+			case o.Operation == OpAlter:
+				need("ALTER", o, true)
+				need("CREATE", o, true)
+				need("INSERT", o, true)
+			case o.Operation == OpDrop && (o.Type == "procedure" || o.Type == "function"):
+				need("ALTER ROUTINE", o, false)
+			case o.Operation == OpDrop && o.Type == "event":
+				need("EVENT", o, false)
+			case o.Operation == OpDrop && o.Type == "trigger":
+				// Needs TRIGGER on a table the statement does not name
+			case o.Operation == OpDrop || o.Operation == OpTruncate:
+				need("DROP", o, true)
+			case o.Operation == OpInsert:
+				need("INSERT", o, true)
+			case o.Operation == OpUpdate:
+				need("UPDATE", o, true)
+			case o.Operation == OpDelete:
+				need("DELETE", o, true)
+			}
+		}
+		for _, pair := range objs.renames {
+			need("ALTER", pair[0], true)
+			need("DROP", pair[0], true)
+			need("CREATE", pair[1], true)
+			need("INSERT", pair[1], true)
+		}
+
+		// Tables named only by a foreign key need REFERENCES rather than SELECT
+		selected := make(map[ObjectRef]bool)
+		for _, m := range readPattern.FindAllStringSubmatch(blank, -1) {
+			selected[newObjectRef("table", m[1], OpRead, database)] = true
+		}
+		for _, o := range objs.reads {
+			if selected[o] {
+				need("SELECT", o, true)
+			} else {
+				need("REFERENCES", o, true)
+			}
+		}
+	}
+
+	if directives, err := directive.Parse([]byte(content)); err == nil {
+		for _, l := range directives.Loads {
+			need("INSERT", newObjectRef("table", l.Table, OpInsert, database), true)
+		}
+	}
+	return privileges
+}
+
+// grant is one GRANT or partial REVOKE row of SHOW GRANTS
+type grant struct {
+	revoke     bool
+	privileges []string
+	database   string // "*" for global grants; may contain % and _ wildcards
+	table      string // "*" for global and database grants
+}
+
+// Grants are the privileges of the connected user, as listed by SHOW GRANTS
+type Grants struct {
+	grants []grant
+}
+
+// ParseGrants parses SHOW GRANTS rows; column-level, routine-level and role grants are ignored
+func ParseGrants(rows []string) *Grants {
+	g := &Grants{}
+	for _, row := range rows {
+		m := grantPattern.FindStringSubmatch(row)
+		if m == nil {
+			continue
+		}
+		entry := grant{revoke: strings.EqualFold(m[1], "REVOKE"), database: unquote(m[3]), table: unquote(m[4])}
+		depth, start := 0, 0
+		list := m[2] + ","
+		for i, c := range list {
+			switch c {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth > 0 {
+					continue
+				}
+				name := strings.ToUpper(strings.Join(strings.Fields(list[start:i]), " "))
+				start = i + 1
+				if name == "ALL" {
+					name = "ALL PRIVILEGES"
+				}
+				if name != "" && !strings.Contains(name, "(") {
+					entry.privileges = append(entry.privileges, name)
+				}
+			}
+		}
+		g.grants = append(g.grants, entry)
+	}
+	return g
+}
+
+// Allows reports whether a grant covers p and no partial revoke removes it
+func (g *Grants) Allows(p Privilege) bool {
+	allowed := false
+	for _, entry := range g.grants {
+		if !entry.covers(p) {
+			continue
+		}
+		if entry.revoke {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
+
+func (e grant) covers(p Privilege) bool {
+	if !containsName(e.privileges, p.Name) && !containsName(e.privileges, "ALL PRIVILEGES") {
+		return false
+	}
+	switch {
+	case e.database == "*":
+		return true
+	case !matchDatabasePattern(e.database, p.Database):
+		return false
+	case e.table == "*":
+		return true
+	default:
+		return p.Table != "" && strings.EqualFold(e.table, p.Table)
+	}
+}
+
+// matchDatabasePattern matches a database name against a grant's name, in which
+// unescaped % and _ are wildcards
+func matchDatabasePattern(pattern, name string) bool {
+	var expr strings.Builder
+	expr.WriteString("(?i)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '%':
+			expr.WriteString(".*")
+		case c == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	return err == nil && re.MatchString(name)
+}
+
+// currentGrants reads SHOW GRANTS for the connected user, expanded with the privileges
+// of its active roles
+func (m *Migrator) currentGrants() (string, *Grants, error) {
+	var user string
+	if err := m.db.QueryRow("SELECT CURRENT_USER()").Scan(&user); err != nil {
+		return "", nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	query := "SHOW GRANTS"
+	var roles sql.NullString
+	if m.db.QueryRow("SELECT CURRENT_ROLE()").Scan(&roles) == nil && roles.Valid && roles.String != "" && roles.String != "NONE" {
+		query = "SHOW GRANTS FOR CURRENT_USER() USING " + roles.String
+	}
+	rows, err := m.db.Query(query)
+	if err != nil && query != "SHOW GRANTS" {
+		// MariaDB lists the active role without USING
+		rows, err = m.db.Query("SHOW GRANTS")
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to show grants: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", nil, fmt.Errorf("failed to read grants: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, fmt.Errorf("failed to read grants: %w", err)
+	}
+	return user, ParseGrants(lines), nil
+}
+
+// checkPrivileges fails before anything runs when the connected user lacks a privilege
+// the pending scripts or the tracking table writes need (--least-privilege)
+func (m *Migrator) checkPrivileges(scripts []git.ScriptInfo) error {
+	if !m.config.LeastPrivilege {
+		return nil
+	}
+	m.console.Info("Checking privileges...")
+	user, grants, err := m.currentGrants()
+	if err != nil {
+		return err
+	}
+
+	neededBy := make(map[Privilege][]string)
+	var order []Privilege
+	require := func(p Privilege, by string, path string) {
+		if grants.Allows(p) {
+			return
+		}
+		if _, ok := neededBy[p]; !ok {
+			order = append(order, p)
+		}
+		neededBy[p] = append(neededBy[p], by)
+		if path != "" {
+			m.validator.report(Finding{RuleID: RuleMissingPrivilege, Level: "error", Path: path,
+				Message: fmt.Sprintf("%s lacks %s", user, p)})
+		}
+	}
+
+	// With --tracker-user, the executing user never touches the tracking table
+	if m.tracker.db == m.db {
+		for _, name := range []string{"SELECT", "INSERT"} {
+			require(Privilege{Name: name, Database: m.config.DBName, Table: m.tracker.tableName}, "tracking table", "")
+		}
+	}
+	for _, script := range scripts {
+		content, err := m.readScript(script)
+		if err != nil {
+			return err
+		}
+		for _, p := range ScriptPrivileges(string(content), m.config.DBName) {
+			require(p, script.Name, script.Path)
+		}
+	}
+	if len(order) == 0 {
+		m.console.Success("%s has every privilege the run needs", user)
+		return nil
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return order[i].Database+order[i].Table < order[j].Database+order[j].Table })
+	m.console.Error("%s is missing privileges the run needs:", user)
+	for _, p := range order {
+		m.console.Failure("  - %s (%s)", p, strings.Join(neededBy[p], ", "))
+	}
+	return fmt.Errorf("%d privileges are missing - grant them before running the migration", len(order))
+}
@@ -0,0 +1,76 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptPrivileges(t *testing.T) {
+	content := `-- dbmig: load table=countries file=data/countries.csv
+CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, FOREIGN KEY (user_id) REFERENCES users(id));
+CREATE INDEX idx_orders_user ON orders (user_id);
+ALTER TABLE users ADD COLUMN note VARCHAR(255) DEFAULT 'from orders';
+INSERT INTO audit.log (msg) SELECT name FROM users;
+RENAME TABLE legacy TO legacy_old;
+CREATE TRIGGER orders_ai AFTER INSERT ON orders FOR EACH ROW INSERT INTO stats VALUES (1);
+CREATE PROCEDURE cleanup() DELETE FROM sessions;
+TRUNCATE TABLE sessions;`
+
+	var got []string
+	for _, p := range ScriptPrivileges(content, "app") {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"CREATE ON `app`.`orders`",
+		"REFERENCES ON `app`.`users`",
+		"INDEX ON `app`.`orders`",
+		"ALTER ON `app`.`users`",
+		"CREATE ON `app`.`users`",
+		"INSERT ON `app`.`users`",
+		"INSERT ON `audit`.`log`",
+		"SELECT ON `app`.`users`",
+		"ALTER ON `app`.`legacy`",
+		"DROP ON `app`.`legacy`",
+		"CREATE ON `app`.`legacy_old`",
+		"INSERT ON `app`.`legacy_old`",
+		"TRIGGER ON `app`.`orders`",
+		"CREATE ROUTINE ON `app`.*",
+		"DROP ON `app`.`sessions`",
+		"INSERT ON `app`.`countries`",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected privileges:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGrantsAllows(t *testing.T) {
+	grants := ParseGrants([]string{
+		"GRANT USAGE ON *.* TO `migrator`@`%`",
+		"GRANT SELECT, INSERT, UPDATE (note), CREATE ON `app`.* TO `migrator`@`%`",
+		"GRANT ALL PRIVILEGES ON `tenant\\_%`.* TO `migrator`@`%`",
+		"GRANT DROP ON `app`.`sessions` TO `migrator`@`%`",
+		"GRANT `deployer`@`%` TO `migrator`@`%`",
+		"REVOKE INSERT ON `tenant_archive`.* FROM `migrator`@`%`",
+	})
+
+	tests := []struct {
+		p    Privilege
+		want bool
+	}{
+		{Privilege{"SELECT", "app", "users"}, true},
+		{Privilege{"CREATE", "app", ""}, true},
+		{Privilege{"UPDATE", "app", "users"}, false}, // Column-level only
+		{Privilege{"DROP", "app", "sessions"}, true},
+		{Privilege{"DROP", "app", "users"}, false},
+		{Privilege{"ALTER", "tenant_1", "orders"}, true},
+		{Privilege{"ALTER", "tenantx1", "orders"}, false}, // \_ is a literal underscore
+		{Privilege{"INSERT", "tenant_archive", "orders"}, false},
+		{Privilege{"SELECT", "tenant_archive", "orders"}, true},
+		{Privilege{"SELECT", "other", "users"}, false},
+	}
+	for _, tt := range tests {
+		if got := grants.Allows(tt.p); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package migration
+
+import "time"
+
+// ProgressExecuting is the status of a script that started; finished scripts report
+// their Result* outcome
+const ProgressExecuting = "executing"
+
+// ScriptProgress reports a pending script of a run starting or finishing
+type ScriptProgress struct {
+	BatchID  string
+	Script   string
+	Status   string // ProgressExecuting or a Result* outcome
+	Index    int    // Position among the pending scripts, from 1
+	Total    int    // Number of pending scripts
+	Duration time.Duration
+	Error    string
+}
+
+// reportProgress passes p to the WithProgress callback; the results recorded so far
+// give its position
+func (m *Migrator) reportProgress(p ScriptProgress) {
+	if m.progress == nil {
+		return
+	}
+	p.BatchID, p.Total = m.batchID, m.pendingTotal
+	p.Index = len(m.results)
+	if p.Status == ProgressExecuting {
+		p.Index++
+	}
+	m.progress(p)
+}
@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// pingTimeout bounds the connection check at each script boundary
+const pingTimeout = 10 * time.Second
+
+// Reconnect records a connection lost during a run and reopened with refreshed
+// credentials before a script
+type Reconnect struct {
+	At           time.Time
+	BeforeScript string
+	Connection   string // "database" or "tracker"
+	Reason       string
+}
+
+// Reconnects returns the reconnects of the most recent run
+func (m *Migrator) Reconnects() []Reconnect {
+	return m.reconnects
+}
+
+// ensureConnection checks the connections at a script boundary; when one was lost, e.g.
+// because a Vault lease or an access token expired, it is reopened with refreshed
+// credentials so the batch resumes with the next script instead of failing
+func (m *Migrator) ensureConnection(next string) error {
+	type connection struct {
+		name     string
+		database *db.DB
+	}
+	conns := []connection{{"database", m.db}}
+	if m.tracker.db != m.db {
+		conns = append(conns, connection{"tracker", m.tracker.db})
+	}
+
+	for _, c := range conns {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := c.database.Ping(ctx)
+		cancel()
+		if err == nil {
+			continue
+		}
+		if !db.IsConnectionError(err) || !c.database.CanReconnect() {
+			return fmt.Errorf("%s connection lost before %s: %w", c.name, next, err)
+		}
+
+		m.console.Warn("The %s connection was lost (%v); reconnecting with refreshed credentials...", c.name, err)
+		if err := c.database.Reconnect(); err != nil {
+			return fmt.Errorf("%s connection lost before %s: %w", c.name, next, err)
+		}
+		m.reconnects = append(m.reconnects, Reconnect{At: m.clock.Now(), BeforeScript: next, Connection: c.name, Reason: err.Error()})
+		m.console.Success("Reconnected; resuming with %s", next)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// RepairPlan is what repair changes in the tracking table
+type RepairPlan struct {
+	Failed  []ScriptRecord // Records of failed attempts, deleted
+	Changes []RepairChange // Column updates of the remaining records, in sno order
+}
+
+// RepairChange sets one column of one tracking record
+type RepairChange struct {
+	SNO    int
+	Script string
+	Column string // checksum, lastgitid or endofbatch
+	From   any
+	To     any
+}
+
+// Empty reports whether the tracking table needs no repair
+func (p *RepairPlan) Empty() bool {
+	return len(p.Failed) == 0 && len(p.Changes) == 0
+}
+
+// Repair reconciles the tracking table after a botched run: it deletes the records of
+// failed attempts, recomputes the checksums of applied scripts from their current
+// content, fills missing lastgitid values from the batch, and moves the endofbatch
+// marker of completed batches to their last remaining record. With dryRun it only
+// prints the changes
+func (m *Migrator) Repair(dryRun bool, confirm ConfirmFunc) error {
+	m.console.Header("DB Repair")
+	m.forgetCommit()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return err
+	}
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+
+	plan, err := m.planRepair()
+	if err != nil {
+		return err
+	}
+	if plan.Empty() {
+		m.console.Success("Tracking table %s needs no repair", m.tracker.tableName)
+		return nil
+	}
+	m.printRepairPlan(plan)
+
+	if dryRun {
+		m.console.Info("Dry run: the tracking table was not changed")
+		return nil
+	}
+	if !confirm(fmt.Sprintf("Delete %d records and update %d values of %s?", len(plan.Failed), len(plan.Changes), m.tracker.tableName)) {
+		return fmt.Errorf("repair cancelled")
+	}
+	if err := m.tracker.ApplyRepair(plan); err != nil {
+		return err
+	}
+
+	m.console.Success("Repaired %s: deleted %d records and updated %d values", m.tracker.tableName, len(plan.Failed), len(plan.Changes))
+	return nil
+}
+
+// planRepair works out the repair of the tracking table without changing it
+func (m *Migrator) planRepair() (*RepairPlan, error) {
+	all, err := m.tracker.GetAllScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RepairPlan{}
+	var kept []ScriptRecord
+	ended := make(map[string]bool)     // Batches that completed
+	commits := make(map[string]string) // Commit each batch ran at
+	for _, rec := range all {
+		if rec.BatchID != "" {
+			if rec.EndOfBatch {
+				ended[rec.BatchID] = true
+			}
+			if rec.LastGitID != "" && (commits[rec.BatchID] == "" || rec.EndOfBatch) {
+				commits[rec.BatchID] = rec.LastGitID
+			}
+		}
+		if rec.Completed {
+			kept = append(kept, rec)
+		} else {
+			plan.Failed = append(plan.Failed, rec)
+		}
+	}
+
+	// A completed batch ends at its last remaining record, e.g. instead of a tolerated
+	// failure that closed it. Batches that stopped early are left open, so the next up
+	// still finds the scripts they did not run
+	last := make(map[string]int)
+	for _, rec := range kept {
+		last[rec.BatchID] = rec.SNO
+	}
+	for _, rec := range kept {
+		if rec.BatchID == "" {
+			continue
+		}
+		if commit := commits[rec.BatchID]; rec.LastGitID == "" && commit != "" {
+			plan.Changes = append(plan.Changes, RepairChange{SNO: rec.SNO, Script: rec.ScriptName, Column: "lastgitid", From: rec.LastGitID, To: commit})
+		}
+		if end := last[rec.BatchID] == rec.SNO; ended[rec.BatchID] && rec.EndOfBatch != end {
+			plan.Changes = append(plan.Changes, RepairChange{SNO: rec.SNO, Script: rec.ScriptName, Column: "endofbatch", From: rec.EndOfBatch, To: end})
+		}
+	}
+
+	applied, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range applied {
+		checksum, ok, err := m.currentChecksum(rec)
+		if err != nil {
+			return nil, err
+		}
+		if ok && checksum != rec.Checksum {
+			plan.Changes = append(plan.Changes, RepairChange{SNO: rec.SNO, Script: rec.ScriptName, Column: "checksum", From: rec.Checksum, To: checksum})
+		}
+	}
+	sort.SliceStable(plan.Changes, func(i, j int) bool { return plan.Changes[i].SNO < plan.Changes[j].SNO })
+
+	return plan, nil
+}
+
+// printRepairPlan lists the records repair deletes and the values it updates
+func (m *Migrator) printRepairPlan(plan *RepairPlan) {
+	var rows [][]string
+	for _, rec := range plan.Failed {
+		rows = append(rows, []string{strconv.Itoa(rec.SNO), rec.ScriptName, "delete failed attempt"})
+	}
+	for _, c := range plan.Changes {
+		from, to := fmt.Sprint(c.From), fmt.Sprint(c.To)
+		if c.Column != "endofbatch" {
+			from, to = shortCommit(from), shortCommit(to)
+		}
+		if from == "" {
+			from = "(empty)"
+		}
+		rows = append(rows, []string{strconv.Itoa(c.SNO), c.Script, fmt.Sprintf("%s %s -> %s", c.Column, from, to)})
+	}
+	m.console.Table([]string{"SNO", "SCRIPT", "CHANGE"}, rows)
+}
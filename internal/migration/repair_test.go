@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	m, repo, scriptsDir, testDB := newFakeMigrator(t)
+	yes := func(string) bool { return true }
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users and posts")
+	if err := m.Run(); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "004_best_effort.sql", "-- dbmig: onError=continue\nDROP TABLE does_not_exist;")
+	repo.CommitScripts("add tags and best effort")
+	if err := m.Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+
+	// A botched manual fix lost a commit and a checksum
+	if err := testDB.Exec("UPDATE sqlScriptExec SET lastgitid = NULL, checksum = 'bogus' WHERE scriptName = '001_users.sql'"); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := m.planRepair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Failed) != 1 || plan.Failed[0].ScriptName != "004_best_effort.sql" {
+		t.Errorf("expected the failed attempt to be deleted, got %+v", plan.Failed)
+	}
+	var changes []string
+	for _, c := range plan.Changes {
+		changes = append(changes, c.Script+" "+c.Column)
+	}
+	want := []string{"001_users.sql lastgitid", "001_users.sql checksum", "003_tags.sql endofbatch"}
+	if len(changes) != len(want) {
+		t.Fatalf("expected changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("expected changes %v, got %v", want, changes)
+			break
+		}
+	}
+
+	// A dry run changes nothing
+	if err := m.Repair(true, yes); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if records, _ := m.tracker.GetAllScripts(); len(records) != 4 {
+		t.Errorf("expected the dry run to keep all 4 records, got %d", len(records))
+	}
+
+	if err := m.Repair(false, yes); err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	records, err := m.tracker.GetAllScripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].LastGitID != records[1].LastGitID || records[0].Checksum == "bogus" || !records[2].EndOfBatch {
+		t.Errorf("expected a repaired tracking table, got %+v", records)
+	}
+	if plan, err := m.planRepair(); err != nil || !plan.Empty() {
+		t.Errorf("expected nothing left to repair, got %+v, %v", plan, err)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatalf("run after repair failed: %v", err)
+	}
+}
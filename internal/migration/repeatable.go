@@ -0,0 +1,66 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// pendingRepeatables returns repeatable (R__) scripts whose content differs from
+// the checksum recorded at their last successful execution, sorted by path
+func (m *Migrator) pendingRepeatables(commit string) ([]git.ScriptInfo, error) {
+	files, err := m.git.ListFiles(commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %w", err)
+	}
+
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve scripts directory: %w", err)
+	}
+
+	applied, err := m.tracker.GetAppliedScripts()
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string)
+	for _, rec := range applied {
+		checksums[rec.ScriptName] = rec.Checksum
+	}
+
+	var scripts []git.ScriptInfo
+	for _, file := range files {
+		if !git.IsScript(file) || !git.IsRepeatable(file) || git.IsDownScript(file) || m.isIncludeFragment(filepath.Join(prefix, file)) || m.isHook(filepath.Join(prefix, file)) || m.isVerifyScript(filepath.Join(prefix, file)) || m.isChangelogFile(filepath.Join(prefix, file)) {
+			continue
+		}
+
+		// Named by their path in the scripts directory, so a/R__views.sql and
+		// b/R__views.sql are tracked apart
+		script := git.ScriptInfo{
+			Name: filepath.ToSlash(file),
+			Path: filepath.Join(m.config.ScriptsDir, file),
+		}
+		content, err := m.readScript(script)
+		if err != nil {
+			return nil, err
+		}
+
+		checksum, err := m.scriptChecksum(script, content)
+		if err != nil {
+			return nil, err
+		}
+		if checksums[script.Name] == checksum {
+			continue
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Name < scripts[j].Name
+	})
+
+	return scripts, nil
+}
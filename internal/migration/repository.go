@@ -0,0 +1,106 @@
+package migration
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Repository is the git history scripts are discovered in, relative to the scripts
+// directory. *git.Git implements it with the git CLI; testkit.FakeRepo without one, and
+// fsRepository without history
+type Repository interface {
+	GetCurrentCommit() (string, error)
+	ResolveCommit(ref string) (string, error)
+	ReadFile(commit, path string) ([]byte, error)
+	Prefix() (string, error)
+	TopLevel() (string, error)
+	IsGitRepository() bool
+	ListFiles(commit string) ([]string, error)
+	DiffFileStatus(fromCommit, toCommit string) (map[string]string, error)
+	GetChangedScripts(fromCommit, toCommit, scriptsDir string) ([]git.ScriptInfo, error)
+	GetFileCommitTimestamp(path string) (time.Time, error)
+	CommitMessages(path string) ([]string, error)
+	Upstream() string
+	Pull() error
+}
+
+// WithRepository makes a Migrator or Inspector read scripts from repo instead of the
+// git checkout at the scripts directory, e.g. a fake in unit tests
+func WithRepository(repo Repository) Option {
+	return func(o *options) {
+		o.repo = repo
+	}
+}
+
+// repository returns the Repository of the scripts directory, current at --ref if given
+// and limited to the directory in a namespace; WithFS and --source filesystem read
+// files without git
+func (o options) repository(cfg *config.Config) Repository {
+	repo := o.repo
+	switch {
+	case repo != nil:
+	case o.fsys != nil:
+		repo = newFSRepository(o.fsys, cfg.ScriptsDir)
+	case cfg.Source == config.SourceFilesystem:
+		repo = newFilesystemRepository(cfg.ScriptsDir)
+	default:
+		repo = git.NewContext(o.ctx, cfg.ScriptsDir, o.logger)
+	}
+	if cfg.Namespace != "" {
+		repo = namespaceRepository{Repository: repo}
+	}
+	if cfg.Ref != "" {
+		return refRepository{Repository: repo, ref: cfg.Ref}
+	}
+	return repo
+}
+
+// refRepository is a Repository whose current commit is a ref, e.g. a release tag,
+// instead of HEAD
+type refRepository struct {
+	Repository
+	ref string
+}
+
+func (r refRepository) GetCurrentCommit() (string, error) {
+	return r.ResolveCommit(r.ref)
+}
+
+// namespaceRepository is a Repository limited to the scripts directory, so the scripts
+// directories of a monorepo neither run nor check each other's scripts
+type namespaceRepository struct {
+	Repository
+}
+
+func (r namespaceRepository) DiffFileStatus(fromCommit, toCommit string) (map[string]string, error) {
+	status, err := r.Repository.DiffFileStatus(fromCommit, toCommit)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := r.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	for file := range status {
+		if !strings.HasPrefix(file, prefix) {
+			delete(status, file)
+		}
+	}
+	return status, nil
+}
+
+func (r namespaceRepository) GetChangedScripts(fromCommit, toCommit, scriptsDir string) ([]git.ScriptInfo, error) {
+	scripts, err := r.Repository.GetChangedScripts(fromCommit, toCommit, scriptsDir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := r.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(scripts, func(s git.ScriptInfo) bool { return !strings.HasPrefix(s.Path, prefix) }), nil
+}
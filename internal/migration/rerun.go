@@ -0,0 +1,132 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// ConfirmFunc asks the operator a yes/no question
+type ConfirmFunc func(prompt string) bool
+
+// Rerun re-executes an applied script whose content still matches the recorded checksum
+// Schema scripts are looked up first, then seed scripts when a seed directory is configured.
+// The run is recorded as a new row with action rerun; it does not change what is applied.
+func (m *Migrator) Rerun(name string, confirm ConfirmFunc) error {
+	m.console.Header("DB Script Rerun")
+	m.forgetCommit()
+	m.batchID = m.ids.NewID()
+
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return err
+	}
+	if err := m.tracker.EnsureTable(); err != nil {
+		return err
+	}
+
+	t, dir, applied, err := m.findApplied(name)
+	if err != nil {
+		return err
+	}
+	if applied.Action == ActionSkip {
+		return fmt.Errorf("%s was skipped by its guard and never ran; nothing to rerun", name)
+	}
+
+	script := git.ScriptInfo{Name: name, Path: filepath.Join(dir, name)}
+	if abs, err := filepath.Abs(script.Path); err == nil {
+		script.Path = abs
+	}
+	if _, err := os.Stat(script.Path); err != nil {
+		return fmt.Errorf("script %s not found in %s", name, dir)
+	}
+
+	// The script must be exactly what was applied
+	content, err := m.readScript(script)
+	if err != nil {
+		return err
+	}
+	checksum, err := m.scriptChecksum(script, content)
+	if err != nil {
+		return err
+	}
+	switch {
+	case applied.Checksum == "":
+		m.console.Warn("%s was applied before checksums were recorded; its content cannot be verified", name)
+	case applied.Checksum != checksum:
+		return fmt.Errorf("%s has changed since it was applied (checksum %s, recorded %s) - refusing to rerun", name, shortChecksum(checksum), shortChecksum(applied.Checksum))
+	default:
+		m.console.Info("Checksum verified: %s", shortChecksum(checksum))
+	}
+
+	if !confirm(fmt.Sprintf("Re-run %s, applied %s?", name, applied.CreatedDateTime.Format("2006-01-02 15:04:05"))) {
+		return fmt.Errorf("rerun of %s cancelled", name)
+	}
+	unlock, err := m.lockRun()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Batch state is unchanged: the row closes its own batch at the last successful commit
+	lastGitID, err := t.GetLastSuccessfulCommit()
+	if err != nil {
+		return fmt.Errorf("failed to get last commit: %w", err)
+	}
+
+	if err := m.loadDirectives([]git.ScriptInfo{script}); err != nil {
+		return err
+	}
+
+	m.console.Script(name, "executing")
+	rec := ScriptRecord{ScriptName: name, EndOfBatch: true, LastGitID: lastGitID, Action: ActionRerun}
+	if err := m.executeScript(t, script, rec); err != nil {
+		m.console.Script(name, "failed")
+		return fmt.Errorf("rerun failed: %w", err)
+	}
+	m.console.Script(name, "success")
+
+	m.console.Success("Rerun completed successfully!")
+	return nil
+}
+
+// findApplied looks up the applied record of a script in the schema, then the seed tracker
+// It returns the tracker, the directory holding the script, and the record
+func (m *Migrator) findApplied(name string) (*Tracker, string, ScriptRecord, error) {
+	candidates := []struct {
+		tracker *Tracker
+		dir     string
+	}{{m.tracker, m.config.ScriptsDir}}
+	if m.config.SeedDir != "" {
+		if err := m.seedTracker.EnsureTable(); err != nil {
+			return nil, "", ScriptRecord{}, err
+		}
+		candidates = append(candidates, struct {
+			tracker *Tracker
+			dir     string
+		}{m.seedTracker, m.config.SeedDir})
+	}
+
+	for _, c := range candidates {
+		applied, err := c.tracker.GetAppliedScripts()
+		if err != nil {
+			return nil, "", ScriptRecord{}, err
+		}
+		for _, rec := range applied {
+			if rec.ScriptName == name {
+				return c.tracker, c.dir, rec, nil
+			}
+		}
+	}
+
+	return nil, "", ScriptRecord{}, fmt.Errorf("%s is not applied; only applied scripts can be rerun", name)
+}
+
+// shortChecksum abbreviates a checksum for display
+func shortChecksum(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
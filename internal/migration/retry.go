@@ -0,0 +1,113 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/directive"
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// maxRetryDelay caps the doubling delay between retries of a script
+const maxRetryDelay = 30 * time.Second
+
+// implicitCommits are the leading keywords of MySQL statements that commit the
+// transaction they run in
+var implicitCommits = map[string]bool{
+	"ALTER": true, "ANALYZE": true, "BEGIN": true, "CACHE": true, "COMMIT": true, "CREATE": true,
+	"DROP": true, "FLUSH": true, "GRANT": true, "INSTALL": true, "LOCK": true, "OPTIMIZE": true,
+	"RENAME": true, "REPAIR": true, "RESET": true, "REVOKE": true, "START": true, "TRUNCATE": true,
+	"UNINSTALL": true, "UNLOCK": true,
+}
+
+// executeInTx runs a script, then loads its data files, in a new transaction and returns
+// it uncommitted. A deadlock, lock wait timeout or lost connection rolls it back and runs
+// the script again in a fresh one, up to --retries times with doubling delays, as long
+// as nothing of it was committed
+func (m *Migrator) executeInTx(ctx context.Context, script git.ScriptInfo, content []byte, directives directive.Set) (*sql.Tx, error) {
+	delay := m.config.RetryDelay
+	for attempt := 1; ; attempt++ {
+		tx, ran, err := m.attemptInTx(ctx, script, content, directives)
+		if err == nil {
+			return tx, nil
+		}
+		if attempt > m.config.Retries || !db.IsTransientError(err) || !retrySafe(m.db.Driver(), string(content), ran) {
+			return nil, err
+		}
+
+		m.console.Warn("%s failed with a transient error, retrying in %s (%d of %d): %v", script.Name, delay, attempt, m.config.Retries, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, err
+		}
+		delay = min(2*delay, maxRetryDelay)
+
+		// A lost connection is reopened, with refreshed credentials where configured
+		if db.IsConnectionError(err) {
+			if err := m.ensureConnection(script.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// attemptInTx is one attempt of executeInTx; on failure it rolls back and returns how
+// many statements of the script ran before it
+func (m *Migrator) attemptInTx(ctx context.Context, script git.ScriptInfo, content []byte, directives directive.Set) (*sql.Tx, int, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	ran := 0
+	if len(directives.Loads) == 0 || len(splitStatements(string(content))) > 0 {
+		err = m.db.ExecuteSQLTx(ctx, tx, string(content))
+		var stmtErr *db.StatementError
+		if errors.As(err, &stmtErr) {
+			ran = stmtErr.Index - 1
+		}
+	}
+	if err == nil {
+		ran = len(db.SplitStatements(string(content)))
+		err = m.executeLoads(tx, script, directives.Loads)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, ran, err
+	}
+	return tx, ran, nil
+}
+
+// retrySafe reports whether a script whose first ran statements succeeded, and whose
+// next one failed, left nothing committed. MySQL commits implicitly before DDL and
+// similar statements, so a script is partly applied once one of them started, even the
+// failing one: for INSERT ...; ALTER ... the INSERT is committed before the ALTER hits a
+// deadlock. The other servers roll DDL back with the transaction
+func retrySafe(driver, content string, ran int) bool {
+	if driver != db.DriverMySQL {
+		return true
+	}
+	statements := db.SplitStatements(content)
+	for _, stmt := range statements[:min(ran+1, len(statements))] {
+		if commitsImplicitly(stmt) {
+			return false
+		}
+	}
+	return true
+}
+
+// commitsImplicitly reports whether a MySQL statement commits the transaction it runs in
+func commitsImplicitly(stmt string) bool {
+	code := splitStatements(stmt)
+	if len(code) == 0 {
+		return false
+	}
+	fields := strings.Fields(code[0])
+	return len(fields) > 0 && implicitCommits[strings.ToUpper(fields[0])]
+}
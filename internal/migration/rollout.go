@@ -0,0 +1,133 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+// Stage outcomes of a rollout
+const (
+	StageSuccess      = "success"
+	StageFailed       = "failed"        // up failed
+	StageVerifyFailed = "verify-failed" // Applied, but a verify script failed
+	StageReverted     = "reverted"      // A verify script failed and the batch was reverted
+	StageNotStarted   = "not-started"   // An earlier stage failed
+)
+
+// RolloutStage is the outcome of one target of a rollout
+type RolloutStage struct {
+	Target     string         `json:"target"`
+	Status     string         `json:"status"`
+	DurationMS int64          `json:"duration_ms"`
+	Report     *RunReport     `json:"report,omitempty"` // nil when not started
+	Verify     []VerifyResult `json:"verify,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// RolloutReport is the consolidated report of a rollout
+type RolloutReport struct {
+	Status    string         `json:"status"` // success or failed
+	StartedAt time.Time      `json:"started_at"`
+	Stages    []RolloutStage `json:"stages"`
+}
+
+// Rollout runs stage against each target in order, e.g. canary, staging, then prod,
+// and stops at the first that does not succeed; later targets are not started
+func Rollout(targets []config.Target, stage func(target config.Target) RolloutStage) RolloutReport {
+	report := RolloutReport{Status: StageSuccess, StartedAt: time.Now().UTC()}
+	for _, target := range targets {
+		if report.Status != StageSuccess {
+			report.Stages = append(report.Stages, RolloutStage{Target: target.Name, Status: StageNotStarted})
+			continue
+		}
+		started := time.Now()
+		s := stage(target)
+		s.Target, s.DurationMS = target.Name, time.Since(started).Milliseconds()
+		if s.Status != StageSuccess {
+			report.Status = StageFailed
+		}
+		report.Stages = append(report.Stages, s)
+	}
+	return report
+}
+
+// RunStage applies the pending scripts and runs the verify scripts as one stage of a
+// rollout. When verification fails and autoRevert is set, the batch just applied is
+// reverted with its down scripts
+func (m *Migrator) RunStage(autoRevert bool) RolloutStage {
+	runErr := m.Run()
+	report := m.Report(runErr)
+	stage := RolloutStage{Status: StageSuccess, Report: &report}
+	if runErr != nil {
+		m.console.Error("Migration failed: %v", runErr)
+		stage.Status, stage.Error = StageFailed, runErr.Error()
+		return stage
+	}
+
+	m.console.Info("Running verify scripts...")
+	results, err := m.Verify()
+	stage.Verify = results
+	if err == nil {
+		return stage
+	}
+	stage.Status, stage.Error = StageVerifyFailed, err.Error()
+	if !autoRevert {
+		return stage
+	}
+
+	applied := 0
+	for _, s := range report.Scripts {
+		if s.Status == ResultSuccess {
+			applied++
+		}
+	}
+	if applied == 0 {
+		m.console.Warn("Nothing was applied in this rollout; there is no batch to revert")
+		return stage
+	}
+	m.console.Warn("Reverting batch %s after the failed verification...", report.BatchID)
+	if err := m.Down("", 0); err != nil {
+		stage.Error = fmt.Sprintf("%s; revert failed: %v", stage.Error, err)
+		return stage
+	}
+	stage.Status = StageReverted
+	return stage
+}
+
+// WriteRollout writes a summary table of a rollout: one row per target with its status,
+// applied scripts, verify scripts passed, duration and error
+func WriteRollout(w io.Writer, report RolloutReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tAPPLIED\tVERIFIED\tDURATION\tERROR")
+	for _, s := range report.Stages {
+		applied, passed := 0, 0
+		if s.Report != nil {
+			for _, script := range s.Report.Scripts {
+				if script.Status == ResultSuccess {
+					applied++
+				}
+			}
+		}
+		for _, v := range s.Verify {
+			if v.Passed {
+				passed++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d/%d\t%s\t%s\n", s.Target, s.Status, applied, passed, len(s.Verify),
+			(time.Duration(s.DurationMS) * time.Millisecond).String(), s.Error)
+	}
+	fmt.Fprintf(tw, "\nRollout %s\n", report.Status)
+	return tw.Flush()
+}
+
+// WriteJSON writes the report as indented JSON, e.g. for --output
+func (r RolloutReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
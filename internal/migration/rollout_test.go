@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+func TestRollout(t *testing.T) {
+	targets := []config.Target{{Name: "canary"}, {Name: "staging"}, {Name: "prod"}}
+	var started []string
+	stage := func(target config.Target) RolloutStage {
+		started = append(started, target.Name)
+		if target.Name == "staging" {
+			return RolloutStage{
+				Status: StageReverted,
+				Report: &RunReport{Scripts: []ReportScript{{Status: ResultSuccess}}},
+				Verify: []VerifyResult{{Script: "verify/001_orders.sql", Passed: true}, {Script: "verify/002_users.sql", Rows: 3, Error: "3 rows violate SELECT id FROM users"}},
+				Error:  "1 of 2 verify scripts failed",
+			}
+		}
+		return RolloutStage{Status: StageSuccess, Report: &RunReport{Scripts: []ReportScript{{Status: ResultSuccess}, {Status: ResultSuccess}}}}
+	}
+
+	report := Rollout(targets, stage)
+	if strings.Join(started, ",") != "canary,staging" {
+		t.Errorf("expected the rollout to stop after staging, started %v", started)
+	}
+	if report.Status != StageFailed || report.Stages[0].Status != StageSuccess || report.Stages[1].Status != StageReverted || report.Stages[2].Status != StageNotStarted {
+		t.Errorf("unexpected rollout report %+v", report)
+	}
+	if report.Stages[1].Target != "staging" || report.Stages[2].Report != nil {
+		t.Errorf("expected stages named after their targets, got %+v", report.Stages)
+	}
+
+	var table bytes.Buffer
+	WriteRollout(&table, report)
+	for _, want := range []string{"TARGET", "canary   success      2        0/0", "staging  reverted     1        1/2", "prod     not-started  0        0/0", "Rollout failed"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("expected %q in the table:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded RolloutReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded.Stages) != 3 || decoded.Stages[1].Verify[1].Rows != 3 {
+		t.Errorf("expected the JSON report to round-trip, got %+v %v", decoded, err)
+	}
+
+	if report := Rollout(targets, func(config.Target) RolloutStage { return RolloutStage{Status: StageSuccess} }); report.Status != StageSuccess {
+		t.Errorf("expected a successful rollout, got %+v", report)
+	}
+}
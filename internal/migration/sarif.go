@@ -0,0 +1,156 @@
+package migration
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Finding is a validation result, reported on the console and, with --sarif, in a SARIF log
+type Finding struct {
+	RuleID  string
+	Level   string // error, warning or note
+	Message string
+	Path    string // Script path relative to the repository root
+	Line    int    // 1-based; 0 when the finding applies to the whole script
+}
+
+// Rules reported by the validator
+const (
+	RuleModifiedScript       = "modified-script"
+	RuleDeletedScript        = "deleted-script"
+	RuleScriptBudget         = "script-budget"
+	RuleDuplicateScript      = "duplicate-script"
+	RuleDestructiveStatement = "destructive-statement"
+	RuleScriptNaming         = "script-naming"
+	RuleMissingPrivilege     = "missing-privilege"
+)
+
+// ruleDescriptions are the short descriptions shown by code scanning dashboards
+var ruleDescriptions = map[string]string{
+	RuleModifiedScript:       "A previously executed script was modified",
+	RuleDeletedScript:        "A previously executed script was deleted",
+	RuleScriptBudget:         "A script exceeds the configured size or complexity budget",
+	RuleDuplicateScript:      "A pending script has the same statements as an applied script",
+	RuleDestructiveStatement: "A script drops, truncates or deletes data",
+	RuleScriptNaming:         "A script name does not follow the <version>_<description>.sql convention",
+	RuleMissingPrivilege:     "The migration user lacks a privilege a script needs",
+}
+
+// sarifLog is the subset of SARIF 2.1.0 written by WriteSARIF
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes findings as a SARIF 2.1.0 log for GitHub code scanning and similar tools
+func WriteSARIF(w io.Writer, findings []Finding) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "db-migration"}},
+		Results: []sarifResult{},
+	}
+	for _, id := range sortedKeys(ruleDescriptions) {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{Text: ruleDescriptions[id]}})
+	}
+
+	for _, f := range findings {
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.Path), URIBaseID: "%SRCROOT%"}}
+		if f.Line > 0 {
+			location.Region = &sarifRegion{StartLine: f.Line}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.RuleID,
+			Level:     f.Level,
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// writeSARIF saves the validator findings to the --sarif file, even when the run failed
+func (m *Migrator) writeSARIF() {
+	if m.config.SARIFFile == "" {
+		return
+	}
+
+	findings := m.validator.Findings()
+	// Bundles are tracked by absolute directory path; code scanning needs repository paths
+	if root, err := m.git.TopLevel(); err == nil {
+		for i, f := range findings {
+			if rel, err := filepath.Rel(root, f.Path); err == nil && filepath.IsAbs(f.Path) {
+				findings[i].Path = rel
+			}
+		}
+	}
+
+	file, err := os.Create(m.config.SARIFFile)
+	if err == nil {
+		err = WriteSARIF(file, findings)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		m.console.Warn("Could not write SARIF report to %s: %v", m.config.SARIFFile, err)
+		return
+	}
+	m.console.Info("Wrote %d findings to %s", len(findings), m.config.SARIFFile)
+}
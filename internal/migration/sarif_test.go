@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	var out strings.Builder
+	err := WriteSARIF(&out, []Finding{
+		{RuleID: RuleModifiedScript, Level: "error", Message: "modified", Path: "db/001_create_users.sql"},
+		{RuleID: RuleDestructiveStatement, Level: "warning", Message: "TRUNCATE removes all rows", Path: "db/002_reset.sql", Line: 3},
+	})
+	if err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out.String()), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log header: %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(ruleDescriptions) {
+		t.Errorf("expected %d rules, got %d", len(ruleDescriptions), len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(run.Results))
+	}
+	if loc := run.Results[0].Locations[0].PhysicalLocation; loc.Region != nil || loc.ArtifactLocation.URI != "db/001_create_users.sql" {
+		t.Errorf("expected a whole-file location, got %+v", loc)
+	}
+	if loc := run.Results[1].Locations[0].PhysicalLocation; loc.Region == nil || loc.Region.StartLine != 3 {
+		t.Errorf("expected line 3, got %+v", loc)
+	}
+}
+
+func TestWriteSARIFWithoutFindings(t *testing.T) {
+	var out strings.Builder
+	if err := WriteSARIF(&out, nil); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+	// Code scanning rejects a run without a results array
+	if !strings.Contains(out.String(), `"results": []`) {
+		t.Errorf("expected an empty results array, got:\n%s", out.String())
+	}
+}
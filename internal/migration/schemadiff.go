@@ -0,0 +1,456 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// Schema is the parsed form of CREATE TABLE and CREATE VIEW statements
+type Schema struct {
+	Tables map[string]*Table
+	Views  map[string]string // Name -> CREATE VIEW statement
+}
+
+// Table holds the definitions of one table, keyed by lowercase name
+type Table struct {
+	Name        string
+	DDL         string
+	Columns     []Column
+	Indexes     map[string]string // Name (PRIMARY for the primary key) -> definition
+	Constraints map[string]string // Foreign keys and checks
+}
+
+// Column is a column name and its full definition
+type Column struct {
+	Name string
+	Def  string
+}
+
+var (
+	createTablePattern = regexp.MustCompile("(?is)^CREATE\\s+(?:TEMPORARY\\s+)?TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?((?:`[^`]+`|\\w+)(?:\\.(?:`[^`]+`|\\w+))?)")
+	createViewPattern  = regexp.MustCompile("(?is)^CREATE\\s.*?\\bVIEW\\s+((?:`[^`]+`|\\w+)(?:\\.(?:`[^`]+`|\\w+))?)")
+	definerPattern     = regexp.MustCompile("(?i)\\s+DEFINER\\s*=\\s*(?:`[^`]*`|'[^']*'|\\S+?)@(?:`[^`]*`|'[^']*'|\\S+)")
+	referencesPattern  = regexp.MustCompile("(?i)\\bREFERENCES\\s+((?:`[^`]+`|\\w+)(?:\\.(?:`[^`]+`|\\w+))?)")
+	createViewPrefix   = regexp.MustCompile(`(?i)^CREATE\s+(?:OR\s+REPLACE\s+)?`)
+	slugPattern        = regexp.MustCompile(`[^a-z0-9]+`)
+	whitespacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// Diff writes a candidate script that turns the --from schema file or --from-db database
+// into the schema of the connected database, and returns its path ("" when nothing differs)
+func (m *Migrator) Diff(name string) (string, error) {
+	source, statements, err := m.diffFrom()
+	if err != nil {
+		return "", err
+	}
+	if len(statements) == 0 {
+		m.console.Info("No schema differences between %s and database %s", source, m.config.DBName)
+		return "", nil
+	}
+
+	path, err := writeDiffScript(m.config.ScriptsDir, name, source+" to database "+m.config.DBName, statements)
+	if err != nil {
+		return "", err
+	}
+	m.console.Success("Wrote %d statements to %s", len(statements), path)
+	return path, nil
+}
+
+// diffFrom returns the statements that turn the --from schema file or --from-db
+// database into the schema of the connected database, and a description of the source
+func (m *Migrator) diffFrom() (string, []string, error) {
+	source, from, err := m.fromSchema()
+	if err != nil {
+		return "", nil, err
+	}
+	to, err := m.liveSchema()
+	if err != nil {
+		return "", nil, err
+	}
+	return source, DiffSchemas(from, to), nil
+}
+
+// fromSchema parses the --from schema file or the schema of the --from-db database,
+// and returns a description of it
+func (m *Migrator) fromSchema() (string, *Schema, error) {
+	source, fromDump := m.config.DiffFrom, ""
+	if m.config.DiffFromDB != "" {
+		source = "database " + m.config.DiffFromDB
+		dump, err := dumpSchema(m.db, m.config.DiffFromDB, []string{ScriptTableName, SeedTableName})
+		if err != nil {
+			return "", nil, err
+		}
+		fromDump = dump
+	} else {
+		content, err := os.ReadFile(m.config.DiffFrom)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", m.config.DiffFrom, err)
+		}
+		fromDump = string(content)
+	}
+
+	from, err := ParseSchema(fromDump)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	return source, from, nil
+}
+
+// liveSchema parses the schema of the connected database, without the tracking tables
+func (m *Migrator) liveSchema() (*Schema, error) {
+	dump, err := DumpSchema(m.db, ScriptTableName, SeedTableName)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := ParseSchema(dump)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database %s: %w", m.config.DBName, err)
+	}
+	return schema, nil
+}
+
+// ParseSchema reads the CREATE TABLE and CREATE VIEW statements of a schema dump
+// Other statements are ignored
+func ParseSchema(content string) (*Schema, error) {
+	schema := &Schema{Tables: make(map[string]*Table), Views: make(map[string]string)}
+
+	for _, stmt := range splitStatements(content) {
+		if match := createTablePattern.FindStringSubmatch(stmt); match != nil {
+			table, err := parseTable(unqualified(match[1]), stmt, len(match[0]))
+			if err != nil {
+				return nil, err
+			}
+			schema.Tables[table.Name] = table
+		} else if match := createViewPattern.FindStringSubmatch(stmt); match != nil {
+			schema.Views[unqualified(match[1])] = definerPattern.ReplaceAllString(stmt, "")
+		}
+	}
+
+	return schema, nil
+}
+
+// parseTable splits the parenthesized body of a CREATE TABLE into columns, indexes and constraints
+func parseTable(name, stmt string, bodyFrom int) (*Table, error) {
+	open := strings.IndexByte(stmt[bodyFrom:], '(')
+	if open < 0 {
+		return nil, fmt.Errorf("table %s has no column definitions", name)
+	}
+	open += bodyFrom
+
+	table := &Table{Name: name, DDL: stmt, Indexes: make(map[string]string), Constraints: make(map[string]string)}
+
+	depth := 0
+	start := open + 1
+	for i := open; i < len(stmt); i++ {
+		switch stmt[i] {
+		case '\'', '"', '`':
+			i = db.SkipQuoted(stmt, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				table.addDefinition(stmt[start:i])
+				return table, nil
+			}
+		case ',':
+			if depth == 1 {
+				table.addDefinition(stmt[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("table %s has unbalanced parentheses", name)
+}
+
+// addDefinition classifies one entry of a CREATE TABLE body
+func (t *Table) addDefinition(def string) {
+	def = strings.TrimSpace(def)
+	if def == "" {
+		return
+	}
+	first, _, _ := strings.Cut(strings.ToUpper(strings.Fields(def)[0]), "(")
+	key := strings.ToLower(normalizeDefinition(def))
+
+	switch first {
+	case "PRIMARY":
+		t.Indexes["PRIMARY"] = def
+	case "UNIQUE", "KEY", "INDEX", "FULLTEXT", "SPATIAL":
+		if name := indexName(def); name != "" {
+			key = strings.ToLower(name)
+		}
+		t.Indexes[key] = def
+	case "CONSTRAINT":
+		if fields := strings.Fields(def); len(fields) > 1 && !strings.EqualFold(fields[1], "FOREIGN") && !strings.EqualFold(fields[1], "CHECK") {
+			key = strings.ToLower(unquote(fields[1]))
+		}
+		t.Constraints[key] = def
+	case "FOREIGN", "CHECK":
+		t.Constraints[key] = def
+	default:
+		name := def
+		if def[0] == '`' {
+			name = def[1 : db.SkipQuoted(def, 0)-1]
+		} else if fields := strings.Fields(def); len(fields) > 0 {
+			name = fields[0]
+		}
+		t.Columns = append(t.Columns, Column{Name: name, Def: def})
+	}
+}
+
+// DiffSchemas returns the statements that turn the from schema into the to schema
+// Statements that drop tables or columns are commented out for review
+func DiffSchemas(from, to *Schema) []string {
+	var statements []string
+
+	for _, name := range createOrder(from, to) {
+		statements = append(statements, to.Tables[name].DDL+";")
+	}
+
+	for _, name := range sortedKeys(to.Tables) {
+		if old, ok := from.Tables[name]; ok {
+			statements = append(statements, diffTable(old, to.Tables[name])...)
+		}
+	}
+
+	for _, name := range sortedKeys(from.Tables) {
+		if _, ok := to.Tables[name]; !ok {
+			statements = append(statements, fmt.Sprintf("-- DROP TABLE %s;", quoteIdentifier(name)))
+		}
+	}
+
+	for _, name := range sortedKeys(from.Views) {
+		if _, ok := to.Views[name]; !ok {
+			statements = append(statements, fmt.Sprintf("DROP VIEW %s;", quoteIdentifier(name)))
+		}
+	}
+	for _, name := range sortedKeys(to.Views) {
+		view := to.Views[name]
+		if old, ok := from.Views[name]; !ok || normalizeDefinition(old) != normalizeDefinition(view) {
+			statements = append(statements, createViewPrefix.ReplaceAllString(view, "CREATE OR REPLACE ")+";")
+		}
+	}
+
+	return statements
+}
+
+// diffTable returns the ALTER TABLE for changed columns, indexes and constraints
+func diffTable(from, to *Table) []string {
+	var clauses, dropped []string
+
+	for _, key := range sortedKeys(from.Constraints) {
+		def := from.Constraints[key]
+		if newDef, ok := to.Constraints[key]; !ok || normalizeDefinition(newDef) != normalizeDefinition(def) {
+			clauses = append(clauses, dropConstraint(key, def))
+		}
+	}
+	for _, key := range sortedKeys(from.Indexes) {
+		def := from.Indexes[key]
+		if newDef, ok := to.Indexes[key]; !ok || normalizeDefinition(newDef) != normalizeDefinition(def) {
+			clauses = append(clauses, dropIndex(key, def))
+		}
+	}
+
+	oldColumns := make(map[string]string)
+	for _, col := range from.Columns {
+		oldColumns[strings.ToLower(col.Name)] = col.Def
+	}
+	newColumns := make(map[string]bool)
+	for i, col := range to.Columns {
+		newColumns[strings.ToLower(col.Name)] = true
+		oldDef, ok := oldColumns[strings.ToLower(col.Name)]
+		switch {
+		case !ok:
+			position := " FIRST"
+			if i > 0 {
+				position = " AFTER " + quoteIdentifier(to.Columns[i-1].Name)
+			}
+			clauses = append(clauses, "ADD COLUMN "+col.Def+position)
+		case normalizeDefinition(oldDef) != normalizeDefinition(col.Def):
+			clauses = append(clauses, "MODIFY COLUMN "+col.Def)
+		}
+	}
+	for _, col := range from.Columns {
+		if !newColumns[strings.ToLower(col.Name)] {
+			dropped = append(dropped, fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;", quoteIdentifier(to.Name), quoteIdentifier(col.Name)))
+		}
+	}
+
+	for _, key := range sortedKeys(to.Indexes) {
+		def := to.Indexes[key]
+		if oldDef, ok := from.Indexes[key]; !ok || normalizeDefinition(oldDef) != normalizeDefinition(def) {
+			clauses = append(clauses, "ADD "+def)
+		}
+	}
+	for _, key := range sortedKeys(to.Constraints) {
+		def := to.Constraints[key]
+		if oldDef, ok := from.Constraints[key]; !ok || normalizeDefinition(oldDef) != normalizeDefinition(def) {
+			clauses = append(clauses, "ADD "+def)
+		}
+	}
+
+	var statements []string
+	if len(clauses) > 0 {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s\n  %s;", quoteIdentifier(to.Name), strings.Join(clauses, ",\n  ")))
+	}
+	return append(statements, dropped...)
+}
+
+// createOrder lists the tables missing from the from schema, referenced tables first
+func createOrder(from, to *Schema) []string {
+	pending := make(map[string]bool)
+	for name := range to.Tables {
+		if _, ok := from.Tables[name]; !ok {
+			pending[name] = true
+		}
+	}
+
+	var order []string
+	for len(pending) > 0 {
+		progress := false
+		for _, name := range sortedKeys(pending) {
+			ready := true
+			for _, match := range referencesPattern.FindAllStringSubmatch(to.Tables[name].DDL, -1) {
+				if ref := unqualified(match[1]); ref != name && pending[ref] {
+					ready = false
+				}
+			}
+			if ready {
+				order = append(order, name)
+				delete(pending, name)
+				progress = true
+			}
+		}
+		// Tables referencing each other are created in name order
+		if !progress {
+			for _, name := range sortedKeys(pending) {
+				order = append(order, name)
+			}
+			break
+		}
+	}
+	return order
+}
+
+// dropConstraint returns the clause removing a foreign key or check constraint
+func dropConstraint(key, def string) string {
+	if strings.Contains(strings.ToUpper(def), "FOREIGN KEY") {
+		return "DROP FOREIGN KEY " + quoteIdentifier(key)
+	}
+	return "DROP CHECK " + quoteIdentifier(key)
+}
+
+// dropIndex returns the clause removing an index or the primary key
+func dropIndex(key, def string) string {
+	if key == "PRIMARY" {
+		return "DROP PRIMARY KEY"
+	}
+	if name := indexName(def); name != "" {
+		return "DROP INDEX " + quoteIdentifier(name)
+	}
+	return "DROP INDEX " + quoteIdentifier(key)
+}
+
+// indexName returns the name of an index definition, or "" when it is unnamed
+func indexName(def string) string {
+	fields := strings.Fields(def)
+	for i, field := range fields {
+		upper := strings.ToUpper(field)
+		if upper != "KEY" && upper != "INDEX" {
+			continue
+		}
+		if i+1 < len(fields) && !strings.HasPrefix(fields[i+1], "(") && !strings.EqualFold(fields[i+1], "USING") {
+			name, _, _ := strings.Cut(fields[i+1], "(")
+			return unquote(name)
+		}
+		return ""
+	}
+	return ""
+}
+
+// writeDiffScript writes statements as the next numbered script in dir and returns its path
+func writeDiffScript(dir, name, source string, statements []string) (string, error) {
+	version, width, err := nextScriptVersion(dir)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%0*d_%s.sql", width, version, scriptSlug(name)))
+	content := fmt.Sprintf("-- Generated by db-migration diff from %s\n-- Review before committing: drops of tables and columns are commented out\n\n%s\n",
+		source, strings.Join(statements, "\n\n"))
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// nextScriptVersion returns one past the highest numeric script prefix in dir, and its zero-padded width
+func nextScriptVersion(dir string) (int64, int, error) {
+	var highest int64
+	width := 3
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if version, ok := scriptVersion(d.Name()); ok && version >= highest {
+			highest = version
+			width = strings.IndexFunc(d.Name(), func(r rune) bool { return r < '0' || r > '9' })
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to scan scripts directory: %w", err)
+	}
+	return highest + 1, width, nil
+}
+
+// scriptSlug turns a description into a script name suffix
+func scriptSlug(name string) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "schema_diff"
+	}
+	return slug
+}
+
+// normalizeDefinition collapses whitespace so formatting alone is not a change
+func normalizeDefinition(def string) string {
+	return whitespacePattern.ReplaceAllString(strings.TrimSpace(def), " ")
+}
+
+// unqualified strips the quotes and schema of a table name
+func unqualified(name string) string {
+	if strings.HasSuffix(name, "`") {
+		return unquote(name[strings.LastIndex(name[:len(name)-1], "`"):])
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return unquote(name)
+}
+
+// unquote removes surrounding backticks from an identifier
+func unquote(ident string) string {
+	return strings.Trim(ident, "`")
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const usersTable = "CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(100) NOT NULL,\n" +
+	"  `legacy` tinyint(1) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_name` (`name`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema("-- Schema snapshot\n\n" + usersTable +
+		"CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `active_users` AS select `users`.`id` AS `id` from `users`;\n")
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	users := schema.Tables["users"]
+	if users == nil {
+		t.Fatalf("expected users table, got %v", schema.Tables)
+	}
+	var columns []string
+	for _, col := range users.Columns {
+		columns = append(columns, col.Name)
+	}
+	if !reflect.DeepEqual(columns, []string{"id", "name", "legacy"}) {
+		t.Errorf("unexpected columns %v", columns)
+	}
+	if users.Indexes["PRIMARY"] == "" || users.Indexes["idx_name"] == "" {
+		t.Errorf("expected primary key and idx_name, got %v", users.Indexes)
+	}
+	if view := schema.Views["active_users"]; view == "" || strings.Contains(view, "DEFINER=`root`") {
+		t.Errorf("expected view without definer, got %q", view)
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	from, err := ParseSchema(usersTable)
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+	to, err := ParseSchema("CREATE TABLE `users` (\n" +
+		"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+		"  `name` varchar(200) NOT NULL,\n" +
+		"  `email` varchar(255) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uq_email` (`email`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+		"CREATE TABLE `orders` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `user_id` int NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n" +
+		") ENGINE=InnoDB;\n")
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	got := DiffSchemas(from, to)
+	want := []string{
+		"CREATE TABLE `orders` (\n" +
+			"  `id` int NOT NULL,\n" +
+			"  `user_id` int NOT NULL,\n" +
+			"  PRIMARY KEY (`id`),\n" +
+			"  CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n" +
+			") ENGINE=InnoDB;",
+		"ALTER TABLE `users`\n" +
+			"  DROP INDEX `idx_name`,\n" +
+			"  MODIFY COLUMN `name` varchar(200) NOT NULL,\n" +
+			"  ADD COLUMN `email` varchar(255) DEFAULT NULL AFTER `name`,\n" +
+			"  ADD UNIQUE KEY `uq_email` (`email`);",
+		"-- ALTER TABLE `users` DROP COLUMN `legacy`;",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if same := DiffSchemas(from, from); len(same) != 0 {
+		t.Errorf("expected no statements for identical schemas, got %v", same)
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	to, err := ParseSchema("CREATE TABLE a (id INT, b_id INT, FOREIGN KEY (b_id) REFERENCES b (id));\nCREATE TABLE b (id INT PRIMARY KEY);\n")
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	got := createOrder(&Schema{Tables: map[string]*Table{}}, to)
+	if !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("expected referenced table first, got %v", got)
+	}
+}
+
+func TestWriteDiffScript(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001_create_users.sql", "0042_add_orders.sql", "R__views.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := writeDiffScript(dir, "Add Email Column", "latest.sql", []string{"ALTER TABLE `users` ADD COLUMN `email` TEXT;"})
+	if err != nil {
+		t.Fatalf("writeDiffScript failed: %v", err)
+	}
+	if filepath.Base(path) != "0043_add_email_column.sql" {
+		t.Errorf("unexpected script name %s", filepath.Base(path))
+	}
+}
@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// Seed runs the seed data scripts on their own, without touching schema history
+// With reseed, every seed script runs again even if its content is unchanged
+func (m *Migrator) Seed(reseed bool) error {
+	m.console.Header("DB Seeding Started")
+	m.forgetCommit()
+	m.batchID = m.ids.NewID()
+	unlock, err := m.lockRun()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if m.config.SeedDir == "" {
+		return fmt.Errorf("no seed directory configured (use --seed-dir)")
+	}
+
+	if err := m.runSeeds(reseed); err != nil {
+		return err
+	}
+
+	m.console.Success("Seeding completed successfully!")
+	return nil
+}
+
+// runSeeds executes new or changed seed scripts in name order, tracked in the seed table
+func (m *Migrator) runSeeds(reseed bool) error {
+	if err := m.seedTracker.EnsureTable(); err != nil {
+		return err
+	}
+
+	scripts, err := m.pendingSeeds(reseed)
+	if err != nil {
+		return err
+	}
+	if len(scripts) == 0 {
+		m.console.Success("No seed scripts to execute")
+		return nil
+	}
+
+	m.console.Info("Found %d seed scripts to execute", len(scripts))
+
+	if err := m.loadDirectives(scripts); err != nil {
+		return err
+	}
+
+	// Seeds are recorded against the current commit when the seed directory is in git
+	gitID := ""
+	if commit, err := git.New(m.config.SeedDir, m.console.Logger()).GetCurrentCommit(); err == nil {
+		gitID = commit
+	}
+
+	successCount := 0
+	failedCount := 0
+	for i, script := range scripts {
+		m.console.Script(script.Name, "executing")
+
+		rec := ScriptRecord{ScriptName: script.Name, EndOfBatch: i == len(scripts)-1, LastGitID: gitID}
+		tolerated, err := m.executeWithPolicy(m.seedTracker, script, rec)
+		if err != nil {
+			m.console.Script(script.Name, "failed")
+			m.console.Error("Seed script failed: %v", err)
+			m.console.Summary(len(scripts), successCount, failedCount+1, 0, nil)
+			return fmt.Errorf("seeding failed at script: %s", script.Name)
+		}
+		if tolerated {
+			m.console.Script(script.Name, "failed")
+			failedCount++
+			continue
+		}
+
+		m.console.Script(script.Name, "success")
+		successCount++
+	}
+
+	m.console.Summary(len(scripts), successCount, failedCount, 0, nil)
+	return nil
+}
+
+// pendingSeeds lists seed scripts that have never run or whose content changed since
+// their last successful run (all of them when reseeding)
+func (m *Migrator) pendingSeeds(reseed bool) ([]git.ScriptInfo, error) {
+	dir, err := filepath.Abs(m.config.SeedDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve seed directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed directory: %w", err)
+	}
+
+	applied, err := m.seedTracker.GetAppliedScripts()
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string)
+	for _, rec := range applied {
+		checksums[rec.ScriptName] = rec.Checksum
+	}
+
+	var scripts []git.ScriptInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !git.IsScript(entry.Name()) || git.IsDownScript(entry.Name()) {
+			continue
+		}
+
+		script := git.ScriptInfo{
+			Name: entry.Name(),
+			Path: filepath.Join(dir, entry.Name()),
+		}
+		if !reseed {
+			content, err := m.readScript(script)
+			if err != nil {
+				return nil, err
+			}
+			checksum, err := m.scriptChecksum(script, content)
+			if err != nil {
+				return nil, err
+			}
+			if checksums[script.Name] == checksum {
+				continue
+			}
+		}
+
+		scripts = append(scripts, script)
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Name < scripts[j].Name
+	})
+
+	return scripts, nil
+}
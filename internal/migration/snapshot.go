@@ -0,0 +1,109 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// autoIncrementPattern matches the table option that changes with every insert
+var autoIncrementPattern = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// DumpSchema returns the CREATE statements of every table and view in the current
+// database, sorted by name, skipping the excluded tables
+// AUTO_INCREMENT counters are removed so snapshots only differ when the schema does
+func DumpSchema(database *db.DB, exclude ...string) (string, error) {
+	return dumpSchema(database, "", exclude)
+}
+
+// dumpSchema dumps the named database, or the current one when schema is empty
+func dumpSchema(database *db.DB, schema string, exclude []string) (string, error) {
+	from, qualifier := "", ""
+	if schema != "" {
+		from, qualifier = " FROM "+quoteIdentifier(schema), quoteIdentifier(schema)+"."
+	}
+
+	rows, err := database.Query("SHOW FULL TABLES" + from)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	skip := make(map[string]bool)
+	for _, name := range exclude {
+		skip[strings.ToLower(name)] = true
+	}
+
+	type object struct{ name, kind string }
+	var objects []object
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			return "", fmt.Errorf("failed to scan table: %w", err)
+		}
+		if !skip[strings.ToLower(name)] {
+			objects = append(objects, object{name, kind})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].name < objects[j].name
+	})
+
+	var b strings.Builder
+	for _, obj := range objects {
+		var ddl string
+		if obj.kind == "VIEW" {
+			var view, charset, collation string
+			err = database.QueryRow("SHOW CREATE VIEW "+qualifier+quoteIdentifier(obj.name)).Scan(&view, &ddl, &charset, &collation)
+		} else {
+			var table string
+			err = database.QueryRow("SHOW CREATE TABLE "+qualifier+quoteIdentifier(obj.name)).Scan(&table, &ddl)
+			ddl = autoIncrementPattern.ReplaceAllString(ddl, "")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read definition of %s: %w", obj.name, err)
+		}
+		b.WriteString(ddl)
+		b.WriteString(";\n\n")
+	}
+
+	return b.String(), nil
+}
+
+// writeSnapshot saves the schema after a batch to <snapshot dir>/<batch id>.sql,
+// and to latest.sql for easy diffing in code review
+func (m *Migrator) writeSnapshot(commit string) error {
+	if m.config.SnapshotDir == "" {
+		return nil
+	}
+
+	schema, err := DumpSchema(m.db, ScriptTableName, SeedTableName)
+	if err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf("-- Schema of %s after batch %s\n-- Commit: %s\n-- Taken: %s\n\n",
+		m.config.DBName, m.batchID, commit, m.clock.Now().UTC().Format("2006-01-02 15:04:05"))
+
+	if err := os.MkdirAll(m.config.SnapshotDir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	path := filepath.Join(m.config.SnapshotDir, m.batchID+".sql")
+	if err := os.WriteFile(path, []byte(header+schema), 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(m.config.SnapshotDir, "latest.sql"), []byte(schema), 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	m.console.Info("Schema snapshot written to %s", path)
+	return nil
+}
@@ -0,0 +1,88 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Status prints the last completed batch and a table of the applied, skipped, failed and
+// pending scripts with their commits and timestamps, followed by the errors of the failed
+// ones. Like Inspector, it never writes the tracking table
+func (m *Migrator) Status() error {
+	m.console.Header("DB Migration Status")
+	if err := m.validator.ValidateScriptsDirectory(); err != nil {
+		return err
+	}
+	inspector := m.inspector()
+	ctx := m.ctx
+
+	batch, err := inspector.LastBatch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get last batch: %w", err)
+	}
+	if batch == nil {
+		m.console.Info("No batch has completed yet")
+	} else {
+		m.console.Info("Last batch %s at commit %s: %d scripts", batch.ID, shortCommit(batch.GitID), len(batch.Scripts))
+	}
+
+	statuses, err := inspector.Scripts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(statuses) > 0 {
+		rows := make([][]string, len(statuses))
+		for i, s := range statuses {
+			rows[i] = []string{s.Script, s.Status, shortCommit(s.Commit), s.Timestamp.UTC().Format("2006-01-02 15:04:05")}
+		}
+		m.console.Table([]string{"SCRIPT", "STATUS", "COMMIT", "TIMESTAMP"}, rows)
+	}
+
+	pending, failed := 0, 0
+	for _, s := range statuses {
+		switch s.Status {
+		case StatusPending:
+			pending++
+		case StatusFailed:
+			failed++
+			if s.Error != "" {
+				m.console.Warn("%s failed: %s", s.Script, s.Error)
+			}
+		}
+	}
+	if failed > 0 {
+		m.console.Warn("%d scripts failed", failed)
+	}
+	if pending > 0 {
+		m.console.Info("%d scripts pending", pending)
+	}
+	if pending == 0 && failed == 0 {
+		m.console.Success("Database is up to date")
+	}
+	return nil
+}
+
+// inspector returns an Inspector sharing the migrator's repository and tracker
+func (m *Migrator) inspector() *Inspector {
+	return &Inspector{config: m.config, git: m.git, tracker: m.tracker}
+}
+
+// WriteHistory writes the records of the tracking table as a table, oldest first
+func WriteHistory(w io.Writer, records []ScriptRecord) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SNO\tBATCH\tSCRIPT\tACTION\tCOMPLETED\tCOMMIT\tEXECUTED")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%t\t%s\t%s\n", r.SNO, r.BatchID, r.ScriptName, r.Action, r.Completed,
+			shortCommit(r.LastGitID), r.CreatedDateTime.UTC().Format("2006-01-02 15:04:05"))
+	}
+	return tw.Flush()
+}
+
+// shortCommit abbreviates a commit hash as git log --oneline does
+func shortCommit(commit string) string {
+	if len(commit) > 8 {
+		return commit[:8]
+	}
+	return commit
+}
@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	m, repo, scriptsDir, cons := newGoldenMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts")
+
+	before := cons.Output()
+	if err := m.Status(); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	golden(t, "status.txt", []byte(strings.TrimPrefix(cons.Output(), before)))
+}
+
+func TestValidate(t *testing.T) {
+	m, repo, scriptsDir, _ := newGoldenMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	problems, err := m.Validate()
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+	if exists, err := m.tracker.TableExists(context.Background()); err != nil || exists {
+		t.Errorf("expected validate to leave the tracking table uncreated, got %v, %v", exists, err)
+	}
+
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	repo.ModifyFile("scripts/001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")
+	repo.CommitScripts("change users")
+	problems, err = m.Validate()
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if len(problems) != 1 {
+		t.Errorf("expected the modified script to be reported, got %v", problems)
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	m, repo, scriptsDir, cons := newGoldenMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	cons.Clock.Advance(time.Hour)
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "003_tags.sql", "CREATE TABLE tags (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add posts and tags")
+	if err := m.Run(); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+
+	records, err := m.inspector().History(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteHistory(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "history.txt", buf.Bytes())
+}
+
+func TestInspector_Scripts(t *testing.T) {
+	m, repo, scriptsDir, _ := newFakeMigrator(t)
+
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+	if err := m.Run(); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	repo.AddSQLScript(scriptsDir, "002_broken.sql", "CREATE TABLEE broken;")
+	repo.AddSQLScript(scriptsDir, "003_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add broken and posts")
+	if err := m.Run(); err == nil {
+		t.Fatal("expected the broken script to fail")
+	}
+
+	statuses, err := m.inspector().Scripts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range statuses {
+		got = append(got, s.Script+" "+s.Status)
+	}
+	want := "001_users.sql applied, 002_broken.sql failed, 003_posts.sql pending"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(got, ", "))
+	}
+	if !strings.Contains(statuses[1].Error, "syntax error") {
+		t.Errorf("expected the error of the broken script, got %q", statuses[1].Error)
+	}
+}
@@ -0,0 +1,102 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// deferredByTags reports whether the --tags/--skip-tags filter holds a script back
+func (m *Migrator) deferredByTags(script git.ScriptInfo) bool {
+	for _, tag := range m.config.SkipTags {
+		if script.Directives.HasTag(tag) {
+			return true
+		}
+	}
+	if len(m.config.Tags) == 0 {
+		return false
+	}
+	for _, tag := range m.config.Tags {
+		if script.Directives.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// heldForWindow reports whether a script carrying a --window-tags tag must wait for the
+// maintenance window; it is checked as each script starts, so none starts after it closed
+func (m *Migrator) heldForWindow(script git.ScriptInfo) bool {
+	if m.config.Window == nil || m.config.Window.Contains(m.clock.Now()) {
+		return false
+	}
+	for _, tag := range m.config.WindowTags {
+		if script.Directives.HasTag(tag) {
+			m.heldBack++
+			return true
+		}
+	}
+	return false
+}
+
+// HeldForWindow returns how many scripts the last Run held back until the maintenance window
+func (m *Migrator) HeldForWindow() int {
+	return m.heldBack
+}
+
+// recordDeferred records a script held back by the tag filter
+// The row keeps the batch consistent without marking the script applied
+func (m *Migrator) recordDeferred(t *Tracker, script git.ScriptInfo, rec ScriptRecord) error {
+	content, err := m.readScript(script)
+	if err != nil {
+		return err
+	}
+
+	rec.Completed = true
+	rec.Action = ActionDefer
+	rec.BatchID = m.batchID
+	rec.Checksum, err = m.scriptChecksum(script, content)
+	if err != nil {
+		return err
+	}
+	return t.RecordExecutionDirect(rec)
+}
+
+// deferredScripts returns scripts deferred by earlier runs that are still unapplied
+// Git discovery only sees changes since the last batch, so these are added back explicitly
+func (m *Migrator) deferredScripts(discovered []git.ScriptInfo, executed map[string]bool) ([]git.ScriptInfo, error) {
+	names, err := m.tracker.GetDeferredScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, script := range discovered {
+		known[script.Name] = true
+	}
+
+	var scripts []git.ScriptInfo
+	for _, name := range names {
+		// Repeatable scripts stay pending through their checksum
+		if known[name] || executed[name] || git.IsRepeatable(name) {
+			continue
+		}
+
+		path, err := filepath.Abs(filepath.Join(m.config.ScriptsDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			m.console.Warn("Deferred script %s no longer exists", name)
+			continue
+		}
+
+		timestamp, err := m.git.GetFileCommitTimestamp(path)
+		if err != nil {
+			timestamp = m.clock.Now()
+		}
+		scripts = append(scripts, git.ScriptInfo{Name: name, Path: path, Timestamp: timestamp})
+	}
+	return scripts, nil
+}
@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// TemplateContext is the data available to .sql.tmpl scripts
+type TemplateContext struct {
+	Environment   string            // --env
+	Schema        string            // Target database name
+	Tenant        string            // --tenant
+	ServerVersion string            // Result of SELECT VERSION()
+	Vars          map[string]string // Placeholder values (--var and DB_MIGRATION_VAR_*)
+}
+
+// RenderTemplate renders a SQL template; referencing a missing key is an error
+func RenderTemplate(name, content string, data TemplateContext) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// templateContext builds the template data, querying the server version once per Migrator
+func (m *Migrator) templateContext() (TemplateContext, error) {
+	if m.serverVersion == "" {
+		version, err := m.db.ServerVersion()
+		if err != nil {
+			return TemplateContext{}, err
+		}
+		m.serverVersion = version
+	}
+
+	return TemplateContext{
+		Environment:   m.config.Environment,
+		Schema:        m.config.DBName,
+		Tenant:        m.config.Tenant,
+		ServerVersion: m.serverVersion,
+		Vars:          m.config.Vars,
+	}, nil
+}
+
+// archiveRendered writes the rendered SQL of a template into the archive directory,
+// under a subdirectory per batch, so audits can see exactly what was executed
+func (m *Migrator) archiveRendered(script git.ScriptInfo, content []byte) error {
+	if m.config.RenderArchiveDir == "" || !git.IsTemplate(script.Name) {
+		return nil
+	}
+
+	dir := filepath.Join(m.config.RenderArchiveDir, m.batchID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create render archive directory: %w", err)
+	}
+
+	name := strings.TrimSuffix(script.Name, ".tmpl")
+	if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+		return fmt.Errorf("failed to archive rendered %s: %w", script.Name, err)
+	}
+
+	return nil
+}
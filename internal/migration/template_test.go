@@ -0,0 +1,31 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRenderTemplate tests rendering a SQL template against the typed context
+func TestRenderTemplate(t *testing.T) {
+	content := `CREATE TABLE {{.Vars.PREFIX}}events (id INT){{if eq .Environment "prod"}} ENGINE=InnoDB{{end}};
+-- schema {{.Schema}} tenant {{.Tenant}}`
+	data := TemplateContext{
+		Environment: "prod",
+		Schema:      "appdb",
+		Tenant:      "acme",
+		Vars:        map[string]string{"PREFIX": "acme_"},
+	}
+
+	got, err := RenderTemplate("001_events.sql.tmpl", content, data)
+	if err != nil {
+		t.Fatalf("RenderTemplate failed: %v", err)
+	}
+	want := "CREATE TABLE acme_events (id INT) ENGINE=InnoDB;\n-- schema appdb tenant acme"
+	if got != want {
+		t.Errorf("unexpected result:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := RenderTemplate("bad.sql.tmpl", "{{.Vars.MISSING}}", data); err == nil || !strings.Contains(err.Error(), "MISSING") {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+-- db-migration plan for app: 2 to create, 1 to update, risk high
+-- dbmig:commit 2222222
+-- Applied up to commit 1111111
+-- error: script 003_old.sql was modified after it ran
+-- Run the scripts in order, then record them with: db-migration mark-applied <this file> ...
+
+-- dbmig:begin 001_users.sql action=create risk=low checksum=aaaa
+CREATE TABLE users (id INT);
+-- dbmig:end 001_users.sql
+
+-- dbmig:begin 002_drop_legacy.sql action=create risk=high checksum=bbbb
+-- drops a table
+DROP TABLE legacy;
+-- dbmig:end 002_drop_legacy.sql
+
+-- dbmig:begin R__views.sql action=update risk=medium checksum=cccc
+-- changed repeatable script
+CREATE OR REPLACE VIEW v AS SELECT 1;
+-- dbmig:end R__views.sql
@@ -0,0 +1,150 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// maxTicketsLength is the size of the tickets tracking column
+const maxTicketsLength = 255
+
+// ticketClient posts ticket comments; a slow issue tracker must not hold up the run for long
+var ticketClient = &http.Client{Timeout: 30 * time.Second}
+
+// findTickets fills in the issue IDs of scripts from their names and the messages
+// of the commits that changed them
+func (m *Migrator) findTickets(scripts []git.ScriptInfo) {
+	if m.config.TicketPattern == nil {
+		return
+	}
+	for i := range scripts {
+		sources := []string{scripts[i].Name}
+		// Scripts that are not committed yet only have their name to go by
+		if messages, err := m.git.CommitMessages(scripts[i].Path); err == nil {
+			sources = append(sources, messages...)
+		}
+		scripts[i].Tickets = matchTickets(m.config.TicketPattern, sources)
+	}
+}
+
+// matchTickets returns the distinct matches of pattern in texts, in order of appearance
+// If the pattern has a group, the first group is the ticket ID
+func matchTickets(pattern *regexp.Regexp, texts []string) []string {
+	var tickets []string
+	for _, text := range texts {
+		for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+			ticket := match[0]
+			if len(match) > 1 {
+				ticket = match[1]
+			}
+			if ticket != "" && !containsName(tickets, ticket) {
+				tickets = append(tickets, ticket)
+			}
+		}
+	}
+	return tickets
+}
+
+// joinTickets returns the tickets column value, dropping IDs that do not fit
+func joinTickets(tickets []string) string {
+	joined := ""
+	for _, ticket := range tickets {
+		next := ticket
+		if joined != "" {
+			next = joined + "," + ticket
+		}
+		if len(next) > maxTicketsLength {
+			break
+		}
+		joined = next
+	}
+	return joined
+}
+
+// commentOnTickets posts a comment to each ticket of the scripts applied by the batch
+// when running in the --ticket-comment-env environment; failures are only warnings,
+// since the scripts have already been applied
+func (m *Migrator) commentOnTickets(commit string) {
+	if m.config.TicketCommentURL == "" || !strings.EqualFold(m.config.Environment, m.config.TicketCommentEnv) {
+		return
+	}
+
+	var order []string
+	scripts := make(map[string][]string)
+	for _, r := range m.results {
+		if r.Status != ResultSuccess {
+			continue
+		}
+		for _, ticket := range r.Tickets {
+			if _, ok := scripts[ticket]; !ok {
+				order = append(order, ticket)
+			}
+			scripts[ticket] = append(scripts[ticket], r.Name)
+		}
+	}
+
+	for _, ticket := range order {
+		values := map[string]string{
+			"ticket":   ticket,
+			"scripts":  strings.Join(scripts[ticket], ", "),
+			"database": m.config.DBName,
+			"env":      m.config.Environment,
+			"batch":    m.batchID,
+			"commit":   commit,
+		}
+		if err := m.postTicketComment(values); err != nil {
+			m.console.Warn("Could not comment on %s: %v", ticket, err)
+			continue
+		}
+		m.console.Info("Commented on %s", ticket)
+	}
+}
+
+// postTicketComment sends the comment request for one ticket
+func (m *Migrator) postTicketComment(values map[string]string) error {
+	target := expandTicketTemplate(m.config.TicketCommentURL, values, url.PathEscape)
+	body := expandTicketTemplate(m.config.TicketCommentBody, values, jsonEscape)
+
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.config.TicketAuth != "" {
+		req.Header.Set("Authorization", m.config.TicketAuth)
+	}
+
+	resp, err := ticketClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", target, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// expandTicketTemplate substitutes {name} placeholders, escaping values for their context
+func expandTicketTemplate(template string, values map[string]string, escape func(string) string) string {
+	pairs := make([]string, 0, 2*len(values))
+	for name, value := range values {
+		pairs = append(pairs, "{"+name+"}", escape(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// jsonEscape escapes a value for use inside a JSON string literal
+func jsonEscape(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted[1 : len(quoted)-1])
+}
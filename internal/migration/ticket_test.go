@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+)
+
+func TestMatchTickets(t *testing.T) {
+	jira := regexp.MustCompile(`[A-Z][A-Z0-9]+-\d+`)
+	got := matchTickets(jira, []string{"005_SHOP-12_add_orders.sql", "Add orders table\n\nRefs SHOP-12, OPS-7"})
+	if want := []string{"SHOP-12", "OPS-7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matchTickets() = %v, want %v", got, want)
+	}
+
+	grouped := regexp.MustCompile(`#(\d+)`)
+	if got := matchTickets(grouped, []string{"Fix index (#42)"}); !reflect.DeepEqual(got, []string{"42"}) {
+		t.Errorf("expected the first group as ticket ID, got %v", got)
+	}
+}
+
+func TestJoinTickets(t *testing.T) {
+	if got := joinTickets([]string{"A-1", "B-2"}); got != "A-1,B-2" {
+		t.Errorf("joinTickets() = %q", got)
+	}
+	long := []string{strings.Repeat("A", 250) + "-1", "B-2"}
+	if got := joinTickets(long); got != long[0] {
+		t.Errorf("expected tickets that do not fit to be dropped, got %q", got)
+	}
+}
+
+func TestCommentOnTickets(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests[r.URL.Path] = string(body)
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	m := &Migrator{
+		config: &config.Config{
+			DBName:            "shop",
+			Environment:       "PROD",
+			TicketCommentURL:  server.URL + "/issue/{ticket}/comment",
+			TicketCommentBody: config.DefaultTicketCommentBody,
+			TicketCommentEnv:  "prod",
+			TicketAuth:        "Bearer secret",
+		},
+		console: console.New(),
+		batchID: "b1",
+		results: []ScriptResult{
+			{Name: `001_"quoted".sql`, Tickets: []string{"SHOP-1"}, Status: ResultSuccess},
+			{Name: "002_b.sql", Tickets: []string{"SHOP-1", "SHOP-2"}, Status: ResultSuccess},
+			{Name: "003_c.sql", Tickets: []string{"SHOP-3"}, Status: ResultSkipped},
+		},
+	}
+	m.commentOnTickets("abc123")
+
+	if len(requests) != 2 {
+		t.Fatalf("expected comments on SHOP-1 and SHOP-2, got %v", requests)
+	}
+	var comment struct{ Body string }
+	if err := json.Unmarshal([]byte(requests["/issue/SHOP-1/comment"]), &comment); err != nil {
+		t.Fatalf("invalid comment body: %v", err)
+	}
+	if want := `001_"quoted".sql, 002_b.sql applied to shop (PROD) in batch b1 at commit abc123`; comment.Body != want {
+		t.Errorf("unexpected comment %q, want %q", comment.Body, want)
+	}
+
+	// Other environments do not comment
+	requests = make(map[string]string)
+	m.config.Environment = "staging"
+	m.commentOnTickets("abc123")
+	if len(requests) != 0 {
+		t.Errorf("expected no comments outside prod, got %v", requests)
+	}
+}
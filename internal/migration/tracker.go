@@ -1,10 +1,12 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/bontaramsonta/db-migration/internal/chaos"
 	"github.com/bontaramsonta/db-migration/internal/db"
 )
 
@@ -12,8 +14,29 @@ import (
 type Tracker struct {
 	db        *db.DB
 	tableName string
+	clock     Clock
+	faults    *chaos.Faults
+	executor  Executor
+	// namespace keeps the records of one scripts directory apart from those of the
+	// others sharing the table, see config.ScriptsDir
+	namespace string
+	// absent is set when a run that only reads the table found none, see
+	// Migrator.prepareTracker; reads then find nothing applied
+	absent bool
 }
 
+// Actions recorded in the tracking table
+const (
+	ActionUp    = "up"
+	ActionDown  = "down"
+	ActionSkip  = "skip"  // Not executed because its skip-if guard matched
+	ActionRerun = "rerun" // Re-executed by the rerun command; does not change what is applied
+	ActionDefer = "defer" // Held back by a tag filter; pending again on later runs
+)
+
+// recordColumns selects a ScriptRecord in scanRecords order
+const recordColumns = "sno, scriptName, completed, endofbatch, COALESCE(lastgitid, ''), COALESCE(batchid, ''), action, COALESCE(checksum, ''), COALESCE(fingerprint, ''), COALESCE(tickets, ''), COALESCE(approvedby, ''), COALESCE(failure, ''), COALESCE(duration_ms, 0), COALESCE(executed_by, ''), COALESCE(executed_host, ''), COALESCE(tool_version, ''), COALESCE(error_message, ''), createddatetime, modifieddatetime"
+
 // ScriptRecord represents a record in the tracking table
 type ScriptRecord struct {
 	SNO              int
@@ -21,52 +44,186 @@ type ScriptRecord struct {
 	Completed        bool
 	EndOfBatch       bool
 	LastGitID        string
+	BatchID          string
+	Action           string // ActionUp, ActionDown, ActionSkip, ActionRerun or ActionDefer
+	Checksum         string // SHA-256 of the executed content
+	Fingerprint      string // SHA-256 of the normalized statements, see Fingerprint
+	Tickets          string // Comma-separated issue IDs, see --ticket-pattern
+	ApprovedBy       string // Approver of the batch, see --approval-webhook
+	Failure          string // FailureError, FailureTimeout or FailureCancelled when not completed
+	DurationMS       int64  // Execution time in milliseconds; 0 for records of scripts that did not execute
+	ExecutedBy       string // OS user of the run, see Executor
+	ExecutedHost     string // Hostname of the run
+	ToolVersion      string // Version of the tool that wrote the record
+	ErrorMessage     string // Error of a failed attempt
 	CreatedDateTime  time.Time
 	ModifiedDateTime time.Time
 }
 
-// NewTracker creates a new Tracker instance
-func NewTracker(database *db.DB) *Tracker {
+// Failure reasons of records that did not complete
+const (
+	FailureError     = "error"     // A statement failed
+	FailureTimeout   = "timeout"   // A statement outlived the timeout directive or --statement-timeout
+	FailureCancelled = "cancelled" // The run was stopped, e.g. by SIGINT or SIGTERM
+)
+
+// Tracking table names
+const (
+	ScriptTableName = "sqlScriptExec" // Schema migrations
+	SeedTableName   = "sqlSeedExec"   // Seed data, kept apart from schema history
+)
+
+// NewTracker creates a new Tracker instance for schema migrations
+func NewTracker(database *db.DB, opts ...Option) *Tracker {
+	return newTracker(database, ScriptTableName, opts)
+}
+
+// NewSeedTracker creates a Tracker for seed data scripts
+func NewSeedTracker(database *db.DB, opts ...Option) *Tracker {
+	return newTracker(database, SeedTableName, opts)
+}
+
+// WithTrackerDB makes a Migrator record its tracking tables on a separate connection,
+// e.g. of a user allowed to write them while the executing user is not
+func WithTrackerDB(database *db.DB) Option {
+	return func(o *options) {
+		o.trackerDB = database
+	}
+}
+
+func newTracker(database *db.DB, tableName string, opts []Option) *Tracker {
+	o := buildOptions(opts)
 	return &Tracker{
 		db:        database,
-		tableName: "sqlScriptExec",
+		tableName: tableName,
+		clock:     o.clock,
+		faults:    o.faults,
+		executor:  o.executor,
 	}
 }
 
 // EnsureTable creates the tracking table if it doesn't exist
 func (t *Tracker) EnsureTable() error {
+	t.absent = false
+	dialect := t.db.Dialect()
+	modified := "NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	if t.db.Driver() == db.DriverMySQL {
+		modified += " ON UPDATE CURRENT_TIMESTAMP"
+	}
 	query := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			sno INT(11) PRIMARY KEY AUTO_INCREMENT,
+		%[1]s (
+			sno %[2]s,
 			scriptName VARCHAR(500) NOT NULL,
-			completed BOOLEAN,
-			endofbatch BOOLEAN,
+			completed %[5]s,
+			endofbatch %[5]s,
 			lastgitid VARCHAR(70),
-			createddatetime DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			modifieddatetime DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			batchid VARCHAR(64),
+			action VARCHAR(10) NOT NULL DEFAULT 'up',
+			checksum VARCHAR(64),
+			fingerprint VARCHAR(64),
+			tickets VARCHAR(255),
+			approvedby VARCHAR(255),
+			failure VARCHAR(10),
+			duration_ms INT,
+			executed_by VARCHAR(255),
+			executed_host VARCHAR(255),
+			tool_version VARCHAR(64),
+			error_message %[6]s,
+			namespace VARCHAR(100) NOT NULL DEFAULT '',
+			createddatetime %[3]s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			modifieddatetime %[3]s %[4]s
 		)
-	`, t.tableName)
+	`, dialect.CreateTableIfNotExists(t.tableName), dialect.SerialKey(), dialect.Timestamp(), modified, dialect.Boolean(), dialect.Text())
 
 	_, err := t.db.Exec(query)
 	if err != nil {
 		return fmt.Errorf("failed to create tracking table: %w", err)
 	}
 
+	// Upgrade tables created by older versions of the tool
+	if err := t.ensureColumn("failure", "VARCHAR(10)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("duration_ms", "INT"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("executed_by", "VARCHAR(255)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("executed_host", "VARCHAR(255)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("tool_version", "VARCHAR(64)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("error_message", dialect.Text()); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("namespace", "VARCHAR(100) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	// Older versions still only supported MySQL
+	if t.db.Driver() != db.DriverMySQL {
+		return nil
+	}
+	if err := t.ensureColumn("batchid", "VARCHAR(64)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("action", "VARCHAR(10) NOT NULL DEFAULT 'up'"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("checksum", "VARCHAR(64)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("fingerprint", "VARCHAR(64)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("tickets", "VARCHAR(255)"); err != nil {
+		return err
+	}
+	if err := t.ensureColumn("approvedby", "VARCHAR(255)"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ensureColumn adds a column to the tracking table if it is missing
+func (t *Tracker) ensureColumn(name, definition string) error {
+	dialect := t.db.Dialect()
+	var count int
+	err := t.db.QueryRow(dialect.ColumnExistsQuery(), t.tableName, name).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to inspect tracking table column %s: %w", name, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := t.db.Exec(dialect.AddColumn(t.tableName, name, definition)); err != nil {
+		return fmt.Errorf("failed to add tracking table column %s: %w", name, err)
+	}
+
 	return nil
 }
 
 // GetLastSuccessfulCommit returns the git commit ID of the last successful batch
-// (where endofbatch = 1)
+// (where endofbatch is true)
 func (t *Tracker) GetLastSuccessfulCommit() (string, error) {
+	if t.absent {
+		return "", nil
+	}
+	dialect := t.db.Dialect()
 	query := fmt.Sprintf(`
 		SELECT lastgitid FROM %s 
-		WHERE endofbatch = 1 
+		WHERE endofbatch = %s AND namespace = ?
 		ORDER BY sno DESC 
-		LIMIT 1
-	`, t.tableName)
+		%s
+	`, t.tableName, dialect.Bool(true), dialect.Limit(1))
 
 	var lastGitID sql.NullString
-	err := t.db.QueryRow(query).Scan(&lastGitID)
+	err := t.db.QueryRow(dialect.Rebind(query), t.namespace).Scan(&lastGitID)
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
@@ -81,73 +238,261 @@ func (t *Tracker) GetLastSuccessfulCommit() (string, error) {
 	return lastGitID.String, nil
 }
 
-// GetExecutedScriptNames returns all script names that have been executed
+// GetExecutedScriptNames returns all script names that are currently applied
+// A script that was rolled back by a down migration is no longer considered executed
+// Only names and actions are read, since every run and plan asks over the whole history
 func (t *Tracker) GetExecutedScriptNames() (map[string]bool, error) {
+	if t.absent {
+		return map[string]bool{}, nil
+	}
 	query := fmt.Sprintf(`
-		SELECT scriptName FROM %s WHERE completed = 1
-	`, t.tableName)
+		SELECT sno, scriptName, action
+		FROM %s
+		WHERE completed = %s AND namespace = ?
+		ORDER BY sno ASC
+	`, t.tableName, t.db.Dialect().Bool(true))
 
-	rows, err := t.db.Query(query)
+	rows, err := t.db.Query(t.db.Dialect().Rebind(query), t.namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get executed scripts: %w", err)
 	}
 	defer rows.Close()
 
-	executed := make(map[string]bool)
+	var records []ScriptRecord
 	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, fmt.Errorf("failed to scan script name: %w", err)
+		var rec ScriptRecord
+		if err := rows.Scan(&rec.SNO, &rec.ScriptName, &rec.Action); err != nil {
+			return nil, fmt.Errorf("failed to scan script record: %w", err)
 		}
-		executed[name] = true
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get executed scripts: %w", err)
+	}
+
+	executed := make(map[string]bool)
+	for _, rec := range replayApplied(records) {
+		executed[rec.ScriptName] = true
 	}
 
 	return executed, nil
 }
 
-// RecordExecution inserts a record for script execution
-func (t *Tracker) RecordExecution(tx *sql.Tx, scriptName string, completed bool, endOfBatch bool, gitID string) error {
+// GetAppliedScripts returns the successful up records of scripts that are currently applied,
+// ordered by execution (sno); scripts reverted by a later down record are excluded
+func (t *Tracker) GetAppliedScripts() ([]ScriptRecord, error) {
+	if t.absent {
+		return nil, nil
+	}
 	query := fmt.Sprintf(`
-		INSERT INTO %s (scriptName, completed, endofbatch, lastgitid)
-		VALUES (?, ?, ?, ?)
-	`, t.tableName)
+		SELECT %s
+		FROM %s
+		WHERE completed = %s AND namespace = ?
+		ORDER BY sno ASC
+	`, recordColumns, t.tableName, t.db.Dialect().Bool(true))
 
-	_, err := tx.Exec(query, scriptName, completed, endOfBatch, gitID)
+	rows, err := t.db.Query(t.db.Dialect().Rebind(query), t.namespace)
 	if err != nil {
-		return fmt.Errorf("failed to record execution for %s: %w", scriptName, err)
+		return nil, fmt.Errorf("failed to get applied scripts: %w", err)
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return replayApplied(records), nil
+}
+
+// replayApplied replays completed up/down records, ordered by sno, to find the ones
+// of the scripts applied now
+func replayApplied(records []ScriptRecord) []ScriptRecord {
+	appliedAt := make(map[string]ScriptRecord)
+	for _, rec := range records {
+		if rec.Action == ActionDown {
+			delete(appliedAt, rec.ScriptName)
+			continue
+		}
+		if rec.Action == ActionRerun || rec.Action == ActionDefer {
+			continue
+		}
+		appliedAt[rec.ScriptName] = rec
+	}
+
+	var applied []ScriptRecord
+	for _, rec := range records {
+		if cur, ok := appliedAt[rec.ScriptName]; ok && cur.SNO == rec.SNO {
+			applied = append(applied, rec)
+		}
+	}
+
+	return applied
+}
+
+// RecordExecution inserts a record for script execution
+func (t *Tracker) RecordExecution(tx *sql.Tx, rec ScriptRecord) error {
+	t.faults.DelayTrackerWrite()
+	query, args := t.insertRecord(rec)
+
+	t.db.Trace(query, args...)
+	_, err := tx.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record execution for %s: %w", rec.ScriptName, err)
 	}
 
 	return nil
 }
 
 // RecordExecutionDirect inserts a record for script execution directly (no transaction)
-func (t *Tracker) RecordExecutionDirect(scriptName string, completed bool, endOfBatch bool, gitID string) error {
+func (t *Tracker) RecordExecutionDirect(rec ScriptRecord) error {
+	t.faults.DelayTrackerWrite()
+	query, args := t.insertRecord(rec)
+
+	_, err := t.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record execution for %s: %w", rec.ScriptName, err)
+	}
+
+	return nil
+}
+
+// RecordBatch inserts the records of a batch in one transaction, so a failed insert
+// leaves no partial batch behind
+func (t *Tracker) RecordBatch(recs []ScriptRecord) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, rec := range recs {
+		if err := t.RecordExecution(tx, rec); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// insertRecord builds the INSERT statement for a script record
+// Timestamps come from the tracker's clock so they can be faked in tests, and the
+// executor fills in who ran the script from where
+func (t *Tracker) insertRecord(rec ScriptRecord) (string, []interface{}) {
 	query := fmt.Sprintf(`
-		INSERT INTO %s (scriptName, completed, endofbatch, lastgitid)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO %s (scriptName, completed, endofbatch, lastgitid, batchid, action, checksum, fingerprint, tickets, approvedby, failure, duration_ms, executed_by, executed_host, tool_version, error_message, namespace, createddatetime, modifieddatetime)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, t.tableName)
 
-	_, err := t.db.Exec(query, scriptName, completed, endOfBatch, gitID)
+	action := rec.Action
+	if action == "" {
+		action = ActionUp
+	}
+
+	now := t.clock.Now()
+	return t.db.Dialect().Rebind(query), []interface{}{rec.ScriptName, rec.Completed, rec.EndOfBatch, rec.LastGitID, rec.BatchID, action, rec.Checksum, rec.Fingerprint, rec.Tickets, rec.ApprovedBy, rec.Failure, rec.DurationMS, t.executor.User, t.executor.Host, t.executor.Version, rec.ErrorMessage, t.namespace, now, now}
+}
+
+// ApplyRepair deletes the records and updates the values of a repair plan in one transaction
+func (t *Tracker) ApplyRepair(plan *RepairPlan) error {
+	tx, err := t.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to record execution for %s: %w", scriptName, err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	dialect := t.db.Dialect()
+	deleteQuery := dialect.Rebind(fmt.Sprintf("DELETE FROM %s WHERE sno = ?", t.tableName))
+	for _, rec := range plan.Failed {
+		t.db.Trace(deleteQuery, rec.SNO)
+		if _, err := tx.Exec(deleteQuery, rec.SNO); err != nil {
+			return fmt.Errorf("failed to delete record %d of %s: %w", rec.SNO, rec.ScriptName, err)
+		}
+	}
+	now := t.clock.Now()
+	for _, c := range plan.Changes {
+		query := dialect.Rebind(fmt.Sprintf("UPDATE %s SET %s = ?, modifieddatetime = ? WHERE sno = ?", t.tableName, c.Column))
+		t.db.Trace(query, c.To, now, c.SNO)
+		if _, err := tx.Exec(query, c.To, now, c.SNO); err != nil {
+			return fmt.Errorf("failed to update %s of record %d of %s: %w", c.Column, c.SNO, c.Script, err)
+		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit repair: %w", err)
+	}
 	return nil
 }
 
+// GetDeferredScripts returns the names of scripts deferred by a tag filter that
+// have not run since, in the order they were first deferred
+func (t *Tracker) GetDeferredScripts() ([]string, error) {
+	if t.absent {
+		return nil, nil
+	}
+	query := fmt.Sprintf(`
+		SELECT scriptName, action FROM %s
+		WHERE completed = %s AND namespace = ?
+		ORDER BY sno ASC
+	`, t.tableName, t.db.Dialect().Bool(true))
+
+	rows, err := t.db.Query(t.db.Dialect().Rebind(query), t.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deferred scripts: %w", err)
+	}
+	defer rows.Close()
+
+	var order []string
+	deferred := make(map[string]bool)
+	for rows.Next() {
+		var name, action string
+		if err := rows.Scan(&name, &action); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		switch action {
+		case ActionDefer:
+			if !deferred[name] {
+				order = append(order, name)
+			}
+			deferred[name] = true
+		case ActionUp, ActionSkip:
+			delete(deferred, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range order {
+		if deferred[name] && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
 // GetHalfCommittedScripts returns scripts executed after the last successful batch
 // These are scripts that were started but the batch didn't complete
 func (t *Tracker) GetHalfCommittedScripts() ([]ScriptRecord, error) {
+	if t.absent {
+		return nil, nil
+	}
 	// Find the SNO of the last successful batch
+	dialect := t.db.Dialect()
 	lastBatchQuery := fmt.Sprintf(`
 		SELECT sno FROM %s 
-		WHERE endofbatch = 1 
+		WHERE endofbatch = %s AND namespace = ?
 		ORDER BY sno DESC 
-		LIMIT 1
-	`, t.tableName)
+		%s
+	`, t.tableName, dialect.Bool(true), dialect.Limit(1))
 
 	var lastBatchSNO int
-	err := t.db.QueryRow(lastBatchQuery).Scan(&lastBatchSNO)
+	err := t.db.QueryRow(dialect.Rebind(lastBatchQuery), t.namespace).Scan(&lastBatchSNO)
 	if err == sql.ErrNoRows {
 		// No successful batch found, check if there are any records at all
 		lastBatchSNO = 0
@@ -157,36 +502,27 @@ func (t *Tracker) GetHalfCommittedScripts() ([]ScriptRecord, error) {
 
 	// Get all scripts after the last successful batch
 	query := fmt.Sprintf(`
-		SELECT sno, scriptName, completed, endofbatch, COALESCE(lastgitid, ''), createddatetime, modifieddatetime
+		SELECT %s
 		FROM %s 
-		WHERE sno > ?
+		WHERE sno > ? AND namespace = ?
 		ORDER BY sno ASC
-	`, t.tableName)
+	`, recordColumns, t.tableName)
 
-	rows, err := t.db.Query(query, lastBatchSNO)
+	rows, err := t.db.Query(t.db.Dialect().Rebind(query), lastBatchSNO, t.namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get half-committed scripts: %w", err)
 	}
 	defer rows.Close()
 
-	var scripts []ScriptRecord
-	for rows.Next() {
-		var rec ScriptRecord
-		if err := rows.Scan(&rec.SNO, &rec.ScriptName, &rec.Completed, &rec.EndOfBatch, &rec.LastGitID, &rec.CreatedDateTime, &rec.ModifiedDateTime); err != nil {
-			return nil, fmt.Errorf("failed to scan script record: %w", err)
-		}
-		scripts = append(scripts, rec)
-	}
-
-	return scripts, nil
+	return scanRecords(rows)
 }
 
 // HasRecords checks if the tracking table has any records
 func (t *Tracker) HasRecords() (bool, error) {
-	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, t.tableName)
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE namespace = ?`, t.tableName)
 
 	var count int
-	err := t.db.QueryRow(query).Scan(&count)
+	err := t.db.QueryRow(t.db.Dialect().Rebind(query), t.namespace).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to count records: %w", err)
 	}
@@ -197,26 +533,117 @@ func (t *Tracker) HasRecords() (bool, error) {
 // GetAllScripts returns all script records
 func (t *Tracker) GetAllScripts() ([]ScriptRecord, error) {
 	query := fmt.Sprintf(`
-		SELECT sno, scriptName, completed, endofbatch, COALESCE(lastgitid, ''), createddatetime, modifieddatetime
+		SELECT %s
 		FROM %s 
+		WHERE namespace = ?
 		ORDER BY sno ASC
-	`, t.tableName)
+	`, recordColumns, t.tableName)
 
-	rows, err := t.db.Query(query)
+	rows, err := t.db.Query(t.db.Dialect().Rebind(query), t.namespace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all scripts: %w", err)
 	}
 	defer rows.Close()
 
+	return scanRecords(rows)
+}
+
+// TableExists reports whether the tracking table has been created
+// Read-only callers use it to avoid failing on databases that were never migrated
+func (t *Tracker) TableExists(ctx context.Context) (bool, error) {
+	var count int
+	err := t.db.QueryRowContext(ctx, t.db.Dialect().TableExistsQuery(), t.tableName).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check tracking table: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// LastBatch returns the records of the most recent completed batch
+// (the rows after the previous endofbatch marker up to and including the last one)
+// Returns nil if no batch has completed yet
+func (t *Tracker) LastBatch(ctx context.Context) ([]ScriptRecord, error) {
+	dialect := t.db.Dialect()
+	query := fmt.Sprintf(`
+		SELECT sno FROM %s
+		WHERE endofbatch = %s AND namespace = ?
+		ORDER BY sno DESC
+		%s
+	`, t.tableName, dialect.Bool(true), dialect.Limit(2))
+
+	rows, err := t.db.QueryContext(ctx, dialect.Rebind(query), t.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch markers: %w", err)
+	}
+	var markers []int
+	for rows.Next() {
+		var sno int
+		if err := rows.Scan(&sno); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan batch marker: %w", err)
+		}
+		markers = append(markers, sno)
+	}
+	rows.Close()
+
+	if len(markers) == 0 {
+		return nil, nil
+	}
+
+	from := 0
+	if len(markers) == 2 {
+		from = markers[1]
+	}
+
+	query = fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE sno > ? AND sno <= ? AND namespace = ?
+		ORDER BY sno ASC
+	`, recordColumns, t.tableName)
+
+	rows, err = t.db.QueryContext(ctx, t.db.Dialect().Rebind(query), from, markers[0], t.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last batch: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// FailedScripts returns the failed attempts of scripts that have not completed since
+func (t *Tracker) FailedScripts(ctx context.Context) ([]ScriptRecord, error) {
+	query := fmt.Sprintf(`
+		SELECT %[2]s
+		FROM %[1]s f
+		WHERE f.completed = %[3]s AND f.namespace = ?
+		AND NOT EXISTS (
+			SELECT 1 FROM %[1]s s
+			WHERE s.scriptName = f.scriptName AND s.namespace = f.namespace AND s.action = f.action AND s.completed = %[4]s AND s.sno > f.sno
+		)
+		ORDER BY f.sno ASC
+	`, t.tableName, recordColumns, t.db.Dialect().Bool(false), t.db.Dialect().Bool(true))
+
+	rows, err := t.db.QueryContext(ctx, t.db.Dialect().Rebind(query), t.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed scripts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// scanRecords reads full tracking rows into ScriptRecords
+func scanRecords(rows *sql.Rows) ([]ScriptRecord, error) {
 	var scripts []ScriptRecord
 	for rows.Next() {
 		var rec ScriptRecord
-		if err := rows.Scan(&rec.SNO, &rec.ScriptName, &rec.Completed, &rec.EndOfBatch, &rec.LastGitID, &rec.CreatedDateTime, &rec.ModifiedDateTime); err != nil {
+		if err := rows.Scan(&rec.SNO, &rec.ScriptName, &rec.Completed, &rec.EndOfBatch, &rec.LastGitID, &rec.BatchID, &rec.Action, &rec.Checksum, &rec.Fingerprint, &rec.Tickets, &rec.ApprovedBy, &rec.Failure, &rec.DurationMS, &rec.ExecutedBy, &rec.ExecutedHost, &rec.ToolVersion, &rec.ErrorMessage, &rec.CreatedDateTime, &rec.ModifiedDateTime); err != nil {
 			return nil, fmt.Errorf("failed to scan script record: %w", err)
 		}
 		scripts = append(scripts, rec)
 	}
 
-	return scripts, nil
+	return scripts, rows.Err()
 }
-
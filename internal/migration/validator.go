@@ -3,25 +3,38 @@ package migration
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/bontaramsonta/db-migration/internal/config"
 	"github.com/bontaramsonta/db-migration/internal/console"
 	"github.com/bontaramsonta/db-migration/internal/git"
 )
 
 // Validator handles modification checks for scripts
 type Validator struct {
-	git     *git.Git
-	console *console.Console
+	git      Repository
+	console  *console.Console
+	findings []Finding
 }
 
 // NewValidator creates a new Validator instance
-func NewValidator(g *git.Git, c *console.Console) *Validator {
+func NewValidator(g Repository, c *console.Console) *Validator {
 	return &Validator{
 		git:     g,
 		console: c,
 	}
 }
 
+// Findings returns everything the checks reported so far
+func (v *Validator) Findings() []Finding {
+	return v.findings
+}
+
+// report records a finding for the SARIF log
+func (v *Validator) report(f Finding) {
+	v.findings = append(v.findings, f)
+}
+
 // CheckFileModifications checks if any previously executed scripts have been modified or deleted
 // Returns an error if modifications are detected (which should fail the migration)
 func (v *Validator) CheckFileModifications(fromCommit, toCommit string, executedScripts map[string]bool) error {
@@ -42,16 +55,27 @@ func (v *Validator) CheckFileModifications(fromCommit, toCommit string, executed
 	for file, status := range statusMap {
 		// Check if this file was previously executed
 		// Compare both full path and base filename since tracking table may store either
+		// Files of a bundle directory are tracked under the directory name
 		baseName := filepath.Base(file)
-		if !executedScripts[file] && !executedScripts[baseName] {
+		bundleName := filepath.Base(filepath.Dir(file))
+		if !executedScripts[file] && !executedScripts[baseName] && !(bundleDirPattern.MatchString(bundleName) && executedScripts[bundleName]) {
+			continue
+		}
+
+		// Repeatable scripts are expected to change; they re-run instead
+		if git.IsRepeatable(file) {
 			continue
 		}
 
 		switch status {
 		case "M":
 			modified = append(modified, file)
+			v.report(Finding{RuleID: RuleModifiedScript, Level: "error", Path: file,
+				Message: "Previously executed script was modified; add a new script instead"})
 		case "D":
 			deleted = append(deleted, file)
+			v.report(Finding{RuleID: RuleDeletedScript, Level: "error", Path: file,
+				Message: "Previously executed script was deleted"})
 		}
 	}
 
@@ -76,6 +100,23 @@ func (v *Validator) CheckFileModifications(fromCommit, toCommit string, executed
 	return nil
 }
 
+// CheckChecksums fails when applied scripts no longer have the content they were applied
+// with. It catches what a git diff misses: rewritten history, cherry-picks, and a
+// tracking table that diverged from the branch
+func (v *Validator) CheckChecksums(changed []string) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	v.console.Error("The following previously executed scripts no longer match their recorded checksums:")
+	for _, name := range changed {
+		v.console.Failure("  - %s", name)
+		v.report(Finding{RuleID: RuleModifiedScript, Level: "error", Path: name,
+			Message: "Previously executed script differs from the content it was applied with; add a new script instead"})
+	}
+	return fmt.Errorf("detected %d scripts whose checksum changed since they were executed - migration aborted", len(changed))
+}
+
 // CheckHalfCommittedFiles validates partial deployment state
 // If there are scripts executed after the last successful batch, they need special handling
 func (v *Validator) CheckHalfCommittedFiles(halfCommitted []ScriptRecord) error {
@@ -103,6 +144,30 @@ func (v *Validator) CheckHalfCommittedFiles(halfCommitted []ScriptRecord) error
 	return nil
 }
 
+// CheckScriptBudgets fails when any script exceeds the configured size or complexity limits
+// contents holds the final SQL of each script, in the same order as scripts
+func (v *Validator) CheckScriptBudgets(scripts []git.ScriptInfo, contents []string, budget config.Budget) error {
+	var offenders int
+	for i, script := range scripts {
+		violations := budgetViolations(AnalyzeScript(contents[i]), budget)
+		if len(violations) == 0 {
+			continue
+		}
+		if offenders == 0 {
+			v.console.Error("The following scripts exceed the script budget:")
+		}
+		offenders++
+		v.console.Failure("  - %s: %s", script.Name, strings.Join(violations, ", "))
+		v.report(Finding{RuleID: RuleScriptBudget, Level: "error", Path: script.Path,
+			Message: "Script exceeds the script budget: " + strings.Join(violations, ", ")})
+	}
+
+	if offenders > 0 {
+		return fmt.Errorf("%d scripts exceed the script budget - split them into smaller migrations", offenders)
+	}
+	return nil
+}
+
 // ValidateScriptsDirectory checks if the scripts directory is within a git repository
 func (v *Validator) ValidateScriptsDirectory() error {
 	if !v.git.IsGitRepository() {
@@ -110,4 +175,3 @@ func (v *Validator) ValidateScriptsDirectory() error {
 	}
 	return nil
 }
-
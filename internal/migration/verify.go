@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// VerifyDir holds verify scripts, relative to the scripts directory
+const VerifyDir = "verify"
+
+// VerifyResult is the outcome of one verify script
+type VerifyResult struct {
+	Script string `json:"script"`
+	Passed bool   `json:"passed"`
+	Rows   int    `json:"rows,omitempty"` // Rows returned by the failing query
+	Error  string `json:"error,omitempty"`
+}
+
+// Verify runs the verify scripts against the database. Every query of a verify script
+// must return no rows, e.g. SELECT id FROM orders WHERE user_id NOT IN (SELECT id FROM users);
+// rows are violations. Verify scripts are not recorded and never change the database
+func (m *Migrator) Verify() ([]VerifyResult, error) {
+	m.forgetCommit()
+	dir := filepath.Join(m.config.ScriptsDir, VerifyDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		m.console.Warn("No %s directory; nothing to verify", VerifyDir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var results []VerifyResult
+	failed := 0
+	for _, name := range names {
+		label := VerifyDir + "/" + name
+		m.console.Script(label, "executing")
+		result := m.verifyScript(name, filepath.Join(dir, name))
+		result.Script = label
+		if result.Passed {
+			m.console.Script(label, "success")
+		} else {
+			failed++
+			m.console.Script(label, "failed")
+			m.console.Error("Verification failed: %s", result.Error)
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d verify scripts failed", failed, len(results))
+	}
+	m.console.Success("%d verify scripts passed", len(results))
+	return results, nil
+}
+
+// verifyScript runs the queries of one verify script, stopping at the first violation
+func (m *Migrator) verifyScript(name, path string) VerifyResult {
+	content, err := m.readScript(git.ScriptInfo{Name: name, Path: path})
+	if err != nil {
+		return VerifyResult{Error: err.Error()}
+	}
+	for _, query := range splitStatements(string(content)) {
+		rows, err := m.db.Query(query)
+		if err != nil {
+			return VerifyResult{Error: fmt.Sprintf("query failed: %v", err)}
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return VerifyResult{Error: fmt.Sprintf("query failed: %v", err)}
+		}
+		if count > 0 {
+			return VerifyResult{Rows: count, Error: fmt.Sprintf("%d rows violate %s", count, firstLine(query))}
+		}
+	}
+	return VerifyResult{Passed: true}
+}
+
+// firstLine returns the first line of a statement, for messages
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// isVerifyScript reports whether a repository path lies in the verify directory
+func (m *Migrator) isVerifyScript(repoPath string) bool {
+	prefix, err := m.git.Prefix()
+	if err != nil {
+		return false
+	}
+	dir := filepath.ToSlash(filepath.Join(prefix, VerifyDir)) + "/"
+	return strings.HasPrefix(filepath.ToSlash(repoPath), dir)
+}
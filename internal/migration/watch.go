@@ -0,0 +1,114 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// Watch applies new commits of the scripts repository every interval until ctx is done,
+// pulling the checkout first when it tracks a remote branch. newMigrator returns the
+// Migrator of each check. A commit whose run failed is not retried until another commit
+// arrives, unless the database was unreachable
+func Watch(ctx context.Context, interval time.Duration, newMigrator func() *Migrator) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	applied := ""
+	for {
+		m := newMigrator()
+		if commit, err := m.applyNewCommit(ctx, applied); err != nil {
+			m.console.Warn("%v", err)
+		} else {
+			applied = commit
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyNewCommit runs up when HEAD moved past applied, holding the migration lock, and
+// returns the commit that was handled
+func (m *Migrator) applyNewCommit(ctx context.Context, applied string) (string, error) {
+	if upstream := m.git.Upstream(); upstream != "" {
+		if err := m.git.Pull(); err != nil {
+			return applied, fmt.Errorf("failed to pull %s: %w", upstream, err)
+		}
+	}
+	commit, err := m.git.GetCurrentCommit()
+	if err != nil {
+		return applied, fmt.Errorf("failed to get current commit: %w", err)
+	}
+	if commit == applied {
+		return applied, nil
+	}
+
+	// Another watcher or pipeline migrating this database is not waited for
+	lock, err := m.db.AcquireLock(ctx, LockName(m.config.DBName), 0)
+	if errors.Is(err, db.ErrLockTimeout) {
+		m.console.Info("Another run holds the migration lock; checking again later")
+		return applied, nil
+	}
+	if err != nil {
+		return applied, err
+	}
+	m.lockHeld = true
+	defer func() {
+		m.lockHeld = false
+		if err := lock.Release(); err != nil {
+			m.console.Warn("%v", err)
+		}
+	}()
+
+	m.console.Info("Applying commit %s", commit[:8])
+	runErr := m.Run()
+	if runErr != nil {
+		m.console.Error("Migration failed: %v", runErr)
+	}
+	report := m.Report(runErr)
+	if runErr != nil || len(report.Scripts) > 0 {
+		m.notify(report)
+	}
+	if runErr != nil && db.IsConnectionError(runErr) {
+		return applied, nil
+	}
+	return commit, nil
+}
+
+// notify runs the --on-apply command with the run report as JSON on stdin, and its
+// outline in DB_MIGRATION_STATUS, DB_MIGRATION_BATCH, DB_MIGRATION_COMMIT and DB_MIGRATION_ERROR
+func (m *Migrator) notify(report RunReport) {
+	if m.config.OnApply == "" {
+		return
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		m.console.Warn("Could not encode run report: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", m.config.OnApply)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"DB_MIGRATION_STATUS="+report.Status,
+		"DB_MIGRATION_BATCH="+report.BatchID,
+		"DB_MIGRATION_COMMIT="+report.Commit,
+		"DB_MIGRATION_ERROR="+report.Error,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		m.console.Warn("--on-apply command failed: %v: %s", err, bytes.TrimSpace(output))
+	}
+}
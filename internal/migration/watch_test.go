@@ -0,0 +1,29 @@
+package migration
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+)
+
+func TestNotify(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "report.json")
+	cfg := &config.Config{OnApply: `cat > ` + out + ` && echo "$DB_MIGRATION_STATUS $DB_MIGRATION_BATCH" > ` + out + `.env`}
+	m := &Migrator{config: cfg, console: console.New()}
+
+	m.notify(RunReport{BatchID: "b1", Status: "failed", Error: "boom", Scripts: []ReportScript{{Name: "001_init.sql", Status: ResultFailed}}})
+
+	var report RunReport
+	data, err := os.ReadFile(out)
+	if err != nil || json.Unmarshal(data, &report) != nil || report.Error != "boom" || len(report.Scripts) != 1 {
+		t.Errorf("expected the report on stdin, got %s (err: %v)", data, err)
+	}
+	if env, _ := os.ReadFile(out + ".env"); string(env) != "failed b1\n" {
+		t.Errorf("expected the outline in the environment, got %q", env)
+	}
+}
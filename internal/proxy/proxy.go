@@ -0,0 +1,182 @@
+// Package proxy sends database connections through a SOCKS5 or HTTP CONNECT proxy, for
+// data centers whose only egress is a proxy. Hosts in NO_PROXY are dialed directly.
+// git, the cloud CLIs and HTTP clients are pointed at the same proxy through the
+// standard environment variables.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	xproxy "golang.org/x/net/proxy"
+)
+
+// Dialer dials through the proxy, or directly for NO_PROXY hosts
+type Dialer struct {
+	url    *url.URL
+	dialer xproxy.ContextDialer
+}
+
+// New parses a socks5://, socks5h://, http:// or https:// proxy URL, optionally with
+// user:password, and bypasses the hosts of noProxy (the NO_PROXY syntax)
+func New(rawURL, noProxy string) (*Dialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", rawURL)
+	}
+
+	var through xproxy.Dialer
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		through, err = xproxy.FromURL(u, xproxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+		}
+	case "http", "https":
+		through = &connectDialer{proxy: u}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: use socks5, socks5h, http or https", u.Scheme)
+	}
+
+	if strings.TrimSpace(noProxy) == "*" {
+		return &Dialer{url: u, dialer: xproxy.Direct}, nil
+	}
+	perHost := xproxy.NewPerHost(through, xproxy.Direct)
+	perHost.AddFromString(noProxy)
+	return &Dialer{url: u, dialer: perHost}, nil
+}
+
+// NoProxy returns NO_PROXY, or no_proxy
+func NoProxy() string {
+	return firstEnv("NO_PROXY", "no_proxy")
+}
+
+// AllProxy returns ALL_PROXY, or all_proxy: the proxy of non-HTTP connections
+func AllProxy() string {
+	return firstEnv("ALL_PROXY", "all_proxy")
+}
+
+// String returns the proxy URL without its password
+func (d *Dialer) String() string {
+	return d.url.Redacted()
+}
+
+// DialContext connects to addr through the proxy
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s through proxy %s: %w", addr, d.url.Host, err)
+	}
+	return conn, nil
+}
+
+// Register makes the mysql driver dial DSNs with this network name through the proxy
+func (d *Dialer) Register(network string) {
+	mysql.RegisterDialContext(network, func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	})
+}
+
+// Export points child processes (git, oras, cosign, aws, gcloud, az) and this
+// process's HTTP clients at the proxy, unless their variables are already set
+func (d *Dialer) Export() {
+	for _, names := range [][2]string{{"HTTPS_PROXY", "https_proxy"}, {"HTTP_PROXY", "http_proxy"}, {"ALL_PROXY", "all_proxy"}} {
+		if firstEnv(names[0], names[1]) == "" {
+			os.Setenv(names[0], d.url.String())
+			os.Setenv(names[1], d.url.String())
+		}
+	}
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// connectDialer opens tunnels with HTTP CONNECT
+type connectDialer struct {
+	proxy *url.URL
+}
+
+func (c *connectDialer) Dial(network, addr string) (net.Conn, error) {
+	return c.DialContext(context.Background(), network, addr)
+}
+
+func (c *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := c.proxy.Host
+	if c.proxy.Port() == "" {
+		port := "80"
+		if c.proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(c.proxy.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if c.proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: c.proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed TLS handshake with proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if user := c.proxy.User; user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT: %s", resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		// The server spoke first (MySQL sends its greeting right away)
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads what the CONNECT response reader already buffered first
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// listen serves each accepted connection with handle until the test ends
+func listen(t *testing.T, handle func(net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { handle(conn); conn.Close() }()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestHTTPConnect(t *testing.T) {
+	// The "database" greets first, like MySQL, then echoes
+	database := listen(t, func(conn net.Conn) {
+		conn.Write([]byte("hello"))
+		io.Copy(conn, conn)
+	})
+
+	var mu sync.Mutex
+	var connects []string
+	proxyAddr := listen(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return
+		}
+		mu.Lock()
+		connects = append(connects, req.Host)
+		mu.Unlock()
+		upstream, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer upstream.Close()
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	})
+
+	seen := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), connects...)
+	}
+
+	d, err := New("http://user:secret@"+proxyAddr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.String() != "http://user:xxxxx@"+proxyAddr {
+		t.Errorf("expected the password to be redacted, got %s", d)
+	}
+	conn, err := d.DialContext(context.Background(), "tcp", database)
+	if err != nil {
+		t.Fatalf("DialContext failed: %v", err)
+	}
+	defer conn.Close()
+	reply := make([]byte, 9)
+	conn.Write([]byte("ping"))
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "helloping" {
+		t.Errorf("expected the greeting and the echo through the proxy, got %q (err: %v)", reply, err)
+	}
+	if got := seen(); len(got) != 1 || got[0] != database {
+		t.Errorf("expected one CONNECT to %s, got %v", database, got)
+	}
+
+	wrong, _ := New("http://user:wrong@"+proxyAddr, "")
+	if _, err := wrong.DialContext(context.Background(), "tcp", database); err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("expected the proxy to refuse bad credentials, got %v", err)
+	}
+
+	// NO_PROXY hosts are dialed directly
+	bypass, _ := New("http://"+proxyAddr, "example.com,127.0.0.0/8")
+	conn, err = bypass.DialContext(context.Background(), "tcp", database)
+	if err != nil {
+		t.Fatalf("direct dial failed: %v", err)
+	}
+	conn.Close()
+	if got := seen(); len(got) != 1 {
+		t.Errorf("expected 127.0.0.1 to bypass the proxy, got %v", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, rawURL := range []string{"socks5://proxy:1080", "socks5h://u:p@proxy:1080", "http://proxy:3128", "https://proxy"} {
+		if _, err := New(rawURL, ""); err != nil {
+			t.Errorf("New(%q) failed: %v", rawURL, err)
+		}
+	}
+	for _, rawURL := range []string{"ftp://proxy:21", "proxy:3128", ""} {
+		if _, err := New(rawURL, ""); err == nil {
+			t.Errorf("New(%q) should fail", rawURL)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringService is the service name passwords are stored under in the OS keychain
+// (macOS Keychain, Windows Credential Manager or the Secret Service on Linux)
+const KeyringService = "db-migration"
+
+// StorePassword saves the password of a profile in the OS keychain, replacing any stored one
+func StorePassword(profile, password string) error {
+	if err := keyring.Set(KeyringService, profile, password); err != nil {
+		return fmt.Errorf("failed to store the password in the OS keychain: %w", err)
+	}
+	return nil
+}
+
+// osKeyring reads keyring://<profile> from the OS keychain
+type osKeyring struct{}
+
+func (osKeyring) Fetch(ctx context.Context, ref string, query url.Values) (string, error) {
+	password, err := keyring.Get(KeyringService, ref)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("no password stored for profile %s; run db-migration login %s", ref, ref)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the OS keychain: %w", err)
+	}
+	return password, nil
+}
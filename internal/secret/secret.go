@@ -0,0 +1,163 @@
+// Package secret resolves secret references such as aws-sm://prod/db-migrator#password
+// to their values at startup, so secrets stay out of command lines, config and CI variables.
+//
+// Providers are looked up by scheme. The built-in ones use the cloud CLIs and their
+// usual login (instance roles, workload identity, az login, ...):
+//
+//	aws-sm://<secret-id>[?region=<region>][#<json key>]        AWS Secrets Manager
+//	gcp-sm://<project>/<secret>[/<version>][#<json key>]       GCP Secret Manager
+//	azure-kv://<vault>/<secret>[#<json key>]                   Azure Key Vault
+//	keyring://<profile>                                        OS keychain (see db-migration login)
+//
+// A #key selects one field of a secret holding a JSON object, e.g. the RDS
+// {"username": ..., "password": ...} format.
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Provider fetches the raw value of a secret; ref is the reference without its scheme,
+// query and #key, e.g. "prod/db-migrator" for aws-sm://prod/db-migrator#password
+type Provider interface {
+	Fetch(ctx context.Context, ref string, query url.Values) (string, error)
+}
+
+var (
+	mu        sync.Mutex
+	providers = map[string]Provider{
+		"aws-sm":   awsSecretsManager{},
+		"gcp-sm":   gcpSecretManager{},
+		"azure-kv": azureKeyVault{},
+		"keyring":  osKeyring{},
+	}
+)
+
+// Register adds or replaces the provider of a scheme
+func Register(scheme string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = p
+}
+
+// IsReference reports whether value names a secret of a registered provider
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok = providers[scheme]
+	return ok
+}
+
+// Resolver resolves references, fetching each secret once even when several
+// of its keys are used
+type Resolver struct {
+	cache map[string]string
+}
+
+// NewResolver creates a Resolver with an empty cache
+func NewResolver() *Resolver {
+	return &Resolver{cache: make(map[string]string)}
+}
+
+// Resolve returns the secret a reference names, or value unchanged when it is not a reference
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	scheme, rest, _ := strings.Cut(value, "://")
+	rest, key, _ := strings.Cut(rest, "#")
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret reference %s://%s: %w", scheme, rest, err)
+	}
+
+	cacheKey := scheme + "://" + rest + "?" + rawQuery
+	raw, ok := r.cache[cacheKey]
+	if !ok {
+		mu.Lock()
+		p := providers[scheme]
+		mu.Unlock()
+		raw, err = p.Fetch(ctx, rest, query)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s://%s: %w", scheme, rest, err)
+		}
+		r.cache[cacheKey] = raw
+	}
+	if key == "" {
+		return raw, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return "", fmt.Errorf("secret %s://%s is not a JSON object, so #%s cannot be selected", scheme, rest, key)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s://%s has no key %s", scheme, rest, key)
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(field), nil
+}
+
+// runCommand runs a CLI and returns its output; replaced in tests
+var runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("%s failed: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	// Only the newline the CLIs add is removed; secrets may end in spaces
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// awsSecretsManager reads aws-sm://<secret-id> with the AWS CLI
+type awsSecretsManager struct{}
+
+func (awsSecretsManager) Fetch(ctx context.Context, ref string, query url.Values) (string, error) {
+	args := []string{"secretsmanager", "get-secret-value", "--secret-id", ref, "--query", "SecretString", "--output", "text"}
+	if region := query.Get("region"); region != "" {
+		args = append(args, "--region", region)
+	}
+	return runCommand(ctx, "aws", args...)
+}
+
+// gcpSecretManager reads gcp-sm://<project>/<secret>[/<version>] with gcloud
+type gcpSecretManager struct{}
+
+func (gcpSecretManager) Fetch(ctx context.Context, ref string, query url.Values) (string, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", fmt.Errorf("expected gcp-sm://<project>/<secret>[/<version>]")
+	}
+	version := "latest"
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	return runCommand(ctx, "gcloud", "secrets", "versions", "access", version, "--secret", parts[1], "--project", parts[0])
+}
+
+// azureKeyVault reads azure-kv://<vault>/<secret> with the Azure CLI
+type azureKeyVault struct{}
+
+func (azureKeyVault) Fetch(ctx context.Context, ref string, query url.Values) (string, error) {
+	vault, name, ok := strings.Cut(ref, "/")
+	if !ok || vault == "" || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("expected azure-kv://<vault>/<secret>")
+	}
+	return runCommand(ctx, "az", "keyvault", "secret", "show", "--vault-name", vault, "--name", name, "--query", "value", "--output", "tsv")
+}
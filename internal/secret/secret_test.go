@@ -0,0 +1,85 @@
+package secret
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestResolve(t *testing.T) {
+	var calls []string
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		if name == "aws" {
+			return `{"username": "migrator", "password": "s3cr#t", "port": 3306}`, nil
+		}
+		return "plain value", nil
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"not-a-reference", "not-a-reference"},
+		{"s3://bucket/key", "s3://bucket/key"},
+		{"aws-sm://prod/db-migrator?region=eu-west-1#username", "migrator"},
+		{"aws-sm://prod/db-migrator?region=eu-west-1#password", "s3cr#t"},
+		{"aws-sm://prod/db-migrator?region=eu-west-1#port", "3306"},
+		{"gcp-sm://my-project/db-password", "plain value"},
+		{"azure-kv://my-vault/db-password", "plain value"},
+	}
+	r := NewResolver()
+	for _, tt := range tests {
+		got, err := r.Resolve(context.Background(), tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.ref, got, err, tt.want)
+		}
+	}
+
+	want := []string{
+		"aws secretsmanager get-secret-value --secret-id prod/db-migrator --query SecretString --output text --region eu-west-1",
+		"gcloud secrets versions access latest --secret db-password --project my-project",
+		"az keyvault secret show --vault-name my-vault --name db-password --query value --output tsv",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected each secret to be fetched once:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(calls, "\n"))
+	}
+
+	if _, err := r.Resolve(context.Background(), "aws-sm://prod/db-migrator?region=eu-west-1#missing"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+	if _, err := r.Resolve(context.Background(), "gcp-sm://my-project/db-password#key"); err == nil {
+		t.Error("expected an error selecting a key of a non-JSON secret")
+	}
+}
+
+type staticProvider map[string]string
+
+func (p staticProvider) Fetch(ctx context.Context, ref string, query url.Values) (string, error) {
+	return p[ref], nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("test", staticProvider{"db/password": "hunter2"})
+	got, err := NewResolver().Resolve(context.Background(), "test://db/password")
+	if err != nil || got != "hunter2" {
+		t.Errorf("expected the registered provider to resolve, got %q, %v", got, err)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keyring.MockInit()
+	if err := StorePassword("local", "s3cr#t"); err != nil {
+		t.Fatalf("StorePassword failed: %v", err)
+	}
+	got, err := NewResolver().Resolve(context.Background(), "keyring://local")
+	if err != nil || got != "s3cr#t" {
+		t.Errorf("expected the stored password, got %q, %v", got, err)
+	}
+	if _, err := NewResolver().Resolve(context.Background(), "keyring://missing"); err == nil || !strings.Contains(err.Error(), "db-migration login missing") {
+		t.Errorf("expected a hint to log in, got %v", err)
+	}
+}
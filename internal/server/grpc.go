@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	migrationv1 "github.com/bontaramsonta/db-migration/api/migration/v1"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// progressStates maps script statuses to their API state
+var progressStates = map[string]migrationv1.ScriptProgress_State{
+	migration.ProgressExecuting: migrationv1.ScriptProgress_STATE_EXECUTING,
+	migration.ResultSuccess:     migrationv1.ScriptProgress_STATE_SUCCESS,
+	migration.ResultFailed:      migrationv1.ScriptProgress_STATE_FAILED,
+	migration.ResultTolerated:   migrationv1.ScriptProgress_STATE_TOLERATED,
+	migration.ResultSkipped:     migrationv1.ScriptProgress_STATE_SKIPPED,
+	migration.ResultDeferred:    migrationv1.ScriptProgress_STATE_DEFERRED,
+	migration.ResultNotRun:      migrationv1.ScriptProgress_STATE_NOT_RUN,
+}
+
+// MutualTLS loads the server certificate and key and requires clients to present a
+// certificate signed by the CAs of clientCAFile
+func MutualTLS(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gRPC certificate: %w", err)
+	}
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}, nil
+}
+
+// GRPC returns a gRPC server of the Migrations service authenticating clients with
+// tlsConfig. Its applies share the one-at-a-time rule of the HTTP API
+func (s *Server) GRPC(tlsConfig *tls.Config) *grpc.Server {
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	migrationv1.RegisterMigrationsServer(srv, &grpcService{server: s})
+	return srv
+}
+
+// grpcService implements the Migrations service on top of a Server
+type grpcService struct {
+	migrationv1.UnimplementedMigrationsServer
+	server *Server
+}
+
+// Plan returns what the next apply would execute
+func (g *grpcService) Plan(ctx context.Context, req *migrationv1.PlanRequest) (*migrationv1.PlanResponse, error) {
+	var log logBuffer
+	plan, err := g.server.backend.Plan(&log)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	resp := &migrationv1.PlanResponse{
+		Database:   plan.Database,
+		FromCommit: plan.FromCommit,
+		ToCommit:   plan.ToCommit,
+		Errors:     plan.Errors,
+		Risk:       plan.Risk(),
+	}
+	for _, c := range plan.Changes {
+		resp.Changes = append(resp.Changes, &migrationv1.PlannedChange{Script: c.Script, Action: c.Action, Risk: c.Risk, Reasons: c.Reasons})
+	}
+	return resp, nil
+}
+
+// Apply starts a run and streams its progress until it finished
+func (g *grpcService) Apply(req *migrationv1.ApplyRequest, stream grpc.ServerStreamingServer[migrationv1.ScriptProgress]) error {
+	origin := "gRPC client"
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		origin = p.Addr.String()
+	}
+	run, current := g.server.start(origin)
+	if current != nil {
+		return status.Errorf(codes.Aborted, "apply %s is in progress", current.BatchID)
+	}
+	if err := stream.SendHeader(metadata.Pairs("batch-id", run.BatchID)); err != nil {
+		return err
+	}
+
+	// The run continues if the client goes away; its outcome stays available from /status
+	err := g.server.follow(stream.Context(), run, func(p migration.ScriptProgress) error {
+		event := &migrationv1.ScriptProgress{
+			BatchId: run.BatchID,
+			Script:  p.Script,
+			State:   progressStates[p.Status],
+			Index:   int32(p.Index),
+			Total:   int32(p.Total),
+			Error:   p.Error,
+		}
+		if p.Status != migration.ProgressExecuting {
+			event.Duration = durationpb.New(p.Duration)
+		}
+		return stream.Send(event)
+	})
+	if err != nil {
+		return err
+	}
+	finished := g.server.snapshot(run)
+	if finished.Status == StatusFailed {
+		return status.Errorf(codes.Internal, "%s", finished.Report.Error)
+	}
+	return nil
+}
+
+// GetHistory returns the tracking table, oldest first
+func (g *grpcService) GetHistory(ctx context.Context, req *migrationv1.GetHistoryRequest) (*migrationv1.GetHistoryResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must not be negative")
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	records, err := g.server.backend.History(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	if limit := int(req.Limit); limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+
+	resp := &migrationv1.GetHistoryResponse{}
+	for _, rec := range records {
+		resp.Records = append(resp.Records, &migrationv1.Record{
+			Sno:        int64(rec.SNO),
+			Script:     rec.ScriptName,
+			Action:     rec.Action,
+			Completed:  rec.Completed,
+			EndOfBatch: rec.EndOfBatch,
+			BatchId:    rec.BatchID,
+			Commit:     rec.LastGitID,
+			Checksum:   rec.Checksum,
+			Tickets:    rec.Tickets,
+			CreatedAt:  timestamppb.New(rec.CreatedDateTime),
+			ModifiedAt: timestamppb.New(rec.ModifiedDateTime),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	migrationv1 "github.com/bontaramsonta/db-migration/api/migration/v1"
+	"github.com/bontaramsonta/db-migration/internal/console"
+)
+
+// issue creates a certificate for name signed by parent (self-signed when nil)
+func issue(t *testing.T, name string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writePEM writes cert, and its key unless keyPath is empty
+func writePEM(t *testing.T, cert tls.Certificate, certPath, keyPath string) {
+	t.Helper()
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	if keyPath != "" {
+		der, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", nil)
+	serverCert := issue(t, "localhost", &ca)
+	writePEM(t, ca, filepath.Join(dir, "ca.pem"), "")
+	writePEM(t, serverCert, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"))
+	tlsConfig, err := MutualTLS(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &fakeBackend{release: make(chan struct{})}
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	api := New(backend, "s3cret", cons)
+	api.newID = func() string { return "batch-1" }
+	srv := api.GRPC(tlsConfig)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	dial := func(certs ...tls.Certificate) migrationv1.MigrationsClient {
+		creds := credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs})
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(creds))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return migrationv1.NewMigrationsClient(conn)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := dial().Plan(ctx, &migrationv1.PlanRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected a client without a certificate to be refused, got %v", err)
+	}
+	if _, err := dial(issue(t, "intruder", nil)).Plan(ctx, &migrationv1.PlanRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected a certificate of another CA to be refused, got %v", err)
+	}
+
+	client := dial(issue(t, "deployer", &ca))
+	plan, err := client.Plan(ctx, &migrationv1.PlanRequest{})
+	if err != nil || len(plan.Changes) != 1 || plan.Risk != "low" {
+		t.Fatalf("expected the plan, got %v %v", plan, err)
+	}
+
+	stream, err := client.Apply(ctx, &migrationv1.ApplyRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := stream.Header()
+	if err != nil || len(header.Get("batch-id")) != 1 || header.Get("batch-id")[0] != "batch-1" {
+		t.Fatalf("expected the batch-id header, got %v %v", header, err)
+	}
+	event, err := stream.Recv()
+	if err != nil || event.State != migrationv1.ScriptProgress_STATE_EXECUTING || event.Script != "002_orders.sql" || event.Index != 1 || event.Total != 1 {
+		t.Fatalf("expected 002_orders.sql to start, got %v %v", event, err)
+	}
+
+	conflict, err := client.Apply(ctx, &migrationv1.ApplyRequest{})
+	if err == nil {
+		_, err = conflict.Recv()
+	}
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected a second apply to be aborted, got %v", err)
+	}
+
+	close(backend.release)
+	if event, err = stream.Recv(); err != nil || event.State != migrationv1.ScriptProgress_STATE_SUCCESS || event.BatchId != "batch-1" || event.Duration == nil {
+		t.Fatalf("expected 002_orders.sql to succeed, got %v %v", event, err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("expected the stream to end after the run, got %v", err)
+	}
+
+	history, err := client.GetHistory(ctx, &migrationv1.GetHistoryRequest{Limit: 1})
+	if err != nil || len(history.Records) != 1 || history.Records[0].Script != "002_orders.sql" {
+		t.Errorf("expected the last record, got %v %v", history, err)
+	}
+}
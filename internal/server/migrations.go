@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"io"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// Migrations is the Backend of a database and its scripts directory; every plan and
+// apply gets a fresh Migrator logging to the request
+type Migrations struct {
+	Config  *config.Config
+	DB      *db.DB
+	Options []migration.Option
+}
+
+func (b *Migrations) migrator(log io.Writer, opts ...migration.Option) *migration.Migrator {
+	cons := console.New()
+	cons.SetOutput(log)
+	return migration.NewMigrator(b.Config, b.DB, cons, append(append([]migration.Option{}, b.Options...), opts...)...)
+}
+
+// Ping checks that the database is reachable
+func (b *Migrations) Ping(ctx context.Context) error {
+	return b.DB.Ping(ctx)
+}
+
+// Plan plans the next apply
+func (b *Migrations) Plan(log io.Writer) (*migration.Plan, error) {
+	return b.migrator(log).Plan()
+}
+
+// Apply runs up as batch batchID, passing the progress of each script to progress, and
+// reports the outcome
+func (b *Migrations) Apply(batchID string, log io.Writer, progress func(migration.ScriptProgress)) migration.RunReport {
+	m := b.migrator(log, migration.WithBatchID(batchID), migration.WithProgress(progress))
+	err := m.Run()
+	return m.Report(err)
+}
+
+// Pending counts the scripts the next apply would execute
+func (b *Migrations) Pending(ctx context.Context) (int, error) {
+	return migration.NewInspector(b.Config, b.DB, b.Options...).PendingCount(ctx)
+}
+
+// History returns the tracking table
+func (b *Migrations) History(ctx context.Context) ([]migration.ScriptRecord, error) {
+	return migration.NewInspector(b.Config, b.DB, b.Options...).History(ctx)
+}
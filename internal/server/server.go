@@ -0,0 +1,367 @@
+// Package server exposes the migrations of one database over HTTP and gRPC, so deployment
+// orchestrators and ChatOps bots can plan, apply and observe them without shell
+// access to a host that reaches the database
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// Run states reported by the API
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Backend plans, applies and inspects the migrations of one database
+type Backend interface {
+	Ping(ctx context.Context) error
+	Plan(log io.Writer) (*migration.Plan, error)
+	Apply(batchID string, log io.Writer, progress func(migration.ScriptProgress)) migration.RunReport
+	Pending(ctx context.Context) (int, error)
+	History(ctx context.Context) ([]migration.ScriptRecord, error)
+}
+
+// Run is an apply started through the API
+type Run struct {
+	BatchID   string               `json:"batch_id"`
+	Status    string               `json:"status"` // running, success or failed
+	StartedAt string               `json:"started_at"`
+	Report    *migration.RunReport `json:"report,omitempty"` // Set once the run finished
+	Log       string               `json:"log"`
+	log       *logBuffer
+	done      chan struct{}
+
+	// events holds the script progress so far; changed is closed and replaced on each
+	// new event. Both are guarded by the server lock
+	events  []migration.ScriptProgress
+	changed chan struct{}
+}
+
+// Record is a tracking table row as /history returns it
+type Record struct {
+	SNO        int       `json:"sno"`
+	Script     string    `json:"script"`
+	Action     string    `json:"action"`
+	Completed  bool      `json:"completed"`
+	EndOfBatch bool      `json:"end_of_batch"`
+	BatchID    string    `json:"batch_id"`
+	Commit     string    `json:"commit"`
+	Checksum   string    `json:"checksum,omitempty"`
+	Tickets    string    `json:"tickets,omitempty"`
+	ApprovedBy string    `json:"approved_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// requestTimeout bounds the database queries of the read-only endpoints
+const requestTimeout = 30 * time.Second
+
+// Server serves the migration API; one apply runs at a time
+type Server struct {
+	backend Backend
+	token   string
+	console *console.Console
+	newID   func() string
+
+	mu      sync.Mutex
+	current *Run // The running apply, if any
+	last    *Run // The most recent finished apply
+	runs    sync.WaitGroup
+}
+
+// New creates a Server requiring token as a bearer token on every endpoint but /healthz
+func New(backend Backend, token string, cons *console.Console) *Server {
+	return &Server{backend: backend, token: token, console: cons, newID: migration.NewBatchID}
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.Handle("GET /plan", s.authenticated(s.plan))
+	mux.Handle("POST /apply", s.authenticated(s.apply))
+	mux.Handle("GET /status", s.authenticated(s.status))
+	mux.Handle("GET /history", s.authenticated(s.history))
+	return mux
+}
+
+// Wait blocks until a running apply finished, e.g. during a graceful shutdown
+func (s *Server) Wait() {
+	s.runs.Wait()
+}
+
+// authenticated rejects requests without the bearer token
+func (s *Server) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="db-migration"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// healthz reports whether the database is reachable; it needs no token, for probes
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := s.backend.Ping(ctx); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// plan returns what the next apply would execute, with the risk of each script
+func (s *Server) plan(w http.ResponseWriter, r *http.Request) {
+	var log logBuffer
+	plan, err := s.backend.Plan(&log)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error(), "log": log.String()})
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// apply starts a run in the background and answers 202 with its batch ID, or waits for
+// it with ?wait=true; 409 while another run is in progress
+func (s *Server) apply(w http.ResponseWriter, r *http.Request) {
+	wait, _ := strconv.ParseBool(r.URL.Query().Get("wait"))
+
+	run, current := s.start(r.RemoteAddr)
+	if current != nil {
+		writeJSON(w, http.StatusConflict, current)
+		return
+	}
+
+	if !wait {
+		w.Header().Set("Location", "/status")
+		writeJSON(w, http.StatusAccepted, s.snapshot(run))
+		return
+	}
+	// The run continues if the client goes away; its outcome stays available from /status
+	select {
+	case <-run.done:
+	case <-r.Context().Done():
+		return
+	}
+	finished := s.snapshot(run)
+	code := http.StatusOK
+	if finished.Status == StatusFailed {
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, finished)
+}
+
+// start starts an apply requested from origin, or returns a snapshot of the one in progress
+func (s *Server) start(origin string) (run, current *Run) {
+	s.mu.Lock()
+	if s.current != nil {
+		current := s.current.snapshot()
+		s.mu.Unlock()
+		return nil, current
+	}
+	run = &Run{
+		BatchID:   s.newID(),
+		Status:    StatusRunning,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		log:       &logBuffer{},
+		done:      make(chan struct{}),
+		changed:   make(chan struct{}),
+	}
+	s.current = run
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	s.console.Info("Apply %s requested from %s", run.BatchID, origin)
+	go s.execute(run)
+	return run, nil
+}
+
+// execute applies the pending scripts and records the outcome as the last run
+func (s *Server) execute(run *Run) {
+	defer s.runs.Done()
+	report := s.backend.Apply(run.BatchID, run.log, func(p migration.ScriptProgress) {
+		s.mu.Lock()
+		run.events = append(run.events, p)
+		close(run.changed)
+		run.changed = make(chan struct{})
+		s.mu.Unlock()
+	})
+
+	s.mu.Lock()
+	run.Report, run.Status = &report, report.Status
+	s.current, s.last = nil, run
+	s.mu.Unlock()
+	close(run.done)
+
+	if report.Status == StatusSuccess {
+		s.console.Success("Apply %s succeeded (%d scripts)", run.BatchID, len(report.Scripts))
+	} else {
+		s.console.Failure("Apply %s failed: %s", run.BatchID, report.Error)
+	}
+}
+
+// follow calls fn with each progress event of run, from the first, until the run
+// finished or ctx is done
+func (s *Server) follow(ctx context.Context, run *Run, fn func(migration.ScriptProgress) error) error {
+	for next, finished := 0, false; ; {
+		s.mu.Lock()
+		events, changed := run.events[next:], run.changed
+		s.mu.Unlock()
+
+		for _, p := range events {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		next += len(events)
+		if len(events) > 0 {
+			continue
+		}
+		// Every event was added before done closed, so one more pass after it sees them all
+		if finished {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-run.done:
+			finished = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// status reports the running and the last apply, and how many scripts are pending
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var current, last *Run
+	if s.current != nil {
+		current = s.current.snapshot()
+	}
+	if s.last != nil {
+		last = s.last.snapshot()
+	}
+	s.mu.Unlock()
+
+	resp := struct {
+		Running      bool   `json:"running"`
+		Current      *Run   `json:"current,omitempty"`
+		Last         *Run   `json:"last,omitempty"`
+		Pending      *int   `json:"pending,omitempty"`
+		PendingError string `json:"pending_error,omitempty"`
+	}{Running: current != nil, Current: current, Last: last}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	if pending, err := s.backend.Pending(ctx); err != nil {
+		resp.PendingError = err.Error()
+	} else {
+		resp.Pending = &pending
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// history returns the tracking table, oldest first; ?limit=n returns the last n records
+func (s *Server) history(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	records, err := s.backend.History(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+
+	out := make([]Record, 0, len(records))
+	for _, rec := range records {
+		out = append(out, Record{
+			SNO:        rec.SNO,
+			Script:     rec.ScriptName,
+			Action:     rec.Action,
+			Completed:  rec.Completed,
+			EndOfBatch: rec.EndOfBatch,
+			BatchID:    rec.BatchID,
+			Commit:     rec.LastGitID,
+			Checksum:   rec.Checksum,
+			Tickets:    rec.Tickets,
+			ApprovedBy: rec.ApprovedBy,
+			CreatedAt:  rec.CreatedDateTime,
+			ModifiedAt: rec.ModifiedDateTime,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string][]Record{"records": out})
+}
+
+// snapshot copies run under the lock
+func (s *Server) snapshot(run *Run) *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return run.snapshot()
+}
+
+// snapshot copies the run with its log so far; the caller holds the server lock
+func (r *Run) snapshot() *Run {
+	c := *r
+	c.Log = r.log.String()
+	return &c
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// logBuffer collects the console output of a run while /status reads it
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
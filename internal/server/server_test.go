@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// fakeBackend applies once release is closed
+type fakeBackend struct {
+	pingErr error
+	release chan struct{}
+	applied []string
+}
+
+func (f *fakeBackend) Ping(ctx context.Context) error { return f.pingErr }
+
+func (f *fakeBackend) Plan(log io.Writer) (*migration.Plan, error) {
+	return &migration.Plan{Database: "app", Changes: []migration.PlannedChange{{Script: "002_orders.sql", Action: "create", Risk: migration.RiskLow}}}, nil
+}
+
+func (f *fakeBackend) Apply(batchID string, log io.Writer, progress func(migration.ScriptProgress)) migration.RunReport {
+	fmt.Fprintf(log, "applying %s\n", batchID)
+	progress(migration.ScriptProgress{BatchID: batchID, Script: "002_orders.sql", Status: migration.ProgressExecuting, Index: 1, Total: 1})
+	<-f.release
+	progress(migration.ScriptProgress{BatchID: batchID, Script: "002_orders.sql", Status: migration.ResultSuccess, Index: 1, Total: 1})
+	f.applied = append(f.applied, batchID)
+	return migration.RunReport{BatchID: batchID, Status: StatusSuccess, Scripts: []migration.ReportScript{{Name: "002_orders.sql", Status: migration.ResultSuccess}}}
+}
+
+func (f *fakeBackend) Pending(ctx context.Context) (int, error) {
+	return 1 - len(f.applied), nil
+}
+
+func (f *fakeBackend) History(ctx context.Context) ([]migration.ScriptRecord, error) {
+	return []migration.ScriptRecord{{SNO: 1, ScriptName: "001_init.sql"}, {SNO: 2, ScriptName: "002_orders.sql"}}, nil
+}
+
+func TestServer(t *testing.T) {
+	backend := &fakeBackend{release: make(chan struct{})}
+	cons := console.New()
+	cons.SetOutput(io.Discard)
+	api := New(backend, "s3cret", cons)
+	ids := []string{"batch-1", "batch-2"}
+	api.newID = func() string { id := ids[0]; ids = ids[1:]; return id }
+	ts := httptest.NewServer(api.Handler())
+	defer ts.Close()
+
+	call := func(method, path, token string, into interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if into != nil {
+			if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if code := call("GET", "/healthz", "", nil); code != http.StatusOK {
+		t.Errorf("expected /healthz without a token to be 200, got %d", code)
+	}
+	for _, token := range []string{"", "wrong"} {
+		if code := call("GET", "/plan", token, nil); code != http.StatusUnauthorized {
+			t.Errorf("expected /plan with token %q to be 401, got %d", token, code)
+		}
+	}
+	if code := call("GET", "/apply", "s3cret", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET /apply to be 405, got %d", code)
+	}
+
+	var plan migration.Plan
+	if code := call("GET", "/plan", "s3cret", &plan); code != http.StatusOK || len(plan.Changes) != 1 {
+		t.Errorf("expected the plan, got %d %+v", code, plan)
+	}
+
+	var run Run
+	if code := call("POST", "/apply", "s3cret", &run); code != http.StatusAccepted || run.BatchID != "batch-1" || run.Status != StatusRunning {
+		t.Fatalf("expected batch-1 to start, got %d %+v", code, run)
+	}
+	if code := call("POST", "/apply", "s3cret", &run); code != http.StatusConflict || run.BatchID != "batch-1" {
+		t.Errorf("expected a second apply to conflict with batch-1, got %d %+v", code, run)
+	}
+
+	var status struct {
+		Running bool `json:"running"`
+		Current *Run `json:"current"`
+		Last    *Run `json:"last"`
+		Pending *int `json:"pending"`
+	}
+	call("GET", "/status", "s3cret", &status)
+	if !status.Running || status.Current == nil || !strings.Contains(status.Current.Log, "applying batch-1") {
+		t.Errorf("expected batch-1 running with its log, got %+v", status)
+	}
+
+	close(backend.release)
+	api.Wait()
+	call("GET", "/status", "s3cret", &status)
+	if status.Running || status.Last == nil || status.Last.Status != StatusSuccess || status.Last.Report == nil || status.Pending == nil || *status.Pending != 0 {
+		t.Errorf("expected batch-1 to have succeeded with nothing pending, got %+v", status)
+	}
+
+	if code := call("POST", "/apply?wait=true", "s3cret", &run); code != http.StatusOK || run.BatchID != "batch-2" || run.Status != StatusSuccess {
+		t.Errorf("expected batch-2 to run to completion, got %d %+v", code, run)
+	}
+
+	var history struct {
+		Records []Record `json:"records"`
+	}
+	if code := call("GET", "/history?limit=1", "s3cret", &history); code != http.StatusOK || len(history.Records) != 1 || history.Records[0].Script != "002_orders.sql" {
+		t.Errorf("expected the last record, got %d %+v", code, history)
+	}
+	if code := call("GET", "/history?limit=0", "s3cret", nil); code != http.StatusBadRequest {
+		t.Errorf("expected limit=0 to be rejected, got %d", code)
+	}
+
+	backend.pingErr = errors.New("connection refused")
+	if code := call("GET", "/healthz", "", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("expected /healthz to be 503 when the database is down, got %d", code)
+	}
+}
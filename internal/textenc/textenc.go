@@ -0,0 +1,110 @@
+// Package textenc normalizes script text before execution and checksumming.
+//
+// Scripts are decoded to UTF-8, a leading byte order mark is removed, and
+// CRLF (or lone CR) line endings become LF, so the same script authored on
+// Windows and Unix executes and checksums identically.
+package textenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Supported source encodings
+const (
+	UTF8        = "utf-8"
+	UTF16       = "utf-16" // Byte order from the BOM, little-endian without one
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Latin1      = "iso-8859-1"
+	Windows1252 = "windows-1252"
+)
+
+// aliases maps accepted spellings to canonical encoding names
+var aliases = map[string]string{
+	"utf-8": UTF8, "utf8": UTF8,
+	"utf-16": UTF16, "utf16": UTF16,
+	"utf-16le": UTF16LE, "utf16le": UTF16LE,
+	"utf-16be": UTF16BE, "utf16be": UTF16BE,
+	"iso-8859-1": Latin1, "latin1": Latin1, "latin-1": Latin1,
+	"windows-1252": Windows1252, "cp1252": Windows1252,
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Canonical returns the canonical name of an encoding, or false if it is not supported
+func Canonical(name string) (string, bool) {
+	canonical, ok := aliases[strings.ToLower(strings.TrimSpace(name))]
+	return canonical, ok
+}
+
+// Normalize decodes content from the given encoding to UTF-8, strips any BOM,
+// and normalizes line endings to LF
+// An empty encoding means UTF-8. A UTF-16 BOM overrides the configured
+// encoding, since it is unambiguous
+func Normalize(content []byte, encoding string) ([]byte, error) {
+	if encoding == "" {
+		encoding = UTF8
+	}
+	enc, ok := Canonical(encoding)
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	var text []byte
+	switch {
+	case bytes.HasPrefix(content, bomUTF16LE):
+		text = decodeUTF16(content[2:], binary.LittleEndian)
+	case bytes.HasPrefix(content, bomUTF16BE):
+		text = decodeUTF16(content[2:], binary.BigEndian)
+	case enc == UTF16 || enc == UTF16LE:
+		text = decodeUTF16(content, binary.LittleEndian)
+	case enc == UTF16BE:
+		text = decodeUTF16(content, binary.BigEndian)
+	case enc == Latin1 || enc == Windows1252:
+		text = decodeSingleByte(content, enc == Windows1252)
+	default:
+		// Invalid UTF-8 passes through unchanged, as scripts were always sent as-is
+		text = bytes.TrimPrefix(content, bomUTF8)
+	}
+
+	text = bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+	text = bytes.ReplaceAll(text, []byte("\r"), []byte("\n"))
+	return text, nil
+}
+
+// decodeUTF16 converts UTF-16 code units to UTF-8; a trailing odd byte is dropped
+func decodeUTF16(content []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// windows1252 maps bytes 0x80-0x9F, where Windows-1252 differs from ISO-8859-1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeSingleByte converts ISO-8859-1 or Windows-1252 text to UTF-8
+func decodeSingleByte(content []byte, cp1252 bool) []byte {
+	var out bytes.Buffer
+	out.Grow(len(content))
+	for _, b := range content {
+		r := rune(b)
+		if cp1252 && b >= 0x80 && b <= 0x9F {
+			r = windows1252[b-0x80]
+		}
+		out.WriteRune(r)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,39 @@
+package textenc
+
+import (
+	"testing"
+)
+
+// TestNormalize tests BOM stripping, line endings and decoding
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []byte
+		encoding string
+		want     string
+	}{
+		{"utf-8 BOM and CRLF", []byte("\xEF\xBB\xBFSELECT 1;\r\nSELECT 2;\r\n"), UTF8, "SELECT 1;\nSELECT 2;\n"},
+		{"lone CR", []byte("SELECT 1;\rSELECT 2;"), "", "SELECT 1;\nSELECT 2;"},
+		{"utf-16le BOM", []byte{0xFF, 0xFE, 'S', 0, 0xE9, 0, '\r', 0, '\n', 0}, UTF8, "Sé\n"},
+		{"utf-16be", []byte{0, 'S', 0, 0xE9}, "UTF16BE", "Sé"},
+		{"latin1", []byte("caf\xE9 \x80"), "latin1", "café \u0080"},
+		{"windows-1252", []byte("caf\xE9 \x80"), "cp1252", "café €"},
+		{"invalid utf-8 passes through", []byte("caf\xE9"), UTF8, "caf\xE9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.content, tt.encoding)
+			if err != nil {
+				t.Fatalf("Normalize failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Normalize([]byte("x"), "ebcdic"); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+}
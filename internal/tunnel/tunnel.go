@@ -0,0 +1,192 @@
+// Package tunnel dials MySQL through an SSH bastion host, so private databases can be
+// migrated without an external "ssh -L" wrapper. Every database connection is a
+// direct-tcpip channel of one SSH connection kept open for the whole run.
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// keepAliveInterval keeps idle bastion connections from being dropped during long scripts
+const keepAliveInterval = 30 * time.Second
+
+// Config describes the bastion host
+type Config struct {
+	Host           string // host or host:port (default port 22)
+	User           string // Defaults to the current user
+	KeyFile        string // Private key; the SSH agent and default keys are used otherwise
+	KnownHostsFile string // Defaults to ~/.ssh/known_hosts
+	// Dial connects to the bastion, e.g. through a --proxy; nil dials directly
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Tunnel is an open SSH connection to the bastion
+type Tunnel struct {
+	client *ssh.Client
+	done   chan struct{}
+}
+
+// Open connects and authenticates to the bastion, verifying its host key
+func Open(cfg Config) (*Tunnel, error) {
+	if cfg.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine SSH user: %w", err)
+		}
+		cfg.User = current.Username
+	}
+	addr := cfg.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	knownHostsFile := expandHome(cfg.KnownHostsFile)
+	if knownHostsFile == "" {
+		knownHostsFile = expandHome("~/.ssh/known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	auth, err := authMethods(expandHome(cfg.KeyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	dial := cfg.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+	})
+	if err != nil {
+		conn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("host key of %s is not in %s; add it with ssh-keyscan", addr, knownHostsFile)
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	t := &Tunnel{client: client, done: make(chan struct{})}
+	go t.keepAlive()
+	return t, nil
+}
+
+// authMethods returns the key file, or the SSH agent and the default keys
+func authMethods(keyFile string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	var signers []ssh.Signer
+
+	keyFiles := []string{keyFile}
+	if keyFile == "" {
+		keyFiles = []string{expandHome("~/.ssh/id_ed25519"), expandHome("~/.ssh/id_ecdsa"), expandHome("~/.ssh/id_rsa")}
+	}
+	for _, file := range keyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if keyFile != "" {
+				return nil, fmt.Errorf("failed to read SSH key: %w", err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		var passphraseErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) {
+			// Encrypted keys are expected to be loaded into the agent
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key %s: %w", file, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no SSH key: pass --ssh-key, or load an encrypted key into ssh-agent")
+	}
+	return methods, nil
+}
+
+// DialContext opens a connection to addr (the database host:port) from the bastion
+func (t *Tunnel) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := t.client.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s through the SSH tunnel: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// Register makes the mysql driver dial DSNs with this network name through the tunnel
+func (t *Tunnel) Register(network string) {
+	mysql.RegisterDialContext(network, t.DialContext)
+}
+
+// Close closes the SSH connection and every connection dialed through it
+func (t *Tunnel) Close() error {
+	close(t.done)
+	return t.client.Close()
+}
+
+// keepAlive sends OpenSSH keepalive requests until the tunnel is closed
+func (t *Tunnel) keepAlive() {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			if _, _, err := t.client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// expandHome expands a leading ~/ that the shell left alone, e.g. in --ssh-key=~/.ssh/id
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,137 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startBastion runs an SSH server accepting clientKey and forwarding direct-tcpip channels
+func startBastion(t *testing.T, clientKey ssh.PublicKey) (addr string, hostKey ssh.PublicKey) {
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "deploy" && string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChan := range chans {
+					var target struct {
+						Host       string
+						Port       uint32
+						OriginHost string
+						OriginPort uint32
+					}
+					if newChan.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChan.ExtraData(), &target) != nil {
+						newChan.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+					if err != nil {
+						newChan.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, chReqs, _ := newChan.Accept()
+					go ssh.DiscardRequests(chReqs)
+					go func() { io.Copy(ch, upstream); ch.Close() }()
+					go func() { io.Copy(upstream, ch); upstream.Close() }()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func TestTunnel(t *testing.T) {
+	// An echo server stands in for MySQL behind the bastion
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(conn, conn); conn.Close() }()
+		}
+	}()
+
+	_, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	clientSigner, _ := ssh.NewSignerFromKey(clientPriv)
+	bastion, hostKey := startBastion(t, clientSigner.PublicKey())
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	knownHosts := filepath.Join(dir, "known_hosts")
+	os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)
+	os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{knownhosts.Normalize(bastion)}, hostKey)+"\n"), 0600)
+
+	tun, err := Open(Config{Host: bastion, User: "deploy", KeyFile: keyFile, KnownHostsFile: knownHosts})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tun.Close()
+
+	conn, err := tun.DialContext(context.Background(), echo.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping" {
+		t.Errorf("expected the echo through the tunnel, got %q (err: %v)", reply, err)
+	}
+
+	// An unknown host key is refused
+	os.WriteFile(knownHosts, nil, 0600)
+	if _, err := Open(Config{Host: bastion, User: "deploy", KeyFile: keyFile, KnownHostsFile: knownHosts}); err == nil || !strings.Contains(err.Error(), "ssh-keyscan") {
+		t.Errorf("expected an unknown host key error, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Package vault fetches short-lived database credentials from HashiCorp Vault's
+// database secrets engine, and renews and revokes their lease.
+//
+// Only the HTTP API is used, so no Vault client library or CLI is needed:
+//
+//	GET /v1/database/creds/migrator      -> username, password, lease
+//	PUT /v1/sys/leases/renew             -> extends the lease
+//	PUT /v1/sys/leases/revoke            -> drops the database user
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Environment variables read by NewFromEnv, as used by the Vault CLI
+const (
+	AddrEnv      = "VAULT_ADDR"
+	TokenEnv     = "VAULT_TOKEN"
+	NamespaceEnv = "VAULT_NAMESPACE"
+)
+
+// Client talks to one Vault server with one token
+type Client struct {
+	Addr      string
+	Token     string
+	Namespace string // Vault Enterprise namespace (optional)
+	HTTP      *http.Client
+}
+
+// Credentials are a database username and password with the lease they belong to
+type Credentials struct {
+	Username      string
+	Password      string
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// NewFromEnv creates a client from VAULT_ADDR and VAULT_TOKEN, falling back to the
+// token the Vault CLI saves in ~/.vault-token after vault login
+func NewFromEnv() (*Client, error) {
+	c := &Client{
+		Addr:      strings.TrimSuffix(os.Getenv(AddrEnv), "/"),
+		Token:     os.Getenv(TokenEnv),
+		Namespace: os.Getenv(NamespaceEnv),
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+	}
+	if c.Addr == "" {
+		return nil, fmt.Errorf("%s is not set", AddrEnv)
+	}
+	if c.Token == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				c.Token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if c.Token == "" {
+		return nil, fmt.Errorf("%s is not set and there is no ~/.vault-token", TokenEnv)
+	}
+	return c, nil
+}
+
+// ReadCredentials reads new database credentials from a secrets engine path,
+// e.g. database/creds/migrator
+func (c *Client) ReadCredentials(ctx context.Context, path string) (*Credentials, error) {
+	var secret struct {
+		LeaseID       string `json:"lease_id"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+		Data          struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, strings.Trim(path, "/"), nil, &secret); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if secret.Data.Username == "" || secret.Data.Password == "" {
+		return nil, fmt.Errorf("%s did not return a username and password", path)
+	}
+	return &Credentials{
+		Username:      secret.Data.Username,
+		Password:      secret.Data.Password,
+		LeaseID:       secret.LeaseID,
+		LeaseDuration: time.Duration(secret.LeaseDuration) * time.Second,
+		Renewable:     secret.Renewable,
+	}, nil
+}
+
+// Renew extends a lease by increment and returns the duration Vault granted,
+// which may be shorter once the lease nears its max TTL
+func (c *Client) Renew(ctx context.Context, leaseID string, increment time.Duration) (time.Duration, error) {
+	var secret struct {
+		LeaseDuration int `json:"lease_duration"`
+	}
+	body := map[string]interface{}{"lease_id": leaseID, "increment": int(increment.Seconds())}
+	if err := c.do(ctx, http.MethodPut, "sys/leases/renew", body, &secret); err != nil {
+		return 0, fmt.Errorf("failed to renew lease: %w", err)
+	}
+	return time.Duration(secret.LeaseDuration) * time.Second, nil
+}
+
+// Revoke ends a lease; for database credentials Vault drops the user
+func (c *Client) Revoke(ctx context.Context, leaseID string) error {
+	if err := c.do(ctx, http.MethodPut, "sys/leases/revoke", map[string]string{"lease_id": leaseID}, nil); err != nil {
+		return fmt.Errorf("failed to revoke lease: %w", err)
+	}
+	return nil
+}
+
+// KeepAlive renews the lease of creds at two thirds of its duration until ctx is done
+// or Vault stops extending it; failures are passed to onError and retried at the next tick
+func (c *Client) KeepAlive(ctx context.Context, creds *Credentials, onError func(error)) {
+	if !creds.Renewable || creds.LeaseDuration <= 0 {
+		return
+	}
+	duration := creds.LeaseDuration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(duration * 2 / 3):
+		}
+
+		granted, err := c.Renew(ctx, creds.LeaseID, creds.LeaseDuration)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			onError(err)
+			continue
+		}
+		if granted <= 0 {
+			onError(fmt.Errorf("lease %s reached its max TTL", creds.LeaseID))
+			return
+		}
+		duration = granted
+	}
+}
+
+// do sends a request to the Vault API and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Addr+"/v1/"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		if len(apiErr.Errors) > 0 {
+			return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(apiErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault serves the creds, renew and revoke endpoints and records the calls
+type fakeVault struct {
+	mu      sync.Mutex
+	renews  int
+	revoked []string
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string][]string{"errors": {"permission denied"}})
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/database/creds/migrator":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       "database/creds/migrator/abc",
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]string{"username": "v-migrator-x1", "password": "p4ss"},
+		})
+	case "/v1/sys/leases/renew":
+		f.renews++
+		json.NewEncoder(w).Encode(map[string]interface{}{"lease_duration": 1})
+	case "/v1/sys/leases/revoke":
+		var body struct {
+			LeaseID string `json:"lease_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.revoked = append(f.revoked, body.LeaseID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCredentialsLifecycle(t *testing.T) {
+	fake := &fakeVault{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := &Client{Addr: server.URL, Token: "s.token"}
+
+	creds, err := client.ReadCredentials(context.Background(), "database/creds/migrator")
+	if err != nil {
+		t.Fatalf("ReadCredentials failed: %v", err)
+	}
+	if creds.Username != "v-migrator-x1" || creds.Password != "p4ss" || creds.LeaseDuration != time.Second || !creds.Renewable {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+
+	// The lease is renewed at two thirds of its duration until the run ends
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	client.KeepAlive(ctx, creds, func(err error) { t.Errorf("renewal failed: %v", err) })
+	fake.mu.Lock()
+	renews := fake.renews
+	fake.mu.Unlock()
+	if renews < 1 {
+		t.Errorf("expected the lease to be renewed, got %d renewals", renews)
+	}
+
+	if err := client.Revoke(context.Background(), creds.LeaseID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if len(fake.revoked) != 1 || fake.revoked[0] != creds.LeaseID {
+		t.Errorf("expected lease %s to be revoked, got %v", creds.LeaseID, fake.revoked)
+	}
+}
+
+func TestReadCredentialsError(t *testing.T) {
+	server := httptest.NewServer(&fakeVault{})
+	defer server.Close()
+	client := &Client{Addr: server.URL, Token: "wrong"}
+
+	_, err := client.ReadCredentials(context.Background(), "database/creds/migrator")
+	if err == nil || err.Error() != "failed to read database/creds/migrator: vault returned 403 Forbidden: permission denied" {
+		t.Errorf("expected the Vault error message, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package window parses maintenance windows such as "Sat 01:00-04:00 Europe/Berlin"
+// and tells whether a time falls inside one
+package window
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Containers often ship without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// dayNames are the accepted day abbreviations, in time.Weekday order; full English
+// names are accepted too
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Window is a daily time range on some days of the week, in a time zone. A range that
+// ends before it starts, e.g. 22:00-02:00, runs past midnight into the next day
+type Window struct {
+	spec  string
+	days  [7]bool // Days the window opens on
+	start time.Duration
+	end   time.Duration
+	loc   *time.Location
+}
+
+// Parse reads a window as [days] HH:MM-HH:MM [zone]. days is a comma-separated list of
+// days or day ranges, e.g. Sat,Sun or Mon-Fri; without it the window opens every day.
+// zone is an IANA time zone name, UTC by default
+func Parse(spec string) (*Window, error) {
+	w := &Window{spec: spec, loc: time.UTC}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid window %q: expected [days] HH:MM-HH:MM [zone]", spec)
+	}
+
+	// The time range is the only field with a colon
+	rangeAt := -1
+	for i, f := range fields {
+		if strings.Contains(f, ":") {
+			rangeAt = i
+			break
+		}
+	}
+	if rangeAt < 0 || rangeAt > 1 {
+		return nil, fmt.Errorf("invalid window %q: expected [days] HH:MM-HH:MM [zone]", spec)
+	}
+
+	if rangeAt == 1 {
+		if err := w.parseDays(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+	} else {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	}
+
+	from, to, ok := strings.Cut(fields[rangeAt], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window %q: expected a time range like 01:00-04:00", spec)
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("invalid window %q: the range is empty", spec)
+	}
+
+	if len(fields) > rangeAt+1 {
+		if w.loc, err = time.LoadLocation(fields[rangeAt+1]); err != nil {
+			return nil, fmt.Errorf("invalid window %q: unknown time zone %s", spec, fields[rangeAt+1])
+		}
+	}
+	return w, nil
+}
+
+// parseDays reads a comma-separated list of days and day ranges
+func (w *Window) parseDays(list string) error {
+	for _, item := range strings.Split(strings.ToLower(list), ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, err := parseDay(from)
+		if err != nil {
+			return err
+		}
+		last := first
+		if isRange {
+			if last, err = parseDay(to); err != nil {
+				return err
+			}
+		}
+		// Ranges may wrap around the week, e.g. Fri-Mon
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseDay(name string) (int, error) {
+	for i, day := range dayNames {
+		if name == day || name == strings.ToLower(time.Weekday(i).String()) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", name)
+}
+
+// parseClock reads HH:MM as the time since midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String returns the window as it was given
+func (w *Window) String() string {
+	return w.spec
+}
+
+// Contains reports whether t falls inside the window
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.loc)
+	// Wall clock time, so DST changes do not shift the window
+	since := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	today := int(t.Weekday())
+	if w.start < w.end {
+		return w.days[today] && since >= w.start && since < w.end
+	}
+	// Past midnight, the window belongs to the day it opened on
+	yesterday := (today + 6) % 7
+	return (w.days[today] && since >= w.start) || (w.days[yesterday] && since < w.end)
+}
+
+// NextOpen returns when the window next opens after t, or t when it is open
+func (w *Window) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	local := t.In(w.loc)
+	for i := 0; i <= 7; i++ {
+		open := time.Date(local.Year(), local.Month(), local.Day()+i, 0, int(w.start.Minutes()), 0, 0, w.loc)
+		if w.days[open.Weekday()] && open.After(t) {
+			return open
+		}
+	}
+	// Unreachable: a parsed window opens at least once a week
+	return t
+}
@@ -0,0 +1,70 @@
+package window
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"", "Sat", "Sat 01:00", "Sat 04:00-04:00", "Someday 01:00-04:00", "Sat 01:00-04:00 Mars/Olympus", "Sat 1am-4am", "Sat 01:00-04:00 UTC extra"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+	for _, spec := range []string{"01:00-04:00", "Sat,Sun 22:00-02:00", "Mon-Fri 12:00-13:00 Europe/Berlin", "saturday 00:00-24:00 UTC"} {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("expected %q to parse, got %v", spec, err)
+		}
+	}
+}
+
+func TestWindow_Contains(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		// 2026-10-17 is a Saturday
+		{"Sat 01:00-04:00 Europe/Berlin", time.Date(2026, 10, 17, 1, 0, 0, 0, berlin), true},
+		{"Sat 01:00-04:00 Europe/Berlin", time.Date(2026, 10, 17, 3, 59, 0, 0, berlin), true},
+		{"Sat 01:00-04:00 Europe/Berlin", time.Date(2026, 10, 17, 4, 0, 0, 0, berlin), false},
+		{"Sat 01:00-04:00 Europe/Berlin", time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC), true},
+		{"Sat 01:00-04:00 Europe/Berlin", time.Date(2026, 10, 18, 2, 0, 0, 0, berlin), false},
+		{"Fri 22:00-02:00", time.Date(2026, 10, 17, 1, 0, 0, 0, time.UTC), true},
+		{"Fri 22:00-02:00", time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC), false},
+		{"Fri-Mon 22:00-02:00", time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC), true},
+		{"Mon-Fri 12:00-13:00", time.Date(2026, 10, 17, 12, 30, 0, 0, time.UTC), false},
+		{"12:00-13:00", time.Date(2026, 10, 17, 12, 30, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		w, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Contains(tt.at); got != tt.want {
+			t.Errorf("%q contains %s: expected %v, got %v", tt.spec, tt.at, tt.want, got)
+		}
+	}
+}
+
+func TestWindow_NextOpen(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	w, err := Parse("Sat 01:00-04:00 Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 10, 17, 1, 0, 0, 0, berlin)
+	if got := w.NextOpen(time.Date(2026, 10, 14, 12, 0, 0, 0, berlin)); !got.Equal(want) {
+		t.Errorf("expected the window to open at %s, got %s", want, got)
+	}
+	// Right after it closed, the next opening is a week later
+	want = time.Date(2026, 10, 24, 1, 0, 0, 0, berlin)
+	if got := w.NextOpen(time.Date(2026, 10, 17, 5, 0, 0, 0, berlin)); !got.Equal(want) {
+		t.Errorf("expected the window to open at %s, got %s", want, got)
+	}
+	now := time.Date(2026, 10, 17, 2, 0, 0, 0, berlin)
+	if got := w.NextOpen(now); !got.Equal(now) {
+		t.Errorf("expected an open window to return now, got %s", got)
+	}
+}
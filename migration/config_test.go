@@ -0,0 +1,21 @@
+package migration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+)
+
+// TestConfig_Defaults tests that zero values get the defaults of the command
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{}.internal()
+	if cfg.LockTimeout != config.DefaultLockTimeout || cfg.Retries != config.DefaultRetries || cfg.RetryDelay != config.DefaultRetryDelay {
+		t.Errorf("expected the defaults of the command, got lock timeout %v, %d retries, retry delay %v", cfg.LockTimeout, cfg.Retries, cfg.RetryDelay)
+	}
+
+	cfg = Config{LockTimeout: -1, Retries: -1, RetryDelay: 10 * time.Millisecond}.internal()
+	if cfg.LockTimeout != 0 || cfg.Retries != 0 || cfg.RetryDelay != 10*time.Millisecond {
+		t.Errorf("expected no wait, no retries and a 10ms delay, got lock timeout %v, %d retries, retry delay %v", cfg.LockTimeout, cfg.Retries, cfg.RetryDelay)
+	}
+}
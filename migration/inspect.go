@@ -0,0 +1,145 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// Inspector answers read-only questions about migration state
+// It never creates or writes the tracking table, so it only needs SELECT access
+// and is safe to call from health endpoints and dashboards
+type Inspector struct {
+	inspector *migration.Inspector
+}
+
+// Script is a script the next run would execute
+type Script struct {
+	Name      string
+	Path      string
+	Timestamp time.Time // When the script was committed
+}
+
+// ScriptRecord is one row of the tracking table
+type ScriptRecord struct {
+	SNO              int
+	ScriptName       string
+	Completed        bool
+	EndOfBatch       bool
+	LastGitID        string
+	BatchID          string
+	Action           string // up, down, skip, rerun or defer
+	Checksum         string // SHA-256 of the executed content
+	Fingerprint      string // SHA-256 of the normalized statements
+	Tickets          string // Comma-separated issue IDs
+	ApprovedBy       string // Approver of the batch
+	Failure          string // error, timeout or cancelled when not completed
+	DurationMS       int64  // Execution time in milliseconds; 0 for records of scripts that did not execute
+	ExecutedBy       string // OS user of the run
+	ExecutedHost     string // Hostname of the run
+	ToolVersion      string // Version of the tool that wrote the record
+	ErrorMessage     string // Error of a failed attempt
+	CreatedDateTime  time.Time
+	ModifiedDateTime time.Time
+}
+
+// Batch describes a completed migration batch
+type Batch struct {
+	ID      string
+	GitID   string
+	Scripts []ScriptRecord
+}
+
+// Script statuses reported by Inspector.Scripts
+const (
+	StatusApplied = migration.StatusApplied // Executed and not reverted since
+	StatusSkipped = migration.StatusSkipped // Recorded without executing, e.g. by a skip-if guard
+	StatusFailed  = migration.StatusFailed  // The latest attempt failed
+	StatusPending = migration.StatusPending // The next run would execute it
+)
+
+// ScriptStatus is one row of the status table
+type ScriptStatus struct {
+	Script    string
+	Status    string    // StatusApplied, StatusSkipped, StatusFailed or StatusPending
+	Commit    string    // Commit the script ran at, or the commit the next run would record
+	Timestamp time.Time // When it ran, or when a pending script was committed
+	Error     string    // Error of the last attempt of a failed script
+}
+
+// NewInspector creates an Inspector for the database behind conn; the caller keeps
+// ownership of conn
+func NewInspector(conn *sql.DB, cfg Config, opts ...Option) *Inspector {
+	o := buildOptions(opts)
+	return &Inspector{
+		inspector: migration.NewInspector(cfg.internal(), db.New(conn, cfg.driver(), nil), o.internal()...),
+	}
+}
+
+// PendingCount returns the number of scripts that a Run would execute
+func (i *Inspector) PendingCount(ctx context.Context) (int, error) {
+	return i.inspector.PendingCount(ctx)
+}
+
+// PendingScripts returns the scripts that a Run would execute, in execution order
+func (i *Inspector) PendingScripts(ctx context.Context) ([]Script, error) {
+	pending, err := i.inspector.PendingScripts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([]Script, len(pending))
+	for n, script := range pending {
+		scripts[n] = Script{Name: script.Name, Path: script.Path, Timestamp: script.Timestamp}
+	}
+	return scripts, nil
+}
+
+// LastBatch returns the most recent completed batch, or nil if none has completed
+func (i *Inspector) LastBatch(ctx context.Context) (*Batch, error) {
+	batch, err := i.inspector.LastBatch(ctx)
+	if err != nil || batch == nil {
+		return nil, err
+	}
+	return &Batch{ID: batch.ID, GitID: batch.GitID, Scripts: records(batch.Scripts)}, nil
+}
+
+// FailedScripts returns scripts whose latest attempt failed
+func (i *Inspector) FailedScripts(ctx context.Context) ([]ScriptRecord, error) {
+	failed, err := i.inspector.FailedScripts(ctx)
+	return records(failed), err
+}
+
+// History returns every record of the tracking table, oldest first
+func (i *Inspector) History(ctx context.Context) ([]ScriptRecord, error) {
+	history, err := i.inspector.History(ctx)
+	return records(history), err
+}
+
+// Scripts joins the tracking table with the scripts changed since the last batch:
+// applied and skipped scripts in execution order, then failed and pending ones
+func (i *Inspector) Scripts(ctx context.Context) ([]ScriptStatus, error) {
+	statuses, err := i.inspector.Scripts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([]ScriptStatus, len(statuses))
+	for n, status := range statuses {
+		scripts[n] = ScriptStatus(status)
+	}
+	return scripts, nil
+}
+
+// records converts records of the tracking table to the public type
+func records(recs []migration.ScriptRecord) []ScriptRecord {
+	if recs == nil {
+		return nil
+	}
+	converted := make([]ScriptRecord, len(recs))
+	for n, rec := range recs {
+		converted[n] = ScriptRecord(rec)
+	}
+	return converted
+}
@@ -0,0 +1,103 @@
+package migration_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/migration"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+// TestInspector tests the inspection API before and after a run
+func TestInspector(t *testing.T) {
+	ctx := context.Background()
+	conn := openSQLite(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users and posts")
+
+	cfg := migration.Config{Driver: migration.DriverSQLite, ScriptsDir: scriptsDir}
+	inspector := migration.NewInspector(conn, cfg)
+
+	// Before any run, everything is pending and the tracking table is not created
+	pending, err := inspector.PendingScripts(ctx)
+	if err != nil {
+		t.Fatalf("PendingScripts failed: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Name != "001_users.sql" || pending[1].Name != "002_posts.sql" {
+		t.Errorf("expected 001_users.sql and 002_posts.sql to be pending, got %+v", pending)
+	}
+	batch, err := inspector.LastBatch(ctx)
+	if err != nil {
+		t.Fatalf("LastBatch failed: %v", err)
+	}
+	if batch != nil {
+		t.Errorf("expected no last batch, got %+v", batch)
+	}
+	var tables int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlScriptExec'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("inspector should not create the tracking table")
+	}
+
+	m := migration.NewMigrator(conn, cfg, migration.WithIDGenerator(testkit.NewSequentialIDs("batch")), migration.WithOutput(io.Discard))
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	count, err := inspector.PendingCount(ctx)
+	if err != nil {
+		t.Fatalf("PendingCount failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected nothing pending after the run, got %d", count)
+	}
+	batch, err = inspector.LastBatch(ctx)
+	if err != nil {
+		t.Fatalf("LastBatch failed: %v", err)
+	}
+	if batch == nil || batch.ID != "batch-1" || len(batch.Scripts) != 2 {
+		t.Fatalf("expected batch-1 with 2 scripts, got %+v", batch)
+	}
+	failed, err := inspector.FailedScripts(ctx)
+	if err != nil {
+		t.Fatalf("FailedScripts failed: %v", err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("expected no failed scripts, got %+v", failed)
+	}
+	statuses, err := inspector.Scripts(ctx)
+	if err != nil {
+		t.Fatalf("Scripts failed: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Status != migration.StatusApplied {
+			t.Errorf("expected %s to be applied, got %s", status.Script, status.Status)
+		}
+	}
+	if len(statuses) != 2 {
+		t.Errorf("expected 2 scripts, got %d", len(statuses))
+	}
+}
+
+// TestInspector_CancelledContext tests that a done context stops the git commands of
+// PendingScripts
+func TestInspector_CancelledContext(t *testing.T) {
+	conn := openSQLite(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+
+	inspector := migration.NewInspector(conn, migration.Config{Driver: migration.DriverSQLite, ScriptsDir: scriptsDir})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := inspector.PendingScripts(ctx); err == nil {
+		t.Error("expected PendingScripts to fail with a cancelled context")
+	}
+}
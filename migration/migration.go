@@ -0,0 +1,108 @@
+// Package migration runs db-migration from Go programs, e.g. services that migrate
+// their database at startup. It works on the caller's *sql.DB and applies the same
+// scripts, checks and tracking table as the db-migration command.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/config"
+	"github.com/bontaramsonta/db-migration/internal/db"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// Drivers a *sql.DB may be opened with
+const (
+	DriverMySQL    = db.DriverMySQL // Needs multiStatements=true and parseTime=true in the DSN
+	DriverPostgres = db.DriverPostgres
+	DriverSQLite   = db.DriverSQLite
+	DriverMSSQL    = db.DriverMSSQL
+)
+
+// ErrLocked is returned by Run when another run held the migration lock past
+// Config.LockTimeout
+var ErrLocked = migration.ErrLocked
+
+// Config selects the scripts of a Migrator or Inspector and the database they run on
+type Config struct {
+	// Driver is the driver the *sql.DB was opened with; DriverMySQL by default
+	Driver string
+	// ScriptsDir is the directory of the scripts in a git checkout; unused with WithFS
+	ScriptsDir string
+	// IncludeDir holds shared SQL fragments for the include directive, relative to ScriptsDir
+	IncludeDir string
+	// Namespace tracks the scripts apart from other scripts directories (optional)
+	Namespace string
+	// Vars are substituted for ${NAME} placeholders in scripts
+	Vars map[string]string
+	// StrictVars fails scripts that reference undefined placeholders
+	StrictVars bool
+	// LockTimeout is how long Run waits for another run holding the migration lock; 0
+	// waits 5m like the command, and a negative value fails at once with ErrLocked
+	LockTimeout time.Duration
+	// Retries runs a script again after a deadlock, a lock wait timeout or a lost
+	// connection; 0 retries 3 times like the command, and a negative value never retries
+	Retries int
+	// RetryDelay is the wait before the first retry, doubling for each further one;
+	// 0 waits 1s like the command
+	RetryDelay time.Duration
+}
+
+// internal returns the configuration of the migrator for cfg
+func (cfg Config) internal() *config.Config {
+	return &config.Config{
+		Driver:      cfg.driver(),
+		ScriptsDir:  cfg.ScriptsDir,
+		IncludeDir:  cfg.IncludeDir,
+		Namespace:   cfg.Namespace,
+		Vars:        cfg.Vars,
+		StrictVars:  cfg.StrictVars,
+		LockTimeout: orDefault(cfg.LockTimeout, config.DefaultLockTimeout),
+		Retries:     orDefault(cfg.Retries, config.DefaultRetries),
+		RetryDelay:  orDefault(cfg.RetryDelay, config.DefaultRetryDelay),
+	}
+}
+
+// orDefault returns def for a zero value and 0 for a negative one
+func orDefault[T int | time.Duration](value, def T) T {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+func (cfg Config) driver() string {
+	if cfg.Driver == "" {
+		return DriverMySQL
+	}
+	return cfg.Driver
+}
+
+// Migrator applies pending scripts to a database
+type Migrator struct {
+	config  Config
+	db      *db.DB
+	options options
+}
+
+// NewMigrator creates a Migrator for the database behind conn; the caller keeps
+// ownership of conn
+func NewMigrator(conn *sql.DB, cfg Config, opts ...Option) *Migrator {
+	return &Migrator{
+		config:  cfg,
+		db:      db.New(conn, cfg.driver(), nil),
+		options: buildOptions(opts),
+	}
+}
+
+// Run applies the pending scripts, as the up command does. Once ctx is done, the
+// running script is rolled back and recorded as cancelled, and no further script starts
+func (m *Migrator) Run(ctx context.Context) error {
+	opts := append(m.options.internal(), migration.WithContext(ctx))
+	return migration.NewMigrator(m.config.internal(), m.db, m.options.console(), opts...).Run()
+}
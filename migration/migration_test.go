@@ -0,0 +1,142 @@
+package migration_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/migration"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+// openSQLite opens an empty SQLite database the way a service would, through database/sql
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	conn, err := sql.Open(migration.DriverSQLite, filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("failed to open SQLite database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Ping(); err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") {
+			t.Skip("SQLite needs cgo")
+		}
+		t.Fatalf("failed to open SQLite database: %v", err)
+	}
+	return conn
+}
+
+// TestMigrator_Run tests a run through the public API, with a fixed clock and batch IDs
+func TestMigrator_Run(t *testing.T) {
+	conn := openSQLite(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.AddSQLScript(scriptsDir, "002_posts.sql", "CREATE TABLE posts (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users and posts")
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := migration.NewMigrator(conn, migration.Config{Driver: migration.DriverSQLite, ScriptsDir: scriptsDir},
+		migration.WithClock(testkit.NewFakeClock(now)), migration.WithIDGenerator(testkit.NewSequentialIDs("batch")),
+		migration.WithOutput(io.Discard))
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// Nothing is pending the second time, so no batch is started
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+
+	rows, err := conn.Query("SELECT scriptName, batchid, createddatetime FROM sqlScriptExec ORDER BY sno")
+	if err != nil {
+		t.Fatalf("failed to read the tracking table: %v", err)
+	}
+	defer rows.Close()
+	var scripts []string
+	for rows.Next() {
+		var script, batchID string
+		var created time.Time
+		if err := rows.Scan(&script, &batchID, &created); err != nil {
+			t.Fatal(err)
+		}
+		if batchID != "batch-1" {
+			t.Errorf("%s: expected batch batch-1, got %s", script, batchID)
+		}
+		if !created.Equal(now) {
+			t.Errorf("%s: expected it to be recorded at %v, got %v", script, now, created)
+		}
+		scripts = append(scripts, script)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(scripts, ","); got != "001_users.sql,002_posts.sql" {
+		t.Errorf("expected 001_users.sql and 002_posts.sql to be recorded, got %s", got)
+	}
+}
+
+// TestMigrator_RunCancelled tests that a done context stops a run before any script
+func TestMigrator_RunCancelled(t *testing.T) {
+	conn := openSQLite(t)
+	repo := testkit.SetupGitRepo(t)
+	scriptsDir := repo.CreateScriptsDir("scripts")
+	repo.AddSQLScript(scriptsDir, "001_users.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);")
+	repo.CommitScripts("add users")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := migration.NewMigrator(conn, migration.Config{Driver: migration.DriverSQLite, ScriptsDir: scriptsDir}, migration.WithOutput(io.Discard))
+	if err := m.Run(ctx); err == nil {
+		t.Fatal("expected Run to fail with a cancelled context")
+	}
+
+	var tables int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'users'").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("expected 001_users.sql not to run")
+	}
+}
+
+// TestMigrator_WithFS tests scripts shipped in an fs.FS, without a git checkout
+func TestMigrator_WithFS(t *testing.T) {
+	ctx := context.Background()
+	conn := openSQLite(t)
+	scripts := fstest.MapFS{
+		"001_users.sql": {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+	}
+	cfg := migration.Config{Driver: migration.DriverSQLite}
+
+	if err := migration.NewMigrator(conn, cfg, migration.WithFS(scripts), migration.WithOutput(io.Discard)).Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The next release ships another script
+	scripts["002_posts.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY);")}
+	inspector := migration.NewInspector(conn, cfg, migration.WithFS(scripts))
+	pending, err := inspector.PendingScripts(ctx)
+	if err != nil {
+		t.Fatalf("PendingScripts failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Name != "002_posts.sql" {
+		t.Errorf("expected 002_posts.sql to be pending, got %+v", pending)
+	}
+	if err := migration.NewMigrator(conn, cfg, migration.WithFS(scripts), migration.WithOutput(io.Discard)).Run(ctx); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+
+	var tables int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('users', 'posts')").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 2 {
+		t.Errorf("expected users and posts to be created, got %d tables", tables)
+	}
+}
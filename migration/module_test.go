@@ -0,0 +1,108 @@
+package migration_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// consumer is a service in another module; it can only build if everything it uses
+// is reachable without importing internal packages
+const consumer = `package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/migration"
+)
+
+type clock struct{}
+
+func (clock) Now() time.Time { return time.Unix(0, 0) }
+
+type ids struct{}
+
+func (ids) NewID() string { return "batch" }
+
+func main() {
+	conn, err := sql.Open(migration.DriverSQLite, "app.db")
+	if err != nil {
+		panic(err)
+	}
+	cfg := migration.Config{Driver: migration.DriverSQLite, ScriptsDir: "scripts"}
+	m := migration.NewMigrator(conn, cfg, migration.WithClock(clock{}), migration.WithIDGenerator(ids{}), migration.WithOutput(io.Discard))
+	if err := m.Run(context.Background()); err != nil {
+		panic(err)
+	}
+
+	inspector := migration.NewInspector(conn, cfg, migration.WithFS(os.DirFS("scripts")))
+	var pending []migration.Script
+	pending, err = inspector.PendingScripts(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(len(pending), "scripts pending")
+}
+`
+
+// consumerTest is a test of the service, run against a throwaway MySQL of testkit
+const consumerTest = `package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/migration"
+	"github.com/bontaramsonta/db-migration/testkit"
+)
+
+func TestMigrations(t *testing.T) {
+	testDB := testkit.StartMySQL(t)
+	m := migration.NewMigrator(testDB.DB, migration.Config{ScriptsDir: "scripts"})
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	testDB.AssertTableExists("users")
+}
+`
+
+// TestExternalModule builds a program of another module and its tests against the
+// public API and testkit
+func TestExternalModule(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping module build in short mode")
+	}
+
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	mod := "module example.com/service\n\ngo 1.24.0\n\n" +
+		"require github.com/bontaramsonta/db-migration v0.0.0\n\n" +
+		"replace github.com/bontaramsonta/db-migration => " + root + "\n"
+	files := map[string]string{"go.mod": mod, "go.sum": string(sum), "main.go": consumer, "main_test.go": consumerTest}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "vet", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\n%s", err, out)
+	}
+}
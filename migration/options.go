@@ -0,0 +1,95 @@
+package migration
+
+import (
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+	"github.com/bontaramsonta/db-migration/internal/migration"
+)
+
+// Clock provides the current time recorded with each script
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator produces the IDs of migration batches
+type IDGenerator interface {
+	NewID() string
+}
+
+// Option configures optional dependencies of a Migrator or Inspector
+type Option func(*options)
+
+// options holds the settings of the options given to a Migrator or Inspector
+type options struct {
+	clock  Clock
+	ids    IDGenerator
+	output io.Writer
+	fsys   fs.FS
+}
+
+// WithClock overrides the clock used for timestamps
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithIDGenerator overrides the generator used for batch IDs
+func WithIDGenerator(g IDGenerator) Option {
+	return func(o *options) {
+		o.ids = g
+	}
+}
+
+// WithFS reads scripts from fsys, e.g. an embed.FS, instead of a git checkout; the
+// root of fsys is the scripts directory
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) {
+		o.fsys = fsys
+	}
+}
+
+// WithOutput writes the progress of runs to w instead of stdout and stderr, e.g.
+// io.Discard to keep a service's own logs clean
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.output = w
+	}
+}
+
+// buildOptions applies opts on top of the defaults
+func buildOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// internal returns the options of the migrator
+func (o options) internal() []migration.Option {
+	var opts []migration.Option
+	if o.clock != nil {
+		opts = append(opts, migration.WithClock(o.clock))
+	}
+	if o.ids != nil {
+		opts = append(opts, migration.WithIDGenerator(o.ids))
+	}
+	if o.fsys != nil {
+		opts = append(opts, migration.WithFS(o.fsys))
+	}
+	return opts
+}
+
+// console returns the console runs write their progress to
+func (o options) console() *console.Console {
+	cons := console.New()
+	if o.output != nil {
+		cons.SetOutput(o.output)
+		cons.SetErrorOutput(o.output)
+	}
+	return cons
+}
@@ -0,0 +1,66 @@
+// Package testkit provides fakes and helpers for testing code that embeds the
+// migration library or ships migration scripts, such as deterministic clocks and ID
+// generators, throwaway MySQL servers, seeded git repositories and assertions on the
+// tracking table.
+package testkit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeClock is a manually controlled clock
+// It satisfies migration.Clock
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock creates a FakeClock frozen at the given time
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now returns the clock's current time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to the given time
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SequentialIDs generates predictable IDs of the form "<prefix>-1", "<prefix>-2", ...
+// It satisfies migration.IDGenerator
+type SequentialIDs struct {
+	mu     sync.Mutex
+	prefix string
+	next   int
+}
+
+// NewSequentialIDs creates a SequentialIDs generator with the given prefix
+func NewSequentialIDs(prefix string) *SequentialIDs {
+	return &SequentialIDs{prefix: prefix, next: 1}
+}
+
+// NewID returns the next ID in the sequence
+func (g *SequentialIDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := fmt.Sprintf("%s-%d", g.prefix, g.next)
+	g.next++
+	return id
+}
@@ -1,6 +1,7 @@
-package testhelpers
+package testkit
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
 	"testing"
@@ -11,13 +12,17 @@ import (
 
 // TestDatabase wraps a MySQL database connection for testing
 type TestDatabase struct {
-	DB       *db.DB
+	DB       *sql.DB
+	Driver   string // database/sql driver DB was opened with, "mysql" or "sqlite3"
 	DSN      string
 	Host     string
 	Port     string
 	User     string
 	Password string
 	DBName   string
+	t        testing.TB
+	// db runs the tool's dialect queries of the helpers on DB
+	db *db.DB
 }
 
 // getEnvOrDefault returns the environment variable value or the default
@@ -28,28 +33,40 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-// SetupTestDB connects to the docker-compose MySQL and returns a test database instance.
-// It waits for MySQL to become healthy with retries, then resets the database to ensure a clean state.
-func SetupTestDB(t *testing.T) *TestDatabase {
+// SetupTestDB connects to the docker-compose MySQL, or the server given by the
+// TEST_DB_HOST, TEST_DB_PORT, TEST_DB_USER, TEST_DB_PASSWORD and TEST_DB_NAME variables,
+// and returns a test database instance. It waits for MySQL to become healthy with
+// retries, then resets the database to ensure a clean state.
+func SetupTestDB(t testing.TB) *TestDatabase {
 	t.Helper()
 
-	host := getEnvOrDefault("TEST_DB_HOST", "127.0.0.1")
-	port := getEnvOrDefault("TEST_DB_PORT", "3307")
-	user := getEnvOrDefault("TEST_DB_USER", "testuser")
-	password := getEnvOrDefault("TEST_DB_PASSWORD", "testpassword")
-	dbName := getEnvOrDefault("TEST_DB_NAME", "testdb")
+	return Connect(t,
+		getEnvOrDefault("TEST_DB_HOST", "127.0.0.1"),
+		getEnvOrDefault("TEST_DB_PORT", "3307"),
+		getEnvOrDefault("TEST_DB_USER", "testuser"),
+		getEnvOrDefault("TEST_DB_PASSWORD", "testpassword"),
+		getEnvOrDefault("TEST_DB_NAME", "testdb"),
+	)
+}
+
+// Connect connects to a MySQL database for t, retrying while the server starts, and
+// resets it to a clean state; the connection is closed when t finishes
+func Connect(t testing.TB, host, port, user, password, dbName string) *TestDatabase {
+	t.Helper()
 
 	// Build DSN
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true", user, password, host, port, dbName)
 
 	// Connect to database with retries (wait for MySQL to be healthy)
-	var database *db.DB
-	var err error
+	conn, err := sql.Open(db.DriverMySQL, dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
 	maxRetries := 10
 	retryInterval := 2 * time.Second
 
 	for i := 0; i < maxRetries; i++ {
-		database, err = db.Connect(dsn)
+		err = conn.Ping()
 		if err == nil {
 			break
 		}
@@ -59,22 +76,26 @@ func SetupTestDB(t *testing.T) *TestDatabase {
 		}
 	}
 	if err != nil {
+		conn.Close()
 		t.Fatalf("failed to connect to test database after %d attempts: %v\nMake sure MySQL is running with: docker compose up -d", maxRetries, err)
 	}
 
 	testDB := &TestDatabase{
-		DB:       database,
+		DB:       conn,
+		Driver:   db.DriverMySQL,
 		DSN:      dsn,
 		Host:     host,
 		Port:     port,
 		User:     user,
 		Password: password,
 		DBName:   dbName,
+		t:        t,
+		db:       db.New(conn, db.DriverMySQL, nil),
 	}
 
 	// Reset database to clean state before each test
 	if err := testDB.ResetDatabase(); err != nil {
-		database.Close()
+		conn.Close()
 		t.Fatalf("failed to reset test database: %v", err)
 	}
 
@@ -111,6 +132,12 @@ func (r *SingleRow) Scan(dest ...interface{}) error {
 
 // TableExists checks if a table exists in the database
 func (td *TestDatabase) TableExists(tableName string) (bool, error) {
+	if td.Driver != db.DriverMySQL {
+		var count int
+		err := td.DB.QueryRow(td.db.Dialect().TableExistsQuery(), tableName).Scan(&count)
+		return count > 0, err
+	}
+
 	var count int
 	err := td.DB.QueryRow(
 		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?",
@@ -141,36 +168,6 @@ func (td *TestDatabase) InsertTrackingRecord(scriptName string, completed bool,
 	return err
 }
 
-// GetTrackingRecords returns all records from the tracking table
-func (td *TestDatabase) GetTrackingRecords() ([]TrackingRecord, error) {
-	rows, err := td.DB.Query(
-		"SELECT sno, scriptName, completed, endofbatch, COALESCE(lastgitid, '') FROM sqlScriptExec ORDER BY sno ASC",
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var records []TrackingRecord
-	for rows.Next() {
-		var rec TrackingRecord
-		if err := rows.Scan(&rec.SNO, &rec.ScriptName, &rec.Completed, &rec.EndOfBatch, &rec.LastGitID); err != nil {
-			return nil, err
-		}
-		records = append(records, rec)
-	}
-	return records, nil
-}
-
-// TrackingRecord represents a row in the sqlScriptExec table
-type TrackingRecord struct {
-	SNO        int
-	ScriptName string
-	Completed  bool
-	EndOfBatch bool
-	LastGitID  string
-}
-
 // ColumnExists checks if a column exists in a table
 func (td *TestDatabase) ColumnExists(tableName, columnName string) (bool, error) {
 	var count int
@@ -0,0 +1,363 @@
+package testkit
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/git"
+)
+
+// FakeRepo is a git repository kept in memory, for unit tests that need no git binary.
+// Files live in a temporary directory like a checkout; each commit snapshots it
+type FakeRepo struct {
+	Dir     string
+	t       testing.TB
+	commits []fakeCommit
+	// Each commit is one minute after the previous, so scripts order deterministically
+	nextTime time.Time
+}
+
+// fakeCommit is a snapshot of the repository, with root-relative slash paths
+type fakeCommit struct {
+	hash    string
+	message string
+	time    time.Time
+	files   map[string]string
+}
+
+// NewFakeRepo creates an empty fake repository in a temporary directory
+func NewFakeRepo(t testing.TB) *FakeRepo {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	return &FakeRepo{Dir: dir, t: t, nextTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// CreateScriptsDir creates a subdirectory for SQL scripts and returns the path
+func (r *FakeRepo) CreateScriptsDir(dirName string) string {
+	r.t.Helper()
+
+	scriptsDir := filepath.Join(r.Dir, dirName)
+	if err := os.MkdirAll(scriptsDir, 0755); err != nil {
+		r.t.Fatalf("failed to create scripts directory: %v", err)
+	}
+	return scriptsDir
+}
+
+// AddSQLScript writes a SQL script to the scripts directory; it is part of the next commit
+func (r *FakeRepo) AddSQLScript(scriptsDir, filename, content string) string {
+	r.t.Helper()
+
+	path := filepath.Join(scriptsDir, filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatalf("failed to create directory for %s: %v", filename, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		r.t.Fatalf("failed to write %s: %v", filename, err)
+	}
+	return path
+}
+
+// ModifyFile rewrites a file, given relative to the repository root
+func (r *FakeRepo) ModifyFile(relPath, content string) {
+	r.t.Helper()
+
+	if err := os.WriteFile(filepath.Join(r.Dir, relPath), []byte(content), 0644); err != nil {
+		r.t.Fatalf("failed to modify %s: %v", relPath, err)
+	}
+}
+
+// DeleteFile removes a file, given relative to the repository root
+func (r *FakeRepo) DeleteFile(relPath string) {
+	r.t.Helper()
+
+	if err := os.Remove(filepath.Join(r.Dir, relPath)); err != nil {
+		r.t.Fatalf("failed to delete %s: %v", relPath, err)
+	}
+}
+
+// CommitScripts commits every file in the directory, like git add -A && git commit,
+// and returns the commit hash
+func (r *FakeRepo) CommitScripts(message string) string {
+	r.t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(r.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.Dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		r.t.Fatalf("failed to snapshot fake repository: %v", err)
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d\x00%s", len(r.commits), message)))
+	commit := fakeCommit{hash: hex.EncodeToString(sum[:]), message: message, time: r.nextTime, files: files}
+	r.commits = append(r.commits, commit)
+	r.nextTime = r.nextTime.Add(time.Minute)
+	return commit.hash
+}
+
+// At returns the git operations of the working directory dir, as git.New(dir) does
+// for a real checkout; pass it to migration.WithRepository
+func (r *FakeRepo) At(dir string) *FakeGit {
+	return &FakeGit{repo: r, workDir: dir}
+}
+
+// commit returns the commit with the given hash or HEAD, or nil for "" (before the first)
+func (r *FakeRepo) commit(ref string) (*fakeCommit, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	if ref == "HEAD" && len(r.commits) > 0 {
+		return &r.commits[len(r.commits)-1], nil
+	}
+	for i := range r.commits {
+		if r.commits[i].hash == ref {
+			return &r.commits[i], nil
+		}
+	}
+	return nil, fmt.Errorf("fatal: bad revision '%s'", ref)
+}
+
+// FakeGit is a working directory of a FakeRepo. It implements migration.Repository
+type FakeGit struct {
+	repo    *FakeRepo
+	workDir string
+}
+
+// GetCurrentCommit returns the hash of the last commit
+func (g *FakeGit) GetCurrentCommit() (string, error) {
+	if len(g.repo.commits) == 0 {
+		return "", fmt.Errorf("fatal: ambiguous argument 'HEAD': unknown revision")
+	}
+	return g.repo.commits[len(g.repo.commits)-1].hash, nil
+}
+
+// ResolveCommit returns the hash of the commit with the given hash or hash prefix
+func (g *FakeGit) ResolveCommit(ref string) (string, error) {
+	for _, commit := range g.repo.commits {
+		if ref != "" && strings.HasPrefix(commit.hash, ref) {
+			return commit.hash, nil
+		}
+	}
+	if ref == "HEAD" {
+		return g.GetCurrentCommit()
+	}
+	return "", fmt.Errorf("unknown commit %s", ref)
+}
+
+// ReadFile returns the content of a file at a commit, with path relative to the
+// repository root
+func (g *FakeGit) ReadFile(commit, path string) ([]byte, error) {
+	c, err := g.repo.commit(commit)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		if content, ok := c.files[path]; ok {
+			return []byte(content), nil
+		}
+	}
+	return nil, fmt.Errorf("%s does not exist at commit %s: %w", path, commit, fs.ErrNotExist)
+}
+
+// Prefix returns the working directory's path relative to the repository root
+func (g *FakeGit) Prefix() (string, error) {
+	rel, err := filepath.Rel(g.repo.Dir, g.workDir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("fatal: %s is outside repository at %s", g.workDir, g.repo.Dir)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel) + "/", nil
+}
+
+// TopLevel returns the repository root
+func (g *FakeGit) TopLevel() (string, error) {
+	return g.repo.Dir, nil
+}
+
+// IsGitRepository reports whether the working directory is inside the repository
+func (g *FakeGit) IsGitRepository() bool {
+	_, err := g.Prefix()
+	return err == nil
+}
+
+// ListFiles returns the files of a commit under the working directory, relative to it
+func (g *FakeGit) ListFiles(commit string) ([]string, error) {
+	c, err := g.repo.commit(commit)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	prefix, err := g.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for name := range c.files {
+		if rel, ok := strings.CutPrefix(name, prefix); ok {
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// DiffFileStatus returns the files changed between two commits with their status
+// (A/M/D), relative to the repository root; fromCommit "" compares with nothing
+func (g *FakeGit) DiffFileStatus(fromCommit, toCommit string) (map[string]string, error) {
+	from, err := g.repo.commit(fromCommit)
+	if err != nil {
+		return nil, err
+	}
+	to, err := g.repo.commit(toCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	var before, after map[string]string
+	if from != nil {
+		before = from.files
+	}
+	if to != nil {
+		after = to.files
+	}
+	status := make(map[string]string)
+	for name, content := range after {
+		old, ok := before[name]
+		switch {
+		case !ok:
+			status[name] = "A"
+		case old != content:
+			status[name] = "M"
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			status[name] = "D"
+		}
+	}
+	return status, nil
+}
+
+// GetChangedScripts returns the SQL scripts changed between commits, sorted by the
+// time they were added, with the filters of git.Git.GetChangedScripts
+func (g *FakeGit) GetChangedScripts(fromCommit, toCommit, scriptsDir string) ([]git.ScriptInfo, error) {
+	status, err := g.DiffFileStatus(fromCommit, toCommit)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(status))
+	for name := range status {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	var scripts []git.ScriptInfo
+	for _, file := range files {
+		if !git.IsScript(file) || git.IsDownScript(file) || git.IsRepeatable(file) {
+			continue
+		}
+		scripts = append(scripts, git.ScriptInfo{
+			Name:      path.Base(file),
+			Path:      file,
+			Timestamp: g.repo.addedAt(file),
+		})
+	}
+	sort.SliceStable(scripts, func(i, j int) bool {
+		return scripts[i].Timestamp.Before(scripts[j].Timestamp)
+	})
+	return scripts, nil
+}
+
+// GetFileCommitTimestamp returns the time of the commit that added a file, given
+// relative to the working directory or absolute
+func (g *FakeGit) GetFileCommitTimestamp(file string) (time.Time, error) {
+	name, err := g.rootRelative(file)
+	if err != nil {
+		return time.Now(), nil
+	}
+	return g.repo.addedAt(name), nil
+}
+
+// CommitMessages returns the messages of the commits that changed a path, newest
+// first; relative paths are taken from the repository root
+func (g *FakeGit) CommitMessages(file string) ([]string, error) {
+	name := filepath.ToSlash(file)
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(g.repo.Dir, file)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.ToSlash(rel)
+	}
+
+	var messages []string
+	previous, existed := "", false
+	for _, c := range g.repo.commits {
+		content, exists := c.files[name]
+		if exists != existed || content != previous {
+			messages = append([]string{c.message}, messages...)
+		}
+		previous, existed = content, exists
+	}
+	return messages, nil
+}
+
+// Upstream returns "", since a fake repository has no remote
+func (g *FakeGit) Upstream() string {
+	return ""
+}
+
+// Pull does nothing, since a fake repository has no remote
+func (g *FakeGit) Pull() error {
+	return nil
+}
+
+// rootRelative converts a path relative to the working directory, or absolute, to a
+// slash path relative to the repository root
+func (g *FakeGit) rootRelative(file string) (string, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(g.workDir, file)
+	}
+	rel, err := filepath.Rel(g.repo.Dir, file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// addedAt returns the time of the first commit containing a root-relative file, or the
+// current time if none does, as git.Git does for files never committed
+func (r *FakeRepo) addedAt(name string) time.Time {
+	for _, c := range r.commits {
+		if _, ok := c.files[name]; ok {
+			return c.time
+		}
+	}
+	return time.Now()
+}
@@ -1,4 +1,4 @@
-package testhelpers
+package testkit
 
 import (
 	"fmt"
@@ -11,11 +11,11 @@ import (
 // GitRepo provides test helpers for creating and managing git repositories
 type GitRepo struct {
 	Dir string
-	t   *testing.T
+	t   testing.TB
 }
 
 // SetupGitRepo creates a new git repository in a temporary directory
-func SetupGitRepo(t *testing.T) *GitRepo {
+func SetupGitRepo(t testing.TB) *GitRepo {
 	t.Helper()
 
 	dir := t.TempDir()
@@ -145,6 +145,10 @@ func (r *GitRepo) AddSQLScript(scriptsDir, filename, content string) string {
 	relPath := filepath.Join(relScriptsDir, filename)
 	fullPath := filepath.Join(r.Dir, relPath)
 
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		r.t.Fatalf("failed to create directory for %s: %v", fullPath, err)
+	}
+
 	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
 		r.t.Fatalf("failed to write script %s: %v", fullPath, err)
 	}
@@ -180,4 +184,3 @@ func (r *GitRepo) MustReadFile(path string) string {
 func (r *GitRepo) String() string {
 	return fmt.Sprintf("GitRepo{Dir: %s}", r.Dir)
 }
-
@@ -0,0 +1,72 @@
+package testkit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bontaramsonta/db-migration/internal/console"
+)
+
+// UpdateGoldenEnv names the environment variable that makes Golden rewrite its files
+// instead of comparing, e.g. UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// ansiPattern matches the color and style escape sequences of the console
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// StripANSI removes color and style escape sequences, so snapshots hold plain text
+func StripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// Golden compares got with the golden file at path, usually under testdata/golden, and
+// fails t with both versions when they differ. With UPDATE_GOLDEN set, the file is
+// written instead, so a deliberate format change is one rerun and a reviewed diff
+func Golden(t testing.TB, path string, got []byte) {
+	t.Helper()
+
+	got = []byte(StripANSI(string(got)))
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (rerun with %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (rerun with %s=1 if the change is intended)\n--- got ---\n%s\n--- want ---\n%s", path, UpdateGoldenEnv, got, want)
+	}
+}
+
+// CapturedConsole is a console recording everything it prints, errors included, in
+// order, with timestamps taken from a FakeClock
+type CapturedConsole struct {
+	*console.Console
+	Clock *FakeClock
+	out   bytes.Buffer
+}
+
+// NewCapturedConsole returns a text console with its clock frozen at now
+func NewCapturedConsole(now time.Time) *CapturedConsole {
+	c := &CapturedConsole{Console: console.New(), Clock: NewFakeClock(now)}
+	c.SetOutput(&c.out)
+	c.SetErrorOutput(&c.out)
+	c.SetClock(c.Clock)
+	return c
+}
+
+// Output returns what was printed so far, without colors
+func (c *CapturedConsole) Output() string {
+	return StripANSI(c.out.String())
+}
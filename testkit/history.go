@@ -0,0 +1,96 @@
+package testkit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// historyStart is the commit time of the first generated commit; later ones follow a
+// second apart
+const historyStart = 1700000000
+
+// HistoryScript returns the name of the nth script (from 1) of GenerateHistory
+func HistoryScript(n int) string {
+	return fmt.Sprintf("%05d_script.sql", n)
+}
+
+// GenerateHistory builds a history on a new main branch with git fast-import, which
+// writes tens of thousands of commits in seconds. Every commit changes a file outside
+// scriptsDir; scripts of them, spread evenly, also add one migration script (see
+// HistoryScript). The branch is checked out, and the hashes of the commits adding each
+// script are returned, in order
+func (r *GitRepo) GenerateHistory(scriptsDir string, commits, scripts int) []string {
+	r.t.Helper()
+
+	if scripts > commits {
+		r.t.Fatalf("cannot add %d scripts in %d commits", scripts, commits)
+	}
+	relScriptsDir, err := filepath.Rel(r.Dir, scriptsDir)
+	if err != nil {
+		r.t.Fatalf("failed to get relative path: %v", err)
+	}
+	marks := filepath.Join(r.t.TempDir(), "marks")
+
+	cmd := exec.Command("git", "fast-import", "--quiet", "--export-marks="+marks)
+	cmd.Dir = r.Dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		r.t.Fatalf("failed to start git fast-import: %v", err)
+	}
+	var output strings.Builder
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Start(); err != nil {
+		r.t.Fatalf("failed to start git fast-import: %v", err)
+	}
+
+	w := bufio.NewWriter(stdin)
+	data := func(s string) { fmt.Fprintf(w, "data %d\n%s\n", len(s), s) }
+	every := commits / max(scripts, 1)
+	var scriptMarks []int
+	for i := 1; i <= commits; i++ {
+		fmt.Fprintf(w, "commit refs/heads/main\nmark :%d\ncommitter Test User <test@test.com> %d +0000\n", i, historyStart+i)
+		data(fmt.Sprintf("Commit %d", i))
+		if i > 1 {
+			fmt.Fprintf(w, "from :%d\n", i-1)
+		}
+		fmt.Fprintf(w, "M 100644 inline app/version.txt\n")
+		data(fmt.Sprintf("%d", i))
+		if n := len(scriptMarks) + 1; n <= scripts && i%every == 0 {
+			fmt.Fprintf(w, "M 100644 inline %s\n", filepath.ToSlash(filepath.Join(relScriptsDir, HistoryScript(n))))
+			data(fmt.Sprintf("CREATE TABLE t%05d (id INTEGER PRIMARY KEY);", n))
+			scriptMarks = append(scriptMarks, i)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		r.t.Fatalf("failed to write to git fast-import: %v", err)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		r.t.Fatalf("git fast-import failed: %v\nOutput: %s", err, output.String())
+	}
+
+	r.runGit("symbolic-ref", "HEAD", "refs/heads/main")
+	r.runGit("reset", "--hard", "--quiet")
+
+	content, err := os.ReadFile(marks)
+	if err != nil {
+		r.t.Fatalf("failed to read git fast-import marks: %v", err)
+	}
+	hashes := make(map[int]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var mark int
+		var hash string
+		if _, err := fmt.Sscanf(line, ":%d %s", &mark, &hash); err == nil {
+			hashes[mark] = hash
+		}
+	}
+	added := make([]string, len(scriptMarks))
+	for n, mark := range scriptMarks {
+		added[n] = hashes[mark]
+	}
+	return added
+}
@@ -0,0 +1,136 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// DefaultMySQLImage is the image StartMySQL runs
+const DefaultMySQLImage = "mysql:8.0"
+
+// mysqlPort is the port MySQL listens on inside the container
+const mysqlPort = "3306/tcp"
+
+// MySQLContainer is a throwaway MySQL server in a Docker container, started with
+// testcontainers-go so tests need nothing but a Docker daemon
+type MySQLContainer struct {
+	ID       string
+	Host     string
+	Port     string
+	User     string
+	Password string
+	DBName   string
+
+	container testcontainers.Container
+}
+
+// StartMySQL runs a MySQL container for t and connects to it; the container is removed
+// when t finishes. Tests are skipped when no Docker daemon is reachable. Each call starts
+// a new server, so packages with many tests share one from RunMySQL in TestMain instead
+func StartMySQL(t testing.TB) *TestDatabase {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	if err := dockerHealth(ctx); err != nil {
+		t.Skipf("Docker is not available: %v", err)
+	}
+	container, err := RunMySQL(ctx, DefaultMySQLImage)
+	if err != nil {
+		t.Fatalf("failed to start MySQL: %v", err)
+	}
+	t.Cleanup(func() { container.Terminate() })
+	return container.Connect(t)
+}
+
+// RunMySQL starts image, publishing MySQL on a free local port, and waits until it
+// accepts connections
+func RunMySQL(ctx context.Context, image string) (*MySQLContainer, error) {
+	c := &MySQLContainer{User: "testuser", Password: "testpassword", DBName: "testdb"}
+
+	// The image initializes with networking off, so the first query that succeeds is
+	// answered by the final server
+	ready := wait.ForSQL(mysqlPort, db.DriverMySQL, func(host string, port nat.Port) string {
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s", c.User, c.Password, net.JoinHostPort(host, port.Port()), c.DBName)
+	}).WithStartupTimeout(3 * time.Minute)
+
+	container, err := testcontainers.Run(ctx, image,
+		testcontainers.WithEnv(map[string]string{
+			"MYSQL_ROOT_PASSWORD": "rootpassword",
+			"MYSQL_DATABASE":      c.DBName,
+			"MYSQL_USER":          c.User,
+			"MYSQL_PASSWORD":      c.Password,
+		}),
+		testcontainers.WithExposedPorts(mysqlPort),
+		testcontainers.WithWaitStrategy(ready),
+	)
+	if container != nil {
+		c.container = container
+		c.ID = container.GetContainerID()
+	}
+	if err != nil {
+		c.Terminate()
+		return nil, fmt.Errorf("failed to start MySQL container: %w", err)
+	}
+
+	c.Host, err = container.Host(ctx)
+	if err == nil {
+		var port nat.Port
+		port, err = container.MappedPort(ctx, mysqlPort)
+		c.Port = port.Port()
+	}
+	if err != nil {
+		c.Terminate()
+		return nil, fmt.Errorf("failed to find the MySQL port of container %s: %w", c.ID, err)
+	}
+	return c, nil
+}
+
+// DSN returns the data source name of the container's database
+func (c *MySQLContainer) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true", c.User, c.Password, c.Host, c.Port, c.DBName)
+}
+
+// Connect connects t to the container's database, reset to a clean state
+func (c *MySQLContainer) Connect(t testing.TB) *TestDatabase {
+	t.Helper()
+
+	return Connect(t, c.Host, c.Port, c.User, c.Password, c.DBName)
+}
+
+// Terminate stops and removes the container
+func (c *MySQLContainer) Terminate() error {
+	if c.container == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return c.container.Terminate(ctx)
+}
+
+// dockerHealth returns why the Docker daemon of testcontainers cannot be reached, if
+// it cannot
+func dockerHealth(ctx context.Context) (err error) {
+	// The client panics instead of failing on some hosts without Docker
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+	return provider.Health(ctx)
+}
@@ -0,0 +1,17 @@
+package testkit
+
+import "testing"
+
+func TestStartMySQL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	testDB := StartMySQL(t)
+	if _, err := testDB.DB.Exec("CREATE TABLE users (id INT PRIMARY KEY)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	testDB.AssertTableExists("users")
+	testDB.AssertNoTable("posts")
+	testDB.AssertNotApplied("001_create_users.sql")
+}
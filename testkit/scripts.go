@@ -1,4 +1,4 @@
-package testhelpers
+package testkit
 
 import (
 	"os"
@@ -13,6 +13,8 @@ var SQLScripts = struct {
 	AddIndexes     string
 	CreateComments string
 	CreateTags     string
+	DropComments   string
+	DropTags       string
 	InvalidSyntax  string
 }{
 	CreateUsers: `CREATE TABLE users (
@@ -57,12 +59,17 @@ CREATE TABLE post_tags (
     FOREIGN KEY (tag_id) REFERENCES tags(id)
 );`,
 
+	DropComments: `DROP TABLE comments;`,
+
+	DropTags: `DROP TABLE post_tags;
+DROP TABLE tags;`,
+
 	// Script with invalid SQL syntax for failure testing
 	InvalidSyntax: `CREATE TABLE invalid_table;`,
 }
 
 // CreateSQLScript writes a SQL script file to the specified directory
-func CreateSQLScript(t *testing.T, dir, filename, content string) string {
+func CreateSQLScript(t testing.TB, dir, filename, content string) string {
 	t.Helper()
 
 	fullPath := filepath.Join(dir, filename)
@@ -81,7 +88,7 @@ func CreateSQLScript(t *testing.T, dir, filename, content string) string {
 
 // CreateTestScripts writes multiple SQL scripts to a directory
 // scripts is a map of filename -> content
-func CreateTestScripts(t *testing.T, dir string, scripts map[string]string) {
+func CreateTestScripts(t testing.TB, dir string, scripts map[string]string) {
 	t.Helper()
 
 	for filename, content := range scripts {
@@ -106,6 +113,14 @@ func IncrementalScripts() map[string]string {
 	}
 }
 
+// IncrementalDownScripts returns the down scripts paired with IncrementalScripts
+func IncrementalDownScripts() map[string]string {
+	return map[string]string{
+		"004_create_comments.down.sql": SQLScripts.DropComments,
+		"005_create_tags.down.sql":     SQLScripts.DropTags,
+	}
+}
+
 // FailingScripts returns scripts where the second one has invalid syntax
 func FailingScripts() map[string]string {
 	return map[string]string{
@@ -139,4 +154,3 @@ func SimpleCreateTable(tableName string) string {
 func SimpleInsert(tableName, value string) string {
 	return `INSERT INTO ` + tableName + ` (data) VALUES ('` + value + `');`
 }
-
@@ -0,0 +1,32 @@
+package testkit
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bontaramsonta/db-migration/internal/db"
+)
+
+// OpenSQLite opens an empty SQLite database for t, so migrator logic and the tracking
+// table can be tested in -short mode without a MySQL server. Scripts run against it
+// must be SQLite-compatible. Tests are skipped when built without cgo
+func OpenSQLite(t testing.TB) *TestDatabase {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	conn, err := sql.Open(db.DriverSQLite, dsn)
+	if err != nil {
+		t.Fatalf("failed to open SQLite database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Ping(); err != nil {
+		if strings.Contains(err.Error(), "CGO_ENABLED=0") {
+			t.Skip("SQLite needs cgo")
+		}
+		t.Fatalf("failed to open SQLite database: %v", err)
+	}
+
+	return &TestDatabase{DB: conn, Driver: db.DriverSQLite, DSN: dsn, DBName: "main", t: t, db: db.New(conn, db.DriverSQLite, nil)}
+}
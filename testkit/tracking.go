@@ -0,0 +1,132 @@
+package testkit
+
+import (
+	"strings"
+	"time"
+)
+
+// TrackingRecord represents a row in the sqlScriptExec table
+type TrackingRecord struct {
+	SNO             int
+	ScriptName      string
+	Completed       bool
+	EndOfBatch      bool
+	LastGitID       string
+	BatchID         string
+	Action          string // up, down, skip, rerun or defer
+	CreatedDateTime time.Time
+}
+
+// GetTrackingRecords returns all records from the tracking table
+func (td *TestDatabase) GetTrackingRecords() ([]TrackingRecord, error) {
+	rows, err := td.DB.Query(
+		"SELECT sno, scriptName, completed, endofbatch, COALESCE(lastgitid, ''), COALESCE(batchid, ''), action, createddatetime FROM sqlScriptExec ORDER BY sno ASC",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []TrackingRecord
+	for rows.Next() {
+		var rec TrackingRecord
+		if err := rows.Scan(&rec.SNO, &rec.ScriptName, &rec.Completed, &rec.EndOfBatch, &rec.LastGitID, &rec.BatchID, &rec.Action, &rec.CreatedDateTime); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// AppliedScripts returns the scripts the tracking table holds as applied: completed by
+// up and not reverted by down since, in the order they were applied. None are applied
+// before the tracking table is created
+func (td *TestDatabase) AppliedScripts() ([]string, error) {
+	if exists, err := td.TableExists("sqlScriptExec"); err != nil || !exists {
+		return nil, err
+	}
+	records, err := td.GetTrackingRecords()
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	for _, rec := range records {
+		switch {
+		case rec.Action == "up" && rec.Completed:
+			applied = append(applied, rec.ScriptName)
+		case rec.Action == "down" && rec.Completed:
+			for i, name := range applied {
+				if name == rec.ScriptName {
+					applied = append(applied[:i], applied[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return applied, nil
+}
+
+// AssertApplied fails the test unless the applied scripts are exactly scripts, in order
+func (td *TestDatabase) AssertApplied(scripts ...string) {
+	td.t.Helper()
+
+	applied, err := td.AppliedScripts()
+	if err != nil {
+		td.t.Fatalf("failed to read the tracking table: %v", err)
+	}
+	if strings.Join(applied, ",") != strings.Join(scripts, ",") {
+		td.t.Errorf("expected applied scripts [%s], got [%s]", strings.Join(scripts, ", "), strings.Join(applied, ", "))
+	}
+}
+
+// AssertNotApplied fails the test if any of scripts is applied
+func (td *TestDatabase) AssertNotApplied(scripts ...string) {
+	td.t.Helper()
+
+	applied, err := td.AppliedScripts()
+	if err != nil {
+		td.t.Fatalf("failed to read the tracking table: %v", err)
+	}
+	for _, script := range scripts {
+		for _, name := range applied {
+			if name == script {
+				td.t.Errorf("expected %s not to be applied", script)
+			}
+		}
+	}
+}
+
+// AssertBatches fails the test unless the tracking table holds n batches
+func (td *TestDatabase) AssertBatches(n int) {
+	td.t.Helper()
+
+	records, err := td.GetTrackingRecords()
+	if err != nil {
+		td.t.Fatalf("failed to read the tracking table: %v", err)
+	}
+	batches := make(map[string]bool)
+	for _, rec := range records {
+		batches[rec.BatchID] = true
+	}
+	if len(batches) != n {
+		td.t.Errorf("expected %d batches, got %d", n, len(batches))
+	}
+}
+
+// AssertTableExists fails the test unless table exists
+func (td *TestDatabase) AssertTableExists(table string) {
+	td.t.Helper()
+
+	if exists, err := td.TableExists(table); err != nil || !exists {
+		td.t.Errorf("expected table %s to exist (err: %v)", table, err)
+	}
+}
+
+// AssertNoTable fails the test if table exists
+func (td *TestDatabase) AssertNoTable(table string) {
+	td.t.Helper()
+
+	if exists, err := td.TableExists(table); err != nil || exists {
+		td.t.Errorf("expected table %s not to exist (err: %v)", table, err)
+	}
+}